@@ -8,15 +8,39 @@ import (
 	"syscall"
 
 	"dashi/internal/app"
+	"dashi/internal/cli"
 	"dashi/internal/config"
 )
 
 func main() {
-	cfg := config.Load()
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		code := cli.Run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+		stop()
+		os.Exit(code)
+	}
+
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
-	logger.Info("starting dashi", "addr", cfg.Addr, "db", cfg.DBPath)
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Error("invalid configuration", "err", err)
+		os.Exit(1)
+	}
+	for _, w := range cfg.Warnings {
+		logger.Warn("configuration problem, running degraded", "problem", w)
+	}
+	if off := cfg.Disabled(); len(off) > 0 {
+		logger.Info("subsystems disabled", "disabled", off)
+	}
 
-	a, err := app.New(cfg, logger)
+	var a interface{ Run(context.Context) error }
+	if cfg.Mode == config.ModeAgent {
+		logger.Info("starting dashi agent", "server", cfg.AgentServer, "host", cfg.AgentName)
+		a, err = app.NewAgent(cfg, logger)
+	} else {
+		logger.Info("starting dashi", "addr", cfg.Addr, "db", cfg.DBPath)
+		a, err = app.New(cfg, logger)
+	}
 	if err != nil {
 		logger.Error("init failed", "err", err)
 		os.Exit(1)
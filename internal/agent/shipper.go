@@ -0,0 +1,257 @@
+// Package agent pushes what a dashi agent collects on its Docker host to a
+// dashi server, which stores it like the data of its own Docker hosts.
+package agent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/db"
+	"dashi/internal/docker"
+	"dashi/internal/models"
+)
+
+const (
+	// batchSize matches the most entries the server accepts per request.
+	batchSize = 1000
+	// spoolMaxAge is how long samples and log lines are kept while the
+	// server cannot be reached.
+	spoolMaxAge = 24 * time.Hour
+	// infoRefresh is how often the Docker daemon is asked again for the
+	// versions heartbeats report.
+	infoRefresh = time.Hour
+)
+
+// Shipper pushes the containers, container samples and log lines stored
+// in the agent's spool database to the /api/ingest endpoints of a server
+// and deletes them from the spool once the server accepted them. Every
+// push starts with a heartbeat, which registers the agent and tells the
+// server it is up. Pushes
+// are gzip-compressed and signed with the token (see api.Sign); batches of
+// samples and lines carry an idempotency key, so a batch pushed again after
+// a lost response is not stored twice.
+type Shipper struct {
+	repo   *db.Repository
+	docker *docker.Client
+	http   *http.Client
+	log    *slog.Logger
+	server string
+	token  string
+	host   string
+	every  time.Duration
+
+	info   docker.Info
+	infoAt time.Time
+
+	// The cursors are the last rowids pushed; rows up to them are deleted.
+	metricsAfter int64
+	logsAfter    int64
+}
+
+// NewShipper creates a shipper pushing to the dashi server at base URL
+// server, signed with token, under the Docker host name host, every
+// interval every. Heartbeats describe the daemon dc, if not nil.
+func NewShipper(repo *db.Repository, dc *docker.Client, logger *slog.Logger, server, token, host string, every time.Duration) *Shipper {
+	return &Shipper{
+		repo:   repo,
+		docker: dc,
+		http:   &http.Client{Timeout: 30 * time.Second},
+		log:    logger,
+		server: strings.TrimRight(server, "/"),
+		token:  token,
+		host:   host,
+		every:  every,
+	}
+}
+
+// Ship sends a heartbeat and pushes the current containers, then the
+// spooled samples and log lines in batches. It stops at the first failed
+// request; what was not accepted is pushed by the next call.
+func (s *Shipper) Ship(ctx context.Context) error {
+	if err := s.heartbeat(ctx); err != nil {
+		return fmt.Errorf("send heartbeat: %w", err)
+	}
+	if err := s.shipContainers(ctx); err != nil {
+		return fmt.Errorf("push containers: %w", err)
+	}
+	for {
+		samples, last, err := s.repo.SpooledContainerMetrics(ctx, s.metricsAfter, batchSize)
+		if err != nil {
+			return fmt.Errorf("read spooled metrics: %w", err)
+		}
+		if len(samples) == 0 {
+			break
+		}
+		if err := s.post(ctx, "/api/ingest/metrics", api.IngestMetrics{Host: s.host, Containers: api.ContainerMetricsFrom(samples)}, true); err != nil {
+			return fmt.Errorf("push metrics: %w", err)
+		}
+		s.metricsAfter = last
+		if len(samples) < batchSize {
+			break
+		}
+	}
+	for {
+		entries, last, err := s.repo.SpooledLogs(ctx, s.logsAfter, batchSize)
+		if err != nil {
+			return fmt.Errorf("read spooled logs: %w", err)
+		}
+		if len(entries) == 0 {
+			break
+		}
+		if err := s.post(ctx, "/api/ingest/logs", ingestLogs(entries), true); err != nil {
+			return fmt.Errorf("push logs: %w", err)
+		}
+		s.logsAfter = last
+		if len(entries) < batchSize {
+			break
+		}
+	}
+	return s.Trim(ctx)
+}
+
+// Trim deletes what was pushed and what is too old to push.
+func (s *Shipper) Trim(ctx context.Context) error {
+	if err := s.repo.TrimSpool(ctx, s.metricsAfter, s.logsAfter, time.Now().Add(-spoolMaxAge)); err != nil {
+		return fmt.Errorf("trim spool: %w", err)
+	}
+	return nil
+}
+
+// heartbeat tells the server the agent is up and describes its host.
+func (s *Shipper) heartbeat(ctx context.Context) error {
+	if s.docker != nil && time.Since(s.infoAt) > infoRefresh {
+		info, err := s.docker.Info(ctx)
+		if err != nil {
+			s.log.Warn("read docker info", "err", err)
+		} else {
+			s.info, s.infoAt = info, time.Now()
+		}
+	}
+	hb := api.AgentHeartbeat{
+		Host:          s.host,
+		OS:            s.info.OperatingSystem,
+		Arch:          runtime.GOARCH,
+		Kernel:        s.info.KernelVersion,
+		DockerVersion: s.info.ServerVersion,
+		IntervalSec:   max(int(s.every/time.Second), 1),
+	}
+	if hb.OS == "" {
+		hb.OS = runtime.GOOS
+	}
+	return s.post(ctx, "/api/ingest/agent", hb, false)
+}
+
+// shipContainers pushes the services with containers the agent currently
+// sees; the server marks the host's other containers missing.
+func (s *Shipper) shipContainers(ctx context.Context) error {
+	containers, err := s.repo.ListContainers(ctx)
+	if err != nil {
+		return err
+	}
+	byService := map[string][]models.Container{}
+	var order []string
+	for _, c := range containers {
+		if c.Status == "missing" {
+			continue
+		}
+		if _, ok := byService[c.ServiceID]; !ok {
+			order = append(order, c.ServiceID)
+		}
+		byService[c.ServiceID] = append(byService[c.ServiceID], c)
+	}
+	body := api.IngestContainers{Host: s.host, Services: make([]api.ServiceDetail, 0, len(order))}
+	for _, id := range order {
+		svc, _, err := s.repo.ServiceDetail(ctx, id)
+		if err != nil {
+			return err
+		}
+		body.Services = append(body.Services, api.ServiceDetailFrom(svc, byService[id]))
+	}
+	// Without a key: pushing the same containers again refreshes them.
+	return s.post(ctx, "/api/ingest/containers", body, false)
+}
+
+func ingestLogs(entries []models.LogEntry) api.IngestLogs {
+	out := api.IngestLogs{Entries: make([]api.IngestLogEntry, 0, len(entries))}
+	for _, e := range entries {
+		ts := e.TS.UTC()
+		out.Entries = append(out.Entries, api.IngestLogEntry{
+			TS:          &ts,
+			ContainerID: e.ContainerID,
+			Level:       e.Level,
+			Stream:      e.Stream,
+			Message:     e.Message,
+			Fields:      e.Fields,
+		})
+	}
+	return out
+}
+
+// post pushes body to path. With idempotent, the key is derived from the
+// body, so the same batch always has the same key.
+func (s *Shipper) post(ctx context.Context, path string, body any, idempotent bool) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	var key string
+	if idempotent {
+		sum := sha256.Sum256(raw)
+		key = hex.EncodeToString(sum[:16])
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.server+path, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("User-Agent", "dashi-agent")
+	req.Header.Set(api.AgentHeader, s.host)
+	req.Header.Set(api.TimestampHeader, strconv.FormatInt(ts, 10))
+	if key != "" {
+		req.Header.Set(api.IdempotencyHeader, key)
+	}
+	// The path is signed without the server's base URL, which a reverse
+	// proxy may strip.
+	req.Header.Set(api.SignatureHeader, api.Sign(s.token, ts, http.MethodPost, path, key, buf.Bytes()))
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var apiErr api.Error
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if json.Unmarshal(msg, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("status %d: %s", resp.StatusCode, apiErr.Error)
+		}
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	var res api.IngestResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err == nil && res.Skipped > 0 {
+		s.log.Warn("server skipped pushed entries", "path", path, "skipped", res.Skipped)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/logs"
+	"dashi/internal/models"
+	"dashi/internal/web"
+)
+
+func openRepo(t *testing.T, name string) *db.Repository {
+	t.Helper()
+	sqldb, err := db.Open(t.TempDir() + "/" + name)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	return db.NewRepository(sqldb)
+}
+
+func TestShipPushesSpoolToServer(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	spool := openRepo(t, "agent.db")
+	server := openRepo(t, "server.db")
+	sink := logs.NewSink(server, logger, logs.Options{})
+	srv := httptest.NewServer(web.NewServer(server, nil, nil, logger, web.Options{LogSink: sink, IngestToken: "secret"}).Routes())
+	t.Cleanup(srv.Close)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := spool.UpsertServiceAndContainer(ctx,
+		models.Service{ID: "web@nas", Host: "nas", Name: "web", Image: "nginx", LabelsJSON: `{"env":"prod"}`, Status: "running"},
+		models.Container{ID: "c1", ServiceID: "web@nas", Host: "nas", Name: "web-1", Status: "running", LastSeenAt: now},
+	); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	if err := spool.InsertContainerMetric(ctx, models.ContainerMetric{TS: now, ContainerID: "c1", CPUPct: 42, MemUsedBytes: 1 << 20}); err != nil {
+		t.Fatalf("insert metric: %v", err)
+	}
+	if err := spool.InsertLogs(ctx, []models.LogEntry{{TS: now, ServiceID: "web@nas", ContainerID: "c1", Level: "ERROR", Stream: "stderr", Message: "upstream timed out"}}); err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+
+	sh := NewShipper(spool, nil, logger, srv.URL+"/", "secret", "nas", 10*time.Second)
+	if err := sh.Ship(ctx); err != nil {
+		t.Fatalf("ship: %v", err)
+	}
+	if err := sink.Stop(ctx); err != nil {
+		t.Fatalf("stop sink: %v", err)
+	}
+
+	svc, containers, err := server.ServiceDetail(ctx, "web@nas")
+	if err != nil || svc.Host != "nas" || len(containers) != 1 || containers[0].ID != "c1" {
+		t.Fatalf("server service = %+v %+v, err %v", svc, containers, err)
+	}
+	metrics, err := server.RecentContainerMetrics(ctx, "c1", now.Add(-time.Minute), 10)
+	if err != nil || len(metrics) != 1 || metrics[0].CPUPct != 42 {
+		t.Fatalf("server metrics = %+v, err %v", metrics, err)
+	}
+	got, err := server.QueryLogs(ctx, db.LogQuery{ServiceID: "web@nas"})
+	if err != nil || len(got) != 1 || got[0].Message != "upstream timed out" || got[0].Level != "ERROR" {
+		t.Fatalf("server logs = %+v, err %v", got, err)
+	}
+
+	// The first heartbeat registered the agent.
+	agents, err := server.ListAgents(ctx)
+	if err != nil || len(agents) != 1 || agents[0].Host != "nas" || agents[0].IntervalSec != 10 || !agents[0].Deadline.After(time.Now()) {
+		t.Fatalf("server agents = %+v, err %v", agents, err)
+	}
+
+	// Pushed rows leave the spool.
+	if rest, _, err := spool.SpooledLogs(ctx, 0, batchSize); err != nil || len(rest) != 0 {
+		t.Fatalf("spooled logs after push = %+v, err %v", rest, err)
+	}
+	if rest, _, err := spool.SpooledContainerMetrics(ctx, 0, batchSize); err != nil || len(rest) != 0 {
+		t.Fatalf("spooled metrics after push = %+v, err %v", rest, err)
+	}
+
+	// A wrong token fails without losing what is spooled.
+	if err := spool.InsertContainerMetric(ctx, models.ContainerMetric{TS: now, ContainerID: "c1"}); err != nil {
+		t.Fatalf("insert metric: %v", err)
+	}
+	if err := NewShipper(spool, nil, logger, srv.URL, "nope", "nas", 10*time.Second).Ship(ctx); err == nil {
+		t.Fatal("ship with a wrong token succeeded")
+	}
+	if rest, _, err := spool.SpooledContainerMetrics(ctx, 0, batchSize); err != nil || len(rest) != 1 {
+		t.Fatalf("spooled metrics after failed push = %+v, err %v", rest, err)
+	}
+}
@@ -7,11 +7,18 @@ import (
 	"log/slog"
 	"math"
 	"strings"
+	"sync"
 	"time"
 
+	"dashi/internal/checks"
 	"dashi/internal/db"
+	"dashi/internal/filter"
+	"dashi/internal/logs"
 	"dashi/internal/models"
 	"dashi/internal/notifier"
+	"dashi/internal/scrape"
+	"dashi/internal/selfmon"
+	"dashi/internal/supervise"
 )
 
 type Engine struct {
@@ -23,13 +30,24 @@ type Engine struct {
 	lastRest map[string]int
 	lastSvc  map[string]string
 	debug    bool
+	filter   *filter.Filter
+
+	// pending are notifications cut off by shutdown, for Flush to send.
+	mu      sync.Mutex
+	pending []pendingNotification
+}
+
+type pendingNotification struct {
+	alertID int64
+	msg     string
 }
 
-func NewEngine(repo *db.Repository, notify *notifier.Telegram, logger *slog.Logger, debugRestartAlerts bool) *Engine {
-	return &Engine{repo: repo, notify: notify, log: logger, now: time.Now, lastHost: map[string]float64{}, lastRest: map[string]int{}, lastSvc: map[string]string{}, debug: debugRestartAlerts}
+func NewEngine(repo *db.Repository, notify *notifier.Telegram, logger *slog.Logger, debugRestartAlerts bool, flt *filter.Filter) *Engine {
+	return &Engine{repo: repo, notify: notify, log: logger, now: time.Now, lastHost: map[string]float64{}, lastRest: map[string]int{}, lastSvc: map[string]string{}, debug: debugRestartAlerts, filter: flt}
 }
 
 func (e *Engine) Evaluate(ctx context.Context) {
+	defer supervise.Recover(e.log, "alert evaluation")
 	rules, err := e.repo.ListRules(ctx)
 	if err != nil {
 		e.log.Error("load rules", "err", err)
@@ -38,27 +56,83 @@ func (e *Engine) Evaluate(ctx context.Context) {
 	latest, err := e.repo.LatestHostMetric(ctx)
 	if err == nil {
 		e.lastHost["host_cpu_pct"] = latest.CPUPct
+		e.lastHost["host_net_rx_rate"] = latest.NetRXRate
+		e.lastHost["host_net_tx_rate"] = latest.NetTXRate
+		e.lastHost["host_disk_read_rate"] = latest.DiskReadRate
+		e.lastHost["host_disk_write_rate"] = latest.DiskWriteRate
+		e.lastHost["host_disk_util_pct"] = latest.DiskUtilPct
+		e.lastHost["host_cpu_temp"] = latest.CPUTempC
+		e.lastHost["host_nvme_temp"] = latest.NVMeTempC
+		e.lastHost["host_tcp_established"] = float64(latest.TCPEstablished)
+		e.lastHost["host_tcp_syn_recv"] = float64(latest.TCPSynRecv)
+		e.lastHost["host_tcp_time_wait"] = float64(latest.TCPTimeWait)
+		e.lastHost["host_tcp_close_wait"] = float64(latest.TCPCloseWait)
+		e.lastHost["host_tcp_listen"] = float64(latest.TCPListen)
+		e.lastHost["host_tcp_conns"] = float64(latest.TCPConns)
 		if latest.MemTotalBytes > 0 {
 			e.lastHost["host_mem_pct"] = (float64(latest.MemUsedBytes) / float64(latest.MemTotalBytes)) * 100
 		}
+		// Hosts without swap report 0 rather than a division by zero.
+		e.lastHost["host_swap_pct"] = 0
+		if latest.SwapTotalBytes > 0 {
+			e.lastHost["host_swap_pct"] = (float64(latest.SwapUsedBytes) / float64(latest.SwapTotalBytes)) * 100
+		}
 		if latest.DiskTotalBytes > 0 {
 			e.lastHost["host_disk_pct"] = (float64(latest.DiskUsedBytes) / float64(latest.DiskTotalBytes)) * 100
 		}
 	}
-	containers, _ := e.repo.ListContainers(ctx)
-	e.cleanupStaleRestartAlerts(ctx, containers)
+	allContainers, _ := e.repo.ListContainers(ctx)
+	labels, err := e.repo.ServiceLabels(ctx)
+	if err != nil {
+		e.log.Warn("load service labels", "err", err)
+	}
+	allContainers = e.monitored(allContainers, labels)
+	e.cleanupStaleRestartAlerts(ctx, allContainers)
 
 	for _, r := range rules {
 		if !r.Enabled {
 			continue
 		}
+		containers, err := selectContainers(allContainers, labels, r.LabelSelector)
+		if err != nil {
+			e.log.Warn("invalid rule label selector", "rule", r.Name, "err", err)
+			continue
+		}
 		switch r.TargetType {
 		case "host":
+			switch r.MetricKey {
+			case "host_clock_drift_ms":
+				e.evalClockDrift(ctx, r)
+				continue
+			case "host_rebooted":
+				e.evalHostReboot(ctx, r)
+				continue
+			}
 			e.evalTarget(ctx, r.ID, "host", "host", r, e.lastHost[r.MetricKey])
+		case "volume":
+			e.evalVolumes(ctx, r)
+		case "check":
+			e.evalChecks(ctx, r)
+		case "heartbeat":
+			e.evalHeartbeats(ctx, r)
+		case "agent":
+			e.evalAgents(ctx, r)
+		case "scrape":
+			e.evalScrape(ctx, r)
+		case "pool":
+			e.evalPools(ctx, r)
+		case "dashi":
+			e.evalSelf(ctx, r)
 		case "container":
 			if r.MetricKey == "container_unavailable" {
 				now := e.now().UTC()
+				offline := e.offlineAgents(ctx)
 				for _, c := range containers {
+					// The agent_offline alert covers the containers of a host
+					// whose agent went quiet.
+					if offline[c.Host] {
+						continue
+					}
 					v := 0.0
 					if strings.EqualFold(c.Status, "running") && now.Sub(c.LastSeenAt) > 60*time.Second {
 						v = 1
@@ -66,6 +140,30 @@ func (e *Engine) Evaluate(ctx context.Context) {
 					e.evalTarget(ctx, r.ID, c.ID, shortTarget(c.ID), r, v)
 				}
 			}
+			if r.MetricKey == "container_unhealthy" {
+				for _, c := range containers {
+					v := 0.0
+					if c.Health == "unhealthy" {
+						v = 1
+					}
+					e.evalTarget(ctx, r.ID, c.ID, shortTarget(c.ID), r, v)
+				}
+			}
+			if r.MetricKey == "image_update_available" {
+				e.evalImageUpdates(ctx, r, containers)
+			}
+			if r.MetricKey == "container_pids_pct" || r.MetricKey == "container_fds_pct" {
+				e.evalLimits(ctx, r, containers)
+			}
+			if r.MetricKey == "container_logs_sampled" {
+				e.evalLogsSampled(ctx, r, containers)
+			}
+			if r.MetricKey == "service_log_rate" || r.MetricKey == "service_error_log_rate" || r.MetricKey == "service_log_bytes_rate" {
+				e.evalLogRates(ctx, r, containers)
+			}
+			if r.MetricKey == "service_request_rate" || r.MetricKey == "service_5xx_rate" || r.MetricKey == "service_5xx_pct" || r.MetricKey == "service_latency_ms" {
+				e.evalRequestRates(ctx, r, containers)
+			}
 			if r.MetricKey == "container_restarts" {
 				runningByService := make(map[string]models.Container, len(containers))
 				for _, c := range containers {
@@ -112,6 +210,437 @@ func (e *Engine) Evaluate(ctx context.Context) {
 	}
 }
 
+// evalImageUpdates evaluates per service rather than per container, so a
+// recreated container does not re-fire the alert for the same image.
+func (e *Engine) evalImageUpdates(ctx context.Context, r models.AlertRule, containers []models.Container) {
+	updates, err := e.repo.ListImageUpdates(ctx)
+	if err != nil {
+		e.log.Error("load image updates", "err", err)
+		return
+	}
+	available := make(map[string]bool, len(updates))
+	for _, u := range updates {
+		available[u.ServiceID] = u.UpdateAvailable
+	}
+	seen := map[string]bool{}
+	for _, c := range containers {
+		if seen[c.ServiceID] || !strings.EqualFold(c.Status, "running") {
+			continue
+		}
+		seen[c.ServiceID] = true
+		v := 0.0
+		if available[c.ServiceID] {
+			v = 1
+		}
+		e.evalTarget(ctx, r.ID, c.ServiceID, c.ServiceID, r, v)
+	}
+}
+
+// evalLimits evaluates container_pids_pct and container_fds_pct, the use of
+// the container's pids limit and of its main process's open file limit.
+// Containers without a limit or a recent sample are skipped.
+func (e *Engine) evalLimits(ctx context.Context, r models.AlertRule, containers []models.Container) {
+	latest, err := e.repo.LatestContainerMetrics(ctx, e.now().Add(-5*time.Minute))
+	if err != nil {
+		e.log.Error("load container metrics", "err", err)
+		return
+	}
+	for _, c := range containers {
+		m, ok := latest[c.ID]
+		if !ok || !strings.EqualFold(c.Status, "running") {
+			continue
+		}
+		used, limit := m.Pids, m.PidsLimit
+		if r.MetricKey == "container_fds_pct" {
+			used, limit = m.FDs, m.FDLimit
+		}
+		if limit <= 0 {
+			continue
+		}
+		e.evalTarget(ctx, r.ID, c.ID, shortTarget(c.ID), r, 100*float64(used)/float64(limit))
+	}
+}
+
+// evalLogsSampled evaluates container_logs_sampled, the number of seconds
+// in the last five minutes in which the container's logs went over the rate
+// limit and were sampled.
+func (e *Engine) evalLogsSampled(ctx context.Context, r models.AlertRule, containers []models.Container) {
+	sampled, err := e.repo.CountLogsByStream(ctx, logs.SampledStream, e.now().Add(-5*time.Minute))
+	if err != nil {
+		e.log.Error("count sampled logs", "err", err)
+		return
+	}
+	for _, c := range containers {
+		e.evalTarget(ctx, r.ID, c.ID, shortTarget(c.ID), r, float64(sampled[c.ID]))
+	}
+}
+
+// evalLogRates evaluates service_log_rate, service_error_log_rate and
+// service_log_bytes_rate, the lines, error lines and bytes a service logged
+// per minute, averaged over the last five finished minutes.
+func (e *Engine) evalLogRates(ctx context.Context, r models.AlertRule, containers []models.Container) {
+	const window = 5
+	totals, err := e.repo.ServiceLogTotals(ctx, e.now().UTC().Truncate(time.Minute).Add(-window*time.Minute))
+	if err != nil {
+		e.log.Error("load service log metrics", "err", err)
+		return
+	}
+	seen := map[string]bool{}
+	for _, c := range containers {
+		if seen[c.ServiceID] {
+			continue
+		}
+		seen[c.ServiceID] = true
+		m := totals[c.ServiceID]
+		var v int64
+		switch r.MetricKey {
+		case "service_log_rate":
+			v = m.Lines
+		case "service_error_log_rate":
+			v = m.ErrorLines
+		case "service_log_bytes_rate":
+			v = m.Bytes
+		default:
+			return
+		}
+		e.evalTarget(ctx, r.ID, c.ServiceID, c.ServiceID, r, float64(v)/window)
+	}
+}
+
+// minRequestsForRatio is how many requests the window needs before
+// service_5xx_pct and service_latency_ms are evaluated, so a single failed
+// request to an idle service does not alert.
+const minRequestsForRatio = 20
+
+// evalRequestRates evaluates the access log metrics over the last five
+// finished minutes: service_request_rate and service_5xx_rate are requests
+// per minute, service_5xx_pct the share of 5xx responses and
+// service_latency_ms the average request time.
+func (e *Engine) evalRequestRates(ctx context.Context, r models.AlertRule, containers []models.Container) {
+	const window = 5
+	totals, err := e.repo.ServiceLogTotals(ctx, e.now().UTC().Truncate(time.Minute).Add(-window*time.Minute))
+	if err != nil {
+		e.log.Error("load service log metrics", "err", err)
+		return
+	}
+	seen := map[string]bool{}
+	for _, c := range containers {
+		if seen[c.ServiceID] {
+			continue
+		}
+		seen[c.ServiceID] = true
+		m := totals[c.ServiceID]
+		var v float64
+		switch r.MetricKey {
+		case "service_request_rate":
+			v = float64(m.Requests) / window
+		case "service_5xx_rate":
+			v = float64(m.Requests5xx) / window
+		case "service_5xx_pct":
+			if m.Requests >= minRequestsForRatio {
+				v = 100 * float64(m.Requests5xx) / float64(m.Requests)
+			}
+		case "service_latency_ms":
+			if m.TimedRequests >= minRequestsForRatio {
+				v = m.LatencyMS / float64(m.TimedRequests)
+			}
+		default:
+			return
+		}
+		e.evalTarget(ctx, r.ID, c.ServiceID, c.ServiceID, r, v)
+	}
+}
+
+// evalVolumes evaluates volume_size_bytes and volume_growth_bytes (size
+// change over the last 24 hours) for every volume of the latest sample.
+func (e *Engine) evalVolumes(ctx context.Context, r models.AlertRule) {
+	volumes, err := e.repo.ListVolumeUsage(ctx, e.now().Add(-24*time.Hour))
+	if err != nil {
+		e.log.Error("load volume usage", "err", err)
+		return
+	}
+	for _, v := range volumes {
+		var value float64
+		switch r.MetricKey {
+		case "volume_size_bytes":
+			value = float64(v.SizeBytes)
+		case "volume_growth_bytes":
+			value = float64(v.GrowthBytes)
+		default:
+			return
+		}
+		target := "volume:" + v.Name
+		if v.Host != "local" {
+			target += "@" + v.Host
+		}
+		e.evalTarget(ctx, r.ID, target, target, r, value)
+	}
+}
+
+// evalPools evaluates pool_degraded (the pool is not ONLINE),
+// pool_used_pct, pool_device_errors and pool_scrub_errors (errors found by
+// the last finished scrub) for every ZFS pool and btrfs filesystem.
+func (e *Engine) evalPools(ctx context.Context, r models.AlertRule) {
+	pools, err := e.repo.ListStoragePools(ctx)
+	if err != nil {
+		e.log.Error("load storage pools", "err", err)
+		return
+	}
+	for _, p := range pools {
+		var value float64
+		switch r.MetricKey {
+		case "pool_degraded":
+			if p.Health != "ONLINE" {
+				value = 1
+			}
+		case "pool_used_pct":
+			if p.SizeBytes == 0 {
+				continue
+			}
+			value = float64(p.AllocBytes) / float64(p.SizeBytes) * 100
+		case "pool_device_errors":
+			value = float64(p.DeviceErrors)
+		case "pool_scrub_errors":
+			value = float64(p.ScrubErrors)
+		default:
+			return
+		}
+		target := "pool:" + p.Type + "/" + p.Name
+		e.evalTarget(ctx, r.ID, target, target, r, value)
+	}
+}
+
+// evalSelf evaluates the dashi_* metrics of the latest self metric sample,
+// e.g. dashi_log_write_errors or dashi_query_avg_ms, as target "dashi".
+// Samples older than five minutes are not evaluated.
+func (e *Engine) evalSelf(ctx context.Context, r models.AlertRule) {
+	m, ok, err := e.repo.LatestSelfMetric(ctx)
+	if err != nil {
+		e.log.Error("load self metric", "err", err)
+		return
+	}
+	if !ok || e.now().Sub(m.TS) > 5*time.Minute {
+		return
+	}
+	var value float64
+	switch r.MetricKey {
+	case "dashi_log_lines_rate":
+		value = m.LogLinesRate
+	case "dashi_log_dropped_rate":
+		value = m.LogDroppedRate
+	case "dashi_log_write_errors":
+		value = float64(m.LogWriteErrors)
+	case "dashi_log_workers":
+		value = float64(m.LogWorkers)
+	case "dashi_db_size_bytes":
+		value = float64(m.DBSizeBytes)
+	case "dashi_query_rate":
+		value = m.QueryRate
+	case "dashi_query_avg_ms":
+		value = m.QueryAvgMS
+	case "dashi_goroutines":
+		value = float64(m.Goroutines)
+	case "dashi_heap_bytes":
+		value = float64(m.HeapBytes)
+	case "dashi_notify_failures":
+		value = float64(m.NotifyFailures)
+	case "dashi_panics":
+		value = float64(m.Panics)
+	case "dashi_log_overflow":
+		value = float64(m.LogOverflow)
+	default:
+		return
+	}
+	e.evalTarget(ctx, r.ID, "dashi", "dashi", r, value)
+}
+
+// evalChecks evaluates check_down (the last probe failed), check_latency_ms
+// of the last probe and check_uptime_pct over 24 hours for every enabled
+// check that has been probed.
+func (e *Engine) evalChecks(ctx context.Context, r models.AlertRule) {
+	checks, err := e.repo.CheckStatuses(ctx, e.now().Add(-24*time.Hour))
+	if err != nil {
+		e.log.Error("load checks", "err", err)
+		return
+	}
+	for _, c := range checks {
+		if !c.Enabled || c.Last == nil {
+			continue
+		}
+		var value float64
+		switch r.MetricKey {
+		case "check_down":
+			if !c.Last.OK {
+				value = 1
+			}
+		case "check_latency_ms":
+			value = float64(c.Last.LatencyMS)
+		case "check_uptime_pct":
+			value = c.UptimePct
+		default:
+			return
+		}
+		e.evalTarget(ctx, r.ID, fmt.Sprintf("check:%d", c.ID), "check:"+c.Name, r, value)
+	}
+}
+
+// evalHeartbeats evaluates heartbeat_down, 1 for heartbeats whose job missed
+// its deadline or reported a failure.
+func (e *Engine) evalHeartbeats(ctx context.Context, r models.AlertRule) {
+	if r.MetricKey != "heartbeat_down" {
+		return
+	}
+	heartbeats, err := e.repo.ListHeartbeats(ctx)
+	if err != nil {
+		e.log.Error("load heartbeats", "err", err)
+		return
+	}
+	now := e.now()
+	for _, h := range heartbeats {
+		value := 0.0
+		if s := checks.HeartbeatState(h, now); s == checks.HeartbeatDown || s == checks.HeartbeatFailed {
+			value = 1
+		}
+		e.evalTarget(ctx, r.ID, fmt.Sprintf("heartbeat:%d", h.ID), "heartbeat:"+h.Name, r, value)
+	}
+}
+
+// evalAgents evaluates agent_offline, 1 for agents whose heartbeat is
+// overdue, i.e. whose host is down or cannot reach dashi.
+func (e *Engine) evalAgents(ctx context.Context, r models.AlertRule) {
+	if r.MetricKey != "agent_offline" {
+		return
+	}
+	agents, err := e.repo.ListAgents(ctx)
+	if err != nil {
+		e.log.Error("load agents", "err", err)
+		return
+	}
+	now := e.now()
+	for _, a := range agents {
+		value := 0.0
+		if !now.Before(a.Deadline) {
+			value = 1
+		}
+		e.evalTarget(ctx, r.ID, "agent:"+a.Host, "agent:"+a.Host, r, value)
+	}
+}
+
+// scrapeStale is how old the last sample of a scraped series may be to be
+// evaluated; older ones belong to targets or series that went away.
+const scrapeStale = 10 * time.Minute
+
+// evalScrape evaluates the series named by the rule's metric key scraped
+// from Prometheus exporters: of the target named by TargetID, if set, and
+// whose labels match the rule's label selector. "up" is 0 for targets
+// whose last scrape failed.
+func (e *Engine) evalScrape(ctx context.Context, r models.AlertRule) {
+	sel, err := db.ParseLabelSelector(r.LabelSelector)
+	if err != nil {
+		return
+	}
+	samples, err := e.repo.LatestScrapeSamples(ctx, e.now().Add(-scrapeStale))
+	if err != nil {
+		e.log.Error("load scraped samples", "err", err)
+		return
+	}
+	for _, s := range samples {
+		if s.Metric != r.MetricKey || (r.TargetID != nil && *r.TargetID != s.Target) || !sel.Matches(s.Labels) {
+			continue
+		}
+		target := "scrape:" + s.Target + ":" + scrape.SeriesName(s.Metric, s.Labels)
+		e.evalTarget(ctx, r.ID, target, target, r, s.Value)
+	}
+}
+
+// offlineAgents returns the hosts whose agent's heartbeat is overdue.
+func (e *Engine) offlineAgents(ctx context.Context) map[string]bool {
+	agents, err := e.repo.ListAgents(ctx)
+	if err != nil {
+		e.log.Error("load agents", "err", err)
+		return nil
+	}
+	out := map[string]bool{}
+	now := e.now()
+	for _, a := range agents {
+		if !now.Before(a.Deadline) {
+			out[a.Host] = true
+		}
+	}
+	return out
+}
+
+// rebootAlertWindow is how long host_rebooted stays 1 after a reboot was
+// detected.
+const rebootAlertWindow = 10 * time.Minute
+
+// evalHostReboot evaluates host_rebooted: 1 for a while after the latest
+// reboot was detected, so a rule fires once per reboot and then recovers. The
+// target label carries the previous uptime and the downtime.
+func (e *Engine) evalHostReboot(ctx context.Context, r models.AlertRule) {
+	reboots, err := e.repo.RecentHostReboots(ctx, 1)
+	if err != nil {
+		e.log.Error("load host reboots", "err", err)
+		return
+	}
+	if len(reboots) == 0 {
+		e.evalTarget(ctx, r.ID, "host", "host", r, 0)
+		return
+	}
+	rb := reboots[0]
+	value := 0.0
+	if e.now().Sub(rb.DetectedAt) < rebootAlertWindow {
+		value = 1
+	}
+	down := max(rb.BootedAt.Sub(rb.LastSeenAt), 0).Round(time.Second)
+	label := fmt.Sprintf("host booted %s after %s up, down %s", rb.BootedAt.Format(time.RFC3339), time.Duration(rb.PrevUptimeSec)*time.Second, down)
+	e.evalTarget(ctx, r.ID, "host", label, r, value)
+}
+
+// evalClockDrift evaluates host_clock_drift_ms, the absolute clock offset,
+// once per Docker host and source. Failed comparisons are not evaluated, so
+// an alert neither fires nor recovers while the reference is unreachable.
+func (e *Engine) evalClockDrift(ctx context.Context, r models.AlertRule) {
+	offsets, err := e.repo.ListClockOffsets(ctx)
+	if err != nil {
+		e.log.Error("load clock offsets", "err", err)
+		return
+	}
+	for _, o := range offsets {
+		if o.Error != "" {
+			continue
+		}
+		target := "clock:" + o.Host + ":" + o.Source
+		e.evalTarget(ctx, r.ID, target, target, r, math.Abs(o.OffsetMS))
+	}
+}
+
+// monitored drops containers excluded by the monitoring filter.
+func (e *Engine) monitored(containers []models.Container, labels map[string]map[string]string) []models.Container {
+	out := containers[:0]
+	for _, c := range containers {
+		if e.filter.Allows(c.Name, labels[c.ServiceID]) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// selectContainers keeps the containers whose service labels match selector.
+func selectContainers(containers []models.Container, labels map[string]map[string]string, selector string) ([]models.Container, error) {
+	sel, err := db.ParseLabelSelector(selector)
+	if err != nil || len(sel) == 0 {
+		return containers, err
+	}
+	out := make([]models.Container, 0, len(containers))
+	for _, c := range containers {
+		if sel.Matches(labels[c.ServiceID]) {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
 func (e *Engine) cleanupStaleRestartAlerts(ctx context.Context, containers []models.Container) {
 	now := e.now().UTC()
 	running := make(map[string]bool, len(containers))
@@ -215,12 +744,38 @@ func (e *Engine) sendNotification(ctx context.Context, alertID int64, msg string
 			_ = e.repo.InsertNotificationEvent(ctx, alertID, "telegram", "sent", attempts, "", &now)
 			return
 		}
+		if ctx.Err() != nil {
+			e.mu.Lock()
+			e.pending = append(e.pending, pendingNotification{alertID: alertID, msg: msg})
+			e.mu.Unlock()
+			return
+		}
 		time.Sleep(time.Duration(attempts) * 300 * time.Millisecond)
 	}
+	selfmon.NotifyFailures.Add(1)
 	_ = e.repo.InsertNotificationEvent(ctx, alertID, "telegram", "failed", attempts, err.Error(), nil)
 	e.log.Warn("notify failed", "err", err)
 }
 
+// Flush sends the notifications that evaluation could not send because its
+// context was cancelled, as on shutdown. It returns an error naming how many
+// are still unsent when ctx ends first.
+func (e *Engine) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	pending := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+	for _, n := range pending {
+		e.sendNotification(ctx, n.alertID, n.msg)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.pending) > 0 {
+		return fmt.Errorf("%d notifications not sent: %w", len(e.pending), ctx.Err())
+	}
+	return nil
+}
+
 func compare(v float64, op string, threshold float64) bool {
 	switch op {
 	case ">":
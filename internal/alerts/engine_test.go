@@ -2,6 +2,7 @@ package alerts
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
@@ -50,7 +51,7 @@ func TestEvaluateContainerRestartsFiresOnIncrement(t *testing.T) {
 		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
 	})}
 
-	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false)
+	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false, nil)
 	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
 	engine.now = func() time.Time { return now }
 
@@ -111,7 +112,7 @@ func TestEvaluateContainerRestartsFiresOnServiceContainerReplacement(t *testing.
 		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
 	})}
 
-	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false)
+	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false, nil)
 	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
 	engine.now = func() time.Time { return now }
 
@@ -172,7 +173,7 @@ func TestEvaluateContainerRestartsIgnoresHistoricalMissingContainers(t *testing.
 		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
 	})}
 
-	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false)
+	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false, nil)
 	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
 	engine.now = func() time.Time { return now }
 
@@ -230,7 +231,7 @@ func TestEvaluateAutoRecoversStaleRestartAlerts(t *testing.T) {
 		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
 	})}
 
-	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false)
+	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false, nil)
 	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
 	engine.now = func() time.Time { return now }
 
@@ -266,6 +267,163 @@ func TestEvaluateAutoRecoversStaleRestartAlerts(t *testing.T) {
 	assertRestartFiringCount(t, repo, 0)
 }
 
+func TestEvaluateImageUpdateFiresOncePerService(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	if err := repo.ImportConfig(ctx, db.ConfigImport{Rules: []models.AlertRule{
+		{Name: "Image outdated", TargetType: "container", MetricKey: "image_update_available", Operator: ">=", Threshold: 1, Enabled: true},
+	}}); err != nil {
+		t.Fatalf("import rule: %v", err)
+	}
+
+	n := notifier.NewTelegram("token", "chat")
+	n.HTTP = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
+	})}
+	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false, nil)
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+
+	for _, id := range []string{"web-1", "web-2"} {
+		if err := repo.UpsertServiceAndContainer(ctx,
+			models.Service{ID: "web", Name: "web", Image: "nginx", LabelsJSON: "{}", Status: "running"},
+			models.Container{ID: id, ServiceID: "web", Name: id, Status: "running", LastSeenAt: now},
+		); err != nil {
+			t.Fatalf("upsert %s: %v", id, err)
+		}
+	}
+	if err := repo.SaveImageUpdate(ctx, models.ImageUpdate{ServiceID: "web", Image: "nginx", UpdateAvailable: true, CheckedAt: now}); err != nil {
+		t.Fatalf("save image update: %v", err)
+	}
+
+	engine.Evaluate(ctx)
+	var got int
+	if err := repo.DB().QueryRow(`SELECT COUNT(*) FROM alerts WHERE target_fingerprint='web' AND status='firing'`).Scan(&got); err != nil {
+		t.Fatalf("count alerts: %v", err)
+	}
+	if got != 1 {
+		t.Fatalf("image update alerts = %d, want 1", got)
+	}
+}
+
+func TestEvaluatePidsLimitUsage(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	if err := repo.ImportConfig(ctx, db.ConfigImport{Rules: []models.AlertRule{
+		{Name: "PIDs nearly exhausted", TargetType: "container", MetricKey: "container_pids_pct", Operator: ">", Threshold: 90, Enabled: true},
+	}}); err != nil {
+		t.Fatalf("import rule: %v", err)
+	}
+	n := notifier.NewTelegram("token", "chat")
+	n.HTTP = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
+	})}
+	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false, nil)
+	now := time.Now().UTC()
+	engine.now = func() time.Time { return now }
+
+	// busy is at 95% of its limit, idle has no limit at all.
+	for _, c := range []struct {
+		id          string
+		pids, limit int64
+	}{{"busy", 95, 100}, {"idle", 5000, 0}} {
+		if err := repo.UpsertServiceAndContainer(ctx,
+			models.Service{ID: c.id, Name: c.id, Image: "app", LabelsJSON: "{}", Status: "running"},
+			models.Container{ID: c.id, ServiceID: c.id, Name: c.id, Status: "running", LastSeenAt: now},
+		); err != nil {
+			t.Fatalf("upsert %s: %v", c.id, err)
+		}
+		if err := repo.InsertMetricsBatch(ctx, nil, []models.ContainerMetric{{TS: now, ContainerID: c.id, Pids: c.pids, PidsLimit: c.limit}}); err != nil {
+			t.Fatalf("insert metric: %v", err)
+		}
+	}
+
+	engine.Evaluate(ctx)
+	var targets []string
+	rows, err := repo.DB().Query(`SELECT target_fingerprint FROM alerts WHERE status='firing'`)
+	if err != nil {
+		t.Fatalf("query alerts: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var target string
+		if err := rows.Scan(&target); err != nil {
+			t.Fatal(err)
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) != 1 || targets[0] != "busy" {
+		t.Fatalf("firing targets = %v, want [busy]", targets)
+	}
+}
+
+func TestEvaluateCheckDown(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	if err := repo.ImportConfig(ctx, db.ConfigImport{Rules: []models.AlertRule{
+		{Name: "Site down", TargetType: "check", MetricKey: "check_down", Operator: ">=", Threshold: 1, Enabled: true},
+	}}); err != nil {
+		t.Fatalf("import rule: %v", err)
+	}
+	n := notifier.NewTelegram("token", "chat")
+	n.HTTP = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
+	})}
+	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false, nil)
+	now := time.Now().UTC()
+	engine.now = func() time.Time { return now }
+
+	var down int64
+	for _, c := range []struct {
+		name string
+		ok   bool
+	}{{"up", true}, {"down", false}} {
+		id, err := repo.CreateCheck(ctx, models.Check{Name: c.name, URL: "http://" + c.name, Method: "GET", IntervalSec: 60, TimeoutSec: 10, Enabled: true})
+		if err != nil {
+			t.Fatalf("create check: %v", err)
+		}
+		if !c.ok {
+			down = id
+		}
+		if err := repo.InsertCheckResult(ctx, models.CheckResult{CheckID: id, TS: now, OK: c.ok}); err != nil {
+			t.Fatalf("insert result: %v", err)
+		}
+	}
+
+	engine.Evaluate(ctx)
+	var target string
+	if err := repo.DB().QueryRow(`SELECT target_fingerprint FROM alerts WHERE status='firing'`).Scan(&target); err != nil {
+		t.Fatalf("firing alert: %v", err)
+	}
+	if want := fmt.Sprintf("check:%d", down); target != want {
+		t.Fatalf("firing target = %s, want %s", target, want)
+	}
+}
+
 func assertRestartAlertCount(t *testing.T, repo *db.Repository, want int) {
 	t.Helper()
 	var got int
@@ -295,3 +453,379 @@ type roundTripFunc func(req *http.Request) (*http.Response, error)
 func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
 	return f(req)
 }
+
+func TestEvaluateClockDrift(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	if err := repo.ImportConfig(ctx, db.ConfigImport{Rules: []models.AlertRule{
+		{Name: "Clock drift", TargetType: "host", MetricKey: "host_clock_drift_ms", Operator: ">", Threshold: 1000, Enabled: true},
+	}}); err != nil {
+		t.Fatalf("import rule: %v", err)
+	}
+	n := notifier.NewTelegram("token", "chat")
+	n.HTTP = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
+	})}
+	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false, nil)
+	now := time.Now().UTC()
+	for _, o := range []models.ClockOffset{
+		{Host: "local", Source: "ntp", OffsetMS: 40, CheckedAt: now},
+		{Host: "nas", Source: "docker", OffsetMS: -2500, CheckedAt: now},
+		{Host: "pi", Source: "docker", OffsetMS: 9000, CheckedAt: now, Error: "context deadline exceeded"},
+	} {
+		if err := repo.SaveClockOffset(ctx, o); err != nil {
+			t.Fatalf("save offset: %v", err)
+		}
+	}
+
+	engine.Evaluate(ctx)
+	rows, err := repo.DB().Query(`SELECT target_fingerprint FROM alerts WHERE status='firing'`)
+	if err != nil {
+		t.Fatalf("firing alerts: %v", err)
+	}
+	defer rows.Close()
+	var targets []string
+	for rows.Next() {
+		var target string
+		if err := rows.Scan(&target); err != nil {
+			t.Fatal(err)
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) != 1 || targets[0] != "clock:nas:docker" {
+		t.Fatalf("firing targets = %v", targets)
+	}
+}
+
+func TestEvaluateHostRebootFiresOnce(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	if err := repo.ImportConfig(ctx, db.ConfigImport{Rules: []models.AlertRule{
+		{Name: "Host rebooted", TargetType: "host", MetricKey: "host_rebooted", Operator: ">=", Threshold: 1, Enabled: true},
+	}}); err != nil {
+		t.Fatalf("import rule: %v", err)
+	}
+	n := notifier.NewTelegram("token", "chat")
+	n.HTTP = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
+	})}
+	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false, nil)
+	now := time.Now().UTC()
+	engine.now = func() time.Time { return now }
+	boot := now.Add(-time.Minute)
+	if err := repo.InsertHostReboot(ctx, models.HostReboot{BootedAt: boot, LastSeenAt: boot.Add(-90 * time.Second), PrevUptimeSec: 86400, DetectedAt: now}); err != nil {
+		t.Fatalf("insert reboot: %v", err)
+	}
+
+	engine.Evaluate(ctx)
+	var summary string
+	if err := repo.DB().QueryRow(`SELECT summary FROM alerts WHERE status='firing'`).Scan(&summary); err != nil {
+		t.Fatalf("firing alert: %v", err)
+	}
+	if !strings.Contains(summary, "after 24h0m0s up, down 1m30s") {
+		t.Fatalf("summary = %q", summary)
+	}
+
+	now = now.Add(rebootAlertWindow)
+	engine.Evaluate(ctx)
+	var firing int
+	if err := repo.DB().QueryRow(`SELECT COUNT(*) FROM alerts WHERE status='firing'`).Scan(&firing); err != nil {
+		t.Fatal(err)
+	}
+	if firing != 0 {
+		t.Fatalf("reboot alert still firing after %v", rebootAlertWindow)
+	}
+}
+
+func TestEvaluateAgentOffline(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	if err := repo.ImportConfig(ctx, db.ConfigImport{Rules: []models.AlertRule{
+		{Name: "Agent offline", TargetType: "agent", MetricKey: "agent_offline", Operator: ">=", Threshold: 1, Enabled: true},
+		{Name: "Container unavailable", TargetType: "container", MetricKey: "container_unavailable", Operator: ">=", Threshold: 1, Enabled: true},
+	}}); err != nil {
+		t.Fatalf("import rules: %v", err)
+	}
+	n := notifier.NewTelegram("token", "chat")
+	n.HTTP = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
+	})}
+	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false, nil)
+	now := time.Now().UTC()
+	engine.now = func() time.Time { return now }
+	if _, err := repo.RecordAgentHeartbeat(ctx, models.Agent{Host: "nas", IntervalSec: 10, LastHeartbeatAt: now, Deadline: now.Add(time.Minute)}); err != nil {
+		t.Fatalf("record heartbeat: %v", err)
+	}
+	if err := repo.UpsertServiceAndContainer(ctx,
+		models.Service{ID: "web@nas", Host: "nas", Name: "web", Image: "img", LabelsJSON: "{}", Status: "running"},
+		models.Container{ID: "c1", ServiceID: "web@nas", Host: "nas", Name: "web", Status: "running", LastSeenAt: now}); err != nil {
+		t.Fatalf("store container: %v", err)
+	}
+	firing := func() []string {
+		t.Helper()
+		rows, err := repo.DB().Query(`SELECT target_fingerprint FROM alerts WHERE status='firing' ORDER BY target_fingerprint`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var out []string
+		for rows.Next() {
+			var target string
+			if err := rows.Scan(&target); err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, target)
+		}
+		return out
+	}
+
+	engine.Evaluate(ctx)
+	if got := firing(); len(got) != 0 {
+		t.Fatalf("firing with a fresh heartbeat = %v", got)
+	}
+	// The agent goes quiet: its host is down rather than each container.
+	now = now.Add(2 * time.Minute)
+	engine.Evaluate(ctx)
+	if got := firing(); len(got) != 1 || got[0] != "agent:nas" {
+		t.Fatalf("firing after the deadline = %v, want only agent:nas", got)
+	}
+	if _, err := repo.RecordAgentHeartbeat(ctx, models.Agent{Host: "nas", IntervalSec: 10, LastHeartbeatAt: now, Deadline: now.Add(time.Minute)}); err != nil {
+		t.Fatalf("record heartbeat: %v", err)
+	}
+	engine.Evaluate(ctx)
+	if got := firing(); len(got) != 1 || got[0] != "c1" {
+		t.Fatalf("firing after the agent is back = %v, want the stale container", got)
+	}
+}
+
+func TestEvaluateServiceErrorLogRate(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	if err := repo.ImportConfig(ctx, db.ConfigImport{Rules: []models.AlertRule{
+		{Name: "Errors", TargetType: "container", MetricKey: "service_error_log_rate", Operator: ">", Threshold: 5, Enabled: true},
+	}}); err != nil {
+		t.Fatalf("import rule: %v", err)
+	}
+	n := notifier.NewTelegram("token", "chat")
+	n.HTTP = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
+	})}
+	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false, nil)
+	now := time.Date(2026, 2, 21, 12, 0, 30, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+
+	for _, id := range []string{"api", "web"} {
+		if err := repo.UpsertServiceAndContainer(ctx,
+			models.Service{ID: id, Name: id, Image: "app", LabelsJSON: "{}", Status: "running"},
+			models.Container{ID: id + "-1", ServiceID: id, Name: id, Status: "running", LastSeenAt: now},
+		); err != nil {
+			t.Fatalf("upsert %s: %v", id, err)
+		}
+	}
+	// api logs 40 errors over the last five minutes (8/min); web's burst is
+	// older than the window.
+	minute := now.Truncate(time.Minute)
+	if err := repo.AddServiceLogMetrics(ctx, []models.ServiceLogMetric{
+		{TS: minute.Add(-2 * time.Minute), ServiceID: "api", Lines: 100, ErrorLines: 25},
+		{TS: minute.Add(-4 * time.Minute), ServiceID: "api", Lines: 100, ErrorLines: 15},
+		{TS: minute.Add(-10 * time.Minute), ServiceID: "web", Lines: 500, ErrorLines: 500},
+	}); err != nil {
+		t.Fatalf("add log metrics: %v", err)
+	}
+
+	engine.Evaluate(ctx)
+	var targets []string
+	rows, err := repo.DB().Query(`SELECT target_fingerprint FROM alerts WHERE status='firing'`)
+	if err != nil {
+		t.Fatalf("query alerts: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var target string
+		if err := rows.Scan(&target); err != nil {
+			t.Fatal(err)
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) != 1 || targets[0] != "api" {
+		t.Fatalf("firing targets = %v, want [api]", targets)
+	}
+}
+
+func TestEvaluateService5xxPct(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	if err := repo.ImportConfig(ctx, db.ConfigImport{Rules: []models.AlertRule{
+		{Name: "5xx", TargetType: "container", MetricKey: "service_5xx_pct", Operator: ">", Threshold: 5, Enabled: true},
+	}}); err != nil {
+		t.Fatalf("import rule: %v", err)
+	}
+	n := notifier.NewTelegram("token", "chat")
+	n.HTTP = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
+	})}
+	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false, nil)
+	now := time.Date(2026, 2, 21, 12, 0, 30, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+
+	for _, id := range []string{"api", "web"} {
+		if err := repo.UpsertServiceAndContainer(ctx,
+			models.Service{ID: id, Name: id, Image: "app", LabelsJSON: "{}", Status: "running"},
+			models.Container{ID: id + "-1", ServiceID: id, Name: id, Status: "running", LastSeenAt: now},
+		); err != nil {
+			t.Fatalf("upsert %s: %v", id, err)
+		}
+	}
+	// api answers 8% of its requests with 5xx; web failed every request but
+	// served too few for the share to count.
+	minute := now.Truncate(time.Minute)
+	if err := repo.AddServiceLogMetrics(ctx, []models.ServiceLogMetric{
+		{TS: minute.Add(-1 * time.Minute), ServiceID: "api", Lines: 100, Requests: 100, Requests5xx: 8},
+		{TS: minute.Add(-3 * time.Minute), ServiceID: "api", Lines: 50, Requests: 50, Requests5xx: 4},
+		{TS: minute.Add(-2 * time.Minute), ServiceID: "web", Lines: 10, Requests: 10, Requests5xx: 10},
+	}); err != nil {
+		t.Fatalf("add log metrics: %v", err)
+	}
+
+	engine.Evaluate(ctx)
+	var targets []string
+	rows, err := repo.DB().Query(`SELECT target_fingerprint FROM alerts WHERE status='firing'`)
+	if err != nil {
+		t.Fatalf("query alerts: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var target string
+		if err := rows.Scan(&target); err != nil {
+			t.Fatal(err)
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) != 1 || targets[0] != "api" {
+		t.Fatalf("firing targets = %v, want [api]", targets)
+	}
+}
+
+func TestEvaluateDashiLogWriteErrors(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	n := notifier.NewTelegram("token", "chat")
+	n.HTTP = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
+	})}
+	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false, nil)
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+
+	firing := func() int {
+		var count int
+		if err := repo.DB().QueryRow(`SELECT COUNT(*) FROM alerts WHERE status='firing' AND target_fingerprint='dashi'`).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+	// The seeded "Log writes failing" rule ignores samples older than five
+	// minutes.
+	if err := repo.InsertSelfMetric(ctx, models.SelfMetric{TS: now.Add(-10 * time.Minute), LogWriteErrors: 50}); err != nil {
+		t.Fatalf("insert self metric: %v", err)
+	}
+	engine.Evaluate(ctx)
+	if got := firing(); got != 0 {
+		t.Fatalf("firing alerts for a stale sample = %d", got)
+	}
+	if err := repo.InsertSelfMetric(ctx, models.SelfMetric{TS: now.Add(-time.Minute), LogWriteErrors: 12}); err != nil {
+		t.Fatalf("insert self metric: %v", err)
+	}
+	engine.Evaluate(ctx)
+	if got := firing(); got != 1 {
+		t.Fatalf("firing alerts = %d, want 1", got)
+	}
+}
+
+func TestFlushSendsNotificationsCutOffByShutdown(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+
+	var sent []string
+	n := notifier.NewTelegram("token", "chat")
+	n.HTTP = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		body, _ := io.ReadAll(req.Body)
+		sent = append(sent, string(body))
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
+	})}
+	engine := NewEngine(repo, n, slog.New(slog.NewTextHandler(io.Discard, nil)), false, nil)
+
+	stopped, cancel := context.WithCancel(context.Background())
+	cancel()
+	engine.sendNotification(stopped, 0, "RECOVERY disk")
+	if len(sent) != 0 || len(engine.pending) != 1 {
+		t.Fatalf("sent %d, pending %d, want the notification kept for Flush", len(sent), len(engine.pending))
+	}
+	if err := engine.Flush(stopped); err == nil || len(engine.pending) != 1 {
+		t.Fatalf("flush with an expired context = %v, pending %d", err, len(engine.pending))
+	}
+	if err := engine.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if len(sent) != 1 || !strings.Contains(sent[0], "RECOVERY disk") || len(engine.pending) != 0 {
+		t.Fatalf("sent %v, pending %d", sent, len(engine.pending))
+	}
+}
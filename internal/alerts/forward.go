@@ -0,0 +1,93 @@
+package alerts
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+	"dashi/internal/notifier"
+	"dashi/internal/selfmon"
+	"dashi/internal/supervise"
+)
+
+// forwardResolvedFor is how long resolved alerts keep being sent, so an
+// Alertmanager that missed a run still learns they ended.
+const forwardResolvedFor = 15 * time.Minute
+
+// Forwarder sends dashi's alerts to Alertmanager. Like Prometheus, it sends
+// every firing alert on each run with an end time a few runs ahead, so
+// alerts resolve on their own if dashi stops sending them, and alerts that
+// ended with their end time.
+type Forwarder struct {
+	repo   *db.Repository
+	am     *notifier.Alertmanager
+	labels map[string]string
+	every  time.Duration
+	log    *slog.Logger
+	now    func() time.Time
+}
+
+// NewForwarder returns a forwarder adding labels, as "name=value", to every
+// alert it sends every interval.
+func NewForwarder(repo *db.Repository, am *notifier.Alertmanager, labels []string, every time.Duration, logger *slog.Logger) *Forwarder {
+	static := map[string]string{}
+	for _, l := range labels {
+		if k, v, ok := strings.Cut(l, "="); ok {
+			static[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return &Forwarder{repo: repo, am: am, labels: static, every: every, log: logger, now: time.Now}
+}
+
+func (f *Forwarder) Run(ctx context.Context) {
+	defer supervise.Recover(f.log, "alert forwarding")
+	now := f.now().UTC()
+	rows, err := f.repo.AlertsWithTargets(ctx, now.Add(-forwardResolvedFor))
+	if err != nil {
+		f.log.Error("load alerts to forward", "err", err)
+		return
+	}
+	if len(rows) == 0 {
+		return
+	}
+	out := make([]notifier.AlertmanagerAlert, 0, len(rows))
+	for _, a := range rows {
+		out = append(out, f.alert(a, now))
+	}
+	if err := f.am.Send(ctx, out); err != nil {
+		selfmon.NotifyFailures.Add(1)
+		f.log.Warn("forward alerts failed", "alerts", len(out), "err", err)
+	}
+}
+
+// alert converts a to Alertmanager's format. The labels identify the alert,
+// so they hold the rule and target but never the summary, which changes.
+func (f *Forwarder) alert(a models.AlertWithTarget, now time.Time) notifier.AlertmanagerAlert {
+	labels := make(map[string]string, len(f.labels)+7)
+	for k, v := range f.labels {
+		labels[k] = v
+	}
+	labels["alertname"] = a.RuleName
+	labels["metric"] = a.MetricKey
+	labels["target_type"] = a.TargetType
+	labels["target"] = a.Target
+	for k, v := range map[string]string{"container": a.Container, "service": a.Service, "host": a.Host} {
+		if v != "" {
+			labels[k] = v
+		}
+	}
+	end := now.Add(4 * f.every)
+	if a.Ended != nil {
+		end = a.Ended.UTC()
+	}
+	return notifier.AlertmanagerAlert{
+		Labels:      labels,
+		Annotations: map[string]string{"summary": a.Summary, "dashi_alert_id": strconv.FormatInt(a.ID, 10)},
+		StartsAt:    a.Started.UTC(),
+		EndsAt:      &end,
+	}
+}
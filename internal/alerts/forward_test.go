@@ -0,0 +1,91 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+	"dashi/internal/notifier"
+)
+
+func TestForwarderSendsFiringAndResolvedAlerts(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	rules, err := repo.ListRules(ctx)
+	if err != nil || len(rules) == 0 {
+		t.Fatalf("rules: %v %d", err, len(rules))
+	}
+	rule := rules[0]
+	svc := models.Service{ID: "web", Host: "local", Name: "web", Status: "running"}
+	if err := repo.UpsertServiceAndContainer(ctx, svc, models.Container{ID: "c1", ServiceID: "web", Host: "local", Name: "web-1", Status: "running"}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := repo.CreateAlert(ctx, rule.ID, "c1", "firing", "cpu high", nil, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	for target, ended := range map[string]time.Time{"web": now.Add(-5 * time.Minute), "host": now.Add(-time.Hour)} {
+		if _, err := repo.CreateAlert(ctx, rule.ID, target, "firing", "old", nil, now.Add(-2*time.Hour)); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if err := repo.CloseAlert(ctx, rule.ID, target, ended); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+	}
+
+	var got []notifier.AlertmanagerAlert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/alerts" || r.Method != http.MethodPost {
+			t.Errorf("request %s %s", r.Method, r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	f := NewForwarder(repo, notifier.NewAlertmanager([]string{srv.URL + "/"}), []string{"env=prod", "alertname=ignored"}, time.Minute, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	f.now = func() time.Time { return now }
+	f.Run(ctx)
+
+	if len(got) != 2 {
+		t.Fatalf("got %d alerts, want the firing one and the recently resolved one: %+v", len(got), got)
+	}
+	firing, resolved := got[0], got[1]
+	if firing.Labels["target"] != "c1" {
+		firing, resolved = resolved, firing
+	}
+	want := map[string]string{"alertname": rule.Name, "metric": rule.MetricKey, "target_type": rule.TargetType, "target": "c1", "container": "web-1", "service": "web", "host": "local", "env": "prod"}
+	for k, v := range want {
+		if firing.Labels[k] != v {
+			t.Fatalf("label %s = %q, want %q (%v)", k, firing.Labels[k], v, firing.Labels)
+		}
+	}
+	if firing.Annotations["summary"] != "cpu high" || firing.Annotations["dashi_alert_id"] == "" {
+		t.Fatalf("annotations %v", firing.Annotations)
+	}
+	if !firing.StartsAt.Equal(now.Add(-time.Hour)) || firing.EndsAt == nil || !firing.EndsAt.Equal(now.Add(4*time.Minute)) {
+		t.Fatalf("firing times %v %v", firing.StartsAt, firing.EndsAt)
+	}
+	if resolved.Labels["service"] != "web" || resolved.Labels["container"] != "" {
+		t.Fatalf("resolved labels %v", resolved.Labels)
+	}
+	if resolved.EndsAt == nil || !resolved.EndsAt.Equal(now.Add(-5*time.Minute)) {
+		t.Fatalf("resolved end %v", resolved.EndsAt)
+	}
+}
@@ -0,0 +1,1624 @@
+// Package api defines the JSON response schemas served under /api/v1.
+//
+// Types in this package are part of the public HTTP contract: fields may be
+// added, but existing fields are not renamed or removed within a version.
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	"dashi/internal/models"
+)
+
+const Version = "v1"
+
+type Error struct {
+	Error string `json:"error"`
+}
+
+type Status struct {
+	Status string `json:"status"`
+}
+
+type HostMetric struct {
+	TS             time.Time `json:"ts"`
+	CPUPct         float64   `json:"cpu_pct"`
+	MemUsedBytes   int64     `json:"mem_used_bytes"`
+	MemTotalBytes  int64     `json:"mem_total_bytes"`
+	NetRXBytes     int64     `json:"net_rx_bytes"`
+	NetTXBytes     int64     `json:"net_tx_bytes"`
+	DiskUsedBytes  int64     `json:"disk_used_bytes"`
+	DiskTotalBytes int64     `json:"disk_total_bytes"`
+	Load1          float64   `json:"load1"`
+	Load5          float64   `json:"load5"`
+	Load15         float64   `json:"load15"`
+	UptimeSec      int64     `json:"uptime_sec"`
+	NetRXRate      float64   `json:"net_rx_rate"`
+	NetTXRate      float64   `json:"net_tx_rate"`
+	SwapUsedBytes  int64     `json:"swap_used_bytes"`
+	SwapTotalBytes int64     `json:"swap_total_bytes"`
+	// Summed over physical disks; disk_util_pct is the busiest one.
+	DiskReadRate  float64 `json:"disk_read_rate"`
+	DiskWriteRate float64 `json:"disk_write_rate"`
+	DiskReadIOPS  float64 `json:"disk_read_iops"`
+	DiskWriteIOPS float64 `json:"disk_write_iops"`
+	DiskUtilPct   float64 `json:"disk_util_pct"`
+	// Hottest CPU and NVMe sensors in °C, 0 without such a sensor.
+	CPUTempC  float64 `json:"cpu_temp_c"`
+	NVMeTempC float64 `json:"nvme_temp_c"`
+	// TCP sockets by state; tcp_conns counts all but listening ones.
+	TCPEstablished int64 `json:"tcp_established"`
+	TCPSynRecv     int64 `json:"tcp_syn_recv"`
+	TCPTimeWait    int64 `json:"tcp_time_wait"`
+	TCPCloseWait   int64 `json:"tcp_close_wait"`
+	TCPListen      int64 `json:"tcp_listen"`
+	TCPConns       int64 `json:"tcp_conns"`
+
+	// Set only on rolled-up points; value fields above are bucket averages.
+	Samples         int      `json:"samples,omitempty"`
+	CPUPctMin       *float64 `json:"cpu_pct_min,omitempty"`
+	CPUPctMax       *float64 `json:"cpu_pct_max,omitempty"`
+	MemUsedBytesMax *int64   `json:"mem_used_bytes_max,omitempty"`
+	Load1Max        *float64 `json:"load1_max,omitempty"`
+}
+
+// HostMetrics.Resolution is "raw" or the rollup bucket width (e.g. "5m0s").
+type HostMetrics struct {
+	Range      string       `json:"range"`
+	Resolution string       `json:"resolution"`
+	Items      []HostMetric `json:"items"`
+}
+
+type DiskIO struct {
+	TS        time.Time `json:"ts"`
+	Device    string    `json:"device"`
+	ReadRate  float64   `json:"read_rate"`
+	WriteRate float64   `json:"write_rate"`
+	ReadIOPS  float64   `json:"read_iops"`
+	WriteIOPS float64   `json:"write_iops"`
+	UtilPct   float64   `json:"util_pct"`
+}
+
+type DiskIOMetrics struct {
+	Range string   `json:"range"`
+	Items []DiskIO `json:"items"`
+}
+
+type Temperature struct {
+	TS     time.Time `json:"ts"`
+	Sensor string    `json:"sensor"`
+	TempC  float64   `json:"temp_c"`
+}
+
+type Temperatures struct {
+	Range string        `json:"range"`
+	Items []Temperature `json:"items"`
+}
+
+// SelfMetric is a sample of dashi's own health; rates are per second and
+// counts cover the time since the previous sample.
+type SelfMetric struct {
+	TS             time.Time `json:"ts"`
+	LogLinesRate   float64   `json:"log_lines_rate"`
+	LogDroppedRate float64   `json:"log_dropped_rate"`
+	LogWriteErrors int64     `json:"log_write_errors"`
+	LogWorkers     int       `json:"log_workers"`
+	DBSizeBytes    int64     `json:"db_size_bytes"`
+	QueryRate      float64   `json:"query_rate"`
+	QueryAvgMS     float64   `json:"query_avg_ms"`
+	Goroutines     int       `json:"goroutines"`
+	HeapBytes      int64     `json:"heap_bytes"`
+	NotifyFailures int64     `json:"notify_failures"`
+	Panics         int64     `json:"panics"`
+	LogOverflow    int64     `json:"log_overflow"`
+}
+
+type SelfMetrics struct {
+	Range string       `json:"range"`
+	Items []SelfMetric `json:"items"`
+}
+
+type ContainerMetric struct {
+	TS            time.Time `json:"ts"`
+	ContainerID   string    `json:"container_id"`
+	CPUPct        float64   `json:"cpu_pct"`
+	MemUsedBytes  int64     `json:"mem_used_bytes"`
+	MemLimitBytes int64     `json:"mem_limit_bytes"`
+	NetRXBytes    int64     `json:"net_rx_bytes"`
+	NetTXBytes    int64     `json:"net_tx_bytes"`
+	BlkReadBytes  int64     `json:"blk_read_bytes"`
+	BlkWriteBytes int64     `json:"blk_write_bytes"`
+	NetRXRate     float64   `json:"net_rx_rate"`
+	NetTXRate     float64   `json:"net_tx_rate"`
+	BlkReadRate   float64   `json:"blk_read_rate"`
+	BlkWriteRate  float64   `json:"blk_write_rate"`
+	// Zero limits are unlimited or unknown; fds counts the main process.
+	Pids      int64 `json:"pids"`
+	PidsLimit int64 `json:"pids_limit"`
+	FDs       int64 `json:"fds"`
+	FDLimit   int64 `json:"fd_limit"`
+
+	Samples         int      `json:"samples,omitempty"`
+	CPUPctMin       *float64 `json:"cpu_pct_min,omitempty"`
+	CPUPctMax       *float64 `json:"cpu_pct_max,omitempty"`
+	MemUsedBytesMax *int64   `json:"mem_used_bytes_max,omitempty"`
+}
+
+type ContainerMetrics struct {
+	ContainerID string            `json:"container_id"`
+	Range       string            `json:"range"`
+	Resolution  string            `json:"resolution"`
+	Items       []ContainerMetric `json:"items"`
+}
+
+// ServiceLogMetric counts what a service logged in the minute starting at
+// TS, before drop rules and sampling.
+type ServiceLogMetric struct {
+	TS         time.Time `json:"ts"`
+	Lines      int64     `json:"lines"`
+	ErrorLines int64     `json:"error_lines"`
+	Bytes      int64     `json:"bytes"`
+	// Requests are parsed from access log formats; AvgLatencyMS is only set
+	// when their lines carry the request time.
+	Requests     int64    `json:"requests"`
+	Requests5xx  int64    `json:"requests_5xx"`
+	AvgLatencyMS *float64 `json:"avg_latency_ms,omitempty"`
+}
+
+// ServiceLogMetrics has one item per minute with logs; quiet minutes are
+// left out.
+type ServiceLogMetrics struct {
+	ServiceID string             `json:"service_id"`
+	Range     string             `json:"range"`
+	Items     []ServiceLogMetric `json:"items"`
+}
+
+type LogEntry struct {
+	TS          time.Time  `json:"ts"`
+	ServiceID   string     `json:"service_id"`
+	ContainerID string     `json:"container_id"`
+	Level       string     `json:"level"`
+	Stream      string     `json:"stream"`
+	Message     string     `json:"message"`
+	RepeatCount int        `json:"repeat_count,omitempty"`
+	LastSeen    *time.Time `json:"last_seen,omitempty"`
+	// Fields are extracted by the logs.extract_rules setting.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+type LogFilters struct {
+	Service string `json:"service,omitempty"`
+	Host    string `json:"host,omitempty"`
+	Query   string `json:"q,omitempty"`
+	Level   string `json:"level,omitempty"`
+	Stream  string `json:"stream,omitempty"`
+	Labels  string `json:"labels,omitempty"`
+	Range   string `json:"range,omitempty"`
+	// From and To bound the entries' time (RFC 3339); From overrides Range.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Fields holds the field.<name>=<value> filters.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// Host is a monitored Docker endpoint; "local" is the machine dashi runs on.
+// Host summarizes a Docker host. CPU and memory are those of the host when
+// Sampled, i.e. it is the machine dashi runs on, and otherwise the sums of
+// its running containers; disk is only known for sampled hosts.
+type Host struct {
+	Name           string  `json:"name"`
+	Containers     int     `json:"containers"`
+	Running        int     `json:"running"`
+	Sampled        bool    `json:"sampled"`
+	CPUPct         float64 `json:"cpu_pct"`
+	MemUsedBytes   int64   `json:"mem_used_bytes"`
+	MemTotalBytes  int64   `json:"mem_total_bytes,omitempty"`
+	DiskUsedBytes  int64   `json:"disk_used_bytes,omitempty"`
+	DiskTotalBytes int64   `json:"disk_total_bytes,omitempty"`
+	ActiveAlerts   int     `json:"active_alerts"`
+	Agent          *Agent  `json:"agent,omitempty"`
+}
+
+type Hosts struct {
+	Items []Host `json:"items"`
+}
+
+type ImageUpdate struct {
+	ServiceID       string    `json:"service_id"`
+	Image           string    `json:"image"`
+	CurrentDigest   string    `json:"current_digest,omitempty"`
+	LatestDigest    string    `json:"latest_digest,omitempty"`
+	UpdateAvailable bool      `json:"update_available"`
+	CheckedAt       time.Time `json:"checked_at"`
+	Error           string    `json:"error,omitempty"`
+}
+
+type HostReboot struct {
+	BootedAt      time.Time `json:"booted_at"`
+	LastSeenAt    time.Time `json:"last_seen_at"`
+	PrevUptimeSec int64     `json:"prev_uptime_sec"`
+	DowntimeSec   int64     `json:"downtime_sec"`
+	DetectedAt    time.Time `json:"detected_at"`
+}
+
+// RestartEvent is a detected restart; DowntimeSec is set when both the end
+// of the previous run and the start of the next are known.
+type RestartEvent struct {
+	ID              int64      `json:"id"`
+	ContainerID     string     `json:"container_id"`
+	ServiceID       string     `json:"service_id"`
+	Host            string     `json:"host"`
+	Name            string     `json:"name"`
+	Reason          string     `json:"reason"`
+	PrevContainerID string     `json:"prev_container_id,omitempty"`
+	ExitCode        *int       `json:"exit_code"`
+	RestartCount    int        `json:"restart_count"`
+	FinishedAt      *time.Time `json:"finished_at"`
+	StartedAt       *time.Time `json:"started_at"`
+	DowntimeSec     *int64     `json:"downtime_sec"`
+	DetectedAt      time.Time  `json:"detected_at"`
+}
+
+type Deployment struct {
+	ID          int64     `json:"id"`
+	TS          time.Time `json:"ts"`
+	ServiceID   string    `json:"service_id"`
+	Source      string    `json:"source"`
+	Version     string    `json:"version"`
+	Environment string    `json:"environment,omitempty"`
+	Description string    `json:"description,omitempty"`
+	URL         string    `json:"url,omitempty"`
+}
+
+// LogArchive is a segment of logs exported to object storage before
+// retention: the lines of one service and UTC day.
+type LogArchive struct {
+	ID        int64     `json:"id"`
+	Key       string    `json:"key"`
+	Day       string    `json:"day"`
+	ServiceID string    `json:"service_id"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Lines     int       `json:"lines"`
+	Bytes     int64     `json:"bytes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ContainerUsage is a container's average and peak usage over a window;
+// network and block I/O are in bytes per second, both directions summed.
+type ContainerUsage struct {
+	ContainerID string  `json:"container_id"`
+	ServiceID   string  `json:"service_id"`
+	Name        string  `json:"name"`
+	Host        string  `json:"host"`
+	Samples     int     `json:"samples"`
+	CPUAvg      float64 `json:"cpu_pct_avg"`
+	CPUMax      float64 `json:"cpu_pct_max"`
+	MemAvg      float64 `json:"mem_used_bytes_avg"`
+	MemMax      int64   `json:"mem_used_bytes_max"`
+	NetAvg      float64 `json:"net_rate_avg"`
+	NetMax      float64 `json:"net_rate_max"`
+	BlkAvg      float64 `json:"blk_rate_avg"`
+	BlkMax      float64 `json:"blk_rate_max"`
+}
+
+type ClockOffset struct {
+	Host      string    `json:"host"`
+	Source    string    `json:"source"`
+	OffsetMS  float64   `json:"offset_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type StoragePool struct {
+	Type         string     `json:"type"`
+	Name         string     `json:"name"`
+	Health       string     `json:"health"`
+	SizeBytes    int64      `json:"size_bytes"`
+	AllocBytes   int64      `json:"alloc_bytes"`
+	DeviceErrors int64      `json:"device_errors"`
+	DataErrors   int64      `json:"data_errors"`
+	ScrubState   string     `json:"scrub_state,omitempty"`
+	ScrubErrors  int64      `json:"scrub_errors"`
+	ScrubAt      *time.Time `json:"scrub_at,omitempty"`
+	CheckedAt    time.Time  `json:"checked_at"`
+}
+
+type Volume struct {
+	Host        string    `json:"host"`
+	Name        string    `json:"name"`
+	Driver      string    `json:"driver"`
+	SizeBytes   int64     `json:"size_bytes"`
+	GrowthBytes int64     `json:"growth_24h_bytes"`
+	RefCount    int       `json:"ref_count"`
+	TS          time.Time `json:"ts"`
+}
+
+type Image struct {
+	Host        string    `json:"host"`
+	ID          string    `json:"id"`
+	Tags        []string  `json:"tags"`
+	SizeBytes   int64     `json:"size_bytes"`
+	SharedBytes int64     `json:"shared_bytes"`
+	Containers  int       `json:"containers"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type Storage struct {
+	Volumes []Volume `json:"volumes"`
+	Images  []Image  `json:"images"`
+}
+
+type Process struct {
+	PID      int     `json:"pid"`
+	User     string  `json:"user,omitempty"`
+	CPUPct   float64 `json:"cpu_pct"`
+	RSSBytes int64   `json:"rss_bytes"`
+	Command  string  `json:"command"`
+}
+
+type Processes struct {
+	ContainerID string    `json:"container_id"`
+	Items       []Process `json:"items"`
+}
+
+// ContainerConfig is how a container was started. Secret-looking values
+// are replaced by "********" and flagged as redacted.
+type ContainerConfig struct {
+	ContainerID   string   `json:"container_id"`
+	Image         string   `json:"image"`
+	Entrypoint    []string `json:"entrypoint"`
+	Command       []string `json:"command"`
+	WorkingDir    string   `json:"working_dir,omitempty"`
+	User          string   `json:"user,omitempty"`
+	Env           []EnvVar `json:"env"`
+	Mounts        []Mount  `json:"mounts"`
+	RestartPolicy string   `json:"restart_policy"`
+	MaxRetries    int      `json:"max_retries,omitempty"`
+}
+
+type EnvVar struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Redacted bool   `json:"redacted,omitempty"`
+}
+
+type Mount struct {
+	Type        string `json:"type"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Mode        string `json:"mode,omitempty"`
+	ReadWrite   bool   `json:"rw"`
+}
+
+// PruneReport lists what a prune removed, or would remove for dry runs.
+// ReclaimedBytes of a dry run is an estimate.
+type PruneReport struct {
+	Host           string   `json:"host"`
+	Kind           string   `json:"kind"`
+	DryRun         bool     `json:"dry_run"`
+	Items          []string `json:"items"`
+	ReclaimedBytes int64    `json:"reclaimed_bytes"`
+}
+
+type AuditEvent struct {
+	ID     int64     `json:"id"`
+	TS     time.Time `json:"ts"`
+	Action string    `json:"action"`
+	Target string    `json:"target"`
+	Detail string    `json:"detail"`
+	Source string    `json:"source"`
+}
+
+type AuditEvents struct {
+	Items []AuditEvent `json:"items"`
+}
+
+// ServiceDetail is a service with what its containers listen on and the
+// networks they are attached to.
+type ServiceDetail struct {
+	ID         string            `json:"id"`
+	Host       string            `json:"host"`
+	Name       string            `json:"name"`
+	Image      string            `json:"image"`
+	Status     string            `json:"status"`
+	Labels     map[string]string `json:"labels"`
+	Containers []Container       `json:"containers"`
+}
+
+// ServiceUsage is a running service's container with its latest resource
+// usage, as listed by /api/v1/services.
+type ServiceUsage struct {
+	ServiceID    string    `json:"service_id"`
+	Name         string    `json:"name"`
+	Host         string    `json:"host"`
+	Status       string    `json:"status"`
+	ContainerID  string    `json:"container_id"`
+	RestartCount int       `json:"restart_count"`
+	CPUPct       float64   `json:"cpu_pct"`
+	MemUsedBytes int64     `json:"mem_used_bytes"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+type Services struct {
+	Items []ServiceUsage `json:"items"`
+}
+
+type Container struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Status       string     `json:"status"`
+	Health       string     `json:"health,omitempty"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	LastSeen     time.Time  `json:"last_seen"`
+	RestartCount int        `json:"restart_count"`
+	Ports        []Port     `json:"ports"`
+	Networks     []Network  `json:"networks"`
+	Links        []string   `json:"links,omitempty"`
+}
+
+// Port is a container port; HostIP and HostPort are empty when it is
+// exposed but not published.
+type Port struct {
+	HostIP        string `json:"host_ip,omitempty"`
+	HostPort      int    `json:"host_port,omitempty"`
+	ContainerPort int    `json:"container_port"`
+	Protocol      string `json:"protocol"`
+}
+
+type Network struct {
+	Name string `json:"name"`
+	IP   string `json:"ip,omitempty"`
+}
+
+// Health reports the database and each Docker host. Status is "ok",
+// "degraded" when a host is unreachable or refuses some API features (for
+// example behind docker-socket-proxy), or "unavailable" without a database.
+type Health struct {
+	Status   string         `json:"status"`
+	Database string         `json:"database"`
+	Docker   []DockerHealth `json:"docker"`
+	// ConfigWarnings are the configuration problems dashi started with.
+	ConfigWarnings []string `json:"config_warnings,omitempty"`
+	// Disabled lists the subsystems switched off: logs, alerts, metrics.
+	Disabled []string `json:"disabled,omitempty"`
+}
+
+type DockerHealth struct {
+	Host   string   `json:"host"`
+	Status string   `json:"status"`
+	Error  string   `json:"error,omitempty"`
+	Denied []string `json:"denied_features"`
+}
+
+type ImageUpdates struct {
+	Items []ImageUpdate `json:"items"`
+}
+
+type HostReboots struct {
+	Items []HostReboot `json:"items"`
+}
+
+type Deployments struct {
+	Range string       `json:"range"`
+	Items []Deployment `json:"items"`
+}
+
+type RestartEvents struct {
+	Range string         `json:"range"`
+	Items []RestartEvent `json:"items"`
+}
+
+// Availability is the share of the time a service had a running container
+// over the windows of 24 hours, 7 and 30 days up to now.
+type Availability struct {
+	ServiceID string               `json:"service_id"`
+	Windows   []AvailabilityWindow `json:"windows"`
+}
+
+// AvailabilityWindow is the availability over one window. ObservedSec only
+// counts the time since dashi first recorded a container of the service;
+// UptimePct is nil without any. Incidents counts the times it went down.
+type AvailabilityWindow struct {
+	Range       string    `json:"range"`
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	UptimePct   *float64  `json:"uptime_pct"`
+	ObservedSec int64     `json:"observed_sec"`
+	DowntimeSec int64     `json:"downtime_sec"`
+	Incidents   int       `json:"incidents"`
+}
+
+type Availabilities struct {
+	Items []Availability `json:"items"`
+}
+
+// Topology is a graph of the services and the Docker networks they are
+// attached to, with the compose dependencies and links between services.
+type Topology struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Edges []TopologyEdge `json:"edges"`
+}
+
+// TopologyNode is a service, or a network when Type is "network". Health
+// is "ok", "degraded" (a container not running or unhealthy), "alerting"
+// (firing alerts) or "down" (no container running); networks have none.
+type TopologyNode struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Host        string `json:"host"`
+	Project     string `json:"project,omitempty"`
+	Health      string `json:"health,omitempty"`
+	Containers  int    `json:"containers,omitempty"`
+	Running     int    `json:"running,omitempty"`
+	Alerts      int    `json:"alerts,omitempty"`
+	ContainerID string `json:"container_id,omitempty"`
+}
+
+// TopologyEdge connects two nodes. Kind is "network" from a service to a
+// network it is attached to, "depends_on" from a service to one it
+// depends on in compose, or "link" from a service to one it links to.
+type TopologyEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// Dashboard is a layout of the index page, its widgets in order.
+type Dashboard struct {
+	Name      string     `json:"name"`
+	Widgets   []Widget   `json:"widgets"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// Widget is a panel of a dashboard: its type, its column ("left" or
+// "main") and, for the widgets taking them, the services and range shown.
+type Widget struct {
+	Type     string   `json:"type"`
+	Column   string   `json:"column"`
+	Services []string `json:"services,omitempty"`
+	Range    string   `json:"range,omitempty"`
+}
+
+type Dashboards struct {
+	Items []Dashboard `json:"items"`
+}
+
+// SearchResult is a hit of /api/search. Type is "service", "container",
+// "image", "label", "alert" or "log"; URL is the page it jumps to.
+type SearchResult struct {
+	Type  string     `json:"type"`
+	ID    string     `json:"id"`
+	Title string     `json:"title"`
+	Match string     `json:"match"`
+	URL   string     `json:"url"`
+	Score int        `json:"score"`
+	TS    *time.Time `json:"ts,omitempty"`
+}
+
+type Search struct {
+	Query   string         `json:"q"`
+	Results []SearchResult `json:"results"`
+}
+
+// TopContainers ranks containers by By ("cpu", "mem", "net" or "disk")
+// and Stat ("avg" or "max") between From and To.
+type TopContainers struct {
+	From       time.Time        `json:"from"`
+	To         time.Time        `json:"to"`
+	Resolution string           `json:"resolution"`
+	By         string           `json:"by"`
+	Stat       string           `json:"stat"`
+	Items      []ContainerUsage `json:"items"`
+}
+
+// MetricComparison is one container metric of several containers and
+// services between From and To, resampled into buckets of StepSec seconds
+// starting at Timestamps so the series overlay.
+type MetricComparison struct {
+	Metric     string           `json:"metric"`
+	From       time.Time        `json:"from"`
+	To         time.Time        `json:"to"`
+	StepSec    int64            `json:"step_sec"`
+	Resolution string           `json:"resolution"`
+	Timestamps []time.Time      `json:"timestamps"`
+	Series     []ComparedSeries `json:"series"`
+}
+
+// ComparedSeries is a container's or a service's series of a comparison,
+// with one value per timestamp: the bucket's average, null without samples.
+type ComparedSeries struct {
+	Type   string     `json:"type"`
+	ID     string     `json:"id"`
+	Name   string     `json:"name"`
+	Values []*float64 `json:"values"`
+}
+
+type LogArchives struct {
+	Items []LogArchive `json:"items"`
+}
+
+// LogArchiveRestore is the result of POST
+// /api/v1/logs/archives/{id}/restore.
+type LogArchiveRestore struct {
+	ID       int64 `json:"id"`
+	Restored int   `json:"restored"`
+	Skipped  int   `json:"skipped,omitempty"`
+}
+
+type ClockOffsets struct {
+	Items []ClockOffset `json:"items"`
+}
+
+type StoragePools struct {
+	Items []StoragePool `json:"items"`
+}
+
+type Logs struct {
+	Filters LogFilters `json:"filters"`
+	Items   []LogEntry `json:"items"`
+}
+
+type LogBucket struct {
+	TS     time.Time        `json:"ts"`
+	Total  int64            `json:"total"`
+	Levels map[string]int64 `json:"levels"`
+}
+
+// LogHistogram counts the lines matching Filters in buckets of StepSec
+// seconds from From to To, empty ones included.
+type LogHistogram struct {
+	Filters LogFilters  `json:"filters"`
+	From    time.Time   `json:"from"`
+	To      time.Time   `json:"to"`
+	StepSec int64       `json:"step_sec"`
+	Buckets []LogBucket `json:"buckets"`
+}
+
+type LogGroup struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+type LogGroups struct {
+	GroupBy string     `json:"group_by"`
+	Filters LogFilters `json:"filters"`
+	Groups  []LogGroup `json:"groups"`
+}
+
+// LogDrop is a log drop rule (the logs.drop_rules setting) with the number
+// of lines it dropped since dashi started.
+type LogDrop struct {
+	Name    string `json:"name"`
+	Service string `json:"service,omitempty"`
+	Level   string `json:"level,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Dropped int64  `json:"dropped"`
+}
+
+type LogDrops struct {
+	Items []LogDrop `json:"items"`
+}
+
+// Preferences are UI settings remembered per user (X-Dashi-User header) or,
+// failing that, per browser session cookie.
+type Preferences struct {
+	DefaultRange string            `json:"default_range,omitempty"`
+	Services     []string          `json:"services,omitempty"`
+	Columns      []string          `json:"columns,omitempty"`
+	Filters      map[string]string `json:"filters,omitempty"`
+	UpdatedAt    *time.Time        `json:"updated_at,omitempty"`
+}
+
+// Setting is one entry of the runtime settings store. Values of secret keys
+// (tokens, passwords) are never returned; Secret marks them instead.
+type Setting struct {
+	Key    string          `json:"key"`
+	Value  json.RawMessage `json:"value,omitempty"`
+	Secret bool            `json:"secret,omitempty"`
+}
+
+type Settings struct {
+	Items []Setting `json:"items"`
+}
+
+type AlertRule struct {
+	Name            string  `json:"name"`
+	TargetType      string  `json:"target_type"`
+	TargetID        *string `json:"target_id,omitempty"`
+	MetricKey       string  `json:"metric_key"`
+	Operator        string  `json:"operator"`
+	Threshold       float64 `json:"threshold"`
+	ForSeconds      int     `json:"for_seconds"`
+	CooldownSeconds int     `json:"cooldown_seconds"`
+	Enabled         bool    `json:"enabled"`
+	LabelSelector   string  `json:"label_selector,omitempty"`
+}
+
+// Alert is a firing or recovered alert of a rule.
+type Alert struct {
+	ID      int64       `json:"id"`
+	Rule    string      `json:"rule"`
+	Status  string      `json:"status"`
+	Started time.Time   `json:"started"`
+	Ended   *time.Time  `json:"ended,omitempty"`
+	Summary string      `json:"summary"`
+	Notes   []AlertNote `json:"notes,omitempty"`
+}
+
+// AlertNote is a note on an alert, such as what was done or the root
+// cause.
+type AlertNote struct {
+	ID      int64     `json:"id"`
+	AlertID int64     `json:"alert_id"`
+	TS      time.Time `json:"ts"`
+	Author  string    `json:"author,omitempty"`
+	Text    string    `json:"text"`
+}
+
+type AlertNotes struct {
+	Items []AlertNote `json:"items"`
+}
+
+// NewAlertNote is the body of POST /api/v1/alerts/{id}/notes.
+type NewAlertNote struct {
+	Author string `json:"author"`
+	Text   string `json:"text"`
+}
+
+type Alerts struct {
+	Range string  `json:"range"`
+	Items []Alert `json:"items"`
+}
+
+// ConfigVersion is the format version of ConfigDocument.
+const ConfigVersion = 1
+
+// ConfigDocument is a portable export of an instance's configuration. Rules
+// are identified by name; secret settings are only included on request.
+type ConfigDocument struct {
+	Version     int                        `json:"version"`
+	ExportedAt  time.Time                  `json:"exported_at"`
+	Rules       []AlertRule                `json:"rules"`
+	Settings    map[string]json.RawMessage `json:"settings"`
+	Preferences map[string]json.RawMessage `json:"preferences"`
+}
+
+type ConfigImportResult struct {
+	Rules       int `json:"rules"`
+	Settings    int `json:"settings"`
+	Preferences int `json:"preferences"`
+}
+
+// ReloadResult is the response of POST /api/v1/admin/reload.
+type ReloadResult struct {
+	Reloaded bool `json:"reloaded"`
+}
+
+// IngestLogs is the body of POST /api/ingest/logs. Entries without a
+// source of their own are stored under Source.
+type IngestLogs struct {
+	Source  string           `json:"source"`
+	Entries []IngestLogEntry `json:"entries"`
+}
+
+// IngestLogEntry is one pushed log line. TS defaults to the time it is
+// received, Level is inferred from the message when empty and Stream
+// defaults to "ingest". Agents set ContainerID, one of the containers they
+// pushed to /api/ingest/containers, instead of a source.
+type IngestLogEntry struct {
+	TS          *time.Time        `json:"ts,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	ContainerID string            `json:"container_id,omitempty"`
+	Level       string            `json:"level,omitempty"`
+	Stream      string            `json:"stream,omitempty"`
+	Message     string            `json:"message"`
+	Fields      map[string]string `json:"fields,omitempty"`
+}
+
+// IngestResult counts the entries stored; Skipped ones named a container
+// the server does not know.
+type IngestResult struct {
+	Accepted int `json:"accepted"`
+	Skipped  int `json:"skipped,omitempty"`
+}
+
+// IngestContainers is the body of POST /api/ingest/containers: the
+// services and containers an agent currently sees on its Docker host.
+// Containers of Host missing from it are marked missing.
+type IngestContainers struct {
+	Host     string          `json:"host"`
+	Services []ServiceDetail `json:"services"`
+}
+
+// AgentHeartbeat is the body of POST /api/ingest/agent, which an agent
+// sends before each push; the first one registers it. The server expects
+// the next within a few intervals.
+type AgentHeartbeat struct {
+	Host          string `json:"host"`
+	OS            string `json:"os"`
+	Arch          string `json:"arch"`
+	Kernel        string `json:"kernel,omitempty"`
+	DockerVersion string `json:"docker_version,omitempty"`
+	IntervalSec   int    `json:"interval_sec"`
+}
+
+// Agent is a registered agent; it is offline once no heartbeat arrived by
+// its deadline.
+type Agent struct {
+	Host            string    `json:"host"`
+	Online          bool      `json:"online"`
+	OS              string    `json:"os"`
+	Arch            string    `json:"arch"`
+	Kernel          string    `json:"kernel,omitempty"`
+	DockerVersion   string    `json:"docker_version,omitempty"`
+	IntervalSec     int       `json:"interval_sec"`
+	RemoteAddr      string    `json:"remote_addr"`
+	RegisteredAt    time.Time `json:"registered_at"`
+	LastHeartbeatAt time.Time `json:"last_heartbeat_at"`
+	Deadline        time.Time `json:"deadline"`
+}
+
+type Agents struct {
+	Items []Agent `json:"items"`
+}
+
+// ScrapeTarget is a Prometheus exporter dashi scrapes, with its last
+// scrape; ScrapedAt is unset until the first.
+type ScrapeTarget struct {
+	Name       string     `json:"name"`
+	URL        string     `json:"url"`
+	Metrics    []string   `json:"metrics"`
+	Up         bool       `json:"up"`
+	ScrapedAt  *time.Time `json:"scraped_at,omitempty"`
+	DurationMS int64      `json:"duration_ms"`
+	Series     int        `json:"series"`
+	Dropped    int        `json:"dropped"`
+	Error      string     `json:"error,omitempty"`
+}
+
+type ScrapeTargets struct {
+	Items []ScrapeTarget `json:"items"`
+}
+
+type ScrapePoint struct {
+	TS    time.Time `json:"ts"`
+	Value float64   `json:"value"`
+}
+
+// ScrapeSeries is one series of a scraped metric, named as Prometheus
+// does, e.g. `http_requests_total{code="200"}`.
+type ScrapeSeries struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Points []ScrapePoint     `json:"points"`
+}
+
+type ScrapeMetric struct {
+	Target string         `json:"target"`
+	Metric string         `json:"metric"`
+	Range  string         `json:"range"`
+	Series []ScrapeSeries `json:"series"`
+}
+
+// Summary is what GET /api/v1/summary tells other dashi instances that
+// federate this one: its hosts and its firing alerts, the newest first.
+type Summary struct {
+	GeneratedAt  time.Time `json:"generated_at"`
+	Hosts        []Host    `json:"hosts"`
+	FiringAlerts int       `json:"firing_alerts"`
+	TopAlerts    []Alert   `json:"top_alerts"`
+}
+
+// Site is a federated dashi instance with the summary it last returned;
+// while it is not Up, Summary is the one from LastOKAt.
+type Site struct {
+	Name      string     `json:"name"`
+	URL       string     `json:"url"`
+	Up        bool       `json:"up"`
+	CheckedAt time.Time  `json:"checked_at"`
+	LastOKAt  *time.Time `json:"last_ok_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Summary   *Summary   `json:"summary,omitempty"`
+}
+
+type Sites struct {
+	Items []Site `json:"items"`
+}
+
+// IngestMetrics is the body of POST /api/ingest/metrics, samples of
+// containers an agent pushed to /api/ingest/containers.
+type IngestMetrics struct {
+	Host       string            `json:"host"`
+	Containers []ContainerMetric `json:"containers"`
+}
+
+func HostMetricFrom(m models.HostMetric) HostMetric {
+	return HostMetric{
+		TS:             m.TS.UTC(),
+		CPUPct:         m.CPUPct,
+		MemUsedBytes:   m.MemUsedBytes,
+		MemTotalBytes:  m.MemTotalBytes,
+		NetRXBytes:     m.NetRXBytes,
+		NetTXBytes:     m.NetTXBytes,
+		DiskUsedBytes:  m.DiskUsedBytes,
+		DiskTotalBytes: m.DiskTotalBytes,
+		Load1:          m.Load1,
+		Load5:          m.Load5,
+		Load15:         m.Load15,
+		UptimeSec:      m.UptimeSec,
+		NetRXRate:      m.NetRXRate,
+		NetTXRate:      m.NetTXRate,
+		SwapUsedBytes:  m.SwapUsedBytes,
+		SwapTotalBytes: m.SwapTotalBytes,
+		DiskReadRate:   m.DiskReadRate,
+		DiskWriteRate:  m.DiskWriteRate,
+		DiskReadIOPS:   m.DiskReadIOPS,
+		DiskWriteIOPS:  m.DiskWriteIOPS,
+		DiskUtilPct:    m.DiskUtilPct,
+		CPUTempC:       m.CPUTempC,
+		NVMeTempC:      m.NVMeTempC,
+		TCPEstablished: m.TCPEstablished,
+		TCPSynRecv:     m.TCPSynRecv,
+		TCPTimeWait:    m.TCPTimeWait,
+		TCPCloseWait:   m.TCPCloseWait,
+		TCPListen:      m.TCPListen,
+		TCPConns:       m.TCPConns,
+	}
+}
+
+func HostMetricsFrom(in []models.HostMetric) []HostMetric {
+	out := make([]HostMetric, 0, len(in))
+	for _, m := range in {
+		out = append(out, HostMetricFrom(m))
+	}
+	return out
+}
+
+func DiskIOFrom(in []models.DiskIO) []DiskIO {
+	out := make([]DiskIO, 0, len(in))
+	for _, d := range in {
+		out = append(out, DiskIO{TS: d.TS.UTC(), Device: d.Device, ReadRate: d.ReadRate, WriteRate: d.WriteRate, ReadIOPS: d.ReadIOPS, WriteIOPS: d.WriteIOPS, UtilPct: d.UtilPct})
+	}
+	return out
+}
+
+func SelfMetricsFrom(in []models.SelfMetric) []SelfMetric {
+	out := make([]SelfMetric, 0, len(in))
+	for _, m := range in {
+		out = append(out, SelfMetric{TS: m.TS.UTC(), LogLinesRate: m.LogLinesRate, LogDroppedRate: m.LogDroppedRate, LogWriteErrors: m.LogWriteErrors,
+			LogWorkers: m.LogWorkers, DBSizeBytes: m.DBSizeBytes, QueryRate: m.QueryRate, QueryAvgMS: m.QueryAvgMS, Goroutines: m.Goroutines,
+			HeapBytes: m.HeapBytes, NotifyFailures: m.NotifyFailures, Panics: m.Panics, LogOverflow: m.LogOverflow})
+	}
+	return out
+}
+
+func TemperaturesFrom(in []models.Temperature) []Temperature {
+	out := make([]Temperature, 0, len(in))
+	for _, t := range in {
+		out = append(out, Temperature{TS: t.TS.UTC(), Sensor: t.Sensor, TempC: t.TempC})
+	}
+	return out
+}
+
+func HostMetricRollupsFrom(in []models.HostMetricRollup) []HostMetric {
+	out := make([]HostMetric, 0, len(in))
+	for _, m := range in {
+		p := HostMetricFrom(m.HostMetric)
+		p.Samples = m.Samples
+		p.CPUPctMin = &m.CPUPctMin
+		p.CPUPctMax = &m.CPUPctMax
+		p.MemUsedBytesMax = &m.MemUsedMax
+		p.Load1Max = &m.Load1Max
+		out = append(out, p)
+	}
+	return out
+}
+
+func ContainerMetricFrom(m models.ContainerMetric) ContainerMetric {
+	return ContainerMetric{
+		TS:            m.TS.UTC(),
+		ContainerID:   m.ContainerID,
+		CPUPct:        m.CPUPct,
+		MemUsedBytes:  m.MemUsedBytes,
+		MemLimitBytes: m.MemLimitBytes,
+		NetRXBytes:    m.NetRXBytes,
+		NetTXBytes:    m.NetTXBytes,
+		BlkReadBytes:  m.BlkReadBytes,
+		BlkWriteBytes: m.BlkWriteBytes,
+		NetRXRate:     m.NetRXRate,
+		NetTXRate:     m.NetTXRate,
+		BlkReadRate:   m.BlkReadRate,
+		BlkWriteRate:  m.BlkWriteRate,
+		Pids:          m.Pids,
+		PidsLimit:     m.PidsLimit,
+		FDs:           m.FDs,
+		FDLimit:       m.FDLimit,
+	}
+}
+
+func (m ContainerMetric) Model() models.ContainerMetric {
+	return models.ContainerMetric{
+		TS:            m.TS.UTC(),
+		ContainerID:   m.ContainerID,
+		CPUPct:        m.CPUPct,
+		MemUsedBytes:  m.MemUsedBytes,
+		MemLimitBytes: m.MemLimitBytes,
+		NetRXBytes:    m.NetRXBytes,
+		NetTXBytes:    m.NetTXBytes,
+		BlkReadBytes:  m.BlkReadBytes,
+		BlkWriteBytes: m.BlkWriteBytes,
+		NetRXRate:     m.NetRXRate,
+		NetTXRate:     m.NetTXRate,
+		BlkReadRate:   m.BlkReadRate,
+		BlkWriteRate:  m.BlkWriteRate,
+		Pids:          m.Pids,
+		PidsLimit:     m.PidsLimit,
+		FDs:           m.FDs,
+		FDLimit:       m.FDLimit,
+	}
+}
+
+func ContainerMetricsFrom(in []models.ContainerMetric) []ContainerMetric {
+	out := make([]ContainerMetric, 0, len(in))
+	for _, m := range in {
+		out = append(out, ContainerMetricFrom(m))
+	}
+	return out
+}
+
+func ContainerMetricRollupsFrom(in []models.ContainerMetricRollup) []ContainerMetric {
+	out := make([]ContainerMetric, 0, len(in))
+	for _, m := range in {
+		p := ContainerMetricFrom(m.ContainerMetric)
+		p.Samples = m.Samples
+		p.CPUPctMin = &m.CPUPctMin
+		p.CPUPctMax = &m.CPUPctMax
+		p.MemUsedBytesMax = &m.MemUsedMax
+		out = append(out, p)
+	}
+	return out
+}
+
+func LogEntryFrom(e models.LogEntry) LogEntry {
+	out := LogEntry{
+		TS:          e.TS.UTC(),
+		ServiceID:   e.ServiceID,
+		ContainerID: e.ContainerID,
+		Level:       e.Level,
+		Stream:      e.Stream,
+		Message:     e.Message,
+		Fields:      e.Fields,
+	}
+	if e.RepeatCount > 1 {
+		out.RepeatCount = e.RepeatCount
+	}
+	if e.LastSeen != nil {
+		t := e.LastSeen.UTC()
+		out.LastSeen = &t
+	}
+	return out
+}
+
+func LogBucketsFrom(in []models.LogBucket) []LogBucket {
+	out := make([]LogBucket, 0, len(in))
+	for _, b := range in {
+		levels := b.Levels
+		if levels == nil {
+			levels = map[string]int64{}
+		}
+		out = append(out, LogBucket{TS: b.TS.UTC(), Total: b.Total, Levels: levels})
+	}
+	return out
+}
+
+func LogEntriesFrom(in []models.LogEntry) []LogEntry {
+	out := make([]LogEntry, 0, len(in))
+	for _, e := range in {
+		out = append(out, LogEntryFrom(e))
+	}
+	return out
+}
+
+// LogGroupsFrom converts the repository's generic group rows ({key,count}).
+func LogGroupsFrom(in []map[string]any) []LogGroup {
+	out := make([]LogGroup, 0, len(in))
+	for _, g := range in {
+		key, _ := g["key"].(string)
+		count, _ := g["count"].(int64)
+		out = append(out, LogGroup{Key: key, Count: count})
+	}
+	return out
+}
+
+// AlertsFrom converts the repository's alert rows.
+func AlertsFrom(in []map[string]any) []Alert {
+	out := make([]Alert, 0, len(in))
+	for _, a := range in {
+		item := Alert{}
+		item.ID, _ = a["id"].(int64)
+		item.Rule, _ = a["rule_name"].(string)
+		item.Status, _ = a["status"].(string)
+		item.Started, _ = a["started"].(time.Time)
+		item.Summary, _ = a["summary"].(string)
+		if ended, ok := a["ended"].(time.Time); ok {
+			item.Ended = &ended
+		}
+		if notes, ok := a["notes"].([]models.AlertNote); ok {
+			item.Notes = AlertNotesFrom(notes)
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+func AlertNotesFrom(in []models.AlertNote) []AlertNote {
+	out := make([]AlertNote, 0, len(in))
+	for _, n := range in {
+		out = append(out, AlertNoteFrom(n))
+	}
+	return out
+}
+
+func AlertNoteFrom(n models.AlertNote) AlertNote {
+	return AlertNote{ID: n.ID, AlertID: n.AlertID, TS: n.TS.UTC(), Author: n.Author, Text: n.Text}
+}
+
+// ServicesFrom converts the repository's service health rows.
+func ServicesFrom(in []map[string]any) []ServiceUsage {
+	out := make([]ServiceUsage, 0, len(in))
+	for _, s := range in {
+		item := ServiceUsage{}
+		item.ServiceID, _ = s["service_id"].(string)
+		item.Name, _ = s["name"].(string)
+		item.Host, _ = s["host"].(string)
+		item.Status, _ = s["status"].(string)
+		item.ContainerID, _ = s["container_id"].(string)
+		item.RestartCount, _ = s["restart_count"].(int)
+		item.CPUPct, _ = s["cpu_pct"].(float64)
+		item.MemUsedBytes, _ = s["mem_used_bytes"].(int64)
+		item.LastSeen, _ = s["last_seen"].(time.Time)
+		out = append(out, item)
+	}
+	return out
+}
+
+func AlertRuleFrom(r models.AlertRule) AlertRule {
+	return AlertRule{
+		Name:            r.Name,
+		TargetType:      r.TargetType,
+		TargetID:        r.TargetID,
+		MetricKey:       r.MetricKey,
+		Operator:        r.Operator,
+		Threshold:       r.Threshold,
+		ForSeconds:      r.ForSeconds,
+		CooldownSeconds: r.CooldownSeconds,
+		Enabled:         r.Enabled,
+		LabelSelector:   r.LabelSelector,
+	}
+}
+
+func (r AlertRule) Model() models.AlertRule {
+	return models.AlertRule{
+		Name:            r.Name,
+		TargetType:      r.TargetType,
+		TargetID:        r.TargetID,
+		MetricKey:       r.MetricKey,
+		Operator:        r.Operator,
+		Threshold:       r.Threshold,
+		ForSeconds:      r.ForSeconds,
+		CooldownSeconds: r.CooldownSeconds,
+		Enabled:         r.Enabled,
+		LabelSelector:   r.LabelSelector,
+	}
+}
+
+// HostsFrom converts host summaries; agents are online if their deadline
+// is after now.
+func HostsFrom(in []models.DockerHost, now time.Time) []Host {
+	out := make([]Host, 0, len(in))
+	for _, h := range in {
+		item := Host{Name: h.Name, Containers: h.Containers, Running: h.Running, CPUPct: h.CPUPct, MemUsedBytes: h.MemUsedBytes, ActiveAlerts: h.ActiveAlerts}
+		if m := h.Metric; m != nil {
+			item.Sampled = true
+			item.CPUPct, item.MemUsedBytes, item.MemTotalBytes = m.CPUPct, m.MemUsedBytes, m.MemTotalBytes
+			item.DiskUsedBytes, item.DiskTotalBytes = m.DiskUsedBytes, m.DiskTotalBytes
+		}
+		if h.Agent != nil {
+			a := AgentFrom(*h.Agent, now)
+			item.Agent = &a
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+func AgentFrom(a models.Agent, now time.Time) Agent {
+	return Agent{
+		Host:            a.Host,
+		Online:          now.Before(a.Deadline),
+		OS:              a.OS,
+		Arch:            a.Arch,
+		Kernel:          a.Kernel,
+		DockerVersion:   a.DockerVersion,
+		IntervalSec:     a.IntervalSec,
+		RemoteAddr:      a.RemoteAddr,
+		RegisteredAt:    a.RegisteredAt.UTC(),
+		LastHeartbeatAt: a.LastHeartbeatAt.UTC(),
+		Deadline:        a.Deadline.UTC(),
+	}
+}
+
+func AgentsFrom(in []models.Agent, now time.Time) []Agent {
+	out := make([]Agent, 0, len(in))
+	for _, a := range in {
+		out = append(out, AgentFrom(a, now))
+	}
+	return out
+}
+
+func SitesFrom(in []models.Site) []Site {
+	out := make([]Site, 0, len(in))
+	for _, s := range in {
+		item := Site{Name: s.Name, URL: s.URL, Up: s.Error == "", CheckedAt: s.CheckedAt.UTC(), Error: s.Error}
+		if s.LastOKAt != nil {
+			t := s.LastOKAt.UTC()
+			item.LastOKAt = &t
+		}
+		var sum Summary
+		if s.SummaryJSON != "" && json.Unmarshal([]byte(s.SummaryJSON), &sum) == nil {
+			item.Summary = &sum
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+func ImageUpdatesFrom(in []models.ImageUpdate) []ImageUpdate {
+	out := make([]ImageUpdate, 0, len(in))
+	for _, u := range in {
+		out = append(out, ImageUpdate{
+			ServiceID:       u.ServiceID,
+			Image:           u.Image,
+			CurrentDigest:   u.CurrentDigest,
+			LatestDigest:    u.LatestDigest,
+			UpdateAvailable: u.UpdateAvailable,
+			CheckedAt:       u.CheckedAt.UTC(),
+			Error:           u.Error,
+		})
+	}
+	return out
+}
+
+// HostRebootsFrom converts reboots; the downtime is the gap between the last
+// sample and the boot.
+func HostRebootsFrom(in []models.HostReboot) []HostReboot {
+	out := make([]HostReboot, 0, len(in))
+	for _, rb := range in {
+		out = append(out, HostReboot{
+			BootedAt:      rb.BootedAt.UTC(),
+			LastSeenAt:    rb.LastSeenAt.UTC(),
+			PrevUptimeSec: rb.PrevUptimeSec,
+			DowntimeSec:   max(int64(rb.BootedAt.Sub(rb.LastSeenAt).Seconds()), 0),
+			DetectedAt:    rb.DetectedAt.UTC(),
+		})
+	}
+	return out
+}
+
+func DeploymentsFrom(in []models.Deployment) []Deployment {
+	out := make([]Deployment, 0, len(in))
+	for _, d := range in {
+		out = append(out, Deployment{ID: d.ID, TS: d.TS.UTC(), ServiceID: d.ServiceID, Source: d.Source, Version: d.Version,
+			Environment: d.Environment, Description: d.Description, URL: d.URL})
+	}
+	return out
+}
+
+func RestartEventsFrom(in []models.RestartEvent) []RestartEvent {
+	out := make([]RestartEvent, 0, len(in))
+	for _, ev := range in {
+		item := RestartEvent{ID: ev.ID, ContainerID: ev.ContainerID, ServiceID: ev.ServiceID, Host: ev.Host, Name: ev.Name, Reason: ev.Reason,
+			PrevContainerID: ev.PrevContainerID, ExitCode: ev.ExitCode, RestartCount: ev.RestartCount, DetectedAt: ev.DetectedAt.UTC()}
+		if ev.FinishedAt != nil {
+			t := ev.FinishedAt.UTC()
+			item.FinishedAt = &t
+		}
+		if ev.StartedAt != nil {
+			t := ev.StartedAt.UTC()
+			item.StartedAt = &t
+		}
+		if item.FinishedAt != nil && item.StartedAt != nil && item.StartedAt.After(*item.FinishedAt) {
+			d := int64(item.StartedAt.Sub(*item.FinishedAt).Seconds())
+			item.DowntimeSec = &d
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+func LogArchivesFrom(in []models.LogArchive) []LogArchive {
+	out := make([]LogArchive, 0, len(in))
+	for _, a := range in {
+		out = append(out, LogArchive{ID: a.ID, Key: a.Key, Day: a.Day, ServiceID: a.ServiceID, From: a.From.UTC(), To: a.To.UTC(),
+			Lines: a.Lines, Bytes: a.Bytes, CreatedAt: a.CreatedAt.UTC()})
+	}
+	return out
+}
+
+// DashboardFrom converts d; the shipped default layout, never stored, has
+// no UpdatedAt.
+func DashboardFrom(d models.Dashboard) Dashboard {
+	out := Dashboard{Name: d.Name, Widgets: make([]Widget, 0, len(d.Widgets))}
+	for _, w := range d.Widgets {
+		out.Widgets = append(out.Widgets, Widget{Type: w.Type, Column: w.Column, Services: w.Services, Range: w.Range})
+	}
+	if !d.UpdatedAt.IsZero() {
+		t := d.UpdatedAt.UTC()
+		out.UpdatedAt = &t
+	}
+	return out
+}
+
+func DashboardsFrom(in []models.Dashboard) []Dashboard {
+	out := make([]Dashboard, 0, len(in))
+	for _, d := range in {
+		out = append(out, DashboardFrom(d))
+	}
+	return out
+}
+
+func ContainerUsageFrom(in []models.ContainerUsage) []ContainerUsage {
+	out := make([]ContainerUsage, 0, len(in))
+	for _, u := range in {
+		out = append(out, ContainerUsage{ContainerID: u.ContainerID, ServiceID: u.ServiceID, Name: u.Name, Host: u.Host, Samples: u.Samples,
+			CPUAvg: u.CPUAvg, CPUMax: u.CPUMax, MemAvg: u.MemAvg, MemMax: u.MemMax, NetAvg: u.NetAvg, NetMax: u.NetMax, BlkAvg: u.BlkAvg, BlkMax: u.BlkMax})
+	}
+	return out
+}
+
+func ClockOffsetsFrom(in []models.ClockOffset) []ClockOffset {
+	out := make([]ClockOffset, 0, len(in))
+	for _, o := range in {
+		out = append(out, ClockOffset{Host: o.Host, Source: o.Source, OffsetMS: o.OffsetMS, CheckedAt: o.CheckedAt.UTC(), Error: o.Error})
+	}
+	return out
+}
+
+func StoragePoolsFrom(in []models.StoragePool) []StoragePool {
+	out := make([]StoragePool, 0, len(in))
+	for _, p := range in {
+		sp := StoragePool{Type: p.Type, Name: p.Name, Health: p.Health, SizeBytes: p.SizeBytes, AllocBytes: p.AllocBytes, DeviceErrors: p.DeviceErrors, DataErrors: p.DataErrors, ScrubState: p.ScrubState, ScrubErrors: p.ScrubErrors, CheckedAt: p.CheckedAt.UTC()}
+		if p.ScrubAt != nil {
+			t := p.ScrubAt.UTC()
+			sp.ScrubAt = &t
+		}
+		out = append(out, sp)
+	}
+	return out
+}
+
+func StorageFrom(volumes []models.VolumeUsage, images []models.ImageUsage) Storage {
+	out := Storage{Volumes: make([]Volume, 0, len(volumes)), Images: make([]Image, 0, len(images))}
+	for _, v := range volumes {
+		out.Volumes = append(out.Volumes, Volume{Host: v.Host, Name: v.Name, Driver: v.Driver, SizeBytes: v.SizeBytes, GrowthBytes: v.GrowthBytes, RefCount: v.RefCount, TS: v.TS.UTC()})
+	}
+	for _, img := range images {
+		tags := img.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		out.Images = append(out.Images, Image{Host: img.Host, ID: img.ID, Tags: tags, SizeBytes: img.SizeBytes, SharedBytes: img.SharedBytes, Containers: img.Containers, UpdatedAt: img.UpdatedAt.UTC()})
+	}
+	return out
+}
+
+func ProcessesFrom(in []models.Process) []Process {
+	out := make([]Process, 0, len(in))
+	for _, p := range in {
+		out = append(out, Process{PID: p.PID, User: p.User, CPUPct: p.CPUPct, RSSBytes: p.RSSBytes, Command: p.Command})
+	}
+	return out
+}
+
+func ServiceLogMetricsFrom(serviceID, rng string, metrics []models.ServiceLogMetric) ServiceLogMetrics {
+	out := ServiceLogMetrics{ServiceID: serviceID, Range: rng, Items: make([]ServiceLogMetric, 0, len(metrics))}
+	for _, m := range metrics {
+		item := ServiceLogMetric{TS: m.TS.UTC(), Lines: m.Lines, ErrorLines: m.ErrorLines, Bytes: m.Bytes, Requests: m.Requests, Requests5xx: m.Requests5xx}
+		if m.TimedRequests > 0 {
+			avg := m.LatencyMS / float64(m.TimedRequests)
+			item.AvgLatencyMS = &avg
+		}
+		out.Items = append(out.Items, item)
+	}
+	return out
+}
+
+func ServiceDetailFrom(svc models.Service, containers []models.Container) ServiceDetail {
+	out := ServiceDetail{ID: svc.ID, Host: svc.Host, Name: svc.Name, Image: svc.Image, Status: svc.Status, Labels: map[string]string{}, Containers: make([]Container, 0, len(containers))}
+	_ = json.Unmarshal([]byte(svc.LabelsJSON), &out.Labels)
+	for _, c := range containers {
+		ct := Container{ID: c.ID, Name: c.Name, Status: c.Status, Health: c.Health, StartedAt: c.StartedAt, LastSeen: c.LastSeenAt, RestartCount: c.RestartCount, Links: c.Links,
+			Ports: make([]Port, 0, len(c.Ports)), Networks: make([]Network, 0, len(c.Networks))}
+		for _, p := range c.Ports {
+			ct.Ports = append(ct.Ports, Port{HostIP: p.HostIP, HostPort: p.HostPort, ContainerPort: p.ContainerPort, Protocol: p.Protocol})
+		}
+		for _, n := range c.Networks {
+			ct.Networks = append(ct.Networks, Network{Name: n.Name, IP: n.IP})
+		}
+		out.Containers = append(out.Containers, ct)
+	}
+	return out
+}
+
+// Model converts a service pushed by an agent back into rows.
+func (d ServiceDetail) Model() (models.Service, []models.Container) {
+	labels, _ := json.Marshal(d.Labels)
+	if d.Labels == nil {
+		labels = []byte("{}")
+	}
+	svc := models.Service{ID: d.ID, Host: d.Host, Name: d.Name, Image: d.Image, LabelsJSON: string(labels), Status: d.Status}
+	containers := make([]models.Container, 0, len(d.Containers))
+	for _, c := range d.Containers {
+		ct := models.Container{ID: c.ID, ServiceID: d.ID, Host: d.Host, Name: c.Name, Status: c.Status, Health: c.Health,
+			StartedAt: c.StartedAt, LastSeenAt: c.LastSeen, RestartCount: c.RestartCount, Links: c.Links}
+		for _, p := range c.Ports {
+			ct.Ports = append(ct.Ports, models.Port{HostIP: p.HostIP, HostPort: p.HostPort, ContainerPort: p.ContainerPort, Protocol: p.Protocol})
+		}
+		for _, n := range c.Networks {
+			ct.Networks = append(ct.Networks, models.Network{Name: n.Name, IP: n.IP})
+		}
+		containers = append(containers, ct)
+	}
+	return svc, containers
+}
+
+func ContainerConfigFrom(id string, in models.ContainerConfig) ContainerConfig {
+	out := ContainerConfig{
+		ContainerID: id, Image: in.Image, Entrypoint: in.Entrypoint, Command: in.Command,
+		WorkingDir: in.WorkingDir, User: in.User, RestartPolicy: in.RestartPolicy, MaxRetries: in.MaxRetries,
+		Env: make([]EnvVar, 0, len(in.Env)), Mounts: make([]Mount, 0, len(in.Mounts)),
+	}
+	for _, v := range in.Env {
+		out.Env = append(out.Env, EnvVar{Name: v.Name, Value: v.Value, Redacted: v.Redacted})
+	}
+	for _, m := range in.Mounts {
+		out.Mounts = append(out.Mounts, Mount{Type: m.Type, Source: m.Source, Destination: m.Destination, Mode: m.Mode, ReadWrite: m.ReadWrite})
+	}
+	return out
+}
+
+func PruneReportFrom(in models.PruneReport) PruneReport {
+	items := in.Items
+	if items == nil {
+		items = []string{}
+	}
+	return PruneReport{Host: in.Host, Kind: in.Kind, DryRun: in.DryRun, Items: items, ReclaimedBytes: in.ReclaimedBytes}
+}
+
+func AuditEventsFrom(in []models.AuditEvent) []AuditEvent {
+	out := make([]AuditEvent, 0, len(in))
+	for _, e := range in {
+		out = append(out, AuditEvent{ID: e.ID, TS: e.TS, Action: e.Action, Target: e.Target, Detail: e.Detail, Source: e.Source})
+	}
+	return out
+}
+
+// Check is an HTTP probe with its last result and its availability over
+// the last 24 hours. As a request body, fields other than the definition
+// are ignored and enabled defaults to true.
+type Check struct {
+	ID           int64        `json:"id"`
+	Name         string       `json:"name"`
+	URL          string       `json:"url"`
+	Method       string       `json:"method"`
+	ExpectStatus int          `json:"expect_status"`
+	ExpectBody   string       `json:"expect_body"`
+	IntervalSec  int          `json:"interval_sec"`
+	TimeoutSec   int          `json:"timeout_sec"`
+	Enabled      *bool        `json:"enabled"`
+	Last         *CheckResult `json:"last,omitempty"`
+	Probes24h    int          `json:"probes_24h"`
+	Uptime24h    float64      `json:"uptime_24h_pct"`
+	Latency24h   float64      `json:"avg_latency_24h_ms"`
+}
+
+type CheckResult struct {
+	TS         time.Time `json:"ts"`
+	OK         bool      `json:"ok"`
+	StatusCode int       `json:"status_code"`
+	LatencyMS  int64     `json:"latency_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type Checks struct {
+	Items []Check `json:"items"`
+}
+
+type CheckHistory struct {
+	Check Check         `json:"check"`
+	Range string        `json:"range"`
+	Items []CheckResult `json:"items"`
+}
+
+func CheckFrom(s models.CheckStatus) Check {
+	enabled := s.Enabled
+	out := Check{
+		ID:           s.ID,
+		Name:         s.Name,
+		URL:          s.URL,
+		Method:       s.Method,
+		ExpectStatus: s.ExpectStatus,
+		ExpectBody:   s.ExpectBody,
+		IntervalSec:  s.IntervalSec,
+		TimeoutSec:   s.TimeoutSec,
+		Enabled:      &enabled,
+		Probes24h:    s.Probes,
+		Uptime24h:    s.UptimePct,
+		Latency24h:   s.AvgLatencyMS,
+	}
+	if s.Last != nil {
+		last := CheckResultFrom(*s.Last)
+		out.Last = &last
+	}
+	return out
+}
+
+func ChecksFrom(in []models.CheckStatus) []Check {
+	out := make([]Check, 0, len(in))
+	for _, s := range in {
+		out = append(out, CheckFrom(s))
+	}
+	return out
+}
+
+func CheckResultFrom(r models.CheckResult) CheckResult {
+	return CheckResult{TS: r.TS.UTC(), OK: r.OK, StatusCode: r.StatusCode, LatencyMS: r.LatencyMS, Error: r.Error}
+}
+
+func CheckResultsFrom(in []models.CheckResult) []CheckResult {
+	out := make([]CheckResult, 0, len(in))
+	for _, r := range in {
+		out = append(out, CheckResultFrom(r))
+	}
+	return out
+}
+
+// Heartbeat is an inbound monitor. Jobs GET or POST ping_url when they
+// succeed and ping_url + "/fail" when they fail; push_url takes Uptime Kuma
+// style pushes instead. As a request body only name, period_sec, grace_sec
+// and token, generated when empty, are read.
+type Heartbeat struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Token      string     `json:"token"`
+	PingURL    string     `json:"ping_url"`
+	PushURL    string     `json:"push_url"`
+	PeriodSec  int        `json:"period_sec"`
+	GraceSec   int        `json:"grace_sec"`
+	State      string     `json:"state"`
+	Deadline   time.Time  `json:"deadline"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastPingAt *time.Time `json:"last_ping_at"`
+	LastFailAt *time.Time `json:"last_fail_at"`
+}
+
+type Heartbeats struct {
+	Items []Heartbeat `json:"items"`
+}
+
+func HeartbeatFrom(h models.Heartbeat, pingURL, pushURL, state string, deadline time.Time) Heartbeat {
+	out := Heartbeat{ID: h.ID, Name: h.Name, Token: h.Token, PingURL: pingURL, PushURL: pushURL, PeriodSec: h.PeriodSec, GraceSec: h.GraceSec, State: state,
+		Deadline: deadline.UTC(), CreatedAt: h.CreatedAt.UTC()}
+	if h.LastPingAt != nil {
+		t := h.LastPingAt.UTC()
+		out.LastPingAt = &t
+	}
+	if h.LastFailAt != nil {
+		t := h.LastFailAt.UTC()
+		out.LastFailAt = &t
+	}
+	return out
+}
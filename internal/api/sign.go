@@ -0,0 +1,37 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// Agents sign their pushes to /api/ingest instead of sending a token: the
+// signature is an HMAC-SHA256, keyed with the agent's token, over the
+// timestamp, method, path, idempotency key and body, so the token never
+// travels and a captured request cannot be altered or replayed much later.
+const (
+	// AgentHeader names the agent, i.e. the Docker host it pushes for.
+	AgentHeader = "X-Dashi-Agent"
+	// TimestampHeader holds the unix seconds the request was signed at.
+	TimestampHeader = "X-Dashi-Timestamp"
+	// SignatureHeader holds "sha256=" and the hex signature.
+	SignatureHeader = "X-Dashi-Signature"
+	// IdempotencyHeader holds a key the server answers a repeated push
+	// with the first result for, without storing the batch again.
+	IdempotencyHeader = "Idempotency-Key"
+)
+
+// Sign returns the SignatureHeader value of a request.
+func Sign(token string, ts int64, method, path, key string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(strconv.FormatInt(ts, 10) + "\n" + method + "\n" + path + "\n" + key + "\n"))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidSignature reports whether signature is the one of the request.
+func ValidSignature(token, signature string, ts int64, method, path, key string, body []byte) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(token, ts, method, path, key, body)))
+}
@@ -0,0 +1,176 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"dashi/internal/agent"
+	"dashi/internal/collector"
+	"dashi/internal/config"
+	"dashi/internal/db"
+	"dashi/internal/docker"
+	"dashi/internal/events"
+	"dashi/internal/logs"
+	"dashi/internal/selfmon"
+	"dashi/internal/settings"
+)
+
+// Agent runs dashi in agent mode: it collects container metrics and logs
+// of the local Docker host into a spool database and pushes them to the
+// server at APP_AGENT_SERVER, which shows them under the host APP_AGENT_NAME.
+// It serves no UI and evaluates no alerts.
+type Agent struct {
+	cfg     config.Config
+	log     *slog.Logger
+	db      *db.Repository
+	host    *dockerHost
+	shipper *agent.Shipper
+	// debugSrv serves pprof and expvar on APP_DEBUG_ADDR.
+	debugSrv *http.Server
+	// containerChanged is signalled by the Docker event watcher.
+	containerChanged chan struct{}
+}
+
+// NewAgent opens the spool database in APP_DATA_DIR and sets up collection
+// for the local Docker socket.
+func NewAgent(cfg config.Config, logger *slog.Logger) (*Agent, error) {
+	sqldb, err := db.Open(filepath.Join(cfg.DataDir, "agent.db"))
+	if err != nil {
+		return nil, fmt.Errorf("open spool: %w", err)
+	}
+	if err := db.Migrate(sqldb); err != nil {
+		_ = sqldb.Close()
+		return nil, err
+	}
+	repo := db.NewRepository(sqldb)
+	st := settings.NewStore(repo, logger.With("module", "settings"))
+	flt, err := monitorFilter(cfg, st, logger)
+	if err != nil {
+		return nil, err
+	}
+	// Service IDs carry the agent's name, as for the server's remote hosts.
+	h := &dockerHost{name: cfg.AgentName, client: docker.NewClient(cfg.DockerSocket)}
+	h.client.SetLogger(logger.With("module", "docker", "docker_host", h.name))
+	h.collector = collector.NewService(repo, h.client, logger.With("module", "collector", "docker_host", h.name), flt, h.name, collector.Options{
+		Workers:       cfg.CollectWorkers,
+		Deadline:      cfg.StatsEvery,
+		StreamStats:   cfg.StatsStream,
+		InventoryOnly: !cfg.MetricsEnabled,
+		OnTick:        selfmon.NewPinger(cfg.HealthcheckURL, logger.With("module", "selfmon")).Tick,
+	})
+	if cfg.LogsEnabled {
+		// Drops, sampling, deduplication and field extraction are left to
+		// the server, which applies its own rules to pushed lines.
+		opts := logs.Options{
+			KeepColors: cfg.LogColors,
+			QueueSize:  cfg.LogQueueSize,
+			BatchSize:  cfg.LogBatchSize,
+		}
+		if cfg.LogMemoryMB > 0 {
+			opts.Budget = logs.NewBudget(int64(cfg.LogMemoryMB) << 20)
+		}
+		h.ingestor = logs.NewIngestor(repo, h.client, logger.With("module", "logs", "docker_host", h.name), flt, h.name, opts)
+	}
+	a := &Agent{
+		cfg:              cfg,
+		log:              logger,
+		db:               repo,
+		host:             h,
+		shipper:          agent.NewShipper(repo, h.client, logger.With("module", "agent"), cfg.AgentServer, cfg.AgentToken, cfg.AgentName, cfg.AgentPushEvery),
+		debugSrv:         debugServer(cfg),
+		containerChanged: make(chan struct{}, 1),
+	}
+	if cfg.DockerEvents {
+		h.events = events.NewWatcher(repo, h.client, logger.With("module", "events", "docker_host", h.name), a.containerChanged)
+	}
+	return a, nil
+}
+
+func (a *Agent) Run(ctx context.Context) error {
+	inspectTicker := time.NewTicker(a.cfg.InspectEvery)
+	pushTicker := time.NewTicker(a.cfg.AgentPushEvery)
+	defer inspectTicker.Stop()
+	defer pushTicker.Stop()
+	var stats <-chan time.Time
+	if a.cfg.MetricsEnabled {
+		t := time.NewTicker(a.cfg.StatsEvery)
+		defer t.Stop()
+		stats = t.C
+	}
+	var logsTick <-chan time.Time
+	if a.host.ingestor != nil {
+		t := time.NewTicker(10 * time.Second)
+		defer t.Stop()
+		logsTick = t.C
+	}
+	if a.host.events != nil {
+		go a.host.events.Run(ctx)
+	}
+	serveDebug(a.debugSrv, a.log)
+
+	a.host.collector.Inspect(ctx)
+	a.host.collector.CollectStats(ctx)
+	a.reconcileLogs(ctx)
+	a.ship(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return a.shutdown()
+		case <-inspectTicker.C:
+			a.host.collector.Inspect(ctx)
+		case <-stats:
+			a.host.collector.CollectStats(ctx)
+		case <-logsTick:
+			a.reconcileLogs(ctx)
+		case <-a.containerChanged:
+			a.host.collector.Inspect(ctx)
+			a.reconcileLogs(ctx)
+		case <-pushTicker.C:
+			a.ship(ctx)
+		}
+	}
+}
+
+func (a *Agent) reconcileLogs(ctx context.Context) {
+	if a.host.ingestor != nil {
+		a.host.ingestor.Reconcile(ctx)
+	}
+}
+
+// ship pushes the spool; failures are retried on the next tick while the
+// spool keeps up to a day of data.
+func (a *Agent) ship(ctx context.Context) {
+	if err := a.shipper.Ship(ctx); err != nil && ctx.Err() == nil {
+		a.log.Warn("push to server", "err", err)
+		if err := a.shipper.Trim(ctx); err != nil {
+			a.log.Warn("trim spool", "err", err)
+		}
+	}
+}
+
+// shutdown stops the log workers and the collector so their pending
+// batches reach the spool, pushes once more and closes the spool.
+func (a *Agent) shutdown() error {
+	a.log.Info("shutting down", "timeout", a.cfg.ShutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+	defer cancel()
+	if a.debugSrv != nil {
+		_ = a.debugSrv.Close()
+	}
+	if a.host.ingestor != nil {
+		if err := a.host.ingestor.Stop(ctx); err != nil {
+			a.log.Warn("log workers did not stop in time", "err", err)
+		}
+	}
+	if err := a.host.collector.Close(ctx); err != nil {
+		a.log.Warn("metric writes did not flush in time", "err", err)
+	}
+	if err := a.shipper.Ship(ctx); err != nil {
+		a.log.Warn("final push to server", "err", err)
+	}
+	return a.db.DB().Close()
+}
@@ -2,18 +2,49 @@ package app
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"dashi/internal/alerts"
+	"dashi/internal/api"
+	"dashi/internal/backup"
+	"dashi/internal/checks"
+	"dashi/internal/clock"
 	"dashi/internal/collector"
 	"dashi/internal/config"
 	"dashi/internal/db"
+	"dashi/internal/demo"
+	"dashi/internal/diag"
 	"dashi/internal/docker"
+	"dashi/internal/events"
+	"dashi/internal/federation"
+	"dashi/internal/filter"
+	"dashi/internal/gelf"
+	"dashi/internal/logarchive"
 	"dashi/internal/logs"
+	"dashi/internal/maintenance"
+	"dashi/internal/models"
 	"dashi/internal/notifier"
+	"dashi/internal/otlp"
+	"dashi/internal/replica"
 	"dashi/internal/retention"
+	"dashi/internal/rollup"
+	"dashi/internal/schedule"
+	"dashi/internal/scrape"
+	"dashi/internal/selfmon"
+	"dashi/internal/settings"
+	"dashi/internal/updates"
 	"dashi/internal/web"
 )
 
@@ -21,21 +52,79 @@ type App struct {
 	cfg config.Config
 	log *slog.Logger
 
-	db     *db.Repository
-	docker *docker.Client
+	db    *db.Repository
+	hosts []*dockerHost
+	// demo runs the generated fleet in demo mode, which has no hosts.
+	demo *demo.Service
+	// containerChanged is signalled by the Docker event watchers.
+	containerChanged chan struct{}
 
-	collector *collector.Service
-	ingestor  *logs.Ingestor
 	alerts    *alerts.Engine
 	retention *retention.Service
+	rollup    *rollup.Service
+	maint     *maintenance.Service
+	backup    *backup.Service
+	replica   *replica.Service
+	checks    *checks.Runner
+	sites     *federation.Poller
+	scraper   *scrape.Scraper
 	notify    *notifier.Telegram
+	// alertsFwd forwards alerts to APP_ALERTMANAGER_URLS; nil when unset.
+	alertsFwd *alerts.Forwarder
+	self      *selfmon.Sampler
 	web       *web.Server
+	settings  *settings.Store
+	// reloadMu serializes reloads from SIGHUP and the settings page.
+	reloadMu sync.Mutex
+
+	// logSink stores logs from inputs other than Docker, such as GELF.
+	logSink *logs.Sink
+	gelf    *gelf.Server
+	gelfSrv *http.Server
+	files   *logs.FileTail
+	forward *logs.Forwarder
+	// logStats counts log lines per service for the service_log_* metrics.
+	logStats *logs.Stats
+	// spill queues log batches that failed to be stored; nil when off.
+	spill *logs.Spill
 
 	httpSrv *http.Server
+	// debugSrv serves pprof and expvar on APP_DEBUG_ADDR.
+	debugSrv *http.Server
 }
 
 func New(cfg config.Config, logger *slog.Logger) (*App, error) {
-	sqldb, err := db.Open(cfg.DBPath)
+	if cfg.DBDriver == "sqlite" && cfg.IntegrityCheck != "off" {
+		if err := checkIntegrity(cfg, logger); err != nil {
+			return nil, err
+		}
+	}
+	var store *replica.S3
+	if cfg.ReplicaEnabled() {
+		store = replica.NewS3(cfg.ReplicaEndpoint, cfg.ReplicaRegion, cfg.ReplicaBucket, cfg.ReplicaAccessKey, cfg.ReplicaSecretKey)
+		if cfg.ReplicaRestore && cfg.DBDriver == "sqlite" {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			restored, err := replica.Restore(ctx, store, cfg.ReplicaPrefix, cfg.DBPath)
+			cancel()
+			if err != nil {
+				return nil, fmt.Errorf("restore replica: %w", err)
+			}
+			if restored {
+				logger.Info("restored database from replica", "path", cfg.DBPath)
+			}
+		}
+	}
+
+	var sqldb *sql.DB
+	var err error
+	switch cfg.DBDriver {
+	case "postgres", "postgresql":
+		sqldb, err = db.OpenPostgres(cfg.DBURL)
+	case "sqlite", "sqlite3", "":
+		sqldb, err = db.OpenWithLogs(cfg.DBPath, cfg.LogsDBPath)
+	default:
+		err = fmt.Errorf("unsupported APP_DB_DRIVER %q", cfg.DBDriver)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -43,34 +132,565 @@ func New(cfg config.Config, logger *slog.Logger) (*App, error) {
 		return nil, err
 	}
 	repo := db.NewRepository(sqldb)
-	dc := docker.NewClient(cfg.DockerSocket)
+	var endpoints []*dockerHost
+	if !cfg.DemoMode {
+		endpoints, err = dockerEndpoints(cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	token, chatID, _ := repo.LoadTelegramSettings(context.Background())
-	if token == "" {
-		token = cfg.TelegramBotToken
+	st := settings.NewStore(repo, logger.With("module", "settings"))
+	if err := encryptSettings(cfg, st, logger); err != nil {
+		return nil, err
+	}
+	telegram := func(ctx context.Context) (string, string) {
+		return st.String(ctx, "telegram.token", cfg.TelegramBotToken), st.String(ctx, "telegram.chat_id", cfg.TelegramChatID)
+	}
+	n := notifier.NewTelegram(telegram(context.Background()))
+	st.OnChange("telegram", func(ctx context.Context, _ string) { n.Update(telegram(ctx)) })
+	flt, err := monitorFilter(cfg, st, logger)
+	if err != nil {
+		return nil, err
+	}
+	drops := logDropper(st, logger.With("module", "logs"))
+	extract := logExtractor(st, logger.With("module", "logs"))
+	scraper := scrapeTargets(repo, st, logger.With("module", "scrape"))
+	var fwd *logs.Forwarder
+	if cfg.LogForwardURL != "" && cfg.LogsEnabled {
+		fwd, err = logs.NewForwarder(logs.ForwardOptions{
+			URL:    cfg.LogForwardURL,
+			Format: cfg.LogForwardFormat,
+			Token:  cfg.LogForwardToken,
+			Tenant: cfg.LogForwardTenant,
+		}, logger.With("module", "logs"))
+		if err != nil {
+			return nil, fmt.Errorf("APP_LOG_FORWARD_URL: %w", err)
+		}
+	}
+	stats := logs.NewStats()
+	tail := logs.NewTail()
+	var spill *logs.Spill
+	if cfg.LogsEnabled && cfg.LogSpillMaxMB > 0 {
+		spill, err = logs.NewSpill(repo, filepath.Join(cfg.DataDir, "log-spill"), int64(cfg.LogSpillMaxMB)<<20, cfg.LogDedupWindow, logger.With("module", "logs"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	var budget *logs.Budget
+	if cfg.LogMemoryMB > 0 {
+		budget = logs.NewBudget(int64(cfg.LogMemoryMB) << 20)
+	}
+	sink := logs.NewSink(repo, logger.With("module", "logs"), logs.Options{
+		DedupWindow: cfg.LogDedupWindow,
+		Drops:       drops,
+		RateLimit:   cfg.LogRateLimit,
+		SampleEvery: cfg.LogSampleEvery,
+		Forward:     fwd,
+		Stats:       stats,
+		Extract:     extract,
+		Tail:        tail,
+		Spill:       spill,
+		QueueSize:   cfg.LogQueueSize,
+		BatchSize:   cfg.LogBatchSize,
+		Budget:      budget,
+	})
+	auth, err := registryAuth(cfg)
+	if err != nil {
+		return nil, err
+	}
+	agentTokens := map[string]string{}
+	for _, entry := range cfg.AgentTokens {
+		name, token, _ := strings.Cut(entry, "=")
+		agentTokens[strings.TrimSpace(name)] = strings.TrimSpace(token)
 	}
-	if chatID == "" {
-		chatID = cfg.TelegramChatID
+	peers := make([]federation.Peer, 0, len(cfg.FederationPeers))
+	for _, entry := range cfg.FederationPeers {
+		name, u, _ := strings.Cut(entry, "=")
+		peers = append(peers, federation.Peer{Name: strings.TrimSpace(name), URL: strings.TrimRight(strings.TrimSpace(u), "/")})
 	}
-	n := notifier.NewTelegram(token, chatID)
-	w := web.NewServer(repo, dc, n, logger)
+	var logArchive *logarchive.Archiver
+	var logArchiver retention.LogArchiver
+	if cfg.LogArchiveEnabled() {
+		logArchive = logarchive.NewArchiver(repo, replica.NewS3(cfg.LogsS3Endpoint, cfg.LogsS3Region, cfg.LogsS3Bucket, cfg.LogsS3AccessKey, cfg.LogsS3SecretKey),
+			cfg.LogsS3Prefix, logger.With("module", "logarchive"))
+		logArchiver = logArchive
+	}
+	ret := retention.NewService(repo, st, models.RetentionPolicy{
+		LogsDays:    cfg.LogRetentionDays,
+		MetricsDays: cfg.MetricsDays,
+		RollupDays:  cfg.RollupDays,
+		AlertsDays:  cfg.AlertsDays,
+	}, cfg.ArchiveAfter, logArchiver, logger.With("module", "retention"))
+	bk := backup.NewService(repo, cfg.BackupDir, cfg.BackupKeep, logger.With("module", "backup"))
+	clients := make(map[string]*docker.Client, len(endpoints))
+	for _, h := range endpoints {
+		h.client.SetLogger(logger.With("module", "docker", "docker_host", h.name))
+		clients[h.name] = h.client
+	}
+	var app *App
+	opts := web.Options{
+		Reload:          func(ctx context.Context) error { return app.Reload(ctx) },
+		ConfigFile:      cfg.ConfigFile,
+		Settings:        st,
+		Retention:       ret,
+		Backup:          bk,
+		LogArchive:      logArchive,
+		DockerHosts:     clients,
+		PruneEnabled:    cfg.PruneEnabled,
+		IngestToken:     cfg.IngestToken,
+		AgentTokens:     agentTokens,
+		Federation:      len(peers) > 0,
+		ConfigWarnings:  cfg.Warnings,
+		LogsDisabled:    !cfg.LogsEnabled,
+		AlertsDisabled:  !cfg.AlertsEnabled,
+		MetricsDisabled: !cfg.MetricsEnabled,
+		CORSOrigins:     cfg.CORSOrigins,
+		CORSMethods:     cfg.CORSMethods,
+		CSP:             cfg.CSP,
+		CSPScriptSrc:    cfg.CSPScriptSrc,
+		HSTSMaxAge:      cfg.HSTSMaxAge,
+		FrameOptions:    cfg.FrameOptions,
+		ReferrerPolicy:  cfg.ReferrerPolicy,
+		Demo:            cfg.DemoMode,
+	}
+	if cfg.MetricsEnabled {
+		opts.Scraper, opts.OTLP = scraper, otlp.NewConverter()
+	}
+	if cfg.LogsEnabled {
+		opts.LogDrops, opts.LogExtract, opts.LogTail, opts.LogSink, opts.LogSpill, opts.LogBudget = drops, extract, tail, sink, spill, budget
+	}
+	var local *docker.Client
+	if len(endpoints) > 0 {
+		local = endpoints[0].client
+	}
+	w := web.NewServer(repo, local, n, logger, opts)
 
-	app := &App{
+	app = &App{
 		cfg:       cfg,
 		log:       logger,
 		db:        repo,
-		docker:    dc,
-		collector: collector.NewService(repo, dc, logger.With("module", "collector")),
-		ingestor:  logs.NewIngestor(repo, dc, logger.With("module", "logs"), cfg.SkipSelfLogs),
-		alerts:    alerts.NewEngine(repo, n, logger.With("module", "alerts"), cfg.DebugRestarts),
-		retention: retention.NewService(repo, cfg.RetentionDays, logger.With("module", "retention")),
+		alerts:    alerts.NewEngine(repo, n, logger.With("module", "alerts"), cfg.DebugRestarts, flt),
+		retention: ret,
+		backup:    bk,
+		rollup:    rollup.NewService(repo, logger.With("module", "rollup")),
+		maint:     maintenance.NewService(repo, int64(cfg.WALMaxMB)<<20, cfg.VacuumPages, logger.With("module", "maintenance")),
+		checks:    checks.NewRunner(repo, logger.With("module", "checks")),
+		sites:     federation.NewPoller(repo, logger.With("module", "federation"), peers),
+		scraper:   scraper,
 		notify:    n,
 		web:       w,
+		settings:  st,
+		logSink:   sink,
+		spill:     spill,
+		forward:   fwd,
+		logStats:  stats,
+	}
+	if len(cfg.AlertmanagerURLs) > 0 {
+		app.alertsFwd = alerts.NewForwarder(repo, notifier.NewAlertmanager(cfg.AlertmanagerURLs), cfg.AlertLabels, cfg.AlertSendEvery, logger.With("module", "alertmanager"))
+	}
+	app.self = selfmon.NewSampler(repo, logger.With("module", "selfmon"), app.logWorkers)
+	app.containerChanged = make(chan struct{}, 1)
+	pinger := selfmon.NewPinger(cfg.HealthcheckURL, logger.With("module", "selfmon"))
+	for _, h := range endpoints {
+		h.collector = collector.NewService(repo, h.client, logger.With("module", "collector", "docker_host", h.name), flt, h.name, collector.Options{
+			Workers:       cfg.CollectWorkers,
+			Deadline:      cfg.StatsEvery,
+			StreamStats:   cfg.StatsStream,
+			CgroupRoot:    cfg.CgroupRoot,
+			InventoryOnly: !cfg.MetricsEnabled,
+			OnTick:        pinger.Tick,
+		})
+		if cfg.LogsEnabled {
+			h.ingestor = logs.NewIngestor(repo, h.client, logger.With("module", "logs", "docker_host", h.name), flt, h.name, logs.Options{
+				SkipSelfLogs: cfg.SkipSelfLogs,
+				DedupWindow:  cfg.LogDedupWindow,
+				KeepColors:   cfg.LogColors,
+				Drops:        drops,
+				RateLimit:    cfg.LogRateLimit,
+				SampleEvery:  cfg.LogSampleEvery,
+				Forward:      fwd,
+				Stats:        stats,
+				Extract:      extract,
+				Tail:         tail,
+				Spill:        spill,
+				QueueSize:    cfg.LogQueueSize,
+				BatchSize:    cfg.LogBatchSize,
+				Budget:       budget,
+			})
+		}
+		if cfg.DockerEvents {
+			h.events = events.NewWatcher(repo, h.client, logger.With("module", "events", "docker_host", h.name), app.containerChanged)
+		}
+		if cfg.ImageCheckEvery > 0 {
+			h.updates = updates.NewChecker(repo, h.client, logger.With("module", "updates", "docker_host", h.name), flt, h.name, auth)
+		}
+		if cfg.ClockCheckEvery > 0 {
+			// NTP measures dashi's own clock, so only the local host asks.
+			ntp := ""
+			if h.name == docker.LocalHost && cfg.NTPServer != "off" {
+				ntp = cfg.NTPServer
+			}
+			h.clock = clock.NewChecker(repo, h.client, logger.With("module", "clock", "docker_host", h.name), h.name, ntp)
+		}
+	}
+	app.hosts = endpoints
+	if cfg.DemoMode {
+		var demoSink *logs.Sink
+		if cfg.LogsEnabled {
+			demoSink = sink
+		}
+		app.demo = demo.NewService(repo, demoSink, logger.With("module", "demo"), demo.Options{
+			Every:         cfg.MetricsInterval,
+			InventoryOnly: !cfg.MetricsEnabled,
+		})
+	}
+	if store != nil {
+		app.replica = replica.NewService(repo, store, cfg.ReplicaPrefix, logger.With("module", "replica"))
+	}
+	if (cfg.GELFUDPAddr != "" || cfg.GELFHTTPAddr != "") && cfg.LogsEnabled {
+		app.gelf = gelf.NewServer(repo, sink, logger.With("module", "gelf"))
+	}
+	if len(cfg.LogFiles) > 0 && cfg.LogsEnabled {
+		globs, err := logs.ParseFileGlobs(cfg.LogFiles)
+		if err != nil {
+			return nil, fmt.Errorf("APP_LOG_FILES: %w", err)
+		}
+		app.files = logs.NewFileTail(repo, sink, logger.With("module", "logs"), globs)
+	}
+	if cfg.GELFHTTPAddr != "" && app.gelf != nil {
+		app.gelfSrv = &http.Server{Addr: cfg.GELFHTTPAddr, Handler: app.gelf.Handler()}
 	}
 	app.httpSrv = &http.Server{Addr: cfg.Addr, Handler: w.Routes()}
+	app.debugSrv = debugServer(cfg)
+	if cfg.ConfigFile != "" {
+		if err := app.applyConfigFile(context.Background()); err != nil {
+			return nil, err
+		}
+	}
 	return app, nil
 }
 
+// Reload applies the configuration file again and runs every settings
+// change hook, so log filters, notification channels and the monitor filter
+// pick up changes. Log workers and alert state are left running; alert
+// rules are read on every evaluation anyway.
+func (a *App) Reload(ctx context.Context) error {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+	if a.cfg.ConfigFile != "" {
+		if err := a.applyConfigFile(ctx); err != nil {
+			a.log.Error("reload configuration", "err", err)
+			return err
+		}
+	}
+	a.settings.Reload(ctx)
+	a.log.Info("configuration reloaded", "file", a.cfg.ConfigFile)
+	return nil
+}
+
+// applyConfigFile imports APP_CONFIG_FILE, a document in the format of
+// /api/v1/admin/config.
+func (a *App) applyConfigFile(ctx context.Context) error {
+	raw, err := os.ReadFile(a.cfg.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("APP_CONFIG_FILE: %w", err)
+	}
+	var doc api.ConfigDocument
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("APP_CONFIG_FILE %s: %w", a.cfg.ConfigFile, err)
+	}
+	if _, err := a.web.ImportConfig(ctx, doc); err != nil {
+		return fmt.Errorf("APP_CONFIG_FILE %s: %w", a.cfg.ConfigFile, err)
+	}
+	return nil
+}
+
+// dockerHost is one monitored Docker endpoint with its workers.
+type dockerHost struct {
+	name      string
+	client    *docker.Client
+	collector *collector.Service
+	ingestor  *logs.Ingestor
+	events    *events.Watcher
+	updates   *updates.Checker
+	clock     *clock.Checker
+}
+
+// dockerEndpoints returns the local DOCKER_SOCKET endpoint followed by the
+// "name=endpoint" entries of APP_DOCKER_HOSTS. An entry named "local"
+// replaces the socket. TLS material for tcp endpoints is read from
+// APP_DOCKER_CERT_DIR/<name> when that directory exists.
+func dockerEndpoints(cfg config.Config) ([]*dockerHost, error) {
+	hosts := []*dockerHost{{name: docker.LocalHost, client: docker.NewClient(cfg.DockerSocket)}}
+	seen := map[string]bool{}
+	for _, entry := range cfg.DockerHosts {
+		name, endpoint, ok := strings.Cut(entry, "=")
+		name, endpoint = strings.TrimSpace(name), strings.TrimSpace(endpoint)
+		if !ok || name == "" || endpoint == "" || strings.ContainsAny(name, "@/ ") {
+			return nil, fmt.Errorf("invalid APP_DOCKER_HOSTS entry %q, want name=endpoint", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate docker host %q", name)
+		}
+		seen[name] = true
+		certDir := ""
+		if cfg.DockerCertDir != "" {
+			if st, err := os.Stat(filepath.Join(cfg.DockerCertDir, name)); err == nil && st.IsDir() {
+				certDir = filepath.Join(cfg.DockerCertDir, name)
+			}
+		}
+		client, err := docker.Dial(endpoint, certDir)
+		if err != nil {
+			return nil, fmt.Errorf("docker host %s: %w", name, err)
+		}
+		if name == docker.LocalHost {
+			hosts[0].client = client
+			continue
+		}
+		hosts = append(hosts, &dockerHost{name: name, client: client})
+	}
+	return hosts, nil
+}
+
+// registryAuth parses the "registry=user:password" entries of
+// APP_REGISTRY_AUTH.
+func registryAuth(cfg config.Config) (map[string]docker.RegistryAuth, error) {
+	out := map[string]docker.RegistryAuth{}
+	for _, entry := range cfg.RegistryAuth {
+		registry, cred, ok := strings.Cut(entry, "=")
+		user, pass, okCred := strings.Cut(cred, ":")
+		registry = strings.TrimSpace(registry)
+		if !ok || !okCred || registry == "" || user == "" {
+			return nil, fmt.Errorf("invalid APP_REGISTRY_AUTH entry for %q, want registry=user:password", registry)
+		}
+		out[registry] = docker.RegistryAuth{Username: user, Password: pass, ServerAddress: registry}
+	}
+	return out, nil
+}
+
+// eachHost runs fn for every Docker endpoint concurrently and waits, so a
+// slow remote daemon does not delay the others.
+func (a *App) eachHost(fn func(*dockerHost)) {
+	var wg sync.WaitGroup
+	for _, h := range a.hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(h)
+		}()
+	}
+	wg.Wait()
+}
+
+// encryptSettings encrypts secret settings at rest with APP_SECRET_KEY, or
+// with a key generated into the data directory on first start, and
+// encrypts those stored in plain text before.
+func encryptSettings(cfg config.Config, st *settings.Store, logger *slog.Logger) error {
+	key := cfg.SecretKey
+	if key == "" {
+		var err error
+		key, err = settings.LoadOrCreateKey(filepath.Join(cfg.DataDir, "secret.key"))
+		if err != nil {
+			return fmt.Errorf("settings secret key: %w", err)
+		}
+	}
+	c, err := settings.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("settings secret key: %w", err)
+	}
+	st.SetCipher(c)
+	n, err := st.EncryptSecrets(context.Background())
+	if err != nil {
+		return fmt.Errorf("encrypt settings: %w", err)
+	}
+	if n > 0 {
+		logger.Info("encrypted stored secrets", "count", n)
+	}
+	return nil
+}
+
+// monitorFilter builds the container filter from the monitor.* settings,
+// falling back to the APP_MONITOR_* environment, and keeps it in sync with
+// later settings changes.
+func monitorFilter(cfg config.Config, st *settings.Store, logger *slog.Logger) (*filter.Filter, error) {
+	rules := func(ctx context.Context) (string, string, string) {
+		return st.String(ctx, "monitor.labels", cfg.MonitorLabels),
+			st.String(ctx, "monitor.include", cfg.MonitorInclude),
+			st.String(ctx, "monitor.exclude", cfg.MonitorExclude)
+	}
+	flt, err := filter.New(rules(context.Background()))
+	if err != nil {
+		return nil, fmt.Errorf("monitor filter: %w", err)
+	}
+	st.Validate("monitor", func(key string, v json.RawMessage) error {
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return errors.New("must be a string")
+		}
+		if key == "monitor.labels" {
+			_, err := db.ParseLabelSelector(s)
+			return err
+		}
+		_, err := filter.Patterns(s)
+		return err
+	})
+	st.OnChange("monitor", func(ctx context.Context, _ string) {
+		if err := flt.Set(rules(ctx)); err != nil {
+			logger.Warn("update monitor filter", "err", err)
+		}
+	})
+	return flt, nil
+}
+
+// logDropper loads the log drop rules from settings and keeps them in sync
+// with later changes. Invalid rules are rejected when they are saved.
+func logDropper(st *settings.Store, logger *slog.Logger) *logs.Dropper {
+	d := logs.NewDropper()
+	load := func(ctx context.Context) {
+		var raw json.RawMessage
+		if ok, err := st.Get(ctx, logs.DropRulesKey, &raw); err != nil || !ok {
+			d.Set(nil)
+			return
+		}
+		rules, err := logs.ParseDropRules(raw)
+		if err != nil {
+			logger.Warn("load log drop rules", "err", err)
+			return
+		}
+		d.Set(rules)
+	}
+	st.Validate("logs", func(key string, v json.RawMessage) error {
+		if key != logs.DropRulesKey {
+			return nil
+		}
+		_, err := logs.ParseDropRules(v)
+		return err
+	})
+	st.OnChange("logs", func(ctx context.Context, _ string) { load(ctx) })
+	load(context.Background())
+	return d
+}
+
+// logExtractor loads the log field extraction rules from settings and keeps
+// them in sync like logDropper.
+func logExtractor(st *settings.Store, logger *slog.Logger) *logs.Extractor {
+	x := logs.NewExtractor()
+	load := func(ctx context.Context) {
+		var raw json.RawMessage
+		if ok, err := st.Get(ctx, logs.ExtractRulesKey, &raw); err != nil || !ok {
+			x.Set(nil)
+			return
+		}
+		rules, err := logs.ParseExtractRules(raw)
+		if err != nil {
+			logger.Warn("load log extraction rules", "err", err)
+			return
+		}
+		x.Set(rules)
+	}
+	st.Validate("logs", func(key string, v json.RawMessage) error {
+		if key != logs.ExtractRulesKey {
+			return nil
+		}
+		_, err := logs.ParseExtractRules(v)
+		return err
+	})
+	st.OnChange("logs", func(ctx context.Context, _ string) { load(ctx) })
+	load(context.Background())
+	return x
+}
+
+// scrapeTargets loads the scrape targets from settings and keeps them in
+// sync like logDropper.
+func scrapeTargets(repo *db.Repository, st *settings.Store, logger *slog.Logger) *scrape.Scraper {
+	sc := scrape.NewScraper(repo, logger)
+	load := func(ctx context.Context) {
+		var raw json.RawMessage
+		if ok, err := st.Get(ctx, scrape.TargetsKey, &raw); err != nil || !ok {
+			sc.Set(nil)
+			return
+		}
+		targets, err := scrape.ParseTargets(raw)
+		if err != nil {
+			logger.Warn("load scrape targets", "err", err)
+			return
+		}
+		sc.Set(targets)
+	}
+	st.Validate("scrape", func(key string, v json.RawMessage) error {
+		var err error
+		switch key {
+		case scrape.TargetsKey:
+			_, err = scrape.ParseTargets(v)
+		case scrape.PanelsKey:
+			_, err = scrape.ParsePanels(v)
+		}
+		return err
+	})
+	st.OnChange("scrape", func(ctx context.Context, _ string) { load(ctx) })
+	load(context.Background())
+	return sc
+}
+
+// checkIntegrity moves corrupt SQLite files aside and puts the newest healthy
+// local backup in place of the main database. Without one the app starts on
+// a fresh schema (or a replica restore) instead of crash-looping.
+func checkIntegrity(cfg config.Config, logger *slog.Logger) error {
+	full := cfg.IntegrityCheck == "full"
+	for _, path := range []string{cfg.DBPath, cfg.LogsDBPath} {
+		if path == "" {
+			continue
+		}
+		err := db.CheckIntegrity(path, full)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, db.ErrCorrupt) {
+			return fmt.Errorf("check database %s: %w", path, err)
+		}
+		moved, qerr := db.Quarantine(path)
+		if qerr != nil {
+			return fmt.Errorf("move corrupt database aside: %w", qerr)
+		}
+		logger.Error("database corrupt, moved aside", "path", path, "moved_to", moved, "err", err)
+		if path != cfg.DBPath || cfg.BackupDir == "" {
+			continue
+		}
+		src, err := backup.RestoreLatest(cfg.BackupDir, path)
+		if err != nil {
+			logger.Error("restore from backup failed", "err", err)
+			continue
+		}
+		if src != "" {
+			logger.Info("restored database from backup", "path", path, "backup", src)
+		}
+	}
+	return nil
+}
+
+// debugServer returns the pprof and expvar listener, or nil unless
+// APP_DEBUG_ADDR is set.
+func debugServer(cfg config.Config) *http.Server {
+	if cfg.DebugAddr == "" {
+		return nil
+	}
+	return &http.Server{Addr: cfg.DebugAddr, Handler: diag.Handler()}
+}
+
+// serveDebug starts srv in the background; a nil srv is left alone.
+func serveDebug(srv *http.Server, logger *slog.Logger) {
+	if srv == nil {
+		return
+	}
+	go func() {
+		logger.Info("debug server listening", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("debug server failed", "err", err)
+		}
+	}()
+}
+
 func (a *App) Run(ctx context.Context) error {
 	go func() {
 		a.log.Info("http server listening", "addr", a.cfg.Addr)
@@ -78,35 +698,290 @@ func (a *App) Run(ctx context.Context) error {
 			a.log.Error("http server failed", "err", err)
 		}
 	}()
+	if a.cfg.GELFUDPAddr != "" && a.gelf != nil {
+		go func() {
+			if err := a.gelf.ListenUDP(ctx, a.cfg.GELFUDPAddr); err != nil {
+				a.log.Error("gelf udp listener failed", "err", err)
+			}
+		}()
+	}
+	if a.gelfSrv != nil {
+		go func() {
+			a.log.Info("gelf http server listening", "addr", a.cfg.GELFHTTPAddr)
+			if err := a.gelfSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				a.log.Error("gelf http server failed", "err", err)
+			}
+		}()
+	}
 
-	metricsTicker := time.NewTicker(a.cfg.MetricsInterval)
-	rulesTicker := time.NewTicker(a.cfg.RulesInterval)
-	logsTicker := time.NewTicker(10 * time.Second)
-	retentionTicker := time.NewTicker(6 * time.Hour)
-	defer metricsTicker.Stop()
-	defer rulesTicker.Stop()
-	defer logsTicker.Stop()
-	defer retentionTicker.Stop()
-
-	// Immediate first run
-	a.collector.Tick(ctx)
-	a.ingestor.Reconcile(ctx)
-	a.alerts.Evaluate(ctx)
-	a.retention.Run(ctx)
+	serveDebug(a.debugSrv, a.log)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	// Docker events refresh log workers and alerts as soon as a container
+	// changes instead of on the next tick.
+	for _, h := range a.hosts {
+		if h.events != nil {
+			go h.events.Run(ctx)
+		}
+		if h.updates != nil {
+			go h.updates.Run(ctx, a.cfg.ImageCheckEvery)
+		}
+		if h.clock != nil {
+			go h.clock.Run(ctx, a.cfg.ClockCheckEvery)
+		}
+	}
+	go a.checks.Run(ctx)
+	go a.sites.Run(ctx, a.cfg.FederationEvery)
+	if a.cfg.MetricsEnabled {
+		go a.scraper.Run(ctx, a.cfg.ScrapeEvery)
+	}
+	if a.files != nil {
+		go a.files.Run(ctx)
+	}
+
+	// Every subsystem runs on its own goroutine with jittered tickers, so a
+	// slow collector tick on a host with many containers delays neither the
+	// other hosts nor alerts and retention. A container change is passed on
+	// to the collectors and log ingestion; alerts are evaluated once a
+	// collector has inspected it.
+	var wg sync.WaitGroup
+	run := func(fn func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn()
+		}()
+	}
+	changed := make([]chan struct{}, 0, len(a.hosts)+1)
+	evaluate := make(chan struct{}, 1)
+	for _, h := range a.hosts {
+		c := make(chan struct{}, 1)
+		changed = append(changed, c)
+		run(func() { a.collect(ctx, h, c, evaluate) })
+	}
+	if a.demo != nil {
+		run(func() { a.demo.Run(ctx) })
+	}
+	if a.cfg.LogsEnabled {
+		c := make(chan struct{}, 1)
+		changed = append(changed, c)
+		run(func() {
+			a.reconcileLogs(ctx)
+			every(ctx, 10*time.Second, c, func() { a.reconcileLogs(ctx) })
+		})
+	}
+	if a.spill != nil {
+		run(func() {
+			a.spill.Replay(ctx)
+			every(ctx, 30*time.Second, nil, func() { a.spill.Replay(ctx) })
+		})
+	}
+	if a.cfg.AlertsEnabled {
+		run(func() { every(ctx, a.cfg.RulesInterval, evaluate, func() { a.alerts.Evaluate(ctx) }) })
+		if a.alertsFwd != nil {
+			run(func() { every(ctx, a.cfg.AlertSendEvery, nil, func() { a.alertsFwd.Run(ctx) }) })
+		}
+	}
+	run(func() {
+		a.retention.Run(ctx)
+		every(ctx, 6*time.Hour, nil, func() { a.retention.Run(ctx) })
+	})
+	run(func() {
+		a.rollup.Run(ctx)
+		every(ctx, time.Minute, nil, func() {
+			a.rollup.Run(ctx)
+			if err := a.logStats.Flush(ctx, a.db, false); err != nil {
+				a.log.Error("store log metrics", "err", err)
+			}
+		})
+	})
+	run(func() { every(ctx, a.cfg.MaintenanceEvery, nil, func() { a.maint.Run(ctx) }) })
+	run(func() { every(ctx, a.cfg.BackupInterval, nil, func() { a.backup.Run(ctx) }) })
+	if a.replica != nil {
+		run(func() { every(ctx, a.cfg.ReplicaInterval, nil, func() { a.replica.Run(ctx) }) })
+	}
+	run(func() { every(ctx, time.Minute, nil, func() { a.self.Collect(ctx) }) })
 
 	for {
 		select {
 		case <-ctx.Done():
-			_ = a.httpSrv.Shutdown(context.Background())
-			return a.db.DB().Close()
-		case <-metricsTicker.C:
-			a.collector.Tick(ctx)
-		case <-rulesTicker.C:
-			a.alerts.Evaluate(ctx)
-		case <-logsTicker.C:
-			a.ingestor.Reconcile(ctx)
-		case <-retentionTicker.C:
-			a.retention.Run(ctx)
+			wg.Wait()
+			return a.shutdown()
+		case <-a.containerChanged:
+			for _, c := range changed {
+				signal1(c)
+			}
+		case <-hup:
+			// Errors are logged; the previous configuration stays.
+			_ = a.Reload(ctx)
 		}
 	}
 }
+
+// collect runs the collector of one Docker host: an immediate inspect, which
+// fills the cache the stats samples use, then each kind of sample on its own
+// ticker. After the first inspect and those caused by a container change it
+// signals evaluate.
+func (a *App) collect(ctx context.Context, h *dockerHost, changed <-chan struct{}, evaluate chan<- struct{}) {
+	inspect := schedule.NewTicker(a.cfg.InspectEvery)
+	defer inspect.Stop()
+	var hostMetrics, stats <-chan time.Time
+	if a.cfg.MetricsEnabled {
+		t := schedule.NewTicker(a.cfg.HostMetricsEvery)
+		defer t.Stop()
+		hostMetrics = t.C
+		st := schedule.NewTicker(a.cfg.StatsEvery)
+		defer st.Stop()
+		stats = st.C
+	}
+	var diskUsage <-chan time.Time
+	if a.cfg.DiskUsageEvery > 0 && a.cfg.MetricsEnabled {
+		t := schedule.NewTicker(a.cfg.DiskUsageEvery)
+		defer t.Stop()
+		diskUsage = t.C
+	}
+	var pools <-chan time.Time
+	if a.cfg.PoolCheckEvery > 0 && a.cfg.MetricsEnabled {
+		t := schedule.NewTicker(a.cfg.PoolCheckEvery)
+		defer t.Stop()
+		pools = t.C
+	}
+
+	h.collector.Inspect(ctx)
+	signal1(evaluate)
+	if stats != nil {
+		h.collector.CollectHost(ctx)
+		h.collector.CollectStats(ctx)
+	}
+	if diskUsage != nil {
+		h.collector.CollectDiskUsage(ctx)
+	}
+	if pools != nil {
+		h.collector.CollectPools(ctx)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-inspect.C:
+			h.collector.Inspect(ctx)
+		case <-hostMetrics:
+			h.collector.CollectHost(ctx)
+		case <-stats:
+			h.collector.CollectStats(ctx)
+		case <-changed:
+			h.collector.Inspect(ctx)
+			signal1(evaluate)
+		case <-diskUsage:
+			h.collector.CollectDiskUsage(ctx)
+		case <-pools:
+			h.collector.CollectPools(ctx)
+		}
+	}
+}
+
+// every calls fn about every d and whenever trigger fires, until ctx is
+// done. A nil trigger never fires.
+func every(ctx context.Context, d time.Duration, trigger <-chan struct{}, fn func()) {
+	t := schedule.NewTicker(d)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-trigger:
+		}
+		fn()
+	}
+}
+
+// signal1 wakes the receiver of c without blocking; a wakeup already
+// pending covers this one.
+func signal1(c chan<- struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// reconcileLogs follows the logs of new containers, unless log ingestion is
+// disabled.
+func (a *App) reconcileLogs(ctx context.Context) {
+	if a.cfg.LogsEnabled {
+		a.eachHost(func(h *dockerHost) { h.ingestor.Reconcile(ctx) })
+	}
+}
+
+// logWorkers returns the number of containers whose logs are followed.
+func (a *App) logWorkers() int {
+	n := 0
+	for _, h := range a.hosts {
+		if h.ingestor != nil {
+			n += h.ingestor.Workers()
+		}
+	}
+	return n
+}
+
+// shutdown drains in-flight HTTP requests, stops log workers so their pending
+// batches are written, and only then closes the database.
+func (a *App) shutdown() error {
+	a.log.Info("shutting down", "timeout", a.cfg.ShutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := a.httpSrv.Shutdown(ctx); err != nil {
+		a.log.Warn("http drain incomplete", "err", err)
+	}
+	if a.gelfSrv != nil {
+		if err := a.gelfSrv.Shutdown(ctx); err != nil {
+			a.log.Warn("gelf http drain incomplete", "err", err)
+		}
+	}
+	if a.debugSrv != nil {
+		_ = a.debugSrv.Close()
+	}
+	if a.files != nil {
+		if err := a.files.Stop(ctx); err != nil {
+			a.log.Warn("log file tailing did not stop in time", "err", err)
+		}
+	}
+	if err := a.logSink.Stop(ctx); err != nil {
+		a.log.Warn("external log writes did not flush in time", "err", err)
+	}
+	for _, h := range a.hosts {
+		if h.ingestor != nil {
+			if err := h.ingestor.Stop(ctx); err != nil {
+				a.log.Warn("log workers did not stop in time", "docker_host", h.name, "err", err)
+			}
+		}
+		if err := h.collector.Close(ctx); err != nil {
+			a.log.Warn("metric writes did not flush in time", "docker_host", h.name, "err", err)
+		}
+	}
+	if err := a.logStats.Flush(ctx, a.db, true); err != nil {
+		a.log.Warn("store log metrics", "err", err)
+	}
+	// Forward last: the workers above hand it their final batches.
+	if a.forward != nil {
+		if err := a.forward.Stop(ctx); err != nil {
+			a.log.Warn("log forwarding did not flush in time", "err", err)
+		}
+	}
+	// Notifications cut off when the jobs were cancelled go out before the
+	// DB that records them closes, and Alertmanager learns the last state.
+	if err := a.alerts.Flush(ctx); err != nil {
+		a.log.Warn("notifications did not flush in time", "err", err)
+	}
+	if a.alertsFwd != nil {
+		a.alertsFwd.Run(ctx)
+	}
+	if a.replica != nil {
+		a.replica.Run(ctx)
+	}
+	return a.db.DB().Close()
+}
@@ -0,0 +1,140 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"dashi/internal/db"
+)
+
+const filePrefix = "dashi-"
+
+type Service struct {
+	repo *db.Repository
+	dir  string
+	keep int
+	log  *slog.Logger
+}
+
+func NewService(repo *db.Repository, dir string, keep int, logger *slog.Logger) *Service {
+	if keep <= 0 {
+		keep = 7
+	}
+	return &Service{repo: repo, dir: dir, keep: keep, log: logger}
+}
+
+func (s *Service) Enabled() bool { return s.dir != "" }
+
+// Run writes a timestamped snapshot into the backup directory and prunes
+// all but the newest snapshots.
+func (s *Service) Run(ctx context.Context) {
+	if !s.Enabled() {
+		return
+	}
+	path, err := s.Snapshot(ctx, s.dir)
+	if err != nil {
+		s.log.Error("scheduled backup failed", "err", err)
+		return
+	}
+	s.log.Info("scheduled backup written", "path", path)
+	if err := s.prune(); err != nil {
+		s.log.Warn("prune backups", "err", err)
+	}
+}
+
+// Snapshot writes a new backup file into dir and returns its path.
+func (s *Service) Snapshot(ctx context.Context, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("mkdir backup dir: %w", err)
+	}
+	path := filepath.Join(dir, filePrefix+time.Now().UTC().Format("20060102-150405")+".db")
+	if err := s.repo.BackupTo(ctx, path); err != nil {
+		_ = os.Remove(path)
+		return "", fmt.Errorf("snapshot database: %w", err)
+	}
+	return path, nil
+}
+
+func (s *Service) prune() error {
+	names, err := snapshots(s.dir)
+	if err != nil || len(names) <= s.keep {
+		return err
+	}
+	for _, name := range names[:len(names)-s.keep] {
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshots lists backup file names in dir, oldest first.
+func snapshots(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), filePrefix) && strings.HasSuffix(e.Name(), ".db") {
+			names = append(names, e.Name())
+		}
+	}
+	// Names embed a sortable UTC timestamp.
+	sort.Strings(names)
+	return names, nil
+}
+
+// RestoreLatest copies the newest snapshot in dir that passes an integrity
+// check to dbPath and returns its path, or "" when there is none.
+func RestoreLatest(dir, dbPath string) (string, error) {
+	names, err := snapshots(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	for i := len(names) - 1; i >= 0; i-- {
+		src := filepath.Join(dir, names[i])
+		if err := db.CheckIntegrity(src, false); err != nil {
+			continue
+		}
+		if err := copyFile(src, dbPath); err != nil {
+			return "", fmt.Errorf("restore %s: %w", names[i], err)
+		}
+		return src, nil
+	}
+	return "", nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := dst + ".restore"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
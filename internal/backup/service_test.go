@@ -0,0 +1,96 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dashi/internal/db"
+)
+
+func TestSnapshotProducesReadableCopyAndPrunes(t *testing.T) {
+	dir := t.TempDir()
+	sqldb, err := db.Open(filepath.Join(dir, "app.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	backupDir := filepath.Join(dir, "backups")
+	svc := NewService(repo, backupDir, 1, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// Seed an older snapshot that should be pruned.
+	if err := os.MkdirAll(backupDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(backupDir, "dashi-20000101-000000.db"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	svc.Run(context.Background())
+
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() == "dashi-20000101-000000.db" {
+		t.Fatalf("unexpected backup files: %v", entries)
+	}
+
+	snap, err := sql.Open("sqlite3", filepath.Join(backupDir, entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Close()
+	var rules int
+	if err := snap.QueryRow(`SELECT COUNT(*) FROM alert_rules`).Scan(&rules); err != nil {
+		t.Fatalf("query snapshot: %v", err)
+	}
+	if rules == 0 {
+		t.Fatal("snapshot has no seeded rules")
+	}
+}
+
+func TestRestoreLatestSkipsDamagedSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	sqldb, err := db.Open(filepath.Join(dir, "app.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	backupDir := filepath.Join(dir, "backups")
+	svc := NewService(db.NewRepository(sqldb), backupDir, 7, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	good, err := svc.Snapshot(context.Background(), backupDir)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	// A newer snapshot that is unreadable must be passed over.
+	if err := os.WriteFile(filepath.Join(backupDir, "dashi-29990101-000000.db"), []byte("garbage, not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "restored.db")
+	src, err := RestoreLatest(backupDir, dst)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if src != good {
+		t.Fatalf("restored from %q, want %q", src, good)
+	}
+	if err := db.CheckIntegrity(dst, true); err != nil {
+		t.Fatalf("restored file: %v", err)
+	}
+
+	if src, err := RestoreLatest(filepath.Join(dir, "none"), dst); err != nil || src != "" {
+		t.Fatalf("missing dir = %q, %v", src, err)
+	}
+}
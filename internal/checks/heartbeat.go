@@ -0,0 +1,86 @@
+package checks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// Heartbeat states.
+const (
+	HeartbeatNew    = "new"
+	HeartbeatUp     = "up"
+	HeartbeatDown   = "down"
+	HeartbeatFailed = "failed"
+)
+
+// HeartbeatDeadline is the latest time the next ping is expected.
+func HeartbeatDeadline(h models.Heartbeat) time.Time {
+	base := h.CreatedAt
+	if h.LastPingAt != nil {
+		base = *h.LastPingAt
+	}
+	return base.Add(time.Duration(h.PeriodSec+h.GraceSec) * time.Second)
+}
+
+// HeartbeatState is "failed" when the newest ping reported a failure, "down"
+// once the deadline passed, "new" before the first ping and "up" otherwise.
+func HeartbeatState(h models.Heartbeat, now time.Time) string {
+	switch {
+	case h.LastFailAt != nil && (h.LastPingAt == nil || h.LastFailAt.After(*h.LastPingAt)):
+		return HeartbeatFailed
+	case now.After(HeartbeatDeadline(h)):
+		return HeartbeatDown
+	case h.LastPingAt == nil:
+		return HeartbeatNew
+	}
+	return HeartbeatUp
+}
+
+// NormalizeHeartbeat validates a heartbeat definition; the grace period
+// defaults to a tenth of the period, at least a minute. A token, when
+// given, is 8 to 64 letters, digits, '-' or '_', as Uptime Kuma push
+// tokens are.
+func NormalizeHeartbeat(h *models.Heartbeat) error {
+	h.Name = strings.TrimSpace(h.Name)
+	h.Token = strings.TrimSpace(h.Token)
+	if h.GraceSec == 0 {
+		h.GraceSec = max(h.PeriodSec/10, 60)
+	}
+	switch {
+	case h.Name == "":
+		return errors.New("name is required")
+	case h.PeriodSec < 60:
+		return errors.New("period must be at least 60 seconds")
+	case h.GraceSec < 0:
+		return errors.New("grace must not be negative")
+	case h.Token != "" && !validToken(h.Token):
+		return errors.New("token must be 8 to 64 letters, digits, '-' or '_'")
+	}
+	return nil
+}
+
+func validToken(token string) bool {
+	if len(token) < 8 || len(token) > 64 {
+		return false
+	}
+	for _, r := range token {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// NewHeartbeatToken returns the unguessable part of a ping URL.
+func NewHeartbeatToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
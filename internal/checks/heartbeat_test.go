@@ -0,0 +1,50 @@
+package checks
+
+import (
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestHeartbeatState(t *testing.T) {
+	created := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		ts := created.Add(d)
+		return &ts
+	}
+	h := models.Heartbeat{PeriodSec: 3600, GraceSec: 300, CreatedAt: created}
+	cases := []struct {
+		name       string
+		ping, fail *time.Time
+		now        time.Duration
+		want       string
+	}{
+		{"never pinged", nil, nil, time.Hour, HeartbeatNew},
+		{"never pinged past deadline", nil, nil, time.Hour + 6*time.Minute, HeartbeatDown},
+		{"pinged", at(time.Hour), nil, 2 * time.Hour, HeartbeatUp},
+		{"within grace", at(time.Hour), nil, 2*time.Hour + 4*time.Minute, HeartbeatUp},
+		{"late", at(time.Hour), nil, 2*time.Hour + 6*time.Minute, HeartbeatDown},
+		{"failed", at(time.Hour), at(2 * time.Hour), 2 * time.Hour, HeartbeatFailed},
+		{"recovered after failure", at(3 * time.Hour), at(2 * time.Hour), 3 * time.Hour, HeartbeatUp},
+	}
+	for _, tc := range cases {
+		h.LastPingAt, h.LastFailAt = tc.ping, tc.fail
+		if got := HeartbeatState(h, created.Add(tc.now)); got != tc.want {
+			t.Errorf("%s: state = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizeHeartbeat(t *testing.T) {
+	h := models.Heartbeat{Name: " backup ", PeriodSec: 86400, Token: " kuma_Push-01 "}
+	if err := NormalizeHeartbeat(&h); err != nil || h.Name != "backup" || h.GraceSec != 8640 || h.Token != "kuma_Push-01" {
+		t.Fatalf("normalized = %+v, err %v", h, err)
+	}
+	for _, token := range []string{"short", "has/slash1", "has space1"} {
+		h := models.Heartbeat{Name: "backup", PeriodSec: 3600, Token: token}
+		if err := NormalizeHeartbeat(&h); err == nil {
+			t.Fatalf("token %q accepted", token)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Package checks runs the user-defined HTTP(S) probes and records their
+// latency and availability, and judges inbound heartbeat monitors.
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+const (
+	DefaultInterval = 60 * time.Second
+	DefaultTimeout  = 10 * time.Second
+	minInterval     = 10 * time.Second
+	// bodyLimit bounds how much of a response is searched for ExpectBody.
+	bodyLimit = 1 << 20
+)
+
+type Runner struct {
+	repo *db.Repository
+	http *http.Client
+	log  *slog.Logger
+	now  func() time.Time
+
+	mu       sync.Mutex
+	last     map[int64]time.Time
+	inflight map[int64]bool
+}
+
+func NewRunner(repo *db.Repository, logger *slog.Logger) *Runner {
+	return &Runner{repo: repo, http: &http.Client{}, log: logger, now: time.Now, last: map[int64]time.Time{}, inflight: map[int64]bool{}}
+}
+
+// Run starts due probes every few seconds until ctx is done. Each probe
+// runs on its own goroutine, so a slow endpoint does not delay the others.
+func (r *Runner) Run(ctx context.Context) {
+	t := time.NewTicker(5 * time.Second)
+	defer t.Stop()
+	for {
+		r.RunDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// RunDue starts the enabled checks whose interval has passed since their
+// last probe and that are not still running.
+func (r *Runner) RunDue(ctx context.Context) {
+	checks, err := r.repo.ListChecks(ctx)
+	if err != nil {
+		r.log.Warn("load checks", "err", err)
+		return
+	}
+	now := r.now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range checks {
+		if !c.Enabled || r.inflight[c.ID] || now.Sub(r.last[c.ID]) < time.Duration(c.IntervalSec)*time.Second {
+			continue
+		}
+		r.last[c.ID] = now
+		r.inflight[c.ID] = true
+		go r.run(ctx, c)
+	}
+}
+
+func (r *Runner) run(ctx context.Context, c models.Check) {
+	defer func() {
+		r.mu.Lock()
+		delete(r.inflight, c.ID)
+		r.mu.Unlock()
+	}()
+	res := Probe(ctx, r.http, c)
+	if ctx.Err() != nil {
+		return
+	}
+	if err := r.repo.InsertCheckResult(ctx, res); err != nil {
+		r.log.Error("save check result", "check", c.Name, "err", err)
+	}
+}
+
+// Probe requests c.URL once and judges the response.
+func Probe(ctx context.Context, hc *http.Client, c models.Check) models.CheckResult {
+	res := models.CheckResult{CheckID: c.ID, TS: time.Now().UTC()}
+	timeout := time.Duration(c.TimeoutSec) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, c.Method, c.URL, nil)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	req.Header.Set("User-Agent", "dashi-check")
+	start := time.Now()
+	resp, err := hc.Do(req)
+	if err != nil {
+		res.LatencyMS = time.Since(start).Milliseconds()
+		res.Error = probeError(err)
+		return res
+	}
+	defer resp.Body.Close()
+	res.StatusCode = resp.StatusCode
+	var body []byte
+	if c.ExpectBody != "" {
+		body, err = io.ReadAll(io.LimitReader(resp.Body, bodyLimit))
+	}
+	res.LatencyMS = time.Since(start).Milliseconds()
+	switch {
+	case err != nil:
+		res.Error = "read body: " + probeError(err)
+	case c.ExpectStatus != 0 && resp.StatusCode != c.ExpectStatus:
+		res.Error = fmt.Sprintf("status %d, want %d", resp.StatusCode, c.ExpectStatus)
+	case c.ExpectStatus == 0 && resp.StatusCode >= 400:
+		res.Error = fmt.Sprintf("status %d", resp.StatusCode)
+	case c.ExpectBody != "" && !strings.Contains(string(body), c.ExpectBody):
+		res.Error = fmt.Sprintf("body does not contain %q", c.ExpectBody)
+	default:
+		res.OK = true
+	}
+	return res
+}
+
+// probeError drops the method and URL net/http prefixes errors with; the
+// check already names them.
+func probeError(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		return ue.Err.Error()
+	}
+	return err.Error()
+}
+
+// Normalize fills in defaults and validates a check definition.
+func Normalize(c *models.Check) error {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Method = strings.ToUpper(strings.TrimSpace(c.Method))
+	if c.Method == "" {
+		c.Method = http.MethodGet
+	}
+	if c.IntervalSec == 0 {
+		c.IntervalSec = int(DefaultInterval.Seconds())
+	}
+	if c.TimeoutSec == 0 {
+		c.TimeoutSec = int(DefaultTimeout.Seconds())
+	}
+	u, err := url.Parse(strings.TrimSpace(c.URL))
+	switch {
+	case c.Name == "":
+		return errors.New("name is required")
+	case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+		return errors.New("url must be an absolute http or https URL")
+	case c.Method != http.MethodGet && c.Method != http.MethodHead && c.Method != http.MethodPost && c.Method != http.MethodOptions:
+		return fmt.Errorf("unsupported method %q", c.Method)
+	case c.ExpectStatus != 0 && (c.ExpectStatus < 100 || c.ExpectStatus > 599):
+		return errors.New("expect_status must be an HTTP status code")
+	case c.IntervalSec < int(minInterval.Seconds()):
+		return fmt.Errorf("interval must be at least %s", minInterval)
+	case c.TimeoutSec < 1 || c.TimeoutSec > c.IntervalSec:
+		return errors.New("timeout must be between 1 second and the interval")
+	}
+	c.URL = u.String()
+	return nil
+}
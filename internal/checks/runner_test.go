@@ -0,0 +1,70 @@
+package checks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(1500 * time.Millisecond)
+		case "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		name  string
+		check models.Check
+		ok    bool
+		err   string
+	}{
+		{"any success", models.Check{URL: srv.URL + "/"}, true, ""},
+		{"body matches", models.Check{URL: srv.URL + "/", ExpectStatus: 200, ExpectBody: `"ok"`}, true, ""},
+		{"body missing", models.Check{URL: srv.URL + "/", ExpectBody: "healthy"}, false, "body does not contain"},
+		{"server error", models.Check{URL: srv.URL + "/down"}, false, "status 503"},
+		{"expected status", models.Check{URL: srv.URL + "/down", ExpectStatus: 503}, true, ""},
+		{"timeout", models.Check{URL: srv.URL + "/slow", TimeoutSec: 1}, false, "timeout"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.check.Method = http.MethodGet
+			res := Probe(context.Background(), srv.Client(), tc.check)
+			if res.OK != tc.ok || !strings.Contains(res.Error, tc.err) {
+				t.Fatalf("result = %+v, want ok=%v error containing %q", res, tc.ok, tc.err)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	c := models.Check{Name: " web ", URL: "https://example.com/health"}
+	if err := Normalize(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "web" || c.Method != "GET" || c.IntervalSec != 60 || c.TimeoutSec != 10 {
+		t.Fatalf("normalized = %+v", c)
+	}
+	for _, bad := range []models.Check{
+		{Name: "x", URL: "ftp://example.com"},
+		{Name: "x", URL: "/relative"},
+		{Name: "x", URL: "http://example.com", Method: "DELETE"},
+		{Name: "x", URL: "http://example.com", IntervalSec: 5},
+		{Name: "x", URL: "http://example.com", IntervalSec: 30, TimeoutSec: 31},
+		{URL: "http://example.com"},
+	} {
+		if err := Normalize(&bad); err == nil {
+			t.Errorf("Normalize(%+v) accepted", bad)
+		}
+	}
+}
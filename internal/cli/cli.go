@@ -0,0 +1,229 @@
+// Package cli implements the client subcommands of the dashi binary, which
+// query a running dashi over its JSON API, e.g. for a quick look over SSH.
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+)
+
+const defaultURL = "http://localhost:8080"
+
+type command struct {
+	summary string
+	run     func(ctx context.Context, c *client, args []string, stdout io.Writer) error
+	// local commands work on a database rather than a running dashi and
+	// take no --url or --token.
+	local bool
+}
+
+var commands = map[string]command{
+	"logs":    {"print stored log entries, or follow new ones with -f", runLogs, false},
+	"alerts":  {"list recent alerts", runAlerts, false},
+	"top":     {"list running services by CPU and memory use", runTop, false},
+	"seed":    {"write generated services, metrics, logs and alerts into a database", runSeed, true},
+	"loadgen": {"push generated containers, metrics and logs to a dashi's ingest API", runLoadgen, false},
+}
+
+// IsCommand reports whether name is a client subcommand.
+func IsCommand(name string) bool {
+	_, ok := commands[name]
+	return ok || name == "help" || name == "-h" || name == "--help"
+}
+
+// Run runs the subcommand named by args[0] and returns the exit code.
+func Run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage(stderr)
+		if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			return 0
+		}
+		return 2
+	}
+	c, err := newClient(args[0], stderr, !cmd.local)
+	if err != nil {
+		fmt.Fprintln(stderr, "dashi:", err)
+		return 2
+	}
+	if err := cmd.run(ctx, c, args[1:], stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		if ctx.Err() != nil {
+			return 130
+		}
+		fmt.Fprintf(stderr, "dashi %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: dashi [command] [flags]")
+	fmt.Fprintln(w, "\nWithout a command dashi runs the server. Client commands:")
+	for _, name := range []string{"logs", "alerts", "top"} {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w, "\nFor development and load tests:")
+	for _, name := range []string{"seed", "loadgen"} {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w, "\nClient commands take --url (DASHI_URL, default "+defaultURL+") and")
+	fmt.Fprintln(w, "--token (DASHI_TOKEN or DASHI_TOKEN_FILE), sent as a bearer token.")
+	fmt.Fprintln(w, "Run \"dashi <command> -h\" for its flags.")
+}
+
+// client calls the JSON API of a dashi instance.
+type client struct {
+	base  string
+	token string
+	http  *http.Client
+	// flags holds --url, --token and the subcommand's own flags.
+	flags *flag.FlagSet
+}
+
+// newClient defines the connection flags, unless remote is false; the
+// subcommand adds its own to the client's FlagSet before calling parse.
+func newClient(name string, stderr io.Writer, remote bool) (*client, error) {
+	fs := flag.NewFlagSet("dashi "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	c := &client{http: &http.Client{}, flags: fs}
+	if !remote {
+		return c, nil
+	}
+	base := os.Getenv("DASHI_URL")
+	if base == "" {
+		base = defaultURL
+	}
+	token, err := envToken()
+	if err != nil {
+		return nil, err
+	}
+	fs.StringVar(&c.base, "url", base, "dashi base URL")
+	fs.StringVar(&c.token, "token", token, "API token, sent as \"Authorization: Bearer\"")
+	return c, nil
+}
+
+// envToken reads DASHI_TOKEN, or the file named by DASHI_TOKEN_FILE.
+func envToken() (string, error) {
+	token, file := os.Getenv("DASHI_TOKEN"), os.Getenv("DASHI_TOKEN_FILE")
+	if file == "" {
+		return token, nil
+	}
+	if token != "" {
+		return "", errors.New("DASHI_TOKEN and DASHI_TOKEN_FILE are both set")
+	}
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("read DASHI_TOKEN_FILE: %w", err)
+	}
+	return strings.TrimRight(string(raw), "\r\n"), nil
+}
+
+// parse parses the subcommand's flags, including --url and --token.
+func (c *client) parse(args []string) error {
+	if err := c.flags.Parse(args); err != nil {
+		return err
+	}
+	if c.flags.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", c.flags.Arg(0))
+	}
+	c.base = strings.TrimRight(c.base, "/")
+	return nil
+}
+
+func (c *client) request(ctx context.Context, path string, q url.Values) (*http.Response, error) {
+	u := c.base + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req)
+}
+
+func (c *client) do(req *http.Request) (*http.Response, error) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode/100 != 2 {
+		defer res.Body.Close()
+		var apiErr api.Error
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			return nil, fmt.Errorf("%s: %s", res.Status, apiErr.Error)
+		}
+		if msg := strings.TrimSpace(string(body)); msg != "" {
+			return nil, fmt.Errorf("%s: %s", res.Status, msg)
+		}
+		return nil, errors.New(res.Status)
+	}
+	return res, nil
+}
+
+// get decodes the JSON response of a GET request into out.
+func (c *client) get(ctx context.Context, path string, q url.Values, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	res, err := c.request(ctx, path, q)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// post sends body as JSON and discards the response.
+func (c *client) post(ctx context.Context, path string, body any) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.base+path, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	return nil
+}
+
+// setIf adds a query parameter when the value is not empty.
+func setIf(q url.Values, key, value string) {
+	if value != "" {
+		q.Set(key, value)
+	}
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/db"
+	"dashi/internal/logs"
+	"dashi/internal/web"
+)
+
+func TestRunQueriesAPIWithToken(t *testing.T) {
+	ts := time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/logs", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Encode(); got != "level=error&limit=100&range=30m0s&service=api" {
+			t.Errorf("logs query = %q", got)
+		}
+		_ = json.NewEncoder(w).Encode(api.Logs{Items: []api.LogEntry{
+			{TS: ts.Add(time.Minute), ServiceID: "api", Level: "ERROR", Message: "second"},
+			{TS: ts, ServiceID: "api", Level: "ERROR", Message: "first", RepeatCount: 3},
+		}})
+	})
+	mux.HandleFunc("/api/v1/services", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(api.Services{Items: []api.ServiceUsage{
+			{Name: "db", Host: "local", Status: "running", CPUPct: 12.34, MemUsedBytes: 512 << 20, RestartCount: 1},
+		}})
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(api.Error{Error: "bad token"})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("DASHI_URL", srv.URL)
+	t.Setenv("DASHI_TOKEN", "s3cret")
+
+	var stdout, stderr bytes.Buffer
+	code := Run(context.Background(), []string{"logs", "--service", "api", "--level", "error", "--since", "30m"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("logs exit code = %d, stderr %q", code, stderr.String())
+	}
+	want := "2026-10-15T09:30:00Z ERROR api first (x3)\n2026-10-15T09:31:00Z ERROR api second\n"
+	if stdout.String() != want {
+		t.Fatalf("logs output = %q, want %q", stdout.String(), want)
+	}
+
+	stdout.Reset()
+	if code := Run(context.Background(), []string{"top"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("top exit code = %d, stderr %q", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "12.3%") || !strings.Contains(stdout.String(), "512.0 MiB") {
+		t.Fatalf("top output = %q", stdout.String())
+	}
+
+	stderr.Reset()
+	if code := Run(context.Background(), []string{"alerts", "--token", "wrong"}, &stdout, &stderr); code != 1 {
+		t.Fatalf("alerts with a wrong token exit code = %d", code)
+	}
+	if !strings.Contains(stderr.String(), "401 Unauthorized: bad token") {
+		t.Fatalf("stderr = %q", stderr.String())
+	}
+}
+
+func TestSeedAndLoadgen(t *testing.T) {
+	path := t.TempDir() + "/app.db"
+	var stdout, stderr bytes.Buffer
+	code := Run(context.Background(), []string{"seed", "--db", path, "--services", "4", "--history", "10m", "--interval", "1m", "--alerts", "2"}, &stdout, &stderr)
+	if code != 0 || !strings.HasPrefix(stdout.String(), "wrote 4 containers, 55 samples, 600 log lines") {
+		t.Fatalf("seed exit code = %d, stdout %q, stderr %q", code, stdout.String(), stderr.String())
+	}
+
+	sqldb, err := db.Open(path)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	repo := db.NewRepository(sqldb)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sink := logs.NewSink(repo, logger, logs.Options{})
+	srv := httptest.NewServer(web.NewServer(repo, nil, nil, logger, web.Options{LogSink: sink, IngestToken: "s3cret"}).Routes())
+	t.Cleanup(srv.Close)
+
+	stdout.Reset()
+	code = Run(context.Background(), []string{"loadgen", "--url", srv.URL, "--token", "s3cret", "--services", "2", "--rate", "20", "--interval", "200ms", "--duration", "700ms"}, &stdout, &stderr)
+	if code != 0 || !strings.HasPrefix(stdout.String(), "pushed ") || !strings.Contains(stdout.String(), " 0 failed") {
+		t.Fatalf("loadgen exit code = %d, stdout %q, stderr %q", code, stdout.String(), stderr.String())
+	}
+	if err := sink.Stop(context.Background()); err != nil {
+		t.Fatalf("stop sink: %v", err)
+	}
+	rows, err := repo.ListServicesWithHealth(context.Background(), 0, 0, 10, false, "loadgen", nil)
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("loadgen services = %+v, err %v", rows, err)
+	}
+}
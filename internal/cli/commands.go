@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dashi/internal/api"
+)
+
+func runLogs(ctx context.Context, c *client, args []string, stdout io.Writer) error {
+	fs := c.flags
+	service := fs.String("service", "", "service IDs, comma-separated")
+	host := fs.String("host", "", "Docker host")
+	level := fs.String("level", "", "level, e.g. error")
+	stream := fs.String("stream", "", "stream, e.g. stderr")
+	query := fs.String("q", "", "text the message contains")
+	labels := fs.String("labels", "", "label selector, e.g. env=prod")
+	since := fs.Duration("since", time.Hour, "how far back to look")
+	limit := fs.Int("limit", 100, "most entries to print, at most 1000")
+	follow := fs.Bool("f", false, "keep printing new entries as they are stored")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+	q := url.Values{}
+	setIf(q, "service", *service)
+	setIf(q, "host", *host)
+	setIf(q, "level", *level)
+	setIf(q, "stream", *stream)
+	setIf(q, "q", *query)
+	setIf(q, "labels", *labels)
+
+	history := url.Values{"range": {since.String()}, "limit": {strconv.Itoa(*limit)}}
+	for k, v := range q {
+		history[k] = v
+	}
+	var out api.Logs
+	if err := c.get(ctx, "/api/v1/logs", history, &out); err != nil {
+		return err
+	}
+	// The API returns the newest entries first; print them like a log.
+	for i := len(out.Items) - 1; i >= 0; i-- {
+		printLog(stdout, out.Items[i])
+	}
+	if !*follow {
+		return nil
+	}
+	return c.followLogs(ctx, q, stdout)
+}
+
+// followLogs prints the entries of the /api/v1/logs/stream events until
+// the stream ends or ctx is done.
+func (c *client) followLogs(ctx context.Context, q url.Values, stdout io.Writer) error {
+	res, err := c.request(ctx, "/api/v1/logs/stream", q)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	lines := bufio.NewScanner(res.Body)
+	lines.Buffer(make([]byte, 64*1024), 1024*1024)
+	var event string
+	var data []string
+	for lines.Scan() {
+		line := lines.Text()
+		switch {
+		case line == "":
+			if err := printEvent(stdout, event, strings.Join(data, "\n")); err != nil {
+				return err
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func printEvent(w io.Writer, event, data string) error {
+	switch event {
+	case "log":
+		var e api.LogEntry
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			return fmt.Errorf("decode log event: %w", err)
+		}
+		printLog(w, e)
+	case "skipped":
+		fmt.Fprintf(w, "... %s entries skipped\n", data)
+	}
+	return nil
+}
+
+func printLog(w io.Writer, e api.LogEntry) {
+	level := e.Level
+	if level == "" {
+		level = "-"
+	}
+	msg := e.Message
+	if e.RepeatCount > 1 {
+		msg += fmt.Sprintf(" (x%d)", e.RepeatCount)
+	}
+	fmt.Fprintf(w, "%s %-5s %s %s\n", e.TS.UTC().Format(time.RFC3339), level, e.ServiceID, msg)
+}
+
+func runAlerts(ctx context.Context, c *client, args []string, stdout io.Writer) error {
+	fs := c.flags
+	since := fs.Duration("since", 24*time.Hour, "list alerts started within this long")
+	status := fs.String("status", "", "only firing or recovered alerts")
+	limit := fs.Int("limit", 100, "most alerts to list, at most 500")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+	q := url.Values{"range": {since.String()}, "limit": {strconv.Itoa(*limit)}}
+	setIf(q, "status", *status)
+	var out api.Alerts
+	if err := c.get(ctx, "/api/v1/alerts", q, &out); err != nil {
+		return err
+	}
+	if len(out.Items) == 0 {
+		fmt.Fprintf(stdout, "no alerts in the last %s\n", out.Range)
+		return nil
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STARTED\tSTATUS\tDURATION\tRULE\tSUMMARY")
+	now := time.Now()
+	for _, a := range out.Items {
+		end := now
+		if a.Ended != nil {
+			end = *a.Ended
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", a.Started.UTC().Format(time.RFC3339), a.Status,
+			end.Sub(a.Started).Truncate(time.Second), a.Rule, a.Summary)
+	}
+	return tw.Flush()
+}
+
+func runTop(ctx context.Context, c *client, args []string, stdout io.Writer) error {
+	fs := c.flags
+	host := fs.String("host", "", "only services on this Docker host")
+	labels := fs.String("labels", "", "label selector, e.g. env=prod")
+	limit := fs.Int("limit", 20, "most services to list, at most 200")
+	all := fs.Bool("all", false, "include stopped and missing containers")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+	q := url.Values{"limit": {strconv.Itoa(*limit)}}
+	setIf(q, "host", *host)
+	setIf(q, "labels", *labels)
+	if *all {
+		q.Set("include_missing", "1")
+	}
+	var out api.Services
+	if err := c.get(ctx, "/api/v1/services", q, &out); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tHOST\tSTATUS\tCPU\tMEMORY\tRESTARTS")
+	for _, s := range out.Items {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.1f%%\t%s\t%d\n", s.Name, s.Host, s.Status, s.CPUPct, formatBytes(s.MemUsedBytes), s.RestartCount)
+	}
+	return tw.Flush()
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
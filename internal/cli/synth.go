@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/db"
+	"dashi/internal/models"
+	"dashi/internal/synth"
+)
+
+// runSeed writes a generated fleet with its history into a database, for
+// developing the UI and testing queries at volume. It does not need a
+// running dashi.
+func runSeed(ctx context.Context, c *client, args []string, stdout io.Writer) error {
+	fs := c.flags
+	path := os.Getenv("APP_DB_PATH")
+	if path == "" {
+		path = "./data/app.db"
+	}
+	dbPath := fs.String("db", path, "SQLite database file (APP_DB_PATH), or a postgres:// URL")
+	logsPath := fs.String("logs-db", os.Getenv("APP_LOGS_DB_PATH"), "separate SQLite logs database (APP_LOGS_DB_PATH)")
+	host := fs.String("host", "demo", "Docker host the services appear on")
+	services := fs.Int("services", 12, "number of services")
+	replicas := fs.Int("replicas", 1, "containers per service")
+	span := fs.Duration("history", 24*time.Hour, "how far back samples, logs and alerts go")
+	interval := fs.Duration("interval", 10*time.Second, "time between samples")
+	logsPerMin := fs.Int("logs-per-min", 60, "log lines per minute across all services")
+	alerts := fs.Int("alerts", 20, "number of alerts, the newest three firing")
+	seed := fs.Uint64("seed", 1, "random seed; the same seed generates the same data")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+	if *interval <= 0 || *span < 0 || *services <= 0 || *replicas <= 0 {
+		return errors.New("--interval, --services and --replicas must be positive")
+	}
+
+	var sqldb *sql.DB
+	var err error
+	if strings.HasPrefix(*dbPath, "postgres://") || strings.HasPrefix(*dbPath, "postgresql://") {
+		sqldb, err = db.OpenPostgres(*dbPath)
+	} else {
+		sqldb, err = db.OpenWithLogs(*dbPath, *logsPath)
+	}
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer sqldb.Close()
+	if err := db.Migrate(sqldb); err != nil {
+		return err
+	}
+	repo := db.NewRepository(sqldb)
+
+	start, now := time.Now(), time.Now().UTC()
+	fleet := synth.NewFleet(synth.Options{Host: *host, Services: *services, Replicas: *replicas, Seed: *seed}, now)
+	n, err := fleet.Seed(ctx, repo, synth.History{Span: *span, Interval: *interval, LogsPerMinute: *logsPerMin, Alerts: *alerts}, now)
+	fmt.Fprintf(stdout, "wrote %d containers, %d samples, %d log lines and %d alerts in %s\n",
+		n.Containers, n.Samples, n.Logs, n.Alerts, time.Since(start).Round(time.Millisecond))
+	return err
+}
+
+// runLoadgen pushes a generated fleet's containers, samples and log lines
+// to a running dashi's ingest API, as an agent would, at a configurable
+// rate, and reports how long the pushes took.
+func runLoadgen(ctx context.Context, c *client, args []string, stdout io.Writer) error {
+	fs := c.flags
+	host := fs.String("host", "loadgen", "agent host name the services appear on")
+	services := fs.Int("services", 20, "number of services")
+	replicas := fs.Int("replicas", 1, "containers per service")
+	rate := fs.Int("rate", 100, "log lines per second")
+	interval := fs.Duration("interval", 10*time.Second, "time between pushes of samples and log lines")
+	duration := fs.Duration("duration", 0, "how long to run; 0 runs until interrupted")
+	seed := fs.Uint64("seed", 1, "random seed")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+	if *interval <= 0 || *services <= 0 || *replicas <= 0 || *rate < 0 {
+		return errors.New("--interval, --services and --replicas must be positive")
+	}
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	fleet := synth.NewFleet(synth.Options{Host: *host, Services: *services, Replicas: *replicas, Seed: *seed}, time.Now())
+	var containers api.IngestContainers
+	containers.Host = *host
+	for _, st := range fleet.Stacks {
+		containers.Services = append(containers.Services, api.ServiceDetailFrom(st.Service, st.Containers))
+	}
+	var st loadStats
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		fmt.Fprintf(stdout, "pushed %d samples and %d log lines in %s (%.0f lines/s), %d requests averaging %s, %d failed\n",
+			st.samples, st.lines, elapsed.Round(time.Second), float64(st.lines)/max(elapsed.Seconds(), 1),
+			st.requests, st.avg(), st.failed)
+	}()
+	if err := st.push(ctx, c, "/api/ingest/containers", containers); err != nil {
+		return err
+	}
+
+	t := time.NewTicker(*interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil
+			}
+			return ctx.Err()
+		case now := <-t.C:
+			_, samples := fleet.Sample(now, *interval)
+			if err := st.push(ctx, c, "/api/ingest/metrics", api.IngestMetrics{Host: *host, Containers: api.ContainerMetricsFrom(samples)}); err == nil {
+				st.samples += len(samples)
+			}
+			entries := fleet.Logs(now, *interval, int(float64(*rate)*interval.Seconds()))
+			for len(entries) > 0 {
+				batch := entries[:min(len(entries), 1000)]
+				entries = entries[len(batch):]
+				if err := st.push(ctx, c, "/api/ingest/logs", ingestLogs(batch)); err == nil {
+					st.lines += len(batch)
+				}
+			}
+		}
+	}
+}
+
+// loadStats counts what loadgen pushed.
+type loadStats struct {
+	samples, lines   int
+	requests, failed int
+	took             time.Duration
+}
+
+// push posts body and counts the request. Only the first push's error is
+// returned: later ones are counted and the load goes on.
+func (s *loadStats) push(ctx context.Context, c *client, path string, body any) error {
+	start := time.Now()
+	err := c.post(ctx, path, body)
+	s.requests++
+	s.took += time.Since(start)
+	if err != nil {
+		s.failed++
+		if s.requests == 1 {
+			return fmt.Errorf("push to %s: %w", path, err)
+		}
+	}
+	return err
+}
+
+func (s *loadStats) avg() time.Duration {
+	if s.requests == 0 {
+		return 0
+	}
+	return (s.took / time.Duration(s.requests)).Round(time.Microsecond)
+}
+
+func ingestLogs(entries []models.LogEntry) api.IngestLogs {
+	out := api.IngestLogs{Entries: make([]api.IngestLogEntry, 0, len(entries))}
+	for _, e := range entries {
+		ts := e.TS
+		out.Entries = append(out.Entries, api.IngestLogEntry{TS: &ts, ContainerID: e.ContainerID, Level: e.Level, Stream: e.Stream, Message: e.Message})
+	}
+	return out
+}
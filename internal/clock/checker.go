@@ -0,0 +1,90 @@
+// Package clock compares clocks with a reference and records their offsets,
+// so alert rules can catch drift before it garbles log ordering and charts.
+package clock
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/docker"
+	"dashi/internal/models"
+)
+
+// Offset sources recorded in models.ClockOffset.
+const (
+	SourceNTP    = "ntp"
+	SourceDocker = "docker"
+)
+
+type Checker struct {
+	repo *db.Repository
+	dc   *docker.Client
+	log  *slog.Logger
+	host string
+	ntp  string
+	now  func() time.Time
+}
+
+// NewChecker compares the clock of the daemon behind dc with dashi's and,
+// when ntpServer ("host" or "host:port") is set, dashi's clock with that
+// NTP server.
+func NewChecker(repo *db.Repository, dc *docker.Client, logger *slog.Logger, dockerHost, ntpServer string) *Checker {
+	return &Checker{repo: repo, dc: dc, log: logger, host: dockerHost, ntp: ntpServer, now: time.Now}
+}
+
+// Run checks immediately and then every interval until ctx is done.
+func (c *Checker) Run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		c.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Check records one offset per source. A daemon that does not allow /info,
+// e.g. behind a socket proxy, is skipped without recording an error.
+func (c *Checker) Check(ctx context.Context) {
+	if c.ntp != "" {
+		off, err := queryNTP(ctx, c.ntp)
+		c.save(ctx, SourceNTP, off, err)
+	}
+	off, err := c.daemonOffset(ctx)
+	if errors.Is(err, docker.ErrForbidden) {
+		return
+	}
+	c.save(ctx, SourceDocker, off, err)
+}
+
+// daemonOffset compares the daemon's clock with the midpoint of the /info
+// round trip, which bounds the error to half the request latency.
+func (c *Checker) daemonOffset(ctx context.Context) (time.Duration, error) {
+	start := c.now()
+	t, err := c.dc.SystemTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+	end := c.now()
+	return t.Sub(start.Add(end.Sub(start) / 2)), nil
+}
+
+func (c *Checker) save(ctx context.Context, source string, off time.Duration, err error) {
+	o := models.ClockOffset{Host: c.host, Source: source, OffsetMS: float64(off) / float64(time.Millisecond), CheckedAt: c.now().UTC()}
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		c.log.Warn("clock comparison failed", "source", source, "err", err)
+		o.Error = err.Error()
+	}
+	if err := c.repo.SaveClockOffset(ctx, o); err != nil {
+		c.log.Warn("save clock offset", "source", source, "err", err)
+	}
+}
@@ -0,0 +1,58 @@
+package clock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/docker"
+)
+
+func TestCheckRecordsDaemonAndNTPOffsets(t *testing.T) {
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/info" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"SystemTime":%q}`, time.Now().Add(-3*time.Second).Format(time.RFC3339Nano))
+	}))
+	defer daemon.Close()
+	dc, err := docker.Dial("tcp://"+strings.TrimPrefix(daemon.URL, "http://"), "")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+
+	c := NewChecker(repo, dc, slog.New(slog.NewTextHandler(io.Discard, nil)), "nas", fakeNTP(t, -time.Second, ""))
+	c.Check(ctx)
+
+	offsets, err := repo.ListClockOffsets(ctx)
+	if err != nil {
+		t.Fatalf("list offsets: %v", err)
+	}
+	if len(offsets) != 2 {
+		t.Fatalf("offsets = %+v", offsets)
+	}
+	for _, o := range offsets {
+		want := map[string]float64{SourceDocker: -3000, SourceNTP: 1000}[o.Source]
+		if o.Host != "nas" || o.Error != "" || o.OffsetMS < want-100 || o.OffsetMS > want+100 {
+			t.Fatalf("offset = %+v, want about %v ms", o, want)
+		}
+	}
+}
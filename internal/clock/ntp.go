@@ -0,0 +1,86 @@
+package clock
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// ntpEpochOffset is the number of seconds between 1900, the NTP epoch,
+	// and 1970.
+	ntpEpochOffset = 2208988800
+	ntpTimeout     = 5 * time.Second
+)
+
+// queryNTP asks an NTP server for the time once (SNTP, RFC 4330) and
+// returns the offset of the local clock, positive when it is ahead of the
+// server.
+func queryNTP(ctx context.Context, server string) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(ntpTimeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return 0, err
+	}
+
+	req := make([]byte, 48)
+	req[0] = 0x23 // leap indicator 0, version 4, mode 3 (client)
+	t1 := time.Now()
+	binary.BigEndian.PutUint64(req[40:], toNTP(t1))
+	if _, err := conn.Write(req); err != nil {
+		return 0, fmt.Errorf("ntp %s: %w", server, err)
+	}
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	t4 := time.Now()
+	if err != nil {
+		return 0, fmt.Errorf("ntp %s: %w", server, err)
+	}
+	switch {
+	case n < 48:
+		return 0, fmt.Errorf("ntp %s: short reply", server)
+	case resp[0]&0x7 != 4:
+		return 0, fmt.Errorf("ntp %s: not a server reply", server)
+	case resp[1] == 0:
+		// Stratum 0 is a "kiss-o'-death", e.g. RATE when polled too often.
+		return 0, fmt.Errorf("ntp %s: refused with %q", server, bytes.TrimRight(resp[12:16], "\x00"))
+	case !bytes.Equal(resp[24:32], req[40:48]):
+		return 0, fmt.Errorf("ntp %s: reply does not match the request", server)
+	}
+	t2 := fromNTP(binary.BigEndian.Uint64(resp[32:]))
+	t3 := fromNTP(binary.BigEndian.Uint64(resp[40:]))
+	// The negated NTP offset: ((t2-t1) + (t3-t4)) / 2 is how far the server
+	// is ahead.
+	return (t1.Sub(t2) + t4.Sub(t3)) / 2, nil
+}
+
+func toNTP(t time.Time) uint64 {
+	sec := uint64(t.Unix()+ntpEpochOffset) & 0xffffffff
+	frac := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return sec<<32 | frac
+}
+
+// fromNTP converts a 64-bit NTP timestamp. Seconds below 2^31 belong to the
+// era starting in 2036.
+func fromNTP(v uint64) time.Time {
+	sec := int64(v >> 32)
+	if sec < 1<<31 {
+		sec += 1 << 32
+	}
+	nsec := int64((v & 0xffffffff) * uint64(time.Second) >> 32)
+	return time.Unix(sec-ntpEpochOffset, nsec).UTC()
+}
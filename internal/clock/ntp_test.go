@@ -0,0 +1,73 @@
+package clock
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNTP answers requests with a clock skewed by skew, or with a
+// kiss-o'-death when kiss is set.
+func fakeNTP(t *testing.T, skew time.Duration, kiss string) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = pc.Close() })
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 48 {
+				continue
+			}
+			resp := make([]byte, 48)
+			resp[0] = 0x24 // version 4, mode 4 (server)
+			resp[1] = 2
+			if kiss != "" {
+				resp[1] = 0
+				copy(resp[12:16], kiss)
+			}
+			copy(resp[24:32], buf[40:48])
+			now := toNTP(time.Now().Add(skew))
+			binary.BigEndian.PutUint64(resp[32:], now)
+			binary.BigEndian.PutUint64(resp[40:], now)
+			_, _ = pc.WriteTo(resp, addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func TestQueryNTP(t *testing.T) {
+	off, err := queryNTP(context.Background(), fakeNTP(t, 2*time.Second, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if off > -1900*time.Millisecond || off < -2100*time.Millisecond {
+		t.Fatalf("offset = %v, want about -2s", off)
+	}
+
+	_, err = queryNTP(context.Background(), fakeNTP(t, 0, "RATE"))
+	if err == nil || !strings.Contains(err.Error(), "RATE") {
+		t.Fatalf("kiss-o'-death err = %v", err)
+	}
+}
+
+func TestNTPTimestampRoundTrip(t *testing.T) {
+	for _, want := range []time.Time{
+		time.Date(2026, 2, 21, 12, 0, 0, 500_000_000, time.UTC),
+		time.Date(2037, 1, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		got := fromNTP(toNTP(want))
+		if d := got.Sub(want); d < -time.Microsecond || d > time.Microsecond {
+			t.Fatalf("round trip of %v = %v", want, got)
+		}
+	}
+}
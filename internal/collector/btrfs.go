@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// readBtrfs reads mounted btrfs filesystems from sysfs (normally
+// /sys/fs/btrfs), which needs neither the btrfs tool nor privileges. Device
+// error counters (devinfo/*/error_stats) need Linux 5.14 or newer.
+func readBtrfs(root string, now time.Time) []models.StoragePool {
+	var pools []models.StoragePool
+	dirs, _ := filepath.Glob(filepath.Join(root, "*", "devinfo"))
+	for _, devinfo := range dirs {
+		fs := filepath.Dir(devinfo)
+		p := models.StoragePool{Type: "btrfs", Name: readTrimmed(filepath.Join(fs, "label")), Health: "ONLINE", CheckedAt: now}
+		if p.Name == "" {
+			p.Name = filepath.Base(fs)
+		}
+		devs, _ := filepath.Glob(filepath.Join(devinfo, "*"))
+		for _, d := range devs {
+			if readTrimmed(filepath.Join(d, "missing")) == "1" {
+				p.Health = "DEGRADED"
+			}
+			p.DeviceErrors += btrfsErrorStats(filepath.Join(d, "error_stats"))
+		}
+		// Device sizes are in 512-byte sectors.
+		sizes, _ := filepath.Glob(filepath.Join(fs, "devices", "*", "size"))
+		for _, f := range sizes {
+			if v, err := strconv.ParseInt(readTrimmed(f), 10, 64); err == nil {
+				p.SizeBytes += v * 512
+			}
+		}
+		for _, kind := range []string{"data", "metadata", "system"} {
+			if v, err := strconv.ParseInt(readTrimmed(filepath.Join(fs, "allocation", kind, "disk_used")), 10, 64); err == nil {
+				p.AllocBytes += v
+			}
+		}
+		pools = append(pools, p)
+	}
+	return pools
+}
+
+// btrfsErrorStats sums the counters of an error_stats file
+// ("write_errs 0", "read_errs 0", "corruption_errs 0", ...).
+func btrfsErrorStats(path string) int64 {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	var n int64
+	for _, line := range strings.Split(string(b), "\n") {
+		if f := strings.Fields(line); len(f) == 2 {
+			v, _ := strconv.ParseInt(f[1], 10, 64)
+			n += v
+		}
+	}
+	return n
+}
@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadBtrfs(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, v string) {
+		p := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(v+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs := "0f4c1b8e-4a4e-4bd4-9a36-1b2f0e6a7c11"
+	write(fs+"/label", "data")
+	write(fs+"/devinfo/1/missing", "0")
+	write(fs+"/devinfo/1/error_stats", "write_errs 0\nread_errs 2\nflush_errs 0\ncorruption_errs 1\ngeneration_errs 0")
+	write(fs+"/devinfo/2/missing", "1")
+	write(fs+"/devices/sda/size", "2097152")
+	write(fs+"/devices/sdb/size", "2097152")
+	write(fs+"/allocation/data/disk_used", "536870912")
+	write(fs+"/allocation/metadata/disk_used", "67108864")
+	write("features/mixed_groups", "0")
+
+	pools := readBtrfs(root, time.Now())
+	if len(pools) != 1 {
+		t.Fatalf("pools = %+v", pools)
+	}
+	p := pools[0]
+	if p.Name != "data" || p.Health != "DEGRADED" || p.DeviceErrors != 3 {
+		t.Fatalf("pool = %+v", p)
+	}
+	if p.SizeBytes != 2<<30 || p.AllocBytes != 576<<20 {
+		t.Fatalf("size = %d, alloc = %d", p.SizeBytes, p.AllocBytes)
+	}
+}
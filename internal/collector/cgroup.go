@@ -0,0 +1,196 @@
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dashi/internal/docker"
+	"dashi/internal/models"
+)
+
+var errNoCgroup = errors.New("cgroup fallback unavailable")
+
+// cgroupReader reads container metrics straight from a cgroup v2 hierarchy
+// when the Docker stats API fails. Network counters are not part of cgroups
+// and stay zero.
+type cgroupReader struct {
+	root string
+
+	mu   sync.Mutex
+	prev map[string]cgroupCPU
+}
+
+type cgroupCPU struct {
+	usageUsec uint64
+	ts        time.Time
+}
+
+// newCgroupReader returns nil unless root is a cgroup v2 mount.
+func newCgroupReader(root string) *cgroupReader {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return nil
+	}
+	return &cgroupReader{root: root, prev: map[string]cgroupCPU{}}
+}
+
+// dir finds the cgroup of a container. The systemd driver places it in
+// <parent>/docker-<id>.scope (parent system.slice by default), the cgroupfs
+// driver in <parent>/<id> (parent docker); /proc/<pid>/cgroup is used when
+// the container's process is visible.
+func (r *cgroupReader) dir(id string, in docker.ContainerInspect) (string, error) {
+	var candidates []string
+	if in.State.Pid > 0 {
+		if b, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", in.State.Pid)); err == nil {
+			for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+				if p, ok := strings.CutPrefix(line, "0::"); ok && p != "/" {
+					candidates = append(candidates, p)
+				}
+			}
+		}
+	}
+	parents := []string{"system.slice", "docker"}
+	if p := in.HostConfig.CgroupParent; p != "" {
+		parents = append([]string{p}, parents...)
+	}
+	for _, p := range parents {
+		candidates = append(candidates, filepath.Join(p, "docker-"+id+".scope"), filepath.Join(p, id))
+	}
+	for _, c := range candidates {
+		d := filepath.Join(r.root, c)
+		if _, err := os.Stat(filepath.Join(d, "cpu.stat")); err == nil {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup found for container %s", shortID(id))
+}
+
+func (r *cgroupReader) read(id string, in docker.ContainerInspect) (models.ContainerMetric, error) {
+	if r == nil {
+		return models.ContainerMetric{}, errNoCgroup
+	}
+	d, err := r.dir(id, in)
+	if err != nil {
+		return models.ContainerMetric{}, err
+	}
+	m := models.ContainerMetric{TS: time.Now().UTC(), ContainerID: id}
+	stat, err := readKeyValues(filepath.Join(d, "cpu.stat"))
+	if err != nil {
+		return m, err
+	}
+	usage := stat["usage_usec"]
+	r.mu.Lock()
+	prev, ok := r.prev[id]
+	r.prev[id] = cgroupCPU{usageUsec: usage, ts: m.TS}
+	r.mu.Unlock()
+	// Like Docker's figure, 100% is one fully used CPU.
+	if elapsed := m.TS.Sub(prev.ts).Microseconds(); ok && elapsed > 0 && usage >= prev.usageUsec {
+		m.CPUPct = 100 * float64(usage-prev.usageUsec) / float64(elapsed)
+	}
+
+	if cur, err := readUint(filepath.Join(d, "memory.current")); err == nil {
+		// Docker reports usage without the inactive page cache.
+		if mem, err := readKeyValues(filepath.Join(d, "memory.stat")); err == nil && mem["inactive_file"] < cur {
+			cur -= mem["inactive_file"]
+		}
+		m.MemUsedBytes = int64(cur)
+	}
+	if limit, err := readUint(filepath.Join(d, "memory.max")); err == nil {
+		m.MemLimitBytes = int64(limit)
+	} else if mem, err := readMem(); err == nil {
+		m.MemLimitBytes = int64(mem["MemTotal"])
+	}
+	m.BlkReadBytes, m.BlkWriteBytes = readIOStat(filepath.Join(d, "io.stat"))
+	if n, err := readUint(filepath.Join(d, "pids.current")); err == nil {
+		m.Pids = int64(n)
+	}
+	if n, err := readUint(filepath.Join(d, "pids.max")); err == nil {
+		m.PidsLimit = int64(n)
+	}
+	return m, nil
+}
+
+// retain drops CPU baselines of containers not in ids.
+func (r *cgroupReader) retain(ids []string) {
+	if r == nil {
+		return
+	}
+	keep := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		keep[id] = true
+	}
+	r.mu.Lock()
+	for id := range r.prev {
+		if !keep[id] {
+			delete(r.prev, id)
+		}
+	}
+	r.mu.Unlock()
+}
+
+// readKeyValues parses "key value" lines such as cpu.stat and memory.stat.
+func readKeyValues(path string) (map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	out := map[string]uint64{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), " ")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+			out[k] = n
+		}
+	}
+	return out, sc.Err()
+}
+
+// readUint reads a single number; "max" (no limit) is an error.
+func readUint(path string) (uint64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// readIOStat sums rbytes and wbytes over all devices of an io.stat file.
+func readIOStat(path string) (read, write int64) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		for _, field := range strings.Fields(line) {
+			k, v, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			n, _ := strconv.ParseInt(v, 10, 64)
+			switch k {
+			case "rbytes":
+				read += n
+			case "wbytes":
+				write += n
+			}
+		}
+	}
+	return read, write
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
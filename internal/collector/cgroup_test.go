@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dashi/internal/docker"
+)
+
+func TestCgroupReaderSystemdLayout(t *testing.T) {
+	root := t.TempDir()
+	id := "0123456789abcdef"
+	dir := filepath.Join(root, "system.slice", "docker-"+id+".scope")
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpu memory io\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	write("cpu.stat", "usage_usec 1000000\nuser_usec 800000\n")
+	write("memory.current", "3000\n")
+	write("memory.stat", "anon 1000\ninactive_file 1000\n")
+	write("memory.max", "max\n")
+	write("io.stat", "8:0 rbytes=10 wbytes=20 rios=1 wios=2\n8:16 rbytes=5 wbytes=5\n")
+	write("pids.current", "7\n")
+	write("pids.max", "100\n")
+
+	r := newCgroupReader(root)
+	if r == nil {
+		t.Fatal("cgroup v2 root not detected")
+	}
+	var in docker.ContainerInspect
+	m, err := r.read(id, in)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if m.MemUsedBytes != 2000 || m.BlkReadBytes != 15 || m.BlkWriteBytes != 25 || m.CPUPct != 0 || m.Pids != 7 || m.PidsLimit != 100 {
+		t.Fatalf("first sample = %+v", m)
+	}
+
+	// Half a CPU over the interval since the previous sample.
+	r.prev[id] = cgroupCPU{usageUsec: 500000, ts: m.TS.Add(-time.Second)}
+	write("cpu.stat", "usage_usec 1000000\n")
+	m, err = r.read(id, in)
+	if err != nil {
+		t.Fatalf("second read: %v", err)
+	}
+	if m.CPUPct < 40 || m.CPUPct > 50 {
+		t.Fatalf("cpu = %.1f, want about 50", m.CPUPct)
+	}
+
+	if newCgroupReader(t.TempDir()) != nil {
+		t.Fatal("reader created for a directory without cgroup.controllers")
+	}
+}
+
+func TestOpenFilesOfOwnProcess(t *testing.T) {
+	n, limit, err := openFiles(os.Getpid())
+	if err != nil {
+		t.Skipf("no /proc: %v", err)
+	}
+	if n < 3 || (limit != 0 && limit < n) {
+		t.Fatalf("open files = %d, limit %d", n, limit)
+	}
+}
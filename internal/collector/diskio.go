@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// diskCounters are the cumulative /proc/diskstats fields of one device.
+type diskCounters struct {
+	reads, writes             uint64
+	readSectors, writeSectors uint64
+	ioTicksMs                 uint64
+}
+
+type diskSample struct {
+	ts       time.Time
+	counters map[string]diskCounters
+}
+
+// readDiskstats parses /proc/diskstats, keeping the devices keep accepts.
+func readDiskstats(r io.Reader, keep func(string) bool) (map[string]diskCounters, error) {
+	out := map[string]diskCounters{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) < 14 || !keep(f[2]) {
+			continue
+		}
+		n := func(i int) uint64 {
+			v, _ := strconv.ParseUint(f[i], 10, 64)
+			return v
+		}
+		out[f[2]] = diskCounters{reads: n(3), readSectors: n(5), writes: n(7), writeSectors: n(9), ioTicksMs: n(12)}
+	}
+	return out, sc.Err()
+}
+
+// physicalDisk keeps whole physical devices. Partitions, device-mapper and
+// md devices would count the same I/O twice; loop, ram and zram devices are
+// not disks.
+func physicalDisk(name string) bool {
+	_, err := os.Stat(filepath.Join("/sys/block", name, "device"))
+	return err == nil
+}
+
+// diskRates turns two samples into per-device throughput (bytes/s), IOPS
+// and utilization (share of wall time the device was busy). Devices whose
+// counters went backwards are skipped.
+func diskRates(prev, cur diskSample) []models.DiskIO {
+	elapsed := cur.ts.Sub(prev.ts).Seconds()
+	if elapsed <= 0 {
+		return nil
+	}
+	var out []models.DiskIO
+	for name, c := range cur.counters {
+		p, ok := prev.counters[name]
+		if !ok || c.reads < p.reads || c.writes < p.writes || c.readSectors < p.readSectors || c.writeSectors < p.writeSectors || c.ioTicksMs < p.ioTicksMs {
+			continue
+		}
+		util := 100 * float64(c.ioTicksMs-p.ioTicksMs) / (elapsed * 1000)
+		out = append(out, models.DiskIO{
+			TS:        cur.ts,
+			Device:    name,
+			ReadRate:  float64(c.readSectors-p.readSectors) * 512 / elapsed,
+			WriteRate: float64(c.writeSectors-p.writeSectors) * 512 / elapsed,
+			ReadIOPS:  float64(c.reads-p.reads) / elapsed,
+			WriteIOPS: float64(c.writes-p.writes) / elapsed,
+			UtilPct:   min(util, 100),
+		})
+	}
+	return out
+}
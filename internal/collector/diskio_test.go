@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiskRates(t *testing.T) {
+	stats := func(reads, readSectors, writes, writeSectors, ticks int) string {
+		return strings.Join([]string{
+			"   8       0 sda " + strings.Join(fields(reads, 0, readSectors, 0, writes, 0, writeSectors, 0, 0, ticks, 0), " "),
+			"   8       1 sda1 1 2 3 4 5 6 7 8 9 10 11",
+			"   7       0 loop0 1 2 3 4 5 6 7 8 9 10 11",
+		}, "\n")
+	}
+	keep := func(name string) bool { return name == "sda" }
+	prev, err := readDiskstats(strings.NewReader(stats(100, 2000, 50, 1000, 1000)), keep)
+	if err != nil || len(prev) != 1 {
+		t.Fatalf("parse = %+v, %v", prev, err)
+	}
+	cur, _ := readDiskstats(strings.NewReader(stats(300, 4048, 150, 3048, 1500)), keep)
+	t0 := time.Unix(1000, 0)
+	got := diskRates(diskSample{ts: t0, counters: prev}, diskSample{ts: t0.Add(2 * time.Second), counters: cur})
+	if len(got) != 1 {
+		t.Fatalf("rates = %+v", got)
+	}
+	d := got[0]
+	if d.Device != "sda" || d.ReadIOPS != 100 || d.WriteIOPS != 50 || d.ReadRate != 1024*512 || d.WriteRate != 1024*512 || d.UtilPct != 25 {
+		t.Fatalf("sda = %+v", d)
+	}
+	// A counter reset (device replaced, wraparound) yields no sample.
+	if got := diskRates(diskSample{ts: t0, counters: cur}, diskSample{ts: t0.Add(time.Second), counters: prev}); len(got) != 0 {
+		t.Fatalf("rates after reset = %+v", got)
+	}
+}
+
+func fields(v ...int) []string {
+	out := make([]string, len(v))
+	for i, n := range v {
+		out[i] = strconv.Itoa(n)
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"dashi/internal/docker"
+	"dashi/internal/models"
+)
+
+// CollectDiskUsage records volume and image sizes. Docker walks every volume
+// to answer, so it runs on its own slow ticker rather than with CollectStats.
+func (s *Service) CollectDiskUsage(ctx context.Context) {
+	du, err := s.dc.DiskUsage(ctx)
+	if err != nil {
+		if !errors.Is(err, docker.ErrForbidden) {
+			s.log.Warn("collect disk usage", "err", err)
+		}
+		return
+	}
+	volumes := make([]models.VolumeUsage, 0, len(du.Volumes))
+	for _, v := range du.Volumes {
+		vu := models.VolumeUsage{Name: v.Name, Driver: v.Driver}
+		// Size is -1 when the daemon could not (or was not asked to) compute it.
+		if v.UsageData != nil && v.UsageData.Size >= 0 {
+			vu.SizeBytes, vu.RefCount = v.UsageData.Size, v.UsageData.RefCount
+		}
+		volumes = append(volumes, vu)
+	}
+	images := make([]models.ImageUsage, 0, len(du.Images))
+	for _, img := range du.Images {
+		var tags []string
+		for _, t := range img.RepoTags {
+			if t != "<none>:<none>" {
+				tags = append(tags, t)
+			}
+		}
+		images = append(images, models.ImageUsage{ID: img.ID, Tags: tags, SizeBytes: img.Size, SharedBytes: max(img.SharedSize, 0), Containers: max(img.Containers, 0)})
+	}
+	if err := s.repo.InsertDiskUsage(ctx, s.dockerHost, time.Now(), volumes, images); err != nil {
+		s.log.Error("store disk usage", "err", err)
+	}
+}
@@ -3,8 +3,9 @@ package collector
 import (
 	"bufio"
 	"errors"
-	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -13,8 +14,12 @@ import (
 	"dashi/internal/models"
 )
 
+// HostCollector samples this machine. CPU, memory, network, load and uptime
+// come from /proc on Linux and from sysctl on macOS and FreeBSD; disk I/O,
+// TCP and temperature readers are Linux-only and stay empty elsewhere.
 type HostCollector struct {
-	prevCPU *cpuSample
+	prevCPU  *cpuSample
+	prevDisk *diskSample
 }
 
 type cpuSample struct {
@@ -30,19 +35,21 @@ func (h *HostCollector) Collect() (models.HostMetric, error) {
 		return models.HostMetric{}, err
 	}
 	metric := models.HostMetric{TS: time.Now().UTC()}
-	if h.prevCPU != nil {
+	// macOS tick counters are 32-bit, so a sample going backwards is a wrap
+	// and skipped.
+	if h.prevCPU != nil && total > h.prevCPU.total && idle >= h.prevCPU.idle {
 		deltaTotal := total - h.prevCPU.total
 		deltaIdle := idle - h.prevCPU.idle
-		if deltaTotal > 0 {
-			metric.CPUPct = 100 * (1 - float64(deltaIdle)/float64(deltaTotal))
-		}
+		metric.CPUPct = 100 * (1 - float64(deltaIdle)/float64(deltaTotal))
 	}
 	h.prevCPU = &cpuSample{total: total, idle: idle}
 
-	memTotal, memAvail, err := readMem()
-	if err == nil && memTotal > 0 {
-		metric.MemTotalBytes = int64(memTotal)
-		metric.MemUsedBytes = int64(memTotal - memAvail)
+	mem, err := readMem()
+	if err == nil {
+		metric.MemTotalBytes = int64(mem["MemTotal"])
+		metric.MemUsedBytes = int64(mem["MemTotal"] - mem["MemAvailable"])
+		metric.SwapTotalBytes = int64(mem["SwapTotal"])
+		metric.SwapUsedBytes = int64(mem["SwapTotal"] - mem["SwapFree"])
 	}
 
 	rx, tx, err := readNetDev()
@@ -65,99 +72,53 @@ func (h *HostCollector) Collect() (models.HostMetric, error) {
 	if err == nil {
 		metric.UptimeSec = up
 	}
-	return metric, nil
-}
 
-func readCPU() (total, idle uint64, err error) {
-	f, err := os.Open("/proc/stat")
-	if err != nil {
-		return 0, 0, err
-	}
-	defer f.Close()
-	s := bufio.NewScanner(f)
-	for s.Scan() {
-		line := s.Text()
-		if strings.HasPrefix(line, "cpu ") {
-			parts := strings.Fields(line)
-			if len(parts) < 5 {
-				return 0, 0, errors.New("invalid cpu line")
-			}
-			vals := make([]uint64, 0, len(parts)-1)
-			for _, p := range parts[1:] {
-				v, e := strconv.ParseUint(p, 10, 64)
-				if e != nil {
-					return 0, 0, e
-				}
-				vals = append(vals, v)
-				total += v
+	if f, err := os.Open("/proc/diskstats"); err == nil {
+		counters, err := readDiskstats(f, physicalDisk)
+		f.Close()
+		if err == nil {
+			cur := diskSample{ts: metric.TS, counters: counters}
+			if h.prevDisk != nil {
+				metric.Disks = diskRates(*h.prevDisk, cur)
+				sort.Slice(metric.Disks, func(i, j int) bool { return metric.Disks[i].Device < metric.Disks[j].Device })
 			}
-			idle = vals[3]
-			if len(vals) > 4 {
-				idle += vals[4]
-			}
-			return total, idle, nil
+			h.prevDisk = &cur
 		}
 	}
-	if err := s.Err(); err != nil {
-		return 0, 0, err
+	if c, err := readTCP(); err == nil {
+		metric.TCPEstablished, metric.TCPSynRecv, metric.TCPTimeWait = c.established, c.synRecv, c.timeWait
+		metric.TCPCloseWait, metric.TCPListen, metric.TCPConns = c.closeWait, c.listen, c.total
+	}
+	metric.Temperatures = readTemperatures("/sys/class", metric.TS)
+	metric.CPUTempC, metric.NVMeTempC = hottest(metric.Temperatures)
+	for _, d := range metric.Disks {
+		metric.DiskReadRate += d.ReadRate
+		metric.DiskWriteRate += d.WriteRate
+		metric.DiskReadIOPS += d.ReadIOPS
+		metric.DiskWriteIOPS += d.WriteIOPS
+		metric.DiskUtilPct = max(metric.DiskUtilPct, d.UtilPct)
 	}
-	return 0, 0, errors.New("cpu line not found")
+	return metric, nil
 }
 
-func readMem() (total, available uint64, err error) {
-	f, err := os.Open("/proc/meminfo")
-	if err != nil {
-		return 0, 0, err
-	}
-	defer f.Close()
-	s := bufio.NewScanner(f)
+func parseMeminfo(r io.Reader) (map[string]uint64, error) {
+	out := map[string]uint64{}
+	s := bufio.NewScanner(r)
 	for s.Scan() {
 		fields := strings.Fields(s.Text())
 		if len(fields) < 2 {
 			continue
 		}
-		if fields[0] == "MemTotal:" {
-			total, _ = strconv.ParseUint(fields[1], 10, 64)
-			total *= 1024
-		}
-		if fields[0] == "MemAvailable:" {
-			available, _ = strconv.ParseUint(fields[1], 10, 64)
-			available *= 1024
-		}
-	}
-	if total == 0 {
-		return 0, 0, errors.New("meminfo parse failed")
+		v, _ := strconv.ParseUint(fields[1], 10, 64)
+		out[strings.TrimSuffix(fields[0], ":")] = v * 1024
 	}
-	return total, available, nil
-}
-
-func readNetDev() (rx, tx uint64, err error) {
-	f, err := os.Open("/proc/net/dev")
-	if err != nil {
-		return 0, 0, err
+	if err := s.Err(); err != nil {
+		return nil, err
 	}
-	defer f.Close()
-	s := bufio.NewScanner(f)
-	for s.Scan() {
-		line := strings.TrimSpace(s.Text())
-		if !strings.Contains(line, ":") {
-			continue
-		}
-		parts := strings.SplitN(line, ":", 2)
-		iface := strings.TrimSpace(parts[0])
-		if iface == "lo" {
-			continue
-		}
-		vals := strings.Fields(parts[1])
-		if len(vals) < 16 {
-			continue
-		}
-		r, _ := strconv.ParseUint(vals[0], 10, 64)
-		t, _ := strconv.ParseUint(vals[8], 10, 64)
-		rx += r
-		tx += t
+	if out["MemTotal"] == 0 || out["MemAvailable"] > out["MemTotal"] || out["SwapFree"] > out["SwapTotal"] {
+		return nil, errors.New("meminfo parse failed")
 	}
-	return rx, tx, s.Err()
+	return out, nil
 }
 
 func readDiskUsage(path string) (total, used uint64, err error) {
@@ -165,39 +126,9 @@ func readDiskUsage(path string) (total, used uint64, err error) {
 	if err := syscall.Statfs(path, &st); err != nil {
 		return 0, 0, err
 	}
-	total = st.Blocks * uint64(st.Bsize)
-	free := st.Bavail * uint64(st.Bsize)
+	// Field types differ per platform; FreeBSD's Bavail is signed.
+	total = uint64(st.Blocks) * uint64(st.Bsize)
+	free := uint64(st.Bavail) * uint64(st.Bsize)
 	used = total - free
 	return total, used, nil
 }
-
-func readLoadAvg() (float64, float64, float64, error) {
-	b, err := os.ReadFile("/proc/loadavg")
-	if err != nil {
-		return 0, 0, 0, err
-	}
-	parts := strings.Fields(string(b))
-	if len(parts) < 3 {
-		return 0, 0, 0, fmt.Errorf("invalid loadavg")
-	}
-	l1, _ := strconv.ParseFloat(parts[0], 64)
-	l5, _ := strconv.ParseFloat(parts[1], 64)
-	l15, _ := strconv.ParseFloat(parts[2], 64)
-	return l1, l5, l15, nil
-}
-
-func readUptimeSec() (int64, error) {
-	b, err := os.ReadFile("/proc/uptime")
-	if err != nil {
-		return 0, err
-	}
-	parts := strings.Fields(string(b))
-	if len(parts) == 0 {
-		return 0, fmt.Errorf("invalid uptime")
-	}
-	f, err := strconv.ParseFloat(parts[0], 64)
-	if err != nil {
-		return 0, err
-	}
-	return int64(f), nil
-}
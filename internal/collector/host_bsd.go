@@ -0,0 +1,84 @@
+//go:build darwin || freebsd
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// loadavg mirrors struct loadavg from <sys/resource.h>; Go's int has the
+// size and alignment of C long on the supported ABIs.
+type loadavg struct {
+	Ldavg  [3]uint32
+	Fscale int
+}
+
+// sysctlValue reads a fixed-size binary sysctl into a T laid out like the
+// kernel's C type.
+func sysctlValue[T any](name string) (T, error) {
+	var v T
+	s, err := syscall.Sysctl(name)
+	if err != nil {
+		return v, fmt.Errorf("sysctl %s: %w", name, err)
+	}
+	// syscall.Sysctl is meant for strings and drops a trailing NUL, which in
+	// binary values is just a zero byte.
+	b := []byte(s)
+	size := int(unsafe.Sizeof(v))
+	if len(b) == size-1 {
+		b = append(b, 0)
+	}
+	if len(b) < size {
+		return v, fmt.Errorf("sysctl %s: %d bytes, want %d", name, len(b), size)
+	}
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(&v)), size), b)
+	return v, nil
+}
+
+func readNetDev() (rx, tx uint64, err error) {
+	rib, err := syscall.RouteRIB(syscall.NET_RT_IFLIST, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	msgs, err := syscall.ParseRoutingMessage(rib)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, m := range msgs {
+		ifm, ok := m.(*syscall.InterfaceMessage)
+		if !ok || ifm.Header.Flags&syscall.IFF_LOOPBACK != 0 {
+			continue
+		}
+		rx += uint64(ifm.Header.Data.Ibytes)
+		tx += uint64(ifm.Header.Data.Obytes)
+	}
+	return rx, tx, nil
+}
+
+func readLoadAvg() (float64, float64, float64, error) {
+	la, err := sysctlValue[loadavg]("vm.loadavg")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if la.Fscale <= 0 {
+		return 0, 0, 0, errors.New("invalid loadavg")
+	}
+	scale := float64(la.Fscale)
+	return float64(la.Ldavg[0]) / scale, float64(la.Ldavg[1]) / scale, float64(la.Ldavg[2]) / scale, nil
+}
+
+func readUptimeSec() (int64, error) {
+	tv, err := sysctlValue[syscall.Timeval]("kern.boottime")
+	if err != nil {
+		return 0, err
+	}
+	sec, nsec := tv.Unix()
+	if sec <= 0 {
+		return 0, fmt.Errorf("invalid uptime")
+	}
+	return int64(time.Since(time.Unix(sec, nsec)).Seconds()), nil
+}
@@ -0,0 +1,28 @@
+//go:build darwin || freebsd
+
+package collector
+
+import "testing"
+
+func TestBSDHostReaders(t *testing.T) {
+	if _, _, err := readCPU(); err != nil {
+		t.Skipf("cpu counters unavailable: %v", err)
+	}
+	mem, err := readMem()
+	if err != nil {
+		t.Fatalf("read memory: %v", err)
+	}
+	if mem["MemTotal"] == 0 || mem["MemAvailable"] > mem["MemTotal"] {
+		t.Fatalf("memory = %d/%d", mem["MemAvailable"], mem["MemTotal"])
+	}
+	l1, _, _, err := readLoadAvg()
+	if err != nil || l1 < 0 {
+		t.Fatalf("load = %v, %v", l1, err)
+	}
+	if up, err := readUptimeSec(); err != nil || up <= 0 {
+		t.Fatalf("uptime = %d, %v", up, err)
+	}
+	if _, _, err := readNetDev(); err != nil {
+		t.Fatalf("read net: %v", err)
+	}
+}
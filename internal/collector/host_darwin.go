@@ -0,0 +1,83 @@
+//go:build cgo
+
+package collector
+
+/*
+#include <mach/mach.h>
+
+static kern_return_t dashi_cpu_ticks(natural_t ticks[CPU_STATE_MAX]) {
+	host_cpu_load_info_data_t info;
+	mach_msg_type_number_t count = HOST_CPU_LOAD_INFO_COUNT;
+	mach_port_t host = mach_host_self();
+	kern_return_t ret = host_statistics(host, HOST_CPU_LOAD_INFO, (host_info_t)&info, &count);
+	mach_port_deallocate(mach_task_self(), host);
+	for (int i = 0; i < CPU_STATE_MAX; i++) {
+		ticks[i] = info.cpu_ticks[i];
+	}
+	return ret;
+}
+
+static kern_return_t dashi_vm_pages(uint64_t *free_pages, uint64_t *inactive_pages) {
+	vm_statistics64_data_t vm;
+	mach_msg_type_number_t count = HOST_VM_INFO64_COUNT;
+	mach_port_t host = mach_host_self();
+	kern_return_t ret = host_statistics64(host, HOST_VM_INFO64, (host_info64_t)&vm, &count);
+	mach_port_deallocate(mach_task_self(), host);
+	*free_pages = vm.free_count;
+	*inactive_pages = vm.inactive_count;
+	return ret;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// xswUsage mirrors struct xsw_usage, the vm.swapusage value.
+type xswUsage struct {
+	Total     uint64
+	Avail     uint64
+	Used      uint64
+	Pagesize  uint32
+	Encrypted int32
+}
+
+// readCPU sums the Mach host CPU ticks. They are 32-bit counters, see
+// Collect.
+func readCPU() (total, idle uint64, err error) {
+	var ticks [C.CPU_STATE_MAX]C.natural_t
+	if ret := C.dashi_cpu_ticks(&ticks[0]); ret != C.KERN_SUCCESS {
+		return 0, 0, fmt.Errorf("host_statistics: kern_return %d", int(ret))
+	}
+	for _, t := range ticks {
+		total += uint64(t)
+	}
+	return total, uint64(ticks[C.CPU_STATE_IDLE]), nil
+}
+
+// readMem returns memory in bytes under the /proc/meminfo names Collect
+// uses. Available counts free and inactive pages, as vm_stat reports them.
+func readMem() (map[string]uint64, error) {
+	total, err := sysctlValue[uint64]("hw.memsize")
+	if err != nil {
+		return nil, err
+	}
+	var free, inactive C.uint64_t
+	if ret := C.dashi_vm_pages(&free, &inactive); ret != C.KERN_SUCCESS {
+		return nil, fmt.Errorf("host_statistics64: kern_return %d", int(ret))
+	}
+	out := map[string]uint64{
+		"MemTotal":     total,
+		"MemAvailable": (uint64(free) + uint64(inactive)) * uint64(os.Getpagesize()),
+	}
+	if swap, err := sysctlValue[xswUsage]("vm.swapusage"); err == nil && swap.Used <= swap.Total {
+		out["SwapTotal"], out["SwapFree"] = swap.Total, swap.Total-swap.Used
+	}
+	if out["MemTotal"] == 0 || out["MemAvailable"] > out["MemTotal"] {
+		return nil, errors.New("memory statistics out of range")
+	}
+	return out, nil
+}
@@ -0,0 +1,13 @@
+//go:build darwin && !cgo
+
+package collector
+
+import "errors"
+
+// CPU and memory counters on macOS come from Mach host_statistics, which
+// needs cgo. Without it the host metric is skipped like on a missing /proc.
+var errNoMach = errors.New("host cpu and memory need a cgo build on macOS")
+
+func readCPU() (total, idle uint64, err error) { return 0, 0, errNoMach }
+
+func readMem() (map[string]uint64, error) { return nil, errNoMach }
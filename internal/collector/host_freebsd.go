@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// cpStates are the kern.cp_time slots: user, nice, system, interrupt, idle.
+const (
+	cpStates = 5
+	cpIdle   = 4
+)
+
+func readCPU() (total, idle uint64, err error) {
+	ticks, err := sysctlValue[[cpStates]int]("kern.cp_time")
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, t := range ticks {
+		total += uint64(t)
+	}
+	return total, uint64(ticks[cpIdle]), nil
+}
+
+// readMem returns memory in bytes under the /proc/meminfo names Collect
+// uses. Available counts free and inactive pages. Swap usage needs kvm or
+// per-device sysctls and is left out.
+func readMem() (map[string]uint64, error) {
+	physmem, err := sysctlValue[uint]("hw.physmem")
+	if err != nil {
+		return nil, err
+	}
+	free, err := syscall.SysctlUint32("vm.stats.vm.v_free_count")
+	if err != nil {
+		return nil, err
+	}
+	inactive, err := syscall.SysctlUint32("vm.stats.vm.v_inactive_count")
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]uint64{
+		"MemTotal":     uint64(physmem),
+		"MemAvailable": (uint64(free) + uint64(inactive)) * uint64(os.Getpagesize()),
+	}
+	if out["MemTotal"] == 0 || out["MemAvailable"] > out["MemTotal"] {
+		return nil, errors.New("memory sysctls out of range")
+	}
+	return out, nil
+}
@@ -0,0 +1,119 @@
+//go:build !darwin && !freebsd
+
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func readCPU() (total, idle uint64, err error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "cpu ") {
+			parts := strings.Fields(line)
+			if len(parts) < 5 {
+				return 0, 0, errors.New("invalid cpu line")
+			}
+			vals := make([]uint64, 0, len(parts)-1)
+			for _, p := range parts[1:] {
+				v, e := strconv.ParseUint(p, 10, 64)
+				if e != nil {
+					return 0, 0, e
+				}
+				vals = append(vals, v)
+				total += v
+			}
+			idle = vals[3]
+			if len(vals) > 4 {
+				idle += vals[4]
+			}
+			return total, idle, nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return 0, 0, err
+	}
+	return 0, 0, errors.New("cpu line not found")
+}
+
+// readMem returns the /proc/meminfo fields in bytes, keyed without the
+// trailing colon.
+func readMem() (map[string]uint64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMeminfo(f)
+}
+
+func readNetDev() (rx, tx uint64, err error) {
+	f, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if !strings.Contains(line, ":") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		iface := strings.TrimSpace(parts[0])
+		if iface == "lo" {
+			continue
+		}
+		vals := strings.Fields(parts[1])
+		if len(vals) < 16 {
+			continue
+		}
+		r, _ := strconv.ParseUint(vals[0], 10, 64)
+		t, _ := strconv.ParseUint(vals[8], 10, 64)
+		rx += r
+		tx += t
+	}
+	return rx, tx, s.Err()
+}
+
+func readLoadAvg() (float64, float64, float64, error) {
+	b, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	parts := strings.Fields(string(b))
+	if len(parts) < 3 {
+		return 0, 0, 0, fmt.Errorf("invalid loadavg")
+	}
+	l1, _ := strconv.ParseFloat(parts[0], 64)
+	l5, _ := strconv.ParseFloat(parts[1], 64)
+	l15, _ := strconv.ParseFloat(parts[2], 64)
+	return l1, l5, l15, nil
+}
+
+func readUptimeSec() (int64, error) {
+	b, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0, err
+	}
+	parts := strings.Fields(string(b))
+	if len(parts) == 0 {
+		return 0, fmt.Errorf("invalid uptime")
+	}
+	f, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(f), nil
+}
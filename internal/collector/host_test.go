@@ -0,0 +1,30 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMeminfo(t *testing.T) {
+	in := `MemTotal:       16303712 kB
+MemFree:         1123456 kB
+MemAvailable:    8151856 kB
+SwapCached:        10240 kB
+SwapTotal:       4194300 kB
+SwapFree:        3145724 kB
+HugePages_Total:       0
+`
+	mem, err := parseMeminfo(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mem["MemTotal"] != 16303712*1024 || mem["MemAvailable"] != 8151856*1024 {
+		t.Fatalf("memory = %d/%d", mem["MemAvailable"], mem["MemTotal"])
+	}
+	if used := mem["SwapTotal"] - mem["SwapFree"]; used != 1048576*1024 {
+		t.Fatalf("swap used = %d", used)
+	}
+	if _, err := parseMeminfo(strings.NewReader("MemFree: 10 kB\n")); err == nil {
+		t.Fatal("expected error without MemTotal")
+	}
+}
@@ -0,0 +1,30 @@
+package collector
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// CollectPools records the ZFS pools (through the zpool command, when it is
+// on PATH) and btrfs filesystems of this machine. Only the local collector
+// reads them.
+func (s *Service) CollectPools(ctx context.Context) {
+	if s.host == nil {
+		return
+	}
+	now := time.Now().UTC()
+	if path, err := exec.LookPath("zpool"); err == nil {
+		zctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		pools, err := readZFSPools(zctx, path, now)
+		cancel()
+		if err != nil {
+			s.log.Warn("collect zfs pools", "err", err)
+		} else if err := s.repo.ReplaceStoragePools(ctx, "zfs", pools); err != nil {
+			s.log.Error("store zfs pools", "err", err)
+		}
+	}
+	if err := s.repo.ReplaceStoragePools(ctx, "btrfs", readBtrfs("/sys/fs/btrfs", now)); err != nil {
+		s.log.Error("store btrfs pools", "err", err)
+	}
+}
@@ -0,0 +1,37 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// openFiles counts the open file descriptors of a host process and reads
+// its soft "Max open files" limit (0 when unlimited). Seeing other
+// processes' fds needs the host PID namespace and CAP_SYS_PTRACE or the same
+// user.
+func openFiles(pid int) (open, limit int64, err error) {
+	entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	f, err := os.Open(fmt.Sprintf("/proc/%d/limits", pid))
+	if err != nil {
+		return int64(len(entries)), 0, nil
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		rest, ok := strings.CutPrefix(sc.Text(), "Max open files")
+		if !ok {
+			continue
+		}
+		if fields := strings.Fields(rest); len(fields) > 0 {
+			limit, _ = strconv.ParseInt(fields[0], 10, 64)
+		}
+		break
+	}
+	return int64(len(entries)), limit, nil
+}
@@ -0,0 +1,53 @@
+package collector
+
+import "time"
+
+// counterTracker turns cumulative byte counters into per-second rates.
+// Counters that go backwards were reset (container restart, host reboot,
+// interface recreated); the new value is then taken as the delta since the
+// reset rather than producing a negative rate.
+type counterTracker struct {
+	prev map[string]counterSample
+}
+
+type counterSample struct {
+	ts     time.Time
+	values []int64
+}
+
+func newCounterTracker() *counterTracker {
+	return &counterTracker{prev: map[string]counterSample{}}
+}
+
+// rates records values for key and returns their rates against the previous
+// sample. The first sample for a key yields zero rates.
+func (t *counterTracker) rates(key string, ts time.Time, values ...int64) []float64 {
+	out := make([]float64, len(values))
+	prev, ok := t.prev[key]
+	t.prev[key] = counterSample{ts: ts, values: values}
+	elapsed := ts.Sub(prev.ts).Seconds()
+	if !ok || elapsed <= 0 || len(prev.values) != len(values) {
+		return out
+	}
+	for i, v := range values {
+		delta := v - prev.values[i]
+		if delta < 0 {
+			delta = v
+		}
+		out[i] = float64(delta) / elapsed
+	}
+	return out
+}
+
+// retain forgets keys that were not seen in the latest collection.
+func (t *counterTracker) retain(keys []string) {
+	keep := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		keep[k] = struct{}{}
+	}
+	for k := range t.prev {
+		if _, ok := keep[k]; !ok {
+			delete(t.prev, k)
+		}
+	}
+}
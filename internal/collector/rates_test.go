@@ -0,0 +1,29 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCounterTrackerRates(t *testing.T) {
+	tr := newCounterTracker()
+	t0 := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+
+	if got := tr.rates("c1", t0, 1000, 500); got[0] != 0 || got[1] != 0 {
+		t.Fatalf("first sample rates = %v, want zeros", got)
+	}
+	got := tr.rates("c1", t0.Add(10*time.Second), 3000, 1500)
+	if got[0] != 200 || got[1] != 100 {
+		t.Fatalf("rates = %v, want [200 100]", got)
+	}
+	// Counter reset after a restart: the new value counts from zero.
+	got = tr.rates("c1", t0.Add(20*time.Second), 400, 2500)
+	if got[0] != 40 || got[1] != 100 {
+		t.Fatalf("rates after reset = %v, want [40 100]", got)
+	}
+
+	tr.retain(nil)
+	if got := tr.rates("c1", t0.Add(30*time.Second), 800, 2600); got[0] != 0 {
+		t.Fatalf("rates after forget = %v, want zeros", got)
+	}
+}
@@ -0,0 +1,22 @@
+package collector
+
+import (
+	"time"
+
+	"dashi/internal/models"
+)
+
+// detectReboot compares two host samples. The host rebooted when its uptime
+// went backwards or, after a downtime longer than the previous uptime, when
+// it booted after the previous sample was taken. Samples without uptime are
+// ignored.
+func detectReboot(prev, cur models.HostMetric) (models.HostReboot, bool) {
+	if prev.UptimeSec <= 0 || cur.UptimeSec <= 0 || !cur.TS.After(prev.TS) {
+		return models.HostReboot{}, false
+	}
+	boot := cur.TS.Add(-time.Duration(cur.UptimeSec) * time.Second)
+	if cur.UptimeSec >= prev.UptimeSec && !boot.After(prev.TS) {
+		return models.HostReboot{}, false
+	}
+	return models.HostReboot{BootedAt: boot, LastSeenAt: prev.TS, PrevUptimeSec: prev.UptimeSec, DetectedAt: cur.TS}, true
+}
@@ -0,0 +1,33 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestDetectReboot(t *testing.T) {
+	t0 := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+	prev := models.HostMetric{TS: t0, UptimeSec: 86400}
+
+	if _, ok := detectReboot(prev, models.HostMetric{TS: t0.Add(10 * time.Second), UptimeSec: 86410}); ok {
+		t.Fatal("steady uptime detected as reboot")
+	}
+	rb, ok := detectReboot(prev, models.HostMetric{TS: t0.Add(3 * time.Minute), UptimeSec: 60})
+	if !ok {
+		t.Fatal("uptime reset not detected")
+	}
+	if !rb.BootedAt.Equal(t0.Add(2*time.Minute)) || !rb.LastSeenAt.Equal(t0) || rb.PrevUptimeSec != 86400 {
+		t.Fatalf("reboot = %+v", rb)
+	}
+	// Down for a day after only 30s of uptime: the uptime grew, but the boot
+	// is after the previous sample.
+	short := models.HostMetric{TS: t0, UptimeSec: 30}
+	if _, ok := detectReboot(short, models.HostMetric{TS: t0.Add(24 * time.Hour), UptimeSec: 120}); !ok {
+		t.Fatal("reboot after long downtime not detected")
+	}
+	if _, ok := detectReboot(models.HostMetric{TS: t0}, models.HostMetric{TS: t0.Add(time.Minute), UptimeSec: 60}); ok {
+		t.Fatal("sample without uptime detected as reboot")
+	}
+}
@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"time"
+
+	"dashi/internal/docker"
+	"dashi/internal/models"
+)
+
+// replaceWindow is how recently a service's container must have been seen
+// for a new one to count as its replacement rather than a first start.
+const replaceWindow = time.Hour
+
+// runState is what restart detection compares of a container between two
+// inspections.
+type runState struct {
+	running      bool
+	restartCount int
+	startedAt    time.Time
+	// exitCode and oomKilled are only meaningful while not running.
+	exitCode   int
+	oomKilled  bool
+	finishedAt time.Time
+}
+
+func runStateOf(in docker.ContainerInspect) runState {
+	st := runState{running: in.State.Status == "running", restartCount: in.RestartCount, exitCode: in.State.ExitCode, oomKilled: in.State.OOMKilled}
+	st.startedAt, _ = time.Parse(time.RFC3339Nano, in.State.StartedAt)
+	st.finishedAt, _ = time.Parse(time.RFC3339Nano, in.State.FinishedAt)
+	return st
+}
+
+// runStateOfRow is the run state of a stored container, for the first
+// inspection after dashi started.
+func runStateOfRow(c models.Container) runState {
+	st := runState{running: c.Status == "running", restartCount: c.RestartCount}
+	if c.StartedAt != nil {
+		st.startedAt = *c.StartedAt
+	}
+	return st
+}
+
+// detectRestart compares two inspections of a container. It restarted when
+// Docker counted a restart, or when it started again while running, as
+// `docker restart` does without counting. A container started again after
+// being down, counted or not, was seen restarting already.
+func detectRestart(prev, cur runState) (models.RestartEvent, bool) {
+	counted := cur.restartCount > prev.restartCount
+	started := prev.running && !prev.startedAt.IsZero() && cur.startedAt.After(prev.startedAt)
+	if !counted && !started {
+		return models.RestartEvent{}, false
+	}
+	ev := models.RestartEvent{Reason: "restart", RestartCount: cur.restartCount}
+	// Docker clears the exit code when the container runs again, so it is
+	// only known when inspected in between.
+	if !cur.running {
+		code := cur.exitCode
+		ev.ExitCode = &code
+		if cur.oomKilled {
+			ev.Reason = "oom"
+		}
+	} else {
+		ev.StartedAt = utcTime(cur.startedAt)
+	}
+	if cur.finishedAt.After(prev.startedAt) {
+		ev.FinishedAt = utcTime(cur.finishedAt)
+	}
+	return ev, true
+}
+
+func utcTime(t time.Time) *time.Time {
+	t = t.UTC()
+	return &t
+}
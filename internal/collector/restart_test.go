@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/docker"
+)
+
+func TestDetectRestart(t *testing.T) {
+	t0 := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+	running := runState{running: true, restartCount: 1, startedAt: t0}
+
+	if _, ok := detectRestart(running, running); ok {
+		t.Fatal("unchanged container detected as restarted")
+	}
+	// docker restart starts it again without counting.
+	ev, ok := detectRestart(running, runState{running: true, restartCount: 1, startedAt: t0.Add(time.Hour), finishedAt: t0.Add(time.Hour - 5*time.Second)})
+	if !ok || ev.Reason != "restart" || ev.ExitCode != nil || !ev.StartedAt.Equal(t0.Add(time.Hour)) || !ev.FinishedAt.Equal(t0.Add(time.Hour-5*time.Second)) {
+		t.Fatalf("restart = %+v, %v", ev, ok)
+	}
+	// Seen waiting for its restart policy after being killed out of memory.
+	down := runState{restartCount: 2, startedAt: t0, exitCode: 137, oomKilled: true, finishedAt: t0.Add(time.Hour)}
+	ev, ok = detectRestart(running, down)
+	if !ok || ev.Reason != "oom" || ev.ExitCode == nil || *ev.ExitCode != 137 || ev.RestartCount != 2 || ev.StartedAt != nil {
+		t.Fatalf("oom restart = %+v, %v", ev, ok)
+	}
+	// Running again after that is the same restart.
+	if _, ok := detectRestart(down, runState{running: true, restartCount: 2, startedAt: t0.Add(time.Hour + time.Second)}); ok {
+		t.Fatal("restart detected twice")
+	}
+	if _, ok := detectRestart(runState{restartCount: 1, startedAt: t0}, runState{running: true, restartCount: 1, startedAt: t0.Add(time.Hour)}); ok {
+		t.Fatal("start of a stopped container detected as restart")
+	}
+}
+
+func TestInspectRecordsRestarts(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+
+	var mu sync.Mutex
+	listed := map[string]string{"a": "/shop-web-1"}
+	inspects := map[string]string{"a": `{"Id":"a","State":{"Status":"running","StartedAt":"2026-02-21T12:00:00Z"}}`}
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, "/containers/json") {
+			var out []docker.ContainerSummary
+			for id, name := range listed {
+				out = append(out, docker.ContainerSummary{ID: id, Names: []string{name}, State: "running", Labels: map[string]string{"com.docker.compose.service": "web"}})
+			}
+			_ = json.NewEncoder(w).Encode(out)
+			return
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path[strings.Index(r.URL.Path, "/containers/"):], "/containers/"), "/json")
+		_, _ = io.WriteString(w, inspects[id])
+	}))
+	defer daemon.Close()
+	dc, err := docker.Dial("tcp://"+strings.TrimPrefix(daemon.URL, "http://"), "")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+	inspect := func(change func()) {
+		mu.Lock()
+		change()
+		mu.Unlock()
+		s := NewService(repo, dc, logger, nil, docker.LocalHost, Options{InventoryOnly: true})
+		defer s.Close(ctx)
+		s.Inspect(ctx)
+	}
+	reasons := func() []string {
+		events, err := repo.RestartEvents(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), "web", "", 10)
+		if err != nil {
+			t.Fatalf("restart events: %v", err)
+		}
+		var out []string
+		for _, ev := range events {
+			out = append(out, ev.Reason+":"+ev.ContainerID+"<"+ev.PrevContainerID)
+		}
+		return out
+	}
+
+	// Every inspection uses a fresh collector, as after restarts of dashi,
+	// so the previous state comes from the stored container.
+	inspect(func() {})
+	if got := reasons(); len(got) != 0 {
+		t.Fatalf("first start recorded as %v", got)
+	}
+	inspect(func() {
+		inspects["a"] = `{"Id":"a","RestartCount":1,"State":{"Status":"running","StartedAt":"2026-02-21T13:00:00Z"}}`
+	})
+	inspect(func() {
+		delete(listed, "a")
+		listed["b"] = "/shop-web-1"
+		inspects["b"] = `{"Id":"b","State":{"Status":"running","StartedAt":"2026-02-21T14:00:00Z"}}`
+	})
+	if got := strings.Join(reasons(), " "); got != "replaced:b<a restart:a<" {
+		t.Fatalf("restart events = %s", got)
+	}
+}
@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// cpuSensorChips are hwmon drivers and thermal zone types reporting the CPU
+// package or die temperature.
+var cpuSensorChips = map[string]bool{
+	"coretemp": true, "k10temp": true, "zenpower": true, "cpu_thermal": true, "x86_pkg_temp": true, "soc_thermal": true,
+}
+
+// readTemperatures reads every hwmon temperature input and, for zones not
+// already covered by a hwmon chip of the same name, the thermal zones under
+// sysClass (normally /sys/class). Sensors are named "<chip>/<label>".
+func readTemperatures(sysClass string, ts time.Time) []models.Temperature {
+	var out []models.Temperature
+	chips := map[string]bool{}
+	inputs, _ := filepath.Glob(filepath.Join(sysClass, "hwmon", "hwmon*", "temp*_input"))
+	for _, in := range inputs {
+		dir := filepath.Dir(in)
+		chip := readTrimmed(filepath.Join(dir, "name"))
+		c, ok := readMilli(in)
+		if chip == "" || !ok {
+			continue
+		}
+		chips[chip] = true
+		sensor := strings.TrimSuffix(filepath.Base(in), "_input")
+		label := readTrimmed(filepath.Join(dir, sensor+"_label"))
+		if label == "" {
+			label = sensor
+		}
+		out = append(out, models.Temperature{TS: ts, Sensor: chip + "/" + label, TempC: c})
+	}
+	zones, _ := filepath.Glob(filepath.Join(sysClass, "thermal", "thermal_zone*"))
+	for _, z := range zones {
+		typ := readTrimmed(filepath.Join(z, "type"))
+		c, ok := readMilli(filepath.Join(z, "temp"))
+		if typ == "" || !ok || chips[typ] {
+			continue
+		}
+		out = append(out, models.Temperature{TS: ts, Sensor: typ + "/" + filepath.Base(z), TempC: c})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Sensor < out[j].Sensor })
+	return out
+}
+
+// hottest returns the highest CPU and NVMe temperatures, 0 when there is no
+// such sensor.
+func hottest(temps []models.Temperature) (cpu, nvme float64) {
+	for _, t := range temps {
+		chip, _, _ := strings.Cut(t.Sensor, "/")
+		switch {
+		case cpuSensorChips[chip]:
+			cpu = max(cpu, t.TempC)
+		case chip == "nvme":
+			nvme = max(nvme, t.TempC)
+		}
+	}
+	return cpu, nvme
+}
+
+func readTrimmed(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// readMilli reads a millidegree Celsius value. Missing sensors read as errors
+// and disconnected ones as large negative values; both are skipped.
+func readMilli(path string) (float64, bool) {
+	v, err := strconv.ParseInt(readTrimmed(path), 10, 64)
+	if err != nil || v <= -40000 {
+		return 0, false
+	}
+	return float64(v) / 1000, true
+}
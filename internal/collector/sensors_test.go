@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadTemperatures(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, v string) {
+		p := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(v+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("hwmon/hwmon0/name", "coretemp")
+	write("hwmon/hwmon0/temp1_input", "54000")
+	write("hwmon/hwmon0/temp1_label", "Package id 0")
+	write("hwmon/hwmon0/temp2_input", "61500")
+	write("hwmon/hwmon0/temp2_label", "Core 0")
+	write("hwmon/hwmon1/name", "nvme")
+	write("hwmon/hwmon1/temp1_input", "38850")
+	write("hwmon/hwmon1/temp2_input", "-273150")
+	write("thermal/thermal_zone0/type", "x86_pkg_temp")
+	write("thermal/thermal_zone0/temp", "55000")
+	write("thermal/thermal_zone1/type", "acpitz")
+	write("thermal/thermal_zone1/temp", "27800")
+
+	temps := readTemperatures(root, time.Now())
+	var names []string
+	for _, tc := range temps {
+		names = append(names, tc.Sensor)
+	}
+	want := []string{"acpitz/thermal_zone1", "coretemp/Core 0", "coretemp/Package id 0", "nvme/temp1", "x86_pkg_temp/thermal_zone0"}
+	if len(names) != len(want) {
+		t.Fatalf("sensors = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("sensors = %v, want %v", names, want)
+		}
+	}
+	cpu, nvme := hottest(temps)
+	if cpu != 61.5 || nvme != 38.85 {
+		t.Fatalf("hottest = %v, %v", cpu, nvme)
+	}
+}
@@ -2,90 +2,447 @@ package collector
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"dashi/internal/db"
 	"dashi/internal/docker"
+	"dashi/internal/filter"
 	"dashi/internal/models"
+	"dashi/internal/supervise"
 )
 
 type Service struct {
-	repo *db.Repository
-	dc   *docker.Client
-	log  *slog.Logger
-	host *HostCollector
+	repo       *db.Repository
+	dc         *docker.Client
+	dockerHost string
+	filter     *filter.Filter
+	log        *slog.Logger
+	host       *HostCollector
+	writer     *writer
+	counters   *counterTracker
+	workers    int
+	deadline   time.Duration
+	streams    *statsStreams
+	cgroups    *cgroupReader
+	// inspected holds the last inspect result of each monitored container,
+	// for the stats samples taken between inspections.
+	inspected map[string]docker.ContainerInspect
+	// inventoryOnly skips metrics, keeping services and containers current.
+	inventoryOnly bool
+	onTick        func()
+	// prevHost is the last host sample, loaded from the database on the
+	// first tick so reboots that also restarted dashi are seen.
+	prevHost     *models.HostMetric
+	prevHostRead bool
 }
 
-func NewService(repo *db.Repository, dc *docker.Client, logger *slog.Logger) *Service {
-	return &Service{repo: repo, dc: dc, log: logger, host: NewHostCollector()}
+type Options struct {
+	// Workers bounds concurrent inspect/stats requests per tick, and a tick
+	// gives up on containers not sampled within Deadline.
+	Workers  int
+	Deadline time.Duration
+	// StreamStats keeps a streaming stats reader per running container;
+	// ticks then only fall back to one-shot stats calls when it has no
+	// recent frame.
+	StreamStats bool
+	// CgroupRoot is the cgroup v2 mount read for containers whose Docker
+	// stats fail. Only used for docker.LocalHost; empty disables it.
+	CgroupRoot string
+	// InventoryOnly tracks services and containers without collecting
+	// host or container metrics, for APP_METRICS_ENABLED=false.
+	InventoryOnly bool
+	// OnTick is called after each Inspect or CollectStats tick that listed
+	// the host's containers, e.g. to ping an external uptime monitor.
+	OnTick func()
 }
 
-func (s *Service) Tick(ctx context.Context) {
-	hm, err := s.host.Collect()
-	if err == nil {
-		if err := s.repo.InsertHostMetric(ctx, hm); err != nil {
-			s.log.Error("insert host metric", "err", err)
+// NewService collects container metrics from the Docker endpoint named
+// dockerHost. Host metrics come from this machine and are only collected by
+// the service for docker.LocalHost.
+func NewService(repo *db.Repository, dc *docker.Client, logger *slog.Logger, flt *filter.Filter, dockerHost string, opts Options) *Service {
+	w := newWriter(repo, logger, 16)
+	go w.run()
+	s := &Service{repo: repo, dc: dc, dockerHost: dockerHost, filter: flt, log: logger, writer: w, counters: newCounterTracker(), workers: max(opts.Workers, 1), deadline: opts.Deadline,
+		inspected: map[string]docker.ContainerInspect{}, inventoryOnly: opts.InventoryOnly, onTick: opts.OnTick}
+	if opts.InventoryOnly {
+		return s
+	}
+	if dockerHost == docker.LocalHost {
+		s.host = NewHostCollector()
+		if opts.CgroupRoot != "" {
+			s.cgroups = newCgroupReader(opts.CgroupRoot)
 		}
-	} else {
-		s.log.Warn("collect host metric", "err", err)
 	}
+	if opts.StreamStats {
+		s.streams = newStatsStreams(dc, logger)
+	}
+	return s
+}
 
-	containers, err := s.dc.ListContainers(ctx)
+// Close stops stats streams and flushes queued metric writes. The collect
+// methods must not be called afterwards.
+func (s *Service) Close(ctx context.Context) error {
+	if s.streams != nil {
+		s.streams.stop()
+	}
+	return s.writer.close(ctx)
+}
+
+// CollectHost samples the metrics of this machine. It does nothing for
+// remote Docker hosts and with metrics disabled.
+func (s *Service) CollectHost(ctx context.Context) {
+	defer supervise.Recover(s.log, "collector host tick")
+	if s.host == nil {
+		return
+	}
+	hm, err := s.host.Collect()
 	if err != nil {
-		s.log.Warn("list containers", "err", err)
+		s.log.Warn("collect host metric", "err", err)
 		return
 	}
-	seen := make([]string, 0, len(containers))
-	for _, c := range containers {
-		seen = append(seen, c.ID)
-		serviceName := inferServiceName(c)
-		labelsJSON, _ := json.Marshal(c.Labels)
-		svcID := serviceName
-		inspect, err := s.dc.InspectContainer(ctx, c.ID)
-		if err != nil {
-			s.log.Warn("inspect container", "id", c.ID, "err", err)
+	r := s.counters.rates("host", hm.TS, hm.NetRXBytes, hm.NetTXBytes)
+	hm.NetRXRate, hm.NetTXRate = r[0], r[1]
+	s.checkReboot(ctx, hm)
+	s.writer.enqueue(metricBatch{hosts: []models.HostMetric{hm}})
+}
+
+// Inspect lists and inspects the monitored containers, updates their
+// service and container rows and marks containers no longer listed as
+// missing.
+func (s *Service) Inspect(ctx context.Context) {
+	defer supervise.Recover(s.log, "collector inspect tick")
+	monitored, ok := s.list(ctx)
+	if !ok {
+		return
+	}
+	seen := make([]string, 0, len(monitored))
+	live := listedIDs(monitored)
+	for _, smp := range s.sample(ctx, monitored, false) {
+		seen = append(seen, smp.container.ID)
+		if smp.err != nil {
+			s.log.Warn("inspect container", "id", smp.container.ID, "err", smp.err)
 			continue
 		}
-		var started *time.Time
-		if t, err := time.Parse(time.RFC3339Nano, inspect.State.StartedAt); err == nil {
-			t = t.UTC()
-			started = &t
+		s.record(ctx, smp, live)
+	}
+	keep := append([]string{"host"}, seen...)
+	for _, id := range seen {
+		keep = append(keep, "cgroup:"+id)
+	}
+	for id := range s.inspected {
+		if !live[id] {
+			delete(s.inspected, id)
+		}
+	}
+	s.counters.retain(keep)
+	s.cgroups.retain(seen)
+	if err := s.repo.MarkMissingContainers(ctx, s.dockerHost, seen); err != nil {
+		s.log.Warn("mark missing containers", "err", err)
+		return
+	}
+	s.ticked()
+}
+
+// CollectStats samples the resource usage of the monitored containers.
+// Containers the last Inspect did not see, e.g. ones started since, are
+// inspected first.
+func (s *Service) CollectStats(ctx context.Context) {
+	defer supervise.Recover(s.log, "collector stats tick")
+	if s.inventoryOnly {
+		return
+	}
+	monitored, ok := s.list(ctx)
+	if !ok {
+		return
+	}
+	if s.streams != nil {
+		running := make([]string, 0, len(monitored))
+		for _, c := range monitored {
+			if c.State == "running" {
+				running = append(running, c.ID)
+			}
 		}
-		if err := s.repo.UpsertServiceAndContainer(ctx,
-			models.Service{ID: svcID, Name: serviceName, Image: c.Image, LabelsJSON: string(labelsJSON), Status: c.State},
-			models.Container{ID: c.ID, ServiceID: svcID, Name: strings.TrimPrefix(c.Names[0], "/"), Status: c.State, StartedAt: started, LastSeenAt: time.Now().UTC(), RestartCount: inspect.RestartCount},
-		); err != nil {
-			s.log.Error("upsert service/container", "id", c.ID, "err", err)
+		s.streams.reconcile(ctx, running)
+	}
+	var batch metricBatch
+	defer func() { s.writer.enqueue(batch) }()
+	listed := listedIDs(monitored)
+	// Docker calls run in parallel; database writes and rate tracking stay
+	// on this goroutine.
+	for _, smp := range s.sample(ctx, monitored, true) {
+		c := smp.container
+		if smp.err != nil {
+			s.log.Warn("inspect container", "id", c.ID, "err", smp.err)
 			continue
 		}
-		stats, err := s.dc.Stats(ctx, c.ID)
-		if err != nil {
-			s.log.Warn("container stats", "id", c.ID, "err", err)
+		if smp.inspected {
+			if !s.record(ctx, smp, listed) {
+				continue
+			}
+		} else {
+			smp.inspect = s.inspected[c.ID]
+		}
+		var m models.ContainerMetric
+		key := c.ID
+		if smp.statsErr == nil {
+			m = docker.NormalizeStats(c.ID, smp.stats)
+			m.TS = smp.ts
+		} else if cm, err := s.cgroups.read(c.ID, smp.inspect); err == nil {
+			// Cgroup counters differ from Docker's (no network), so they get
+			// their own rate baseline.
+			m, key = cm, "cgroup:"+c.ID
+		} else {
+			// Behind a socket proxy that refuses stats, containers are still
+			// tracked, just without metrics.
+			if !errors.Is(smp.statsErr, docker.ErrForbidden) {
+				s.log.Warn("container stats", "id", c.ID, "err", smp.statsErr)
+			}
 			continue
 		}
-		m := docker.NormalizeStats(c.ID, stats)
-		m.TS = time.Now().UTC()
-		if err := s.repo.InsertContainerMetric(ctx, m); err != nil {
-			s.log.Error("insert container metric", "id", c.ID, "err", err)
+		// Container PIDs are host PIDs, so only the local collector can look
+		// them up in /proc.
+		if s.host != nil && smp.inspect.State.Pid > 0 {
+			if n, limit, err := openFiles(smp.inspect.State.Pid); err == nil {
+				m.FDs, m.FDLimit = n, limit
+			}
 		}
+		r := s.counters.rates(key, m.TS, m.NetRXBytes, m.NetTXBytes, m.BlkReadBytes, m.BlkWriteBytes)
+		m.NetRXRate, m.NetTXRate, m.BlkReadRate, m.BlkWriteRate = r[0], r[1], r[2], r[3]
+		batch.containers = append(batch.containers, m)
 	}
-	if err := s.repo.MarkMissingContainers(ctx, seen); err != nil {
-		s.log.Warn("mark missing containers", "err", err)
+	s.ticked()
+}
+
+func (s *Service) ticked() {
+	if s.onTick != nil {
+		s.onTick()
+	}
+}
+
+// list returns the containers of the Docker host the filter allows.
+func (s *Service) list(ctx context.Context) ([]docker.ContainerSummary, bool) {
+	containers, err := s.dc.ListContainers(ctx)
+	if err != nil {
+		if !errors.Is(err, docker.ErrForbidden) {
+			s.log.Warn("list containers", "err", err)
+		}
+		return nil, false
+	}
+	monitored := containers[:0]
+	for _, c := range containers {
+		if len(c.Names) > 0 && s.filter.Allows(c.Names[0], c.Labels) {
+			monitored = append(monitored, c)
+		}
+	}
+	return monitored, true
+}
+
+func listedIDs(containers []docker.ContainerSummary) map[string]bool {
+	ids := make(map[string]bool, len(containers))
+	for _, c := range containers {
+		ids[c.ID] = true
+	}
+	return ids
+}
+
+// record upserts the service and container of an inspected sample, records
+// restarts and replacements since the previous inspection, and keeps the
+// inspect result for the stats samples until the next Inspect. listed are
+// the IDs of the containers the tick listed.
+func (s *Service) record(ctx context.Context, smp containerSample, listed map[string]bool) bool {
+	c := smp.container
+	prev, known, err := s.prevRun(ctx, c.ID)
+	if err != nil {
+		s.log.Warn("load container state", "id", c.ID, "err", err)
+	}
+	labelsJSON, _ := json.Marshal(c.Labels)
+	svcID := docker.ServiceID(s.dockerHost, c)
+	health := ""
+	if smp.inspect.State.Health != nil {
+		health = smp.inspect.State.Health.Status
+	}
+	var started *time.Time
+	if t, err := time.Parse(time.RFC3339Nano, smp.inspect.State.StartedAt); err == nil {
+		t = t.UTC()
+		started = &t
+	}
+	if err := s.repo.UpsertServiceAndContainer(ctx,
+		models.Service{ID: svcID, Host: s.dockerHost, Name: docker.ServiceName(c), Image: c.Image, LabelsJSON: string(labelsJSON), Status: c.State},
+		models.Container{ID: c.ID, ServiceID: svcID, Host: s.dockerHost, Name: strings.TrimPrefix(c.Names[0], "/"), Status: c.State, Health: health, StartedAt: started, LastSeenAt: time.Now().UTC(), RestartCount: smp.inspect.RestartCount,
+			Ports: smp.inspect.Ports(), Networks: smp.inspect.Networks(), Links: smp.inspect.Links()},
+	); err != nil {
+		s.log.Error("upsert service/container", "id", c.ID, "err", err)
+		return false
+	}
+	s.inspected[c.ID] = smp.inspect
+	// Without the previous state a restart cannot be told from a first start.
+	if err == nil {
+		s.checkRestart(ctx, smp, svcID, prev, known, listed)
+	}
+	return true
+}
+
+// prevRun returns what the last inspection, or after a restart of dashi the
+// stored row, knew of a container; known is false for new containers.
+func (s *Service) prevRun(ctx context.Context, id string) (st runState, known bool, err error) {
+	if in, ok := s.inspected[id]; ok {
+		return runStateOf(in), true, nil
+	}
+	c, err := s.repo.Container(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return runState{}, false, nil
+	}
+	if err != nil {
+		return runState{}, false, err
+	}
+	return runStateOfRow(c), true, nil
+}
+
+// checkRestart records a restart of a known container, or for a new one
+// the replacement of a container of its service that is no longer listed.
+func (s *Service) checkRestart(ctx context.Context, smp containerSample, svcID string, prev runState, known bool, listed map[string]bool) {
+	c := smp.container
+	name := strings.TrimPrefix(c.Names[0], "/")
+	now := time.Now().UTC()
+	var ev models.RestartEvent
+	if known {
+		var ok bool
+		if ev, ok = detectRestart(prev, runStateOf(smp.inspect)); !ok {
+			return
+		}
+	} else {
+		candidates, err := s.repo.ReplacementCandidates(ctx, svcID, s.dockerHost, c.ID, now.Add(-replaceWindow))
+		if err != nil {
+			s.log.Warn("load replaced containers", "id", c.ID, "err", err)
+			return
+		}
+		i := slices.IndexFunc(candidates, func(old models.Container) bool { return !listed[old.ID] })
+		if i < 0 {
+			return
+		}
+		ev = models.RestartEvent{Reason: "replaced", PrevContainerID: candidates[i].ID, RestartCount: smp.inspect.RestartCount}
+		if st := runStateOf(smp.inspect); !st.startedAt.IsZero() {
+			ev.StartedAt = utcTime(st.startedAt)
+		}
+	}
+	ev.ContainerID, ev.ServiceID, ev.Host, ev.Name, ev.DetectedAt = c.ID, svcID, s.dockerHost, name, now
+	s.log.Info("container restart detected", "container", name, "id", shortID(c.ID), "reason", ev.Reason, "prev_id", shortID(ev.PrevContainerID))
+	if err := s.repo.InsertRestartEvent(ctx, ev); err != nil {
+		s.log.Warn("record restart event", "id", c.ID, "err", err)
+	}
+}
+
+// checkReboot records a reboot when the uptime of hm does not continue the
+// previous sample.
+func (s *Service) checkReboot(ctx context.Context, hm models.HostMetric) {
+	if !s.prevHostRead {
+		s.prevHostRead = true
+		prev, err := s.repo.LatestHostMetric(ctx)
+		switch {
+		case err == nil:
+			s.prevHost = &prev
+		case !errors.Is(err, sql.ErrNoRows):
+			s.log.Warn("load last host metric", "err", err)
+		}
+	}
+	if s.prevHost != nil {
+		if rb, ok := detectReboot(*s.prevHost, hm); ok {
+			s.log.Info("host reboot detected", "booted_at", rb.BootedAt, "prev_uptime_sec", rb.PrevUptimeSec)
+			if err := s.repo.InsertHostReboot(ctx, rb); err != nil {
+				s.log.Warn("record host reboot", "err", err)
+			}
+		}
 	}
+	s.prevHost = &hm
 }
 
-func inferServiceName(c docker.ContainerSummary) string {
-	if v := c.Labels["com.docker.compose.service"]; v != "" {
-		return v
+// containerSample is the Docker state of one container for a tick. err is
+// set when inspecting failed; statsErr when only the stats call did.
+// inspected tells whether inspect is fresh from this tick.
+type containerSample struct {
+	container docker.ContainerSummary
+	inspect   docker.ContainerInspect
+	inspected bool
+	stats     docker.Stats
+	ts        time.Time
+	err       error
+	statsErr  error
+}
+
+// sample inspects containers, or with stats samples their usage and only
+// inspects those not inspected before, with a bounded worker pool. Samples
+// are returned in input order; containers still pending when the tick
+// deadline passes carry the context error.
+func (s *Service) sample(ctx context.Context, containers []docker.ContainerSummary, stats bool) []containerSample {
+	if s.deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.deadline)
+		defer cancel()
+	}
+	out := make([]containerSample, len(containers))
+	inspect := make([]bool, len(containers))
+	for i, c := range containers {
+		_, known := s.inspected[c.ID]
+		inspect[i] = !stats || !known
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(s.workers, len(containers)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// A panic is recovered so it costs one container's sample,
+				// not the process.
+				if !supervise.Call(s.log, "collector worker", func() {
+					out[i] = s.sampleOne(ctx, containers[i], inspect[i], stats)
+				}) {
+					out[i] = containerSample{container: containers[i], err: errors.New("sample panicked")}
+				}
+			}
+		}()
+	}
+	for i := range containers {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		skipped := 0
+		for _, smp := range out {
+			if errors.Is(smp.err, context.DeadlineExceeded) || errors.Is(smp.statsErr, context.DeadlineExceeded) {
+				skipped++
+			}
+		}
+		s.log.Warn("collector tick deadline exceeded", "deadline", s.deadline, "containers", len(containers), "skipped", skipped)
+	}
+	return out
+}
+
+func (s *Service) sampleOne(ctx context.Context, c docker.ContainerSummary, inspect, stats bool) containerSample {
+	smp := containerSample{container: c, inspected: inspect}
+	if inspect {
+		if smp.inspect, smp.err = s.dc.InspectContainer(ctx, c.ID); smp.err != nil {
+			return smp
+		}
 	}
-	if len(c.Names) > 0 {
-		return strings.TrimPrefix(c.Names[0], "/")
+	if !stats {
+		return smp
 	}
-	if len(c.ID) >= 12 {
-		return c.ID[:12]
+	if s.streams != nil {
+		if st, ts, ok := s.streams.latest(c.ID); ok {
+			smp.stats, smp.ts = st, ts
+			return smp
+		}
 	}
-	return c.ID
+	smp.stats, smp.statsErr = s.dc.Stats(ctx, c.ID)
+	smp.ts = time.Now().UTC()
+	return smp
 }
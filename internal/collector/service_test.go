@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dashi/internal/docker"
+)
+
+func TestSampleRunsConcurrentlyWithinDeadline(t *testing.T) {
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			_, _ = io.WriteString(w, `{"Id":"x","RestartCount":1}`)
+		case strings.HasSuffix(r.URL.Path, "/stats"):
+			if strings.Contains(r.URL.Path, "slow") {
+				<-r.Context().Done()
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+			_, _ = io.WriteString(w, `{"memory_stats":{"usage":1}}`)
+		}
+	}))
+	defer daemon.Close()
+	dc, err := docker.Dial("tcp://"+strings.TrimPrefix(daemon.URL, "http://"), "")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	s := &Service{dc: dc, log: slog.New(slog.NewTextHandler(io.Discard, nil)), workers: 8, deadline: 500 * time.Millisecond, inspected: map[string]docker.ContainerInspect{}}
+
+	var containers []docker.ContainerSummary
+	for i := range 8 {
+		containers = append(containers, docker.ContainerSummary{ID: fmt.Sprintf("c%d", i)})
+	}
+	containers = append(containers, docker.ContainerSummary{ID: "slow"})
+
+	start := time.Now()
+	out := s.sample(context.Background(), containers, true)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("sample took %s, want bounded by the deadline", elapsed)
+	}
+	for i, smp := range out[:8] {
+		if smp.container.ID != containers[i].ID || smp.err != nil || smp.statsErr != nil || smp.stats.MemoryStats.Usage != 1 {
+			t.Fatalf("sample %d = %+v", i, smp)
+		}
+	}
+	if out[8].statsErr == nil {
+		t.Fatal("slow container sampled despite deadline")
+	}
+}
+
+func TestInventoryOnlySkipsStats(t *testing.T) {
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/stats") {
+			t.Errorf("stats requested with metrics disabled: %s", r.URL.Path)
+		}
+		_, _ = io.WriteString(w, `{"Id":"x","RestartCount":2}`)
+	}))
+	defer daemon.Close()
+	dc, err := docker.Dial("tcp://"+strings.TrimPrefix(daemon.URL, "http://"), "")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	s := NewService(nil, dc, slog.New(slog.NewTextHandler(io.Discard, nil)), nil, docker.LocalHost, Options{StreamStats: true, CgroupRoot: "/sys/fs/cgroup", InventoryOnly: true})
+	defer s.Close(context.Background())
+	if s.host != nil || s.streams != nil || s.cgroups != nil {
+		t.Fatal("metric sources set up with metrics disabled")
+	}
+	smp := s.sampleOne(context.Background(), docker.ContainerSummary{ID: "x"}, true, false)
+	if smp.err != nil || smp.inspect.RestartCount != 2 {
+		t.Fatalf("sample = %+v", smp)
+	}
+}
+
+func TestStatsReuseInspectUntilNextInspect(t *testing.T) {
+	var inspects atomic.Int32
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/stats"):
+			_, _ = io.WriteString(w, `{"memory_stats":{"usage":1}}`)
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			inspects.Add(1)
+			_, _ = io.WriteString(w, `{"Id":"x","RestartCount":3}`)
+		}
+	}))
+	defer daemon.Close()
+	dc, err := docker.Dial("tcp://"+strings.TrimPrefix(daemon.URL, "http://"), "")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	s := &Service{dc: dc, log: slog.New(slog.NewTextHandler(io.Discard, nil)), workers: 2, deadline: time.Second, inspected: map[string]docker.ContainerInspect{}}
+	containers := []docker.ContainerSummary{{ID: "a"}, {ID: "b"}}
+
+	// Containers no inspect has seen yet are inspected with their stats.
+	out := s.sample(context.Background(), containers, true)
+	if inspects.Load() != 2 || !out[0].inspected || out[0].inspect.RestartCount != 3 || out[0].stats.MemoryStats.Usage != 1 {
+		t.Fatalf("first sample = %+v after %d inspects", out[0], inspects.Load())
+	}
+	s.inspected["a"] = out[0].inspect
+	out = s.sample(context.Background(), containers, true)
+	if inspects.Load() != 3 || out[0].inspected || !out[1].inspected || out[0].stats.MemoryStats.Usage != 1 {
+		t.Fatalf("second sample = %+v after %d inspects", out, inspects.Load())
+	}
+	// An inspect pass inspects every container again and takes no stats.
+	out = s.sample(context.Background(), containers, false)
+	if inspects.Load() != 5 || out[0].stats.MemoryStats.Usage != 0 {
+		t.Fatalf("inspect pass = %+v after %d inspects", out, inspects.Load())
+	}
+}
+
+func TestStatsStreamsKeepLatestFrame(t *testing.T) {
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("stream") != "true" {
+			http.Error(w, "want stream", http.StatusBadRequest)
+			return
+		}
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, `{"memory_stats":{"usage":%d}}`+"\n", i)
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer daemon.Close()
+	dc, err := docker.Dial("tcp://"+strings.TrimPrefix(daemon.URL, "http://"), "")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	streams := newStatsStreams(dc, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer streams.stop()
+
+	streams.reconcile(context.Background(), []string{"c1"})
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		st, _, ok := streams.latest("c1")
+		if ok && st.MemoryStats.Usage == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("latest = %+v, %v; want the third frame", st.MemoryStats, ok)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	streams.reconcile(context.Background(), nil)
+	if _, _, ok := streams.latest("c1"); ok {
+		t.Fatal("stream kept after container stopped running")
+	}
+}
@@ -0,0 +1,135 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"dashi/internal/docker"
+)
+
+// streamStale is how old the last streamed frame may be before a tick falls
+// back to a one-shot stats call; Docker sends a frame every second.
+const streamStale = 15 * time.Second
+
+// statsStreams keeps one streaming stats reader per running container, like
+// the log workers, so ticks read the latest frame instead of issuing a
+// blocking one-shot stats request per container.
+type statsStreams struct {
+	dc  *docker.Client
+	log *slog.Logger
+
+	mu      sync.Mutex
+	workers map[string]*statsStream
+	wg      sync.WaitGroup
+}
+
+type statsStream struct {
+	cancel context.CancelFunc
+
+	mu    sync.Mutex
+	stats docker.Stats
+	ts    time.Time
+}
+
+func newStatsStreams(dc *docker.Client, logger *slog.Logger) *statsStreams {
+	return &statsStreams{dc: dc, log: logger, workers: map[string]*statsStream{}}
+}
+
+// reconcile starts readers for running containers and stops the others.
+func (s *statsStreams) reconcile(parent context.Context, running []string) {
+	live := make(map[string]bool, len(running))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range running {
+		live[id] = true
+		if _, ok := s.workers[id]; ok {
+			continue
+		}
+		ctx, cancel := context.WithCancel(parent)
+		w := &statsStream{cancel: cancel}
+		s.workers[id] = w
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.run(ctx, id, w)
+		}()
+	}
+	for id, w := range s.workers {
+		if !live[id] {
+			w.cancel()
+			delete(s.workers, id)
+		}
+	}
+}
+
+// latest returns the newest frame of a container if it is recent enough.
+func (s *statsStreams) latest(id string) (docker.Stats, time.Time, bool) {
+	s.mu.Lock()
+	w, ok := s.workers[id]
+	s.mu.Unlock()
+	if !ok {
+		return docker.Stats{}, time.Time{}, false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ts.IsZero() || time.Since(w.ts) > streamStale {
+		return docker.Stats{}, time.Time{}, false
+	}
+	return w.stats, w.ts, true
+}
+
+// stop cancels all readers and waits for them to exit.
+func (s *statsStreams) stop() {
+	s.mu.Lock()
+	for id, w := range s.workers {
+		w.cancel()
+		delete(s.workers, id)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *statsStreams) run(ctx context.Context, id string, w *statsStream) {
+	first := true
+	for ctx.Err() == nil {
+		rc, err := s.dc.StatsStream(ctx, id)
+		if errors.Is(err, docker.ErrForbidden) {
+			sleepCtx(ctx, time.Minute)
+			continue
+		}
+		if err != nil {
+			s.log.Warn("open stats stream", "container", id, "err", err)
+			sleepCtx(ctx, 5*time.Second)
+			continue
+		}
+		err = docker.DecodeStats(rc, func(st docker.Stats) error {
+			// The first frame of a stream has no previous CPU sample.
+			if first {
+				first = false
+				return nil
+			}
+			w.mu.Lock()
+			w.stats, w.ts = st, time.Now().UTC()
+			w.mu.Unlock()
+			return nil
+		})
+		_ = rc.Close()
+		if err != nil && ctx.Err() == nil {
+			s.log.Warn("read stats stream", "container", id, "err", err)
+		}
+		first = true
+		sleepCtx(ctx, time.Second)
+	}
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
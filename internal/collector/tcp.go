@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// tcpCounts are sockets by state across IPv4 and IPv6.
+type tcpCounts struct {
+	established, synRecv, timeWait, closeWait, listen, total int64
+}
+
+// readTCP counts sockets in /proc/net/tcp and tcp6. Those files only list
+// the current network namespace, so in a container they describe the host
+// only with network_mode: host.
+func readTCP() (tcpCounts, error) {
+	var c tcpCounts
+	found := false
+	for _, p := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(p)
+		if err != nil {
+			continue
+		}
+		err = c.parse(f)
+		f.Close()
+		if err != nil {
+			return tcpCounts{}, err
+		}
+		found = true
+	}
+	if !found {
+		return tcpCounts{}, os.ErrNotExist
+	}
+	return c, nil
+}
+
+// parse adds the sockets of one /proc/net/tcp{,6} table. The fourth column
+// is the state as in include/net/tcp_states.h.
+func (c *tcpCounts) parse(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	sc.Scan() // header
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) < 4 {
+			continue
+		}
+		switch f[3] {
+		case "01":
+			c.established++
+		case "03":
+			c.synRecv++
+		case "06":
+			c.timeWait++
+		case "08":
+			c.closeWait++
+		case "0A":
+			c.listen++
+			continue
+		}
+		c.total++
+	}
+	return sc.Err()
+}
@@ -0,0 +1,31 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTCPCountsParse(t *testing.T) {
+	tcp := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 20561 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 31872 1 0000000000000000 100 0 0 10 0
+   2: 0F02000A:0016 0202000A:C2B4 01 00000000:00000000 02:0009A7B3 00000000     0        0 40114 4 0000000000000000 20 4 29 10 -1
+   3: 0F02000A:0050 0302000A:D1C0 06 00000000:00000000 03:00000F91 00000000     0        0 0 3 0000000000000000
+`
+	tcp6 := `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0016 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 20563 1 0000000000000000 100 0 0 10 0
+   1: 0000000000000000FFFF00000F02000A:01BB 0000000000000000FFFF00000302000A:E1A2 03 00000000:00000000 01:00000064 00000000     0        0 0 0 0000000000000000
+   2: 0000000000000000FFFF00000F02000A:01BB 0000000000000000FFFF00000402000A:E1A4 01 00000000:00000000 00:00000000 00000000     0        0 41000 1 0000000000000000 20 4 30 10 -1
+`
+	var c tcpCounts
+	if err := c.parse(strings.NewReader(tcp)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.parse(strings.NewReader(tcp6)); err != nil {
+		t.Fatal(err)
+	}
+	want := tcpCounts{established: 2, synRecv: 1, timeWait: 1, listen: 3, total: 4}
+	if c != want {
+		t.Fatalf("counts = %+v, want %+v", c, want)
+	}
+}
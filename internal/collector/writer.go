@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+type metricBatch struct {
+	hosts      []models.HostMetric
+	containers []models.ContainerMetric
+}
+
+// writer persists metric batches off the collection path. Batches queued
+// while a write is in flight are coalesced into the next transaction.
+type writer struct {
+	repo  *db.Repository
+	log   *slog.Logger
+	queue chan metricBatch
+	done  chan struct{}
+}
+
+func newWriter(repo *db.Repository, logger *slog.Logger, size int) *writer {
+	return &writer{repo: repo, log: logger, queue: make(chan metricBatch, size), done: make(chan struct{})}
+}
+
+// enqueue never blocks the collector; when the queue is full the batch is
+// dropped, since the next tick produces fresh samples anyway.
+func (w *writer) enqueue(b metricBatch) {
+	select {
+	case w.queue <- b:
+	default:
+		w.log.Warn("metric write queue full, dropping batch", "hosts", len(b.hosts), "containers", len(b.containers))
+	}
+}
+
+func (w *writer) run() {
+	defer close(w.done)
+	for b := range w.queue {
+	drain:
+		for {
+			select {
+			case next, ok := <-w.queue:
+				if !ok {
+					break drain
+				}
+				b.hosts = append(b.hosts, next.hosts...)
+				b.containers = append(b.containers, next.containers...)
+			default:
+				break drain
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := w.repo.InsertMetricsBatch(ctx, b.hosts, b.containers); err != nil {
+			w.log.Error("insert metric batch", "err", err, "hosts", len(b.hosts), "containers", len(b.containers))
+		}
+		cancel()
+	}
+}
+
+// close stops accepting batches and waits for queued ones to be written.
+func (w *writer) close(ctx context.Context) error {
+	close(w.queue)
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
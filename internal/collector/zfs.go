@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// readZFSPools lists the pools with the zpool command at path and reads
+// each pool's status.
+func readZFSPools(ctx context.Context, path string, now time.Time) ([]models.StoragePool, error) {
+	out, err := exec.CommandContext(ctx, path, "list", "-Hp", "-o", "name,size,allocated,health").Output()
+	if err != nil {
+		return nil, fmt.Errorf("zpool list: %w", err)
+	}
+	pools := parseZpoolList(string(out))
+	for i := range pools {
+		pools[i].CheckedAt = now
+		status, err := exec.CommandContext(ctx, path, "status", "-p", pools[i].Name).Output()
+		if err != nil {
+			return nil, fmt.Errorf("zpool status %s: %w", pools[i].Name, err)
+		}
+		parseZpoolStatus(string(status), &pools[i])
+	}
+	return pools, nil
+}
+
+// parseZpoolList parses `zpool list -Hp -o name,size,allocated,health`.
+func parseZpoolList(out string) []models.StoragePool {
+	var pools []models.StoragePool
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		f := strings.Split(line, "\t")
+		if len(f) != 4 {
+			continue
+		}
+		size, _ := strconv.ParseInt(f[1], 10, 64)
+		alloc, _ := strconv.ParseInt(f[2], 10, 64)
+		pools = append(pools, models.StoragePool{Type: "zfs", Name: f[0], Health: f[3], SizeBytes: size, AllocBytes: alloc})
+	}
+	return pools
+}
+
+// parseZpoolStatus reads the scan line, the device error counters and the
+// data error count of `zpool status -p <pool>` into p. Device errors are
+// summed over leaf devices only, since mirror and raidz rows repeat the
+// errors of their children.
+func parseZpoolStatus(out string, p *models.StoragePool) {
+	type vdev struct {
+		depth  int
+		errors int64
+	}
+	var vdevs []vdev
+	inConfig := false
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		line := sc.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "scan:"):
+			parseZpoolScan(strings.TrimSpace(strings.TrimPrefix(trimmed, "scan:")), p)
+		case strings.HasPrefix(trimmed, "config:"):
+			inConfig = true
+		case strings.HasPrefix(trimmed, "errors:"):
+			inConfig = false
+			if f := strings.Fields(strings.TrimPrefix(trimmed, "errors:")); len(f) > 0 {
+				p.DataErrors, _ = strconv.ParseInt(f[0], 10, 64)
+			}
+		case inConfig && trimmed != "" && !strings.HasPrefix(trimmed, "NAME"):
+			f := strings.Fields(trimmed)
+			if len(f) < 5 {
+				continue
+			}
+			var n int64
+			for _, c := range f[2:5] {
+				v, _ := strconv.ParseInt(c, 10, 64)
+				n += v
+			}
+			vdevs = append(vdevs, vdev{depth: len(line) - len(strings.TrimLeft(line, " \t")), errors: n})
+		}
+	}
+	for i, v := range vdevs {
+		if i+1 == len(vdevs) || vdevs[i+1].depth <= v.depth {
+			p.DeviceErrors += v.errors
+		}
+	}
+}
+
+// parseZpoolScan reads the scan line, e.g. "scrub repaired 0B in 00:10:32
+// with 0 errors on Sun Feb  8 00:34:33 2026".
+func parseZpoolScan(scan string, p *models.StoragePool) {
+	switch {
+	case strings.HasPrefix(scan, "scrub in progress"):
+		p.ScrubState = "scrubbing"
+	case strings.HasPrefix(scan, "scrub canceled"):
+		p.ScrubState = "canceled"
+	case strings.HasPrefix(scan, "scrub repaired"):
+		p.ScrubState = "finished"
+		if _, rest, ok := strings.Cut(scan, " with "); ok {
+			if f := strings.Fields(rest); len(f) > 0 {
+				p.ScrubErrors, _ = strconv.ParseInt(f[0], 10, 64)
+			}
+		}
+		if _, when, ok := strings.Cut(scan, " on "); ok {
+			if t, err := time.ParseInLocation(time.ANSIC, strings.TrimSpace(when), time.Local); err == nil {
+				t = t.UTC()
+				p.ScrubAt = &t
+			}
+		}
+	default:
+		// "none requested", or a resilver, which is not a scrub.
+		p.ScrubState = "none"
+	}
+}
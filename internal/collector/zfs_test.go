@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseZpool(t *testing.T) {
+	pools := parseZpoolList("tank\t3985729650688\t1099511627776\tDEGRADED\nfast\t498216206336\t1024\tONLINE\n")
+	if len(pools) != 2 || pools[0].Name != "tank" || pools[0].Health != "DEGRADED" || pools[0].AllocBytes != 1<<40 {
+		t.Fatalf("pools = %+v", pools)
+	}
+
+	status := `  pool: tank
+ state: DEGRADED
+status: One or more devices could not be used because the label is missing or
+	invalid.
+  scan: scrub repaired 0B in 00:10:32 with 3 errors on Sun Feb  8 00:34:33 2026
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	tank        DEGRADED     0     0     0
+	  mirror-0  DEGRADED     0     0     5
+	    sda     ONLINE       0     0     5
+	    sdb     UNAVAIL      2     0     0  cannot open
+	  sdc       ONLINE       0     1     0
+
+errors: 2 data errors, use '-v' for a list
+`
+	p := &pools[0]
+	parseZpoolStatus(status, p)
+	if p.ScrubState != "finished" || p.ScrubErrors != 3 || p.ScrubAt == nil {
+		t.Fatalf("scrub = %q %d %v", p.ScrubState, p.ScrubErrors, p.ScrubAt)
+	}
+	if want := time.Date(2026, 2, 8, 0, 34, 33, 0, time.Local); !p.ScrubAt.Equal(want) {
+		t.Fatalf("scrub at = %v, want %v", p.ScrubAt, want)
+	}
+	if p.DeviceErrors != 8 || p.DataErrors != 2 {
+		t.Fatalf("device errors = %d, data errors = %d", p.DeviceErrors, p.DataErrors)
+	}
+
+	p = &pools[1]
+	parseZpoolStatus("  pool: fast\n state: ONLINE\n  scan: none requested\nconfig:\n\n\tNAME STATE READ WRITE CKSUM\n\tfast ONLINE 0 0 0\n\nerrors: No known data errors\n", p)
+	if p.ScrubState != "none" || p.DeviceErrors != 0 || p.DataErrors != 0 {
+		t.Fatalf("fast = %+v", *p)
+	}
+}
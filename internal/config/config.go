@@ -1,85 +1,441 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// Deployment modes: a server stores and shows data; an agent only collects
+// metrics and logs of its Docker host and pushes them to a server.
+const (
+	ModeServer = "server"
+	ModeAgent  = "agent"
+)
+
 type Config struct {
+	Mode           string
+	AgentServer    string
+	AgentToken     string
+	AgentName      string
+	AgentPushEvery time.Duration
+	// DemoMode replaces Docker with a generated fleet.
+	DemoMode bool
+	// Subsystems can be switched off, e.g. where logs are shipped
+	// elsewhere; Disabled lists those that are.
+	LogsEnabled      bool
+	AlertsEnabled    bool
+	MetricsEnabled   bool
 	Addr             string
 	DataDir          string
 	DBPath           string
+	LogsDBPath       string
+	DBDriver         string
+	DBURL            string
+	IntegrityCheck   string
 	DockerSocket     string
+	DockerHosts      []string
+	DockerCertDir    string
+	DockerEvents     bool
+	ImageCheckEvery  time.Duration
+	RegistryAuth     []string
+	DiskUsageEvery   time.Duration
+	PoolCheckEvery   time.Duration
+	ClockCheckEvery  time.Duration
+	NTPServer        string
+	PruneEnabled     bool
+	MonitorLabels    string
+	MonitorInclude   string
+	MonitorExclude   string
 	MetricsInterval  time.Duration
+	HostMetricsEvery time.Duration
+	StatsEvery       time.Duration
+	InspectEvery     time.Duration
+	CollectWorkers   int
+	StatsStream      bool
+	CgroupRoot       string
 	RulesInterval    time.Duration
+	ShutdownTimeout  time.Duration
+	MaintenanceEvery time.Duration
+	WALMaxMB         int
+	VacuumPages      int
+	BackupDir        string
+	BackupInterval   time.Duration
+	BackupKeep       int
+	ReplicaEndpoint  string
+	ReplicaRegion    string
+	ReplicaBucket    string
+	ReplicaPrefix    string
+	ReplicaAccessKey string
+	ReplicaSecretKey string
+	ReplicaInterval  time.Duration
+	ReplicaRestore   bool
+	LogsS3Endpoint   string
+	LogsS3Region     string
+	LogsS3Bucket     string
+	LogsS3Prefix     string
+	LogsS3AccessKey  string
+	LogsS3SecretKey  string
 	RetentionDays    int
+	LogRetentionDays int
+	MetricsDays      int
+	RollupDays       int
+	AlertsDays       int
+	ArchiveAfter     time.Duration
 	DebugRestarts    bool
 	SkipSelfLogs     bool
+	LogDedupWindow   time.Duration
+	LogColors        bool
+	LogRateLimit     int
+	LogSampleEvery   int
+	LogSpillMaxMB    int
+	LogQueueSize     int
+	LogBatchSize     int
+	LogMemoryMB      int
+	GELFUDPAddr      string
+	GELFHTTPAddr     string
+	DebugAddr        string
+	IngestToken      string
+	AgentTokens      []string
+	FederationPeers  []string
+	FederationEvery  time.Duration
+	ScrapeEvery      time.Duration
+	HealthcheckURL   string
+	LogFiles         []string
+	LogForwardURL    string
+	LogForwardFormat string
+	LogForwardToken  string
+	LogForwardTenant string
 	TelegramBotToken string
 	TelegramChatID   string
+	// AlertmanagerURLs are the Alertmanagers alerts are forwarded to every
+	// AlertSendEvery, with the "name=value" AlertLabels added.
+	AlertmanagerURLs []string
+	AlertLabels      []string
+	AlertSendEvery   time.Duration
+	CORSOrigins      []string
+	CORSMethods      []string
+	CSP              string
+	CSPScriptSrc     []string
+	HSTSMaxAge       time.Duration
+	FrameOptions     string
+	ReferrerPolicy   string
+	ConfigFile       string
+	SecretKey        string
+	// Warnings are problems Load found that leave part of dashi without
+	// function, such as a missing Docker socket. dashi starts anyway, in a
+	// degraded mode the UI and /api/v1/health report, unless
+	// APP_STRICT_CONFIG is set.
+	Warnings []string
+}
+
+// secretVars are the variables that may instead name a file holding their
+// value in <name>_FILE, as Docker and Kubernetes secrets are mounted.
+var secretVars = []string{
+	"APP_DB_URL",
+	"APP_REGISTRY_AUTH",
+	"APP_REPLICA_S3_ACCESS_KEY",
+	"APP_REPLICA_S3_SECRET_KEY",
+	"APP_LOG_ARCHIVE_S3_ACCESS_KEY",
+	"APP_LOG_ARCHIVE_S3_SECRET_KEY",
+	"APP_INGEST_TOKEN",
+	"APP_AGENT_TOKENS",
+	"APP_FEDERATION_PEERS",
+	"APP_LOG_FORWARD_TOKEN",
+	"APP_HEALTHCHECK_URL",
+	"APP_AGENT_TOKEN",
+	"APP_SECRET_KEY",
+	"TELEGRAM_BOT_TOKEN",
+	"TELEGRAM_CHAT_ID",
+	"APP_ALERTMANAGER_URLS",
 }
 
-func Load() Config {
-	dataDir := getenv("APP_DATA_DIR", "./data")
-	retention := getenvInt("APP_RETENTION_DAYS", 14)
-	return Config{
-		Addr:             getenv("APP_ADDR", ":8080"),
+// Load reads the configuration from the environment. It fails, listing
+// every problem, when a value does not parse or is out of range, or a
+// *_FILE variable names a file that cannot be read.
+func Load() (Config, error) {
+	secrets, err := readSecretFiles()
+	if err != nil {
+		return Config{}, err
+	}
+	e := &env{read: map[string]bool{}}
+	secret := func(k string) string {
+		e.read[k+"_FILE"] = true
+		if v := e.str(k, ""); v != "" {
+			return v
+		}
+		return secrets[k]
+	}
+	dataDir := e.str("APP_DATA_DIR", "./data")
+	retention := e.int("APP_RETENTION_DAYS", 14)
+	metricsEvery := e.duration("APP_METRICS_INTERVAL", 10*time.Second)
+	hostname, _ := os.Hostname()
+	c := Config{
+		Mode:             strings.ToLower(e.str("APP_MODE", ModeServer)),
+		AgentServer:      e.str("APP_AGENT_SERVER", ""),
+		AgentToken:       secret("APP_AGENT_TOKEN"),
+		AgentName:        e.str("APP_AGENT_NAME", strings.ToLower(strings.Split(hostname, ".")[0])),
+		AgentPushEvery:   e.duration("APP_AGENT_PUSH_INTERVAL", 10*time.Second),
+		DemoMode:         e.bool("APP_DEMO_MODE", false),
+		LogsEnabled:      e.bool("APP_LOGS_ENABLED", true),
+		AlertsEnabled:    e.bool("APP_ALERTS_ENABLED", true),
+		MetricsEnabled:   e.bool("APP_METRICS_ENABLED", true),
+		Addr:             e.str("APP_ADDR", ":8080"),
 		DataDir:          dataDir,
-		DBPath:           getenv("APP_DB_PATH", dataDir+"/app.db"),
-		DockerSocket:     getenv("DOCKER_SOCKET", "/var/run/docker.sock"),
-		MetricsInterval:  getenvDuration("APP_METRICS_INTERVAL", 10*time.Second),
-		RulesInterval:    getenvDuration("APP_RULES_INTERVAL", 15*time.Second),
+		DBPath:           e.str("APP_DB_PATH", dataDir+"/app.db"),
+		LogsDBPath:       e.str("APP_LOGS_DB_PATH", ""),
+		DBDriver:         strings.ToLower(e.str("APP_DB_DRIVER", "sqlite")),
+		DBURL:            secret("APP_DB_URL"),
+		IntegrityCheck:   strings.ToLower(e.str("APP_DB_INTEGRITY_CHECK", "quick")),
+		DockerSocket:     e.str("DOCKER_SOCKET", "/var/run/docker.sock"),
+		DockerHosts:      e.list("APP_DOCKER_HOSTS", nil),
+		DockerCertDir:    e.str("APP_DOCKER_CERT_DIR", ""),
+		DockerEvents:     e.bool("APP_DOCKER_EVENTS", true),
+		ImageCheckEvery:  e.optionalDuration("APP_IMAGE_CHECK_INTERVAL", 6*time.Hour),
+		RegistryAuth:     splitList(secret("APP_REGISTRY_AUTH")),
+		DiskUsageEvery:   e.optionalDuration("APP_DISK_USAGE_INTERVAL", 30*time.Minute),
+		PoolCheckEvery:   e.optionalDuration("APP_POOL_CHECK_INTERVAL", 5*time.Minute),
+		ClockCheckEvery:  e.optionalDuration("APP_CLOCK_CHECK_INTERVAL", 15*time.Minute),
+		NTPServer:        e.str("APP_NTP_SERVER", "pool.ntp.org"),
+		PruneEnabled:     e.bool("APP_PRUNE_ENABLED", false),
+		MonitorLabels:    e.str("APP_MONITOR_LABELS", ""),
+		MonitorInclude:   e.str("APP_MONITOR_INCLUDE", ""),
+		MonitorExclude:   e.str("APP_MONITOR_EXCLUDE", ""),
+		MetricsInterval:  metricsEvery,
+		HostMetricsEvery: e.duration("APP_HOST_METRICS_INTERVAL", metricsEvery),
+		StatsEvery:       e.duration("APP_CONTAINER_STATS_INTERVAL", metricsEvery),
+		InspectEvery:     e.duration("APP_INSPECT_INTERVAL", 30*time.Second),
+		CollectWorkers:   e.int("APP_COLLECT_WORKERS", 8),
+		StatsStream:      e.bool("APP_STATS_STREAM", true),
+		CgroupRoot:       e.str("APP_CGROUP_ROOT", "/sys/fs/cgroup"),
+		RulesInterval:    e.duration("APP_RULES_INTERVAL", 15*time.Second),
+		ShutdownTimeout:  e.duration("APP_SHUTDOWN_TIMEOUT", 15*time.Second),
+		MaintenanceEvery: e.duration("APP_MAINTENANCE_INTERVAL", time.Hour),
+		WALMaxMB:         e.int("APP_WAL_MAX_MB", 64),
+		VacuumPages:      e.int("APP_VACUUM_PAGES", 4096),
+		BackupDir:        e.str("APP_BACKUP_DIR", ""),
+		BackupInterval:   e.duration("APP_BACKUP_INTERVAL", 24*time.Hour),
+		BackupKeep:       e.int("APP_BACKUP_KEEP", 7),
+		ReplicaEndpoint:  e.str("APP_REPLICA_S3_ENDPOINT", ""),
+		ReplicaRegion:    e.str("APP_REPLICA_S3_REGION", "us-east-1"),
+		ReplicaBucket:    e.str("APP_REPLICA_S3_BUCKET", ""),
+		ReplicaPrefix:    e.str("APP_REPLICA_S3_PREFIX", "dashi"),
+		ReplicaAccessKey: secret("APP_REPLICA_S3_ACCESS_KEY"),
+		ReplicaSecretKey: secret("APP_REPLICA_S3_SECRET_KEY"),
+		ReplicaInterval:  e.duration("APP_REPLICA_INTERVAL", time.Hour),
+		ReplicaRestore:   e.bool("APP_REPLICA_RESTORE", true),
+		LogsS3Endpoint:   e.str("APP_LOG_ARCHIVE_S3_ENDPOINT", ""),
+		LogsS3Region:     e.str("APP_LOG_ARCHIVE_S3_REGION", "us-east-1"),
+		LogsS3Bucket:     e.str("APP_LOG_ARCHIVE_S3_BUCKET", ""),
+		LogsS3Prefix:     e.str("APP_LOG_ARCHIVE_S3_PREFIX", "dashi/logs"),
+		LogsS3AccessKey:  secret("APP_LOG_ARCHIVE_S3_ACCESS_KEY"),
+		LogsS3SecretKey:  secret("APP_LOG_ARCHIVE_S3_SECRET_KEY"),
 		RetentionDays:    retention,
-		DebugRestarts:    getenvBool("APP_DEBUG_RESTART_ALERTS", false),
-		SkipSelfLogs:     getenvBool("APP_SKIP_SELF_LOGS", true),
-		TelegramBotToken: os.Getenv("TELEGRAM_BOT_TOKEN"),
-		TelegramChatID:   os.Getenv("TELEGRAM_CHAT_ID"),
+		LogRetentionDays: e.int("APP_LOG_RETENTION_DAYS", retention),
+		MetricsDays:      e.int("APP_METRICS_RETENTION_DAYS", retention),
+		RollupDays:       e.int("APP_ROLLUP_RETENTION_DAYS", 365),
+		AlertsDays:       e.int("APP_ALERT_RETENTION_DAYS", retention),
+		ArchiveAfter:     e.duration("APP_CONTAINER_ARCHIVE_AFTER", 7*24*time.Hour),
+		DebugRestarts:    e.bool("APP_DEBUG_RESTART_ALERTS", false),
+		SkipSelfLogs:     e.bool("APP_SKIP_SELF_LOGS", true),
+		LogDedupWindow:   e.logDedupWindow(),
+		LogColors:        e.bool("APP_LOG_COLORS", false),
+		LogRateLimit:     e.int("APP_LOG_RATE_LIMIT", 500),
+		LogSampleEvery:   e.int("APP_LOG_SAMPLE_EVERY", 100),
+		LogSpillMaxMB:    e.int("APP_LOG_SPILL_MAX_MB", 256),
+		LogQueueSize:     e.int("APP_LOG_QUEUE_SIZE", 256),
+		LogBatchSize:     e.int("APP_LOG_BATCH_SIZE", 200),
+		LogMemoryMB:      e.int("APP_LOG_MEMORY_MB", 64),
+		GELFUDPAddr:      e.str("APP_GELF_UDP_ADDR", ""),
+		GELFHTTPAddr:     e.str("APP_GELF_HTTP_ADDR", ""),
+		DebugAddr:        e.str("APP_DEBUG_ADDR", ""),
+		IngestToken:      secret("APP_INGEST_TOKEN"),
+		AgentTokens:      splitList(secret("APP_AGENT_TOKENS")),
+		FederationPeers:  splitList(secret("APP_FEDERATION_PEERS")),
+		FederationEvery:  e.duration("APP_FEDERATION_INTERVAL", time.Minute),
+		ScrapeEvery:      e.duration("APP_SCRAPE_INTERVAL", 30*time.Second),
+		HealthcheckURL:   secret("APP_HEALTHCHECK_URL"),
+		LogFiles:         e.list("APP_LOG_FILES", nil),
+		LogForwardURL:    e.str("APP_LOG_FORWARD_URL", ""),
+		LogForwardFormat: e.str("APP_LOG_FORWARD_FORMAT", "loki"),
+		LogForwardToken:  secret("APP_LOG_FORWARD_TOKEN"),
+		LogForwardTenant: e.str("APP_LOG_FORWARD_TENANT", ""),
+		TelegramBotToken: secret("TELEGRAM_BOT_TOKEN"),
+		TelegramChatID:   secret("TELEGRAM_CHAT_ID"),
+		AlertmanagerURLs: splitList(secret("APP_ALERTMANAGER_URLS")),
+		AlertLabels:      e.list("APP_ALERTMANAGER_LABELS", nil),
+		AlertSendEvery:   e.duration("APP_ALERTMANAGER_INTERVAL", time.Minute),
+		CORSOrigins:      e.list("APP_CORS_ORIGINS", nil),
+		CORSMethods:      e.list("APP_CORS_METHODS", []string{"GET", "POST", "OPTIONS"}),
+		CSP:              e.str("APP_CSP", ""),
+		CSPScriptSrc:     e.list("APP_CSP_SCRIPT_SRC", nil),
+		HSTSMaxAge:       e.optionalDuration("APP_HSTS_MAX_AGE", 0),
+		FrameOptions:     e.str("APP_FRAME_OPTIONS", "DENY"),
+		ReferrerPolicy:   e.str("APP_REFERRER_POLICY", "same-origin"),
+		ConfigFile:       e.str("APP_CONFIG_FILE", ""),
+		SecretKey:        secret("APP_SECRET_KEY"),
+	}
+	strict := e.bool("APP_STRICT_CONFIG", false)
+	errs := append(e.errs, c.validate()...)
+	c.Warnings = append(c.Warnings, e.unknown()...)
+	if strict {
+		for _, w := range c.Warnings {
+			errs = append(errs, errors.New(w))
+		}
+	}
+	if len(errs) > 0 {
+		return Config{}, errors.Join(errs...)
+	}
+	return c, nil
+}
+
+// readSecretFiles reads the files named by the <name>_FILE variables of
+// secretVars, without their trailing newline.
+func readSecretFiles() (map[string]string, error) {
+	out := map[string]string{}
+	for _, k := range secretVars {
+		path := strings.TrimSpace(os.Getenv(k + "_FILE"))
+		if path == "" {
+			continue
+		}
+		if os.Getenv(k) != "" {
+			return nil, fmt.Errorf("set either %s or %s_FILE, not both", k, k)
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s_FILE: %w", k, err)
+		}
+		out[k] = strings.TrimRight(string(raw), "\r\n")
 	}
+	return out, nil
 }
 
-func getenv(k, d string) string {
+// logDedupWindow is zero when APP_LOG_DEDUP is off.
+func (e *env) logDedupWindow() time.Duration {
+	if !e.bool("APP_LOG_DEDUP", true) {
+		return 0
+	}
+	return e.duration("APP_LOG_DEDUP_WINDOW", 5*time.Minute)
+}
+
+// Disabled lists the subsystems switched off: "logs", "alerts" and
+// "metrics".
+func (c Config) Disabled() []string {
+	var out []string
+	for _, f := range []struct {
+		name    string
+		enabled bool
+	}{{"logs", c.LogsEnabled}, {"alerts", c.AlertsEnabled}, {"metrics", c.MetricsEnabled}} {
+		if !f.enabled {
+			out = append(out, f.name)
+		}
+	}
+	return out
+}
+
+func (c Config) ReplicaEnabled() bool {
+	return c.ReplicaEndpoint != "" && c.ReplicaBucket != ""
+}
+
+// LogArchiveEnabled reports whether logs are exported to object storage
+// before retention deletes them.
+func (c Config) LogArchiveEnabled() bool {
+	return c.LogsEnabled && c.LogsS3Endpoint != "" && c.LogsS3Bucket != ""
+}
+
+// env reads variables, collecting an error for every value that does not
+// parse instead of falling back to the default.
+type env struct {
+	read map[string]bool
+	errs []error
+}
+
+func (e *env) str(k, d string) string {
+	e.read[k] = true
 	if v := os.Getenv(k); v != "" {
 		return v
 	}
 	return d
 }
 
-func getenvInt(k string, d int) int {
-	v := os.Getenv(k)
+func (e *env) int(k string, d int) int {
+	v := strings.TrimSpace(e.str(k, ""))
 	if v == "" {
 		return d
 	}
 	n, err := strconv.Atoi(v)
 	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %q is not a whole number", k, v))
 		return d
 	}
 	return n
 }
 
-func getenvDuration(k string, d time.Duration) time.Duration {
-	v := os.Getenv(k)
-	if v == "" {
+// duration reads a duration that has to be positive.
+func (e *env) duration(k string, d time.Duration) time.Duration {
+	dur, ok := e.parseDuration(k, d)
+	if ok && dur <= 0 {
+		e.errs = append(e.errs, fmt.Errorf("%s: must be longer than 0", k))
 		return d
 	}
-	dur, err := time.ParseDuration(v)
-	if err != nil {
+	return dur
+}
+
+// optionalDuration reads a duration for which 0 turns the feature off.
+func (e *env) optionalDuration(k string, d time.Duration) time.Duration {
+	dur, ok := e.parseDuration(k, d)
+	if ok && dur < 0 {
+		e.errs = append(e.errs, fmt.Errorf("%s: must not be negative", k))
 		return d
 	}
 	return dur
 }
 
-func getenvBool(k string, d bool) bool {
-	v := strings.TrimSpace(strings.ToLower(os.Getenv(k)))
+func (e *env) parseDuration(k string, d time.Duration) (time.Duration, bool) {
+	v := strings.TrimSpace(e.str(k, ""))
 	if v == "" {
-		return d
+		return d, false
 	}
-	if v == "1" || v == "true" || v == "yes" || v == "on" {
-		return true
+	dur, err := time.ParseDuration(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %q is not a duration such as 30s, 5m or 6h", k, v))
+		return d, false
 	}
-	if v == "0" || v == "false" || v == "no" || v == "off" {
+	return dur, true
+}
+
+func (e *env) bool(k string, d bool) bool {
+	v := strings.TrimSpace(strings.ToLower(e.str(k, "")))
+	switch v {
+	case "":
+		return d
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
 		return false
 	}
+	e.errs = append(e.errs, fmt.Errorf("%s: %q is not true or false", k, v))
 	return d
 }
+
+func (e *env) list(k string, d []string) []string {
+	v := e.str(k, "")
+	if strings.TrimSpace(v) == "" {
+		return d
+	}
+	return splitList(v)
+}
+
+// splitList splits a comma-separated value, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadRejectsInvalidValues(t *testing.T) {
+	t.Setenv("DOCKER_SOCKET", t.TempDir())
+	t.Setenv("APP_METRICS_INTERVAL", "10")
+	t.Setenv("APP_RETENTION_DAYS", "0")
+	t.Setenv("APP_DOCKER_EVENTS", "maybe")
+	t.Setenv("APP_DOCKER_HOSTS", "nas=tcp://10.0.0.5,pi=ssh://pi")
+	t.Setenv("APP_AGENT_TOKENS", "nas=s3cret,local=s3cret")
+	t.Setenv("APP_FEDERATION_PEERS", "berlin=https://dashi.berlin.example.com,paris=dashi.paris.example.com")
+	t.Setenv("APP_ALERTMANAGER_URLS", "http://alertmanager:9093,alertmanager:9093")
+	t.Setenv("APP_ALERTMANAGER_LABELS", "site=home,bad-name=x")
+	_, err := Load()
+	if err == nil {
+		t.Fatal("Load accepted invalid values")
+	}
+	for _, want := range []string{
+		`APP_METRICS_INTERVAL: "10" is not a duration`,
+		"APP_RETENTION_DAYS: 0 days",
+		`APP_DOCKER_EVENTS: "maybe" is not true or false`,
+		`APP_DOCKER_HOSTS: nas: "tcp://10.0.0.5" is not a tcp://host:port URL`,
+		`APP_DOCKER_HOSTS: pi: "ssh://pi" is not`,
+		`APP_AGENT_TOKENS: "local" is not name=token`,
+		`APP_FEDERATION_PEERS: "paris" is not name=url`,
+		`APP_ALERTMANAGER_URLS: "alertmanager:9093" is not`,
+		`APP_ALERTMANAGER_LABELS: "bad-name=x" is not name=value`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoadWarnsAndStrictFails(t *testing.T) {
+	t.Setenv("DOCKER_SOCKET", t.TempDir()+"/docker.sock")
+	t.Setenv("APP_METRICS_INTERVL", "5s")
+	t.Setenv("APP_IMAGE_CHECK_INTERVAL", "0")
+	t.Setenv("TELEGRAM_BOT_TOKEN", "not-a-token")
+	t.Setenv("TELEGRAM_CHAT_ID", "-1001234")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.ImageCheckEvery != 0 {
+		t.Fatalf("image check interval = %s, want 0 (disabled)", cfg.ImageCheckEvery)
+	}
+	got := strings.Join(cfg.Warnings, "\n")
+	for _, want := range []string{
+		"DOCKER_SOCKET:",
+		"unknown variable APP_METRICS_INTERVL is ignored; did you mean APP_METRICS_INTERVAL?",
+		"TELEGRAM_BOT_TOKEN: not a bot token",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("warnings %q do not mention %q", got, want)
+		}
+	}
+	if strings.Contains(got, "TELEGRAM_CHAT_ID") {
+		t.Errorf("chat ID reported as invalid: %q", got)
+	}
+
+	t.Setenv("APP_STRICT_CONFIG", "true")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "APP_METRICS_INTERVL") {
+		t.Fatalf("strict load error = %v", err)
+	}
+}
+
+func TestLoadDemoMode(t *testing.T) {
+	t.Setenv("DOCKER_SOCKET", t.TempDir()+"/docker.sock")
+	t.Setenv("APP_DEMO_MODE", "true")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.DemoMode || len(cfg.Warnings) > 0 {
+		t.Fatalf("demo mode = %v, warnings %q; a demo needs no Docker socket", cfg.DemoMode, cfg.Warnings)
+	}
+
+	t.Setenv("APP_MODE", "agent")
+	t.Setenv("APP_AGENT_SERVER", "https://dashi.example.com")
+	t.Setenv("APP_AGENT_TOKEN", "secret")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "APP_DEMO_MODE") {
+		t.Fatalf("agent demo load error = %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var (
+	agentName      = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+	telegramToken  = regexp.MustCompile(`^[0-9]+:[A-Za-z0-9_-]{30,}$`)
+	telegramChatID = regexp.MustCompile(`^(-?[0-9]+|@[A-Za-z0-9_]{5,})$`)
+	labelName      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// validate checks values that parse but make no sense. It returns the
+// problems that keep dashi from starting and adds those it can run
+// without to c.Warnings.
+func (c *Config) validate() []error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	warn := func(format string, args ...any) {
+		c.Warnings = append(c.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	switch c.Mode {
+	case ModeServer:
+		if c.DemoMode && len(c.DockerHosts) > 0 {
+			warn("APP_DOCKER_HOSTS is ignored in demo mode")
+		}
+		for _, entry := range c.AgentTokens {
+			name, token, _ := strings.Cut(entry, "=")
+			if name = strings.TrimSpace(name); !agentName.MatchString(name) || name == "local" || strings.TrimSpace(token) == "" {
+				fail("APP_AGENT_TOKENS: %q is not name=token with an agent name other than local", name)
+			}
+		}
+		sites := map[string]bool{}
+		for _, entry := range c.FederationPeers {
+			name, raw, _ := strings.Cut(entry, "=")
+			name = strings.TrimSpace(name)
+			if u, err := url.Parse(strings.TrimSpace(raw)); !agentName.MatchString(name) || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				fail("APP_FEDERATION_PEERS: %q is not name=url with the http(s) URL of a dashi instance", name)
+			} else if sites[name] {
+				fail("APP_FEDERATION_PEERS: site %q is listed twice", name)
+			}
+			sites[name] = true
+		}
+		for _, raw := range c.AlertmanagerURLs {
+			if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				fail("APP_ALERTMANAGER_URLS: %q is not the http(s) URL of an Alertmanager", raw)
+			}
+		}
+		for _, entry := range c.AlertLabels {
+			if name, _, ok := strings.Cut(entry, "="); !ok || !labelName.MatchString(strings.TrimSpace(name)) {
+				fail("APP_ALERTMANAGER_LABELS: %q is not name=value with a Prometheus label name", entry)
+			}
+		}
+		if len(c.AlertmanagerURLs) > 0 && !c.AlertsEnabled {
+			warn("APP_ALERTMANAGER_URLS is ignored while APP_ALERTS_ENABLED is false")
+		}
+	case ModeAgent:
+		if u, err := url.Parse(c.AgentServer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("APP_AGENT_SERVER: %q is not the http(s) URL of a dashi server", c.AgentServer)
+		}
+		if c.AgentToken == "" {
+			fail("APP_AGENT_TOKEN: required in agent mode; use the agent's entry in the server's APP_AGENT_TOKENS or its APP_INGEST_TOKEN")
+		}
+		if !agentName.MatchString(c.AgentName) || c.AgentName == "local" {
+			fail("APP_AGENT_NAME: %q is not a host name of letters, digits, '.', '_' or '-' other than local", c.AgentName)
+		}
+		if !c.LogsEnabled && !c.MetricsEnabled {
+			warn("APP_LOGS_ENABLED and APP_METRICS_ENABLED are both false; the agent only pushes its container list")
+		}
+		if c.DemoMode {
+			fail("APP_DEMO_MODE: an agent collects a real Docker host; run the demo as a server")
+		}
+		if len(c.FederationPeers) > 0 {
+			warn("APP_FEDERATION_PEERS is ignored in agent mode")
+		}
+		if len(c.AlertmanagerURLs) > 0 {
+			warn("APP_ALERTMANAGER_URLS is ignored in agent mode; the server evaluates alerts")
+		}
+	default:
+		fail("APP_MODE: %q is not server or agent", c.Mode)
+	}
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		fail("APP_ADDR: %q is not a host:port address such as :8080", c.Addr)
+	}
+	for k, addr := range map[string]string{"APP_GELF_UDP_ADDR": c.GELFUDPAddr, "APP_GELF_HTTP_ADDR": c.GELFHTTPAddr, "APP_DEBUG_ADDR": c.DebugAddr} {
+		if _, _, err := net.SplitHostPort(addr); addr != "" && err != nil {
+			fail("%s: %q is not a host:port address such as :12201", k, addr)
+		}
+	}
+	switch c.DBDriver {
+	case "sqlite", "sqlite3":
+	case "postgres", "postgresql":
+		if c.DBURL == "" {
+			fail("APP_DB_URL: required when APP_DB_DRIVER is %s", c.DBDriver)
+		}
+	default:
+		fail("APP_DB_DRIVER: %q is not sqlite or postgres", c.DBDriver)
+	}
+	if !slices.Contains([]string{"quick", "full", "off"}, c.IntegrityCheck) {
+		fail("APP_DB_INTEGRITY_CHECK: %q is not quick, full or off", c.IntegrityCheck)
+	}
+
+	for k, days := range map[string]int{
+		"APP_RETENTION_DAYS":         c.RetentionDays,
+		"APP_LOG_RETENTION_DAYS":     c.LogRetentionDays,
+		"APP_METRICS_RETENTION_DAYS": c.MetricsDays,
+		"APP_ROLLUP_RETENTION_DAYS":  c.RollupDays,
+		"APP_ALERT_RETENTION_DAYS":   c.AlertsDays,
+	} {
+		if days < 1 {
+			fail("%s: %d days; keep data for at least 1 day", k, days)
+		}
+	}
+	if !c.LogsEnabled {
+		for _, in := range []struct{ key, value string }{
+			{"APP_GELF_UDP_ADDR", c.GELFUDPAddr},
+			{"APP_GELF_HTTP_ADDR", c.GELFHTTPAddr},
+			{"APP_LOG_FILES", strings.Join(c.LogFiles, ",")},
+			{"APP_LOG_FORWARD_URL", c.LogForwardURL},
+			{"APP_LOG_ARCHIVE_S3_ENDPOINT", c.LogsS3Endpoint},
+		} {
+			if in.value != "" {
+				warn("%s is ignored while APP_LOGS_ENABLED is false", in.key)
+			}
+		}
+	}
+	if c.RollupDays < c.MetricsDays {
+		warn("APP_ROLLUP_RETENTION_DAYS (%d) is shorter than APP_METRICS_RETENTION_DAYS (%d); long-range charts end where the rollups do", c.RollupDays, c.MetricsDays)
+	}
+	for k, n := range map[string]struct{ value, min int }{
+		"APP_COLLECT_WORKERS":  {c.CollectWorkers, 1},
+		"APP_WAL_MAX_MB":       {c.WALMaxMB, 1},
+		"APP_VACUUM_PAGES":     {c.VacuumPages, 0},
+		"APP_BACKUP_KEEP":      {c.BackupKeep, 1},
+		"APP_LOG_RATE_LIMIT":   {c.LogRateLimit, 0},
+		"APP_LOG_SAMPLE_EVERY": {c.LogSampleEvery, 1},
+		"APP_LOG_SPILL_MAX_MB": {c.LogSpillMaxMB, 0},
+		"APP_LOG_QUEUE_SIZE":   {c.LogQueueSize, 1},
+		"APP_LOG_BATCH_SIZE":   {c.LogBatchSize, 1},
+		"APP_LOG_MEMORY_MB":    {c.LogMemoryMB, 0},
+	} {
+		if n.value < n.min {
+			fail("%s: %d is below the minimum of %d", k, n.value, n.min)
+		}
+	}
+
+	localOverride := false
+	for _, entry := range c.DockerHosts {
+		name, endpoint, _ := strings.Cut(entry, "=")
+		name, endpoint = strings.TrimSpace(name), strings.TrimSpace(endpoint)
+		if name == "local" {
+			localOverride = true
+		}
+		if err := checkDockerEndpoint(endpoint); err != nil {
+			fail("APP_DOCKER_HOSTS: %s: %v", name, err)
+		}
+	}
+	if !localOverride && !c.DemoMode {
+		st, err := os.Stat(c.DockerSocket)
+		switch {
+		case err != nil:
+			warn("DOCKER_SOCKET: %v; the local Docker host is unavailable", err)
+		case st.Mode()&os.ModeSocket == 0:
+			warn("DOCKER_SOCKET: %s is not a socket; the local Docker host is unavailable", c.DockerSocket)
+		}
+	}
+
+	for k, raw := range map[string]string{"APP_LOG_FORWARD_URL": c.LogForwardURL, "APP_REPLICA_S3_ENDPOINT": c.ReplicaEndpoint, "APP_HEALTHCHECK_URL": c.HealthcheckURL,
+		"APP_LOG_ARCHIVE_S3_ENDPOINT": c.LogsS3Endpoint} {
+		if u, err := url.Parse(raw); raw != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+			fail("%s: %q is not an http(s) URL", k, raw)
+		}
+	}
+	if (c.LogsS3Endpoint == "") != (c.LogsS3Bucket == "") {
+		fail("APP_LOG_ARCHIVE_S3_ENDPOINT and APP_LOG_ARCHIVE_S3_BUCKET: only one is set; log archival needs both")
+	}
+
+	if c.TelegramBotToken != "" && !telegramToken.MatchString(c.TelegramBotToken) {
+		warn("TELEGRAM_BOT_TOKEN: not a bot token of the form 123456:ABC-DEF...; Telegram alerts will fail")
+	}
+	if c.TelegramChatID != "" && !telegramChatID.MatchString(c.TelegramChatID) {
+		warn("TELEGRAM_CHAT_ID: %q is not a numeric chat ID or @channel name; Telegram alerts will fail", c.TelegramChatID)
+	}
+	if (c.TelegramBotToken == "") != (c.TelegramChatID == "") {
+		warn("TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID: only one is set; Telegram alerts need both unless the other is saved on the settings page")
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errs
+}
+
+func checkDockerEndpoint(endpoint string) error {
+	switch {
+	case strings.HasPrefix(endpoint, "tcp://"):
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Port() == "" || u.Hostname() == "" {
+			return fmt.Errorf("%q is not a tcp://host:port URL", endpoint)
+		}
+	case strings.HasPrefix(endpoint, "unix:///"), strings.HasPrefix(endpoint, "/"):
+	default:
+		return fmt.Errorf("%q is not tcp://host:port, unix:///path or an absolute socket path", endpoint)
+	}
+	return nil
+}
+
+// unknown warns about APP_ and TELEGRAM_ variables Load did not read,
+// which are usually misspelled names whose value is silently ignored.
+func (e *env) unknown() []string {
+	var out []string
+	for _, kv := range os.Environ() {
+		k, _, _ := strings.Cut(kv, "=")
+		if e.read[k] || !(strings.HasPrefix(k, "APP_") || strings.HasPrefix(k, "TELEGRAM_")) {
+			continue
+		}
+		msg := "unknown variable " + k + " is ignored"
+		if near := e.nearest(k); near != "" {
+			msg += "; did you mean " + near + "?"
+		}
+		out = append(out, msg)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// nearest returns the variable read whose name is closest to k, if it is
+// within a few edits.
+func (e *env) nearest(k string) string {
+	best, bestDist := "", 4
+	for name := range e.read {
+		if d := editDistance(k, name); d < bestDist || (d == bestDist && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// RecordAgentHeartbeat stores a heartbeat of agent a, registering the agent
+// and its host on the first one. It returns the deadline of the previous
+// heartbeat, zero for an agent that was not registered.
+func (r *Repository) RecordAgentHeartbeat(ctx context.Context, a models.Agent) (time.Time, error) {
+	id, err := r.hostID(ctx, a.Host)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var prev time.Time
+	if err := r.queryRow(ctx, `SELECT deadline FROM agents WHERE host_id=?`, id).Scan(&prev); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, err
+	}
+	_, err = r.exec(ctx, `INSERT INTO agents (host_id,os,arch,kernel,docker_version,interval_sec,remote_addr,registered_at,last_heartbeat_at,deadline)
+		VALUES (?,?,?,?,?,?,?,?,?,?)
+		ON CONFLICT(host_id) DO UPDATE SET os=excluded.os,arch=excluded.arch,kernel=excluded.kernel,docker_version=excluded.docker_version,
+			interval_sec=excluded.interval_sec,remote_addr=excluded.remote_addr,last_heartbeat_at=excluded.last_heartbeat_at,deadline=excluded.deadline`,
+		id, a.OS, a.Arch, a.Kernel, a.DockerVersion, a.IntervalSec, a.RemoteAddr, a.LastHeartbeatAt.UTC(), a.LastHeartbeatAt.UTC(), a.Deadline.UTC())
+	return prev, err
+}
+
+// ListAgents returns the registered agents by host name.
+func (r *Repository) ListAgents(ctx context.Context) ([]models.Agent, error) {
+	rows, err := r.query(ctx, `SELECT h.name,a.os,a.arch,a.kernel,a.docker_version,a.interval_sec,a.remote_addr,a.registered_at,a.last_heartbeat_at,a.deadline
+		FROM agents a JOIN hosts h ON h.id=a.host_id ORDER BY h.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.Agent
+	for rows.Next() {
+		var a models.Agent
+		if err := rows.Scan(&a.Host, &a.OS, &a.Arch, &a.Kernel, &a.DockerVersion, &a.IntervalSec, &a.RemoteAddr, &a.RegisteredAt, &a.LastHeartbeatAt, &a.Deadline); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// DeleteAgent forgets the agent of host, e.g. one that was decommissioned,
+// or returns sql.ErrNoRows. It registers again with its next heartbeat.
+func (r *Repository) DeleteAgent(ctx context.Context, host string) error {
+	res, err := r.exec(ctx, `DELETE FROM agents WHERE host_id=(SELECT id FROM hosts WHERE name=?)`, host)
+	return affected(res, err)
+}
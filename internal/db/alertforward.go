@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// AlertsWithTargets returns the firing alerts and those that ended since
+// endedSince, oldest first, with their rules and targets.
+func (r *Repository) AlertsWithTargets(ctx context.Context, endedSince time.Time) ([]models.AlertWithTarget, error) {
+	rows, err := r.query(ctx, `SELECT a.id,ar.name,ar.metric_key,ar.target_type,a.target_fingerprint,a.status,a.summary,a.started_ts,a.ended_ts_nullable,
+		COALESCE(c.name,''),COALESCE(c.service_id,s.id,''),COALESCE(c.host,s.host,'')
+		FROM alerts a JOIN alert_rules ar ON ar.id=a.rule_id
+		LEFT JOIN containers c ON c.id=a.target_fingerprint
+		LEFT JOIN services s ON s.id=a.target_fingerprint
+		WHERE a.status='firing' OR a.ended_ts_nullable >= ?
+		ORDER BY a.started_ts, a.id`, endedSince.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.AlertWithTarget
+	for rows.Next() {
+		var a models.AlertWithTarget
+		var ended sql.NullTime
+		if err := rows.Scan(&a.ID, &a.RuleName, &a.MetricKey, &a.TargetType, &a.Target, &a.Status, &a.Summary, &a.Started, &ended,
+			&a.Container, &a.Service, &a.Host); err != nil {
+			return nil, err
+		}
+		if ended.Valid {
+			t := ended.Time
+			a.Ended = &t
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
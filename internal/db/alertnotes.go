@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"dashi/internal/models"
+)
+
+// AddAlertNote stores a note on an alert and returns its ID, or
+// sql.ErrNoRows when the alert does not exist.
+func (r *Repository) AddAlertNote(ctx context.Context, n models.AlertNote) (int64, error) {
+	var one int
+	if err := r.queryRow(ctx, `SELECT 1 FROM alerts WHERE id=?`, n.AlertID).Scan(&one); err != nil {
+		return 0, err
+	}
+	return r.insertID(ctx, `INSERT INTO alert_notes (alert_id,ts,author,text) VALUES (?,?,?,?)`, n.AlertID, n.TS.UTC(), n.Author, n.Text)
+}
+
+// AlertNotes returns the notes of the given alerts by alert, oldest first.
+func (r *Repository) AlertNotes(ctx context.Context, alertIDs []int64) (map[int64][]models.AlertNote, error) {
+	out := map[int64][]models.AlertNote{}
+	if len(alertIDs) == 0 {
+		return out, nil
+	}
+	args := make([]any, len(alertIDs))
+	for i, id := range alertIDs {
+		args[i] = id
+	}
+	rows, err := r.query(ctx, `SELECT id,alert_id,ts,author,text FROM alert_notes
+		WHERE alert_id IN (`+strings.TrimSuffix(strings.Repeat("?,", len(alertIDs)), ",")+`) ORDER BY ts, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n models.AlertNote
+		if err := rows.Scan(&n.ID, &n.AlertID, &n.TS, &n.Author, &n.Text); err != nil {
+			return nil, err
+		}
+		out[n.AlertID] = append(out[n.AlertID], n)
+	}
+	return out, rows.Err()
+}
+
+// DeleteAlertNote deletes a note of an alert and reports whether it existed.
+func (r *Repository) DeleteAlertNote(ctx context.Context, alertID, id int64) (bool, error) {
+	res, err := r.exec(ctx, `DELETE FROM alert_notes WHERE alert_id=? AND id=?`, alertID, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// AlertExists reports whether an alert is stored.
+func (r *Repository) AlertExists(ctx context.Context, id int64) (bool, error) {
+	var one int
+	err := r.queryRow(ctx, `SELECT 1 FROM alerts WHERE id=?`, id).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
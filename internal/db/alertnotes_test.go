@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestAlertNotes(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	rules, err := repo.ListRules(ctx)
+	if err != nil || len(rules) == 0 {
+		t.Fatalf("rules = %v, %v", rules, err)
+	}
+	alertID, err := repo.CreateAlert(ctx, rules[0].ID, "host", "firing", "disk full", nil, time.Now())
+	if err != nil {
+		t.Fatalf("create alert: %v", err)
+	}
+	now := time.Now().UTC()
+	first, err := repo.AddAlertNote(ctx, models.AlertNote{AlertID: alertID, TS: now.Add(-time.Minute), Author: "ops", Text: "pruned images"})
+	if err != nil {
+		t.Fatalf("add note: %v", err)
+	}
+	if _, err := repo.AddAlertNote(ctx, models.AlertNote{AlertID: alertID, TS: now, Text: "root cause: build cache"}); err != nil {
+		t.Fatalf("add note: %v", err)
+	}
+	if _, err := repo.AddAlertNote(ctx, models.AlertNote{AlertID: alertID + 1, TS: now, Text: "x"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("note on unknown alert: %v, want sql.ErrNoRows", err)
+	}
+	notes, err := repo.AlertNotes(ctx, []int64{alertID, alertID + 1})
+	if err != nil || len(notes) != 1 || len(notes[alertID]) != 2 || notes[alertID][0].ID != first || notes[alertID][0].Author != "ops" {
+		t.Fatalf("notes = %+v, %v", notes, err)
+	}
+
+	if found, err := repo.DeleteAlertNote(ctx, alertID+1, first); err != nil || found {
+		t.Fatalf("delete note of another alert = %v, %v", found, err)
+	}
+	if found, err := repo.DeleteAlertNote(ctx, alertID, first); err != nil || !found {
+		t.Fatalf("delete note = %v, %v", found, err)
+	}
+	// Notes go with their alert.
+	if _, err := repo.DeleteAllAlerts(ctx); err != nil {
+		t.Fatalf("delete alerts: %v", err)
+	}
+	var n int
+	if err := repo.queryRow(ctx, `SELECT COUNT(*) FROM alert_notes`).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("notes after deleting alerts = %d, %v", n, err)
+	}
+}
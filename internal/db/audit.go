@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// InsertAudit records an administrative action. Events are kept until
+// deleted by hand; there are few of them.
+func (r *Repository) InsertAudit(ctx context.Context, e models.AuditEvent) error {
+	if e.TS.IsZero() {
+		e.TS = time.Now()
+	}
+	_, err := r.exec(ctx, `INSERT INTO audit_log(ts,action,target,detail,source) VALUES (?,?,?,?,?)`,
+		e.TS.UTC(), e.Action, e.Target, e.Detail, e.Source)
+	return err
+}
+
+// ListAudit returns the newest audit events first.
+func (r *Repository) ListAudit(ctx context.Context, limit int) ([]models.AuditEvent, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	rows, err := r.query(ctx, `SELECT id,ts,action,target,detail,source FROM audit_log ORDER BY ts DESC, id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.AuditEvent
+	for rows.Next() {
+		var e models.AuditEvent
+		if err := rows.Scan(&e.ID, &e.TS, &e.Action, &e.Target, &e.Detail, &e.Source); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
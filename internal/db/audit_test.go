@@ -0,0 +1,28 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestAuditNewestFirst(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for i, action := range []string{"prune.images", "prune.volumes"} {
+		e := models.AuditEvent{TS: now.Add(time.Duration(i) * time.Minute), Action: action, Target: "local", Detail: "2 removed", Source: "10.0.0.5"}
+		if err := repo.InsertAudit(ctx, e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	events, err := repo.ListAudit(ctx, 10)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(events) != 2 || events[0].Action != "prune.volumes" || events[1].Source != "10.0.0.5" {
+		t.Fatalf("events = %+v", events)
+	}
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// recordStatusChanges adds a status change for each container matching
+// filter (on containers c) whose status differs from the last one recorded.
+// Archiving is not recorded, as an archived container was missing already.
+func (r *Repository) recordStatusChanges(ctx context.Context, ts time.Time, filter string, args ...any) error {
+	_, err := r.exec(ctx, `INSERT INTO container_status_changes (container_id,service_id,status,ts)
+		SELECT c.id,c.service_id,c.status,? FROM containers c
+		WHERE `+filter+` AND c.status<>'archived' AND c.status<>COALESCE((SELECT s.status FROM container_status_changes s
+			WHERE s.container_id=c.id ORDER BY s.ts DESC, s.id DESC LIMIT 1),'')`, append([]any{ts.UTC()}, args...)...)
+	return err
+}
+
+// ContainerStatusHistory returns the status changes of the containers of a
+// service, or of all with an empty serviceID, between from and to, each
+// container's last change before from included, by container and time.
+func (r *Repository) ContainerStatusHistory(ctx context.Context, serviceID string, from, to time.Time) ([]models.ContainerStatusChange, error) {
+	filter, args := "", []any{to.UTC(), from.UTC(), from.UTC()}
+	if serviceID != "" {
+		filter, args = " AND s.service_id=?", append(args, serviceID)
+	}
+	rows, err := r.query(ctx, `SELECT s.container_id,s.service_id,s.status,s.ts FROM container_status_changes s
+		WHERE s.ts <= ? AND s.ts >= COALESCE((SELECT MAX(p.ts) FROM container_status_changes p WHERE p.container_id=s.container_id AND p.ts <= ?), ?)`+filter+`
+		ORDER BY s.container_id, s.ts, s.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.ContainerStatusChange
+	for rows.Next() {
+		var c models.ContainerStatusChange
+		if err := rows.Scan(&c.ContainerID, &c.ServiceID, &c.Status, &c.TS); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// ContainerOutages returns the container_unavailable alerts overlapping
+// from to to of the containers of a service, or of all with an empty
+// serviceID.
+func (r *Repository) ContainerOutages(ctx context.Context, serviceID string, from, to time.Time) ([]models.ContainerOutage, error) {
+	filter, args := "", []any{to.UTC(), from.UTC()}
+	if serviceID != "" {
+		filter, args = " AND c.service_id=?", append(args, serviceID)
+	}
+	rows, err := r.query(ctx, `SELECT a.target_fingerprint,c.service_id,a.started_ts,a.ended_ts_nullable
+		FROM alerts a JOIN alert_rules ar ON ar.id=a.rule_id JOIN containers c ON c.id=a.target_fingerprint
+		WHERE ar.metric_key='container_unavailable' AND a.started_ts <= ? AND (a.ended_ts_nullable IS NULL OR a.ended_ts_nullable >= ?)`+filter+`
+		ORDER BY a.started_ts`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.ContainerOutage
+	for rows.Next() {
+		var o models.ContainerOutage
+		var ended sql.NullTime
+		if err := rows.Scan(&o.ContainerID, &o.ServiceID, &o.From, &ended); err != nil {
+			return nil, err
+		}
+		if ended.Valid {
+			o.To = &ended.Time
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// DeleteStatusChangesOlderThan deletes status changes before cutoff but the
+// last of each container, which still tells its status since.
+func (r *Repository) DeleteStatusChangesOlderThan(ctx context.Context, cutoff time.Time) error {
+	_, err := r.exec(ctx, `DELETE FROM container_status_changes WHERE ts < ?
+		AND id NOT IN (SELECT MAX(id) FROM container_status_changes GROUP BY container_id)`, cutoff.UTC())
+	return err
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestContainerStatusHistory(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	svc := models.Service{ID: "web", Name: "web", LabelsJSON: "{}", Status: "running"}
+	for i := 0; i < 2; i++ {
+		if err := repo.UpsertServiceAndContainer(ctx, svc, models.Container{ID: "a", ServiceID: "web", Name: "web-1", Status: "running"}); err != nil {
+			t.Fatalf("store container: %v", err)
+		}
+	}
+	if err := repo.MarkMissingContainers(ctx, "", nil); err != nil {
+		t.Fatalf("mark missing: %v", err)
+	}
+	if err := repo.MarkMissingContainers(ctx, "", nil); err != nil {
+		t.Fatalf("mark missing: %v", err)
+	}
+	now := time.Now().UTC()
+	changes, err := repo.ContainerStatusHistory(ctx, "web", now.Add(-time.Hour), now)
+	if err != nil || len(changes) != 2 || changes[0].Status != "running" || changes[1].Status != "missing" {
+		t.Fatalf("history = %+v, %v, want running then missing once each", changes, err)
+	}
+	// The last change before the window tells the status at its start.
+	if changes, err := repo.ContainerStatusHistory(ctx, "web", now.Add(time.Minute), now.Add(time.Hour)); err != nil || len(changes) != 1 || changes[0].Status != "missing" {
+		t.Fatalf("later history = %+v, %v", changes, err)
+	}
+	if changes, err := repo.ContainerStatusHistory(ctx, "db", now.Add(-time.Hour), now); err != nil || len(changes) != 0 {
+		t.Fatalf("other service history = %+v, %v", changes, err)
+	}
+
+	if err := repo.DeleteStatusChangesOlderThan(ctx, now.Add(time.Minute)); err != nil {
+		t.Fatalf("delete status changes: %v", err)
+	}
+	if changes, err := repo.ContainerStatusHistory(ctx, "", now.Add(time.Minute), now.Add(time.Hour)); err != nil || len(changes) != 1 || changes[0].Status != "missing" {
+		t.Fatalf("history after retention = %+v, %v, want the last change kept", changes, err)
+	}
+	if outages, err := repo.ContainerOutages(ctx, "web", now.Add(-time.Hour), now); err != nil || len(outages) != 0 {
+		t.Fatalf("outages = %+v, %v", outages, err)
+	}
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"dashi/internal/models"
+)
+
+const checkColumns = `id,name,url,method,expect_status,expect_body,interval_sec,timeout_sec,enabled`
+
+func scanCheck(sc interface{ Scan(...any) error }) (models.Check, error) {
+	var c models.Check
+	var enabled int
+	err := sc.Scan(&c.ID, &c.Name, &c.URL, &c.Method, &c.ExpectStatus, &c.ExpectBody, &c.IntervalSec, &c.TimeoutSec, &enabled)
+	c.Enabled = enabled == 1
+	return c, err
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (r *Repository) CreateCheck(ctx context.Context, c models.Check) (int64, error) {
+	return r.insertID(ctx, `INSERT INTO checks (name,url,method,expect_status,expect_body,interval_sec,timeout_sec,enabled) VALUES (?,?,?,?,?,?,?,?)`,
+		c.Name, c.URL, c.Method, c.ExpectStatus, c.ExpectBody, c.IntervalSec, c.TimeoutSec, boolInt(c.Enabled))
+}
+
+// UpdateCheck replaces a check's definition, sql.ErrNoRows when it does not
+// exist.
+func (r *Repository) UpdateCheck(ctx context.Context, c models.Check) error {
+	res, err := r.exec(ctx, `UPDATE checks SET name=?,url=?,method=?,expect_status=?,expect_body=?,interval_sec=?,timeout_sec=?,enabled=? WHERE id=?`,
+		c.Name, c.URL, c.Method, c.ExpectStatus, c.ExpectBody, c.IntervalSec, c.TimeoutSec, boolInt(c.Enabled), c.ID)
+	return affected(res, err)
+}
+
+// DeleteCheck removes a check and its history, sql.ErrNoRows when it does
+// not exist.
+func (r *Repository) DeleteCheck(ctx context.Context, id int64) error {
+	res, err := r.exec(ctx, `DELETE FROM checks WHERE id=?`, id)
+	return affected(res, err)
+}
+
+func affected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetCheck returns sql.ErrNoRows for unknown checks.
+func (r *Repository) GetCheck(ctx context.Context, id int64) (models.Check, error) {
+	return scanCheck(r.queryRow(ctx, `SELECT `+checkColumns+` FROM checks WHERE id=?`, id))
+}
+
+func (r *Repository) ListChecks(ctx context.Context) ([]models.Check, error) {
+	rows, err := r.query(ctx, `SELECT `+checkColumns+` FROM checks ORDER BY name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.Check
+	for rows.Next() {
+		c, err := scanCheck(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repository) InsertCheckResult(ctx context.Context, res models.CheckResult) error {
+	_, err := r.exec(ctx, `INSERT INTO check_results (check_id,ts,ok,status_code,latency_ms,error) VALUES (?,?,?,?,?,?)`,
+		res.CheckID, res.TS.UTC(), boolInt(res.OK), res.StatusCode, res.LatencyMS, res.Error)
+	return err
+}
+
+// CheckResults returns the probes of one check since from, oldest first.
+func (r *Repository) CheckResults(ctx context.Context, id int64, from time.Time, limit int) ([]models.CheckResult, error) {
+	rows, err := r.query(ctx, `SELECT check_id,ts,ok,status_code,latency_ms,error FROM check_results
+		WHERE check_id=? AND ts >= ? ORDER BY ts ASC LIMIT ?`, id, from.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.CheckResult
+	for rows.Next() {
+		res, err := scanCheckResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, res)
+	}
+	return out, rows.Err()
+}
+
+func scanCheckResult(sc interface{ Scan(...any) error }) (models.CheckResult, error) {
+	var res models.CheckResult
+	var ok int
+	err := sc.Scan(&res.CheckID, &res.TS, &ok, &res.StatusCode, &res.LatencyMS, &res.Error)
+	res.OK = ok == 1
+	return res, err
+}
+
+// CheckStatuses returns every check with its newest result and its uptime
+// and mean latency over the probes since from.
+func (r *Repository) CheckStatuses(ctx context.Context, from time.Time) ([]models.CheckStatus, error) {
+	checks, err := r.ListChecks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]models.CheckStatus, len(checks))
+	byID := make(map[int64]*models.CheckStatus, len(checks))
+	for i, c := range checks {
+		out[i].Check = c
+		byID[c.ID] = &out[i]
+	}
+
+	rows, err := r.query(ctx, `SELECT check_id, COUNT(*), SUM(ok), AVG(latency_ms) FROM check_results WHERE ts >= ? GROUP BY check_id`, from.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var n, up int
+		var latency float64
+		if err := rows.Scan(&id, &n, &up, &latency); err != nil {
+			return nil, err
+		}
+		if s, ok := byID[id]; ok && n > 0 {
+			s.Probes, s.UptimePct, s.AvgLatencyMS = n, 100*float64(up)/float64(n), latency
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	last, err := r.query(ctx, `SELECT cr.check_id,cr.ts,cr.ok,cr.status_code,cr.latency_ms,cr.error
+		FROM check_results cr
+		JOIN (SELECT check_id, MAX(ts) AS ts FROM check_results GROUP BY check_id) l
+			ON l.check_id=cr.check_id AND l.ts=cr.ts`)
+	if err != nil {
+		return nil, err
+	}
+	defer last.Close()
+	for last.Next() {
+		res, err := scanCheckResult(last)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := byID[res.CheckID]; ok {
+			s.Last = &res
+		}
+	}
+	return out, last.Err()
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestCheckStatuses(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+
+	id, err := repo.CreateCheck(ctx, models.Check{Name: "web", URL: "https://web.example", Method: "GET", IntervalSec: 60, TimeoutSec: 10, Enabled: true})
+	if err != nil {
+		t.Fatalf("create check: %v", err)
+	}
+	if _, err := repo.CreateCheck(ctx, models.Check{Name: "idle", URL: "https://idle.example", Method: "GET", IntervalSec: 60, TimeoutSec: 10}); err != nil {
+		t.Fatalf("create check: %v", err)
+	}
+	for i, ok := range []bool{true, true, true, false} {
+		res := models.CheckResult{CheckID: id, TS: now.Add(time.Duration(i-3) * time.Minute), OK: ok, StatusCode: 200, LatencyMS: int64(100 * (i + 1))}
+		if !ok {
+			res.StatusCode, res.Error = 502, "status 502"
+		}
+		if err := repo.InsertCheckResult(ctx, res); err != nil {
+			t.Fatalf("insert result: %v", err)
+		}
+	}
+
+	statuses, err := repo.CheckStatuses(ctx, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("check statuses: %v", err)
+	}
+	if len(statuses) != 2 || statuses[0].Name != "idle" || statuses[0].Last != nil || statuses[0].Enabled {
+		t.Fatalf("statuses = %+v", statuses)
+	}
+	web := statuses[1]
+	if web.Probes != 4 || web.UptimePct != 75 || web.AvgLatencyMS != 250 {
+		t.Fatalf("web summary = %+v", web)
+	}
+	if web.Last == nil || web.Last.OK || web.Last.StatusCode != 502 || web.Last.Error != "status 502" {
+		t.Fatalf("web last = %+v", web.Last)
+	}
+
+	if err := repo.DeleteCheck(ctx, id); err != nil {
+		t.Fatalf("delete check: %v", err)
+	}
+	if res, _ := repo.CheckResults(ctx, id, now.Add(-time.Hour), 10); len(res) != 0 {
+		t.Fatalf("results after delete = %+v", res)
+	}
+	if err := repo.DeleteCheck(ctx, id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("second delete err = %v, want sql.ErrNoRows", err)
+	}
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"context"
+
+	"dashi/internal/models"
+)
+
+// SaveClockOffset records the latest comparison of a host's clock. A failed
+// comparison updates the error but keeps the last measured offset.
+func (r *Repository) SaveClockOffset(ctx context.Context, o models.ClockOffset) error {
+	_, err := r.exec(ctx, `INSERT INTO clock_offsets(host,source,offset_ms,checked_at,error) VALUES (?,?,?,?,?)
+		ON CONFLICT(host,source) DO UPDATE SET checked_at=excluded.checked_at,error=excluded.error,
+			offset_ms=CASE WHEN excluded.error='' THEN excluded.offset_ms ELSE clock_offsets.offset_ms END`,
+		o.Host, o.Source, o.OffsetMS, o.CheckedAt.UTC(), o.Error)
+	return err
+}
+
+func (r *Repository) ListClockOffsets(ctx context.Context) ([]models.ClockOffset, error) {
+	rows, err := r.query(ctx, `SELECT host,source,offset_ms,checked_at,error FROM clock_offsets ORDER BY host, source`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.ClockOffset
+	for rows.Next() {
+		var o models.ClockOffset
+		if err := rows.Scan(&o.Host, &o.Source, &o.OffsetMS, &o.CheckedAt, &o.Error); err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestSaveClockOffsetKeepsOffsetOnError(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+
+	for _, o := range []models.ClockOffset{
+		{Host: "local", Source: "ntp", OffsetMS: 1500, CheckedAt: now},
+		{Host: "local", Source: "docker", OffsetMS: 0.4, CheckedAt: now},
+		{Host: "local", Source: "ntp", CheckedAt: now.Add(time.Minute), Error: "i/o timeout"},
+	} {
+		if err := repo.SaveClockOffset(ctx, o); err != nil {
+			t.Fatalf("save offset: %v", err)
+		}
+	}
+	offsets, err := repo.ListClockOffsets(ctx)
+	if err != nil {
+		t.Fatalf("list offsets: %v", err)
+	}
+	if len(offsets) != 2 || offsets[0].Source != "docker" || offsets[1].Source != "ntp" {
+		t.Fatalf("offsets = %+v", offsets)
+	}
+	ntp := offsets[1]
+	if ntp.OffsetMS != 1500 || ntp.Error != "i/o timeout" || !ntp.CheckedAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("ntp offset = %+v", ntp)
+	}
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// ConfigImport is the user-editable state carried by a configuration
+// export: alert rules, settings and UI preferences. Values are JSON text.
+type ConfigImport struct {
+	Rules       []models.AlertRule
+	Settings    map[string]string
+	Preferences map[string]string
+}
+
+// ListPreferences returns the stored preferences JSON of every owner.
+func (r *Repository) ListPreferences(ctx context.Context) (map[string]string, error) {
+	rows, err := r.query(ctx, `SELECT owner,prefs_json FROM preferences ORDER BY owner`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]string{}
+	for rows.Next() {
+		var owner, prefs string
+		if err := rows.Scan(&owner, &prefs); err != nil {
+			return nil, err
+		}
+		out[owner] = prefs
+	}
+	return out, rows.Err()
+}
+
+// ImportConfig applies c in one transaction. Rules are matched by name and
+// updated in place (keeping their alert history) or inserted; settings and
+// preferences are upserted. Nothing that is absent from c is removed.
+func (r *Repository) ImportConfig(ctx context.Context, c ConfigImport) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	rb := r.dialect.Rebind
+	for _, rule := range c.Rules {
+		enabled := 0
+		if rule.Enabled {
+			enabled = 1
+		}
+		res, err := tx.ExecContext(ctx, rb(`UPDATE alert_rules SET target_type=?,target_id_nullable=?,metric_key=?,operator=?,threshold=?,for_seconds=?,cooldown_seconds=?,enabled=?,label_selector=? WHERE name=?`),
+			rule.TargetType, rule.TargetID, rule.MetricKey, rule.Operator, rule.Threshold, rule.ForSeconds, rule.CooldownSeconds, enabled, rule.LabelSelector, rule.Name)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, rb(`INSERT INTO alert_rules (name,target_type,target_id_nullable,metric_key,operator,threshold,for_seconds,cooldown_seconds,enabled,label_selector)
+			VALUES (?,?,?,?,?,?,?,?,?,?)`),
+			rule.Name, rule.TargetType, rule.TargetID, rule.MetricKey, rule.Operator, rule.Threshold, rule.ForSeconds, rule.CooldownSeconds, enabled, rule.LabelSelector); err != nil {
+			return err
+		}
+	}
+	for key, value := range c.Settings {
+		if _, err := tx.ExecContext(ctx, rb(`INSERT INTO settings(key,value) VALUES (?,?) ON CONFLICT(key) DO UPDATE SET value=excluded.value`), key, value); err != nil {
+			return err
+		}
+	}
+	now := time.Now().UTC()
+	for owner, prefs := range c.Preferences {
+		if _, err := tx.ExecContext(ctx, rb(`INSERT INTO preferences (owner,prefs_json,updated_at) VALUES (?,?,?)
+			ON CONFLICT(owner) DO UPDATE SET prefs_json=excluded.prefs_json,updated_at=excluded.updated_at`), owner, prefs, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
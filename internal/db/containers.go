@@ -0,0 +1,293 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// Containers that disappear from Docker go "missing" and are archived once
+// they stay gone; archived containers keep their history but drop out of
+// lists and alert evaluation. Purging removes a container and its data.
+
+// ErrContainerActive is returned when archiving or purging a container that
+// Docker still reports.
+var ErrContainerActive = errors.New("container is still present in docker")
+
+// SetContainerState records a state change reported by a Docker event for a
+// known container. Empty status or health values are left unchanged.
+func (r *Repository) SetContainerState(ctx context.Context, id, status, health string) error {
+	now := time.Now().UTC()
+	_, err := r.exec(ctx, `UPDATE containers SET
+		status=CASE WHEN ?='' THEN status ELSE ? END,
+		health=CASE WHEN ?='' THEN health ELSE ? END,
+		last_seen_at=?
+		WHERE id=?`, status, status, health, health, now, id)
+	if err != nil || status == "" {
+		return err
+	}
+	return r.recordStatusChanges(ctx, now, "c.id=?", id)
+}
+
+// ArchiveMissingContainers archives containers missing since before cutoff.
+func (r *Repository) ArchiveMissingContainers(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := r.exec(ctx, `UPDATE containers SET status='archived' WHERE status='missing' AND last_seen_at < ?`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ArchiveContainer archives one missing or exited container.
+func (r *Repository) ArchiveContainer(ctx context.Context, id string) error {
+	status, _, err := r.containerState(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !inactive(status) {
+		return ErrContainerActive
+	}
+	_, err = r.exec(ctx, `UPDATE containers SET status='archived' WHERE id=?`, id)
+	return err
+}
+
+// PurgeContainer deletes a container that Docker no longer runs together
+// with its metrics, rollups, logs and alerts. Its service goes too once no
+// containers are left for it.
+func (r *Repository) PurgeContainer(ctx context.Context, id string) error {
+	status, serviceID, err := r.containerState(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !inactive(status) {
+		return ErrContainerActive
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	rb := r.dialect.Rebind
+	// Logs may live in an attached database without foreign keys, so every
+	// table is cleared explicitly rather than relying on cascades.
+	for _, q := range []string{
+		`DELETE FROM container_metrics WHERE container_id=?`,
+		`DELETE FROM container_metrics_rollup WHERE container_id=?`,
+		`DELETE FROM logs WHERE container_id=?`,
+		`DELETE FROM alerts WHERE target_fingerprint=?`,
+		`DELETE FROM alert_states WHERE target_fingerprint=?`,
+		`DELETE FROM restart_events WHERE container_id=?`,
+		`DELETE FROM container_status_changes WHERE container_id=?`,
+		`DELETE FROM containers WHERE id=?`,
+	} {
+		if _, err := tx.ExecContext(ctx, rb(q), id); err != nil {
+			return err
+		}
+	}
+	for _, q := range []string{
+		`DELETE FROM labels WHERE service_id=? AND NOT EXISTS (SELECT 1 FROM containers WHERE service_id=labels.service_id)`,
+		`DELETE FROM image_updates WHERE service_id=? AND NOT EXISTS (SELECT 1 FROM containers WHERE service_id=image_updates.service_id)`,
+		`DELETE FROM service_log_metrics WHERE service_id=? AND NOT EXISTS (SELECT 1 FROM containers WHERE service_id=service_log_metrics.service_id)`,
+		`DELETE FROM services WHERE id=? AND NOT EXISTS (SELECT 1 FROM containers WHERE service_id=services.id)`,
+	} {
+		if _, err := tx.ExecContext(ctx, rb(q), serviceID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	r.hot.dropContainer(id)
+	r.hot.dropAlerts()
+	return nil
+}
+
+// ContainerHost returns the Docker host of a container, sql.ErrNoRows for
+// unknown containers.
+func (r *Repository) ContainerHost(ctx context.Context, id string) (string, error) {
+	var host string
+	err := r.queryRow(ctx, `SELECT host FROM containers WHERE id=?`, id).Scan(&host)
+	return host, err
+}
+
+// ContainerService returns the service of a container, sql.ErrNoRows for
+// unknown containers.
+func (r *Repository) ContainerService(ctx context.Context, id string) (string, error) {
+	var serviceID string
+	err := r.queryRow(ctx, `SELECT service_id FROM containers WHERE id=?`, id).Scan(&serviceID)
+	return serviceID, err
+}
+
+// containerState returns sql.ErrNoRows for unknown containers.
+func (r *Repository) containerState(ctx context.Context, id string) (status, serviceID string, err error) {
+	err = r.queryRow(ctx, `SELECT status,service_id FROM containers WHERE id=?`, id).Scan(&status, &serviceID)
+	return status, serviceID, err
+}
+
+func inactive(status string) bool {
+	return status == "missing" || status == "exited" || status == "archived"
+}
+
+func hostOrLocal(host string) string {
+	if host == "" {
+		return "local"
+	}
+	return host
+}
+
+// ListHosts summarizes each Docker endpoint: its containers, the summed
+// latest samples of the running ones, the host sample for the local host,
+// which is the only one dashi samples, its firing alerts and its agent.
+func (r *Repository) ListHosts(ctx context.Context) ([]models.DockerHost, error) {
+	latest, err := r.latestContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]*models.DockerHost{}
+	host := func(name string) *models.DockerHost {
+		h, ok := byName[name]
+		if !ok {
+			h = &models.DockerHost{Name: name}
+			byName[name] = h
+		}
+		return h
+	}
+	rows, err := r.query(ctx, `SELECT h.name,c.id,c.status FROM containers c JOIN hosts h ON h.id=c.host_id WHERE c.status!='archived'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, id, status string
+		if err := rows.Scan(&name, &id, &status); err != nil {
+			return nil, err
+		}
+		h := host(name)
+		h.Containers++
+		if status != "running" {
+			continue
+		}
+		h.Running++
+		if m, ok := latest[id]; ok {
+			h.CPUPct += m.CPUPct
+			h.MemUsedBytes += m.MemUsedBytes
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	m, err := r.LatestHostMetric(ctx)
+	switch {
+	case err == nil:
+		host(hostOrLocal("")).Metric = &m
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+	alerts, err := r.ActiveAlertsByHost(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for name, n := range alerts {
+		host(name).ActiveAlerts = n
+	}
+	agents, err := r.ListAgents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range agents {
+		host(a.Host).Agent = &a
+	}
+	out := make([]models.DockerHost, 0, len(byName))
+	for _, h := range byName {
+		out = append(out, *h)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+const containerColumns = `id,service_id,host,name,status,health,started_at,last_seen_at,restart_count,ports_json,networks_json,links_json`
+
+func scanContainers(rows *sql.Rows) ([]models.Container, error) {
+	var out []models.Container
+	for rows.Next() {
+		var c models.Container
+		var started sql.NullTime
+		var portsJSON, networksJSON, linksJSON string
+		if err := rows.Scan(&c.ID, &c.ServiceID, &c.Host, &c.Name, &c.Status, &c.Health, &started, &c.LastSeenAt, &c.RestartCount, &portsJSON, &networksJSON, &linksJSON); err != nil {
+			return nil, err
+		}
+		if started.Valid {
+			t := started.Time
+			c.StartedAt = &t
+		}
+		_ = json.Unmarshal([]byte(portsJSON), &c.Ports)
+		_ = json.Unmarshal([]byte(networksJSON), &c.Networks)
+		_ = json.Unmarshal([]byte(linksJSON), &c.Links)
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// Container returns a container, archived ones included, or sql.ErrNoRows
+// for unknown containers.
+func (r *Repository) Container(ctx context.Context, id string) (models.Container, error) {
+	rows, err := r.query(ctx, `SELECT `+containerColumns+` FROM containers WHERE id=?`, id)
+	if err != nil {
+		return models.Container{}, err
+	}
+	defer rows.Close()
+	containers, err := scanContainers(rows)
+	if err != nil {
+		return models.Container{}, err
+	}
+	if len(containers) == 0 {
+		return models.Container{}, sql.ErrNoRows
+	}
+	return containers[0], nil
+}
+
+// ServiceContainers returns the containers a service ran, archived ones
+// included, so its history survives redeploys.
+func (r *Repository) ServiceContainers(ctx context.Context, serviceID string) ([]models.Container, error) {
+	rows, err := r.query(ctx, `SELECT `+containerColumns+` FROM containers WHERE service_id=? ORDER BY name`, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanContainers(rows)
+}
+
+// ServiceDetail returns a service with its containers, archived ones
+// excluded, or sql.ErrNoRows for unknown services.
+func (r *Repository) ServiceDetail(ctx context.Context, id string) (models.Service, []models.Container, error) {
+	var svc models.Service
+	err := r.queryRow(ctx, `SELECT id,host,name,image,labels_json,status FROM services WHERE id=?`, id).
+		Scan(&svc.ID, &svc.Host, &svc.Name, &svc.Image, &svc.LabelsJSON, &svc.Status)
+	if err != nil {
+		return models.Service{}, nil, err
+	}
+	rows, err := r.query(ctx, `SELECT `+containerColumns+` FROM containers WHERE service_id=? AND status!='archived' ORDER BY name`, id)
+	if err != nil {
+		return models.Service{}, nil, err
+	}
+	defer rows.Close()
+	containers, err := scanContainers(rows)
+	return svc, containers, err
+}
+
+// jsonList encodes a slice for a JSON list column, "[]" when it is empty.
+func jsonList[T any](v []T) string {
+	if len(v) == 0 {
+		return "[]"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "[]"
+	}
+	return string(b)
+}
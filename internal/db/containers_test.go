@@ -0,0 +1,205 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestArchiveAndPurgeContainer(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	seedContainer(t, repo, ctx, "svc-a", "c1", now)
+	seedContainer(t, repo, ctx, "svc-b", "c2", now)
+	if err := repo.InsertMetricsBatch(ctx, nil, []models.ContainerMetric{{TS: now, ContainerID: "c1"}, {TS: now, ContainerID: "c2"}}); err != nil {
+		t.Fatalf("insert metrics: %v", err)
+	}
+	if err := repo.InsertLogs(ctx, []models.LogEntry{{TS: now, ServiceID: "svc-a", ContainerID: "c1", Level: "INFO", Stream: "stdout", Message: "bye"}}); err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+
+	if err := repo.PurgeContainer(ctx, "c1"); !errors.Is(err, ErrContainerActive) {
+		t.Fatalf("purge running container err = %v, want ErrContainerActive", err)
+	}
+	if err := repo.MarkMissingContainers(ctx, "", []string{"c2"}); err != nil {
+		t.Fatalf("mark missing: %v", err)
+	}
+
+	// Only containers missing since before the cutoff are archived.
+	if n, err := repo.ArchiveMissingContainers(ctx, now.Add(-time.Hour)); err != nil || n != 0 {
+		t.Fatalf("archive recent = %d, %v", n, err)
+	}
+	if n, err := repo.ArchiveMissingContainers(ctx, now.Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("archive stale = %d, %v", n, err)
+	}
+	containers, err := repo.ListContainers(ctx)
+	if err != nil {
+		t.Fatalf("list containers: %v", err)
+	}
+	if len(containers) != 1 || containers[0].ID != "c2" {
+		t.Fatalf("archived container still listed: %+v", containers)
+	}
+	// A later missing sweep must not resurrect the archived container.
+	if err := repo.MarkMissingContainers(ctx, "", []string{"c2"}); err != nil {
+		t.Fatalf("mark missing: %v", err)
+	}
+	if status, _, _ := repo.containerState(ctx, "c1"); status != "archived" {
+		t.Fatalf("status = %q, want archived", status)
+	}
+
+	if err := repo.PurgeContainer(ctx, "c1"); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	var n int
+	for _, q := range []string{
+		`SELECT COUNT(*) FROM containers WHERE id='c1'`,
+		`SELECT COUNT(*) FROM container_metrics WHERE container_id='c1'`,
+		`SELECT COUNT(*) FROM logs WHERE container_id='c1'`,
+		`SELECT COUNT(*) FROM services WHERE id='svc-a'`,
+	} {
+		if err := repo.db.QueryRow(q).Scan(&n); err != nil || n != 0 {
+			t.Fatalf("%s = %d, %v", q, n, err)
+		}
+	}
+	if err := repo.db.QueryRow(`SELECT COUNT(*) FROM container_metrics WHERE container_id='c2'`).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("other container's metrics touched: %d, %v", n, err)
+	}
+	if err := repo.PurgeContainer(ctx, "c1"); err == nil {
+		t.Fatal("purging an unknown container should fail")
+	}
+}
+
+func TestSetContainerState(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	seedContainer(t, repo, ctx, "svc-a", "c1", time.Now())
+
+	if err := repo.SetContainerState(ctx, "c1", "", "unhealthy"); err != nil {
+		t.Fatalf("set health: %v", err)
+	}
+	if err := repo.SetContainerState(ctx, "c1", "exited", ""); err != nil {
+		t.Fatalf("set status: %v", err)
+	}
+	containers, err := repo.ListContainers(ctx)
+	if err != nil {
+		t.Fatalf("list containers: %v", err)
+	}
+	if len(containers) != 1 || containers[0].Status != "exited" || containers[0].Health != "unhealthy" {
+		t.Fatalf("unexpected state: %+v", containers)
+	}
+}
+
+func TestHostScopedContainers(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	seedContainer(t, repo, ctx, "web", "c1", now)
+	err := repo.UpsertServiceAndContainer(ctx,
+		models.Service{ID: "web@nas", Name: "web", Host: "nas", Image: "img", LabelsJSON: "{}", Status: "running"},
+		models.Container{ID: "c2", ServiceID: "web@nas", Host: "nas", Name: "web", Status: "running", LastSeenAt: now},
+	)
+	if err != nil {
+		t.Fatalf("seed remote container: %v", err)
+	}
+	if err := repo.InsertLogs(ctx, []models.LogEntry{
+		{TS: now, ServiceID: "web", ContainerID: "c1", Level: "INFO", Stream: "stdout", Message: "local"},
+		{TS: now, ServiceID: "web@nas", ContainerID: "c2", Level: "INFO", Stream: "stdout", Message: "remote"},
+	}); err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+
+	// A sweep of the nas host must not touch containers of the local host.
+	if err := repo.MarkMissingContainers(ctx, "nas", nil); err != nil {
+		t.Fatalf("mark missing: %v", err)
+	}
+	hosts, err := repo.ListHosts(ctx)
+	if err != nil {
+		t.Fatalf("list hosts: %v", err)
+	}
+	if len(hosts) != 2 || hosts[0].Name != "local" || hosts[0].Running != 1 || hosts[1].Name != "nas" || hosts[1].Running != 0 {
+		t.Fatalf("hosts = %+v", hosts)
+	}
+
+	entries, err := repo.QueryLogs(ctx, LogQuery{Host: "nas", Limit: 10})
+	if err != nil {
+		t.Fatalf("query logs: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Message != "remote" {
+		t.Fatalf("entries = %+v, want only the nas line", entries)
+	}
+}
+
+func TestImageUpdatesFollowServices(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	seedContainer(t, repo, ctx, "web", "c1", now)
+	u := models.ImageUpdate{ServiceID: "web", Image: "nginx:1.27", CurrentDigest: "sha256:a", LatestDigest: "sha256:b", UpdateAvailable: true, CheckedAt: now}
+	if err := repo.SaveImageUpdate(ctx, u); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	rows, err := repo.ListServicesWithHealth(ctx, 0, 0, 10, false, "", nil)
+	if err != nil {
+		t.Fatalf("list services: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["update"] != true {
+		t.Fatalf("services = %v, want update flag", rows)
+	}
+
+	if err := repo.MarkMissingContainers(ctx, "", nil); err != nil {
+		t.Fatalf("mark missing: %v", err)
+	}
+	if err := repo.PurgeContainer(ctx, "c1"); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	updates, err := repo.ListImageUpdates(ctx)
+	if err != nil {
+		t.Fatalf("list updates: %v", err)
+	}
+	if len(updates) != 0 {
+		t.Fatalf("updates after purge = %+v", updates)
+	}
+}
+
+func TestServiceDetailKeepsPortsAndNetworks(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	ports := []models.Port{{HostIP: "0.0.0.0", HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}, {ContainerPort: 443, Protocol: "tcp"}}
+	err := repo.UpsertServiceAndContainer(ctx,
+		models.Service{ID: "web", Name: "web", Image: "nginx", LabelsJSON: `{"env":"prod"}`, Status: "running"},
+		models.Container{ID: "c1", ServiceID: "web", Name: "web-1", Status: "running", LastSeenAt: time.Now(),
+			Ports: ports, Networks: []models.Network{{Name: "bridge", IP: "172.17.0.2"}}},
+	)
+	if err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	svc, containers, err := repo.ServiceDetail(ctx, "web")
+	if err != nil {
+		t.Fatalf("service detail: %v", err)
+	}
+	if svc.Image != "nginx" || len(containers) != 1 {
+		t.Fatalf("detail = %+v %+v", svc, containers)
+	}
+	c := containers[0]
+	if len(c.Ports) != 2 || c.Ports[0] != ports[0] || c.Ports[1] != ports[1] {
+		t.Fatalf("ports = %+v", c.Ports)
+	}
+	if len(c.Networks) != 1 || c.Networks[0].IP != "172.17.0.2" {
+		t.Fatalf("networks = %+v", c.Networks)
+	}
+	rows, err := repo.ListServicesWithHealth(ctx, 0, 0, 10, false, "", nil)
+	if err != nil {
+		t.Fatalf("list services: %v", err)
+	}
+	if len(rows) != 1 || len(rows[0]["ports"].([]models.Port)) != 2 {
+		t.Fatalf("service rows = %+v", rows)
+	}
+	if _, _, err := repo.ServiceDetail(ctx, "nope"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("unknown service err = %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+
+	"dashi/internal/models"
+)
+
+// Dashboards returns the stored dashboard layouts by name.
+func (r *Repository) Dashboards(ctx context.Context) ([]models.Dashboard, error) {
+	rows, err := r.query(ctx, `SELECT name,widgets_json,updated_at FROM dashboards ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.Dashboard
+	for rows.Next() {
+		d, err := scanDashboard(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// Dashboard returns the layout stored as name, sql.ErrNoRows if there is
+// none.
+func (r *Repository) Dashboard(ctx context.Context, name string) (models.Dashboard, error) {
+	return scanDashboard(r.queryRow(ctx, `SELECT name,widgets_json,updated_at FROM dashboards WHERE name=?`, name))
+}
+
+// SaveDashboard stores d, replacing a layout of the same name.
+func (r *Repository) SaveDashboard(ctx context.Context, d models.Dashboard) error {
+	widgets, err := json.Marshal(d.Widgets)
+	if err != nil {
+		return err
+	}
+	_, err = r.exec(ctx, `INSERT INTO dashboards (name,widgets_json,updated_at) VALUES (?,?,?)
+		ON CONFLICT(name) DO UPDATE SET widgets_json=excluded.widgets_json,updated_at=excluded.updated_at`,
+		d.Name, string(widgets), d.UpdatedAt.UTC())
+	return err
+}
+
+// DeleteDashboard removes the layout stored as name and reports whether
+// there was one.
+func (r *Repository) DeleteDashboard(ctx context.Context, name string) (bool, error) {
+	res, err := r.exec(ctx, `DELETE FROM dashboards WHERE name=?`, name)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanDashboard(row interface{ Scan(...any) error }) (models.Dashboard, error) {
+	var d models.Dashboard
+	var widgets string
+	if err := row.Scan(&d.Name, &widgets, &d.UpdatedAt); err != nil {
+		return d, err
+	}
+	err := json.Unmarshal([]byte(widgets), &d.Widgets)
+	return d, err
+}
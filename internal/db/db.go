@@ -6,17 +6,33 @@ import (
 	"os"
 	"path/filepath"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 func Open(path string) (*sql.DB, error) {
+	return OpenWithLogs(path, "")
+}
+
+// OpenWithLogs opens the SQLite database at path and, when logsPath is set,
+// attaches a second file there to hold the logs table, so log churn and
+// retention deletes do not bloat or lock the metrics and alerts database.
+func OpenWithLogs(path, logsPath string) (*sql.DB, error) {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return nil, fmt.Errorf("mkdir data dir: %w", err)
 	}
 	dsn := fmt.Sprintf("file:%s?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000", path)
-	db, err := sql.Open("sqlite3", dsn)
-	if err != nil {
-		return nil, err
+	var db *sql.DB
+	if logsPath == "" {
+		var err error
+		if db, err = sql.Open("sqlite3", dsn); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(logsPath), 0o755); err != nil {
+			return nil, fmt.Errorf("mkdir logs dir: %w", err)
+		}
+		db = sql.OpenDB(attachLogsConnector(dsn, logsPath))
 	}
 	if err := db.Ping(); err != nil {
 		_ = db.Close()
@@ -29,8 +45,41 @@ func Open(path string) (*sql.DB, error) {
 	return db, nil
 }
 
+// OpenPostgres connects to a PostgreSQL database given a libpq URL or DSN.
+func OpenPostgres(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("connect postgres: %w", err)
+	}
+	return db, nil
+}
+
 func Migrate(db *sql.DB) error {
+	d := DialectOf(db)
 	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS hosts (
+			id INTEGER PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE,
+			first_seen_at DATETIME NOT NULL,
+			last_seen_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS agents (
+			host_id INTEGER PRIMARY KEY,
+			os TEXT NOT NULL,
+			arch TEXT NOT NULL,
+			kernel TEXT NOT NULL,
+			docker_version TEXT NOT NULL,
+			interval_sec INTEGER NOT NULL,
+			remote_addr TEXT NOT NULL,
+			registered_at DATETIME NOT NULL,
+			last_heartbeat_at DATETIME NOT NULL,
+			deadline DATETIME NOT NULL,
+			FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE
+		);`,
 		`CREATE TABLE IF NOT EXISTS services (
 			id TEXT PRIMARY KEY,
 			name TEXT NOT NULL,
@@ -64,6 +113,20 @@ func Migrate(db *sql.DB) error {
 			load15 REAL NOT NULL,
 			uptime_sec INTEGER NOT NULL
 		);`,
+		`CREATE TABLE IF NOT EXISTS disk_io (
+			ts DATETIME NOT NULL,
+			device TEXT NOT NULL,
+			read_rate REAL NOT NULL,
+			write_rate REAL NOT NULL,
+			read_iops REAL NOT NULL,
+			write_iops REAL NOT NULL,
+			util_pct REAL NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS temperatures (
+			ts DATETIME NOT NULL,
+			sensor TEXT NOT NULL,
+			temp_c REAL NOT NULL
+		);`,
 		`CREATE TABLE IF NOT EXISTS container_metrics (
 			ts DATETIME NOT NULL,
 			container_id TEXT NOT NULL,
@@ -76,17 +139,6 @@ func Migrate(db *sql.DB) error {
 			blk_write_bytes INTEGER NOT NULL,
 			FOREIGN KEY(container_id) REFERENCES containers(id) ON DELETE CASCADE
 		);`,
-		`CREATE TABLE IF NOT EXISTS logs (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			ts DATETIME NOT NULL,
-			service_id TEXT NOT NULL,
-			container_id TEXT NOT NULL,
-			level TEXT NOT NULL,
-			stream TEXT NOT NULL,
-			message TEXT NOT NULL,
-			FOREIGN KEY(service_id) REFERENCES services(id) ON DELETE CASCADE,
-			FOREIGN KEY(container_id) REFERENCES containers(id) ON DELETE CASCADE
-		);`,
 		`CREATE TABLE IF NOT EXISTS alert_rules (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			name TEXT NOT NULL,
@@ -130,21 +182,405 @@ func Migrate(db *sql.DB) error {
 			sent_ts_nullable DATETIME,
 			FOREIGN KEY(alert_id) REFERENCES alerts(id) ON DELETE CASCADE
 		);`,
-		`CREATE INDEX IF NOT EXISTS idx_logs_service_ts ON logs(service_id, ts DESC);`,
-		`CREATE INDEX IF NOT EXISTS idx_logs_container_ts ON logs(container_id, ts DESC);`,
+		`CREATE TABLE IF NOT EXISTS labels (
+			service_id TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL,
+			PRIMARY KEY(service_id, key),
+			FOREIGN KEY(service_id) REFERENCES services(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS image_updates (
+			service_id TEXT PRIMARY KEY,
+			image TEXT NOT NULL,
+			current_digest TEXT NOT NULL,
+			latest_digest TEXT NOT NULL,
+			update_available INTEGER NOT NULL,
+			checked_at DATETIME NOT NULL,
+			error TEXT NOT NULL,
+			FOREIGN KEY(service_id) REFERENCES services(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS volume_usage (
+			ts DATETIME NOT NULL,
+			host TEXT NOT NULL,
+			name TEXT NOT NULL,
+			driver TEXT NOT NULL,
+			size_bytes INTEGER NOT NULL,
+			ref_count INTEGER NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS image_usage (
+			host TEXT NOT NULL,
+			id TEXT NOT NULL,
+			tags TEXT NOT NULL,
+			size_bytes INTEGER NOT NULL,
+			shared_bytes INTEGER NOT NULL,
+			containers INTEGER NOT NULL,
+			updated_at DATETIME NOT NULL,
+			PRIMARY KEY(host, id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ts DATETIME NOT NULL,
+			action TEXT NOT NULL,
+			target TEXT NOT NULL,
+			detail TEXT NOT NULL,
+			source TEXT NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS checks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			url TEXT NOT NULL,
+			method TEXT NOT NULL,
+			expect_status INTEGER NOT NULL,
+			expect_body TEXT NOT NULL,
+			interval_sec INTEGER NOT NULL,
+			timeout_sec INTEGER NOT NULL,
+			enabled INTEGER NOT NULL DEFAULT 1
+		);`,
+		`CREATE TABLE IF NOT EXISTS check_results (
+			check_id INTEGER NOT NULL,
+			ts DATETIME NOT NULL,
+			ok INTEGER NOT NULL,
+			status_code INTEGER NOT NULL,
+			latency_ms INTEGER NOT NULL,
+			error TEXT NOT NULL,
+			FOREIGN KEY(check_id) REFERENCES checks(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS heartbeats (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			token TEXT NOT NULL UNIQUE,
+			period_sec INTEGER NOT NULL,
+			grace_sec INTEGER NOT NULL,
+			created_at DATETIME NOT NULL,
+			last_ping_at DATETIME,
+			last_fail_at DATETIME
+		);`,
+		`CREATE TABLE IF NOT EXISTS deployments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ts DATETIME NOT NULL,
+			service_id TEXT NOT NULL,
+			source TEXT NOT NULL,
+			version TEXT NOT NULL,
+			environment TEXT NOT NULL,
+			description TEXT NOT NULL,
+			url TEXT NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS log_archives (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			object_key TEXT NOT NULL UNIQUE,
+			day TEXT NOT NULL,
+			service_id TEXT NOT NULL,
+			from_ts DATETIME NOT NULL,
+			to_ts DATETIME NOT NULL,
+			lines INTEGER NOT NULL,
+			bytes INTEGER NOT NULL,
+			cutoff DATETIME NOT NULL,
+			created_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS host_reboots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			booted_at DATETIME NOT NULL,
+			last_seen_at DATETIME NOT NULL,
+			prev_uptime_sec INTEGER NOT NULL,
+			detected_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS restart_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			container_id TEXT NOT NULL,
+			service_id TEXT NOT NULL,
+			host TEXT NOT NULL,
+			name TEXT NOT NULL,
+			reason TEXT NOT NULL,
+			prev_container_id TEXT NOT NULL,
+			exit_code INTEGER,
+			restart_count INTEGER NOT NULL,
+			finished_at DATETIME,
+			started_at DATETIME,
+			detected_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS container_status_changes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			container_id TEXT NOT NULL,
+			service_id TEXT NOT NULL,
+			status TEXT NOT NULL,
+			ts DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS alert_notes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			alert_id INTEGER NOT NULL,
+			ts DATETIME NOT NULL,
+			author TEXT NOT NULL,
+			text TEXT NOT NULL,
+			FOREIGN KEY(alert_id) REFERENCES alerts(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS storage_pools (
+			type TEXT NOT NULL,
+			name TEXT NOT NULL,
+			health TEXT NOT NULL,
+			size_bytes INTEGER NOT NULL,
+			alloc_bytes INTEGER NOT NULL,
+			device_errors INTEGER NOT NULL,
+			data_errors INTEGER NOT NULL,
+			scrub_state TEXT NOT NULL,
+			scrub_errors INTEGER NOT NULL,
+			scrub_at DATETIME,
+			checked_at DATETIME NOT NULL,
+			PRIMARY KEY(type, name)
+		);`,
+		`CREATE TABLE IF NOT EXISTS clock_offsets (
+			host TEXT NOT NULL,
+			source TEXT NOT NULL,
+			offset_ms REAL NOT NULL,
+			checked_at DATETIME NOT NULL,
+			error TEXT NOT NULL,
+			PRIMARY KEY(host, source)
+		);`,
+		`CREATE TABLE IF NOT EXISTS scrape_samples (
+			ts DATETIME NOT NULL,
+			target TEXT NOT NULL,
+			metric TEXT NOT NULL,
+			labels TEXT NOT NULL,
+			value REAL NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS sites (
+			name TEXT PRIMARY KEY,
+			url TEXT NOT NULL,
+			checked_at DATETIME NOT NULL,
+			last_ok_at DATETIME,
+			error TEXT NOT NULL,
+			summary_json TEXT NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS service_log_metrics (
+			ts DATETIME NOT NULL,
+			service_id TEXT NOT NULL,
+			lines INTEGER NOT NULL,
+			error_lines INTEGER NOT NULL,
+			bytes INTEGER NOT NULL,
+			requests INTEGER NOT NULL DEFAULT 0,
+			requests_5xx INTEGER NOT NULL DEFAULT 0,
+			timed_requests INTEGER NOT NULL DEFAULT 0,
+			latency_ms REAL NOT NULL DEFAULT 0,
+			PRIMARY KEY(service_id, ts)
+		);`,
+		`CREATE TABLE IF NOT EXISTS self_metrics (
+			ts DATETIME NOT NULL,
+			log_lines_rate REAL NOT NULL,
+			log_dropped_rate REAL NOT NULL,
+			log_write_errors INTEGER NOT NULL,
+			log_workers INTEGER NOT NULL,
+			db_size_bytes INTEGER NOT NULL,
+			query_rate REAL NOT NULL,
+			query_avg_ms REAL NOT NULL,
+			goroutines INTEGER NOT NULL,
+			heap_bytes INTEGER NOT NULL,
+			notify_failures INTEGER NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS log_file_positions (
+			path TEXT PRIMARY KEY,
+			file_id TEXT NOT NULL,
+			pos INTEGER NOT NULL,
+			updated_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS log_stream_positions (
+			container_id TEXT PRIMARY KEY,
+			ts_ns INTEGER NOT NULL,
+			lines INTEGER NOT NULL,
+			updated_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS settings (key TEXT PRIMARY KEY, value TEXT NOT NULL);`,
+		`CREATE TABLE IF NOT EXISTS ingest_keys (
+			key TEXT PRIMARY KEY,
+			result_json TEXT NOT NULL,
+			created_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS preferences (
+			owner TEXT PRIMARY KEY,
+			prefs_json TEXT NOT NULL,
+			updated_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS dashboards (
+			name TEXT PRIMARY KEY,
+			widgets_json TEXT NOT NULL,
+			updated_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS host_metrics_rollup (
+			resolution_sec INTEGER NOT NULL,
+			bucket INTEGER NOT NULL,
+			samples INTEGER NOT NULL,
+			cpu_pct REAL NOT NULL,
+			cpu_pct_min REAL NOT NULL,
+			cpu_pct_max REAL NOT NULL,
+			mem_used_bytes INTEGER NOT NULL,
+			mem_used_max INTEGER NOT NULL,
+			mem_total_bytes INTEGER NOT NULL,
+			net_rx_bytes INTEGER NOT NULL,
+			net_tx_bytes INTEGER NOT NULL,
+			disk_used_bytes INTEGER NOT NULL,
+			disk_total_bytes INTEGER NOT NULL,
+			load1 REAL NOT NULL,
+			load1_max REAL NOT NULL,
+			load5 REAL NOT NULL,
+			load15 REAL NOT NULL,
+			uptime_sec INTEGER NOT NULL,
+			PRIMARY KEY(resolution_sec, bucket)
+		);`,
+		`CREATE TABLE IF NOT EXISTS container_metrics_rollup (
+			resolution_sec INTEGER NOT NULL,
+			bucket INTEGER NOT NULL,
+			container_id TEXT NOT NULL,
+			samples INTEGER NOT NULL,
+			cpu_pct REAL NOT NULL,
+			cpu_pct_min REAL NOT NULL,
+			cpu_pct_max REAL NOT NULL,
+			mem_used_bytes INTEGER NOT NULL,
+			mem_used_max INTEGER NOT NULL,
+			mem_limit_bytes INTEGER NOT NULL,
+			net_rx_bytes INTEGER NOT NULL,
+			net_tx_bytes INTEGER NOT NULL,
+			blk_read_bytes INTEGER NOT NULL,
+			blk_write_bytes INTEGER NOT NULL,
+			PRIMARY KEY(resolution_sec, container_id, bucket)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_labels_key_value ON labels(key, value);`,
 		`CREATE INDEX IF NOT EXISTS idx_host_metrics_ts ON host_metrics(ts DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_service_log_metrics_ts ON service_log_metrics(ts);`,
+		`CREATE INDEX IF NOT EXISTS idx_scrape_samples_series_ts ON scrape_samples(target, metric, ts);`,
+		`CREATE INDEX IF NOT EXISTS idx_scrape_samples_ts ON scrape_samples(ts);`,
+		`CREATE INDEX IF NOT EXISTS idx_disk_io_ts ON disk_io(ts);`,
+		`CREATE INDEX IF NOT EXISTS idx_self_metrics_ts ON self_metrics(ts);`,
+		`CREATE INDEX IF NOT EXISTS idx_deployments_ts ON deployments(ts);`,
+		`CREATE INDEX IF NOT EXISTS idx_restart_events_service ON restart_events(service_id, detected_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_restart_events_detected ON restart_events(detected_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_container_status_changes ON container_status_changes(container_id, ts);`,
+		`CREATE INDEX IF NOT EXISTS idx_container_status_changes_service ON container_status_changes(service_id, ts);`,
+		`CREATE INDEX IF NOT EXISTS idx_alert_notes ON alert_notes(alert_id, ts);`,
+		`CREATE INDEX IF NOT EXISTS idx_log_archives_day ON log_archives(day, service_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_temperatures_ts ON temperatures(ts);`,
+		`CREATE INDEX IF NOT EXISTS idx_check_results_check_ts ON check_results(check_id, ts);`,
 		`CREATE INDEX IF NOT EXISTS idx_container_metrics_container_ts ON container_metrics(container_id, ts DESC);`,
 		`CREATE INDEX IF NOT EXISTS idx_alerts_status_started ON alerts(status, started_ts DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_volume_usage_host_name_ts ON volume_usage(host, name, ts);`,
 	}
 	for _, stmt := range stmts {
-		if _, err := db.Exec(stmt); err != nil {
+		if _, err := db.Exec(d.DDL(stmt)); err != nil {
 			return fmt.Errorf("migrate failed: %w", err)
 		}
 	}
-	return seedDefaultRules(db)
+	columns := []struct{ table, column, def string }{
+		{"alert_rules", "label_selector", "TEXT NOT NULL DEFAULT ''"},
+		{"containers", "health", "TEXT NOT NULL DEFAULT ''"},
+		// Docker endpoint names; rows from before multi-host are "local".
+		{"services", "host", "TEXT NOT NULL DEFAULT 'local'"},
+		{"containers", "host", "TEXT NOT NULL DEFAULT 'local'"},
+		// Published ports, attached networks and links from inspect, as JSON
+		// lists.
+		{"containers", "ports_json", "TEXT NOT NULL DEFAULT '[]'"},
+		{"containers", "networks_json", "TEXT NOT NULL DEFAULT '[]'"},
+		{"containers", "links_json", "TEXT NOT NULL DEFAULT '[]'"},
+		// Per-second rates derived from the cumulative byte counters.
+		{"host_metrics", "net_rx_rate", "REAL NOT NULL DEFAULT 0"},
+		{"host_metrics", "net_tx_rate", "REAL NOT NULL DEFAULT 0"},
+		{"container_metrics", "net_rx_rate", "REAL NOT NULL DEFAULT 0"},
+		{"container_metrics", "net_tx_rate", "REAL NOT NULL DEFAULT 0"},
+		{"container_metrics", "blk_read_rate", "REAL NOT NULL DEFAULT 0"},
+		{"container_metrics", "blk_write_rate", "REAL NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "net_rx_rate", "REAL NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "net_tx_rate", "REAL NOT NULL DEFAULT 0"},
+		{"container_metrics_rollup", "net_rx_rate", "REAL NOT NULL DEFAULT 0"},
+		{"container_metrics_rollup", "net_tx_rate", "REAL NOT NULL DEFAULT 0"},
+		{"container_metrics_rollup", "blk_read_rate", "REAL NOT NULL DEFAULT 0"},
+		{"container_metrics_rollup", "blk_write_rate", "REAL NOT NULL DEFAULT 0"},
+		// Process and open file counts; rollups keep the bucket maximum.
+		{"container_metrics", "pids", "INTEGER NOT NULL DEFAULT 0"},
+		{"container_metrics", "pids_limit", "INTEGER NOT NULL DEFAULT 0"},
+		{"container_metrics", "fds", "INTEGER NOT NULL DEFAULT 0"},
+		{"container_metrics", "fd_limit", "INTEGER NOT NULL DEFAULT 0"},
+		{"container_metrics_rollup", "pids", "INTEGER NOT NULL DEFAULT 0"},
+		{"container_metrics_rollup", "pids_limit", "INTEGER NOT NULL DEFAULT 0"},
+		{"container_metrics_rollup", "fds", "INTEGER NOT NULL DEFAULT 0"},
+		{"container_metrics_rollup", "fd_limit", "INTEGER NOT NULL DEFAULT 0"},
+		// Disk I/O summed over physical devices; util is the busiest device.
+		{"host_metrics", "disk_read_rate", "REAL NOT NULL DEFAULT 0"},
+		{"host_metrics", "disk_write_rate", "REAL NOT NULL DEFAULT 0"},
+		{"host_metrics", "disk_read_iops", "REAL NOT NULL DEFAULT 0"},
+		{"host_metrics", "disk_write_iops", "REAL NOT NULL DEFAULT 0"},
+		{"host_metrics", "disk_util_pct", "REAL NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "disk_read_rate", "REAL NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "disk_write_rate", "REAL NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "disk_read_iops", "REAL NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "disk_write_iops", "REAL NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "disk_util_pct", "REAL NOT NULL DEFAULT 0"},
+		// Hottest CPU and NVMe sensors; rollups keep the bucket maximum.
+		{"host_metrics", "cpu_temp_c", "REAL NOT NULL DEFAULT 0"},
+		{"host_metrics", "nvme_temp_c", "REAL NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "cpu_temp_c", "REAL NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "nvme_temp_c", "REAL NOT NULL DEFAULT 0"},
+		{"host_metrics", "swap_used_bytes", "INTEGER NOT NULL DEFAULT 0"},
+		{"host_metrics", "swap_total_bytes", "INTEGER NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "swap_used_bytes", "INTEGER NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "swap_total_bytes", "INTEGER NOT NULL DEFAULT 0"},
+		// TCP socket counts by state; rollups keep the bucket maximum.
+		{"host_metrics", "tcp_established", "INTEGER NOT NULL DEFAULT 0"},
+		{"host_metrics", "tcp_syn_recv", "INTEGER NOT NULL DEFAULT 0"},
+		{"host_metrics", "tcp_time_wait", "INTEGER NOT NULL DEFAULT 0"},
+		{"host_metrics", "tcp_close_wait", "INTEGER NOT NULL DEFAULT 0"},
+		{"host_metrics", "tcp_listen", "INTEGER NOT NULL DEFAULT 0"},
+		{"host_metrics", "tcp_conns", "INTEGER NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "tcp_established", "INTEGER NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "tcp_syn_recv", "INTEGER NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "tcp_time_wait", "INTEGER NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "tcp_close_wait", "INTEGER NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "tcp_listen", "INTEGER NOT NULL DEFAULT 0"},
+		{"host_metrics_rollup", "tcp_conns", "INTEGER NOT NULL DEFAULT 0"},
+		{"service_log_metrics", "requests", "INTEGER NOT NULL DEFAULT 0"},
+		{"service_log_metrics", "requests_5xx", "INTEGER NOT NULL DEFAULT 0"},
+		{"service_log_metrics", "timed_requests", "INTEGER NOT NULL DEFAULT 0"},
+		{"service_log_metrics", "latency_ms", "REAL NOT NULL DEFAULT 0"},
+		// Panics recovered by the worker supervisors.
+		{"self_metrics", "panics", "INTEGER NOT NULL DEFAULT 0"},
+		// Pushed log lines dropped under backpressure.
+		{"self_metrics", "log_overflow", "INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, c := range columns {
+		if err := addColumn(db, d, c.table, c.column, c.def); err != nil {
+			return fmt.Errorf("migrate failed: %w", err)
+		}
+	}
+	if err := migrateLegacySettings(db, d); err != nil {
+		return err
+	}
+	if err := backfillLabels(db, d); err != nil {
+		return err
+	}
+	if err := migrateLogs(db, d); err != nil {
+		return err
+	}
+	if err := migrateHosts(db, d); err != nil {
+		return err
+	}
+	return seedDefaultRules(db, d)
 }
 
-func seedDefaultRules(db *sql.DB) error {
+// addColumn adds a column to an existing table unless it is already there.
+// def is written in SQLite types and translated like other DDL.
+func addColumn(db *sql.DB, d Dialect, table, column, def string) error {
+	def = d.DDL(def)
+	if d == Postgres {
+		_, err := db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN IF NOT EXISTS ` + column + ` ` + def)
+		return err
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err := db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + def)
+	return err
+}
+
+func seedDefaultRules(db *sql.DB, d Dialect) error {
 	defaults := []struct {
 		name, targetType, metricKey, op string
 		th                              float64
@@ -153,13 +589,35 @@ func seedDefaultRules(db *sql.DB) error {
 		{"Host CPU high", "host", "host_cpu_pct", ">", 90, 120, 600},
 		{"Host memory high", "host", "host_mem_pct", ">", 90, 120, 600},
 		{"Host disk high", "host", "host_disk_pct", ">", 85, 300, 1800},
+		{"Host swap high", "host", "host_swap_pct", ">", 50, 300, 1800},
 		{"Container unavailable", "container", "container_unavailable", ">=", 1, 60, 600},
+		{"Container unhealthy", "container", "container_unhealthy", ">=", 1, 30, 600},
 		{"Container restarted", "container", "container_restarts", ">=", 1, 0, 60},
+		{"Volume growth", "volume", "volume_growth_bytes", ">", 10 << 30, 0, 21600},
+		{"Check down", "check", "check_down", ">=", 1, 60, 600},
+		{"Heartbeat missed", "heartbeat", "heartbeat_down", ">=", 1, 0, 3600},
+		{"Agent offline", "agent", "agent_offline", ">=", 1, 0, 3600},
+		{"Scrape target down", "scrape", "up", "<", 1, 60, 3600},
+		{"Clock drift", "host", "host_clock_drift_ms", ">", 1000, 0, 21600},
+		{"Host rebooted", "host", "host_rebooted", ">=", 1, 0, 0},
+		{"Pool degraded", "pool", "pool_degraded", ">=", 1, 0, 3600},
+		{"Pool scrub errors", "pool", "pool_scrub_errors", ">=", 1, 0, 86400},
+		{"Logs sampled", "container", "container_logs_sampled", ">=", 1, 0, 3600},
+		{"Error logs spiking", "container", "service_error_log_rate", ">", 10, 300, 1800},
+		{"HTTP 5xx responses high", "container", "service_5xx_pct", ">", 5, 300, 1800},
+		{"Log writes failing", "dashi", "dashi_log_write_errors", ">=", 1, 0, 1800},
 	}
 	for _, r := range defaults {
-		_, err := db.Exec(`INSERT INTO alert_rules (name,target_type,metric_key,operator,threshold,for_seconds,cooldown_seconds,enabled)
-			SELECT ?,?,?,?,?,?,?,1 WHERE NOT EXISTS (SELECT 1 FROM alert_rules WHERE name = ?)`,
-			r.name, r.targetType, r.metricKey, r.op, r.th, r.forSec, r.cooldown, r.name)
+		var n int
+		if err := db.QueryRow(d.Rebind(`SELECT COUNT(*) FROM alert_rules WHERE name = ?`), r.name).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		_, err := db.Exec(d.Rebind(`INSERT INTO alert_rules (name,target_type,metric_key,operator,threshold,for_seconds,cooldown_seconds,enabled)
+			VALUES (?,?,?,?,?,?,?,1)`),
+			r.name, r.targetType, r.metricKey, r.op, r.th, r.forSec, r.cooldown)
 		if err != nil {
 			return err
 		}
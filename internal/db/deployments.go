@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func (r *Repository) InsertDeployment(ctx context.Context, d models.Deployment) (int64, error) {
+	return r.insertID(ctx, `INSERT INTO deployments (ts,service_id,source,version,environment,description,url) VALUES (?,?,?,?,?,?,?)`,
+		d.TS.UTC(), d.ServiceID, d.Source, d.Version, d.Environment, d.Description, d.URL)
+}
+
+// Deployments returns up to limit deployments between from and to, newest
+// first; with a service, only its own, and with a host, only those of its
+// services.
+func (r *Repository) Deployments(ctx context.Context, from, to time.Time, serviceID, host string, limit int) ([]models.Deployment, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	filter := ""
+	args := []any{from.UTC(), to.UTC()}
+	if serviceID != "" {
+		filter += " AND service_id=?"
+		args = append(args, serviceID)
+	}
+	if host != "" {
+		filter += " AND service_id IN (SELECT id FROM services WHERE host=?)"
+		args = append(args, host)
+	}
+	rows, err := r.query(ctx, `SELECT id,ts,service_id,source,version,environment,description,url FROM deployments
+		WHERE ts >= ? AND ts <= ?`+filter+` ORDER BY ts DESC, id DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.Deployment
+	for rows.Next() {
+		var d models.Deployment
+		if err := rows.Scan(&d.ID, &d.TS, &d.ServiceID, &d.Source, &d.Version, &d.Environment, &d.Description, &d.URL); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repository) DeleteDeploymentsOlderThan(ctx context.Context, cutoff time.Time) error {
+	_, err := r.exec(ctx, `DELETE FROM deployments WHERE ts < ?`, cutoff.UTC())
+	return err
+}
+
+// ResolveService returns the ID of the service with the given ID, or else
+// of one named so, preferring the local host's; sql.ErrNoRows without one.
+func (r *Repository) ResolveService(ctx context.Context, idOrName string) (string, error) {
+	var id string
+	err := r.queryRow(ctx, `SELECT id FROM services WHERE id=? OR name=?
+		ORDER BY CASE WHEN id=? THEN 0 WHEN host='local' THEN 1 ELSE 2 END, id LIMIT 1`, idOrName, idOrName, idOrName).Scan(&id)
+	return id, err
+}
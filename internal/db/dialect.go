@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Dialect captures the SQL differences between the supported backends.
+// Repository SQL is written for SQLite with ? placeholders and translated
+// on the way out.
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+func DialectOf(db *sql.DB) Dialect {
+	if _, ok := db.Driver().(*pq.Driver); ok {
+		return Postgres
+	}
+	return SQLite
+}
+
+// IsConstraint reports whether err is a constraint violation of either
+// backend, e.g. a row referring to a purged container, which retrying the
+// same write does not fix.
+func IsConstraint(err error) bool {
+	var se sqlite3.Error
+	if errors.As(err, &se) {
+		return se.Code == sqlite3.ErrConstraint
+	}
+	var pe *pq.Error
+	return errors.As(err, &pe) && pe.Code.Class() == "23"
+}
+
+// Rebind rewrites ? placeholders to $n for Postgres, leaving quoted
+// literals untouched.
+func (d Dialect) Rebind(q string) string {
+	if d != Postgres {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	inQuote := false
+	for _, r := range q {
+		switch {
+		case r == '\'':
+			inQuote = !inQuote
+			b.WriteRune(r)
+		case r == '?' && !inQuote:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+var ddlReplacements = []struct {
+	re   *regexp.Regexp
+	with string
+}{
+	{regexp.MustCompile(`\bINTEGER PRIMARY KEY AUTOINCREMENT\b`), "BIGSERIAL PRIMARY KEY"},
+	{regexp.MustCompile(`\bDATETIME\b`), "TIMESTAMPTZ"},
+	{regexp.MustCompile(`\bREAL\b`), "DOUBLE PRECISION"},
+	{regexp.MustCompile(`\bINTEGER\b`), "BIGINT"},
+}
+
+// DDL translates SQLite column types in a CREATE statement.
+func (d Dialect) DDL(stmt string) string {
+	if d != Postgres {
+		return stmt
+	}
+	for _, rep := range ddlReplacements {
+		stmt = rep.re.ReplaceAllString(stmt, rep.with)
+	}
+	return stmt
+}
+
+// Epoch returns an expression yielding col as integer unix seconds.
+func (d Dialect) Epoch(col string) string {
+	if d == Postgres {
+		return "CAST(EXTRACT(EPOCH FROM " + col + ") AS BIGINT)"
+	}
+	return "CAST(strftime('%s', " + col + ") AS INTEGER)"
+}
+
+// JSONText returns an expression yielding the text of key in the JSON
+// object stored in col. key is inlined and has to match FieldName.
+func (d Dialect) JSONText(col, key string) string {
+	if d == Postgres {
+		return "(" + col + "::jsonb ->> '" + key + "')"
+	}
+	return "json_extract(" + col + ", '$." + key + "')"
+}
+
+func (r *Repository) exec(ctx context.Context, q string, args ...any) (sql.Result, error) {
+	defer r.queries.observe(time.Now())
+	return r.db.ExecContext(ctx, r.dialect.Rebind(q), args...)
+}
+
+func (r *Repository) query(ctx context.Context, q string, args ...any) (*sql.Rows, error) {
+	defer r.queries.observe(time.Now())
+	return r.db.QueryContext(ctx, r.dialect.Rebind(q), args...)
+}
+
+func (r *Repository) queryRow(ctx context.Context, q string, args ...any) *sql.Row {
+	defer r.queries.observe(time.Now())
+	return r.db.QueryRowContext(ctx, r.dialect.Rebind(q), args...)
+}
+
+// insertID runs an INSERT and returns the new row id. Postgres has no
+// LastInsertId, so the statement is extended with RETURNING id.
+func (r *Repository) insertID(ctx context.Context, q string, args ...any) (int64, error) {
+	if r.dialect == Postgres {
+		var id int64
+		err := r.queryRow(ctx, q+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+	res, err := r.exec(ctx, q, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
@@ -0,0 +1,22 @@
+package db
+
+import "testing"
+
+func TestPostgresRebind(t *testing.T) {
+	got := Postgres.Rebind(`SELECT * FROM logs WHERE level = ? AND message != '?' AND ts >= ?`)
+	want := `SELECT * FROM logs WHERE level = $1 AND message != '?' AND ts >= $2`
+	if got != want {
+		t.Fatalf("rebind = %q, want %q", got, want)
+	}
+	if SQLite.Rebind("a = ?") != "a = ?" {
+		t.Fatal("sqlite rebind should be a no-op")
+	}
+}
+
+func TestPostgresDDL(t *testing.T) {
+	got := Postgres.DDL(`CREATE TABLE t (id INTEGER PRIMARY KEY AUTOINCREMENT, ts DATETIME NOT NULL, v REAL, n INTEGER)`)
+	want := `CREATE TABLE t (id BIGSERIAL PRIMARY KEY, ts TIMESTAMPTZ NOT NULL, v DOUBLE PRECISION, n BIGINT)`
+	if got != want {
+		t.Fatalf("ddl = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"dashi/internal/models"
+)
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func (r *Repository) insertDiskIO(ctx context.Context, ex execer, disks []models.DiskIO) error {
+	for _, d := range disks {
+		if _, err := ex.ExecContext(ctx, r.dialect.Rebind(`INSERT INTO disk_io (ts,device,read_rate,write_rate,read_iops,write_iops,util_pct) VALUES (?,?,?,?,?,?,?)`),
+			d.TS.UTC(), d.Device, d.ReadRate, d.WriteRate, d.ReadIOPS, d.WriteIOPS, d.UtilPct); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecentDiskIO returns per-device disk I/O samples since from, oldest first.
+func (r *Repository) RecentDiskIO(ctx context.Context, from time.Time, limit int) ([]models.DiskIO, error) {
+	rows, err := r.query(ctx, `SELECT ts,device,read_rate,write_rate,read_iops,write_iops,util_pct
+		FROM disk_io WHERE ts >= ? ORDER BY ts ASC, device ASC LIMIT ?`, from.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.DiskIO
+	for rows.Next() {
+		var d models.DiskIO
+		if err := rows.Scan(&d.TS, &d.Device, &d.ReadRate, &d.WriteRate, &d.ReadIOPS, &d.WriteIOPS, &d.UtilPct); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
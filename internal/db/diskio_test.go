@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestDiskIOStoredWithHostMetric(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+
+	hm := models.HostMetric{TS: now, DiskReadRate: 3072, DiskWriteRate: 1024, DiskUtilPct: 40, Disks: []models.DiskIO{
+		{TS: now, Device: "nvme0n1", ReadRate: 2048, WriteRate: 1024, UtilPct: 40},
+		{TS: now, Device: "sda", ReadRate: 1024, UtilPct: 5},
+	}}
+	if err := repo.InsertMetricsBatch(ctx, []models.HostMetric{hm}, nil); err != nil {
+		t.Fatalf("insert batch: %v", err)
+	}
+	latest, err := repo.LatestHostMetric(ctx)
+	if err != nil {
+		t.Fatalf("latest host metric: %v", err)
+	}
+	if latest.DiskReadRate != 3072 || latest.DiskUtilPct != 40 {
+		t.Fatalf("latest = %+v", latest)
+	}
+	disks, err := repo.RecentDiskIO(ctx, now.Add(-time.Hour), 10)
+	if err != nil {
+		t.Fatalf("recent disk io: %v", err)
+	}
+	if len(disks) != 2 || disks[0].Device != "nvme0n1" || disks[1].ReadRate != 1024 {
+		t.Fatalf("disks = %+v", disks)
+	}
+
+	if err := repo.DeleteMetricsOlderThan(ctx, now.Add(time.Minute)); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if disks, _ = repo.RecentDiskIO(ctx, now.Add(-time.Hour), 10); len(disks) != 0 {
+		t.Fatalf("disks after retention = %+v", disks)
+	}
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// migrateLogsFTS maintains logs_fts, an external-content FTS5 index over
+// logs.message. FTS5 is only compiled into go-sqlite3 with the sqlite_fts5
+// build tag; without it the triggers are dropped so ingestion keeps working
+// and searches fall back to LIKE. schema names the database holding logs
+// ("" for main).
+func migrateLogsFTS(db *sql.DB, schema string) error {
+	if _, err := db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS ` + qualify(schema, "logs_fts") + ` USING fts5(message, content='logs', content_rowid='id')`); err != nil {
+		for _, stmt := range []string{
+			`DROP TRIGGER IF EXISTS ` + qualify(schema, "logs_fts_ai"),
+			`DROP TRIGGER IF EXISTS ` + qualify(schema, "logs_fts_ad"),
+		} {
+			if _, err := db.Exec(stmt); err != nil {
+				return fmt.Errorf("drop logs fts trigger: %w", err)
+			}
+		}
+		return nil
+	}
+	var triggers int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM ` + qualify(schema, "sqlite_master") + ` WHERE type='trigger' AND name IN ('logs_fts_ai','logs_fts_ad')`).Scan(&triggers); err != nil {
+		return err
+	}
+	if triggers == 2 {
+		return nil
+	}
+	stmts := []string{
+		`CREATE TRIGGER IF NOT EXISTS ` + qualify(schema, "logs_fts_ai") + ` AFTER INSERT ON logs BEGIN
+			INSERT INTO logs_fts(rowid, message) VALUES (new.id, new.message);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS ` + qualify(schema, "logs_fts_ad") + ` AFTER DELETE ON logs BEGIN
+			INSERT INTO logs_fts(logs_fts, rowid, message) VALUES ('delete', old.id, old.message);
+		END;`,
+		// Index whatever was ingested while the triggers were missing.
+		`INSERT INTO ` + qualify(schema, "logs_fts") + `(logs_fts) VALUES ('rebuild')`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("migrate logs fts: %w", err)
+		}
+	}
+	return nil
+}
+
+func hasLogsFTS(db *sql.DB) bool {
+	_, err := db.Exec(`SELECT rowid FROM logs_fts WHERE 0`)
+	return err == nil
+}
+
+// ftsQuery turns user search input into a safe FTS5 MATCH expression.
+// Quoted phrases, AND/OR/NOT, parentheses and a trailing * for prefix
+// matches are kept; every other token is quoted so punctuation in log
+// text (paths, "key=value", colons) cannot produce syntax errors.
+func ftsQuery(q string) string {
+	var out []string
+	for _, tok := range splitSearchTokens(q) {
+		switch {
+		case tok == "AND" || tok == "OR" || tok == "NOT" || tok == "(" || tok == ")":
+			out = append(out, tok)
+		case strings.HasPrefix(tok, `"`):
+			phrase := strings.Trim(tok, `"`)
+			if phrase != "" {
+				out = append(out, `"`+strings.ReplaceAll(phrase, `"`, `""`)+`"`)
+			}
+		default:
+			prefix := strings.HasSuffix(tok, "*")
+			tok = strings.TrimRight(tok, "*")
+			if tok == "" {
+				continue
+			}
+			term := `"` + strings.ReplaceAll(tok, `"`, `""`) + `"`
+			if prefix {
+				term += "*"
+			}
+			out = append(out, term)
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+func splitSearchTokens(q string) []string {
+	var out []string
+	var cur strings.Builder
+	inQuote := false
+	flush := func() {
+		if cur.Len() > 0 {
+			out = append(out, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range q {
+		switch {
+		case r == '"':
+			if inQuote {
+				cur.WriteRune(r)
+				flush()
+			} else {
+				flush()
+				cur.WriteRune(r)
+			}
+			inQuote = !inQuote
+		case inQuote:
+			cur.WriteRune(r)
+		case r == '(' || r == ')':
+			flush()
+			out = append(out, string(r))
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return out
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestFTSQuery(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{`disk full`, `"disk" "full"`},
+		{`"connection reset" OR timeout`, `"connection reset" OR "timeout"`},
+		{`error NOT (debug OR trace)`, `"error" NOT ( "debug" OR "trace" )`},
+		{`migrat*`, `"migrat"*`},
+		{`path=/var/lib:`, `"path=/var/lib:"`},
+	}
+	for _, tc := range cases {
+		if got := ftsQuery(tc.in); got != tc.want {
+			t.Fatalf("ftsQuery(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestQueryLogsFullTextPhraseAndBoolean(t *testing.T) {
+	repo := newTestRepo(t)
+	if !repo.FullTextSearch() {
+		t.Skip("built without sqlite_fts5")
+	}
+	ctx := context.Background()
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+	seedContainer(t, repo, ctx, "svc", "c1", now)
+	err := repo.InsertLogs(ctx, []models.LogEntry{
+		{TS: now, ServiceID: "svc", ContainerID: "c1", Level: "ERROR", Stream: "stderr", Message: "connection reset by peer"},
+		{TS: now, ServiceID: "svc", ContainerID: "c1", Level: "WARN", Stream: "stderr", Message: "reset connection pool"},
+		{TS: now, ServiceID: "svc", ContainerID: "c1", Level: "INFO", Stream: "stdout", Message: "request timeout after 5s"},
+	})
+	if err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+
+	phrase, err := repo.QueryLogs(ctx, LogQuery{Query: `"connection reset"`, Limit: 10})
+	if err != nil {
+		t.Fatalf("phrase query: %v", err)
+	}
+	if len(phrase) != 1 {
+		t.Fatalf("phrase matches = %d, want 1", len(phrase))
+	}
+	either, err := repo.QueryLogs(ctx, LogQuery{Query: `timeout OR pool`, Limit: 10})
+	if err != nil {
+		t.Fatalf("boolean query: %v", err)
+	}
+	if len(either) != 2 {
+		t.Fatalf("boolean matches = %d, want 2", len(either))
+	}
+}
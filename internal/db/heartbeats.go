@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func (r *Repository) CreateHeartbeat(ctx context.Context, h models.Heartbeat) (int64, error) {
+	return r.insertID(ctx, `INSERT INTO heartbeats (name,token,period_sec,grace_sec,created_at) VALUES (?,?,?,?,?)`,
+		h.Name, h.Token, h.PeriodSec, h.GraceSec, h.CreatedAt.UTC())
+}
+
+// DeleteHeartbeat returns sql.ErrNoRows for unknown heartbeats.
+func (r *Repository) DeleteHeartbeat(ctx context.Context, id int64) error {
+	res, err := r.exec(ctx, `DELETE FROM heartbeats WHERE id=?`, id)
+	return affected(res, err)
+}
+
+func (r *Repository) ListHeartbeats(ctx context.Context) ([]models.Heartbeat, error) {
+	rows, err := r.query(ctx, `SELECT id,name,token,period_sec,grace_sec,created_at,last_ping_at,last_fail_at FROM heartbeats ORDER BY name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.Heartbeat
+	for rows.Next() {
+		var h models.Heartbeat
+		var ping, fail sql.NullTime
+		if err := rows.Scan(&h.ID, &h.Name, &h.Token, &h.PeriodSec, &h.GraceSec, &h.CreatedAt, &ping, &fail); err != nil {
+			return nil, err
+		}
+		if ping.Valid {
+			h.LastPingAt = &ping.Time
+		}
+		if fail.Valid {
+			h.LastFailAt = &fail.Time
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+// PingHeartbeat records a ping, or a failure report when fail is set, for
+// the heartbeat with token. Unknown tokens return sql.ErrNoRows.
+func (r *Repository) PingHeartbeat(ctx context.Context, token string, fail bool, ts time.Time) error {
+	col := "last_ping_at"
+	if fail {
+		col = "last_fail_at"
+	}
+	res, err := r.exec(ctx, `UPDATE heartbeats SET `+col+`=? WHERE token=?`, ts.UTC(), token)
+	return affected(res, err)
+}
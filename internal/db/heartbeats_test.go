@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestPingHeartbeat(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+
+	id, err := repo.CreateHeartbeat(ctx, models.Heartbeat{Name: "backup", Token: "abc", PeriodSec: 86400, GraceSec: 3600, CreatedAt: now})
+	if err != nil {
+		t.Fatalf("create heartbeat: %v", err)
+	}
+	if err := repo.PingHeartbeat(ctx, "abc", false, now.Add(time.Minute)); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if err := repo.PingHeartbeat(ctx, "abc", true, now.Add(2*time.Minute)); err != nil {
+		t.Fatalf("fail: %v", err)
+	}
+	if err := repo.PingHeartbeat(ctx, "nope", false, now); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("unknown token err = %v", err)
+	}
+
+	hbs, err := repo.ListHeartbeats(ctx)
+	if err != nil {
+		t.Fatalf("list heartbeats: %v", err)
+	}
+	if len(hbs) != 1 || hbs[0].ID != id || hbs[0].LastPingAt == nil || hbs[0].LastFailAt == nil {
+		t.Fatalf("heartbeats = %+v", hbs)
+	}
+	if !hbs[0].LastPingAt.Equal(now.Add(time.Minute)) || !hbs[0].LastFailAt.Equal(now.Add(2*time.Minute)) {
+		t.Fatalf("ping = %v, fail = %v", hbs[0].LastPingAt, hbs[0].LastFailAt)
+	}
+
+	if err := repo.DeleteHeartbeat(ctx, id); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := repo.DeleteHeartbeat(ctx, id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("second delete err = %v", err)
+	}
+}
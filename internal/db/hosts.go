@@ -0,0 +1,214 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// Every Docker endpoint, agent and pseudo host of a log source has a row in
+// hosts, and services, containers, samples and log lines carry its host_id.
+// The local host is always 1, so rows from before hosts existed, and host
+// samples, which only the local host takes, belong to it by default.
+
+// localHostID is the id of the local host.
+const localHostID = 1
+
+// ErrContainerConflict is returned when a host reports a container whose ID
+// another host still reports.
+var ErrContainerConflict = errors.New("container id is already reported by another host")
+
+// containerTakeover is how long a container has to be unreported before
+// another host may claim its ID, e.g. after a Docker host was renamed.
+const containerTakeover = 10 * time.Minute
+
+// hostTouch is how often the last_seen_at of a host in use is refreshed.
+const hostTouch = time.Minute
+
+// alertHostID is the host_id of the target of alert a: the local host for
+// host rules, the agent's host for agent rules, else the host of the
+// container or service alerted on. It is NULL for targets on no host, such
+// as checks and heartbeats.
+const alertHostID = `CASE WHEN a.target_fingerprint='host' THEN 1
+	WHEN a.target_fingerprint LIKE 'agent:%' THEN (SELECT id FROM hosts WHERE name=SUBSTR(a.target_fingerprint,7))
+	ELSE COALESCE(
+	(SELECT host_id FROM containers WHERE id=a.target_fingerprint),
+	(SELECT host_id FROM services WHERE id=a.target_fingerprint)) END`
+
+// hostIDs caches the ids of hosts by name and when each was last touched.
+type hostIDs struct {
+	mu      sync.Mutex
+	ids     map[string]int64
+	touched map[string]time.Time
+}
+
+func newHostIDs() *hostIDs {
+	return &hostIDs{ids: map[string]int64{}, touched: map[string]time.Time{}}
+}
+
+// hostID returns the id of the host named name, "" being the local host,
+// registering it on first use.
+func (r *Repository) hostID(ctx context.Context, name string) (int64, error) {
+	name = hostOrLocal(name)
+	now := time.Now().UTC()
+	r.hosts.mu.Lock()
+	id, ok := r.hosts.ids[name]
+	fresh := now.Sub(r.hosts.touched[name]) < hostTouch
+	r.hosts.mu.Unlock()
+	if ok && fresh {
+		return id, nil
+	}
+	if ok {
+		if _, err := r.exec(ctx, `UPDATE hosts SET last_seen_at=? WHERE id=?`, now, id); err != nil {
+			return 0, err
+		}
+	} else {
+		// Ids are handed out by hand, as the local host is pinned to 1; a
+		// concurrent registration taking the same id is retried.
+		for attempt := 0; ; attempt++ {
+			if _, err := r.exec(ctx, `INSERT INTO hosts (id,name,first_seen_at,last_seen_at)
+				VALUES ((SELECT COALESCE(MAX(id),0)+1 FROM hosts),?,?,?) ON CONFLICT DO NOTHING`, name, now, now); err != nil {
+				return 0, err
+			}
+			err := r.queryRow(ctx, `SELECT id FROM hosts WHERE name=?`, name).Scan(&id)
+			if err == nil {
+				break
+			}
+			if !errors.Is(err, sql.ErrNoRows) || attempt == 2 {
+				return 0, fmt.Errorf("register host %s: %w", name, err)
+			}
+		}
+	}
+	r.hosts.mu.Lock()
+	r.hosts.ids[name], r.hosts.touched[name] = id, now
+	r.hosts.mu.Unlock()
+	return id, nil
+}
+
+// Hosts returns the registered hosts by name.
+func (r *Repository) Hosts(ctx context.Context) ([]models.Host, error) {
+	rows, err := r.query(ctx, `SELECT id,name,first_seen_at,last_seen_at FROM hosts ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.Host
+	for rows.Next() {
+		var h models.Host
+		if err := rows.Scan(&h.ID, &h.Name, &h.FirstSeenAt, &h.LastSeenAt); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+// ActiveAlertsByHost counts the firing alerts on each host's targets.
+func (r *Repository) ActiveAlertsByHost(ctx context.Context) (map[string]int, error) {
+	rows, err := r.query(ctx, `SELECT h.name,COUNT(*) FROM alerts a JOIN hosts h ON h.id=`+alertHostID+`
+		WHERE a.status='firing' GROUP BY h.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]int{}
+	for rows.Next() {
+		var name string
+		var n int
+		if err := rows.Scan(&name, &n); err != nil {
+			return nil, err
+		}
+		out[name] = n
+	}
+	return out, rows.Err()
+}
+
+// migrateHosts registers the local host and the hosts named by stored
+// services and containers, and adds host_id to the tables that carry it.
+// Existing rows default to the local host; those of other hosts are moved
+// to theirs once, when the column is added.
+func migrateHosts(db *sql.DB, d Dialect) error {
+	now := time.Now().UTC()
+	if _, err := db.Exec(d.Rebind(`INSERT INTO hosts (id,name,first_seen_at,last_seen_at) VALUES (?,'local',?,?) ON CONFLICT DO NOTHING`), localHostID, now, now); err != nil {
+		return fmt.Errorf("migrate hosts: %w", err)
+	}
+	rows, err := db.Query(`SELECT host FROM services UNION SELECT host FROM containers`)
+	if err != nil {
+		return fmt.Errorf("migrate hosts: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("migrate hosts: %w", err)
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	for _, name := range names {
+		if _, err := db.Exec(d.Rebind(`INSERT INTO hosts (id,name,first_seen_at,last_seen_at)
+			VALUES ((SELECT MAX(id)+1 FROM hosts),?,?,?) ON CONFLICT DO NOTHING`), name, now, now); err != nil {
+			return fmt.Errorf("migrate host %s: %w", name, err)
+		}
+	}
+	for _, m := range []struct {
+		table    string
+		backfill string
+	}{
+		{"services", `UPDATE services SET host_id=(SELECT id FROM hosts WHERE name=services.host) WHERE host<>'local'`},
+		{"containers", `UPDATE containers SET host_id=(SELECT id FROM hosts WHERE name=containers.host) WHERE host<>'local'`},
+		{"host_metrics", ""},
+		{"container_metrics", `UPDATE container_metrics SET host_id=(SELECT host_id FROM containers WHERE id=container_metrics.container_id)
+			WHERE container_id IN (SELECT id FROM containers WHERE host_id<>1)`},
+		{"logs", `UPDATE logs SET host_id=(SELECT host_id FROM containers WHERE id=logs.container_id)
+			WHERE container_id IN (SELECT id FROM containers WHERE host_id<>1)`},
+	} {
+		exists, err := hasColumn(db, d, m.table, "host_id")
+		if err != nil {
+			return fmt.Errorf("migrate hosts: %w", err)
+		}
+		if exists {
+			continue
+		}
+		if err := addColumn(db, d, m.table, "host_id", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+			return fmt.Errorf("migrate hosts: %w", err)
+		}
+		if m.backfill == "" {
+			continue
+		}
+		if _, err := db.Exec(m.backfill); err != nil {
+			return fmt.Errorf("migrate hosts of %s: %w", m.table, err)
+		}
+	}
+	schema := ""
+	if d == SQLite && logsAttached(db) {
+		schema = logsSchema
+	}
+	for _, stmt := range []string{
+		`CREATE INDEX IF NOT EXISTS idx_containers_host_id ON containers(host_id);`,
+		`CREATE INDEX IF NOT EXISTS ` + qualify(schema, "idx_logs_host_ts") + ` ON logs(host_id, ts DESC);`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("migrate hosts: %w", err)
+		}
+	}
+	return nil
+}
+
+// hasColumn reports whether table has column.
+func hasColumn(db *sql.DB, d Dialect, table, column string) (bool, error) {
+	var n int
+	var err error
+	if d == Postgres {
+		err = db.QueryRow(`SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2`, table, column).Scan(&n)
+	} else {
+		err = db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n)
+	}
+	return n > 0, err
+}
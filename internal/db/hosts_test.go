@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestHostsKeepContainersApart(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	seedContainer(t, repo, ctx, "web", "c1", now)
+	err := repo.UpsertServiceAndContainer(ctx,
+		models.Service{ID: "web@nas", Host: "nas", Name: "web", Image: "img", LabelsJSON: "{}", Status: "running"},
+		models.Container{ID: "c2", ServiceID: "web@nas", Host: "nas", Name: "web", Status: "running"})
+	if err != nil {
+		t.Fatalf("store nas container: %v", err)
+	}
+	if err := repo.InsertMetricsBatch(ctx, []models.HostMetric{{TS: now}}, []models.ContainerMetric{{TS: now, ContainerID: "c1"}, {TS: now, ContainerID: "c2"}}); err != nil {
+		t.Fatalf("insert metrics: %v", err)
+	}
+	if err := repo.InsertLogs(ctx, []models.LogEntry{{TS: now, ServiceID: "web@nas", ContainerID: "c2", Message: "remote"}}); err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+
+	hosts, err := repo.Hosts(ctx)
+	if err != nil || len(hosts) != 2 || hosts[0].Name != "local" || hosts[0].ID != localHostID || hosts[1].Name != "nas" {
+		t.Fatalf("hosts = %+v, err %v", hosts, err)
+	}
+	nas := hosts[1].ID
+	for _, q := range []struct {
+		sql  string
+		want int64
+	}{
+		{`SELECT host_id FROM services WHERE id='web@nas'`, nas},
+		{`SELECT host_id FROM containers WHERE id='c1'`, localHostID},
+		{`SELECT host_id FROM container_metrics WHERE container_id='c2'`, nas},
+		{`SELECT host_id FROM logs WHERE container_id='c2'`, nas},
+		{`SELECT host_id FROM host_metrics`, localHostID},
+	} {
+		var got int64
+		if err := repo.DB().QueryRow(q.sql).Scan(&got); err != nil || got != q.want {
+			t.Fatalf("%s = %d, err %v, want %d", q.sql, got, err, q.want)
+		}
+	}
+
+	// Another host reporting c2 does not take it over while nas does.
+	err = repo.UpsertServiceAndContainer(ctx,
+		models.Service{ID: "web@pi", Host: "pi", Name: "web", Image: "img", LabelsJSON: "{}", Status: "running"},
+		models.Container{ID: "c2", ServiceID: "web@pi", Host: "pi", Name: "web", Status: "running"})
+	if !errors.Is(err, ErrContainerConflict) {
+		t.Fatalf("conflicting upsert err = %v, want ErrContainerConflict", err)
+	}
+	if host, err := repo.ContainerHost(ctx, "c2"); err != nil || host != "nas" {
+		t.Fatalf("c2 is on %q, err %v", host, err)
+	}
+}
+
+func TestMigrateMovesRowsToTheirHosts(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	sqldb := repo.DB()
+	// A database from before hosts: a nas container with samples and logs.
+	for _, stmt := range []string{
+		`INSERT INTO services (id,host,name,image,labels_json,first_seen_at,last_seen_at,status) VALUES ('web@nas','nas','web','img','{}',?,?,'running')`,
+		`INSERT INTO containers (id,service_id,host,name,status,last_seen_at) VALUES ('c1','web@nas','nas','web','running',?)`,
+	} {
+		if _, err := sqldb.Exec(stmt, now, now); err != nil {
+			t.Fatalf("seed legacy rows: %v", err)
+		}
+	}
+	if err := repo.InsertMetricsBatch(ctx, nil, []models.ContainerMetric{{TS: now, ContainerID: "c1"}}); err != nil {
+		t.Fatalf("insert metrics: %v", err)
+	}
+	for _, stmt := range []string{
+		`DROP INDEX idx_containers_host_id`,
+		`DROP INDEX idx_logs_host_ts`,
+		`ALTER TABLE services DROP COLUMN host_id`,
+		`ALTER TABLE containers DROP COLUMN host_id`,
+		`ALTER TABLE container_metrics DROP COLUMN host_id`,
+		`ALTER TABLE logs DROP COLUMN host_id`,
+	} {
+		if _, err := sqldb.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	if _, err := sqldb.Exec(`DELETE FROM hosts WHERE name<>'local'`); err != nil {
+		t.Fatalf("forget hosts: %v", err)
+	}
+	if _, err := sqldb.Exec(`INSERT INTO logs (ts,service_id,container_id,level,stream,message) VALUES (?,'web@nas','c1','INFO','stdout','old')`, now); err != nil {
+		t.Fatalf("insert legacy log: %v", err)
+	}
+
+	if err := Migrate(sqldb); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	var nas int64
+	if err := sqldb.QueryRow(`SELECT id FROM hosts WHERE name='nas'`).Scan(&nas); err != nil || nas == localHostID {
+		t.Fatalf("nas host id = %d, err %v", nas, err)
+	}
+	for _, table := range []string{"services", "containers", "container_metrics", "logs"} {
+		var n int
+		if err := sqldb.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE host_id=?`, nas).Scan(&n); err != nil || n != 1 {
+			t.Fatalf("%s rows on nas = %d, err %v, want 1", table, n, err)
+		}
+	}
+}
+
+func TestAgentHeartbeats(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	a := models.Agent{Host: "nas", OS: "Debian", IntervalSec: 10, LastHeartbeatAt: now, Deadline: now.Add(time.Minute)}
+	if prev, err := repo.RecordAgentHeartbeat(ctx, a); err != nil || !prev.IsZero() {
+		t.Fatalf("first heartbeat: prev %v, err %v", prev, err)
+	}
+	a.LastHeartbeatAt, a.Deadline, a.DockerVersion = now.Add(10*time.Second), now.Add(70*time.Second), "27.1"
+	if prev, err := repo.RecordAgentHeartbeat(ctx, a); err != nil || !prev.Equal(now.Add(time.Minute)) {
+		t.Fatalf("second heartbeat: prev %v, err %v", prev, err)
+	}
+	agents, err := repo.ListAgents(ctx)
+	if err != nil || len(agents) != 1 || agents[0].DockerVersion != "27.1" || !agents[0].RegisteredAt.Equal(now) {
+		t.Fatalf("agents = %+v, err %v", agents, err)
+	}
+	if err := repo.DeleteAgent(ctx, "nas"); err != nil {
+		t.Fatalf("delete agent: %v", err)
+	}
+	if err := repo.DeleteAgent(ctx, "nas"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("delete unknown agent err = %v, want sql.ErrNoRows", err)
+	}
+}
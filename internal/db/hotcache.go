@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// hotCache keeps what the overview and services fragments poll in memory:
+// the newest host sample, the newest sample of each container and the
+// number of firing alerts. Metric writes update it; alert writes drop the
+// count, which the next read takes from the database again.
+type hotCache struct {
+	mu sync.RWMutex
+
+	host   models.HostMetric
+	hostOK bool
+
+	containers map[string]models.ContainerMetric
+	// containersOK is set once the newest samples were loaded, which
+	// happens on the first read after the repository is opened.
+	containersOK bool
+
+	alerts   int
+	alertsOK bool
+	// alertsGen counts alert writes, so a count read while one happened
+	// is not kept.
+	alertsGen uint64
+}
+
+func newHotCache() *hotCache {
+	return &hotCache{containers: map[string]models.ContainerMetric{}}
+}
+
+func (c *hotCache) putHost(m models.HostMetric) {
+	// Disks and temperatures are in their own tables, which
+	// LatestHostMetric does not read either.
+	m.Disks, m.Temperatures = nil, nil
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.hostOK || !m.TS.Before(c.host.TS) {
+		c.host, c.hostOK = m, true
+	}
+}
+
+func (c *hotCache) putContainers(ms ...models.ContainerMetric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range ms {
+		if prev, ok := c.containers[m.ContainerID]; !ok || !m.TS.Before(prev.TS) {
+			c.containers[m.ContainerID] = m
+		}
+	}
+}
+
+// trim forgets samples older than cutoff, which retention deleted.
+func (c *hotCache) trim(cutoff time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hostOK && c.host.TS.Before(cutoff) {
+		c.host, c.hostOK = models.HostMetric{}, false
+	}
+	for id, m := range c.containers {
+		if m.TS.Before(cutoff) {
+			delete(c.containers, id)
+		}
+	}
+}
+
+func (c *hotCache) dropContainer(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.containers, id)
+}
+
+func (c *hotCache) dropAlerts() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.alertsOK = false
+	c.alertsGen++
+}
+
+// latestContainers returns the newest sample of each container, loading
+// them from the database on first use.
+func (r *Repository) latestContainers(ctx context.Context) (map[string]models.ContainerMetric, error) {
+	c := r.hot
+	c.mu.RLock()
+	ok := c.containersOK
+	c.mu.RUnlock()
+	if !ok {
+		loaded, err := r.LatestContainerMetrics(ctx, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		ms := make([]models.ContainerMetric, 0, len(loaded))
+		for _, m := range loaded {
+			ms = append(ms, m)
+		}
+		// Samples written meanwhile are newer and win.
+		c.putContainers(ms...)
+		c.mu.Lock()
+		c.containersOK = true
+		c.mu.Unlock()
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[string]models.ContainerMetric, len(c.containers))
+	for id, m := range c.containers {
+		out[id] = m
+	}
+	return out, nil
+}
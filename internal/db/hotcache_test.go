@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestHotCacheFollowsWrites(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+	seedContainer(t, repo, ctx, "svc-a", "c1", now)
+	seedContainer(t, repo, ctx, "svc-b", "c2", now)
+	if err := repo.InsertMetricsBatch(ctx, []models.HostMetric{{TS: now, CPUPct: 20}}, []models.ContainerMetric{
+		{TS: now.Add(-time.Minute), ContainerID: "c1", CPUPct: 50},
+		{TS: now, ContainerID: "c1", CPUPct: 5, MemUsedBytes: 100},
+		{TS: now, ContainerID: "c2", CPUPct: 10, MemUsedBytes: 10},
+	}); err != nil {
+		t.Fatalf("insert batch: %v", err)
+	}
+
+	// A fresh repository starts from what the database holds.
+	cold := NewRepository(repo.DB())
+	for name, r := range map[string]*Repository{"warm": repo, "cold": cold} {
+		rows, err := r.ListServicesWithHealth(ctx, 0, 0, 10, false, "", nil)
+		if err != nil {
+			t.Fatalf("%s: list services: %v", name, err)
+		}
+		if len(rows) != 2 || rows[0]["container_id"] != "c2" || rows[1]["cpu_pct"] != 5.0 {
+			t.Fatalf("%s: services = %+v, want c2 then c1 at 5%%", name, rows)
+		}
+		if m, err := r.LatestHostMetric(ctx); err != nil || m.CPUPct != 20 {
+			t.Fatalf("%s: latest host metric = %+v, err %v", name, m, err)
+		}
+	}
+
+	// Newer samples reorder and filter; older ones do not replace them.
+	if err := cold.InsertContainerMetric(ctx, models.ContainerMetric{TS: now.Add(time.Minute), ContainerID: "c1", CPUPct: 80}); err != nil {
+		t.Fatalf("insert metric: %v", err)
+	}
+	if err := cold.InsertContainerMetric(ctx, models.ContainerMetric{TS: now.Add(-time.Hour), ContainerID: "c2", CPUPct: 99}); err != nil {
+		t.Fatalf("insert metric: %v", err)
+	}
+	rows, err := cold.ListServicesWithHealth(ctx, 50, 0, 10, false, "", nil)
+	if err != nil || len(rows) != 1 || rows[0]["container_id"] != "c1" {
+		t.Fatalf("services above 50%% cpu = %+v, err %v", rows, err)
+	}
+
+	if n, err := repo.ActiveAlertCount(ctx); err != nil || n != 0 {
+		t.Fatalf("active alerts = %d, err %v", n, err)
+	}
+	if _, err := repo.CreateAlert(ctx, 1, "c1", "firing", "cpu", nil, now); err != nil {
+		t.Fatalf("create alert: %v", err)
+	}
+	if n, err := repo.ActiveAlertCount(ctx); err != nil || n != 1 {
+		t.Fatalf("active alerts after create = %d, err %v", n, err)
+	}
+	if err := repo.CloseAlert(ctx, 1, "c1", now); err != nil {
+		t.Fatalf("close alert: %v", err)
+	}
+	if n, err := repo.ActiveAlertCount(ctx); err != nil || n != 0 {
+		t.Fatalf("active alerts after close = %d, err %v", n, err)
+	}
+
+	// Retention drops what it deleted.
+	if err := repo.DeleteMetricsOlderThan(ctx, now.Add(time.Hour)); err != nil {
+		t.Fatalf("delete metrics: %v", err)
+	}
+	if _, err := repo.LatestHostMetric(ctx); err == nil {
+		t.Fatal("latest host metric survived retention")
+	}
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+
+	"dashi/internal/models"
+)
+
+// SaveImageUpdate records the latest registry comparison for a service.
+func (r *Repository) SaveImageUpdate(ctx context.Context, u models.ImageUpdate) error {
+	available := 0
+	if u.UpdateAvailable {
+		available = 1
+	}
+	_, err := r.exec(ctx, `INSERT INTO image_updates(service_id,image,current_digest,latest_digest,update_available,checked_at,error)
+		VALUES (?,?,?,?,?,?,?)
+		ON CONFLICT(service_id) DO UPDATE SET image=excluded.image,current_digest=excluded.current_digest,latest_digest=excluded.latest_digest,
+			update_available=excluded.update_available,checked_at=excluded.checked_at,error=excluded.error`,
+		u.ServiceID, u.Image, u.CurrentDigest, u.LatestDigest, available, u.CheckedAt.UTC(), u.Error)
+	return err
+}
+
+// ListImageUpdates returns the last check of every service, services with
+// an update first.
+func (r *Repository) ListImageUpdates(ctx context.Context) ([]models.ImageUpdate, error) {
+	rows, err := r.query(ctx, `SELECT service_id,image,current_digest,latest_digest,update_available,checked_at,error
+		FROM image_updates ORDER BY update_available DESC, service_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.ImageUpdate
+	for rows.Next() {
+		var u models.ImageUpdate
+		var available int
+		if err := rows.Scan(&u.ServiceID, &u.Image, &u.CurrentDigest, &u.LatestDigest, &available, &u.CheckedAt, &u.Error); err != nil {
+			return nil, err
+		}
+		u.UpdateAvailable = available == 1
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
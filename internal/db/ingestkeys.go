@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// IngestResult returns the response stored for an idempotency key of a
+// push, ok false for keys not seen yet.
+func (r *Repository) IngestResult(ctx context.Context, key string) (result string, ok bool, err error) {
+	err = r.queryRow(ctx, `SELECT result_json FROM ingest_keys WHERE key=?`, key).Scan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	return result, err == nil, err
+}
+
+// SaveIngestResult remembers the response to a push with an idempotency
+// key. A key saved meanwhile keeps its first response.
+func (r *Repository) SaveIngestResult(ctx context.Context, key, result string, at time.Time) error {
+	_, err := r.exec(ctx, `INSERT INTO ingest_keys(key,result_json,created_at) VALUES (?,?,?) ON CONFLICT(key) DO NOTHING`, key, result, at.UTC())
+	return err
+}
+
+// DeleteIngestKeysOlderThan forgets idempotency keys saved before cutoff;
+// a push repeated later is stored again.
+func (r *Repository) DeleteIngestKeysOlderThan(ctx context.Context, cutoff time.Time) error {
+	_, err := r.exec(ctx, `DELETE FROM ingest_keys WHERE created_at < ?`, cutoff.UTC())
+	return err
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrCorrupt reports a SQLite file that failed its integrity check.
+var ErrCorrupt = errors.New("database is corrupt")
+
+// CheckIntegrity runs PRAGMA quick_check, or the slower full integrity_check,
+// on the SQLite file at path. A missing file passes; a damaged or non-SQLite
+// file yields an error wrapping ErrCorrupt.
+func CheckIntegrity(path string, full bool) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_busy_timeout=5000", path))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	pragma := `PRAGMA quick_check`
+	if full {
+		pragma = `PRAGMA integrity_check`
+	}
+	rows, err := db.Query(pragma)
+	if err != nil {
+		return corruptErr(err)
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return corruptErr(err)
+		}
+		if msg != "ok" && len(problems) < 5 {
+			problems = append(problems, msg)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return corruptErr(err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrCorrupt, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func corruptErr(err error) error {
+	var se sqlite3.Error
+	if errors.As(err, &se) && (se.Code == sqlite3.ErrCorrupt || se.Code == sqlite3.ErrNotADB) {
+		return fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return err
+}
+
+// Quarantine moves a damaged database and its WAL/SHM side files aside so a
+// fresh or restored file can take its place, and returns the new path.
+func Quarantine(path string) (string, error) {
+	dst := path + ".corrupt-" + time.Now().UTC().Format("20060102-150405")
+	if err := os.Rename(path, dst); err != nil {
+		return "", err
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Rename(path+suffix, dst+suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return dst, err
+		}
+	}
+	return dst, nil
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := CheckIntegrity(filepath.Join(dir, "missing.db"), false); err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+
+	good := filepath.Join(dir, "good.db")
+	sqldb, err := Open(good)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	_ = sqldb.Close()
+	for _, full := range []bool{false, true} {
+		if err := CheckIntegrity(good, full); err != nil {
+			t.Fatalf("healthy db (full=%v): %v", full, err)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.db")
+	if err := os.WriteFile(bad, []byte("definitely not a sqlite database file, just some bytes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckIntegrity(bad, false); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("garbage file err = %v, want ErrCorrupt", err)
+	}
+
+	moved, err := Quarantine(bad)
+	if err != nil {
+		t.Fatalf("quarantine: %v", err)
+	}
+	if _, err := os.Stat(bad); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("damaged file still in place: %v", err)
+	}
+	if _, err := os.Stat(moved); err != nil {
+		t.Fatalf("quarantined file missing: %v", err)
+	}
+}
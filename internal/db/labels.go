@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// LabelSelector is a parsed comma-separated label filter such as
+// "env=prod,tier!=db,com.example.team". All terms must hold.
+type LabelSelector []LabelTerm
+
+// LabelTerm is one selector term. An empty Op tests for the key's presence;
+// Negate inverts the term ("!key", "key!=value").
+type LabelTerm struct {
+	Key    string
+	Op     string // "=" or ""
+	Value  string
+	Negate bool
+}
+
+func ParseLabelSelector(s string) (LabelSelector, error) {
+	var sel LabelSelector
+	for _, raw := range strings.Split(s, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		var t LabelTerm
+		switch {
+		case strings.Contains(raw, "!="):
+			k, v, _ := strings.Cut(raw, "!=")
+			t = LabelTerm{Key: k, Op: "=", Value: v, Negate: true}
+		case strings.Contains(raw, "="):
+			k, v, _ := strings.Cut(raw, "=")
+			t = LabelTerm{Key: k, Op: "=", Value: v}
+		case strings.HasPrefix(raw, "!"):
+			t = LabelTerm{Key: raw[1:], Negate: true}
+		default:
+			t = LabelTerm{Key: raw}
+		}
+		t.Key = strings.TrimSpace(t.Key)
+		t.Value = strings.TrimSpace(t.Value)
+		if t.Key == "" {
+			return nil, fmt.Errorf("invalid label selector term %q", raw)
+		}
+		sel = append(sel, t)
+	}
+	return sel, nil
+}
+
+func (s LabelSelector) String() string {
+	parts := make([]string, 0, len(s))
+	for _, t := range s {
+		switch {
+		case t.Op == "" && t.Negate:
+			parts = append(parts, "!"+t.Key)
+		case t.Op == "":
+			parts = append(parts, t.Key)
+		case t.Negate:
+			parts = append(parts, t.Key+"!="+t.Value)
+		default:
+			parts = append(parts, t.Key+"="+t.Value)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Matches evaluates the selector against a label set.
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, t := range s {
+		v, ok := labels[t.Key]
+		hit := ok && (t.Op == "" || v == t.Value)
+		if hit == t.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+// sql renders the selector as predicates on a service id column.
+func (s LabelSelector) sql(column string) ([]string, []any) {
+	var clauses []string
+	var args []any
+	for _, t := range s {
+		not := ""
+		if t.Negate {
+			not = "NOT "
+		}
+		if t.Op == "" {
+			clauses = append(clauses, column+" "+not+"IN (SELECT service_id FROM labels WHERE key = ?)")
+			args = append(args, t.Key)
+			continue
+		}
+		clauses = append(clauses, column+" "+not+"IN (SELECT service_id FROM labels WHERE key = ? AND value = ?)")
+		args = append(args, t.Key, t.Value)
+	}
+	return clauses, args
+}
+
+// ServiceLabels returns the labels of every known service keyed by service id.
+func (r *Repository) ServiceLabels(ctx context.Context) (map[string]map[string]string, error) {
+	rows, err := r.query(ctx, `SELECT service_id,key,value FROM labels`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]map[string]string{}
+	for rows.Next() {
+		var svc, k, v string
+		if err := rows.Scan(&svc, &k, &v); err != nil {
+			return nil, err
+		}
+		if out[svc] == nil {
+			out[svc] = map[string]string{}
+		}
+		out[svc][k] = v
+	}
+	return out, rows.Err()
+}
+
+// replaceLabels rewrites the labels rows of a service from its labels_json.
+func (r *Repository) replaceLabels(ctx context.Context, serviceID, labelsJSON string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := writeLabels(ctx, tx, r.dialect, serviceID, labelsJSON); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func writeLabels(ctx context.Context, tx *sql.Tx, d Dialect, serviceID, labelsJSON string) error {
+	var labels map[string]string
+	if labelsJSON != "" {
+		if err := json.Unmarshal([]byte(labelsJSON), &labels); err != nil {
+			return fmt.Errorf("decode labels of %s: %w", serviceID, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, d.Rebind(`DELETE FROM labels WHERE service_id = ?`), serviceID); err != nil {
+		return err
+	}
+	for k, v := range labels {
+		if _, err := tx.ExecContext(ctx, d.Rebind(`INSERT INTO labels (service_id,key,value) VALUES (?,?,?)`), serviceID, k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillLabels fills the labels table from services.labels_json for
+// databases created before labels were normalized.
+func backfillLabels(db *sql.DB, d Dialect) error {
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM labels`).Scan(&n); err != nil || n > 0 {
+		return err
+	}
+	rows, err := db.Query(`SELECT id,labels_json FROM services`)
+	if err != nil {
+		return err
+	}
+	type svc struct{ id, labels string }
+	var all []svc
+	for rows.Next() {
+		var s svc
+		if err := rows.Scan(&s.id, &s.labels); err != nil {
+			rows.Close()
+			return err
+		}
+		all = append(all, s)
+	}
+	rows.Close()
+	if len(all) == 0 {
+		return rows.Err()
+	}
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, s := range all {
+		if err := writeLabels(ctx, tx, d, s.id, s.labels); err != nil {
+			return fmt.Errorf("backfill labels: %w", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := map[string]string{"env": "prod", "tier": "web"}
+	cases := []struct {
+		sel  string
+		want bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env=prod,tier=web", true},
+		{"env=dev", false},
+		{"env!=dev", true},
+		{"tier", true},
+		{"!tier", false},
+		{"!backup", true},
+		{" env = prod ", true},
+	}
+	for _, tc := range cases {
+		sel, err := ParseLabelSelector(tc.sel)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.sel, err)
+		}
+		if got := sel.Matches(labels); got != tc.want {
+			t.Fatalf("%q matches = %v, want %v", tc.sel, got, tc.want)
+		}
+	}
+	if _, err := ParseLabelSelector("=prod"); err == nil {
+		t.Fatal("expected error for empty key")
+	}
+}
+
+func TestQueryLogsByLabelSelector(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+	for _, svc := range []struct{ id, labels string }{
+		{"api", `{"env":"prod"}`},
+		{"worker", `{"env":"dev"}`},
+	} {
+		if err := repo.UpsertServiceAndContainer(ctx,
+			models.Service{ID: svc.id, Name: svc.id, Image: "img", LabelsJSON: svc.labels, Status: "running"},
+			models.Container{ID: svc.id + "-1", ServiceID: svc.id, Name: svc.id, Status: "running", LastSeenAt: now},
+		); err != nil {
+			t.Fatalf("upsert %s: %v", svc.id, err)
+		}
+	}
+	if err := repo.InsertLogs(ctx, []models.LogEntry{
+		{TS: now, ServiceID: "api", ContainerID: "api-1", Level: "INFO", Stream: "stdout", Message: "prod line"},
+		{TS: now, ServiceID: "worker", ContainerID: "worker-1", Level: "INFO", Stream: "stdout", Message: "dev line"},
+	}); err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+
+	sel, _ := ParseLabelSelector("env=prod")
+	entries, err := repo.QueryLogs(ctx, LogQuery{Labels: sel})
+	if err != nil {
+		t.Fatalf("query logs: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ServiceID != "api" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	// Relabelling a service replaces its label rows.
+	if err := repo.UpsertServiceAndContainer(ctx,
+		models.Service{ID: "worker", Name: "worker", Image: "img", LabelsJSON: `{"env":"prod"}`, Status: "running"},
+		models.Container{ID: "worker-1", ServiceID: "worker", Name: "worker", Status: "running", LastSeenAt: now},
+	); err != nil {
+		t.Fatalf("relabel worker: %v", err)
+	}
+	entries, err = repo.QueryLogs(ctx, LogQuery{Labels: sel})
+	if err != nil {
+		t.Fatalf("query logs: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries len = %d, want 2", len(entries))
+	}
+}
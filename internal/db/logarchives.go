@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// ArchiveLog is a stored log line with its row ID, as read for archiving.
+type ArchiveLog struct {
+	ID int64
+	models.LogEntry
+}
+
+// LogsToArchive returns up to limit log lines with from <= ts < to and an ID
+// above afterID, in ID order, so a caller pages through them by passing the
+// last ID it got.
+func (r *Repository) LogsToArchive(ctx context.Context, from, to time.Time, afterID int64, limit int) ([]ArchiveLog, error) {
+	rows, err := r.query(ctx, `SELECT id,ts,service_id,container_id,level,stream,message,repeat_count,last_seen,fields FROM logs
+		WHERE ts >= ? AND ts < ? AND id > ? ORDER BY id LIMIT ?`, from.UTC(), to.UTC(), afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ArchiveLog
+	for rows.Next() {
+		var l ArchiveLog
+		var lastSeen sql.NullTime
+		var fields sql.NullString
+		if err := rows.Scan(&l.ID, &l.TS, &l.ServiceID, &l.ContainerID, &l.Level, &l.Stream, &l.Message, &l.RepeatCount, &lastSeen, &fields); err != nil {
+			return nil, err
+		}
+		if lastSeen.Valid {
+			t := lastSeen.Time
+			l.LastSeen = &t
+		}
+		l.Fields = scanFields(fields)
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+// RestoreLogs stores archived log lines as they were, repeat counts
+// included. Lines of containers no longer known are skipped, as their rows
+// could not reference them.
+func (r *Repository) RestoreLogs(ctx context.Context, entries []models.LogEntry) (restored, skipped int, err error) {
+	known := map[string]bool{}
+	rows, err := r.query(ctx, `SELECT id FROM containers`)
+	if err != nil {
+		return 0, 0, err
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		known[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, r.dialect.Rebind(`INSERT INTO logs (ts,service_id,container_id,host_id,level,stream,message,repeat_count,last_seen,fields)
+		VALUES (?,?,?,`+containerHostID+`,?,?,?,?,?,?)`))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer stmt.Close()
+	for _, e := range entries {
+		if !known[e.ContainerID] {
+			skipped++
+			continue
+		}
+		var lastSeen any
+		if e.LastSeen != nil {
+			lastSeen = e.LastSeen.UTC()
+		}
+		if _, err := stmt.ExecContext(ctx, e.TS.UTC(), e.ServiceID, e.ContainerID, e.ContainerID, e.Level, e.Stream, e.Message,
+			max(e.RepeatCount, 1), lastSeen, fieldsValue(e.Fields)); err != nil {
+			return 0, 0, err
+		}
+		restored++
+	}
+	return restored, skipped, tx.Commit()
+}
+
+// InsertLogArchives records the segments of an archive run, all or none.
+func (r *Repository) InsertLogArchives(ctx context.Context, segments []models.LogArchive) error {
+	if len(segments) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, r.dialect.Rebind(`INSERT INTO log_archives (object_key,day,service_id,from_ts,to_ts,lines,bytes,cutoff,created_at) VALUES (?,?,?,?,?,?,?,?,?)`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, a := range segments {
+		if _, err := stmt.ExecContext(ctx, a.Key, a.Day, a.ServiceID, a.From.UTC(), a.To.UTC(), a.Lines, a.Bytes, a.Cutoff.UTC(), a.CreatedAt.UTC()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// LogArchiveCutoff returns the retention cutoff up to which logs were
+// archived, the zero time before the first archive.
+func (r *Repository) LogArchiveCutoff(ctx context.Context) (time.Time, error) {
+	var cutoff time.Time
+	err := r.queryRow(ctx, `SELECT cutoff FROM log_archives ORDER BY cutoff DESC LIMIT 1`).Scan(&cutoff)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return cutoff, err
+}
+
+const logArchiveColumns = `id,object_key,day,service_id,from_ts,to_ts,lines,bytes,cutoff,created_at`
+
+// LogArchives returns up to limit archive segments of days between from and
+// to (YYYY-MM-DD, either may be empty), newest first; with a service, only
+// its own.
+func (r *Repository) LogArchives(ctx context.Context, from, to, serviceID string, limit int) ([]models.LogArchive, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	filter, args := "", []any{}
+	if from != "" {
+		filter += " AND day >= ?"
+		args = append(args, from)
+	}
+	if to != "" {
+		filter += " AND day <= ?"
+		args = append(args, to)
+	}
+	if serviceID != "" {
+		filter += " AND service_id=?"
+		args = append(args, serviceID)
+	}
+	rows, err := r.query(ctx, `SELECT `+logArchiveColumns+` FROM log_archives WHERE 1=1`+filter+` ORDER BY day DESC, service_id, from_ts DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.LogArchive
+	for rows.Next() {
+		a, err := scanLogArchive(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// LogArchive returns one archive segment, sql.ErrNoRows for unknown IDs.
+func (r *Repository) LogArchive(ctx context.Context, id int64) (models.LogArchive, error) {
+	return scanLogArchive(r.queryRow(ctx, `SELECT `+logArchiveColumns+` FROM log_archives WHERE id=?`, id))
+}
+
+func scanLogArchive(row interface{ Scan(...any) error }) (models.LogArchive, error) {
+	var a models.LogArchive
+	err := row.Scan(&a.ID, &a.Key, &a.Day, &a.ServiceID, &a.From, &a.To, &a.Lines, &a.Bytes, &a.Cutoff, &a.CreatedAt)
+	return a, err
+}
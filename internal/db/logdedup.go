@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"dashi/internal/models"
+)
+
+type lastLogRow struct {
+	id       int64
+	level    string
+	stream   string
+	message  string
+	lastSeen time.Time
+}
+
+// InsertLogsCollapsed inserts entries like InsertLogs, but a line that
+// repeats the previous line of the same container (same level, stream and
+// message) within window bumps that row's repeat_count and last_seen instead
+// of adding a new row.
+func (r *Repository) InsertLogsCollapsed(ctx context.Context, entries []models.LogEntry, window time.Duration) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	insertSQL := `INSERT INTO logs (ts,service_id,container_id,host_id,level,stream,message,fields) VALUES (?,?,?,` + containerHostID + `,?,?,?,?)`
+	if r.dialect == Postgres {
+		insertSQL += ` RETURNING id`
+	}
+	insert, err := tx.PrepareContext(ctx, r.dialect.Rebind(insertSQL))
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	bump, err := tx.PrepareContext(ctx, r.dialect.Rebind(`UPDATE logs SET repeat_count=repeat_count+1, last_seen=? WHERE id=?`))
+	if err != nil {
+		return err
+	}
+	defer bump.Close()
+	latest, err := tx.PrepareContext(ctx, r.dialect.Rebind(`SELECT id,ts,last_seen,level,stream,message FROM logs WHERE container_id=? ORDER BY ts DESC, id DESC LIMIT 1`))
+	if err != nil {
+		return err
+	}
+	defer latest.Close()
+
+	last := map[string]*lastLogRow{}
+	for _, e := range entries {
+		ts := e.TS.UTC()
+		prev, seen := last[e.ContainerID]
+		if !seen {
+			prev, err = scanLastLog(latest.QueryRowContext(ctx, e.ContainerID))
+			if err != nil {
+				return err
+			}
+		}
+		if prev != nil && prev.level == e.Level && prev.stream == e.Stream && prev.message == e.Message &&
+			!ts.Before(prev.lastSeen) && ts.Sub(prev.lastSeen) <= window {
+			if _, err := bump.ExecContext(ctx, ts, prev.id); err != nil {
+				return err
+			}
+			prev.lastSeen = ts
+			last[e.ContainerID] = prev
+			continue
+		}
+		var id int64
+		if r.dialect == Postgres {
+			err = insert.QueryRowContext(ctx, ts, e.ServiceID, e.ContainerID, e.ContainerID, e.Level, e.Stream, e.Message, fieldsValue(e.Fields)).Scan(&id)
+		} else {
+			var res sql.Result
+			if res, err = insert.ExecContext(ctx, ts, e.ServiceID, e.ContainerID, e.ContainerID, e.Level, e.Stream, e.Message, fieldsValue(e.Fields)); err == nil {
+				id, err = res.LastInsertId()
+			}
+		}
+		if err != nil {
+			return err
+		}
+		last[e.ContainerID] = &lastLogRow{id: id, level: e.Level, stream: e.Stream, message: e.Message, lastSeen: ts}
+	}
+	return tx.Commit()
+}
+
+func scanLastLog(row *sql.Row) (*lastLogRow, error) {
+	var l lastLogRow
+	var ts time.Time
+	var lastSeen sql.NullTime
+	err := row.Scan(&l.id, &ts, &lastSeen, &l.level, &l.stream, &l.message)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.lastSeen = ts
+	if lastSeen.Valid {
+		l.lastSeen = lastSeen.Time
+	}
+	return &l, nil
+}
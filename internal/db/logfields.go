@@ -0,0 +1,35 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"regexp"
+)
+
+// FieldName is the syntax of an extracted log field name, as accepted by
+// the field filters and group_by of log queries.
+var FieldName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// fieldsValue encodes the extracted fields of a log entry for the fields
+// column, NULL when there are none.
+func fieldsValue(fields map[string]string) any {
+	if len(fields) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	return string(b)
+}
+
+func scanFields(raw sql.NullString) map[string]string {
+	if !raw.Valid || raw.String == "" {
+		return nil
+	}
+	var fields map[string]string
+	if json.Unmarshal([]byte(raw.String), &fields) != nil {
+		return nil
+	}
+	return fields
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+
+	"dashi/internal/models"
+)
+
+// SaveLogFilePosition records how far a tailed file has been read.
+func (r *Repository) SaveLogFilePosition(ctx context.Context, p models.LogFilePosition) error {
+	_, err := r.exec(ctx, `INSERT INTO log_file_positions(path,file_id,pos,updated_at) VALUES (?,?,?,?)
+		ON CONFLICT(path) DO UPDATE SET file_id=excluded.file_id,pos=excluded.pos,updated_at=excluded.updated_at`,
+		p.Path, p.FileID, p.Offset, p.UpdatedAt.UTC())
+	return err
+}
+
+func (r *Repository) ListLogFilePositions(ctx context.Context) ([]models.LogFilePosition, error) {
+	rows, err := r.query(ctx, `SELECT path,file_id,pos,updated_at FROM log_file_positions ORDER BY path`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.LogFilePosition
+	for rows.Next() {
+		var p models.LogFilePosition
+		if err := rows.Scan(&p.Path, &p.FileID, &p.Offset, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// DeleteLogFilePosition forgets a file that is no longer tailed.
+func (r *Repository) DeleteLogFilePosition(ctx context.Context, path string) error {
+	_, err := r.exec(ctx, `DELETE FROM log_file_positions WHERE path=?`, path)
+	return err
+}
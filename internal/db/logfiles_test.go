@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestLogFilePositions(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+
+	for _, p := range []models.LogFilePosition{
+		{Path: "/var/log/nginx/access.log", FileID: "1:10", Offset: 100, UpdatedAt: now},
+		{Path: "/var/log/syslog", FileID: "1:20", Offset: 5, UpdatedAt: now},
+		{Path: "/var/log/nginx/access.log", FileID: "1:11", Offset: 42, UpdatedAt: now.Add(time.Minute)},
+	} {
+		if err := repo.SaveLogFilePosition(ctx, p); err != nil {
+			t.Fatalf("save position: %v", err)
+		}
+	}
+	if err := repo.DeleteLogFilePosition(ctx, "/var/log/syslog"); err != nil {
+		t.Fatalf("delete position: %v", err)
+	}
+	got, err := repo.ListLogFilePositions(ctx)
+	if err != nil {
+		t.Fatalf("list positions: %v", err)
+	}
+	if len(got) != 1 || got[0].FileID != "1:11" || got[0].Offset != 42 || !got[0].UpdatedAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("positions = %+v", got)
+	}
+}
+
+func TestLogStreamPositions(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	ts := time.Date(2026, 2, 21, 12, 0, 0, 123456789, time.UTC)
+
+	if _, err := repo.GetLogStreamPosition(ctx, "c1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("unknown position err = %v", err)
+	}
+	for _, p := range []models.LogStreamPosition{
+		{ContainerID: "c1", TS: ts.Add(-time.Second), Lines: 1, UpdatedAt: now},
+		{ContainerID: "c1", TS: ts, Lines: 3, UpdatedAt: now},
+		{ContainerID: "c2", TS: ts, Lines: 1, UpdatedAt: now.Add(-30 * 24 * time.Hour)},
+	} {
+		if err := repo.SaveLogStreamPosition(ctx, p); err != nil {
+			t.Fatalf("save position: %v", err)
+		}
+	}
+	got, err := repo.GetLogStreamPosition(ctx, "c1")
+	if err != nil {
+		t.Fatalf("get position: %v", err)
+	}
+	if !got.TS.Equal(ts) || got.Lines != 3 {
+		t.Fatalf("position = %+v, want ts %v with 3 lines", got, ts)
+	}
+	if n, err := repo.PruneLogStreamPositions(ctx, now.Add(-24*time.Hour)); err != nil || n != 1 {
+		t.Fatalf("prune = %d, %v", n, err)
+	}
+	if _, err := repo.GetLogStreamPosition(ctx, "c2"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("pruned position err = %v", err)
+	}
+}
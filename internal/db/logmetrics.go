@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"dashi/internal/models"
+)
+
+const serviceLogMetricColumns = `ts,service_id,lines,error_lines,bytes,requests,requests_5xx,timed_requests,latency_ms`
+
+func serviceLogMetricDest(m *models.ServiceLogMetric) []any {
+	return []any{&m.TS, &m.ServiceID, &m.Lines, &m.ErrorLines, &m.Bytes, &m.Requests, &m.Requests5xx, &m.TimedRequests, &m.LatencyMS}
+}
+
+// AddServiceLogMetrics adds counts to the per-minute log metrics; a minute
+// written twice, e.g. around a restart, accumulates.
+func (r *Repository) AddServiceLogMetrics(ctx context.Context, metrics []models.ServiceLogMetric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, r.dialect.Rebind(`INSERT INTO service_log_metrics(`+serviceLogMetricColumns+`) VALUES (?,?,?,?,?,?,?,?,?)
+		ON CONFLICT(service_id,ts) DO UPDATE SET lines=service_log_metrics.lines+excluded.lines,
+			error_lines=service_log_metrics.error_lines+excluded.error_lines,bytes=service_log_metrics.bytes+excluded.bytes,
+			requests=service_log_metrics.requests+excluded.requests,requests_5xx=service_log_metrics.requests_5xx+excluded.requests_5xx,
+			timed_requests=service_log_metrics.timed_requests+excluded.timed_requests,latency_ms=service_log_metrics.latency_ms+excluded.latency_ms`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, m := range metrics {
+		if _, err := stmt.ExecContext(ctx, m.TS.UTC(), m.ServiceID, m.Lines, m.ErrorLines, m.Bytes, m.Requests, m.Requests5xx, m.TimedRequests, m.LatencyMS); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ServiceLogMetrics returns the minutes of one service since from, oldest
+// first. Minutes without logs have no row.
+func (r *Repository) ServiceLogMetrics(ctx context.Context, serviceID string, from time.Time) ([]models.ServiceLogMetric, error) {
+	rows, err := r.query(ctx, `SELECT `+serviceLogMetricColumns+` FROM service_log_metrics
+		WHERE service_id=? AND ts >= ? ORDER BY ts`, serviceID, from.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.ServiceLogMetric
+	for rows.Next() {
+		var m models.ServiceLogMetric
+		if err := rows.Scan(serviceLogMetricDest(&m)...); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// ServiceLogTotals sums the log metrics of every service since from.
+func (r *Repository) ServiceLogTotals(ctx context.Context, from time.Time) (map[string]models.ServiceLogMetric, error) {
+	rows, err := r.query(ctx, `SELECT service_id,SUM(lines),SUM(error_lines),SUM(bytes),SUM(requests),SUM(requests_5xx),SUM(timed_requests),SUM(latency_ms)
+		FROM service_log_metrics WHERE ts >= ? GROUP BY service_id`, from.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]models.ServiceLogMetric{}
+	for rows.Next() {
+		var m models.ServiceLogMetric
+		if err := rows.Scan(&m.ServiceID, &m.Lines, &m.ErrorLines, &m.Bytes, &m.Requests, &m.Requests5xx, &m.TimedRequests, &m.LatencyMS); err != nil {
+			return nil, err
+		}
+		out[m.ServiceID] = m
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestServiceLogMetricsAccumulate(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	minute := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+
+	for _, batch := range [][]models.ServiceLogMetric{
+		{{TS: minute, ServiceID: "web", Lines: 10, ErrorLines: 1, Bytes: 400}, {TS: minute, ServiceID: "db", Lines: 2, Bytes: 80}},
+		{{TS: minute, ServiceID: "web", Lines: 5, ErrorLines: 2, Bytes: 100}, {TS: minute.Add(time.Minute), ServiceID: "web", Lines: 1, Bytes: 10}},
+	} {
+		if err := repo.AddServiceLogMetrics(ctx, batch); err != nil {
+			t.Fatalf("add metrics: %v", err)
+		}
+	}
+	web, err := repo.ServiceLogMetrics(ctx, "web", minute)
+	if err != nil {
+		t.Fatalf("service metrics: %v", err)
+	}
+	if len(web) != 2 || web[0].Lines != 15 || web[0].ErrorLines != 3 || web[0].Bytes != 500 || !web[1].TS.Equal(minute.Add(time.Minute)) {
+		t.Fatalf("web metrics = %+v", web)
+	}
+	totals, err := repo.ServiceLogTotals(ctx, minute.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("totals: %v", err)
+	}
+	if len(totals) != 1 || totals["web"].Lines != 1 {
+		t.Fatalf("totals = %+v", totals)
+	}
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// SaveLogStreamPosition records how far a container's log stream has been
+// read. The timestamp is kept in nanoseconds, as Docker reports it.
+func (r *Repository) SaveLogStreamPosition(ctx context.Context, p models.LogStreamPosition) error {
+	_, err := r.exec(ctx, `INSERT INTO log_stream_positions(container_id,ts_ns,lines,updated_at) VALUES (?,?,?,?)
+		ON CONFLICT(container_id) DO UPDATE SET ts_ns=excluded.ts_ns,lines=excluded.lines,updated_at=excluded.updated_at`,
+		p.ContainerID, p.TS.UnixNano(), p.Lines, p.UpdatedAt.UTC())
+	return err
+}
+
+// GetLogStreamPosition returns sql.ErrNoRows for containers whose logs
+// were never read.
+func (r *Repository) GetLogStreamPosition(ctx context.Context, containerID string) (models.LogStreamPosition, error) {
+	p := models.LogStreamPosition{ContainerID: containerID}
+	var ns int64
+	err := r.queryRow(ctx, `SELECT ts_ns,lines,updated_at FROM log_stream_positions WHERE container_id=?`, containerID).Scan(&ns, &p.Lines, &p.UpdatedAt)
+	if err != nil {
+		return models.LogStreamPosition{}, err
+	}
+	p.TS = time.Unix(0, ns).UTC()
+	return p, nil
+}
+
+// PruneLogStreamPositions forgets the positions of containers whose logs
+// were last read before before, returning how many were removed.
+func (r *Repository) PruneLogStreamPositions(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.exec(ctx, `DELETE FROM log_stream_positions WHERE updated_at < ?`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,161 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// logsSchema is the name the dedicated logs database is attached under.
+// Repository SQL keeps using unqualified table names; SQLite resolves them
+// across attached databases as long as main holds no table of that name.
+const logsSchema = "logdb"
+
+// sqliteConnector opens connections through a driver with a ConnectHook, so
+// every pooled connection gets the logs database attached.
+type sqliteConnector struct {
+	dsn string
+	drv *sqlite3.SQLiteDriver
+}
+
+func (c sqliteConnector) Connect(context.Context) (driver.Conn, error) { return c.drv.Open(c.dsn) }
+func (c sqliteConnector) Driver() driver.Driver                        { return c.drv }
+
+func attachLogsConnector(dsn, logsPath string) driver.Connector {
+	return sqliteConnector{dsn: dsn, drv: &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if _, err := conn.Exec(`ATTACH DATABASE ? AS `+logsSchema, []driver.Value{logsPath}); err != nil {
+				return fmt.Errorf("attach logs db: %w", err)
+			}
+			_, err := conn.Exec(`PRAGMA `+logsSchema+`.journal_mode=WAL`, nil)
+			return err
+		},
+	}}
+}
+
+// logsAttached reports whether logs live in a separate attached database.
+func logsAttached(db *sql.DB) bool {
+	rows, err := db.Query(`PRAGMA database_list`)
+	if err != nil {
+		return false
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var seq int
+		var name, file string
+		if err := rows.Scan(&seq, &name, &file); err == nil && name == logsSchema {
+			return true
+		}
+	}
+	return false
+}
+
+// migrateLogs creates the logs table and its indexes, in the attached logs
+// database when there is one. Foreign keys cannot span database files, so
+// the attached variant drops them; container purges delete logs explicitly.
+func migrateLogs(db *sql.DB, d Dialect) error {
+	schema := ""
+	if d == SQLite && logsAttached(db) {
+		schema = logsSchema
+	}
+	fks := `,
+			FOREIGN KEY(service_id) REFERENCES services(id) ON DELETE CASCADE,
+			FOREIGN KEY(container_id) REFERENCES containers(id) ON DELETE CASCADE`
+	if schema != "" {
+		fks = ""
+	}
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS ` + qualify(schema, "logs") + ` (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ts DATETIME NOT NULL,
+			service_id TEXT NOT NULL,
+			container_id TEXT NOT NULL,
+			level TEXT NOT NULL,
+			stream TEXT NOT NULL,
+			message TEXT NOT NULL,
+			repeat_count INTEGER NOT NULL DEFAULT 1,
+			last_seen DATETIME,
+			fields TEXT` + fks + `
+		);`,
+		`CREATE INDEX IF NOT EXISTS ` + qualify(schema, "idx_logs_service_ts") + ` ON logs(service_id, ts DESC);`,
+		`CREATE INDEX IF NOT EXISTS ` + qualify(schema, "idx_logs_container_ts") + ` ON logs(container_id, ts DESC);`,
+		`CREATE INDEX IF NOT EXISTS ` + qualify(schema, "idx_logs_ts") + ` ON logs(ts DESC);`,
+		`CREATE INDEX IF NOT EXISTS ` + qualify(schema, "idx_logs_level_ts") + ` ON logs(level, ts DESC);`,
+		`CREATE INDEX IF NOT EXISTS ` + qualify(schema, "idx_logs_stream_ts") + ` ON logs(stream, ts DESC);`,
+		`CREATE INDEX IF NOT EXISTS ` + qualify(schema, "idx_logs_service_level_ts") + ` ON logs(service_id, level, ts DESC);`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(d.DDL(stmt)); err != nil {
+			return fmt.Errorf("migrate logs: %w", err)
+		}
+	}
+	if schema != "" {
+		if err := moveLogsToAttached(db); err != nil {
+			return err
+		}
+	}
+	// Once the main database has no logs table, the unqualified name
+	// resolves to the attached one.
+	for _, c := range []struct{ column, def string }{
+		{"repeat_count", "INTEGER NOT NULL DEFAULT 1"},
+		{"last_seen", "DATETIME"},
+		{"fields", "TEXT"},
+	} {
+		if err := addColumn(db, d, "logs", c.column, c.def); err != nil {
+			return fmt.Errorf("migrate logs: %w", err)
+		}
+	}
+	if d == SQLite {
+		return migrateLogsFTS(db, schema)
+	}
+	return nil
+}
+
+// moveLogsToAttached copies logs left in the main database (from before
+// APP_LOGS_DB_PATH was set) into the attached one and drops the originals.
+// INSERT OR IGNORE keeps it safe to rerun after an interrupted move.
+func moveLogsToAttached(db *sql.DB) error {
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM main.sqlite_master WHERE type='table' AND name='logs'`).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	columns := "id,ts,service_id,container_id,level,stream,message"
+	var repeats int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('logs','main') WHERE name='repeat_count'`).Scan(&repeats); err != nil {
+		return err
+	}
+	if repeats > 0 {
+		columns += ",repeat_count,last_seen"
+	}
+	var fields int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('logs','main') WHERE name='fields'`).Scan(&fields); err != nil {
+		return err
+	}
+	if fields > 0 {
+		columns += ",fields"
+	}
+	stmts := []string{
+		`INSERT OR IGNORE INTO ` + logsSchema + `.logs (` + columns + `) SELECT ` + columns + ` FROM main.logs`,
+		`DROP TABLE IF EXISTS main.logs_fts`,
+		`DROP TABLE main.logs`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("move logs to attached db: %w", err)
+		}
+	}
+	return nil
+}
+
+func qualify(schema, name string) string {
+	if schema == "" {
+		return name
+	}
+	return schema + "." + name
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestLogsInAttachedDatabase(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+
+	// Start with logs in the main database, then split them out.
+	sqldb, err := Open(dir + "/app.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := NewRepository(sqldb)
+	seedContainer(t, repo, ctx, "svc-a", "c1", now)
+	if err := repo.InsertLogs(ctx, []models.LogEntry{
+		{TS: now.Add(-time.Minute), ServiceID: "svc-a", ContainerID: "c1", Level: "INFO", Stream: "stdout", Message: "before split"},
+	}); err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+	_ = sqldb.Close()
+
+	sqldb, err = OpenWithLogs(dir+"/app.db", dir+"/logs/logs.db")
+	if err != nil {
+		t.Fatalf("open split db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	sqldb.SetMaxOpenConns(2)
+	if err := Migrate(sqldb); err != nil {
+		t.Fatalf("migrate split db: %v", err)
+	}
+	repo = NewRepository(sqldb)
+	if err := repo.InsertLogs(ctx, []models.LogEntry{
+		{TS: now, ServiceID: "svc-a", ContainerID: "c1", Level: "ERROR", Stream: "stderr", Message: "after split"},
+	}); err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+
+	var inMain int
+	if err := sqldb.QueryRow(`SELECT COUNT(*) FROM main.sqlite_master WHERE name='logs'`).Scan(&inMain); err != nil {
+		t.Fatalf("inspect main: %v", err)
+	}
+	if inMain != 0 {
+		t.Fatal("logs table still present in main database")
+	}
+	entries, err := repo.QueryLogs(ctx, LogQuery{ServiceID: "svc-a", Limit: 10})
+	if err != nil {
+		t.Fatalf("query logs: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries len = %d, want 2", len(entries))
+	}
+	entries, err = repo.QueryLogs(ctx, LogQuery{Query: "before", Limit: 10})
+	if err != nil {
+		t.Fatalf("search logs: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("search entries len = %d, want 1", len(entries))
+	}
+
+	logsOnly, err := sql.Open("sqlite3", dir+"/logs/logs.db")
+	if err != nil {
+		t.Fatalf("open logs file: %v", err)
+	}
+	defer logsOnly.Close()
+	var n int
+	if err := logsOnly.QueryRow(`SELECT COUNT(*) FROM logs`).Scan(&n); err != nil {
+		t.Fatalf("count logs file: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("logs file rows = %d, want 2", n)
+	}
+}
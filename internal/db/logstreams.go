@@ -0,0 +1,26 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// CountLogsByStream counts the log lines of stream since since per
+// container, including collapsed repeats.
+func (r *Repository) CountLogsByStream(ctx context.Context, stream string, since time.Time) (map[string]int64, error) {
+	rows, err := r.query(ctx, `SELECT container_id, SUM(repeat_count) FROM logs WHERE stream=? AND ts>=? GROUP BY container_id`, stream, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]int64{}
+	for rows.Next() {
+		var id string
+		var n int64
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		out[id] = n
+	}
+	return out, rows.Err()
+}
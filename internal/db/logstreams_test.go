@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestCountLogsByStream(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	seedContainer(t, repo, ctx, "api", "c1", now)
+	marker := models.LogEntry{ServiceID: "api", ContainerID: "c1", Level: "WARN", Stream: "dashi", Message: "logs sampled"}
+	entries := []models.LogEntry{
+		{TS: now.Add(-10 * time.Minute), ServiceID: "api", ContainerID: "c1", Level: "WARN", Stream: "dashi", Message: "old"},
+		{TS: now.Add(-time.Minute), ServiceID: "api", ContainerID: "c1", Level: "INFO", Stream: "stdout", Message: "hello"},
+	}
+	if err := repo.InsertLogs(ctx, entries); err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+	// Identical markers collapse into one row with a repeat count.
+	for i := 2; i > 0; i-- {
+		marker.TS = now.Add(-time.Duration(i) * time.Second)
+		if err := repo.InsertLogsCollapsed(ctx, []models.LogEntry{marker}, time.Minute); err != nil {
+			t.Fatalf("insert marker: %v", err)
+		}
+	}
+	counts, err := repo.CountLogsByStream(ctx, "dashi", now.Add(-5*time.Minute))
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if len(counts) != 1 || counts["c1"] != 2 {
+		t.Fatalf("counts = %v", counts)
+	}
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// StorageFile describes one SQLite database file (main or attached) and
+// how much of it is free or still sitting in the WAL.
+type StorageFile struct {
+	Schema     string
+	Path       string
+	PageSize   int64
+	Pages      int64
+	FreePages  int64
+	AutoVacuum int // 0 none, 1 full, 2 incremental
+	WALBytes   int64
+}
+
+func (f StorageFile) FreeBytes() int64 { return f.FreePages * f.PageSize }
+
+// StorageFiles reports space usage for every database on the connection.
+func (r *Repository) StorageFiles(ctx context.Context) ([]StorageFile, error) {
+	if r.dialect != SQLite {
+		return nil, ErrUnsupported
+	}
+	rows, err := r.db.QueryContext(ctx, `PRAGMA database_list`)
+	if err != nil {
+		return nil, err
+	}
+	var out []StorageFile
+	for rows.Next() {
+		var seq int
+		var f StorageFile
+		if err := rows.Scan(&seq, &f.Schema, &f.Path); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if f.Schema == "temp" {
+			continue
+		}
+		out = append(out, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range out {
+		f := &out[i]
+		for _, p := range []struct {
+			name string
+			dst  any
+		}{
+			{"page_size", &f.PageSize},
+			{"page_count", &f.Pages},
+			{"freelist_count", &f.FreePages},
+			{"auto_vacuum", &f.AutoVacuum},
+		} {
+			if err := r.db.QueryRowContext(ctx, `PRAGMA `+f.Schema+`.`+p.name).Scan(p.dst); err != nil {
+				return nil, fmt.Errorf("pragma %s.%s: %w", f.Schema, p.name, err)
+			}
+		}
+		if f.Path != "" {
+			if st, err := os.Stat(f.Path + "-wal"); err == nil {
+				f.WALBytes = st.Size()
+			}
+		}
+	}
+	return out, nil
+}
+
+// EnableIncrementalVacuum switches schema to incremental auto-vacuum. The
+// mode only takes effect after a full VACUUM, so this rewrites the file once.
+func (r *Repository) EnableIncrementalVacuum(ctx context.Context, schema string) error {
+	if r.dialect != SQLite {
+		return ErrUnsupported
+	}
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `PRAGMA `+schema+`.auto_vacuum=INCREMENTAL`); err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, `VACUUM `+schema)
+	return err
+}
+
+// IncrementalVacuum returns up to pages free pages of schema to the file
+// system; pages <= 0 frees all of them.
+func (r *Repository) IncrementalVacuum(ctx context.Context, schema string, pages int) error {
+	if r.dialect != SQLite {
+		return ErrUnsupported
+	}
+	q := `PRAGMA ` + schema + `.incremental_vacuum`
+	if pages > 0 {
+		q += fmt.Sprintf("(%d)", pages)
+	}
+	// Each step frees one page, so the statement has to be drained.
+	rows, err := r.db.QueryContext(ctx, q)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// CheckpointWAL copies the WAL back into the database files and truncates it.
+func (r *Repository) CheckpointWAL(ctx context.Context) error {
+	if r.dialect != SQLite {
+		return ErrUnsupported
+	}
+	var busy, logFrames, checkpointed int
+	if err := r.db.QueryRowContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`).Scan(&busy, &logFrames, &checkpointed); err != nil {
+		return err
+	}
+	if busy != 0 {
+		return fmt.Errorf("wal checkpoint blocked by readers (%d/%d frames)", checkpointed, logFrames)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"dashi/internal/models"
+)
+
+// ReplaceStoragePools stores the current pools of one type, dropping pools
+// of that type that no longer exist.
+func (r *Repository) ReplaceStoragePools(ctx context.Context, typ string, pools []models.StoragePool) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	rb := r.dialect.Rebind
+	if _, err := tx.ExecContext(ctx, rb(`DELETE FROM storage_pools WHERE type=?`), typ); err != nil {
+		return err
+	}
+	for _, p := range pools {
+		var scrubAt any
+		if p.ScrubAt != nil {
+			scrubAt = p.ScrubAt.UTC()
+		}
+		if _, err := tx.ExecContext(ctx, rb(`INSERT INTO storage_pools(type,name,health,size_bytes,alloc_bytes,device_errors,data_errors,scrub_state,scrub_errors,scrub_at,checked_at)
+			VALUES (?,?,?,?,?,?,?,?,?,?,?)`),
+			typ, p.Name, p.Health, p.SizeBytes, p.AllocBytes, p.DeviceErrors, p.DataErrors, p.ScrubState, p.ScrubErrors, scrubAt, p.CheckedAt.UTC()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *Repository) ListStoragePools(ctx context.Context) ([]models.StoragePool, error) {
+	rows, err := r.query(ctx, `SELECT type,name,health,size_bytes,alloc_bytes,device_errors,data_errors,scrub_state,scrub_errors,scrub_at,checked_at
+		FROM storage_pools ORDER BY type, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.StoragePool
+	for rows.Next() {
+		var p models.StoragePool
+		var scrubAt sql.NullTime
+		if err := rows.Scan(&p.Type, &p.Name, &p.Health, &p.SizeBytes, &p.AllocBytes, &p.DeviceErrors, &p.DataErrors, &p.ScrubState, &p.ScrubErrors, &scrubAt, &p.CheckedAt); err != nil {
+			return nil, err
+		}
+		if scrubAt.Valid {
+			p.ScrubAt = &scrubAt.Time
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
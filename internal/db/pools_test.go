@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestReplaceStoragePools(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+	scrub := now.Add(-24 * time.Hour)
+
+	if err := repo.ReplaceStoragePools(ctx, "zfs", []models.StoragePool{
+		{Name: "tank", Health: "ONLINE", SizeBytes: 4 << 40, AllocBytes: 1 << 40, ScrubState: "finished", ScrubAt: &scrub, CheckedAt: now},
+		{Name: "old", Health: "ONLINE", CheckedAt: now},
+	}); err != nil {
+		t.Fatalf("replace zfs: %v", err)
+	}
+	if err := repo.ReplaceStoragePools(ctx, "btrfs", []models.StoragePool{{Name: "data", Health: "DEGRADED", DeviceErrors: 3, CheckedAt: now}}); err != nil {
+		t.Fatalf("replace btrfs: %v", err)
+	}
+	if err := repo.ReplaceStoragePools(ctx, "zfs", []models.StoragePool{
+		{Name: "tank", Health: "DEGRADED", SizeBytes: 4 << 40, AllocBytes: 1 << 40, ScrubState: "finished", ScrubErrors: 2, ScrubAt: &scrub, CheckedAt: now.Add(time.Minute)},
+	}); err != nil {
+		t.Fatalf("replace zfs: %v", err)
+	}
+
+	pools, err := repo.ListStoragePools(ctx)
+	if err != nil {
+		t.Fatalf("list pools: %v", err)
+	}
+	if len(pools) != 2 || pools[0].Type != "btrfs" || pools[1].Name != "tank" {
+		t.Fatalf("pools = %+v", pools)
+	}
+	tank := pools[1]
+	if tank.Health != "DEGRADED" || tank.ScrubErrors != 2 || tank.ScrubAt == nil || !tank.ScrubAt.Equal(scrub) {
+		t.Fatalf("tank = %+v", tank)
+	}
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"context"
+
+	"dashi/internal/models"
+)
+
+func (r *Repository) InsertHostReboot(ctx context.Context, rb models.HostReboot) error {
+	_, err := r.exec(ctx, `INSERT INTO host_reboots (booted_at,last_seen_at,prev_uptime_sec,detected_at) VALUES (?,?,?,?)`,
+		rb.BootedAt.UTC(), rb.LastSeenAt.UTC(), rb.PrevUptimeSec, rb.DetectedAt.UTC())
+	return err
+}
+
+// RecentHostReboots returns up to limit reboots, newest first.
+func (r *Repository) RecentHostReboots(ctx context.Context, limit int) ([]models.HostReboot, error) {
+	rows, err := r.query(ctx, `SELECT id,booted_at,last_seen_at,prev_uptime_sec,detected_at FROM host_reboots ORDER BY booted_at DESC, id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.HostReboot
+	for rows.Next() {
+		var rb models.HostReboot
+		if err := rows.Scan(&rb.ID, &rb.BootedAt, &rb.LastSeenAt, &rb.PrevUptimeSec, &rb.DetectedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, rb)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestRecentHostReboots(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+
+	for i, prevUp := range []int64{86400, 3600, 600} {
+		boot := now.Add(time.Duration(i-3) * time.Hour)
+		rb := models.HostReboot{BootedAt: boot, LastSeenAt: boot.Add(-2 * time.Minute), PrevUptimeSec: prevUp, DetectedAt: boot.Add(time.Minute)}
+		if err := repo.InsertHostReboot(ctx, rb); err != nil {
+			t.Fatalf("insert reboot: %v", err)
+		}
+	}
+	reboots, err := repo.RecentHostReboots(ctx, 2)
+	if err != nil {
+		t.Fatalf("recent reboots: %v", err)
+	}
+	if len(reboots) != 2 || reboots[0].PrevUptimeSec != 600 || reboots[1].PrevUptimeSec != 3600 {
+		t.Fatalf("reboots = %+v", reboots)
+	}
+	if d := reboots[0].BootedAt.Sub(reboots[0].LastSeenAt); d != 2*time.Minute {
+		t.Fatalf("downtime = %v", d)
+	}
+}
@@ -1,11 +1,14 @@
 package db
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,7 +16,12 @@ import (
 )
 
 type Repository struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect Dialect
+	fts     bool
+	queries *queryStats
+	hot     *hotCache
+	hosts   *hostIDs
 }
 
 type ActiveAlertTarget struct {
@@ -22,58 +30,189 @@ type ActiveAlertTarget struct {
 }
 
 func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+	d := DialectOf(db)
+	return &Repository{db: db, dialect: d, fts: d == SQLite && hasLogsFTS(db), queries: &queryStats{}, hot: newHotCache(), hosts: newHostIDs()}
 }
 
 func (r *Repository) DB() *sql.DB { return r.db }
 
+// FullTextSearch reports whether log searches use the FTS5 index.
+func (r *Repository) FullTextSearch() bool { return r.fts }
+
 func (r *Repository) UpsertServiceAndContainer(ctx context.Context, svc models.Service, c models.Container) error {
 	now := time.Now().UTC()
-	_, err := r.db.ExecContext(ctx, `INSERT INTO services (id,name,image,labels_json,first_seen_at,last_seen_at,status)
-		VALUES (?,?,?,?,?,?,?)
-		ON CONFLICT(id) DO UPDATE SET name=excluded.name,image=excluded.image,labels_json=excluded.labels_json,last_seen_at=excluded.last_seen_at,status=excluded.status`,
-		svc.ID, svc.Name, svc.Image, svc.LabelsJSON, now, now, svc.Status)
+	var prevLabels string
+	err := r.queryRow(ctx, `SELECT labels_json FROM services WHERE id = ?`, svc.ID).Scan(&prevLabels)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	labelsChanged := err != nil || prevLabels != svc.LabelsJSON
+	svcHost, err := r.hostID(ctx, svc.Host)
 	if err != nil {
 		return err
 	}
-	_, err = r.db.ExecContext(ctx, `INSERT INTO containers (id,service_id,name,status,started_at,last_seen_at,restart_count)
-		VALUES (?,?,?,?,?,?,?)
-		ON CONFLICT(id) DO UPDATE SET service_id=excluded.service_id,name=excluded.name,status=excluded.status,last_seen_at=excluded.last_seen_at,restart_count=excluded.restart_count`,
-		c.ID, c.ServiceID, c.Name, c.Status, c.StartedAt, now, c.RestartCount)
-	return err
+	cHost, err := r.hostID(ctx, c.Host)
+	if err != nil {
+		return err
+	}
+	_, err = r.exec(ctx, `INSERT INTO services (id,host,host_id,name,image,labels_json,first_seen_at,last_seen_at,status)
+		VALUES (?,?,?,?,?,?,?,?,?)
+		ON CONFLICT(id) DO UPDATE SET host=excluded.host,host_id=excluded.host_id,name=excluded.name,image=excluded.image,labels_json=excluded.labels_json,last_seen_at=excluded.last_seen_at,status=excluded.status`,
+		svc.ID, hostOrLocal(svc.Host), svcHost, svc.Name, svc.Image, svc.LabelsJSON, now, now, svc.Status)
+	if err != nil {
+		return err
+	}
+	if labelsChanged {
+		if err := r.replaceLabels(ctx, svc.ID, svc.LabelsJSON); err != nil {
+			return err
+		}
+	}
+	// A container stays with its host while that reports it, so two hosts
+	// reporting the same ID do not overwrite each other's.
+	res, err := r.exec(ctx, `INSERT INTO containers (id,service_id,host,host_id,name,status,health,started_at,last_seen_at,restart_count,ports_json,networks_json,links_json)
+		VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?)
+		ON CONFLICT(id) DO UPDATE SET service_id=excluded.service_id,host=excluded.host,host_id=excluded.host_id,name=excluded.name,status=excluded.status,health=excluded.health,started_at=COALESCE(excluded.started_at,containers.started_at),last_seen_at=excluded.last_seen_at,restart_count=excluded.restart_count,ports_json=excluded.ports_json,networks_json=excluded.networks_json,links_json=excluded.links_json
+		WHERE containers.host_id=excluded.host_id OR containers.last_seen_at < ?`,
+		c.ID, c.ServiceID, hostOrLocal(c.Host), cHost, c.Name, c.Status, c.Health, c.StartedAt, now, c.RestartCount, jsonList(c.Ports), jsonList(c.Networks), jsonList(c.Links), now.Add(-containerTakeover))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("container %s on %s: %w", c.ID, hostOrLocal(c.Host), ErrContainerConflict)
+	}
+	return r.recordStatusChanges(ctx, now, "c.id=?", c.ID)
 }
 
-func (r *Repository) MarkMissingContainers(ctx context.Context, seenIDs []string) error {
+// MarkMissingContainers marks containers of host that were not in the
+// latest listing as missing.
+func (r *Repository) MarkMissingContainers(ctx context.Context, host string, seenIDs []string) error {
+	host = hostOrLocal(host)
 	if len(seenIDs) == 0 {
-		_, err := r.db.ExecContext(ctx, `UPDATE containers SET status='missing' WHERE status!='missing'`)
-		return err
+		if _, err := r.exec(ctx, `UPDATE containers SET status='missing' WHERE host=? AND status NOT IN ('missing','archived')`, host); err != nil {
+			return err
+		}
+		return r.recordStatusChanges(ctx, time.Now().UTC(), "c.host=?", host)
 	}
 	placeholders := make([]string, len(seenIDs))
-	args := make([]any, 0, len(seenIDs))
+	args := make([]any, 0, len(seenIDs)+1)
+	args = append(args, host)
 	for i, id := range seenIDs {
-		placeholders[i] = "?" + strconv.Itoa(i+1)
+		placeholders[i] = "?"
 		args = append(args, id)
 	}
-	query := fmt.Sprintf(`UPDATE containers SET status='missing' WHERE id NOT IN (%s) AND status!='missing'`, strings.Join(placeholders, ","))
-	_, err := r.db.ExecContext(ctx, query, args...)
-	return err
+	query := fmt.Sprintf(`UPDATE containers SET status='missing' WHERE host=? AND id NOT IN (%s) AND status NOT IN ('missing','archived')`, strings.Join(placeholders, ","))
+	if _, err := r.exec(ctx, query, args...); err != nil {
+		return err
+	}
+	return r.recordStatusChanges(ctx, time.Now().UTC(), "c.host=?", host)
+}
+
+// hostMetricColumns are the host_metrics columns read and written in the
+// order of hostMetricArgs and hostMetricDest.
+const hostMetricColumns = `ts,cpu_pct,mem_used_bytes,mem_total_bytes,net_rx_bytes,net_tx_bytes,disk_used_bytes,disk_total_bytes,load1,load5,load15,uptime_sec,net_rx_rate,net_tx_rate,
+	disk_read_rate,disk_write_rate,disk_read_iops,disk_write_iops,disk_util_pct,cpu_temp_c,nvme_temp_c,
+	swap_used_bytes,swap_total_bytes,tcp_established,tcp_syn_recv,tcp_time_wait,tcp_close_wait,tcp_listen,tcp_conns`
+
+func hostMetricArgs(m models.HostMetric) []any {
+	return []any{m.TS.UTC(), m.CPUPct, m.MemUsedBytes, m.MemTotalBytes, m.NetRXBytes, m.NetTXBytes, m.DiskUsedBytes, m.DiskTotalBytes,
+		m.Load1, m.Load5, m.Load15, m.UptimeSec, m.NetRXRate, m.NetTXRate,
+		m.DiskReadRate, m.DiskWriteRate, m.DiskReadIOPS, m.DiskWriteIOPS, m.DiskUtilPct, m.CPUTempC, m.NVMeTempC,
+		m.SwapUsedBytes, m.SwapTotalBytes, m.TCPEstablished, m.TCPSynRecv, m.TCPTimeWait, m.TCPCloseWait, m.TCPListen, m.TCPConns}
+}
+
+func hostMetricDest(m *models.HostMetric) []any {
+	return []any{&m.TS, &m.CPUPct, &m.MemUsedBytes, &m.MemTotalBytes, &m.NetRXBytes, &m.NetTXBytes, &m.DiskUsedBytes, &m.DiskTotalBytes,
+		&m.Load1, &m.Load5, &m.Load15, &m.UptimeSec, &m.NetRXRate, &m.NetTXRate,
+		&m.DiskReadRate, &m.DiskWriteRate, &m.DiskReadIOPS, &m.DiskWriteIOPS, &m.DiskUtilPct, &m.CPUTempC, &m.NVMeTempC,
+		&m.SwapUsedBytes, &m.SwapTotalBytes, &m.TCPEstablished, &m.TCPSynRecv, &m.TCPTimeWait, &m.TCPCloseWait, &m.TCPListen, &m.TCPConns}
+}
+
+// containerHostID is the host_id of the container given as its argument,
+// for samples and log lines; the local host for unknown containers, which
+// only the logs database, having no foreign keys, accepts.
+const containerHostID = `COALESCE((SELECT host_id FROM containers WHERE id=?),1)`
+
+var insertHostMetric = `INSERT INTO host_metrics (` + hostMetricColumns + `) VALUES (` + placeholders(len(hostMetricArgs(models.HostMetric{}))) + `)`
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
 }
 
 func (r *Repository) InsertHostMetric(ctx context.Context, m models.HostMetric) error {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO host_metrics
-		(ts,cpu_pct,mem_used_bytes,mem_total_bytes,net_rx_bytes,net_tx_bytes,disk_used_bytes,disk_total_bytes,load1,load5,load15,uptime_sec)
-		VALUES (?,?,?,?,?,?,?,?,?,?,?,?)`,
-		m.TS.UTC(), m.CPUPct, m.MemUsedBytes, m.MemTotalBytes, m.NetRXBytes, m.NetTXBytes, m.DiskUsedBytes, m.DiskTotalBytes,
-		m.Load1, m.Load5, m.Load15, m.UptimeSec)
-	return err
+	if _, err := r.exec(ctx, insertHostMetric, hostMetricArgs(m)...); err != nil {
+		return err
+	}
+	r.hot.putHost(m)
+	if err := r.insertDiskIO(ctx, r.db, m.Disks); err != nil {
+		return err
+	}
+	return r.insertTemperatures(ctx, r.db, m.Temperatures)
 }
 
 func (r *Repository) InsertContainerMetric(ctx context.Context, m models.ContainerMetric) error {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO container_metrics
-		(ts,container_id,cpu_pct,mem_used_bytes,mem_limit_bytes,net_rx_bytes,net_tx_bytes,blk_read_bytes,blk_write_bytes)
-		VALUES (?,?,?,?,?,?,?,?,?)`,
-		m.TS.UTC(), m.ContainerID, m.CPUPct, m.MemUsedBytes, m.MemLimitBytes, m.NetRXBytes, m.NetTXBytes, m.BlkReadBytes, m.BlkWriteBytes)
-	return err
+	_, err := r.exec(ctx, `INSERT INTO container_metrics
+		(ts,container_id,host_id,cpu_pct,mem_used_bytes,mem_limit_bytes,net_rx_bytes,net_tx_bytes,blk_read_bytes,blk_write_bytes,net_rx_rate,net_tx_rate,blk_read_rate,blk_write_rate,pids,pids_limit,fds,fd_limit)
+		VALUES (?,?,`+containerHostID+`,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		m.TS.UTC(), m.ContainerID, m.ContainerID, m.CPUPct, m.MemUsedBytes, m.MemLimitBytes, m.NetRXBytes, m.NetTXBytes, m.BlkReadBytes, m.BlkWriteBytes,
+		m.NetRXRate, m.NetTXRate, m.BlkReadRate, m.BlkWriteRate, m.Pids, m.PidsLimit, m.FDs, m.FDLimit)
+	if err != nil {
+		return err
+	}
+	r.hot.putContainers(m)
+	return nil
+}
+
+// InsertMetricsBatch writes host and container samples in one transaction.
+func (r *Repository) InsertMetricsBatch(ctx context.Context, hosts []models.HostMetric, containers []models.ContainerMetric) error {
+	if len(hosts) == 0 && len(containers) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if len(hosts) > 0 {
+		stmt, err := tx.PrepareContext(ctx, r.dialect.Rebind(insertHostMetric))
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, m := range hosts {
+			if _, err := stmt.ExecContext(ctx, hostMetricArgs(m)...); err != nil {
+				return err
+			}
+			if err := r.insertDiskIO(ctx, tx, m.Disks); err != nil {
+				return err
+			}
+			if err := r.insertTemperatures(ctx, tx, m.Temperatures); err != nil {
+				return err
+			}
+		}
+	}
+	if len(containers) > 0 {
+		stmt, err := tx.PrepareContext(ctx, r.dialect.Rebind(`INSERT INTO container_metrics
+			(ts,container_id,host_id,cpu_pct,mem_used_bytes,mem_limit_bytes,net_rx_bytes,net_tx_bytes,blk_read_bytes,blk_write_bytes,net_rx_rate,net_tx_rate,blk_read_rate,blk_write_rate,pids,pids_limit,fds,fd_limit)
+			VALUES (?,?,`+containerHostID+`,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`))
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, m := range containers {
+			if _, err := stmt.ExecContext(ctx, m.TS.UTC(), m.ContainerID, m.ContainerID, m.CPUPct, m.MemUsedBytes, m.MemLimitBytes, m.NetRXBytes, m.NetTXBytes, m.BlkReadBytes, m.BlkWriteBytes,
+				m.NetRXRate, m.NetTXRate, m.BlkReadRate, m.BlkWriteRate, m.Pids, m.PidsLimit, m.FDs, m.FDLimit); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, m := range hosts {
+		r.hot.putHost(m)
+	}
+	r.hot.putContainers(containers...)
+	return nil
 }
 
 func (r *Repository) InsertLogs(ctx context.Context, entries []models.LogEntry) error {
@@ -85,28 +224,37 @@ func (r *Repository) InsertLogs(ctx context.Context, entries []models.LogEntry)
 		return err
 	}
 	defer tx.Rollback()
-	stmt, err := tx.PrepareContext(ctx, `INSERT INTO logs (ts,service_id,container_id,level,stream,message) VALUES (?,?,?,?,?,?)`)
+	stmt, err := tx.PrepareContext(ctx, r.dialect.Rebind(`INSERT INTO logs (ts,service_id,container_id,host_id,level,stream,message,fields) VALUES (?,?,?,`+containerHostID+`,?,?,?,?)`))
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 	for _, e := range entries {
-		if _, err := stmt.ExecContext(ctx, e.TS.UTC(), e.ServiceID, e.ContainerID, e.Level, e.Stream, e.Message); err != nil {
+		if _, err := stmt.ExecContext(ctx, e.TS.UTC(), e.ServiceID, e.ContainerID, e.ContainerID, e.Level, e.Stream, e.Message, fieldsValue(e.Fields)); err != nil {
 			return err
 		}
 	}
 	return tx.Commit()
 }
 
+// LatestHostMetric returns the newest host sample, from memory once one
+// was written or read since the repository was opened.
 func (r *Repository) LatestHostMetric(ctx context.Context) (models.HostMetric, error) {
-	var m models.HostMetric
-	err := r.db.QueryRowContext(ctx, `SELECT ts,cpu_pct,mem_used_bytes,mem_total_bytes,net_rx_bytes,net_tx_bytes,disk_used_bytes,disk_total_bytes,load1,load5,load15,uptime_sec FROM host_metrics ORDER BY ts DESC LIMIT 1`).
-		Scan(&m.TS, &m.CPUPct, &m.MemUsedBytes, &m.MemTotalBytes, &m.NetRXBytes, &m.NetTXBytes, &m.DiskUsedBytes, &m.DiskTotalBytes, &m.Load1, &m.Load5, &m.Load15, &m.UptimeSec)
+	r.hot.mu.RLock()
+	m, ok := r.hot.host, r.hot.hostOK
+	r.hot.mu.RUnlock()
+	if ok {
+		return m, nil
+	}
+	err := r.queryRow(ctx, `SELECT `+hostMetricColumns+` FROM host_metrics ORDER BY ts DESC LIMIT 1`).Scan(hostMetricDest(&m)...)
+	if err == nil {
+		r.hot.putHost(m)
+	}
 	return m, err
 }
 
 func (r *Repository) RecentHostMetrics(ctx context.Context, from time.Time, limit int) ([]models.HostMetric, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT ts,cpu_pct,mem_used_bytes,mem_total_bytes,net_rx_bytes,net_tx_bytes,disk_used_bytes,disk_total_bytes,load1,load5,load15,uptime_sec FROM host_metrics WHERE ts >= ? ORDER BY ts ASC LIMIT ?`, from.UTC(), limit)
+	rows, err := r.query(ctx, `SELECT `+hostMetricColumns+` FROM host_metrics WHERE ts >= ? ORDER BY ts ASC LIMIT ?`, from.UTC(), limit)
 	if err != nil {
 		return nil, err
 	}
@@ -114,7 +262,7 @@ func (r *Repository) RecentHostMetrics(ctx context.Context, from time.Time, limi
 	out := make([]models.HostMetric, 0, limit)
 	for rows.Next() {
 		var m models.HostMetric
-		if err := rows.Scan(&m.TS, &m.CPUPct, &m.MemUsedBytes, &m.MemTotalBytes, &m.NetRXBytes, &m.NetTXBytes, &m.DiskUsedBytes, &m.DiskTotalBytes, &m.Load1, &m.Load5, &m.Load15, &m.UptimeSec); err != nil {
+		if err := rows.Scan(hostMetricDest(&m)...); err != nil {
 			return nil, err
 		}
 		out = append(out, m)
@@ -122,8 +270,30 @@ func (r *Repository) RecentHostMetrics(ctx context.Context, from time.Time, limi
 	return out, rows.Err()
 }
 
+// LatestContainerMetrics returns the newest sample of every container with
+// one since from, keyed by container ID.
+func (r *Repository) LatestContainerMetrics(ctx context.Context, from time.Time) (map[string]models.ContainerMetric, error) {
+	rows, err := r.query(ctx, `SELECT cm.ts,cm.container_id,cm.cpu_pct,cm.mem_used_bytes,cm.mem_limit_bytes,cm.pids,cm.pids_limit,cm.fds,cm.fd_limit
+		FROM container_metrics cm
+		JOIN (SELECT container_id, MAX(ts) AS ts FROM container_metrics WHERE ts >= ? GROUP BY container_id) l
+			ON l.container_id=cm.container_id AND l.ts=cm.ts`, from.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]models.ContainerMetric{}
+	for rows.Next() {
+		var m models.ContainerMetric
+		if err := rows.Scan(&m.TS, &m.ContainerID, &m.CPUPct, &m.MemUsedBytes, &m.MemLimitBytes, &m.Pids, &m.PidsLimit, &m.FDs, &m.FDLimit); err != nil {
+			return nil, err
+		}
+		out[m.ContainerID] = m
+	}
+	return out, rows.Err()
+}
+
 func (r *Repository) RecentContainerMetrics(ctx context.Context, containerID string, from time.Time, limit int) ([]models.ContainerMetric, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT ts,container_id,cpu_pct,mem_used_bytes,mem_limit_bytes,net_rx_bytes,net_tx_bytes,blk_read_bytes,blk_write_bytes FROM container_metrics WHERE container_id = ? AND ts >= ? ORDER BY ts ASC LIMIT ?`, containerID, from.UTC(), limit)
+	rows, err := r.query(ctx, `SELECT ts,container_id,cpu_pct,mem_used_bytes,mem_limit_bytes,net_rx_bytes,net_tx_bytes,blk_read_bytes,blk_write_bytes,net_rx_rate,net_tx_rate,blk_read_rate,blk_write_rate,pids,pids_limit,fds,fd_limit FROM container_metrics WHERE container_id = ? AND ts >= ? ORDER BY ts ASC LIMIT ?`, containerID, from.UTC(), limit)
 	if err != nil {
 		return nil, err
 	}
@@ -131,7 +301,8 @@ func (r *Repository) RecentContainerMetrics(ctx context.Context, containerID str
 	out := make([]models.ContainerMetric, 0, limit)
 	for rows.Next() {
 		var m models.ContainerMetric
-		if err := rows.Scan(&m.TS, &m.ContainerID, &m.CPUPct, &m.MemUsedBytes, &m.MemLimitBytes, &m.NetRXBytes, &m.NetTXBytes, &m.BlkReadBytes, &m.BlkWriteBytes); err != nil {
+		if err := rows.Scan(&m.TS, &m.ContainerID, &m.CPUPct, &m.MemUsedBytes, &m.MemLimitBytes, &m.NetRXBytes, &m.NetTXBytes, &m.BlkReadBytes, &m.BlkWriteBytes,
+			&m.NetRXRate, &m.NetTXRate, &m.BlkReadRate, &m.BlkWriteRate, &m.Pids, &m.PidsLimit, &m.FDs, &m.FDLimit); err != nil {
 			return nil, err
 		}
 		out = append(out, m)
@@ -139,66 +310,100 @@ func (r *Repository) RecentContainerMetrics(ctx context.Context, containerID str
 	return out, rows.Err()
 }
 
-func (r *Repository) ListServicesWithHealth(ctx context.Context, minCPU float64, minMemBytes int64, limit int, includeMissing bool) ([]map[string]any, error) {
+// ListServicesWithHealth returns the containers with their newest CPU and
+// memory sample, busiest first. The samples come from the hot cache rather
+// than a subquery per container, as the services fragment polls this.
+func (r *Repository) ListServicesWithHealth(ctx context.Context, minCPU float64, minMemBytes int64, limit int, includeMissing bool, host string, labels LabelSelector) ([]map[string]any, error) {
 	if limit <= 0 || limit > 200 {
 		limit = 20
 	}
-	missingFilter := ""
+	latest, err := r.latestContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	missingFilter := " AND c.status!='archived'"
 	if !includeMissing {
-		missingFilter = " AND c.status NOT IN ('missing','exited')"
+		missingFilter = " AND c.status NOT IN ('missing','exited','archived')"
 	}
-	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`SELECT s.id,s.name,c.status,c.id,c.restart_count,c.last_seen_at,
-		COALESCE((SELECT cpu_pct FROM container_metrics cm WHERE cm.container_id=c.id ORDER BY ts DESC LIMIT 1),0),
-		COALESCE((SELECT mem_used_bytes FROM container_metrics cm WHERE cm.container_id=c.id ORDER BY ts DESC LIMIT 1),0),
-		COALESCE((SELECT MAX(ts) FROM logs l WHERE l.container_id=c.id),'')
+	var args []any
+	labelClauses, labelArgs := labels.sql("s.id")
+	for _, c := range labelClauses {
+		missingFilter += " AND " + c
+	}
+	args = append(args, labelArgs...)
+	if host != "" {
+		missingFilter += " AND c.host = ?"
+		args = append(args, host)
+	}
+	rows, err := r.query(ctx, `SELECT s.id,s.name,c.host,c.status,c.id,c.restart_count,c.last_seen_at,
+		(SELECT MAX(ts) FROM logs l WHERE l.container_id=c.id),
+		COALESCE((SELECT update_available FROM image_updates iu WHERE iu.service_id=s.id),0),
+		c.ports_json
 		FROM services s JOIN containers c ON c.service_id=s.id
-		WHERE (
-			COALESCE((SELECT cpu_pct FROM container_metrics cm WHERE cm.container_id=c.id ORDER BY ts DESC LIMIT 1),0) >= ?
-			AND COALESCE((SELECT mem_used_bytes FROM container_metrics cm WHERE cm.container_id=c.id ORDER BY ts DESC LIMIT 1),0) >= ?
-		)%s
-		ORDER BY
-			COALESCE((SELECT cpu_pct FROM container_metrics cm WHERE cm.container_id=c.id ORDER BY ts DESC LIMIT 1),0) DESC,
-			COALESCE((SELECT mem_used_bytes FROM container_metrics cm WHERE cm.container_id=c.id ORDER BY ts DESC LIMIT 1),0) DESC,
-			c.restart_count DESC
-		LIMIT ?`, missingFilter), minCPU, minMemBytes, limit)
+		WHERE 1=1`+missingFilter, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	var out []map[string]any
 	for rows.Next() {
-		var svcID, name, status, containerID string
+		var svcID, name, host, status, containerID string
 		var restart int
 		var lastSeen time.Time
-		var cpu float64
-		var mem int64
 		var lastLog sql.NullString
-		if err := rows.Scan(&svcID, &name, &status, &containerID, &restart, &lastSeen, &cpu, &mem, &lastLog); err != nil {
+		var update int
+		var portsJSON string
+		if err := rows.Scan(&svcID, &name, &host, &status, &containerID, &restart, &lastSeen, &lastLog, &update, &portsJSON); err != nil {
 			return nil, err
 		}
+		m := latest[containerID]
+		if m.CPUPct < minCPU || m.MemUsedBytes < minMemBytes {
+			continue
+		}
+		var ports []models.Port
+		_ = json.Unmarshal([]byte(portsJSON), &ports)
 		out = append(out, map[string]any{
 			"service_id":     svcID,
 			"name":           name,
+			"host":           host,
 			"status":         status,
 			"container_id":   containerID,
 			"restart_count":  restart,
 			"last_seen":      lastSeen,
-			"cpu_pct":        cpu,
-			"mem_used_bytes": mem,
+			"cpu_pct":        m.CPUPct,
+			"mem_used_bytes": m.MemUsedBytes,
 			"last_log":       lastLog.String,
+			"update":         update == 1,
+			"ports":          ports,
 		})
 	}
-	return out, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(out, func(a, b map[string]any) int {
+		if c := cmp.Compare(b["cpu_pct"].(float64), a["cpu_pct"].(float64)); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b["mem_used_bytes"].(int64), a["mem_used_bytes"].(int64)); c != 0 {
+			return c
+		}
+		return cmp.Compare(b["restart_count"].(int), a["restart_count"].(int))
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
 }
 
-func (r *Repository) QueryLogs(ctx context.Context, serviceID, q, level, stream string, from, to *time.Time, limit int) ([]models.LogEntry, error) {
-	clauses, args := buildLogFilters(serviceID, q, level, stream, from, to)
+func (r *Repository) QueryLogs(ctx context.Context, f LogQuery) ([]models.LogEntry, error) {
+	where, args := r.logWhere(f)
+	limit := f.Limit
 	if limit <= 0 || limit > 1000 {
 		limit = 200
 	}
 	args = append(args, limit)
-	query := fmt.Sprintf(`SELECT ts,service_id,container_id,level,stream,message FROM logs WHERE %s ORDER BY ts DESC LIMIT ?`, strings.Join(clauses, " AND "))
-	rows, err := r.db.QueryContext(ctx, query, args...)
+	query := `SELECT ts,service_id,container_id,level,stream,message,repeat_count,last_seen,fields FROM logs` + where + ` ORDER BY ts DESC LIMIT ?`
+	rows, err := r.query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -206,16 +411,23 @@ func (r *Repository) QueryLogs(ctx context.Context, serviceID, q, level, stream
 	out := make([]models.LogEntry, 0, limit)
 	for rows.Next() {
 		var e models.LogEntry
-		if err := rows.Scan(&e.TS, &e.ServiceID, &e.ContainerID, &e.Level, &e.Stream, &e.Message); err != nil {
+		var lastSeen sql.NullTime
+		var fields sql.NullString
+		if err := rows.Scan(&e.TS, &e.ServiceID, &e.ContainerID, &e.Level, &e.Stream, &e.Message, &e.RepeatCount, &lastSeen, &fields); err != nil {
 			return nil, err
 		}
+		if lastSeen.Valid {
+			t := lastSeen.Time
+			e.LastSeen = &t
+		}
+		e.Fields = scanFields(fields)
 		out = append(out, e)
 	}
 	return out, rows.Err()
 }
 
-func (r *Repository) GroupLogs(ctx context.Context, groupBy, serviceID, q, level, stream string, from, to *time.Time, limit int) ([]map[string]any, error) {
-	column := ""
+func (r *Repository) GroupLogs(ctx context.Context, groupBy string, f LogQuery) ([]map[string]any, error) {
+	column, present := "", ""
 	switch groupBy {
 	case "service":
 		column = "service_id"
@@ -224,17 +436,31 @@ func (r *Repository) GroupLogs(ctx context.Context, groupBy, serviceID, q, level
 	case "stream":
 		column = "stream"
 	default:
-		return nil, fmt.Errorf("unsupported group_by: %s", groupBy)
+		name, ok := strings.CutPrefix(groupBy, "field.")
+		if !ok || !FieldName.MatchString(name) {
+			return nil, fmt.Errorf("unsupported group_by: %s", groupBy)
+		}
+		column = r.dialect.JSONText("fields", name)
+		// Grouping on a field only counts the entries that have it.
+		present = column + " IS NOT NULL"
 	}
 
-	clauses, args := buildLogFilters(serviceID, q, level, stream, from, to)
+	where, args := r.logWhere(f)
+	if present != "" {
+		if where == "" {
+			where = " WHERE " + present
+		} else {
+			where += " AND " + present
+		}
+	}
+	limit := f.Limit
 	if limit <= 0 || limit > 500 {
 		limit = 100
 	}
 	args = append(args, limit)
 
-	query := fmt.Sprintf(`SELECT %s AS group_key, COUNT(*) AS count FROM logs WHERE %s GROUP BY %s ORDER BY count DESC, group_key ASC LIMIT ?`, column, strings.Join(clauses, " AND "), column)
-	rows, err := r.db.QueryContext(ctx, query, args...)
+	query := fmt.Sprintf(`SELECT %s AS group_key, COUNT(*) AS count FROM logs%s GROUP BY %s ORDER BY count DESC, group_key ASC LIMIT ?`, column, where, column)
+	rows, err := r.query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -252,38 +478,134 @@ func (r *Repository) GroupLogs(ctx context.Context, groupBy, serviceID, q, level
 	return out, rows.Err()
 }
 
-func buildLogFilters(serviceID, q, level, stream string, from, to *time.Time) ([]string, []any) {
-	clauses := []string{"1=1"}
-	args := []any{}
-	if serviceID != "" {
+// LogQuery holds the criteria shared by QueryLogs and GroupLogs. Zero
+// fields do not filter.
+type LogQuery struct {
+	ServiceID string
+	// ContainerID narrows the entries to one container of the service.
+	ContainerID string
+	// ServiceIDs matches any of several services.
+	ServiceIDs []string
+	Host       string
+	Query      string
+	Level      string
+	Stream     string
+	Labels     LabelSelector
+	// Contains matches messages holding the text anywhere, without regard
+	// to case. Unlike Query it never uses the FTS index, which only
+	// matches whole tokens.
+	Contains string
+	// Fields maps extracted field names (see FieldName) to the value they
+	// must have.
+	Fields   map[string]string
+	From, To *time.Time
+	Limit    int
+}
+
+// logWhere renders the filter as a WHERE clause ANDing its predicates: the
+// service, container, level and stream equalities and the ts range, which
+// the (service_id, level, ts), (level, ts) and (stream, ts) indexes can
+// serve, then extracted fields, labels, host and text, which they cannot.
+// The planner picks the index whatever the order.
+func (r *Repository) logWhere(f LogQuery) (string, []any) {
+	var clauses []string
+	var args []any
+	if f.ServiceID != "" {
 		clauses = append(clauses, "service_id = ?")
-		args = append(args, serviceID)
+		args = append(args, f.ServiceID)
+	}
+	if f.ContainerID != "" {
+		clauses = append(clauses, "container_id = ?")
+		args = append(args, f.ContainerID)
 	}
-	if level != "" {
+	if len(f.ServiceIDs) > 0 {
+		clauses = append(clauses, "service_id IN (?"+strings.Repeat(",?", len(f.ServiceIDs)-1)+")")
+		for _, id := range f.ServiceIDs {
+			args = append(args, id)
+		}
+	}
+	if f.Level != "" {
 		clauses = append(clauses, "level = ?")
-		args = append(args, strings.ToUpper(level))
+		args = append(args, strings.ToUpper(f.Level))
 	}
-	if stream != "" {
+	if f.Stream != "" {
 		clauses = append(clauses, "stream = ?")
-		args = append(args, strings.ToLower(stream))
-	}
-	if q != "" {
-		clauses = append(clauses, "message LIKE ?")
-		args = append(args, "%"+q+"%")
+		args = append(args, strings.ToLower(f.Stream))
 	}
-	if from != nil {
+	if f.From != nil {
 		clauses = append(clauses, "ts >= ?")
-		args = append(args, from.UTC())
+		args = append(args, f.From.UTC())
 	}
-	if to != nil {
+	if f.To != nil {
 		clauses = append(clauses, "ts <= ?")
-		args = append(args, to.UTC())
+		args = append(args, f.To.UTC())
+	}
+	for _, name := range slices.Sorted(maps.Keys(f.Fields)) {
+		if !FieldName.MatchString(name) {
+			continue
+		}
+		clauses = append(clauses, r.dialect.JSONText("fields", name)+" = ?")
+		args = append(args, f.Fields[name])
+	}
+	labelClauses, labelArgs := f.Labels.sql("service_id")
+	clauses = append(clauses, labelClauses...)
+	args = append(args, labelArgs...)
+	if f.Host != "" {
+		clauses = append(clauses, "host_id = (SELECT id FROM hosts WHERE name = ?)")
+		args = append(args, f.Host)
+	}
+	// No index serves text search, except the FTS subquery, which yields
+	// rowids directly.
+	if f.Query != "" {
+		if match := ftsQuery(f.Query); r.fts && match != "" {
+			clauses = append(clauses, "id IN (SELECT rowid FROM logs_fts WHERE logs_fts MATCH ?)")
+			args = append(args, match)
+		} else {
+			clauses = append(clauses, "message LIKE ?")
+			args = append(args, "%"+f.Query+"%")
+		}
 	}
-	return clauses, args
+	if f.Contains != "" {
+		clauses = append(clauses, "LOWER(message) LIKE ?")
+		args = append(args, "%"+strings.ToLower(f.Contains)+"%")
+	}
+	if len(clauses) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(clauses, " AND "), args
+}
+
+// LogHistogram counts the lines matching f, which must bound ts by From, in
+// buckets of step aligned to multiples of it since the epoch, oldest first.
+// Buckets without lines are left out.
+func (r *Repository) LogHistogram(ctx context.Context, f LogQuery, step time.Duration) ([]models.LogBucket, error) {
+	sec := max(int64(step.Seconds()), 1)
+	where, args := r.logWhere(f)
+	rows, err := r.query(ctx, `SELECT (`+r.dialect.Epoch("ts")+` / ?) * ? AS b, level, SUM(repeat_count) FROM logs`+where+`
+		GROUP BY b, level ORDER BY b`, append([]any{sec, sec}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.LogBucket
+	for rows.Next() {
+		var bucket, n int64
+		var level string
+		if err := rows.Scan(&bucket, &level, &n); err != nil {
+			return nil, err
+		}
+		if len(out) == 0 || out[len(out)-1].TS.Unix() != bucket {
+			out = append(out, models.LogBucket{TS: time.Unix(bucket, 0).UTC(), Levels: map[string]int64{}})
+		}
+		b := &out[len(out)-1]
+		b.Total += n
+		b.Levels[level] += n
+	}
+	return out, rows.Err()
 }
 
 func (r *Repository) ListRules(ctx context.Context) ([]models.AlertRule, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id,name,target_type,target_id_nullable,metric_key,operator,threshold,for_seconds,cooldown_seconds,enabled FROM alert_rules ORDER BY id`)
+	rows, err := r.query(ctx, `SELECT id,name,target_type,target_id_nullable,metric_key,operator,threshold,for_seconds,cooldown_seconds,enabled,label_selector FROM alert_rules ORDER BY id`)
 	if err != nil {
 		return nil, err
 	}
@@ -293,7 +615,7 @@ func (r *Repository) ListRules(ctx context.Context) ([]models.AlertRule, error)
 		var rule models.AlertRule
 		var target sql.NullString
 		var enabled int
-		if err := rows.Scan(&rule.ID, &rule.Name, &rule.TargetType, &target, &rule.MetricKey, &rule.Operator, &rule.Threshold, &rule.ForSeconds, &rule.CooldownSeconds, &enabled); err != nil {
+		if err := rows.Scan(&rule.ID, &rule.Name, &rule.TargetType, &target, &rule.MetricKey, &rule.Operator, &rule.Threshold, &rule.ForSeconds, &rule.CooldownSeconds, &enabled, &rule.LabelSelector); err != nil {
 			return nil, err
 		}
 		if target.Valid {
@@ -307,7 +629,7 @@ func (r *Repository) ListRules(ctx context.Context) ([]models.AlertRule, error)
 }
 
 func (r *Repository) UpsertAlertState(ctx context.Context, ruleID int64, target, state string, since time.Time, lastFired, lastRecovered *time.Time) error {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO alert_states (rule_id,target_fingerprint,state,since_ts,last_fired_ts,last_recovered_ts)
+	_, err := r.exec(ctx, `INSERT INTO alert_states (rule_id,target_fingerprint,state,since_ts,last_fired_ts,last_recovered_ts)
 		VALUES (?,?,?,?,?,?)
 		ON CONFLICT(rule_id,target_fingerprint) DO UPDATE SET state=excluded.state,since_ts=excluded.since_ts,last_fired_ts=excluded.last_fired_ts,last_recovered_ts=excluded.last_recovered_ts`,
 		ruleID, target, state, since.UTC(), lastFired, lastRecovered)
@@ -316,7 +638,7 @@ func (r *Repository) UpsertAlertState(ctx context.Context, ruleID int64, target,
 
 func (r *Repository) GetAlertState(ctx context.Context, ruleID int64, target string) (state string, since time.Time, lastFired, lastRecovered *time.Time, err error) {
 	var fired, recovered sql.NullTime
-	err = r.db.QueryRowContext(ctx, `SELECT state,since_ts,last_fired_ts,last_recovered_ts FROM alert_states WHERE rule_id=? AND target_fingerprint=?`, ruleID, target).
+	err = r.queryRow(ctx, `SELECT state,since_ts,last_fired_ts,last_recovered_ts FROM alert_states WHERE rule_id=? AND target_fingerprint=?`, ruleID, target).
 		Scan(&state, &since, &fired, &recovered)
 	if err != nil {
 		return "", time.Time{}, nil, nil, err
@@ -334,26 +656,65 @@ func (r *Repository) GetAlertState(ctx context.Context, ruleID int64, target str
 
 func (r *Repository) CreateAlert(ctx context.Context, ruleID int64, target, status, summary string, details map[string]any, started time.Time) (int64, error) {
 	b, _ := json.Marshal(details)
-	res, err := r.db.ExecContext(ctx, `INSERT INTO alerts (rule_id,target_fingerprint,status,started_ts,summary,details_json) VALUES (?,?,?,?,?,?)`, ruleID, target, status, started.UTC(), summary, string(b))
-	if err != nil {
-		return 0, err
-	}
-	return res.LastInsertId()
+	defer r.hot.dropAlerts()
+	return r.insertID(ctx, `INSERT INTO alerts (rule_id,target_fingerprint,status,started_ts,summary,details_json) VALUES (?,?,?,?,?,?)`, ruleID, target, status, started.UTC(), summary, string(b))
 }
 
 func (r *Repository) CloseAlert(ctx context.Context, ruleID int64, target string, ended time.Time) error {
-	_, err := r.db.ExecContext(ctx, `UPDATE alerts SET status='recovered', ended_ts_nullable=? WHERE rule_id=? AND target_fingerprint=? AND status='firing'`, ended.UTC(), ruleID, target)
+	defer r.hot.dropAlerts()
+	_, err := r.exec(ctx, `UPDATE alerts SET status='recovered', ended_ts_nullable=? WHERE rule_id=? AND target_fingerprint=? AND status='firing'`, ended.UTC(), ruleID, target)
 	return err
 }
 
-func (r *Repository) RecentAlerts(ctx context.Context, since time.Time, limit int) ([]map[string]any, error) {
+// RecentAlerts returns the alerts started since since, newest first; with
+// a host, only those on its containers, services or, for the local host,
+// the host itself.
+func (r *Repository) RecentAlerts(ctx context.Context, since time.Time, limit int, host string) ([]map[string]any, error) {
 	if limit <= 0 {
 		limit = 100
 	}
-	rows, err := r.db.QueryContext(ctx, `SELECT a.id,a.status,a.started_ts,a.ended_ts_nullable,a.summary,r.name
+	filter := ""
+	args := []any{since.UTC()}
+	if host != "" {
+		filter = " AND " + alertHostID + " = (SELECT id FROM hosts WHERE name = ?)"
+		args = append(args, host)
+	}
+	return r.alertRows(ctx, `SELECT a.id,a.status,a.started_ts,a.ended_ts_nullable,a.summary,r.name
+		FROM alerts a JOIN alert_rules r ON r.id=a.rule_id
+		WHERE a.started_ts >= ?`+filter+`
+		ORDER BY a.started_ts DESC LIMIT ?`, append(args, limit)...)
+}
+
+// ContainerAlerts returns the alerts started since since on a container or
+// its service, newest first; with restartsOnly, only container_restarts
+// alerts of the container.
+func (r *Repository) ContainerAlerts(ctx context.Context, containerID, serviceID string, since time.Time, restartsOnly bool, limit int) ([]map[string]any, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	filter, args := " AND a.target_fingerprint IN (?,?)", []any{since.UTC(), containerID, serviceID}
+	if restartsOnly {
+		filter, args = " AND a.target_fingerprint=? AND r.metric_key='container_restarts'", []any{since.UTC(), containerID}
+	}
+	return r.alertRows(ctx, `SELECT a.id,a.status,a.started_ts,a.ended_ts_nullable,a.summary,r.name
+		FROM alerts a JOIN alert_rules r ON r.id=a.rule_id
+		WHERE a.started_ts >= ?`+filter+`
+		ORDER BY a.started_ts DESC LIMIT ?`, append(args, limit)...)
+}
+
+// FiringAlerts returns up to limit firing alerts, newest first, however
+// long ago they started.
+func (r *Repository) FiringAlerts(ctx context.Context, limit int) ([]map[string]any, error) {
+	return r.alertRows(ctx, `SELECT a.id,a.status,a.started_ts,a.ended_ts_nullable,a.summary,r.name
 		FROM alerts a JOIN alert_rules r ON r.id=a.rule_id
-		WHERE a.started_ts >= ?
-		ORDER BY a.started_ts DESC LIMIT ?`, since.UTC(), limit)
+		WHERE a.status='firing'
+		ORDER BY a.started_ts DESC LIMIT ?`, limit)
+}
+
+// alertRows runs query, which selects the id, status, start, end, summary
+// and rule name of alerts, and returns them as api.AlertsFrom takes them.
+func (r *Repository) alertRows(ctx context.Context, query string, args ...any) ([]map[string]any, error) {
+	rows, err := r.query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -380,7 +741,7 @@ func (r *Repository) RecentRestartAlerts(ctx context.Context, since time.Time, l
 	if limit <= 0 || limit > 200 {
 		limit = 20
 	}
-	rows, err := r.db.QueryContext(ctx, `SELECT a.id,a.target_fingerprint,a.status,a.started_ts,a.ended_ts_nullable,a.summary
+	rows, err := r.query(ctx, `SELECT a.id,a.target_fingerprint,a.status,a.started_ts,a.ended_ts_nullable,a.summary
 		FROM alerts a
 		JOIN alert_rules r ON r.id=a.rule_id
 		WHERE r.metric_key='container_restarts' AND a.started_ts >= ?
@@ -410,7 +771,7 @@ func (r *Repository) RecentRestartAlerts(ctx context.Context, since time.Time, l
 }
 
 func (r *Repository) DeleteRecoveredAlerts(ctx context.Context) (int64, error) {
-	res, err := r.db.ExecContext(ctx, `DELETE FROM alerts WHERE status='recovered'`)
+	res, err := r.exec(ctx, `DELETE FROM alerts WHERE status='recovered'`)
 	if err != nil {
 		return 0, err
 	}
@@ -418,6 +779,7 @@ func (r *Repository) DeleteRecoveredAlerts(ctx context.Context) (int64, error) {
 }
 
 func (r *Repository) DeleteAllAlerts(ctx context.Context) (int64, error) {
+	defer r.hot.dropAlerts()
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
@@ -438,18 +800,32 @@ func (r *Repository) DeleteAllAlerts(ctx context.Context) (int64, error) {
 }
 
 func (r *Repository) InsertNotificationEvent(ctx context.Context, alertID int64, channel, status string, attempts int, lastErr string, sent *time.Time) error {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO notification_events (alert_id,channel,status,attempts,last_error,sent_ts_nullable) VALUES (?,?,?,?,?,?)`, alertID, channel, status, attempts, lastErr, sent)
+	_, err := r.exec(ctx, `INSERT INTO notification_events (alert_id,channel,status,attempts,last_error,sent_ts_nullable) VALUES (?,?,?,?,?,?)`, alertID, channel, status, attempts, lastErr, sent)
 	return err
 }
 
+// ActiveAlertCount returns the number of firing alerts, counted again
+// only after an alert was written.
 func (r *Repository) ActiveAlertCount(ctx context.Context) (int, error) {
-	var n int
-	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM alerts WHERE status='firing'`).Scan(&n)
-	return n, err
+	r.hot.mu.RLock()
+	n, ok, gen := r.hot.alerts, r.hot.alertsOK, r.hot.alertsGen
+	r.hot.mu.RUnlock()
+	if ok {
+		return n, nil
+	}
+	if err := r.queryRow(ctx, `SELECT COUNT(*) FROM alerts WHERE status='firing'`).Scan(&n); err != nil {
+		return 0, err
+	}
+	r.hot.mu.Lock()
+	if r.hot.alertsGen == gen {
+		r.hot.alerts, r.hot.alertsOK = n, true
+	}
+	r.hot.mu.Unlock()
+	return n, nil
 }
 
 func (r *Repository) ActiveAlertTargetsByMetric(ctx context.Context, metricKey string) ([]ActiveAlertTarget, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT a.rule_id,a.target_fingerprint
+	rows, err := r.query(ctx, `SELECT a.rule_id,a.target_fingerprint
 		FROM alerts a
 		JOIN alert_rules r ON r.id=a.rule_id
 		WHERE a.status='firing' AND r.metric_key=?
@@ -469,88 +845,308 @@ func (r *Repository) ActiveAlertTargetsByMetric(ctx context.Context, metricKey s
 	return out, rows.Err()
 }
 
-func (r *Repository) ListContainers(ctx context.Context) ([]models.Container, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id,service_id,name,status,started_at,last_seen_at,restart_count FROM containers`)
+// FiringAlertsByTarget counts the firing alerts of each target.
+func (r *Repository) FiringAlertsByTarget(ctx context.Context) (map[string]int, error) {
+	rows, err := r.query(ctx, `SELECT target_fingerprint,COUNT(*) FROM alerts WHERE status='firing' GROUP BY target_fingerprint`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var out []models.Container
+	out := map[string]int{}
 	for rows.Next() {
-		var c models.Container
-		var started sql.NullTime
-		if err := rows.Scan(&c.ID, &c.ServiceID, &c.Name, &c.Status, &started, &c.LastSeenAt, &c.RestartCount); err != nil {
+		var target string
+		var n int
+		if err := rows.Scan(&target, &n); err != nil {
 			return nil, err
 		}
-		if started.Valid {
-			t := started.Time
-			c.StartedAt = &t
+		out[target] = n
+	}
+	return out, rows.Err()
+}
+
+// ListServices returns the services with a container that is not
+// archived, by name.
+func (r *Repository) ListServices(ctx context.Context) ([]models.Service, error) {
+	rows, err := r.query(ctx, `SELECT id,host,name,image,labels_json,status FROM services s
+		WHERE EXISTS (SELECT 1 FROM containers c WHERE c.service_id=s.id AND c.status!='archived') ORDER BY name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.Service
+	for rows.Next() {
+		var svc models.Service
+		if err := rows.Scan(&svc.ID, &svc.Host, &svc.Name, &svc.Image, &svc.LabelsJSON, &svc.Status); err != nil {
+			return nil, err
 		}
-		out = append(out, c)
+		out = append(out, svc)
 	}
 	return out, rows.Err()
 }
 
+// ListContainers returns all containers that are not archived.
+func (r *Repository) ListContainers(ctx context.Context) ([]models.Container, error) {
+	rows, err := r.query(ctx, `SELECT `+containerColumns+` FROM containers WHERE status!='archived'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanContainers(rows)
+}
+
 func (r *Repository) UpdateRuleThresholds(ctx context.Context, id int64, threshold float64, forSec, cooldown int, enabled bool) error {
 	enabledInt := 0
 	if enabled {
 		enabledInt = 1
 	}
-	_, err := r.db.ExecContext(ctx, `UPDATE alert_rules SET threshold=?,for_seconds=?,cooldown_seconds=?,enabled=? WHERE id=?`, threshold, forSec, cooldown, enabledInt, id)
+	_, err := r.exec(ctx, `UPDATE alert_rules SET threshold=?,for_seconds=?,cooldown_seconds=?,enabled=? WHERE id=?`, threshold, forSec, cooldown, enabledInt, id)
 	return err
 }
 
-func (r *Repository) DeleteOlderThan(ctx context.Context, cutoff time.Time) error {
-	queries := []string{
+func (r *Repository) UpdateRuleLabelSelector(ctx context.Context, id int64, sel LabelSelector) error {
+	_, err := r.exec(ctx, `UPDATE alert_rules SET label_selector=? WHERE id=?`, sel.String(), id)
+	return err
+}
+
+func (r *Repository) DeleteMetricsOlderThan(ctx context.Context, cutoff time.Time) error {
+	for _, q := range []string{
 		`DELETE FROM host_metrics WHERE ts < ?`,
+		`DELETE FROM disk_io WHERE ts < ?`,
+		`DELETE FROM temperatures WHERE ts < ?`,
+		`DELETE FROM check_results WHERE ts < ?`,
 		`DELETE FROM container_metrics WHERE ts < ?`,
-		`DELETE FROM logs WHERE ts < ?`,
-		`DELETE FROM alerts WHERE started_ts < ? AND status='recovered'`,
-	}
-	for _, q := range queries {
-		if _, err := r.db.ExecContext(ctx, q, cutoff.UTC()); err != nil {
+		`DELETE FROM service_log_metrics WHERE ts < ?`,
+		`DELETE FROM self_metrics WHERE ts < ?`,
+		`DELETE FROM scrape_samples WHERE ts < ?`,
+	} {
+		if _, err := r.exec(ctx, q, cutoff.UTC()); err != nil {
 			return err
 		}
 	}
-	_, _ = r.db.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`)
-	_, _ = r.db.ExecContext(ctx, `PRAGMA optimize`)
+	r.hot.trim(cutoff)
 	return nil
 }
 
-func (r *Repository) SaveTelegramSettings(ctx context.Context, token, chatID string) error {
-	_, err := r.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS settings (key TEXT PRIMARY KEY, value TEXT NOT NULL)`)
+func (r *Repository) DeleteLogsOlderThan(ctx context.Context, cutoff time.Time) error {
+	_, err := r.exec(ctx, `DELETE FROM logs WHERE ts < ?`, cutoff.UTC())
+	return err
+}
+
+func (r *Repository) DeleteAlertsOlderThan(ctx context.Context, cutoff time.Time) error {
+	_, err := r.exec(ctx, `DELETE FROM alerts WHERE started_ts < ? AND status='recovered'`, cutoff.UTC())
+	return err
+}
+
+// Compact reclaims WAL space after large deletes. It is a no-op on Postgres.
+func (r *Repository) Compact(ctx context.Context) {
+	if r.dialect == SQLite {
+		_, _ = r.exec(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`)
+		_, _ = r.exec(ctx, `PRAGMA optimize`)
+	}
+}
+
+func (r *Repository) GetPreferences(ctx context.Context, owner string) (string, time.Time, error) {
+	var prefs string
+	var updated time.Time
+	err := r.queryRow(ctx, `SELECT prefs_json,updated_at FROM preferences WHERE owner=?`, owner).Scan(&prefs, &updated)
+	return prefs, updated, err
+}
+
+func (r *Repository) SavePreferences(ctx context.Context, owner, prefsJSON string) error {
+	_, err := r.exec(ctx, `INSERT INTO preferences (owner,prefs_json,updated_at) VALUES (?,?,?)
+		ON CONFLICT(owner) DO UPDATE SET prefs_json=excluded.prefs_json,updated_at=excluded.updated_at`,
+		owner, prefsJSON, time.Now().UTC())
+	return err
+}
+
+func (r *Repository) DeletePreferences(ctx context.Context, owner string) error {
+	_, err := r.exec(ctx, `DELETE FROM preferences WHERE owner=?`, owner)
+	return err
+}
+
+// RollupHostMetrics aggregates raw host samples in [from, to) into buckets of
+// the given resolution. Buckets are recomputed in place, so overlapping runs
+// are safe.
+func (r *Repository) RollupHostMetrics(ctx context.Context, res time.Duration, from, to time.Time) error {
+	sec := int64(res.Seconds())
+	_, err := r.exec(ctx, `INSERT INTO host_metrics_rollup
+		(resolution_sec,bucket,samples,cpu_pct,cpu_pct_min,cpu_pct_max,mem_used_bytes,mem_used_max,mem_total_bytes,net_rx_bytes,net_tx_bytes,disk_used_bytes,disk_total_bytes,load1,load1_max,load5,load15,uptime_sec,net_rx_rate,net_tx_rate,
+			disk_read_rate,disk_write_rate,disk_read_iops,disk_write_iops,disk_util_pct,cpu_temp_c,nvme_temp_c,swap_used_bytes,swap_total_bytes,
+			tcp_established,tcp_syn_recv,tcp_time_wait,tcp_close_wait,tcp_listen,tcp_conns)
+		SELECT CAST(? AS INTEGER), (`+r.dialect.Epoch("ts")+` / ?) * ? AS b, COUNT(*),
+			AVG(cpu_pct), MIN(cpu_pct), MAX(cpu_pct),
+			CAST(AVG(mem_used_bytes) AS INTEGER), MAX(mem_used_bytes), MAX(mem_total_bytes),
+			MAX(net_rx_bytes), MAX(net_tx_bytes),
+			CAST(AVG(disk_used_bytes) AS INTEGER), MAX(disk_total_bytes),
+			AVG(load1), MAX(load1), AVG(load5), AVG(load15), MAX(uptime_sec),
+			AVG(net_rx_rate), AVG(net_tx_rate),
+			AVG(disk_read_rate), AVG(disk_write_rate), AVG(disk_read_iops), AVG(disk_write_iops), AVG(disk_util_pct),
+			MAX(cpu_temp_c), MAX(nvme_temp_c),
+			CAST(AVG(swap_used_bytes) AS INTEGER), MAX(swap_total_bytes),
+			MAX(tcp_established), MAX(tcp_syn_recv), MAX(tcp_time_wait), MAX(tcp_close_wait), MAX(tcp_listen), MAX(tcp_conns)
+		FROM host_metrics WHERE ts >= ? AND ts < ?
+		GROUP BY b
+		ON CONFLICT(resolution_sec,bucket) DO UPDATE SET samples=excluded.samples,cpu_pct=excluded.cpu_pct,cpu_pct_min=excluded.cpu_pct_min,
+			cpu_pct_max=excluded.cpu_pct_max,mem_used_bytes=excluded.mem_used_bytes,mem_used_max=excluded.mem_used_max,mem_total_bytes=excluded.mem_total_bytes,
+			net_rx_bytes=excluded.net_rx_bytes,net_tx_bytes=excluded.net_tx_bytes,disk_used_bytes=excluded.disk_used_bytes,disk_total_bytes=excluded.disk_total_bytes,
+			load1=excluded.load1,load1_max=excluded.load1_max,load5=excluded.load5,load15=excluded.load15,uptime_sec=excluded.uptime_sec,
+			net_rx_rate=excluded.net_rx_rate,net_tx_rate=excluded.net_tx_rate,
+			disk_read_rate=excluded.disk_read_rate,disk_write_rate=excluded.disk_write_rate,disk_read_iops=excluded.disk_read_iops,
+			disk_write_iops=excluded.disk_write_iops,disk_util_pct=excluded.disk_util_pct,
+			cpu_temp_c=excluded.cpu_temp_c,nvme_temp_c=excluded.nvme_temp_c,
+			swap_used_bytes=excluded.swap_used_bytes,swap_total_bytes=excluded.swap_total_bytes,
+			tcp_established=excluded.tcp_established,tcp_syn_recv=excluded.tcp_syn_recv,tcp_time_wait=excluded.tcp_time_wait,
+			tcp_close_wait=excluded.tcp_close_wait,tcp_listen=excluded.tcp_listen,tcp_conns=excluded.tcp_conns`,
+		sec, sec, sec, from.UTC(), to.UTC())
+	return err
+}
+
+func (r *Repository) RollupContainerMetrics(ctx context.Context, res time.Duration, from, to time.Time) error {
+	sec := int64(res.Seconds())
+	_, err := r.exec(ctx, `INSERT INTO container_metrics_rollup
+		(resolution_sec,bucket,container_id,samples,cpu_pct,cpu_pct_min,cpu_pct_max,mem_used_bytes,mem_used_max,mem_limit_bytes,net_rx_bytes,net_tx_bytes,blk_read_bytes,blk_write_bytes,
+			net_rx_rate,net_tx_rate,blk_read_rate,blk_write_rate,pids,pids_limit,fds,fd_limit)
+		SELECT CAST(? AS INTEGER), (`+r.dialect.Epoch("ts")+` / ?) * ? AS b, container_id, COUNT(*),
+			AVG(cpu_pct), MIN(cpu_pct), MAX(cpu_pct),
+			CAST(AVG(mem_used_bytes) AS INTEGER), MAX(mem_used_bytes), MAX(mem_limit_bytes),
+			MAX(net_rx_bytes), MAX(net_tx_bytes), MAX(blk_read_bytes), MAX(blk_write_bytes),
+			AVG(net_rx_rate), AVG(net_tx_rate), AVG(blk_read_rate), AVG(blk_write_rate),
+			MAX(pids), MAX(pids_limit), MAX(fds), MAX(fd_limit)
+		FROM container_metrics WHERE ts >= ? AND ts < ?
+		GROUP BY container_id, b
+		ON CONFLICT(resolution_sec,container_id,bucket) DO UPDATE SET samples=excluded.samples,cpu_pct=excluded.cpu_pct,cpu_pct_min=excluded.cpu_pct_min,
+			cpu_pct_max=excluded.cpu_pct_max,mem_used_bytes=excluded.mem_used_bytes,mem_used_max=excluded.mem_used_max,mem_limit_bytes=excluded.mem_limit_bytes,
+			net_rx_bytes=excluded.net_rx_bytes,net_tx_bytes=excluded.net_tx_bytes,blk_read_bytes=excluded.blk_read_bytes,blk_write_bytes=excluded.blk_write_bytes,
+			net_rx_rate=excluded.net_rx_rate,net_tx_rate=excluded.net_tx_rate,blk_read_rate=excluded.blk_read_rate,blk_write_rate=excluded.blk_write_rate,
+			pids=excluded.pids,pids_limit=excluded.pids_limit,fds=excluded.fds,fd_limit=excluded.fd_limit`,
+		sec, sec, sec, from.UTC(), to.UTC())
+	return err
+}
+
+// LatestRollupBucket returns the start of the newest host rollup bucket for
+// res, or the zero time when nothing has been rolled up yet.
+func (r *Repository) LatestRollupBucket(ctx context.Context, res time.Duration) (time.Time, error) {
+	var bucket sql.NullInt64
+	err := r.queryRow(ctx, `SELECT MAX(bucket) FROM host_metrics_rollup WHERE resolution_sec=?`, int64(res.Seconds())).Scan(&bucket)
+	if err != nil || !bucket.Valid {
+		return time.Time{}, err
+	}
+	return time.Unix(bucket.Int64, 0).UTC(), nil
+}
+
+func (r *Repository) HostMetricRollups(ctx context.Context, res time.Duration, from time.Time, limit int) ([]models.HostMetricRollup, error) {
+	rows, err := r.query(ctx, `SELECT bucket,samples,cpu_pct,cpu_pct_min,cpu_pct_max,mem_used_bytes,mem_used_max,mem_total_bytes,net_rx_bytes,net_tx_bytes,disk_used_bytes,disk_total_bytes,load1,load1_max,load5,load15,uptime_sec,
+			net_rx_rate,net_tx_rate,disk_read_rate,disk_write_rate,disk_read_iops,disk_write_iops,disk_util_pct,cpu_temp_c,nvme_temp_c,swap_used_bytes,swap_total_bytes,
+			tcp_established,tcp_syn_recv,tcp_time_wait,tcp_close_wait,tcp_listen,tcp_conns
+		FROM host_metrics_rollup WHERE resolution_sec=? AND bucket >= ? ORDER BY bucket ASC LIMIT ?`, int64(res.Seconds()), from.Unix(), limit)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for k, v := range map[string]string{"telegram_token": token, "telegram_chat_id": chatID} {
-		if _, err := r.db.ExecContext(ctx, `INSERT INTO settings(key,value) VALUES (?,?) ON CONFLICT(key) DO UPDATE SET value=excluded.value`, k, v); err != nil {
-			return err
+	defer rows.Close()
+	out := make([]models.HostMetricRollup, 0, limit)
+	for rows.Next() {
+		var m models.HostMetricRollup
+		var bucket int64
+		if err := rows.Scan(&bucket, &m.Samples, &m.CPUPct, &m.CPUPctMin, &m.CPUPctMax, &m.MemUsedBytes, &m.MemUsedMax, &m.MemTotalBytes, &m.NetRXBytes, &m.NetTXBytes,
+			&m.DiskUsedBytes, &m.DiskTotalBytes, &m.Load1, &m.Load1Max, &m.Load5, &m.Load15, &m.UptimeSec, &m.NetRXRate, &m.NetTXRate,
+			&m.DiskReadRate, &m.DiskWriteRate, &m.DiskReadIOPS, &m.DiskWriteIOPS, &m.DiskUtilPct, &m.CPUTempC, &m.NVMeTempC,
+			&m.SwapUsedBytes, &m.SwapTotalBytes, &m.TCPEstablished, &m.TCPSynRecv, &m.TCPTimeWait, &m.TCPCloseWait, &m.TCPListen, &m.TCPConns); err != nil {
+			return nil, err
 		}
+		m.TS = time.Unix(bucket, 0).UTC()
+		m.Resolution = res
+		out = append(out, m)
 	}
-	return nil
+	return out, rows.Err()
 }
 
-func (r *Repository) LoadTelegramSettings(ctx context.Context) (token, chatID string, err error) {
-	_, err = r.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS settings (key TEXT PRIMARY KEY, value TEXT NOT NULL)`)
+func (r *Repository) ContainerMetricRollups(ctx context.Context, containerID string, res time.Duration, from time.Time, limit int) ([]models.ContainerMetricRollup, error) {
+	rows, err := r.query(ctx, `SELECT bucket,container_id,samples,cpu_pct,cpu_pct_min,cpu_pct_max,mem_used_bytes,mem_used_max,mem_limit_bytes,net_rx_bytes,net_tx_bytes,blk_read_bytes,blk_write_bytes,
+			net_rx_rate,net_tx_rate,blk_read_rate,blk_write_rate,pids,pids_limit,fds,fd_limit
+		FROM container_metrics_rollup WHERE resolution_sec=? AND container_id=? AND bucket >= ? ORDER BY bucket ASC LIMIT ?`, int64(res.Seconds()), containerID, from.Unix(), limit)
 	if err != nil {
-		return "", "", err
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]models.ContainerMetricRollup, 0, limit)
+	for rows.Next() {
+		var m models.ContainerMetricRollup
+		var bucket int64
+		if err := rows.Scan(&bucket, &m.ContainerID, &m.Samples, &m.CPUPct, &m.CPUPctMin, &m.CPUPctMax, &m.MemUsedBytes, &m.MemUsedMax, &m.MemLimitBytes,
+			&m.NetRXBytes, &m.NetTXBytes, &m.BlkReadBytes, &m.BlkWriteBytes, &m.NetRXRate, &m.NetTXRate, &m.BlkReadRate, &m.BlkWriteRate,
+			&m.Pids, &m.PidsLimit, &m.FDs, &m.FDLimit); err != nil {
+			return nil, err
+		}
+		m.TS = time.Unix(bucket, 0).UTC()
+		m.Resolution = res
+		out = append(out, m)
 	}
-	rows, err := r.db.QueryContext(ctx, `SELECT key,value FROM settings WHERE key IN ('telegram_token','telegram_chat_id')`)
+	return out, rows.Err()
+}
+
+// ContainerUsage aggregates each container's samples with from <= ts < to,
+// from raw samples for res 0 and from the rollups of res otherwise. With a
+// host, only its containers are included; archived ones are, as they may
+// have been the busiest back then.
+func (r *Repository) ContainerUsage(ctx context.Context, from, to time.Time, res time.Duration, host string) ([]models.ContainerUsage, error) {
+	query := `SELECT c.id,c.service_id,c.name,c.host,COUNT(*),AVG(m.cpu_pct),MAX(m.cpu_pct),AVG(m.mem_used_bytes),MAX(m.mem_used_bytes),
+			AVG(m.net_rx_rate+m.net_tx_rate),MAX(m.net_rx_rate+m.net_tx_rate),AVG(m.blk_read_rate+m.blk_write_rate),MAX(m.blk_read_rate+m.blk_write_rate)
+		FROM container_metrics m JOIN containers c ON c.id=m.container_id
+		WHERE m.ts >= ? AND m.ts < ?`
+	args := []any{from.UTC(), to.UTC()}
+	if res > 0 {
+		// Averages are weighted by the samples of each bucket.
+		query = `SELECT c.id,c.service_id,c.name,c.host,SUM(m.samples),SUM(m.cpu_pct*m.samples)/SUM(m.samples),MAX(m.cpu_pct_max),
+				SUM(m.mem_used_bytes*1.0*m.samples)/SUM(m.samples),MAX(m.mem_used_max),
+				SUM((m.net_rx_rate+m.net_tx_rate)*m.samples)/SUM(m.samples),MAX(m.net_rx_rate+m.net_tx_rate),
+				SUM((m.blk_read_rate+m.blk_write_rate)*m.samples)/SUM(m.samples),MAX(m.blk_read_rate+m.blk_write_rate)
+			FROM container_metrics_rollup m JOIN containers c ON c.id=m.container_id
+			WHERE m.resolution_sec=? AND m.bucket >= ? AND m.bucket < ?`
+		args = []any{int64(res.Seconds()), from.Unix(), to.Unix()}
+	}
+	if host != "" {
+		query += " AND c.host=?"
+		args = append(args, host)
+	}
+	rows, err := r.query(ctx, query+" GROUP BY c.id,c.service_id,c.name,c.host", args...)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 	defer rows.Close()
+	var out []models.ContainerUsage
 	for rows.Next() {
-		var k, v string
-		if err := rows.Scan(&k, &v); err != nil {
-			return "", "", err
-		}
-		if k == "telegram_token" {
-			token = v
+		var u models.ContainerUsage
+		if err := rows.Scan(&u.ContainerID, &u.ServiceID, &u.Name, &u.Host, &u.Samples, &u.CPUAvg, &u.CPUMax, &u.MemAvg, &u.MemMax,
+			&u.NetAvg, &u.NetMax, &u.BlkAvg, &u.BlkMax); err != nil {
+			return nil, err
 		}
-		if k == "telegram_chat_id" {
-			chatID = v
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repository) DeleteRollupsOlderThan(ctx context.Context, cutoff time.Time) error {
+	for _, q := range []string{
+		`DELETE FROM host_metrics_rollup WHERE bucket < ?`,
+		`DELETE FROM container_metrics_rollup WHERE bucket < ?`,
+	} {
+		if _, err := r.exec(ctx, q, cutoff.Unix()); err != nil {
+			return err
 		}
 	}
-	return token, chatID, rows.Err()
+	// Volume samples are taken on a slow ticker and kept as long as rollups.
+	_, err := r.exec(ctx, `DELETE FROM volume_usage WHERE ts < ?`, cutoff.UTC())
+	return err
+}
+
+var ErrUnsupported = errors.New("not supported by this database backend")
+
+// BackupTo writes a consistent snapshot of the database to path, which must
+// not exist yet. Only SQLite is supported; use pg_dump for Postgres.
+func (r *Repository) BackupTo(ctx context.Context, path string) error {
+	if r.dialect != SQLite {
+		return ErrUnsupported
+	}
+	_, err := r.db.ExecContext(ctx, `VACUUM INTO ?`, path)
+	return err
 }
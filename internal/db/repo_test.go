@@ -25,7 +25,7 @@ func TestQueryLogsFiltersByStreamLevelAndTime(t *testing.T) {
 	}
 
 	from := now.Add(-5 * time.Minute)
-	entries, err := repo.QueryLogs(ctx, "svc-a", "disk", "ERROR", "stderr", &from, nil, 50)
+	entries, err := repo.QueryLogs(ctx, LogQuery{ServiceID: "svc-a", Query: "disk", Level: "ERROR", Stream: "stderr", From: &from, Limit: 50})
 	if err != nil {
 		t.Fatalf("query logs: %v", err)
 	}
@@ -52,7 +52,7 @@ func TestGroupLogsByLevel(t *testing.T) {
 		t.Fatalf("insert logs: %v", err)
 	}
 
-	groups, err := repo.GroupLogs(ctx, "level", "svc", "", "", "", nil, nil, 10)
+	groups, err := repo.GroupLogs(ctx, "level", LogQuery{ServiceID: "svc", Limit: 10})
 	if err != nil {
 		t.Fatalf("group logs: %v", err)
 	}
@@ -64,6 +64,25 @@ func TestGroupLogsByLevel(t *testing.T) {
 	}
 }
 
+func TestSavePreferencesUpserts(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+
+	if err := repo.SavePreferences(ctx, "user:alex", `{"default_range":"1h"}`); err != nil {
+		t.Fatalf("save preferences: %v", err)
+	}
+	if err := repo.SavePreferences(ctx, "user:alex", `{"default_range":"6h"}`); err != nil {
+		t.Fatalf("overwrite preferences: %v", err)
+	}
+	got, _, err := repo.GetPreferences(ctx, "user:alex")
+	if err != nil {
+		t.Fatalf("get preferences: %v", err)
+	}
+	if got != `{"default_range":"6h"}` {
+		t.Fatalf("preferences = %s, want overwritten value", got)
+	}
+}
+
 func newTestRepo(t *testing.T) *Repository {
 	t.Helper()
 	sqldb, err := Open(t.TempDir() + "/test.db")
@@ -87,3 +106,181 @@ func seedContainer(t *testing.T, repo *Repository, ctx context.Context, serviceI
 		t.Fatalf("seed container %s: %v", containerID, err)
 	}
 }
+
+func TestInsertMetricsBatch(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+	seedContainer(t, repo, ctx, "svc-a", "c1", now)
+
+	hosts := []models.HostMetric{{TS: now.Add(-time.Minute), CPUPct: 10}, {TS: now, CPUPct: 20}}
+	containers := []models.ContainerMetric{{TS: now, ContainerID: "c1", CPUPct: 5}}
+	if err := repo.InsertMetricsBatch(ctx, hosts, containers); err != nil {
+		t.Fatalf("insert batch: %v", err)
+	}
+	got, err := repo.RecentHostMetrics(ctx, now.Add(-time.Hour), 10)
+	if err != nil {
+		t.Fatalf("recent host metrics: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("host metrics len = %d, want 2", len(got))
+	}
+	cm, err := repo.RecentContainerMetrics(ctx, "c1", now.Add(-time.Hour), 10)
+	if err != nil {
+		t.Fatalf("recent container metrics: %v", err)
+	}
+	if len(cm) != 1 || cm[0].CPUPct != 5 {
+		t.Fatalf("unexpected container metrics: %+v", cm)
+	}
+}
+
+func TestInsertLogsCollapsedCountsRepeats(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+	seedContainer(t, repo, ctx, "svc-a", "c1", now)
+
+	line := func(offset time.Duration, msg string) models.LogEntry {
+		return models.LogEntry{TS: now.Add(offset), ServiceID: "svc-a", ContainerID: "c1", Level: "ERROR", Stream: "stderr", Message: msg}
+	}
+	if err := repo.InsertLogsCollapsed(ctx, []models.LogEntry{
+		line(0, "db unreachable"),
+		line(time.Second, "db unreachable"),
+	}, time.Minute); err != nil {
+		t.Fatalf("insert first batch: %v", err)
+	}
+	// A later batch keeps collapsing into the stored row until the line
+	// changes or the window is exceeded.
+	if err := repo.InsertLogsCollapsed(ctx, []models.LogEntry{
+		line(2*time.Second, "db unreachable"),
+		line(3*time.Second, "reconnected"),
+		line(4*time.Second, "db unreachable"),
+		line(10*time.Minute, "db unreachable"),
+	}, time.Minute); err != nil {
+		t.Fatalf("insert second batch: %v", err)
+	}
+
+	entries, err := repo.QueryLogs(ctx, LogQuery{ServiceID: "svc-a", Limit: 10})
+	if err != nil {
+		t.Fatalf("query logs: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("entries len = %d, want 4", len(entries))
+	}
+	first := entries[len(entries)-1]
+	if first.RepeatCount != 3 || first.LastSeen == nil || !first.LastSeen.Equal(now.Add(2*time.Second)) {
+		t.Fatalf("collapsed row = %+v, want 3 repeats last seen at +2s", first)
+	}
+	if entries[0].RepeatCount != 1 || entries[0].LastSeen != nil {
+		t.Fatalf("row after window = %+v, want a fresh row", entries[0])
+	}
+}
+
+func TestMigrateLegacySettings(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	for k, v := range map[string]string{"telegram_token": "abc", "retention_logs_days": "30", "retention_alerts_days": "x"} {
+		if _, err := repo.db.Exec(`INSERT INTO settings(key,value) VALUES (?,?)`, k, v); err != nil {
+			t.Fatalf("seed %s: %v", k, err)
+		}
+	}
+	if err := Migrate(repo.db); err != nil {
+		t.Fatalf("re-migrate: %v", err)
+	}
+	all, err := repo.ListSettings(ctx, "")
+	if err != nil {
+		t.Fatalf("list settings: %v", err)
+	}
+	want := map[string]string{"telegram.token": `"abc"`, "retention.logs_days": "30"}
+	if len(all) != len(want) {
+		t.Fatalf("settings = %v, want %v", all, want)
+	}
+	for k, v := range want {
+		if all[k] != v {
+			t.Fatalf("%s = %q, want %q", k, all[k], v)
+		}
+	}
+}
+
+func TestImportConfigUpsertsRulesByName(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	err := repo.ImportConfig(ctx, ConfigImport{
+		Rules: []models.AlertRule{
+			{Name: "Host CPU high", TargetType: "host", MetricKey: "host_cpu_pct", Operator: ">", Threshold: 75, ForSeconds: 60, CooldownSeconds: 300},
+			{Name: "Prod memory", TargetType: "container", MetricKey: "container_mem_pct", Operator: ">", Threshold: 80, Enabled: true, LabelSelector: "env=prod"},
+		},
+		Settings:    map[string]string{"telegram.chat_id": `"42"`},
+		Preferences: map[string]string{"user:alice": `{"default_range":"6h"}`},
+	})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	rules, err := repo.ListRules(ctx)
+	if err != nil {
+		t.Fatalf("list rules: %v", err)
+	}
+	byName := map[string]models.AlertRule{}
+	for _, r := range rules {
+		byName[r.Name] = r
+	}
+	if len(byName) != len(rules) {
+		t.Fatalf("duplicate rule names after import: %+v", rules)
+	}
+	if cpu := byName["Host CPU high"]; cpu.Threshold != 75 || cpu.Enabled {
+		t.Fatalf("existing rule not updated: %+v", cpu)
+	}
+	if mem, ok := byName["Prod memory"]; !ok || mem.LabelSelector != "env=prod" {
+		t.Fatalf("new rule not inserted: %+v", mem)
+	}
+	if v, err := repo.GetSetting(ctx, "telegram.chat_id"); err != nil || v != `"42"` {
+		t.Fatalf("setting = %q, %v", v, err)
+	}
+	prefs, err := repo.ListPreferences(ctx)
+	if err != nil || prefs["user:alice"] != `{"default_range":"6h"}` {
+		t.Fatalf("preferences = %v, %v", prefs, err)
+	}
+}
+
+func TestQueryAndGroupLogsByField(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+	seedContainer(t, repo, ctx, "svc", "c1", now)
+
+	err := repo.InsertLogs(ctx, []models.LogEntry{
+		{TS: now, ServiceID: "svc", ContainerID: "c1", Level: "ERROR", Stream: "stdout", Message: "GET /a 500", Fields: map[string]string{"path": "/a", "status": "500"}},
+		{TS: now, ServiceID: "svc", ContainerID: "c1", Level: "INFO", Stream: "stdout", Message: "GET /a 200", Fields: map[string]string{"path": "/a", "status": "200"}},
+		{TS: now, ServiceID: "svc", ContainerID: "c1", Level: "ERROR", Stream: "stdout", Message: "GET /b 500", Fields: map[string]string{"path": "/b", "status": "500"}},
+		{TS: now, ServiceID: "svc", ContainerID: "c1", Level: "INFO", Stream: "stdout", Message: "starting"},
+	})
+	if err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+
+	entries, err := repo.QueryLogs(ctx, LogQuery{Fields: map[string]string{"status": "500", "path": "/a"}})
+	if err != nil {
+		t.Fatalf("query logs: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Message != "GET /a 500" || entries[0].Fields["status"] != "500" {
+		t.Fatalf("entries = %+v", entries)
+	}
+
+	groups, err := repo.GroupLogs(ctx, "field.path", LogQuery{Fields: map[string]string{"status": "500"}})
+	if err != nil {
+		t.Fatalf("group logs: %v", err)
+	}
+	if len(groups) != 2 || groups[0]["key"] != "/a" || groups[0]["count"] != int64(1) {
+		t.Fatalf("groups = %#v", groups)
+	}
+	groups, err = repo.GroupLogs(ctx, "field.status", LogQuery{})
+	if err != nil {
+		t.Fatalf("group logs: %v", err)
+	}
+	if len(groups) != 2 || groups[0]["key"] != "500" || groups[0]["count"] != int64(2) {
+		t.Fatalf("groups = %#v, want entries without the field left out", groups)
+	}
+	if _, err := repo.GroupLogs(ctx, "field.bad-name", LogQuery{}); err == nil {
+		t.Fatal("invalid field name accepted")
+	}
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func (r *Repository) InsertRestartEvent(ctx context.Context, ev models.RestartEvent) error {
+	_, err := r.exec(ctx, `INSERT INTO restart_events (container_id,service_id,host,name,reason,prev_container_id,exit_code,restart_count,finished_at,started_at,detected_at)
+		VALUES (?,?,?,?,?,?,?,?,?,?,?)`,
+		ev.ContainerID, ev.ServiceID, hostOrLocal(ev.Host), ev.Name, ev.Reason, ev.PrevContainerID, ev.ExitCode, ev.RestartCount, ev.FinishedAt, ev.StartedAt, ev.DetectedAt.UTC())
+	return err
+}
+
+// RestartEvents returns up to limit restarts detected between from and to,
+// of one service and one host when set, newest first.
+func (r *Repository) RestartEvents(ctx context.Context, from, to time.Time, serviceID, host string, limit int) ([]models.RestartEvent, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	filter := ""
+	args := []any{from.UTC(), to.UTC()}
+	if serviceID != "" {
+		filter += " AND service_id=?"
+		args = append(args, serviceID)
+	}
+	if host != "" {
+		filter += " AND host=?"
+		args = append(args, host)
+	}
+	rows, err := r.query(ctx, `SELECT id,container_id,service_id,host,name,reason,prev_container_id,exit_code,restart_count,finished_at,started_at,detected_at
+		FROM restart_events WHERE detected_at >= ? AND detected_at <= ?`+filter+` ORDER BY detected_at DESC, id DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.RestartEvent
+	for rows.Next() {
+		var ev models.RestartEvent
+		var exitCode sql.NullInt64
+		var finished, started sql.NullTime
+		if err := rows.Scan(&ev.ID, &ev.ContainerID, &ev.ServiceID, &ev.Host, &ev.Name, &ev.Reason, &ev.PrevContainerID, &exitCode, &ev.RestartCount,
+			&finished, &started, &ev.DetectedAt); err != nil {
+			return nil, err
+		}
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			ev.ExitCode = &code
+		}
+		if finished.Valid {
+			ev.FinishedAt = &finished.Time
+		}
+		if started.Valid {
+			ev.StartedAt = &started.Time
+		}
+		out = append(out, ev)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repository) DeleteRestartEventsOlderThan(ctx context.Context, cutoff time.Time) error {
+	_, err := r.exec(ctx, `DELETE FROM restart_events WHERE detected_at < ?`, cutoff.UTC())
+	return err
+}
+
+// ReplacementCandidates returns the containers of a service on host, other
+// than id, seen since and not replaced yet, most recently seen first: those
+// a new container of the service may have taken over from.
+func (r *Repository) ReplacementCandidates(ctx context.Context, serviceID, host, id string, since time.Time) ([]models.Container, error) {
+	rows, err := r.query(ctx, `SELECT `+containerColumns+` FROM containers
+		WHERE service_id=? AND host=? AND id<>? AND last_seen_at >= ?
+			AND NOT EXISTS (SELECT 1 FROM restart_events e WHERE e.prev_container_id=containers.id)
+		ORDER BY last_seen_at DESC`, serviceID, hostOrLocal(host), id, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanContainers(rows)
+}
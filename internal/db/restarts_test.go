@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestRestartEvents(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	started := now.Add(-time.Hour)
+	for _, c := range []models.Container{{ID: "a", ServiceID: "web", Name: "web-1"}, {ID: "b", ServiceID: "web", Name: "web-2"}, {ID: "c", ServiceID: "db", Name: "db"}} {
+		svc := models.Service{ID: c.ServiceID, Name: c.ServiceID, LabelsJSON: "{}", Status: "running"}
+		c.Status, c.StartedAt = "running", &started
+		if err := repo.UpsertServiceAndContainer(ctx, svc, c); err != nil {
+			t.Fatalf("store container: %v", err)
+		}
+	}
+	// A later start replaces the stored one.
+	restarted := now.Add(-time.Minute).Truncate(time.Second)
+	if err := repo.UpsertServiceAndContainer(ctx, models.Service{ID: "web", Name: "web", LabelsJSON: "{}", Status: "running"},
+		models.Container{ID: "a", ServiceID: "web", Name: "web-1", Status: "running", StartedAt: &restarted}); err != nil {
+		t.Fatalf("store container: %v", err)
+	}
+	if c, err := repo.Container(ctx, "a"); err != nil || !c.StartedAt.Equal(restarted) {
+		t.Fatalf("container = %+v, %v, want started at %s", c, err, restarted)
+	}
+
+	code := 137
+	for _, ev := range []models.RestartEvent{
+		{ContainerID: "a", ServiceID: "web", Name: "web-1", Reason: "oom", ExitCode: &code, RestartCount: 1, DetectedAt: now.Add(-2 * time.Hour)},
+		{ContainerID: "b", ServiceID: "web", Name: "web-2", Reason: "replaced", PrevContainerID: "old", StartedAt: &restarted, DetectedAt: now.Add(-time.Minute)},
+		{ContainerID: "c", ServiceID: "db", Name: "db", Reason: "restart", DetectedAt: now},
+	} {
+		if err := repo.InsertRestartEvent(ctx, ev); err != nil {
+			t.Fatalf("insert restart event: %v", err)
+		}
+	}
+	events, err := repo.RestartEvents(ctx, now.Add(-3*time.Hour), now, "web", "local", 10)
+	if err != nil {
+		t.Fatalf("restart events: %v", err)
+	}
+	if len(events) != 2 || events[0].ContainerID != "b" || events[0].ExitCode != nil || !events[0].StartedAt.Equal(restarted) ||
+		events[1].Reason != "oom" || *events[1].ExitCode != 137 || events[1].StartedAt != nil {
+		t.Fatalf("events = %+v", events)
+	}
+
+	// b replaced a container that no longer exists; a is yet to be replaced.
+	if err := repo.InsertRestartEvent(ctx, models.RestartEvent{ContainerID: "c", ServiceID: "db", Reason: "replaced", PrevContainerID: "b", DetectedAt: now}); err != nil {
+		t.Fatalf("insert restart event: %v", err)
+	}
+	candidates, err := repo.ReplacementCandidates(ctx, "web", "", "new", now.Add(-time.Hour))
+	if err != nil || len(candidates) != 1 || candidates[0].ID != "a" {
+		t.Fatalf("replacement candidates = %+v, %v", candidates, err)
+	}
+
+	if err := repo.DeleteRestartEventsOlderThan(ctx, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("delete restart events: %v", err)
+	}
+	if events, _ := repo.RestartEvents(ctx, now.Add(-3*time.Hour), now, "", "", 10); len(events) != 3 {
+		t.Fatalf("events after retention = %+v", events)
+	}
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// InsertScrapeSamples stores the samples of one scrape.
+func (r *Repository) InsertScrapeSamples(ctx context.Context, samples []models.ScrapeSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, r.dialect.Rebind(`INSERT INTO scrape_samples (ts,target,metric,labels,value) VALUES (?,?,?,?,?)`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, s := range samples {
+		// Labels are stored as a JSON object with sorted keys, which
+		// identifies the series.
+		labels := []byte("{}")
+		if len(s.Labels) > 0 {
+			if labels, err = json.Marshal(s.Labels); err != nil {
+				return err
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, s.TS.UTC(), s.Target, s.Metric, string(labels), s.Value); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ScrapeSeries returns the samples of metric scraped from target since
+// since, oldest first.
+func (r *Repository) ScrapeSeries(ctx context.Context, target, metric string, since time.Time) ([]models.ScrapeSample, error) {
+	return r.scrapeSamples(ctx, `SELECT ts,target,metric,labels,value FROM scrape_samples
+		WHERE target=? AND metric=? AND ts>=? ORDER BY ts`, target, metric, since.UTC())
+}
+
+// LatestScrapeSamples returns the newest sample of every series scraped
+// since since.
+func (r *Repository) LatestScrapeSamples(ctx context.Context, since time.Time) ([]models.ScrapeSample, error) {
+	return r.scrapeSamples(ctx, `SELECT s.ts,s.target,s.metric,s.labels,s.value FROM scrape_samples s
+		JOIN (SELECT target,metric,labels,MAX(ts) AS ts FROM scrape_samples WHERE ts>=? GROUP BY target,metric,labels) l
+		ON l.target=s.target AND l.metric=s.metric AND l.labels=s.labels AND l.ts=s.ts
+		ORDER BY s.target,s.metric,s.labels`, since.UTC())
+}
+
+func (r *Repository) scrapeSamples(ctx context.Context, query string, args ...any) ([]models.ScrapeSample, error) {
+	rows, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.ScrapeSample
+	for rows.Next() {
+		var s models.ScrapeSample
+		var labels string
+		if err := rows.Scan(&s.TS, &s.Target, &s.Metric, &labels, &s.Value); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(labels), &s.Labels); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// serviceContainer picks the container a service's hits lead to: its most
+// recently seen one.
+const serviceContainer = `COALESCE((SELECT c.id FROM containers c WHERE c.service_id=s.id AND c.status!='archived' ORDER BY c.last_seen_at DESC LIMIT 1),'')`
+
+// Search returns up to limit hits of each kind for q, matched without
+// regard to case: services by ID or name, their images and labels,
+// containers by ID prefix or name, the summaries of alerts started since
+// alertsSince and log messages since logsSince, all as substrings. A zero time skips alerts
+// or logs; archived containers are left out.
+func (r *Repository) Search(ctx context.Context, q string, alertsSince, logsSince time.Time, limit int) ([]models.SearchHit, error) {
+	lower := strings.ToLower(q)
+	like := "%" + lower + "%"
+	var out []models.SearchHit
+	rows, err := r.query(ctx, `SELECT s.id,s.name,s.image,`+serviceContainer+` FROM services s
+		WHERE LOWER(s.id) LIKE ? OR LOWER(s.name) LIKE ? OR LOWER(s.image) LIKE ? ORDER BY s.name LIMIT ?`, like, like, like, limit)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id, name, image, container string
+		if err := rows.Scan(&id, &name, &image, &container); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if strings.Contains(strings.ToLower(id+"\n"+name), lower) {
+			out = append(out, models.SearchHit{Kind: "service", ID: id, Name: name, Text: name, ServiceID: id, ContainerID: container})
+		}
+		if strings.Contains(strings.ToLower(image), lower) {
+			out = append(out, models.SearchHit{Kind: "image", ID: id, Name: name, Text: image, ServiceID: id, ContainerID: container})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.query(ctx, `SELECT c.id,c.name,c.service_id FROM containers c
+		WHERE c.status!='archived' AND (LOWER(c.id) LIKE ? OR LOWER(c.name) LIKE ?) ORDER BY c.name LIMIT ?`, lower+"%", like, limit)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var h models.SearchHit
+		if err := rows.Scan(&h.ID, &h.Name, &h.ServiceID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		h.Kind, h.Text, h.ContainerID = "container", h.Name, h.ID
+		out = append(out, h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.query(ctx, `SELECT s.id,s.name,l.key,l.value,`+serviceContainer+` FROM labels l JOIN services s ON s.id=l.service_id
+		WHERE LOWER(l.key) LIKE ? OR LOWER(l.value) LIKE ? ORDER BY s.name, l.key LIMIT ?`, like, like, limit)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var h models.SearchHit
+		var key, value string
+		if err := rows.Scan(&h.ServiceID, &h.Name, &key, &value, &h.ContainerID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		h.Kind, h.ID, h.Text = "label", h.ServiceID, key+"="+value
+		out = append(out, h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if !alertsSince.IsZero() {
+		rows, err = r.query(ctx, `SELECT a.id,r.name,a.summary,a.started_ts,COALESCE(c.id,'') FROM alerts a
+			JOIN alert_rules r ON r.id=a.rule_id LEFT JOIN containers c ON c.id=a.target_fingerprint
+			WHERE a.started_ts >= ? AND LOWER(a.summary) LIKE ? ORDER BY a.started_ts DESC LIMIT ?`, alertsSince.UTC(), like, limit)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var h models.SearchHit
+			var id int64
+			if err := rows.Scan(&id, &h.Name, &h.Text, &h.TS, &h.ContainerID); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			h.Kind, h.ID = "alert", strconv.FormatInt(id, 10)
+			out = append(out, h)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if !logsSince.IsZero() {
+		entries, err := r.QueryLogs(ctx, LogQuery{Contains: q, From: &logsSince, Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			out = append(out, models.SearchHit{Kind: "log", ID: e.ContainerID, Name: e.ServiceID, Text: e.Message, ServiceID: e.ServiceID,
+				ContainerID: e.ContainerID, TS: e.TS})
+		}
+	}
+	return out, nil
+}
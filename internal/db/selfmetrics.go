@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// queryStats counts the repository's statements and the time they took
+// until their first row, for the self metrics.
+type queryStats struct {
+	n     atomic.Int64
+	nanos atomic.Int64
+}
+
+func (q *queryStats) observe(start time.Time) {
+	q.n.Add(1)
+	q.nanos.Add(int64(time.Since(start)))
+}
+
+// QueryStats returns the number of statements run since the repository
+// was opened and their total time.
+func (r *Repository) QueryStats() (int64, time.Duration) {
+	return r.queries.n.Load(), time.Duration(r.queries.nanos.Load())
+}
+
+// Size returns the bytes the database takes: every SQLite file with its
+// WAL, or the Postgres database.
+func (r *Repository) Size(ctx context.Context) (int64, error) {
+	if r.dialect == Postgres {
+		var n int64
+		err := r.db.QueryRowContext(ctx, `SELECT pg_database_size(current_database())`).Scan(&n)
+		return n, err
+	}
+	files, err := r.StorageFiles(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, f := range files {
+		n += f.Pages*f.PageSize + f.WALBytes
+	}
+	return n, nil
+}
+
+const selfMetricColumns = `ts,log_lines_rate,log_dropped_rate,log_write_errors,log_workers,db_size_bytes,query_rate,query_avg_ms,goroutines,heap_bytes,notify_failures,panics,log_overflow`
+
+func (r *Repository) InsertSelfMetric(ctx context.Context, m models.SelfMetric) error {
+	_, err := r.exec(ctx, `INSERT INTO self_metrics(`+selfMetricColumns+`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		m.TS.UTC(), m.LogLinesRate, m.LogDroppedRate, m.LogWriteErrors, m.LogWorkers, m.DBSizeBytes, m.QueryRate, m.QueryAvgMS, m.Goroutines, m.HeapBytes, m.NotifyFailures, m.Panics, m.LogOverflow)
+	return err
+}
+
+// RecentSelfMetrics returns the self metric samples since from, oldest first.
+func (r *Repository) RecentSelfMetrics(ctx context.Context, from time.Time, limit int) ([]models.SelfMetric, error) {
+	rows, err := r.query(ctx, `SELECT `+selfMetricColumns+` FROM self_metrics WHERE ts >= ? ORDER BY ts ASC LIMIT ?`, from.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.SelfMetric
+	for rows.Next() {
+		m, err := scanSelfMetric(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// LatestSelfMetric returns the newest self metric sample; ok is false
+// before the first one was stored.
+func (r *Repository) LatestSelfMetric(ctx context.Context) (models.SelfMetric, bool, error) {
+	m, err := scanSelfMetric(r.queryRow(ctx, `SELECT `+selfMetricColumns+` FROM self_metrics ORDER BY ts DESC LIMIT 1`))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.SelfMetric{}, false, nil
+	}
+	return m, err == nil, err
+}
+
+func scanSelfMetric(sc interface{ Scan(...any) error }) (models.SelfMetric, error) {
+	var m models.SelfMetric
+	err := sc.Scan(&m.TS, &m.LogLinesRate, &m.LogDroppedRate, &m.LogWriteErrors, &m.LogWorkers, &m.DBSizeBytes, &m.QueryRate, &m.QueryAvgMS, &m.Goroutines, &m.HeapBytes, &m.NotifyFailures, &m.Panics, &m.LogOverflow)
+	return m, err
+}
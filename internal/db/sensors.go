@@ -0,0 +1,35 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func (r *Repository) insertTemperatures(ctx context.Context, ex execer, temps []models.Temperature) error {
+	for _, t := range temps {
+		if _, err := ex.ExecContext(ctx, r.dialect.Rebind(`INSERT INTO temperatures (ts,sensor,temp_c) VALUES (?,?,?)`), t.TS.UTC(), t.Sensor, t.TempC); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecentTemperatures returns sensor readings since from, oldest first.
+func (r *Repository) RecentTemperatures(ctx context.Context, from time.Time, limit int) ([]models.Temperature, error) {
+	rows, err := r.query(ctx, `SELECT ts,sensor,temp_c FROM temperatures WHERE ts >= ? ORDER BY ts ASC, sensor ASC LIMIT ?`, from.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.Temperature
+	for rows.Next() {
+		var t models.Temperature
+		if err := rows.Scan(&t.TS, &t.Sensor, &t.TempC); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
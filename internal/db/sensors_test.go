@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestTemperaturesStoredWithHostMetric(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+
+	hm := models.HostMetric{TS: now, CPUTempC: 71, NVMeTempC: 44, Temperatures: []models.Temperature{
+		{TS: now, Sensor: "coretemp/Package id 0", TempC: 71},
+		{TS: now, Sensor: "nvme/Composite", TempC: 44},
+	}}
+	if err := repo.InsertHostMetric(ctx, hm); err != nil {
+		t.Fatalf("insert host metric: %v", err)
+	}
+	latest, err := repo.LatestHostMetric(ctx)
+	if err != nil {
+		t.Fatalf("latest host metric: %v", err)
+	}
+	if latest.CPUTempC != 71 || latest.NVMeTempC != 44 {
+		t.Fatalf("latest = %+v", latest)
+	}
+	temps, err := repo.RecentTemperatures(ctx, now.Add(-time.Hour), 10)
+	if err != nil {
+		t.Fatalf("recent temperatures: %v", err)
+	}
+	if len(temps) != 2 || temps[0].Sensor != "coretemp/Package id 0" || temps[1].TempC != 44 {
+		t.Fatalf("temperatures = %+v", temps)
+	}
+}
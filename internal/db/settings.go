@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Settings hold JSON values under namespaced keys ("telegram.token").
+// Typed access, validation and change hooks live in internal/settings.
+
+func (r *Repository) GetSetting(ctx context.Context, key string) (string, error) {
+	var v string
+	err := r.queryRow(ctx, `SELECT value FROM settings WHERE key=?`, key).Scan(&v)
+	return v, err
+}
+
+// ListSettings returns all settings whose key starts with prefix.
+func (r *Repository) ListSettings(ctx context.Context, prefix string) (map[string]string, error) {
+	rows, err := r.query(ctx, `SELECT key,value FROM settings WHERE substr(key,1,?)=? ORDER BY key`, len(prefix), prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]string{}
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, err
+		}
+		out[k] = v
+	}
+	return out, rows.Err()
+}
+
+func (r *Repository) SetSetting(ctx context.Context, key, value string) error {
+	_, err := r.exec(ctx, `INSERT INTO settings(key,value) VALUES (?,?) ON CONFLICT(key) DO UPDATE SET value=excluded.value`, key, value)
+	return err
+}
+
+func (r *Repository) DeleteSetting(ctx context.Context, key string) error {
+	_, err := r.exec(ctx, `DELETE FROM settings WHERE key=?`, key)
+	return err
+}
+
+// legacySettings maps pre-namespace keys, which stored bare strings, to
+// their current names.
+var legacySettings = []struct {
+	old, key string
+	number   bool
+}{
+	{"telegram_token", "telegram.token", false},
+	{"telegram_chat_id", "telegram.chat_id", false},
+	{"retention_logs_days", "retention.logs_days", true},
+	{"retention_metrics_days", "retention.metrics_days", true},
+	{"retention_rollups_days", "retention.rollups_days", true},
+	{"retention_alerts_days", "retention.alerts_days", true},
+}
+
+func migrateLegacySettings(db *sql.DB, d Dialect) error {
+	for _, l := range legacySettings {
+		var v string
+		err := db.QueryRow(d.Rebind(`SELECT value FROM settings WHERE key=?`), l.old).Scan(&v)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if value, ok := legacyValue(v, l.number); ok {
+			if _, err := db.Exec(d.Rebind(`INSERT INTO settings(key,value) VALUES (?,?) ON CONFLICT(key) DO NOTHING`), l.key, value); err != nil {
+				return fmt.Errorf("migrate setting %s: %w", l.old, err)
+			}
+		}
+		if _, err := db.Exec(d.Rebind(`DELETE FROM settings WHERE key=?`), l.old); err != nil {
+			return fmt.Errorf("migrate setting %s: %w", l.old, err)
+		}
+	}
+	return nil
+}
+
+// legacyValue encodes a legacy bare value as JSON. Unparseable numbers are
+// dropped so the defaults apply.
+func legacyValue(v string, number bool) (string, bool) {
+	if !number {
+		b, _ := json.Marshal(v)
+		return string(b), true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return "", false
+	}
+	return strconv.Itoa(n), true
+}
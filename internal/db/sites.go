@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"dashi/internal/models"
+)
+
+// SaveSite records a poll of site s. A failed poll, one with an Error,
+// keeps the previous summary and LastOKAt.
+func (r *Repository) SaveSite(ctx context.Context, s models.Site) error {
+	var lastOK any
+	if s.LastOKAt != nil {
+		lastOK = s.LastOKAt.UTC()
+	}
+	_, err := r.exec(ctx, `INSERT INTO sites(name,url,checked_at,last_ok_at,error,summary_json) VALUES (?,?,?,?,?,?)
+		ON CONFLICT(name) DO UPDATE SET url=excluded.url,checked_at=excluded.checked_at,error=excluded.error,
+			last_ok_at=CASE WHEN excluded.error='' THEN excluded.last_ok_at ELSE sites.last_ok_at END,
+			summary_json=CASE WHEN excluded.error='' THEN excluded.summary_json ELSE sites.summary_json END`,
+		s.Name, s.URL, s.CheckedAt.UTC(), lastOK, s.Error, s.SummaryJSON)
+	return err
+}
+
+// ListSites returns the polled sites by name.
+func (r *Repository) ListSites(ctx context.Context) ([]models.Site, error) {
+	rows, err := r.query(ctx, `SELECT name,url,checked_at,last_ok_at,error,summary_json FROM sites ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.Site
+	for rows.Next() {
+		var s models.Site
+		var lastOK sql.NullTime
+		if err := rows.Scan(&s.Name, &s.URL, &s.CheckedAt, &lastOK, &s.Error, &s.SummaryJSON); err != nil {
+			return nil, err
+		}
+		if lastOK.Valid {
+			s.LastOKAt = &lastOK.Time
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// DeleteSitesExcept forgets the sites not named in keep, e.g. after they
+// were removed from the configuration.
+func (r *Repository) DeleteSitesExcept(ctx context.Context, keep []string) error {
+	if len(keep) == 0 {
+		_, err := r.exec(ctx, `DELETE FROM sites`)
+		return err
+	}
+	args := make([]any, len(keep))
+	for i, name := range keep {
+		args[i] = name
+	}
+	_, err := r.exec(ctx, `DELETE FROM sites WHERE name NOT IN (`+strings.Repeat("?,", len(keep)-1)+`?)`, args...)
+	return err
+}
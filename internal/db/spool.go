@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// The spool queries read the SQLite database of an agent, which keeps what
+// it collected until the server accepted it. Rows are returned in insertion
+// order with their rowid, the cursor to pass as after for the next batch.
+
+// SpooledContainerMetrics returns up to limit container samples stored
+// after rowid after.
+func (r *Repository) SpooledContainerMetrics(ctx context.Context, after int64, limit int) ([]models.ContainerMetric, int64, error) {
+	rows, err := r.query(ctx, `SELECT rowid,ts,container_id,cpu_pct,mem_used_bytes,mem_limit_bytes,net_rx_bytes,net_tx_bytes,blk_read_bytes,blk_write_bytes,
+		net_rx_rate,net_tx_rate,blk_read_rate,blk_write_rate,pids,pids_limit,fds,fd_limit
+		FROM container_metrics WHERE rowid > ? ORDER BY rowid LIMIT ?`, after, limit)
+	if err != nil {
+		return nil, after, err
+	}
+	defer rows.Close()
+	var out []models.ContainerMetric
+	last := after
+	for rows.Next() {
+		var m models.ContainerMetric
+		if err := rows.Scan(&last, &m.TS, &m.ContainerID, &m.CPUPct, &m.MemUsedBytes, &m.MemLimitBytes, &m.NetRXBytes, &m.NetTXBytes, &m.BlkReadBytes, &m.BlkWriteBytes,
+			&m.NetRXRate, &m.NetTXRate, &m.BlkReadRate, &m.BlkWriteRate, &m.Pids, &m.PidsLimit, &m.FDs, &m.FDLimit); err != nil {
+			return nil, after, err
+		}
+		out = append(out, m)
+	}
+	return out, last, rows.Err()
+}
+
+// SpooledLogs returns up to limit log entries stored after id after.
+func (r *Repository) SpooledLogs(ctx context.Context, after int64, limit int) ([]models.LogEntry, int64, error) {
+	rows, err := r.query(ctx, `SELECT id,ts,service_id,container_id,level,stream,message,fields FROM logs WHERE id > ? ORDER BY id LIMIT ?`, after, limit)
+	if err != nil {
+		return nil, after, err
+	}
+	defer rows.Close()
+	var out []models.LogEntry
+	last := after
+	for rows.Next() {
+		var e models.LogEntry
+		var fields sql.NullString
+		if err := rows.Scan(&last, &e.TS, &e.ServiceID, &e.ContainerID, &e.Level, &e.Stream, &e.Message, &fields); err != nil {
+			return nil, after, err
+		}
+		e.Fields = scanFields(fields)
+		out = append(out, e)
+	}
+	return out, last, rows.Err()
+}
+
+// TrimSpool deletes the container samples and log entries up to the given
+// cursors, which the server has accepted, and any older than cutoff, which
+// it never will.
+func (r *Repository) TrimSpool(ctx context.Context, metricsUpTo, logsUpTo int64, cutoff time.Time) error {
+	if _, err := r.exec(ctx, `DELETE FROM container_metrics WHERE rowid <= ? OR ts < ?`, metricsUpTo, cutoff.UTC()); err != nil {
+		return err
+	}
+	_, err := r.exec(ctx, `DELETE FROM logs WHERE id <= ? OR ts < ?`, logsUpTo, cutoff.UTC())
+	return err
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// InsertDiskUsage stores a volume sample for every volume of host and
+// replaces the host's image sizes.
+func (r *Repository) InsertDiskUsage(ctx context.Context, host string, ts time.Time, volumes []models.VolumeUsage, images []models.ImageUsage) error {
+	host = hostOrLocal(host)
+	ts = ts.UTC()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	rb := r.dialect.Rebind
+	for _, v := range volumes {
+		if _, err := tx.ExecContext(ctx, rb(`INSERT INTO volume_usage(ts,host,name,driver,size_bytes,ref_count) VALUES (?,?,?,?,?,?)`),
+			ts, host, v.Name, v.Driver, v.SizeBytes, v.RefCount); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, rb(`DELETE FROM image_usage WHERE host=?`), host); err != nil {
+		return err
+	}
+	for _, img := range images {
+		if _, err := tx.ExecContext(ctx, rb(`INSERT INTO image_usage(host,id,tags,size_bytes,shared_bytes,containers,updated_at) VALUES (?,?,?,?,?,?,?)`),
+			host, img.ID, strings.Join(img.Tags, ","), img.SizeBytes, img.SharedBytes, img.Containers, ts); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListVolumeUsage returns the volumes of each host's latest sample, largest
+// first, with their growth since the oldest sample at or after since.
+func (r *Repository) ListVolumeUsage(ctx context.Context, since time.Time) ([]models.VolumeUsage, error) {
+	rows, err := r.query(ctx, `SELECT v.ts,v.host,v.name,v.driver,v.size_bytes,v.ref_count,
+		v.size_bytes - COALESCE((SELECT o.size_bytes FROM volume_usage o WHERE o.host=v.host AND o.name=v.name AND o.ts >= ? ORDER BY o.ts LIMIT 1), v.size_bytes)
+		FROM volume_usage v
+		WHERE v.ts = (SELECT MAX(m.ts) FROM volume_usage m WHERE m.host=v.host)
+		ORDER BY v.size_bytes DESC, v.host, v.name`, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.VolumeUsage
+	for rows.Next() {
+		var v models.VolumeUsage
+		if err := rows.Scan(&v.TS, &v.Host, &v.Name, &v.Driver, &v.SizeBytes, &v.RefCount, &v.GrowthBytes); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// ListImageUsage returns the images of every host, largest first.
+func (r *Repository) ListImageUsage(ctx context.Context) ([]models.ImageUsage, error) {
+	rows, err := r.query(ctx, `SELECT host,id,tags,size_bytes,shared_bytes,containers,updated_at FROM image_usage ORDER BY size_bytes DESC, host, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.ImageUsage
+	for rows.Next() {
+		var img models.ImageUsage
+		var tags string
+		if err := rows.Scan(&img.Host, &img.ID, &tags, &img.SizeBytes, &img.SharedBytes, &img.Containers, &img.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if tags != "" {
+			img.Tags = strings.Split(tags, ",")
+		}
+		out = append(out, img)
+	}
+	return out, rows.Err()
+}
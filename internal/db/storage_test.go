@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestListVolumeUsageReportsGrowth(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+
+	samples := []struct {
+		at      time.Time
+		volumes []models.VolumeUsage
+	}{
+		{now.Add(-48 * time.Hour), []models.VolumeUsage{{Name: "db", SizeBytes: 10}, {Name: "gone", SizeBytes: 99}}},
+		{now.Add(-12 * time.Hour), []models.VolumeUsage{{Name: "db", SizeBytes: 100}}},
+		{now, []models.VolumeUsage{{Name: "db", SizeBytes: 250}, {Name: "cache", SizeBytes: 5}}},
+	}
+	for _, s := range samples {
+		if err := repo.InsertDiskUsage(ctx, "", s.at, s.volumes, nil); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	img := []models.ImageUsage{{ID: "sha256:1", Tags: []string{"nginx:1.27", "nginx:latest"}, SizeBytes: 42}}
+	if err := repo.InsertDiskUsage(ctx, "nas", now, nil, img); err != nil {
+		t.Fatalf("insert images: %v", err)
+	}
+
+	volumes, err := repo.ListVolumeUsage(ctx, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("list volumes: %v", err)
+	}
+	if len(volumes) != 2 || volumes[0].Name != "db" || volumes[0].GrowthBytes != 150 || volumes[1].GrowthBytes != 0 {
+		t.Fatalf("volumes = %+v", volumes)
+	}
+	images, err := repo.ListImageUsage(ctx)
+	if err != nil {
+		t.Fatalf("list images: %v", err)
+	}
+	if len(images) != 1 || images[0].Host != "nas" || len(images[0].Tags) != 2 {
+		t.Fatalf("images = %+v", images)
+	}
+}
@@ -0,0 +1,191 @@
+// Package demo stands in for Docker in demo mode. It runs a generated
+// fleet on the local host, storing container states, samples and log lines
+// as the collectors and log workers would, and now and then makes a
+// container unhealthy or restarts it, which the alert rules pick up like a
+// real incident.
+package demo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/logs"
+	"dashi/internal/models"
+	"dashi/internal/schedule"
+	"dashi/internal/supervise"
+	"dashi/internal/synth"
+)
+
+const (
+	// services is the size of the demo fleet.
+	services = 12
+	// history is how far back a fresh database is filled.
+	history       = 6 * time.Hour
+	logsPerMinute = 60
+)
+
+// Options configures a Service.
+type Options struct {
+	// Every is the time between samples, as APP_METRICS_INTERVAL.
+	Every time.Duration
+	// InventoryOnly stores the containers without samples, as with
+	// APP_METRICS_ENABLED=false.
+	InventoryOnly bool
+}
+
+// Service advances the demo fleet.
+type Service struct {
+	repo *db.Repository
+	// sink stores the log lines; nil when log ingestion is disabled.
+	sink  *logs.Sink
+	log   *slog.Logger
+	opts  Options
+	fleet *synth.Fleet
+	rnd   *rand.Rand
+
+	// troubled is the unhealthy container, if any, until recovers.
+	troubled *models.Container
+	recovers time.Time
+	// next is when the next incident starts.
+	next time.Time
+	// restarts are the restarts of the last incident, for the next Tick to
+	// record.
+	restarts []models.RestartEvent
+}
+
+// NewService creates the demo fleet. Its services and container IDs are
+// the same on every start, so a restart continues the stored history.
+func NewService(repo *db.Repository, sink *logs.Sink, logger *slog.Logger, opts Options) *Service {
+	if opts.Every <= 0 {
+		opts.Every = 10 * time.Second
+	}
+	now := time.Now().UTC()
+	return &Service{
+		repo:  repo,
+		sink:  sink,
+		log:   logger,
+		opts:  opts,
+		fleet: synth.NewFleet(synth.Options{Services: services, Seed: 1}, now),
+		rnd:   rand.New(rand.NewPCG(uint64(now.UnixNano()), 1)),
+		next:  now.Add(time.Minute),
+	}
+}
+
+// Run fills a fresh database with a few hours of history, then advances
+// the fleet every opts.Every until ctx is done.
+func (s *Service) Run(ctx context.Context) {
+	now := time.Now().UTC()
+	seeded, err := s.seed(ctx, now)
+	if err != nil {
+		s.log.Error("seed demo history", "err", err)
+	}
+	if !seeded {
+		s.Tick(ctx, now)
+	}
+	t := schedule.NewTicker(s.opts.Every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ts := <-t.C:
+			s.Tick(ctx, ts.UTC())
+		}
+	}
+}
+
+// seed writes the fleet's history unless its containers are stored
+// already, and reports whether it did.
+func (s *Service) seed(ctx context.Context, now time.Time) (bool, error) {
+	stored, err := s.repo.ListContainers(ctx)
+	if err != nil {
+		return false, fmt.Errorf("load containers: %w", err)
+	}
+	first := s.fleet.Stacks[0].Containers[0].ID
+	for _, c := range stored {
+		if c.ID == first {
+			return false, nil
+		}
+	}
+	if s.opts.InventoryOnly {
+		return false, nil
+	}
+	h := synth.History{Span: history, Interval: s.opts.Every}
+	if s.sink != nil {
+		h.LogsPerMinute = logsPerMinute
+	}
+	n, err := s.fleet.Seed(ctx, s.repo, h, now)
+	if err != nil {
+		return false, err
+	}
+	s.log.Info("seeded demo history", "containers", n.Containers, "samples", n.Samples, "logs", n.Logs)
+	return true, nil
+}
+
+// Tick stores the fleet's containers, a sample of each and the log lines
+// of the last interval at now.
+func (s *Service) Tick(ctx context.Context, now time.Time) {
+	defer supervise.Recover(s.log, "demo tick")
+	entries := s.incident(now)
+	for _, st := range s.fleet.Stacks {
+		for _, c := range st.Containers {
+			c.LastSeenAt = now
+			if err := s.repo.UpsertServiceAndContainer(ctx, st.Service, c); err != nil {
+				s.log.Error("store demo container", "container", c.Name, "err", err)
+				return
+			}
+		}
+	}
+	for _, ev := range s.restarts {
+		if err := s.repo.InsertRestartEvent(ctx, ev); err != nil {
+			s.log.Error("store demo restart", "container", ev.Name, "err", err)
+		}
+	}
+	s.restarts = nil
+	if !s.opts.InventoryOnly {
+		hm, cms := s.fleet.Sample(now, s.opts.Every)
+		if err := s.repo.InsertMetricsBatch(ctx, []models.HostMetric{hm}, cms); err != nil {
+			s.log.Error("store demo samples", "err", err)
+		}
+	}
+	if s.sink == nil {
+		return
+	}
+	n := int(float64(logsPerMinute) * s.opts.Every.Minutes())
+	for _, e := range append(s.fleet.Logs(now, s.opts.Every, n), entries...) {
+		s.sink.Write(e)
+	}
+}
+
+// incident ends the current incident when it is due and every few minutes
+// starts the next: a container restarts or fails its health check for a
+// few minutes. It returns the log lines the troubled container writes.
+func (s *Service) incident(now time.Time) []models.LogEntry {
+	if s.troubled != nil && !now.Before(s.recovers) {
+		s.troubled.Health = "healthy"
+		s.troubled = nil
+	}
+	if s.troubled == nil && !now.Before(s.next) {
+		s.next = now.Add(time.Duration(3+s.rnd.IntN(8)) * time.Minute)
+		st := s.fleet.Stacks[s.rnd.IntN(len(s.fleet.Stacks))]
+		c := &st.Containers[s.rnd.IntN(len(st.Containers))]
+		if s.rnd.IntN(2) == 0 {
+			c.RestartCount++
+			c.StartedAt = &now
+			s.restarts = append(s.restarts, models.RestartEvent{ContainerID: c.ID, ServiceID: c.ServiceID, Host: c.Host, Name: c.Name,
+				Reason: "restart", RestartCount: c.RestartCount, StartedAt: &now, DetectedAt: now})
+			return []models.LogEntry{{TS: now, ServiceID: c.ServiceID, ContainerID: c.ID, Level: "INFO", Stream: "stdout", Message: "received SIGTERM, shutting down"}}
+		}
+		c.Health = "unhealthy"
+		s.troubled, s.recovers = c, now.Add(time.Duration(1+s.rnd.IntN(4))*time.Minute)
+	}
+	if s.troubled == nil {
+		return nil
+	}
+	c := s.troubled
+	return []models.LogEntry{{TS: now, ServiceID: c.ServiceID, ContainerID: c.ID, Level: "ERROR", Stream: "stderr", Message: "health check failed: GET /health: context deadline exceeded"}}
+}
@@ -0,0 +1,77 @@
+package demo
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+)
+
+func TestServiceSeedsOnceAndRaisesIncidents(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/demo.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := NewService(repo, nil, logger, Options{Every: time.Minute})
+	now := time.Now().UTC()
+
+	if seeded, err := s.seed(ctx, now); err != nil || !seeded {
+		t.Fatalf("first seed = %v, err %v", seeded, err)
+	}
+	containers, err := repo.ListContainers(ctx)
+	if err != nil || len(containers) != services {
+		t.Fatalf("stored %d containers, err %v, want %d", len(containers), err, services)
+	}
+	// A restart finds its fleet and continues without a second history.
+	again := NewService(repo, nil, logger, Options{Every: time.Minute})
+	if seeded, err := again.seed(ctx, now); err != nil || seeded {
+		t.Fatalf("second seed = %v, err %v", seeded, err)
+	}
+
+	// Force an unhealthy container and let it recover.
+	for s.troubled == nil {
+		s.next = now
+		s.incident(now)
+	}
+	id := s.troubled.ID
+	next := now.Add(30 * time.Second)
+	s.Tick(ctx, next)
+	if h := health(t, repo, id); h != "unhealthy" {
+		t.Fatalf("troubled container is %q", h)
+	}
+	if m, err := repo.LatestHostMetric(ctx); err != nil || !m.TS.Equal(next) {
+		t.Fatalf("latest host sample = %v, err %v, want %v", m.TS, err, next)
+	}
+	// Keep the next incident from starting in the recovering Tick, which
+	// could pick the same container again.
+	s.next = s.recovers.Add(time.Hour)
+	s.Tick(ctx, s.recovers)
+	if h := health(t, repo, id); h != "healthy" {
+		t.Fatalf("recovered container is %q", h)
+	}
+}
+
+func health(t *testing.T, repo *db.Repository, id string) string {
+	t.Helper()
+	containers, err := repo.ListContainers(context.Background())
+	if err != nil {
+		t.Fatalf("list containers: %v", err)
+	}
+	for _, c := range containers {
+		if c.ID == id {
+			return c.Health
+		}
+	}
+	t.Fatalf("container %s not stored", id)
+	return ""
+}
@@ -0,0 +1,248 @@
+// Package deploy parses deployment webhooks of GitHub, GitLab and generic
+// CI jobs into the deployments dashi marks on charts and alert timelines.
+package deploy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxVersion     = 100
+	maxDescription = 500
+	maxURL         = 500
+)
+
+// Sources of deployments.
+const (
+	GitHub  = "github"
+	GitLab  = "gitlab"
+	Generic = "webhook"
+)
+
+// Event is a finished deployment.
+type Event struct {
+	// Service names the deployed service: the one the caller named, or
+	// else the repository or project name.
+	Service     string
+	Source      string
+	Version     string
+	Environment string
+	Description string
+	URL         string
+	TS          time.Time
+}
+
+// Parse returns the deployment a webhook reports, timestamped now when it
+// carries no time. ok is false for events that are not a finished
+// deployment, such as GitHub's ping or a GitLab deployment still running.
+func Parse(h http.Header, body []byte, now time.Time) (ev Event, ok bool, err error) {
+	switch {
+	case h.Get("X-GitHub-Event") != "":
+		ev, ok, err = github(h.Get("X-GitHub-Event"), body)
+		ev.Source = GitHub
+	case h.Get("X-Gitlab-Event") != "":
+		ev, ok, err = gitlab(h.Get("X-Gitlab-Event"), body)
+		ev.Source = GitLab
+	default:
+		ev, ok, err = generic(body)
+		ev.Source = Generic
+	}
+	if err != nil || !ok {
+		return ev, false, err
+	}
+	if ev.TS.IsZero() || ev.TS.After(now) {
+		ev.TS = now
+	}
+	ev.TS = ev.TS.UTC()
+	ev.Service = strings.TrimSpace(ev.Service)
+	ev.Version = clip(ev.Version, maxVersion)
+	ev.Environment = clip(ev.Environment, maxVersion)
+	ev.Description = clip(ev.Description, maxDescription)
+	ev.URL = clip(ev.URL, maxURL)
+	return ev, true, nil
+}
+
+type githubRepository struct {
+	Name string `json:"name"`
+}
+
+// github handles successful deployment statuses and published releases.
+func github(event string, body []byte) (Event, bool, error) {
+	switch event {
+	case "deployment_status":
+		var p struct {
+			DeploymentStatus struct {
+				State          string    `json:"state"`
+				Description    string    `json:"description"`
+				TargetURL      string    `json:"target_url"`
+				EnvironmentURL string    `json:"environment_url"`
+				UpdatedAt      time.Time `json:"updated_at"`
+			} `json:"deployment_status"`
+			Deployment struct {
+				SHA         string `json:"sha"`
+				Ref         string `json:"ref"`
+				Environment string `json:"environment"`
+				Description string `json:"description"`
+			} `json:"deployment"`
+			Repository githubRepository `json:"repository"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return Event{}, false, fmt.Errorf("invalid deployment_status event: %w", err)
+		}
+		st, d := p.DeploymentStatus, p.Deployment
+		if st.State != "success" {
+			return Event{}, false, nil
+		}
+		return Event{
+			Service:     p.Repository.Name,
+			Version:     version(d.Ref, d.SHA),
+			Environment: d.Environment,
+			Description: first(st.Description, d.Description),
+			URL:         first(st.EnvironmentURL, st.TargetURL),
+			TS:          st.UpdatedAt,
+		}, true, nil
+	case "release":
+		var p struct {
+			Action  string `json:"action"`
+			Release struct {
+				TagName     string    `json:"tag_name"`
+				Name        string    `json:"name"`
+				HTMLURL     string    `json:"html_url"`
+				PublishedAt time.Time `json:"published_at"`
+			} `json:"release"`
+			Repository githubRepository `json:"repository"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return Event{}, false, fmt.Errorf("invalid release event: %w", err)
+		}
+		if p.Action != "published" {
+			return Event{}, false, nil
+		}
+		return Event{Service: p.Repository.Name, Version: p.Release.TagName, Description: p.Release.Name,
+			URL: p.Release.HTMLURL, TS: p.Release.PublishedAt}, true, nil
+	}
+	return Event{}, false, nil
+}
+
+type gitlabProject struct {
+	Name string `json:"name"`
+}
+
+// gitlabTime is a GitLab webhook timestamp, "2021-04-28 21:50:00 +0200"
+// or "2020-11-02 12:55:12 UTC".
+type gitlabTime struct{ time.Time }
+
+func (t *gitlabTime) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil || s == "" {
+		return err
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05 -0700", "2006-01-02 15:04:05 MST", time.RFC3339} {
+		if ts, err := time.Parse(layout, s); err == nil {
+			t.Time = ts
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid time %q", s)
+}
+
+// gitlab handles successful deployments and created releases.
+func gitlab(event string, body []byte) (Event, bool, error) {
+	switch event {
+	case "Deployment Hook":
+		var p struct {
+			Status          string        `json:"status"`
+			StatusChangedAt gitlabTime    `json:"status_changed_at"`
+			Environment     string        `json:"environment"`
+			Ref             string        `json:"ref"`
+			ShortSHA        string        `json:"short_sha"`
+			CommitURL       string        `json:"commit_url"`
+			CommitTitle     string        `json:"commit_title"`
+			Project         gitlabProject `json:"project"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return Event{}, false, fmt.Errorf("invalid deployment event: %w", err)
+		}
+		if p.Status != "success" {
+			return Event{}, false, nil
+		}
+		return Event{Service: p.Project.Name, Version: version(p.Ref, p.ShortSHA), Environment: p.Environment,
+			Description: p.CommitTitle, URL: p.CommitURL, TS: p.StatusChangedAt.Time}, true, nil
+	case "Release Hook":
+		var p struct {
+			Action     string        `json:"action"`
+			Tag        string        `json:"tag"`
+			Name       string        `json:"name"`
+			URL        string        `json:"url"`
+			ReleasedAt gitlabTime    `json:"released_at"`
+			Project    gitlabProject `json:"project"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return Event{}, false, fmt.Errorf("invalid release event: %w", err)
+		}
+		if p.Action != "create" {
+			return Event{}, false, nil
+		}
+		return Event{Service: p.Project.Name, Version: p.Tag, Description: p.Name, URL: p.URL, TS: p.ReleasedAt.Time}, true, nil
+	}
+	return Event{}, false, nil
+}
+
+// generic handles a plain JSON body, as a CI job would post it:
+// {"service", "version", "environment", "description", "url", "ts"}.
+func generic(body []byte) (Event, bool, error) {
+	var p struct {
+		Service     string    `json:"service"`
+		Version     string    `json:"version"`
+		Environment string    `json:"environment"`
+		Description string    `json:"description"`
+		URL         string    `json:"url"`
+		TS          time.Time `json:"ts"`
+	}
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if err := json.Unmarshal(body, &p); err != nil {
+			return Event{}, false, fmt.Errorf("invalid deployment: %w", err)
+		}
+	}
+	if p.URL != "" && !strings.HasPrefix(p.URL, "http://") && !strings.HasPrefix(p.URL, "https://") {
+		return Event{}, false, errors.New("url must be an http or https URL")
+	}
+	return Event{Service: p.Service, Version: p.Version, Environment: p.Environment, Description: p.Description,
+		URL: p.URL, TS: p.TS}, true, nil
+}
+
+// version names a deployed ref and commit, e.g. "main@1a2b3c4".
+func version(ref, sha string) string {
+	if len(sha) > 7 {
+		sha = sha[:7]
+	}
+	switch {
+	case sha == "":
+		return ref
+	case ref == "" || strings.HasPrefix(ref, sha):
+		return sha
+	}
+	return ref + "@" + sha
+}
+
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func clip(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) > n {
+		s = strings.ToValidUTF8(s[:n], "")
+	}
+	return s
+}
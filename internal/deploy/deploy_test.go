@@ -0,0 +1,57 @@
+package deploy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	header := func(k, v string) http.Header {
+		h := http.Header{}
+		if k != "" {
+			h.Set(k, v)
+		}
+		return h
+	}
+	cases := []struct {
+		name   string
+		header http.Header
+		body   string
+		ok     bool
+		want   Event
+	}{
+		{"github deployment", header("X-GitHub-Event", "deployment_status"), `{
+			"deployment_status": {"state": "success", "environment_url": "https://shop.example", "updated_at": "2026-05-01T10:00:00Z"},
+			"deployment": {"sha": "1a2b3c4d5e6f", "ref": "main", "environment": "production", "description": "Deploy main"},
+			"repository": {"name": "shop"}}`,
+			true, Event{Service: "shop", Source: GitHub, Version: "main@1a2b3c4", Environment: "production", Description: "Deploy main",
+				URL: "https://shop.example", TS: time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)}},
+		{"github deployment in progress", header("X-GitHub-Event", "deployment_status"), `{"deployment_status": {"state": "in_progress"}}`, false, Event{}},
+		{"github release", header("X-GitHub-Event", "release"), `{"action": "published",
+			"release": {"tag_name": "v1.4.0", "name": "Spring", "html_url": "https://github.com/o/shop/releases/v1.4.0"}, "repository": {"name": "shop"}}`,
+			true, Event{Service: "shop", Source: GitHub, Version: "v1.4.0", Description: "Spring", URL: "https://github.com/o/shop/releases/v1.4.0", TS: now}},
+		{"github ping", header("X-GitHub-Event", "ping"), `{"zen": "Keep it simple."}`, false, Event{}},
+		{"gitlab deployment", header("X-Gitlab-Event", "Deployment Hook"), `{"status": "success", "status_changed_at": "2026-05-01 11:30:00 +0200",
+			"environment": "staging", "ref": "main", "short_sha": "9f8e7d6", "commit_title": "Fix cart", "commit_url": "https://gitlab.example/c/9f8e7d6",
+			"project": {"name": "cart"}}`,
+			true, Event{Service: "cart", Source: GitLab, Version: "main@9f8e7d6", Environment: "staging", Description: "Fix cart",
+				URL: "https://gitlab.example/c/9f8e7d6", TS: time.Date(2026, 5, 1, 9, 30, 0, 0, time.UTC)}},
+		{"gitlab deployment running", header("X-Gitlab-Event", "Deployment Hook"), `{"status": "running"}`, false, Event{}},
+		{"generic", header("", ""), `{"service": "api", "version": "2026.05.01", "ts": "2026-05-02T00:00:00Z"}`,
+			true, Event{Service: "api", Source: Generic, Version: "2026.05.01", TS: now}},
+		{"generic without body", header("", ""), ``, true, Event{Source: Generic, TS: now}},
+	}
+	for _, tc := range cases {
+		got, ok, err := Parse(tc.header, []byte(tc.body), now)
+		if err != nil || ok != tc.ok || (ok && got != tc.want) {
+			t.Errorf("%s: Parse = %+v, %v, %v; want %+v", tc.name, got, ok, err, tc.want)
+		}
+	}
+	for _, body := range []string{`{"version": 3}`, `{"url": "javascript:alert(1)"}`} {
+		if _, _, err := Parse(http.Header{}, []byte(body), now); err == nil {
+			t.Errorf("Parse(%s) accepted", body)
+		}
+	}
+}
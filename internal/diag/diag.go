@@ -0,0 +1,28 @@
+// Package diag serves Go's runtime profiles and expvar counters, for
+// profiling CPU and memory use of a running dashi. It is only mounted on
+// the separate APP_DEBUG_ADDR listener, never on the UI's.
+package diag
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+}
+
+// Handler serves the net/http/pprof profiles under /debug/pprof/ and the
+// expvar variables, including memstats, at /debug/vars.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
@@ -0,0 +1,101 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrForbidden is returned for API endpoints the daemon, or a socket proxy in
+// front of it, refuses with 403.
+var ErrForbidden = errors.New("forbidden by docker api")
+
+// deniedRecheck is how long a refused feature is skipped before it is tried
+// again, so a changed proxy configuration is picked up without a restart.
+const deniedRecheck = 10 * time.Minute
+
+// feature names the group of endpoints a socket proxy allows or denies
+// together (docker-socket-proxy's CONTAINERS, EVENTS, IMAGES, ...).
+func feature(p string) string {
+	p = strings.TrimPrefix(p, "/")
+	first, rest, _ := strings.Cut(p, "/")
+	first, _, _ = strings.Cut(first, "?")
+	// Proxies usually refuse POSTs while allowing reads, so prunes must not
+	// take the listing endpoints down with them.
+	if action, _, _ := strings.Cut(rest, "?"); action == "prune" {
+		return first + "/prune"
+	}
+	if first == "containers" {
+		rest, _, _ = strings.Cut(rest, "?")
+		parts := strings.Split(rest, "/")
+		if len(parts) == 2 && parts[1] != "json" {
+			return parts[1] // stats, logs, top
+		}
+	}
+	return strings.TrimPrefix(first, "_")
+}
+
+// SetLogger enables a one-time warning per feature when it is refused.
+func (c *Client) SetLogger(l *slog.Logger) {
+	c.mu.Lock()
+	c.log = l
+	c.mu.Unlock()
+}
+
+// Denied lists the features currently refused by the daemon.
+func (c *Client) Denied() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]string, 0, len(c.denied))
+	for f := range c.denied {
+		out = append(out, f)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// send performs req unless its feature was recently refused, and records
+// 403 responses. Callers still handle other non-2xx statuses.
+func (c *Client) send(hc *http.Client, req *http.Request) (*http.Response, error) {
+	f := feature(req.URL.Path)
+	c.mu.Lock()
+	since, denied := c.denied[f]
+	c.mu.Unlock()
+	if denied && time.Since(since) < deniedRecheck {
+		return nil, fmt.Errorf("%w: %s", ErrForbidden, f)
+	}
+	res, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusForbidden {
+		if denied && res.StatusCode < 300 {
+			c.mu.Lock()
+			delete(c.denied, f)
+			log := c.log
+			c.mu.Unlock()
+			if log != nil {
+				log.Info("docker api feature allowed again", "feature", f)
+			}
+		}
+		return res, nil
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 2048))
+	_ = res.Body.Close()
+	c.mu.Lock()
+	if c.denied == nil {
+		c.denied = map[string]time.Time{}
+	}
+	c.denied[f] = time.Now()
+	log := c.log
+	c.mu.Unlock()
+	if !denied && log != nil {
+		log.Warn("docker api feature forbidden, disabling it", "feature", f, "recheck_in", deniedRecheck, "response", strings.TrimSpace(string(b)))
+	}
+	return nil, fmt.Errorf("%w: %s", ErrForbidden, f)
+}
@@ -3,26 +3,39 @@ package docker
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Client struct {
+	base       string
 	http       *http.Client
 	streamHTTP *http.Client
+
+	mu     sync.Mutex
+	denied map[string]time.Time
+	log    *slog.Logger
 }
 
 type ContainerSummary struct {
 	ID      string            `json:"Id"`
 	Names   []string          `json:"Names"`
 	Image   string            `json:"Image"`
+	ImageID string            `json:"ImageID"`
 	State   string            `json:"State"`
 	Status  string            `json:"Status"`
 	Labels  map[string]string `json:"Labels"`
@@ -36,7 +49,30 @@ type ContainerInspect struct {
 	State        struct {
 		StartedAt string `json:"StartedAt"`
 		Status    string `json:"Status"`
+		Pid       int    `json:"Pid"`
+		// ExitCode, OOMKilled and FinishedAt describe the last exit.
+		ExitCode   int    `json:"ExitCode"`
+		OOMKilled  bool   `json:"OOMKilled"`
+		FinishedAt string `json:"FinishedAt"`
+		Health     *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
 	} `json:"State"`
+	HostConfig struct {
+		CgroupParent string `json:"CgroupParent"`
+		// Links are legacy links as "/target:/name/alias".
+		Links []string `json:"Links"`
+	} `json:"HostConfig"`
+	NetworkSettings struct {
+		// Ports maps "80/tcp" to its host bindings, nil when unpublished.
+		Ports map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string `json:"HostPort"`
+		} `json:"Ports"`
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
 }
 
 type Stats struct {
@@ -65,6 +101,10 @@ type Stats struct {
 		RxBytes uint64 `json:"rx_bytes"`
 		TxBytes uint64 `json:"tx_bytes"`
 	} `json:"networks"`
+	PidsStats struct {
+		Current uint64 `json:"current"`
+		Limit   uint64 `json:"limit"`
+	} `json:"pids_stats"`
 	BlkioStats struct {
 		IoServiceBytesRecursive []struct {
 			Op    string `json:"op"`
@@ -80,7 +120,72 @@ func NewClient(socketPath string) *Client {
 			return dialer.DialContext(ctx, "unix", socketPath)
 		},
 	}
+	return newClient("http://unix", transport)
+}
+
+// NewTCPClient talks to a Docker daemon listening on addr ("host:port"),
+// over TLS when tlsCfg is set.
+func NewTCPClient(addr string, tlsCfg *tls.Config) *Client {
+	dialer := &net.Dialer{Timeout: 3 * time.Second}
+	transport := &http.Transport{DialContext: dialer.DialContext, TLSClientConfig: tlsCfg}
+	scheme := "http"
+	if tlsCfg != nil {
+		scheme = "https"
+	}
+	return newClient(scheme+"://"+addr, transport)
+}
+
+// Hostname is the daemon host of a tcp client, empty for socket clients.
+func (c *Client) Hostname() string {
+	u, err := url.Parse(c.base)
+	if err != nil || u.Host == "unix" {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// Dial returns a client for a Docker endpoint: a socket path,
+// unix:///path or tcp://host:port. For tcp endpoints a certDir holding
+// ca.pem, cert.pem and key.pem (the docker CLI layout) enables TLS.
+func Dial(endpoint, certDir string) (*Client, error) {
+	switch {
+	case strings.HasPrefix(endpoint, "tcp://"):
+		var tlsCfg *tls.Config
+		if certDir != "" {
+			var err error
+			if tlsCfg, err = LoadTLS(certDir); err != nil {
+				return nil, err
+			}
+		}
+		return NewTCPClient(strings.TrimPrefix(endpoint, "tcp://"), tlsCfg), nil
+	case strings.HasPrefix(endpoint, "unix://"):
+		return NewClient(strings.TrimPrefix(endpoint, "unix://")), nil
+	case strings.HasPrefix(endpoint, "/"):
+		return NewClient(endpoint), nil
+	}
+	return nil, fmt.Errorf("unsupported docker endpoint %q", endpoint)
+}
+
+// LoadTLS reads a client certificate and CA from dir.
+func LoadTLS(dir string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("load docker client certificate: %w", err)
+	}
+	ca, err := os.ReadFile(filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("load docker ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates in %s", filepath.Join(dir, "ca.pem"))
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, RootCAs: pool, MinVersion: tls.VersionTLS12}, nil
+}
+
+func newClient(base string, transport *http.Transport) *Client {
 	return &Client{
+		base:       base,
 		http:       &http.Client{Transport: transport, Timeout: 30 * time.Second},
 		streamHTTP: &http.Client{Transport: transport},
 	}
@@ -91,6 +196,42 @@ func (c *Client) Ping(ctx context.Context) error {
 	return err
 }
 
+// Info describes the Docker daemon and the machine it runs on.
+type Info struct {
+	ServerVersion   string
+	OperatingSystem string
+	KernelVersion   string
+	Architecture    string
+}
+
+// Info returns the daemon's /info.
+func (c *Client) Info(ctx context.Context) (Info, error) {
+	b, err := c.do(ctx, http.MethodGet, "/info", nil)
+	if err != nil {
+		return Info{}, err
+	}
+	var out Info
+	if err := json.Unmarshal(b, &out); err != nil {
+		return Info{}, err
+	}
+	return out, nil
+}
+
+// SystemTime returns the daemon's clock as reported by /info.
+func (c *Client) SystemTime(ctx context.Context) (time.Time, error) {
+	b, err := c.do(ctx, http.MethodGet, "/info", nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var info struct {
+		SystemTime string
+	}
+	if err := json.Unmarshal(b, &info); err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, info.SystemTime)
+}
+
 func (c *Client) ListContainers(ctx context.Context) ([]ContainerSummary, error) {
 	b, err := c.do(ctx, http.MethodGet, "/containers/json?all=1", nil)
 	if err != nil {
@@ -127,6 +268,43 @@ func (c *Client) Stats(ctx context.Context, id string) (Stats, error) {
 	return out, nil
 }
 
+// StatsStream follows a container's stats, one JSON frame per second until
+// the container stops or ctx is done; see DecodeStats.
+func (c *Client) StatsStream(ctx context.Context, id string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+"/containers/"+id+"/stats?stream=true", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.send(c.streamHTTP, req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 2048))
+		return nil, fmt.Errorf("stats stream status %d: %s", res.StatusCode, string(b))
+	}
+	return res.Body, nil
+}
+
+// DecodeStats calls fn for each frame of a stats stream until the stream
+// ends or fn returns an error.
+func DecodeStats(r io.Reader, fn func(Stats) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		var st Stats
+		if err := dec.Decode(&st); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := fn(st); err != nil {
+			return err
+		}
+	}
+}
+
 func (c *Client) Logs(ctx context.Context, id string, since time.Time, follow bool, tail int) (io.ReadCloser, error) {
 	q := url.Values{}
 	q.Set("stdout", "1")
@@ -136,16 +314,17 @@ func (c *Client) Logs(ctx context.Context, id string, since time.Time, follow bo
 		q.Set("follow", "1")
 	}
 	if !since.IsZero() {
-		q.Set("since", fmt.Sprintf("%d", since.Unix()))
+		// Docker accepts nanoseconds and includes lines logged at since.
+		q.Set("since", fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond()))
 	}
 	if tail > 0 {
 		q.Set("tail", fmt.Sprintf("%d", tail))
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://unix"+path.Join("/containers", id, "logs")+"?"+q.Encode(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+path.Join("/containers", id, "logs")+"?"+q.Encode(), nil)
 	if err != nil {
 		return nil, err
 	}
-	res, err := c.streamHTTP.Do(req)
+	res, err := c.send(c.streamHTTP, req)
 	if err != nil {
 		return nil, err
 	}
@@ -157,12 +336,15 @@ func (c *Client) Logs(ctx context.Context, id string, since time.Time, follow bo
 	return res.Body, nil
 }
 
+// Events streams container events as newline-delimited JSON; see
+// DecodeEvents.
 func (c *Client) Events(ctx context.Context) (io.ReadCloser, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://unix/events", nil)
+	q := url.Values{"filters": {`{"type":["container"]}`}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+"/events?"+q.Encode(), nil)
 	if err != nil {
 		return nil, err
 	}
-	res, err := c.streamHTTP.Do(req)
+	res, err := c.send(c.streamHTTP, req)
 	if err != nil {
 		return nil, err
 	}
@@ -179,11 +361,11 @@ func (c *Client) do(ctx context.Context, method, p string, body []byte) ([]byte,
 	if body != nil {
 		reader = bytes.NewReader(body)
 	}
-	req, err := http.NewRequestWithContext(ctx, method, "http://unix"+p, reader)
+	req, err := http.NewRequestWithContext(ctx, method, c.base+p, reader)
 	if err != nil {
 		return nil, err
 	}
-	res, err := c.http.Do(req)
+	res, err := c.send(c.http, req)
 	if err != nil {
 		return nil, err
 	}
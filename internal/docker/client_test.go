@@ -0,0 +1,106 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTCPClientPing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_ping" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("OK"))
+	}))
+	defer srv.Close()
+
+	c, err := Dial("tcp://"+strings.TrimPrefix(srv.URL, "http://"), "")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+}
+
+func TestDialRejectsUnknownScheme(t *testing.T) {
+	for _, ep := range []string{"ssh://host", "host:2375", ""} {
+		if _, err := Dial(ep, ""); err == nil {
+			t.Errorf("Dial(%q) succeeded", ep)
+		}
+	}
+	if _, err := Dial("tcp://host:2376", t.TempDir()); err == nil {
+		t.Error("Dial with an empty cert dir succeeded")
+	}
+}
+
+func TestServiceIDQualifiesRemoteHosts(t *testing.T) {
+	c := ContainerSummary{Names: []string{"/web"}, Labels: map[string]string{"com.docker.compose.service": "web"}}
+	if got := ServiceID(LocalHost, c); got != "web" {
+		t.Fatalf("local id = %q", got)
+	}
+	if got := ServiceID("nas", c); got != "web@nas" {
+		t.Fatalf("remote id = %q", got)
+	}
+}
+
+func TestForbiddenFeatureIsSkipped(t *testing.T) {
+	var eventCalls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/events":
+			eventCalls++
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		case "/_ping":
+			_, _ = w.Write([]byte("OK"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	c, err := Dial("tcp://"+strings.TrimPrefix(srv.URL, "http://"), "")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	ctx := context.Background()
+	for range 3 {
+		if _, err := c.Events(ctx); !errors.Is(err, ErrForbidden) {
+			t.Fatalf("events err = %v, want ErrForbidden", err)
+		}
+	}
+	if eventCalls != 1 {
+		t.Fatalf("events requested %d times, want 1", eventCalls)
+	}
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if got := c.Denied(); len(got) != 1 || got[0] != "events" {
+		t.Fatalf("denied = %v", got)
+	}
+}
+
+func TestFeature(t *testing.T) {
+	cases := map[string]string{
+		"/containers/json":       "containers",
+		"/containers/abc/json":   "containers",
+		"/containers/abc/stats":  "stats",
+		"/containers/abc/logs":   "logs",
+		"/containers/abc/top":    "top",
+		"/events":                "events",
+		"/_ping":                 "ping",
+		"/system/df":             "system",
+		"/images/sha256:x/json":  "images",
+		"/distribution/a/b/json": "distribution",
+		"/volumes/prune?filters": "volumes/prune",
+	}
+	for p, want := range cases {
+		if got := feature(p); got != want {
+			t.Errorf("feature(%q) = %q, want %q", p, got, want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"dashi/internal/models"
+)
+
+// secretKeys are the name fragments of environment variables and flags
+// whose values are never shown.
+var secretKeys = []string{"PASSWORD", "PASSWD", "SECRET", "TOKEN", "KEY", "CREDENTIAL", "AUTH"}
+
+type containerConfig struct {
+	Config struct {
+		Image      string   `json:"Image"`
+		Env        []string `json:"Env"`
+		Cmd        []string `json:"Cmd"`
+		Entrypoint []string `json:"Entrypoint"`
+		WorkingDir string   `json:"WorkingDir"`
+		User       string   `json:"User"`
+	} `json:"Config"`
+	Mounts []struct {
+		Type        string `json:"Type"`
+		Name        string `json:"Name"`
+		Source      string `json:"Source"`
+		Destination string `json:"Destination"`
+		Mode        string `json:"Mode"`
+		RW          bool   `json:"RW"`
+	} `json:"Mounts"`
+	HostConfig struct {
+		RestartPolicy struct {
+			Name              string `json:"Name"`
+			MaximumRetryCount int    `json:"MaximumRetryCount"`
+		} `json:"RestartPolicy"`
+	} `json:"HostConfig"`
+}
+
+// ContainerConfig returns the environment, mounts, command and restart
+// policy of a container with secret-looking values redacted.
+func (c *Client) ContainerConfig(ctx context.Context, id string) (models.ContainerConfig, error) {
+	b, err := c.do(ctx, http.MethodGet, "/containers/"+id+"/json", nil)
+	if err != nil {
+		return models.ContainerConfig{}, err
+	}
+	var raw containerConfig
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return models.ContainerConfig{}, err
+	}
+	return raw.redacted(), nil
+}
+
+func (cc containerConfig) redacted() models.ContainerConfig {
+	out := models.ContainerConfig{
+		Image:         cc.Config.Image,
+		Entrypoint:    redactArgs(cc.Config.Entrypoint),
+		Command:       redactArgs(cc.Config.Cmd),
+		WorkingDir:    cc.Config.WorkingDir,
+		User:          cc.Config.User,
+		RestartPolicy: cc.HostConfig.RestartPolicy.Name,
+		MaxRetries:    cc.HostConfig.RestartPolicy.MaximumRetryCount,
+	}
+	if out.RestartPolicy == "" {
+		out.RestartPolicy = "no"
+	}
+	for _, kv := range cc.Config.Env {
+		name, value, _ := strings.Cut(kv, "=")
+		v := models.EnvVar{Name: name, Value: value}
+		if isSecret(name) {
+			v.Value, v.Redacted = models.RedactedValue, true
+		} else if clean := redactURL(value); clean != value {
+			v.Value, v.Redacted = clean, true
+		}
+		out.Env = append(out.Env, v)
+	}
+	for _, m := range cc.Mounts {
+		src := m.Source
+		if m.Type == "volume" && m.Name != "" {
+			src = m.Name
+		}
+		out.Mounts = append(out.Mounts, models.Mount{Type: m.Type, Source: src, Destination: m.Destination, Mode: m.Mode, ReadWrite: m.RW})
+	}
+	return out
+}
+
+func isSecret(name string) bool {
+	name = strings.ToUpper(name)
+	for _, k := range secretKeys {
+		if strings.Contains(name, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactArgs hides the values of secret-looking flags, both "--password=x"
+// and "--password x".
+func redactArgs(args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	out := make([]string, len(args))
+	hideNext := false
+	for i, a := range args {
+		switch {
+		case hideNext:
+			out[i] = models.RedactedValue
+			hideNext = false
+		case strings.HasPrefix(a, "-") && isSecret(a):
+			if flag, _, ok := strings.Cut(a, "="); ok {
+				out[i] = flag + "=" + models.RedactedValue
+			} else {
+				out[i] = a
+				hideNext = true
+			}
+		default:
+			out[i] = redactURL(a)
+		}
+	}
+	return out
+}
+
+// redactURL hides the password of connection strings such as
+// postgres://user:secret@db/app.
+func redactURL(s string) string {
+	if !strings.Contains(s, "://") || !strings.Contains(s, "@") {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); !ok {
+		return s
+	}
+	u.User = url.UserPassword(u.User.Username(), models.RedactedValue)
+	return u.String()
+}
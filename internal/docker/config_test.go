@@ -0,0 +1,47 @@
+package docker
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"dashi/internal/models"
+)
+
+func TestContainerConfigRedactsSecrets(t *testing.T) {
+	var raw containerConfig
+	err := json.Unmarshal([]byte(`{
+		"Config":{"Image":"app:1","Env":["TZ=UTC","DB_PASSWORD=hunter2","api_token=abc","DATABASE_URL=postgres://app:s3cret@db:5432/app"],
+			"Cmd":["serve","--secret-key","xyz","--auth-token=abc","--port","8080"]},
+		"Mounts":[{"Type":"volume","Name":"data","Source":"/var/lib/docker/volumes/data/_data","Destination":"/data","Mode":"z","RW":true}],
+		"HostConfig":{"RestartPolicy":{"Name":"on-failure","MaximumRetryCount":3}}}`), &raw)
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	cfg := raw.redacted()
+	env := map[string]models.EnvVar{}
+	for _, v := range cfg.Env {
+		env[v.Name] = v
+	}
+	if v := env["TZ"]; v.Value != "UTC" || v.Redacted {
+		t.Fatalf("TZ = %+v", v)
+	}
+	for _, name := range []string{"DB_PASSWORD", "api_token"} {
+		if v := env[name]; v.Value != models.RedactedValue || !v.Redacted {
+			t.Fatalf("%s = %+v", name, v)
+		}
+	}
+	if v := env["DATABASE_URL"]; strings.Contains(v.Value, "s3cret") || !strings.Contains(v.Value, "app:") || !v.Redacted {
+		t.Fatalf("DATABASE_URL = %+v", v)
+	}
+	want := []string{"serve", "--secret-key", models.RedactedValue, "--auth-token=" + models.RedactedValue, "--port", "8080"}
+	if strings.Join(cfg.Command, " ") != strings.Join(want, " ") {
+		t.Fatalf("command = %q", cfg.Command)
+	}
+	if len(cfg.Mounts) != 1 || cfg.Mounts[0].Source != "data" || !cfg.Mounts[0].ReadWrite {
+		t.Fatalf("mounts = %+v", cfg.Mounts)
+	}
+	if cfg.RestartPolicy != "on-failure" || cfg.MaxRetries != 3 {
+		t.Fatalf("restart policy = %q/%d", cfg.RestartPolicy, cfg.MaxRetries)
+	}
+}
@@ -0,0 +1,42 @@
+package docker
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+type Event struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+	TimeNano int64 `json:"timeNano"`
+}
+
+// Health returns the status carried by a "health_status: <status>" action.
+func (e Event) Health() (string, bool) {
+	status, ok := strings.CutPrefix(e.Action, "health_status: ")
+	return status, ok
+}
+
+// DecodeEvents calls fn for each event read from an events stream until the
+// stream ends or fn returns an error.
+func DecodeEvents(r io.Reader, fn func(Event) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		var ev Event
+		if err := dec.Decode(&ev); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := fn(ev); err != nil {
+			return err
+		}
+	}
+}
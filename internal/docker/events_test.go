@@ -0,0 +1,31 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeEvents(t *testing.T) {
+	stream := `{"Type":"container","Action":"start","Actor":{"ID":"abc","Attributes":{"name":"web"}},"timeNano":1}
+{"Type":"container","Action":"health_status: unhealthy","Actor":{"ID":"abc"},"timeNano":2}
+`
+	var got []Event
+	if err := DecodeEvents(strings.NewReader(stream), func(e Event) error {
+		got = append(got, e)
+		return nil
+	}); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("decoded %d events, want 2", len(got))
+	}
+	if got[0].Action != "start" || got[0].Actor.ID != "abc" || got[0].Actor.Attributes["name"] != "web" {
+		t.Fatalf("unexpected first event: %+v", got[0])
+	}
+	if _, ok := got[0].Health(); ok {
+		t.Fatal("start event reported a health status")
+	}
+	if h, ok := got[1].Health(); !ok || h != "unhealthy" {
+		t.Fatalf("health = %q, %v", h, ok)
+	}
+}
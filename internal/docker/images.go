@@ -0,0 +1,178 @@
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultRegistry is the registry of image references without a host part.
+const DefaultRegistry = "docker.io"
+
+type ImageInspect struct {
+	ID          string   `json:"Id"`
+	RepoTags    []string `json:"RepoTags"`
+	RepoDigests []string `json:"RepoDigests"`
+}
+
+// RegistryAuth is sent to the daemon for registry lookups that need
+// credentials.
+type RegistryAuth struct {
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	ServerAddress string `json:"serveraddress,omitempty"`
+}
+
+// Reference is an image reference split into its parts, normalized the way
+// the docker CLI does ("nginx" is docker.io/library/nginx:latest).
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference splits ref into registry, repository, tag and digest.
+func ParseReference(ref string) Reference {
+	var out Reference
+	name := ref
+	if i := strings.Index(name, "@"); i >= 0 {
+		out.Digest = name[i+1:]
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		out.Tag = name[i+1:]
+		name = name[:i]
+	}
+	out.Registry = DefaultRegistry
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		out.Registry = first
+		name = rest
+	}
+	if out.Registry == DefaultRegistry && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	out.Repository = name
+	if out.Tag == "" && out.Digest == "" {
+		out.Tag = "latest"
+	}
+	return out
+}
+
+// Name is the registry-qualified repository, without tag or digest.
+func (r Reference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// String is the fully qualified reference.
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// RepoDigest returns the digest img was pulled with from ref's repository,
+// empty for images built locally or pulled from elsewhere.
+func (img ImageInspect) RepoDigest(ref Reference) string {
+	for _, rd := range img.RepoDigests {
+		parsed := ParseReference(rd)
+		if parsed.Name() == ref.Name() {
+			return parsed.Digest
+		}
+	}
+	return ""
+}
+
+func (c *Client) InspectImage(ctx context.Context, ref string) (ImageInspect, error) {
+	b, err := c.do(ctx, http.MethodGet, "/images/"+ref+"/json", nil)
+	if err != nil {
+		return ImageInspect{}, err
+	}
+	var out ImageInspect
+	if err := json.Unmarshal(b, &out); err != nil {
+		return ImageInspect{}, err
+	}
+	return out, nil
+}
+
+// DistributionDigest asks the daemon for the digest ref currently has in its
+// registry, without pulling it. auth may be nil for public images.
+func (c *Client) DistributionDigest(ctx context.Context, ref string, auth *RegistryAuth) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+"/distribution/"+ref+"/json", nil)
+	if err != nil {
+		return "", err
+	}
+	if auth != nil {
+		b, err := json.Marshal(auth)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("X-Registry-Auth", base64.URLEncoding.EncodeToString(b))
+	}
+	res, err := c.send(c.http, req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode >= 300 {
+		return "", fmt.Errorf("distribution %s status %d: %s", ref, res.StatusCode, strings.TrimSpace(string(b)))
+	}
+	var out struct {
+		Descriptor struct {
+			Digest string `json:"digest"`
+		} `json:"Descriptor"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return "", err
+	}
+	if out.Descriptor.Digest == "" {
+		return "", fmt.Errorf("distribution %s: no digest", ref)
+	}
+	return out.Descriptor.Digest, nil
+}
+
+// DiskUsage is the subset of /system/df dashi records.
+type DiskUsage struct {
+	Images []struct {
+		ID         string   `json:"Id"`
+		RepoTags   []string `json:"RepoTags"`
+		Size       int64    `json:"Size"`
+		SharedSize int64    `json:"SharedSize"`
+		Containers int      `json:"Containers"`
+	} `json:"Images"`
+	Volumes []struct {
+		Name      string `json:"Name"`
+		Driver    string `json:"Driver"`
+		UsageData *struct {
+			Size     int64 `json:"Size"`
+			RefCount int   `json:"RefCount"`
+		} `json:"UsageData"`
+	} `json:"Volumes"`
+}
+
+// DiskUsage walks images and volumes on the daemon, which can take a while
+// on hosts with large volumes.
+func (c *Client) DiskUsage(ctx context.Context) (DiskUsage, error) {
+	b, err := c.do(ctx, http.MethodGet, "/system/df", nil)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+	var out DiskUsage
+	if err := json.Unmarshal(b, &out); err != nil {
+		return DiskUsage{}, err
+	}
+	return out, nil
+}
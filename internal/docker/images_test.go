@@ -0,0 +1,29 @@
+package docker
+
+import "testing"
+
+func TestParseReference(t *testing.T) {
+	cases := map[string]string{
+		"nginx":                             "docker.io/library/nginx:latest",
+		"grafana/grafana:10.4":              "docker.io/grafana/grafana:10.4",
+		"ghcr.io/acme/api:v2":               "ghcr.io/acme/api:v2",
+		"localhost:5000/tools":              "localhost:5000/tools:latest",
+		"redis@sha256:abc":                  "docker.io/library/redis@sha256:abc",
+		"registry.local:443/a/b:1@sha256:d": "registry.local:443/a/b:1@sha256:d",
+	}
+	for in, want := range cases {
+		if got := ParseReference(in).String(); got != want {
+			t.Errorf("ParseReference(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRepoDigestMatchesFamiliarNames(t *testing.T) {
+	img := ImageInspect{RepoDigests: []string{"ghcr.io/acme/api@sha256:111", "nginx@sha256:222"}}
+	if got := img.RepoDigest(ParseReference("docker.io/library/nginx:1.27")); got != "sha256:222" {
+		t.Fatalf("digest = %q", got)
+	}
+	if got := img.RepoDigest(ParseReference("acme/api")); got != "" {
+		t.Fatalf("digest for other registry = %q", got)
+	}
+}
@@ -1,6 +1,10 @@
 package docker
 
-import "dashi/internal/models"
+import (
+	"math"
+
+	"dashi/internal/models"
+)
 
 func NormalizeStats(id string, s Stats) models.ContainerMetric {
 	var cpuPct float64
@@ -39,5 +43,15 @@ func NormalizeStats(id string, s Stats) models.ContainerMetric {
 		NetTXBytes:    int64(tx),
 		BlkReadBytes:  int64(br),
 		BlkWriteBytes: int64(bw),
+		Pids:          int64(s.PidsStats.Current),
+		PidsLimit:     limit(s.PidsStats.Limit),
+	}
+}
+
+// limit maps the "unlimited" values Docker reports (0 or MaxUint64) to 0.
+func limit(v uint64) int64 {
+	if v > math.MaxInt64 {
+		return 0
 	}
+	return int64(v)
 }
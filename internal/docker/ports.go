@@ -0,0 +1,71 @@
+package docker
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"dashi/internal/models"
+)
+
+// Ports flattens the port map of an inspect into one entry per host
+// binding, plus one entry without a host port for exposed but unpublished
+// ports. The result is sorted by container port.
+func (ci ContainerInspect) Ports() []models.Port {
+	var out []models.Port
+	for key, bindings := range ci.NetworkSettings.Ports {
+		port, proto, _ := strings.Cut(key, "/")
+		cport, err := strconv.Atoi(port)
+		if err != nil {
+			continue
+		}
+		if proto == "" {
+			proto = "tcp"
+		}
+		if len(bindings) == 0 {
+			out = append(out, models.Port{ContainerPort: cport, Protocol: proto})
+			continue
+		}
+		for _, b := range bindings {
+			hport, _ := strconv.Atoi(b.HostPort)
+			out = append(out, models.Port{HostIP: b.HostIP, HostPort: hport, ContainerPort: cport, Protocol: proto})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.ContainerPort != b.ContainerPort {
+			return a.ContainerPort < b.ContainerPort
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if a.HostIP != b.HostIP {
+			return a.HostIP < b.HostIP
+		}
+		return a.HostPort < b.HostPort
+	})
+	return out
+}
+
+// Links lists the names of the containers linked to, sorted.
+func (ci ContainerInspect) Links() []string {
+	out := make([]string, 0, len(ci.HostConfig.Links))
+	for _, l := range ci.HostConfig.Links {
+		target, _, _ := strings.Cut(l, ":")
+		if target = strings.TrimPrefix(target, "/"); target != "" {
+			out = append(out, target)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Networks lists the attached networks by name.
+func (ci ContainerInspect) Networks() []models.Network {
+	out := make([]models.Network, 0, len(ci.NetworkSettings.Networks))
+	for name, n := range ci.NetworkSettings.Networks {
+		out = append(out, models.Network{Name: name, IP: n.IPAddress})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,36 @@
+package docker
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestInspectPortsNetworksAndLinks(t *testing.T) {
+	var ci ContainerInspect
+	raw := `{"HostConfig":{"Links":["/redis:/web/cache","/db:/web/db"]},"NetworkSettings":{
+		"Ports":{"443/tcp":null,"80/tcp":[{"HostIp":"0.0.0.0","HostPort":"8080"},{"HostIp":"::","HostPort":"8080"}],"53/udp":[{"HostIp":"127.0.0.1","HostPort":"5353"}]},
+		"Networks":{"web":{"IPAddress":"172.20.0.3"},"bridge":{"IPAddress":"172.17.0.2"}}}}`
+	if err := json.Unmarshal([]byte(raw), &ci); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	ports := ci.Ports()
+	if len(ports) != 4 {
+		t.Fatalf("ports = %+v", ports)
+	}
+	if p := ports[0]; p.ContainerPort != 53 || p.Protocol != "udp" || p.HostIP != "127.0.0.1" || p.HostPort != 5353 {
+		t.Fatalf("first port = %+v", p)
+	}
+	if p := ports[1]; p.ContainerPort != 80 || p.HostIP != "0.0.0.0" || p.HostPort != 8080 {
+		t.Fatalf("second port = %+v", p)
+	}
+	if p := ports[3]; p.ContainerPort != 443 || p.HostPort != 0 {
+		t.Fatalf("unpublished port = %+v", p)
+	}
+	nets := ci.Networks()
+	if len(nets) != 2 || nets[0].Name != "bridge" || nets[1].IP != "172.20.0.3" {
+		t.Fatalf("networks = %+v", nets)
+	}
+	if links := ci.Links(); len(links) != 2 || links[0] != "db" || links[1] != "redis" {
+		t.Fatalf("links = %v", links)
+	}
+}
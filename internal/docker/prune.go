@@ -0,0 +1,141 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"dashi/internal/models"
+)
+
+// PruneKinds are the resources Prune can remove: stopped containers,
+// dangling images and volumes no container uses.
+var PruneKinds = []string{"containers", "images", "volumes"}
+
+// prunable are the container states docker container prune removes.
+var prunable = map[string]bool{"exited": true, "created": true, "dead": true}
+
+// PrunePreview lists what Prune would remove and roughly how much space it
+// would free. The daemon has no dry run, so the preview is built from the
+// same filters the prune call uses.
+func (c *Client) PrunePreview(ctx context.Context, kind string) (models.PruneReport, error) {
+	out := models.PruneReport{Kind: kind, DryRun: true}
+	switch kind {
+	case "containers":
+		b, err := c.do(ctx, http.MethodGet, "/containers/json?all=1&size=1", nil)
+		if err != nil {
+			return out, err
+		}
+		var list []struct {
+			ID     string   `json:"Id"`
+			Names  []string `json:"Names"`
+			State  string   `json:"State"`
+			SizeRw int64    `json:"SizeRw"`
+		}
+		if err := json.Unmarshal(b, &list); err != nil {
+			return out, err
+		}
+		for _, ct := range list {
+			if !prunable[ct.State] {
+				continue
+			}
+			name := shortID(ct.ID)
+			if len(ct.Names) > 0 {
+				name = strings.TrimPrefix(ct.Names[0], "/")
+			}
+			out.Items = append(out.Items, name)
+			out.ReclaimedBytes += ct.SizeRw
+		}
+	case "images":
+		b, err := c.do(ctx, http.MethodGet, "/images/json?"+pruneFilter("dangling", "true"), nil)
+		if err != nil {
+			return out, err
+		}
+		var list []struct {
+			ID   string `json:"Id"`
+			Size int64  `json:"Size"`
+		}
+		if err := json.Unmarshal(b, &list); err != nil {
+			return out, err
+		}
+		for _, img := range list {
+			out.Items = append(out.Items, shortID(img.ID))
+			out.ReclaimedBytes += img.Size
+		}
+	case "volumes":
+		df, err := c.DiskUsage(ctx)
+		if err != nil {
+			return out, err
+		}
+		for _, v := range df.Volumes {
+			if v.UsageData == nil || v.UsageData.RefCount != 0 {
+				continue
+			}
+			out.Items = append(out.Items, v.Name)
+			out.ReclaimedBytes += max(v.UsageData.Size, 0)
+		}
+	default:
+		return out, fmt.Errorf("unknown prune kind %q", kind)
+	}
+	return out, nil
+}
+
+// Prune removes stopped containers, dangling images or unused volumes,
+// named volumes included, and reports what the daemon deleted.
+func (c *Client) Prune(ctx context.Context, kind string) (models.PruneReport, error) {
+	out := models.PruneReport{Kind: kind}
+	var p string
+	switch kind {
+	case "containers":
+		p = "/containers/prune"
+	case "images":
+		p = "/images/prune?" + pruneFilter("dangling", "true")
+	case "volumes":
+		// Since API 1.42 only anonymous volumes are pruned by default.
+		p = "/volumes/prune?" + pruneFilter("all", "true")
+	default:
+		return out, fmt.Errorf("unknown prune kind %q", kind)
+	}
+	b, err := c.do(ctx, http.MethodPost, p, nil)
+	if err != nil {
+		return out, err
+	}
+	var res struct {
+		ContainersDeleted []string `json:"ContainersDeleted"`
+		ImagesDeleted     []struct {
+			Deleted string `json:"Deleted"`
+		} `json:"ImagesDeleted"`
+		VolumesDeleted []string `json:"VolumesDeleted"`
+		SpaceReclaimed int64    `json:"SpaceReclaimed"`
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return out, err
+	}
+	for _, id := range res.ContainersDeleted {
+		out.Items = append(out.Items, shortID(id))
+	}
+	for _, img := range res.ImagesDeleted {
+		if img.Deleted != "" {
+			out.Items = append(out.Items, shortID(img.Deleted))
+		}
+	}
+	out.Items = append(out.Items, res.VolumesDeleted...)
+	out.ReclaimedBytes = res.SpaceReclaimed
+	return out, nil
+}
+
+func pruneFilter(key, value string) string {
+	f, _ := json.Marshal(map[string][]string{key: {value}})
+	return url.Values{"filters": {string(f)}}.Encode()
+}
+
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,52 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrunePreviewMatchesPruneFilters(t *testing.T) {
+	var pruned string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/images/json":
+			if r.URL.Query().Get("filters") != `{"dangling":["true"]}` {
+				t.Errorf("image filters = %q", r.URL.Query().Get("filters"))
+			}
+			_, _ = io.WriteString(w, `[{"Id":"sha256:0123456789abcdef","Size":300}]`)
+		case r.URL.Path == "/system/df":
+			_, _ = io.WriteString(w, `{"Volumes":[{"Name":"used","UsageData":{"Size":10,"RefCount":1}},{"Name":"old","UsageData":{"Size":20,"RefCount":0}}]}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/volumes/prune":
+			pruned = r.URL.Query().Get("filters")
+			_, _ = io.WriteString(w, `{"VolumesDeleted":["old"],"SpaceReclaimed":20}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	c := NewTCPClient(strings.TrimPrefix(srv.URL, "http://"), nil)
+	ctx := context.Background()
+
+	images, err := c.PrunePreview(ctx, "images")
+	if err != nil || len(images.Items) != 1 || images.Items[0] != "0123456789ab" || images.ReclaimedBytes != 300 {
+		t.Fatalf("images preview = %+v, %v", images, err)
+	}
+	volumes, err := c.PrunePreview(ctx, "volumes")
+	if err != nil || len(volumes.Items) != 1 || volumes.Items[0] != "old" || !volumes.DryRun {
+		t.Fatalf("volumes preview = %+v, %v", volumes, err)
+	}
+	rep, err := c.Prune(ctx, "volumes")
+	if err != nil || len(rep.Items) != 1 || rep.ReclaimedBytes != 20 || rep.DryRun {
+		t.Fatalf("prune = %+v, %v", rep, err)
+	}
+	if pruned != `{"all":["true"]}` {
+		t.Fatalf("volume prune filters = %q", pruned)
+	}
+	if _, err := c.Prune(ctx, "networks"); err == nil {
+		t.Fatal("unknown kind accepted")
+	}
+}
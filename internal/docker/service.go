@@ -0,0 +1,32 @@
+package docker
+
+import "strings"
+
+// LocalHost names the Docker endpoint on the machine dashi runs on.
+const LocalHost = "local"
+
+// ServiceName is the compose service of c, falling back to the container
+// name or short ID.
+func ServiceName(c ContainerSummary) string {
+	if v := c.Labels["com.docker.compose.service"]; v != "" {
+		return v
+	}
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	if len(c.ID) >= 12 {
+		return c.ID[:12]
+	}
+	return c.ID
+}
+
+// ServiceID identifies the service of c on host. Local services keep their
+// bare name; others become "name@host" so equally named compose services on
+// different machines stay apart.
+func ServiceID(host string, c ContainerSummary) string {
+	name := ServiceName(c)
+	if host == "" || host == LocalHost {
+		return name
+	}
+	return name + "@" + host
+}
@@ -0,0 +1,63 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"dashi/internal/models"
+)
+
+// topArgs are the ps options passed to docker top; the command must come
+// last since it may contain spaces.
+const topArgs = "-o pid,user,pcpu,rss,args"
+
+type containerTop struct {
+	Titles    []string   `json:"Titles"`
+	Processes [][]string `json:"Processes"`
+}
+
+// Top lists the processes running inside a container, as seen by ps on the
+// Docker host.
+func (c *Client) Top(ctx context.Context, id string) ([]models.Process, error) {
+	b, err := c.do(ctx, http.MethodGet, "/containers/"+id+"/top?"+url.Values{"ps_args": {topArgs}}.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var top containerTop
+	if err := json.Unmarshal(b, &top); err != nil {
+		return nil, err
+	}
+	return top.parse(), nil
+}
+
+// parse maps rows by column title, so daemons that ignore ps_args (Windows,
+// some rootless setups) still yield PID and command.
+func (t containerTop) parse() []models.Process {
+	col := map[string]int{}
+	for i, title := range t.Titles {
+		col[strings.ToUpper(title)] = i
+	}
+	field := func(row []string, names ...string) string {
+		for _, n := range names {
+			if i, ok := col[n]; ok && i < len(row) {
+				return row[i]
+			}
+		}
+		return ""
+	}
+	out := make([]models.Process, 0, len(t.Processes))
+	for _, row := range t.Processes {
+		p := models.Process{User: field(row, "USER", "UID"), Command: field(row, "COMMAND", "CMD")}
+		p.PID, _ = strconv.Atoi(field(row, "PID"))
+		p.CPUPct, _ = strconv.ParseFloat(field(row, "%CPU", "C"), 64)
+		if kb, err := strconv.ParseInt(field(row, "RSS"), 10, 64); err == nil {
+			p.RSSBytes = kb << 10
+		}
+		out = append(out, p)
+	}
+	return out
+}
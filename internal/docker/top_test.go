@@ -0,0 +1,23 @@
+package docker
+
+import "testing"
+
+func TestTopParse(t *testing.T) {
+	top := containerTop{
+		Titles:    []string{"PID", "USER", "%CPU", "RSS", "COMMAND"},
+		Processes: [][]string{{"4242", "root", "12.5", "2048", "nginx: worker process"}},
+	}
+	got := top.parse()
+	if len(got) != 1 {
+		t.Fatalf("processes = %+v", got)
+	}
+	if p := got[0]; p.PID != 4242 || p.User != "root" || p.CPUPct != 12.5 || p.RSSBytes != 2048<<10 || p.Command != "nginx: worker process" {
+		t.Fatalf("process = %+v", p)
+	}
+
+	// Default "ps -ef" output has no CPU or RSS columns.
+	plain := containerTop{Titles: []string{"UID", "PID", "PPID", "C", "STIME", "TTY", "TIME", "CMD"}, Processes: [][]string{{"root", "1", "0", "0", "10:00", "?", "00:00:01", "sleep 1000"}}}
+	if p := plain.parse()[0]; p.PID != 1 || p.Command != "sleep 1000" || p.RSSBytes != 0 {
+		t.Fatalf("plain process = %+v", p)
+	}
+}
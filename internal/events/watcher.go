@@ -0,0 +1,117 @@
+// Package events follows the Docker event stream so container state changes
+// are recorded immediately instead of on the next collector tick.
+package events
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/docker"
+)
+
+type Watcher struct {
+	repo    *db.Repository
+	dc      *docker.Client
+	log     *slog.Logger
+	changed chan<- struct{}
+}
+
+// NewWatcher sends on changed after container state changed. Sends never
+// block, so with a buffered channel bursts of events (and several watchers
+// sharing one channel) coalesce and the receiver should re-read all state.
+func NewWatcher(repo *db.Repository, dc *docker.Client, logger *slog.Logger, changed chan<- struct{}) *Watcher {
+	return &Watcher{repo: repo, dc: dc, log: logger, changed: changed}
+}
+
+// Run follows the event stream until ctx is done, reconnecting with backoff.
+// Each (re)connect signals Changed, since events may have been missed.
+func (w *Watcher) Run(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		start := time.Now()
+		err := w.follow(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		if !errors.Is(err, docker.ErrForbidden) {
+			w.log.Warn("docker event stream closed", "err", err, "retry_in", backoff)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+func (w *Watcher) follow(ctx context.Context) error {
+	stream, err := w.dc.Events(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	w.signal()
+	return docker.DecodeEvents(stream, func(ev docker.Event) error {
+		w.handle(ctx, ev)
+		return nil
+	})
+}
+
+func (w *Watcher) handle(ctx context.Context, ev docker.Event) {
+	if ev.Type != "container" || ev.Actor.ID == "" {
+		return
+	}
+	status, health, ok := stateOf(ev)
+	if !ok {
+		return
+	}
+	if ev.Action == "oom" {
+		w.log.Warn("container out of memory", "container", ev.Actor.Attributes["name"], "id", shortID(ev.Actor.ID))
+	}
+	if err := w.repo.SetContainerState(ctx, ev.Actor.ID, status, health); err != nil {
+		w.log.Warn("record container event", "id", shortID(ev.Actor.ID), "action", ev.Action, "err", err)
+	}
+	w.signal()
+}
+
+// stateOf maps an event to the container status and health it implies.
+// Events that do not change either are ignored.
+func stateOf(ev docker.Event) (status, health string, ok bool) {
+	if h, isHealth := ev.Health(); isHealth {
+		return "", h, true
+	}
+	switch ev.Action {
+	case "start", "restart", "unpause":
+		return "running", "", true
+	case "die", "stop", "kill":
+		return "exited", "", true
+	case "pause":
+		return "paused", "", true
+	case "destroy":
+		return "missing", "", true
+	case "oom":
+		return "", "", true
+	}
+	return "", "", false
+}
+
+func (w *Watcher) signal() {
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,29 @@
+package events
+
+import (
+	"testing"
+
+	"dashi/internal/docker"
+)
+
+func TestStateOf(t *testing.T) {
+	cases := []struct {
+		action, status, health string
+		ok                     bool
+	}{
+		{"start", "running", "", true},
+		{"die", "exited", "", true},
+		{"pause", "paused", "", true},
+		{"destroy", "missing", "", true},
+		{"health_status: unhealthy", "", "unhealthy", true},
+		{"oom", "", "", true},
+		{"exec_start: sh", "", "", false},
+		{"attach", "", "", false},
+	}
+	for _, tc := range cases {
+		status, health, ok := stateOf(docker.Event{Type: "container", Action: tc.action})
+		if status != tc.status || health != tc.health || ok != tc.ok {
+			t.Errorf("%s => (%q, %q, %v), want (%q, %q, %v)", tc.action, status, health, ok, tc.status, tc.health, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Package federation polls the summaries of other dashi instances, e.g.
+// one per site, so one instance can show the hosts and alerts of all.
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+// maxSummary bounds the summary read from a peer.
+const maxSummary = 4 << 20
+
+// Peer is another dashi instance, by the site name it is shown under.
+type Peer struct {
+	Name string
+	// URL is the peer's base URL. Credentials in it are sent as basic
+	// auth, e.g. to a reverse proxy in front of the peer, and not shown.
+	URL string
+}
+
+type Poller struct {
+	repo  *db.Repository
+	http  *http.Client
+	log   *slog.Logger
+	peers []Peer
+	now   func() time.Time
+}
+
+// NewPoller polls the GET /api/v1/summary of each of peers.
+func NewPoller(repo *db.Repository, logger *slog.Logger, peers []Peer) *Poller {
+	return &Poller{repo: repo, http: &http.Client{Timeout: 15 * time.Second}, log: logger, peers: peers, now: time.Now}
+}
+
+// Run forgets the sites no longer configured, then, if there are peers,
+// polls immediately and every interval until ctx is done.
+func (p *Poller) Run(ctx context.Context, every time.Duration) {
+	names := make([]string, 0, len(p.peers))
+	for _, peer := range p.peers {
+		names = append(names, peer.Name)
+	}
+	if err := p.repo.DeleteSitesExcept(ctx, names); err != nil {
+		p.log.Warn("forget removed sites", "err", err)
+	}
+	if len(p.peers) == 0 {
+		return
+	}
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		p.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Poll fetches and records the summary of every peer.
+func (p *Poller) Poll(ctx context.Context) {
+	for _, peer := range p.peers {
+		site := models.Site{Name: peer.Name, URL: displayURL(peer.URL), CheckedAt: p.now().UTC()}
+		raw, err := p.fetch(ctx, peer)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			p.log.Warn("poll site", "site", peer.Name, "err", err)
+			site.Error = err.Error()
+		} else {
+			site.LastOKAt, site.SummaryJSON = &site.CheckedAt, string(raw)
+		}
+		if err := p.repo.SaveSite(ctx, site); err != nil {
+			p.log.Warn("save site", "site", peer.Name, "err", err)
+		}
+	}
+}
+
+// fetch returns the summary of peer, re-encoded so only what this version
+// knows of it is stored.
+func (p *Poller) fetch(ctx context.Context, peer Peer) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, peer.URL+"/api/v1/summary", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "dashi-federation")
+	res, err := p.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		var apiErr api.Error
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			return nil, fmt.Errorf("%s: %s", res.Status, apiErr.Error)
+		}
+		return nil, errors.New(res.Status)
+	}
+	var sum api.Summary
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxSummary)).Decode(&sum); err != nil {
+		return nil, fmt.Errorf("decode summary: %w", err)
+	}
+	return json.Marshal(sum)
+}
+
+// displayURL is u without credentials.
+func displayURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	parsed.User = nil
+	return parsed.String()
+}
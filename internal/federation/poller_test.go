@@ -0,0 +1,75 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/db"
+)
+
+func TestPollRecordsSiteSummaries(t *testing.T) {
+	down := false
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); r.URL.Path != "/api/v1/summary" || user != "dashi" || pass != "secret" {
+			http.NotFound(w, r)
+			return
+		}
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = io.WriteString(w, `{"error": "no database"}`)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(api.Summary{
+			Hosts:        []api.Host{{Name: "local", Containers: 3, Running: 2, ActiveAlerts: 1}},
+			FiringAlerts: 1,
+			TopAlerts:    []api.Alert{{ID: 7, Rule: "High CPU", Status: "firing", Summary: "cpu at 97%"}},
+		})
+	}))
+	defer peer.Close()
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	withAuth := strings.Replace(peer.URL, "http://", "http://dashi:secret@", 1)
+	p := NewPoller(repo, slog.New(slog.NewTextHandler(io.Discard, nil)), []Peer{{Name: "berlin", URL: withAuth}})
+
+	p.Poll(ctx)
+	sites, err := repo.ListSites(ctx)
+	if err != nil || len(sites) != 1 {
+		t.Fatalf("sites = %+v, err %v", sites, err)
+	}
+	site := api.SitesFrom(sites)[0]
+	if !site.Up || site.URL != peer.URL || site.Summary == nil || site.Summary.FiringAlerts != 1 || site.Summary.TopAlerts[0].Summary != "cpu at 97%" {
+		t.Fatalf("site = %+v", site)
+	}
+
+	// An unreachable site keeps its last summary.
+	down = true
+	p.now = func() time.Time { return time.Now().Add(time.Minute) }
+	p.Poll(ctx)
+	sites, _ = repo.ListSites(ctx)
+	site = api.SitesFrom(sites)[0]
+	if site.Up || site.Error != "503 Service Unavailable: no database" || site.Summary == nil || len(site.Summary.Hosts) != 1 || !site.CheckedAt.After(*site.LastOKAt) {
+		t.Fatalf("unreachable site = %+v", site)
+	}
+
+	// Sites removed from the configuration are forgotten.
+	NewPoller(repo, p.log, nil).Run(ctx, time.Minute)
+	if sites, _ := repo.ListSites(ctx); len(sites) != 0 {
+		t.Fatalf("sites after removal = %+v", sites)
+	}
+}
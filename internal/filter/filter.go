@@ -0,0 +1,95 @@
+// Package filter decides which containers dashi monitors. The collector, log
+// ingestor and alert engine share one Filter so a container is either
+// watched everywhere or nowhere.
+package filter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"dashi/internal/db"
+)
+
+// IgnoreLabel excludes a container whenever it is set to "true".
+const IgnoreLabel = "dashi.ignore"
+
+type Filter struct {
+	mu      sync.RWMutex
+	labels  db.LabelSelector
+	include []string
+	exclude []string
+}
+
+// New builds a filter from a label selector and comma-separated name glob
+// patterns (path.Match syntax, e.g. "media-*").
+func New(labels, include, exclude string) (*Filter, error) {
+	f := &Filter{}
+	return f, f.Set(labels, include, exclude)
+}
+
+// Set replaces the filter rules; on error the previous rules stay active.
+func (f *Filter) Set(labels, include, exclude string) error {
+	sel, err := db.ParseLabelSelector(labels)
+	if err != nil {
+		return err
+	}
+	inc, err := Patterns(include)
+	if err != nil {
+		return err
+	}
+	exc, err := Patterns(exclude)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.labels, f.include, f.exclude = sel, inc, exc
+	f.mu.Unlock()
+	return nil
+}
+
+// Allows reports whether a container with this name and labels is
+// monitored. A nil Filter allows everything but ignored containers.
+func (f *Filter) Allows(name string, labels map[string]string) bool {
+	if labels[IgnoreLabel] == "true" {
+		return false
+	}
+	if f == nil {
+		return true
+	}
+	name = strings.TrimPrefix(name, "/")
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if !f.labels.Matches(labels) {
+		return false
+	}
+	if len(f.include) > 0 && !matchAny(f.include, name) {
+		return false
+	}
+	return !matchAny(f.exclude, name)
+}
+
+// Patterns splits and validates a comma-separated list of name globs.
+func Patterns(s string) ([]string, error) {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q", p)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package filter
+
+import "testing"
+
+func TestFilterAllows(t *testing.T) {
+	f, err := New("com.docker.compose.project=media", "", "*-backup")
+	if err != nil {
+		t.Fatalf("new filter: %v", err)
+	}
+	media := map[string]string{"com.docker.compose.project": "media"}
+	cases := []struct {
+		name   string
+		labels map[string]string
+		want   bool
+	}{
+		{"/jellyfin", media, true},
+		{"jellyfin-backup", media, false},
+		{"postgres", map[string]string{"com.docker.compose.project": "db"}, false},
+		{"sonarr", map[string]string{"com.docker.compose.project": "media", IgnoreLabel: "true"}, false},
+	}
+	for _, tc := range cases {
+		if got := f.Allows(tc.name, tc.labels); got != tc.want {
+			t.Errorf("Allows(%q, %v) = %v, want %v", tc.name, tc.labels, got, tc.want)
+		}
+	}
+
+	if err := f.Set("", "web-*,api", ""); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if !f.Allows("web-1", nil) || !f.Allows("api", nil) || f.Allows("worker", nil) {
+		t.Fatal("include patterns not applied")
+	}
+	if err := f.Set("", "[", ""); err == nil {
+		t.Fatal("expected error for invalid pattern")
+	}
+	if !f.Allows("web-1", nil) {
+		t.Fatal("failed Set replaced the previous rules")
+	}
+
+	var none *Filter
+	if !none.Allows("anything", nil) || none.Allows("x", map[string]string{IgnoreLabel: "true"}) {
+		t.Fatal("nil filter should allow all but ignored containers")
+	}
+}
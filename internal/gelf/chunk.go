@@ -0,0 +1,99 @@
+package gelf
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// maxChunks is the most chunks a message may be split into.
+	maxChunks = 128
+	// chunkTimeout is how long the chunks of a message are kept waiting for
+	// the rest.
+	chunkTimeout = 5 * time.Second
+	// maxPending caps the messages being reassembled at once.
+	maxPending = 1024
+)
+
+var chunkMagic = [2]byte{0x1e, 0x0f}
+
+// chunked reports whether a datagram is one chunk of a larger message.
+func chunked(b []byte) bool {
+	return len(b) >= 2 && b[0] == chunkMagic[0] && b[1] == chunkMagic[1]
+}
+
+type pending struct {
+	parts    [][]byte
+	received int
+	size     int
+	first    time.Time
+}
+
+// assembler joins the chunks of messages split across UDP datagrams.
+type assembler struct {
+	mu      sync.Mutex
+	pending map[[8]byte]*pending
+}
+
+func newAssembler() *assembler {
+	return &assembler{pending: map[[8]byte]*pending{}}
+}
+
+// add takes a chunk and returns the message once all of its chunks arrived.
+func (a *assembler) add(b []byte, now time.Time) ([]byte, bool, error) {
+	if len(b) < 12 {
+		return nil, false, errors.New("short chunk")
+	}
+	var id [8]byte
+	copy(id[:], b[2:10])
+	seq, count := int(b[10]), int(b[11])
+	if count == 0 || count > maxChunks || seq >= count {
+		return nil, false, errors.New("invalid chunk sequence")
+	}
+	data := b[12:]
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expire(now)
+	p, ok := a.pending[id]
+	if !ok {
+		if len(a.pending) >= maxPending {
+			return nil, false, errors.New("too many incomplete messages")
+		}
+		p = &pending{parts: make([][]byte, count), first: now}
+		a.pending[id] = p
+	}
+	if len(p.parts) != count {
+		delete(a.pending, id)
+		return nil, false, errors.New("chunk count changed")
+	}
+	if p.parts[seq] != nil {
+		return nil, false, nil
+	}
+	if p.size+len(data) > maxMessageBytes {
+		delete(a.pending, id)
+		return nil, false, errors.New("message too large")
+	}
+	p.parts[seq] = append([]byte(nil), data...)
+	p.received++
+	p.size += len(data)
+	if p.received < count {
+		return nil, false, nil
+	}
+	delete(a.pending, id)
+	msg := make([]byte, 0, p.size)
+	for _, part := range p.parts {
+		msg = append(msg, part...)
+	}
+	return msg, true, nil
+}
+
+// expire drops messages whose chunks did not all arrive in time.
+func (a *assembler) expire(now time.Time) {
+	for id, p := range a.pending {
+		if now.Sub(p.first) > chunkTimeout {
+			delete(a.pending, id)
+		}
+	}
+}
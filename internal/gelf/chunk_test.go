@@ -0,0 +1,49 @@
+package gelf
+
+import (
+	"testing"
+	"time"
+)
+
+func chunk(id byte, seq, count int, data string) []byte {
+	b := []byte{0x1e, 0x0f, id, 0, 0, 0, 0, 0, 0, 0, byte(seq), byte(count)}
+	return append(b, data...)
+}
+
+func TestAssemblerJoinsChunksInAnyOrder(t *testing.T) {
+	a := newAssembler()
+	now := time.Now()
+	for _, c := range [][]byte{chunk(1, 2, 3, `"}`), chunk(1, 0, 3, `{"short_message":`), chunk(2, 0, 2, `x`)} {
+		if _, done, err := a.add(c, now); err != nil || done {
+			t.Fatalf("add: done=%v err=%v", done, err)
+		}
+	}
+	msg, done, err := a.add(chunk(1, 1, 3, `"hi`), now)
+	if err != nil || !done {
+		t.Fatalf("last chunk: done=%v err=%v", done, err)
+	}
+	if string(msg) != `{"short_message":"hi"}` {
+		t.Fatalf("msg = %s", msg)
+	}
+	if len(a.pending) != 1 {
+		t.Fatalf("pending = %d, want the other message", len(a.pending))
+	}
+}
+
+func TestAssemblerExpiresIncompleteMessages(t *testing.T) {
+	a := newAssembler()
+	now := time.Now()
+	a.add(chunk(1, 0, 2, "a"), now)
+	if _, done, _ := a.add(chunk(1, 1, 2, "b"), now.Add(chunkTimeout+time.Second)); done {
+		t.Fatal("message completed from an expired chunk")
+	}
+}
+
+func TestAssemblerRejectsInvalidChunks(t *testing.T) {
+	a := newAssembler()
+	for _, c := range [][]byte{{0x1e, 0x0f, 1}, chunk(1, 2, 2, "x"), chunk(1, 0, 0, "x"), chunk(1, 0, maxChunks+1, "x")} {
+		if _, _, err := a.add(c, time.Now()); err == nil {
+			t.Fatalf("add(%v) succeeded", c)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Package gelf receives logs in the Graylog Extended Log Format, as sent by
+// Docker's gelf log driver, over UDP and HTTP.
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxMessageBytes caps a decompressed message.
+const maxMessageBytes = 1 << 20
+
+// Message is a decoded GELF message. Additional fields keep their leading
+// underscore.
+type Message struct {
+	Host         string
+	ShortMessage string
+	FullMessage  string
+	Timestamp    float64
+	Level        *int
+	Facility     string
+	Extra        map[string]any
+}
+
+// Decode reads a GELF message, gzip or zlib compressed or plain JSON.
+func Decode(b []byte) (Message, error) {
+	var r io.Reader = bytes.NewReader(b)
+	switch {
+	case len(b) >= 2 && b[0] == 0x1f && b[1] == 0x8b:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return Message{}, fmt.Errorf("gzip: %w", err)
+		}
+		r = zr
+	case len(b) >= 1 && b[0] == 0x78:
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return Message{}, fmt.Errorf("zlib: %w", err)
+		}
+		r = zr
+	}
+	raw, err := io.ReadAll(io.LimitReader(r, maxMessageBytes+1))
+	if err != nil {
+		return Message{}, fmt.Errorf("decompress: %w", err)
+	}
+	if len(raw) > maxMessageBytes {
+		return Message{}, errors.New("message too large")
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return Message{}, fmt.Errorf("decode json: %w", err)
+	}
+	m := Message{Extra: map[string]any{}}
+	for k, v := range fields {
+		switch k {
+		case "host":
+			m.Host, _ = v.(string)
+		case "short_message":
+			m.ShortMessage, _ = v.(string)
+		case "full_message":
+			m.FullMessage, _ = v.(string)
+		case "timestamp":
+			m.Timestamp, _ = v.(float64)
+		case "level":
+			if f, ok := v.(float64); ok {
+				n := int(f)
+				m.Level = &n
+			}
+		case "facility":
+			m.Facility, _ = v.(string)
+		default:
+			if strings.HasPrefix(k, "_") {
+				m.Extra[k] = v
+			}
+		}
+	}
+	if m.ShortMessage == "" && m.FullMessage == "" {
+		return Message{}, errors.New("short_message is required")
+	}
+	return m, nil
+}
+
+// Text returns the full message, or the short one when there is none.
+func (m Message) Text() string {
+	if m.FullMessage != "" {
+		return m.FullMessage
+	}
+	return m.ShortMessage
+}
+
+// Time returns the time the message was logged, or now when it carries no
+// timestamp.
+func (m Message) Time(now time.Time) time.Time {
+	if m.Timestamp <= 0 {
+		return now
+	}
+	sec := int64(m.Timestamp)
+	return time.Unix(sec, int64((m.Timestamp-float64(sec))*1e9)).UTC()
+}
+
+// LevelName maps the syslog severity of the message to dashi's levels.
+// Messages without one are INFO.
+func (m Message) LevelName() string {
+	if m.Level == nil {
+		return "INFO"
+	}
+	switch l := *m.Level; {
+	case l <= 3:
+		return "ERROR"
+	case l == 4:
+		return "WARN"
+	case l == 7:
+		return "DEBUG"
+	default:
+		return "INFO"
+	}
+}
+
+// String returns the additional field key (with its underscore) when it
+// is a non-empty string.
+func (m Message) String(key string) string {
+	s, _ := m.Extra[key].(string)
+	return s
+}
+
+// SourceName names the sender of a message from an unknown container: the
+// container name or tag set by Docker, else the application, facility or
+// host.
+func (m Message) SourceName() string {
+	for _, s := range []string{
+		strings.TrimPrefix(m.String("_container_name"), "/"),
+		m.String("_tag"),
+		m.String("_app"),
+		m.Facility,
+		m.Host,
+	} {
+		if s = strings.TrimSpace(s); s != "" {
+			return s
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,73 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"testing"
+	"time"
+)
+
+const sample = `{"version":"1.1","host":"node1","short_message":"boom","full_message":"boom\n  at main","timestamp":1700000000.25,"level":3,"_container_id":"abc","_container_name":"/web","_tag":"t"}`
+
+func TestDecodeCompressed(t *testing.T) {
+	var gz, zl bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(sample))
+	gw.Close()
+	zw := zlib.NewWriter(&zl)
+	zw.Write([]byte(sample))
+	zw.Close()
+
+	for name, b := range map[string][]byte{"plain": []byte(sample), "gzip": gz.Bytes(), "zlib": zl.Bytes()} {
+		m, err := Decode(b)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if m.Text() != "boom\n  at main" || m.LevelName() != "ERROR" || m.String("_container_id") != "abc" {
+			t.Fatalf("%s: unexpected message %+v", name, m)
+		}
+		if got := m.Time(time.Now()); !got.Equal(time.Unix(1700000000, 250e6)) {
+			t.Fatalf("%s: time = %v", name, got)
+		}
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	for _, b := range []string{`not json`, `{"host":"x"}`, `[]`} {
+		if _, err := Decode([]byte(b)); err == nil {
+			t.Fatalf("Decode(%q) succeeded", b)
+		}
+	}
+}
+
+func TestLevelName(t *testing.T) {
+	want := map[int]string{0: "ERROR", 3: "ERROR", 4: "WARN", 5: "INFO", 6: "INFO", 7: "DEBUG"}
+	for level, name := range want {
+		m := Message{Level: &level}
+		if got := m.LevelName(); got != name {
+			t.Fatalf("level %d = %s, want %s", level, got, name)
+		}
+	}
+	if got := (Message{}).LevelName(); got != "INFO" {
+		t.Fatalf("no level = %s, want INFO", got)
+	}
+}
+
+func TestSourceName(t *testing.T) {
+	cases := []struct {
+		m    Message
+		want string
+	}{
+		{Message{Host: "node1", Extra: map[string]any{"_container_name": "/web", "_tag": "t"}}, "web"},
+		{Message{Host: "node1", Extra: map[string]any{"_tag": "t"}}, "t"},
+		{Message{Host: "node1", Facility: "cron", Extra: map[string]any{}}, "cron"},
+		{Message{Host: "node1"}, "node1"},
+		{Message{}, "unknown"},
+	}
+	for _, c := range cases {
+		if got := c.m.SourceName(); got != c.want {
+			t.Fatalf("SourceName(%+v) = %q, want %q", c.m, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package gelf
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/logs"
+	"dashi/internal/models"
+)
+
+const (
+	// Kind is the pseudo host of sources that are not known containers.
+	Kind = "gelf"
+	// lookupTTL is how long the service of a container ID is cached.
+	lookupTTL = time.Minute
+	// maxDatagram is the largest UDP datagram read.
+	maxDatagram = 65536
+)
+
+type lookup struct {
+	serviceID string
+	at        time.Time
+}
+
+// Server turns GELF messages into log entries. Messages carrying the ID of
+// a container dashi already monitors are stored under that container;
+// others under a "gelf" source named after the sender.
+type Server struct {
+	repo   *db.Repository
+	sink   *logs.Sink
+	log    *slog.Logger
+	chunks *assembler
+
+	mu       sync.Mutex
+	services map[string]lookup
+}
+
+func NewServer(repo *db.Repository, sink *logs.Sink, logger *slog.Logger) *Server {
+	return &Server{repo: repo, sink: sink, log: logger, chunks: newAssembler(), services: map[string]lookup{}}
+}
+
+// ListenUDP receives messages on addr until ctx is done.
+func (s *Server) ListenUDP(ctx context.Context, addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	s.log.Info("gelf udp listening", "addr", conn.LocalAddr().String())
+	buf := make([]byte, maxDatagram)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		s.datagram(ctx, buf[:n])
+	}
+}
+
+func (s *Server) datagram(ctx context.Context, b []byte) {
+	if chunked(b) {
+		msg, done, err := s.chunks.add(b, time.Now())
+		if err != nil {
+			s.log.Debug("gelf chunk dropped", "err", err)
+		}
+		if !done {
+			return
+		}
+		b = msg
+	}
+	m, err := Decode(b)
+	if err != nil {
+		s.log.Debug("gelf message dropped", "err", err)
+		return
+	}
+	if err := s.Handle(ctx, m); err != nil {
+		s.log.Warn("store gelf message", "err", err)
+	}
+}
+
+// Handler serves POST /gelf with one message per request body, which may
+// be gzip encoded.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gelf", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var body io.Reader = http.MaxBytesReader(w, r.Body, maxMessageBytes)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(body)
+			if err != nil {
+				http.Error(w, "invalid gzip body", http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		b, err := io.ReadAll(io.LimitReader(body, maxMessageBytes))
+		if err != nil {
+			http.Error(w, "read body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		m, err := Decode(b)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.Handle(r.Context(), m); err != nil {
+			s.log.Warn("store gelf message", "err", err)
+			http.Error(w, "store message", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	return mux
+}
+
+// Handle queues m for storage.
+func (s *Server) Handle(ctx context.Context, m Message) error {
+	e := models.LogEntry{TS: m.Time(time.Now().UTC()), Stream: "gelf", Level: m.LevelName(), Message: m.Text()}
+	if id := m.String("_container_id"); id != "" {
+		svc, err := s.serviceOf(ctx, id)
+		if err != nil {
+			return err
+		}
+		if svc != "" {
+			e.ServiceID, e.ContainerID = svc, id
+			s.sink.Write(e)
+			return nil
+		}
+	}
+	svc, cid, err := s.sink.Source(ctx, logs.Source{Kind: Kind, Name: m.SourceName()})
+	if err != nil {
+		return err
+	}
+	e.ServiceID, e.ContainerID = svc, cid
+	s.sink.Write(e)
+	return nil
+}
+
+// serviceOf returns the service of a monitored container, "" for unknown
+// ones.
+func (s *Server) serviceOf(ctx context.Context, containerID string) (string, error) {
+	now := time.Now()
+	s.mu.Lock()
+	l, ok := s.services[containerID]
+	s.mu.Unlock()
+	if ok && now.Sub(l.at) < lookupTTL {
+		return l.serviceID, nil
+	}
+	svc, err := s.repo.ContainerService(ctx, containerID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+	s.mu.Lock()
+	if len(s.services) > 10000 {
+		clear(s.services)
+	}
+	s.services[containerID] = lookup{serviceID: svc, at: now}
+	s.mu.Unlock()
+	return svc, nil
+}
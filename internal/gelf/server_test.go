@@ -0,0 +1,76 @@
+package gelf
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/logs"
+	"dashi/internal/models"
+)
+
+func TestServerStoresMessages(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	// The sink writes each container on its own goroutine; one connection
+	// keeps a "database is locked" from dropping a batch, as no spill is set.
+	sqldb.SetMaxOpenConns(1)
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	err = repo.UpsertServiceAndContainer(ctx,
+		models.Service{ID: "web@local", Name: "web", Host: "local", LabelsJSON: "{}", Status: "running"},
+		models.Container{ID: "abc", ServiceID: "web@local", Host: "local", Name: "web", Status: "running", LastSeenAt: time.Now()},
+	)
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sink := logs.NewSink(repo, logger, logs.Options{})
+	h := NewServer(repo, sink, logger).Handler()
+	for _, body := range []string{
+		`{"short_message":"known","level":4,"_container_id":"abc"}`,
+		`{"short_message":"other","host":"node1","_container_name":"/cron","_container_id":"zzz"}`,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/gelf", strings.NewReader(body)))
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("POST %s = %d %s", body, rec.Code, rec.Body)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/gelf", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid message = %d, want 400", rec.Code)
+	}
+	if err := sink.Stop(ctx); err != nil {
+		t.Fatalf("stop sink: %v", err)
+	}
+
+	got, err := repo.QueryLogs(ctx, db.LogQuery{Stream: "gelf"})
+	if err != nil {
+		t.Fatalf("query logs: %v", err)
+	}
+	byService := map[string]models.LogEntry{}
+	for _, e := range got {
+		byService[e.ServiceID] = e
+	}
+	if e := byService["web@local"]; e.ContainerID != "abc" || e.Message != "known" || e.Level != "WARN" {
+		t.Fatalf("known container entry = %+v", e)
+	}
+	if e := byService["cron@gelf"]; e.ContainerID != "gelf:cron" || e.Message != "other" || e.Level != "INFO" {
+		t.Fatalf("external entry = %+v (all %+v)", e, got)
+	}
+}
@@ -0,0 +1,238 @@
+// Package logarchive exports logs to S3-compatible storage before retention
+// deletes them, as gzipped NDJSON objects per UTC day and service, and
+// restores such a segment into the database for an investigation.
+package logarchive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+const (
+	pageSize = 5000
+	// segmentLines bounds the lines, and so the memory, of one object; a
+	// busier service and day is split into several.
+	segmentLines = 100000
+	restoreBatch = 1000
+)
+
+// Store is the object storage segments are written to, a replica.S3.
+type Store interface {
+	Put(ctx context.Context, key string, body []byte) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+type Archiver struct {
+	repo   *db.Repository
+	store  Store
+	prefix string
+	log    *slog.Logger
+	now    func() time.Time
+}
+
+// NewArchiver writes segments under prefix, e.g.
+// <prefix>/2026-10-01/web/123.ndjson.gz, named after their first line's ID.
+func NewArchiver(repo *db.Repository, store Store, prefix string, logger *slog.Logger) *Archiver {
+	return &Archiver{repo: repo, store: store, prefix: strings.Trim(prefix, "/"), log: logger, now: time.Now}
+}
+
+// line is an archived log line, one JSON object per line of a segment.
+type line struct {
+	TS        time.Time         `json:"ts"`
+	Service   string            `json:"service"`
+	Container string            `json:"container"`
+	Level     string            `json:"level"`
+	Stream    string            `json:"stream"`
+	Message   string            `json:"message"`
+	Repeat    int               `json:"repeat,omitempty"`
+	LastSeen  *time.Time        `json:"last_seen,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+}
+
+type segment struct {
+	models.LogArchive
+	buf bytes.Buffer
+	gz  *gzip.Writer
+	enc *json.Encoder
+}
+
+func (s *segment) add(l db.ArchiveLog) error {
+	if s.Lines == 0 {
+		s.From = l.TS
+	}
+	s.From, s.To = minTime(s.From, l.TS), maxTime(s.To, l.TS)
+	s.Lines++
+	out := line{TS: l.TS.UTC(), Service: l.ServiceID, Container: l.ContainerID, Level: l.Level, Stream: l.Stream,
+		Message: l.Message, LastSeen: l.LastSeen, Fields: l.Fields}
+	if l.RepeatCount > 1 {
+		out.Repeat = l.RepeatCount
+	}
+	return s.enc.Encode(out)
+}
+
+// Archive uploads the logs older than cutoff that earlier runs have not
+// archived. Segments are only recorded once all of them are uploaded, so
+// on an error, when the logs must be kept, the next run writes the same
+// objects again.
+func (a *Archiver) Archive(ctx context.Context, cutoff time.Time) error {
+	from, err := a.repo.LogArchiveCutoff(ctx)
+	if err != nil {
+		return fmt.Errorf("read archive cutoff: %w", err)
+	}
+	if !from.Before(cutoff) {
+		return nil
+	}
+	var done []models.LogArchive
+	open := map[[2]string]*segment{}
+	flush := func(seg *segment) error {
+		if err := seg.gz.Close(); err != nil {
+			return err
+		}
+		seg.Bytes = int64(seg.buf.Len())
+		if err := a.store.Put(ctx, seg.Key, seg.buf.Bytes()); err != nil {
+			return fmt.Errorf("upload %s: %w", seg.Key, err)
+		}
+		seg.Cutoff, seg.CreatedAt = cutoff, a.now()
+		done = append(done, seg.LogArchive)
+		delete(open, [2]string{seg.Day, seg.ServiceID})
+		return nil
+	}
+	var afterID int64
+	for {
+		page, err := a.repo.LogsToArchive(ctx, from, cutoff, afterID, pageSize)
+		if err != nil {
+			return fmt.Errorf("read logs: %w", err)
+		}
+		for _, l := range page {
+			day := l.TS.UTC().Format(time.DateOnly)
+			seg := open[[2]string{day, l.ServiceID}]
+			if seg == nil {
+				seg = &segment{LogArchive: models.LogArchive{Day: day, ServiceID: l.ServiceID, Key: a.key(day, l.ServiceID, l.ID)}}
+				seg.gz = gzip.NewWriter(&seg.buf)
+				seg.enc = json.NewEncoder(seg.gz)
+				open[[2]string{day, l.ServiceID}] = seg
+			}
+			if err := seg.add(l); err != nil {
+				return err
+			}
+			if seg.Lines >= segmentLines {
+				if err := flush(seg); err != nil {
+					return err
+				}
+			}
+		}
+		if len(page) < pageSize {
+			break
+		}
+		afterID = page[len(page)-1].ID
+	}
+	rest := make([]*segment, 0, len(open))
+	for _, seg := range open {
+		rest = append(rest, seg)
+	}
+	sort.Slice(rest, func(i, j int) bool { return rest[i].Key < rest[j].Key })
+	for _, seg := range rest {
+		if err := flush(seg); err != nil {
+			return err
+		}
+	}
+	if err := a.repo.InsertLogArchives(ctx, done); err != nil {
+		return fmt.Errorf("record archive segments: %w", err)
+	}
+	if len(done) > 0 {
+		lines := 0
+		for _, seg := range done {
+			lines += seg.Lines
+		}
+		a.log.Info("logs archived", "segments", len(done), "lines", lines, "cutoff", cutoff)
+	}
+	return nil
+}
+
+// key names a segment's object. Service IDs may hold a '/' of a file
+// source, which is replaced to keep one level per service.
+func (a *Archiver) key(day, serviceID string, firstID int64) string {
+	name := fmt.Sprintf("%s/%s/%d.ndjson.gz", day, strings.ReplaceAll(serviceID, "/", "_"), firstID)
+	if a.prefix == "" {
+		return name
+	}
+	return a.prefix + "/" + name
+}
+
+// Restore stores the lines of the archive segment id in the database
+// again. It returns sql.ErrNoRows for unknown segments. Lines of containers
+// dashi no longer knows are skipped.
+func (a *Archiver) Restore(ctx context.Context, id int64) (restored, skipped int, err error) {
+	seg, err := a.repo.LogArchive(ctx, id)
+	if err != nil {
+		return 0, 0, err
+	}
+	body, err := a.store.Get(ctx, seg.Key)
+	if err != nil {
+		return 0, 0, fmt.Errorf("download %s: %w", seg.Key, err)
+	}
+	defer body.Close()
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return 0, 0, fmt.Errorf("read %s: %w", seg.Key, err)
+	}
+	sc := bufio.NewScanner(gz)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	batch := make([]models.LogEntry, 0, restoreBatch)
+	store := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, s, err := a.repo.RestoreLogs(ctx, batch)
+		restored, skipped = restored+n, skipped+s
+		batch = batch[:0]
+		return err
+	}
+	for sc.Scan() {
+		var l line
+		if err := json.Unmarshal(sc.Bytes(), &l); err != nil {
+			return restored, skipped, fmt.Errorf("read %s: %w", seg.Key, err)
+		}
+		batch = append(batch, models.LogEntry{TS: l.TS, ServiceID: l.Service, ContainerID: l.Container, Level: l.Level, Stream: l.Stream,
+			Message: l.Message, RepeatCount: max(l.Repeat, 1), LastSeen: l.LastSeen, Fields: l.Fields})
+		if len(batch) == restoreBatch {
+			if err := store(); err != nil {
+				return restored, skipped, err
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return restored, skipped, fmt.Errorf("read %s: %w", seg.Key, err)
+	}
+	if err := store(); err != nil {
+		return restored, skipped, err
+	}
+	a.log.Info("log archive restored", "key", seg.Key, "restored", restored, "skipped", skipped)
+	return restored, skipped, nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,122 @@
+package logarchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+type memStore struct {
+	objects map[string][]byte
+	fail    bool
+}
+
+func (m *memStore) Put(_ context.Context, key string, body []byte) error {
+	if m.fail {
+		return errors.New("bucket unavailable")
+	}
+	m.objects[key] = bytes.Clone(body)
+	return nil
+}
+
+func (m *memStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	b, ok := m.objects[key]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+func TestArchiveAndRestore(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	for _, id := range []string{"web", "db"} {
+		svc := models.Service{ID: id, Name: id, Image: "img", LabelsJSON: "{}", Status: "running"}
+		if err := repo.UpsertServiceAndContainer(ctx, svc, models.Container{ID: id + "1", ServiceID: id, Name: id, Status: "running"}); err != nil {
+			t.Fatalf("store container: %v", err)
+		}
+	}
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	if err := repo.InsertLogs(ctx, []models.LogEntry{
+		{TS: day.Add(23 * time.Hour), ServiceID: "web", ContainerID: "web1", Level: "INFO", Stream: "stdout", Message: "GET /", Fields: map[string]string{"status": "200"}},
+		{TS: day.Add(25 * time.Hour), ServiceID: "web", ContainerID: "web1", Level: "ERROR", Stream: "stderr", Message: "panic"},
+		{TS: day.Add(26 * time.Hour), ServiceID: "db", ContainerID: "db1", Level: "INFO", Stream: "stdout", Message: "checkpoint"},
+		{TS: day.Add(50 * time.Hour), ServiceID: "web", ContainerID: "web1", Level: "INFO", Stream: "stdout", Message: "recent"},
+	}); err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+	store := &memStore{objects: map[string][]byte{}, fail: true}
+	a := NewArchiver(repo, store, "/dashi/logs/", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	cutoff := day.Add(48 * time.Hour)
+
+	if err := a.Archive(ctx, cutoff); err == nil {
+		t.Fatal("archive with a failing store succeeded")
+	}
+	if segs, _ := repo.LogArchives(ctx, "", "", "", 0); len(segs) != 0 {
+		t.Fatalf("segments recorded after a failed upload: %+v", segs)
+	}
+
+	store.fail = false
+	if err := a.Archive(ctx, cutoff); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	keys := make([]string, 0, len(store.objects))
+	for k := range store.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) != 3 || !strings.HasPrefix(keys[0], "dashi/logs/2026-10-01/web/") || !strings.HasPrefix(keys[1], "dashi/logs/2026-10-02/db/") ||
+		!strings.HasPrefix(keys[2], "dashi/logs/2026-10-02/web/") {
+		t.Fatalf("objects = %v", keys)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(store.objects[keys[0]]))
+	if err != nil {
+		t.Fatalf("gunzip: %v", err)
+	}
+	body, _ := io.ReadAll(gz)
+	if !strings.Contains(string(body), `"message":"GET /"`) || !strings.Contains(string(body), `"fields":{"status":"200"}`) || strings.Count(string(body), "\n") != 1 {
+		t.Fatalf("segment = %s", body)
+	}
+	segs, err := repo.LogArchives(ctx, "2026-10-02", "", "web", 0)
+	if err != nil || len(segs) != 1 || segs[0].Lines != 1 || segs[0].Key != keys[2] {
+		t.Fatalf("segments = %+v, err %v", segs, err)
+	}
+
+	// A later run only archives what the earlier one did not.
+	if err := a.Archive(ctx, cutoff.Add(time.Hour)); err != nil {
+		t.Fatalf("second archive: %v", err)
+	}
+	if all, _ := repo.LogArchives(ctx, "", "", "", 0); len(all) != 3 {
+		t.Fatalf("segments after a second run = %d", len(all))
+	}
+
+	if err := repo.DeleteLogsOlderThan(ctx, cutoff); err != nil {
+		t.Fatalf("delete logs: %v", err)
+	}
+	restored, skipped, err := a.Restore(ctx, segs[0].ID)
+	if err != nil || restored != 1 || skipped != 0 {
+		t.Fatalf("restore = %d, %d, %v", restored, skipped, err)
+	}
+	got, err := repo.QueryLogs(ctx, db.LogQuery{ServiceID: "web"})
+	if err != nil || len(got) != 2 || got[1].Message != "panic" || got[1].Level != "ERROR" || !got[1].TS.Equal(day.Add(25*time.Hour)) {
+		t.Fatalf("logs after restore = %+v, err %v", got, err)
+	}
+}
@@ -0,0 +1,155 @@
+package logs
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// accessFormats are the values of FormatLabel whose lines are parsed as
+// HTTP access logs.
+var accessFormats = map[string]bool{"nginx": true, "apache": true, "caddy": true, "traefik": true}
+
+var (
+	// combinedLine matches the Common and Combined Log Formats of nginx,
+	// Apache and Traefik, capturing anything after them.
+	combinedLine = regexp.MustCompile(`^(\S+) \S+ (\S+) \[[^\]]+\] "(\S+) (\S+)[^"]*" ([1-5][0-9]{2}) (\d+|-)(?: "[^"]*" "[^"]*")?(.*)$`)
+	// Request times appended to combined lines: Traefik's "123ms", nginx
+	// "rt=0.123" or "request_time=0.123", or a bare number of seconds.
+	trailingMS      = regexp.MustCompile(`\s(\d+(?:\.\d+)?)ms$`)
+	trailingSeconds = regexp.MustCompile(`(?:^|\s)(?:rt=|request_time=)?(\d+\.\d+)$`)
+)
+
+// AccessRecord is one request of an HTTP access log.
+type AccessRecord struct {
+	Client string
+	User   string
+	Method string
+	Path   string
+	Status int
+	Bytes  int64
+	// Duration is zero when the line does not carry the request time.
+	Duration time.Duration
+}
+
+// Fields returns the record as log entry fields, which request metrics
+// and field searches read.
+func (a AccessRecord) Fields() map[string]string {
+	f := map[string]string{
+		"method": a.Method,
+		"path":   a.Path,
+		"status": strconv.Itoa(a.Status),
+		"bytes":  strconv.FormatInt(a.Bytes, 10),
+	}
+	if a.Client != "" {
+		f["client"] = a.Client
+	}
+	if a.User != "" && a.User != "-" {
+		f["user"] = a.User
+	}
+	if a.Duration > 0 {
+		f["duration_ms"] = strconv.FormatFloat(float64(a.Duration)/float64(time.Millisecond), 'f', -1, 64)
+	}
+	return f
+}
+
+// parseAccess reads msg as a request in the access log format of the
+// given FormatLabel value.
+func parseAccess(format, msg string) (AccessRecord, bool) {
+	switch format {
+	case "nginx", "apache":
+		return parseCombined(msg)
+	case "traefik":
+		if strings.HasPrefix(msg, "{") {
+			return parseTraefikJSON(msg)
+		}
+		return parseCombined(msg)
+	case "caddy":
+		return parseCaddy(msg)
+	}
+	return AccessRecord{}, false
+}
+
+func parseCombined(msg string) (AccessRecord, bool) {
+	m := combinedLine.FindStringSubmatch(msg)
+	if m == nil {
+		return AccessRecord{}, false
+	}
+	a := AccessRecord{Client: m[1], User: m[2], Method: m[3], Path: stripQuery(m[4])}
+	a.Status, _ = strconv.Atoi(m[5])
+	a.Bytes, _ = strconv.ParseInt(m[6], 10, 64)
+	rest := strings.TrimSpace(m[7])
+	if t := trailingMS.FindStringSubmatch(" " + rest); t != nil {
+		ms, _ := strconv.ParseFloat(t[1], 64)
+		a.Duration = time.Duration(ms * float64(time.Millisecond))
+	} else if t := trailingSeconds.FindStringSubmatch(rest); t != nil {
+		sec, _ := strconv.ParseFloat(t[1], 64)
+		a.Duration = time.Duration(sec * float64(time.Second))
+	}
+	return a, true
+}
+
+// parseCaddy reads Caddy's JSON access log, where duration is in seconds.
+func parseCaddy(msg string) (AccessRecord, bool) {
+	if !strings.HasPrefix(msg, "{") {
+		return AccessRecord{}, false
+	}
+	var line struct {
+		Request *struct {
+			RemoteIP string `json:"remote_ip"`
+			Method   string `json:"method"`
+			URI      string `json:"uri"`
+		} `json:"request"`
+		UserID   string  `json:"user_id"`
+		Duration float64 `json:"duration"`
+		Size     int64   `json:"size"`
+		Status   int     `json:"status"`
+	}
+	if json.Unmarshal([]byte(msg), &line) != nil || line.Request == nil || line.Status == 0 {
+		return AccessRecord{}, false
+	}
+	return AccessRecord{
+		Client:   line.Request.RemoteIP,
+		User:     line.UserID,
+		Method:   line.Request.Method,
+		Path:     stripQuery(line.Request.URI),
+		Status:   line.Status,
+		Bytes:    line.Size,
+		Duration: time.Duration(line.Duration * float64(time.Second)),
+	}, true
+}
+
+// parseTraefikJSON reads Traefik's JSON access log, where Duration is in
+// nanoseconds.
+func parseTraefikJSON(msg string) (AccessRecord, bool) {
+	var line struct {
+		ClientHost            string `json:"ClientHost"`
+		ClientUsername        string `json:"ClientUsername"`
+		RequestMethod         string `json:"RequestMethod"`
+		RequestPath           string `json:"RequestPath"`
+		DownstreamStatus      int    `json:"DownstreamStatus"`
+		DownstreamContentSize int64  `json:"DownstreamContentSize"`
+		Duration              int64  `json:"Duration"`
+	}
+	if json.Unmarshal([]byte(msg), &line) != nil || line.DownstreamStatus == 0 {
+		return AccessRecord{}, false
+	}
+	return AccessRecord{
+		Client:   line.ClientHost,
+		User:     line.ClientUsername,
+		Method:   line.RequestMethod,
+		Path:     stripQuery(line.RequestPath),
+		Status:   line.DownstreamStatus,
+		Bytes:    line.DownstreamContentSize,
+		Duration: time.Duration(line.Duration),
+	}, true
+}
+
+// stripQuery drops the query string, which would make every path unique
+// and may carry tokens.
+func stripQuery(uri string) string {
+	path, _, _ := strings.Cut(uri, "?")
+	return path
+}
@@ -0,0 +1,90 @@
+package logs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAccess(t *testing.T) {
+	cases := []struct {
+		format, msg string
+		want        AccessRecord
+	}{
+		{
+			"nginx",
+			`10.0.0.7 - alice [21/Feb/2026:12:00:00 +0000] "GET /api/items?token=x HTTP/1.1" 502 157 "-" "curl/8.5.0" rt=0.250`,
+			AccessRecord{Client: "10.0.0.7", User: "alice", Method: "GET", Path: "/api/items", Status: 502, Bytes: 157, Duration: 250 * time.Millisecond},
+		},
+		{
+			"apache",
+			`192.168.1.2 - - [21/Feb/2026:12:00:00 +0100] "POST /login HTTP/1.1" 302 -`,
+			AccessRecord{Client: "192.168.1.2", User: "-", Method: "POST", Path: "/login", Status: 302},
+		},
+		{
+			"traefik",
+			`10.0.0.1 - - [21/Feb/2026:12:00:00 +0000] "GET /health HTTP/2.0" 200 2 "-" "kube-probe" 42 "web@docker" "http://172.18.0.3:80" 7ms`,
+			AccessRecord{Client: "10.0.0.1", User: "-", Method: "GET", Path: "/health", Status: 200, Bytes: 2, Duration: 7 * time.Millisecond},
+		},
+		{
+			"traefik",
+			`{"ClientHost":"10.0.0.1","DownstreamContentSize":512,"DownstreamStatus":404,"Duration":1500000,"RequestMethod":"GET","RequestPath":"/missing?x=1"}`,
+			AccessRecord{Client: "10.0.0.1", Method: "GET", Path: "/missing", Status: 404, Bytes: 512, Duration: 1500 * time.Microsecond},
+		},
+		{
+			"caddy",
+			`{"level":"info","logger":"http.log.access","msg":"handled request","request":{"remote_ip":"10.0.0.9","method":"PUT","uri":"/v1/files/a"},"user_id":"","duration":0.012,"size":0,"status":201}`,
+			AccessRecord{Client: "10.0.0.9", Method: "PUT", Path: "/v1/files/a", Status: 201, Duration: 12 * time.Millisecond},
+		},
+	}
+	for _, tc := range cases {
+		got, ok := parseAccess(tc.format, tc.msg)
+		if !ok {
+			t.Errorf("%s: %q not parsed", tc.format, tc.msg)
+			continue
+		}
+		if got.Duration.Round(time.Microsecond) != tc.want.Duration {
+			t.Errorf("%s: duration = %v, want %v", tc.format, got.Duration, tc.want.Duration)
+		}
+		got.Duration = tc.want.Duration
+		if got != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.format, got, tc.want)
+		}
+	}
+	for _, tc := range []struct{ format, msg string }{
+		{"nginx", `2026/02/21 12:00:00 [error] 29#29: *1 connect() failed`},
+		{"caddy", `{"level":"info","msg":"serving initial configuration"}`},
+		{"traefik", `time="2026-02-21T12:00:00Z" level=error msg="provider error"`},
+		{"json", `{"status":200}`},
+	} {
+		if a, ok := parseAccess(tc.format, tc.msg); ok {
+			t.Errorf("%s: %q parsed as %+v", tc.format, tc.msg, a)
+		}
+	}
+}
+
+func TestAccessRules(t *testing.T) {
+	r, err := RulesFromLabels(map[string]string{FormatLabel: "caddy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := `{"level":"info","ts":1771675200.5,"request":{"method":"GET","uri":"/"},"duration":0.002,"size":10,"status":503}`
+	if lvl := r.Level(msg); lvl != "ERROR" {
+		t.Fatalf("level = %s, want ERROR from the status", lvl)
+	}
+	a, ok := r.Access(msg)
+	if !ok {
+		t.Fatal("access line not parsed")
+	}
+	want := map[string]string{"method": "GET", "path": "/", "status": "503", "bytes": "10", "duration_ms": "2"}
+	if got := a.Fields(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("fields = %v, want %v", got, want)
+	}
+	if _, ok := (*Rules)(nil).Access(msg); ok {
+		t.Fatal("nil rules parsed an access line")
+	}
+	apache, _ := RulesFromLabels(map[string]string{FormatLabel: "apache"})
+	if lvl := apache.Level(`[Sat Feb 21 12:00:00.000000 2026] [core:warn] [pid 7] AH00098: pid file overwritten`); lvl != "WARN" {
+		t.Fatalf("apache error log level = %s, want WARN", lvl)
+	}
+}
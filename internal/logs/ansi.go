@@ -0,0 +1,27 @@
+package logs
+
+import "regexp"
+
+var (
+	// ansiEscape matches CSI sequences (colors, cursor movement, erasing),
+	// OSC sequences (window titles, hyperlinks) and other two-byte escapes.
+	ansiEscape = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)?|[@-Z\\-_])`)
+	// ansiSGR matches the select graphic rendition (color) sequences only.
+	ansiSGR = regexp.MustCompile(`^\x1b\[[0-9;]*m$`)
+)
+
+// StripANSI removes terminal escape sequences from s.
+func StripANSI(s string) string {
+	return ansiEscape.ReplaceAllString(s, "")
+}
+
+// stripANSIExceptColors removes terminal escape sequences from s but keeps
+// color codes, which the log view renders.
+func stripANSIExceptColors(s string) string {
+	return ansiEscape.ReplaceAllStringFunc(s, func(seq string) string {
+		if ansiSGR.MatchString(seq) {
+			return seq
+		}
+		return ""
+	})
+}
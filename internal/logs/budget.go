@@ -0,0 +1,92 @@
+package logs
+
+import (
+	"sync"
+
+	"dashi/internal/models"
+	"dashi/internal/selfmon"
+)
+
+// Budget bounds the memory of log lines waiting to be stored across all
+// pipelines. A nil Budget is unlimited.
+type Budget struct {
+	max int64
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	used int64
+}
+
+// NewBudget creates a budget of maxBytes.
+func NewBudget(maxBytes int64) *Budget {
+	b := &Budget{max: maxBytes}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Used returns the bytes held and the budget.
+func (b *Budget) Used() (used, max int64) {
+	if b == nil {
+		return 0, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used, b.max
+}
+
+// take holds n bytes unless that exceeds the budget. A line is always
+// admitted into an empty budget, so one larger than the budget does not
+// block its pipeline for good.
+func (b *Budget) take(n int64) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used > 0 && b.used+n > b.max {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+// wait holds n bytes once other pipelines have given back enough.
+func (b *Budget) wait(n int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.used > 0 && b.used+n > b.max {
+		b.cond.Wait()
+	}
+	b.used += n
+}
+
+func (b *Budget) give(n int64) {
+	if b == nil || n == 0 {
+		return
+	}
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// entryCost estimates the memory of a queued line: its strings and fields
+// plus the entry itself.
+func entryCost(e models.LogEntry) int64 {
+	n := 160 + len(e.ServiceID) + len(e.ContainerID) + len(e.Level) + len(e.Stream) + len(e.Message)
+	for k, v := range e.Fields {
+		n += 32 + len(k) + len(v)
+	}
+	return int64(n)
+}
+
+// overflow counts a line dropped because its pipeline could not keep up.
+func overflow(containerID string) {
+	// The line was read all the same.
+	selfmon.LogLines.Add(1)
+	selfmon.LogOverflow.Add(1)
+	selfmon.LogOverflowByContainer.Add(containerID, 1)
+}
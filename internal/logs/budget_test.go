@@ -0,0 +1,84 @@
+package logs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+	"dashi/internal/selfmon"
+)
+
+func TestBudgetWaitsForRoom(t *testing.T) {
+	b := NewBudget(100)
+	if !b.take(60) || b.take(60) {
+		t.Fatalf("budget of 100 admitted 60 twice or not at all")
+	}
+	taken := make(chan struct{})
+	go func() {
+		b.wait(60)
+		close(taken)
+	}()
+	select {
+	case <-taken:
+		t.Fatalf("wait returned while the budget was spent")
+	case <-time.After(50 * time.Millisecond):
+	}
+	b.give(60)
+	<-taken
+	if used, max := b.Used(); used != 60 || max != 100 {
+		t.Fatalf("used %d of %d, want 60 of 100", used, max)
+	}
+
+	// A line larger than the budget still gets through on its own.
+	big := NewBudget(10)
+	if !big.take(50) {
+		t.Fatalf("empty budget refused a large line")
+	}
+	var unlimited *Budget
+	if !unlimited.take(1 << 40) {
+		t.Fatalf("nil budget refused a line")
+	}
+}
+
+func TestSinkCountsOverflowAndReleasesBudget(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+	if err := repo.UpsertServiceAndContainer(ctx, models.Service{ID: "svc", Name: "svc", LabelsJSON: "{}"}, models.Container{ID: "loud", ServiceID: "svc", Name: "loud"}); err != nil {
+		t.Fatalf("store container: %v", err)
+	}
+
+	// The first line spends the budget until its batch is written, two
+	// seconds later, so the others are dropped.
+	budget := NewBudget(1)
+	sink := NewSink(repo, logger, Options{Budget: budget, QueueSize: 1})
+	before := selfmon.Overflows()["loud"]
+	for _, msg := range []string{"first", "second", "third"} {
+		sink.Write(models.LogEntry{ServiceID: "svc", ContainerID: "loud", Stream: "stdout", Message: msg})
+	}
+	if n := selfmon.Overflows()["loud"] - before; n != 2 {
+		t.Fatalf("overflow = %d, want 2", n)
+	}
+	if err := sink.Stop(ctx); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if used, _ := budget.Used(); used != 0 {
+		t.Fatalf("budget holds %d bytes after the batch was written", used)
+	}
+	var stored int
+	if err := sqldb.QueryRow(`SELECT COUNT(*) FROM logs WHERE container_id = 'loud'`).Scan(&stored); err != nil || stored != 1 {
+		t.Fatalf("stored %d lines, err %v, want 1", stored, err)
+	}
+}
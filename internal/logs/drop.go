@@ -0,0 +1,146 @@
+package logs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"dashi/internal/models"
+)
+
+// DropRulesKey is the setting holding the drop rules as a JSON array.
+const DropRulesKey = "logs.drop_rules"
+
+// DropRule discards matching log lines before they are stored. Every set
+// field has to match.
+type DropRule struct {
+	Name string `json:"name"`
+	// Service is a name glob (path.Match syntax) matched against the
+	// service, with or without its "@host" suffix.
+	Service string `json:"service,omitempty"`
+	// Level is DEBUG, INFO, WARN or ERROR.
+	Level string `json:"level,omitempty"`
+	// Pattern is a regular expression matched against the message.
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// DropCount is a rule with the number of lines it dropped since start.
+type DropCount struct {
+	DropRule
+	Dropped int64
+}
+
+type dropRule struct {
+	DropRule
+	re      *regexp.Regexp
+	dropped *atomic.Int64
+}
+
+// Dropper applies the drop rules for all log ingestors.
+type Dropper struct {
+	mu    sync.RWMutex
+	rules []dropRule
+	// counts outlive rule updates, so editing a rule keeps its count.
+	counts map[string]*atomic.Int64
+}
+
+func NewDropper() *Dropper {
+	return &Dropper{counts: map[string]*atomic.Int64{}}
+}
+
+// ParseDropRules decodes and validates the value of DropRulesKey.
+func ParseDropRules(raw json.RawMessage) ([]DropRule, error) {
+	var rules []DropRule
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, errors.New("must be an array of drop rules")
+	}
+	seen := map[string]bool{}
+	for i, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d: name is required", i+1)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("rule %q: duplicate name", r.Name)
+		}
+		seen[r.Name] = true
+		if r.Service == "" && r.Level == "" && r.Pattern == "" {
+			return nil, fmt.Errorf("rule %q: set service, level or pattern", r.Name)
+		}
+		if _, err := path.Match(r.Service, ""); err != nil {
+			return nil, fmt.Errorf("rule %q: invalid service pattern %q", r.Name, r.Service)
+		}
+		switch strings.ToUpper(r.Level) {
+		case "", "DEBUG", "INFO", "WARN", "ERROR":
+			rules[i].Level = strings.ToUpper(r.Level)
+		default:
+			return nil, fmt.Errorf("rule %q: level must be DEBUG, INFO, WARN or ERROR", r.Name)
+		}
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+	}
+	return rules, nil
+}
+
+// Set replaces the rules. They are expected to come from ParseDropRules.
+func (d *Dropper) Set(rules []DropRule) {
+	compiled := make([]dropRule, 0, len(rules))
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, r := range rules {
+		n, ok := d.counts[r.Name]
+		if !ok {
+			n = new(atomic.Int64)
+			d.counts[r.Name] = n
+		}
+		dr := dropRule{DropRule: r, dropped: n}
+		if r.Pattern != "" {
+			dr.re = regexp.MustCompile(r.Pattern)
+		}
+		compiled = append(compiled, dr)
+	}
+	d.rules = compiled
+}
+
+// Drop reports whether e matches a rule, counting it for the first match.
+// A nil Dropper drops nothing.
+func (d *Dropper) Drop(e models.LogEntry) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, r := range d.rules {
+		if r.matches(e) {
+			r.dropped.Add(1)
+			return true
+		}
+	}
+	return false
+}
+
+func (r dropRule) matches(e models.LogEntry) bool {
+	if r.Level != "" && r.Level != e.Level {
+		return false
+	}
+	if !matchService(r.Service, e.ServiceID) {
+		return false
+	}
+	return r.re == nil || r.re.MatchString(e.Message)
+}
+
+// Counts returns the current rules with their drop counts.
+func (d *Dropper) Counts() []DropCount {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	out := make([]DropCount, 0, len(d.rules))
+	for _, r := range d.rules {
+		out = append(out, DropCount{DropRule: r.DropRule, Dropped: r.dropped.Load()})
+	}
+	return out
+}
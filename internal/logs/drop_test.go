@@ -0,0 +1,61 @@
+package logs
+
+import (
+	"encoding/json"
+	"testing"
+
+	"dashi/internal/models"
+)
+
+func TestDropper(t *testing.T) {
+	rules, err := ParseDropRules(json.RawMessage(`[
+		{"name": "healthchecks", "service": "nginx*", "pattern": "GET /health"},
+		{"name": "debug", "level": "debug"}
+	]`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	d := NewDropper()
+	d.Set(rules)
+	cases := []struct {
+		entry models.LogEntry
+		want  bool
+	}{
+		{models.LogEntry{ServiceID: "nginx", Level: "INFO", Message: `10.0.0.1 "GET /healthz HTTP/1.1" 200`}, true},
+		{models.LogEntry{ServiceID: "nginx-proxy@nas", Level: "INFO", Message: `"GET /health HTTP/1.1" 200`}, true},
+		{models.LogEntry{ServiceID: "nginx", Level: "INFO", Message: `"GET / HTTP/1.1" 200`}, false},
+		{models.LogEntry{ServiceID: "api", Level: "INFO", Message: `GET /health`}, false},
+		{models.LogEntry{ServiceID: "api", Level: "DEBUG", Message: `cache miss`}, true},
+	}
+	for _, tc := range cases {
+		if got := d.Drop(tc.entry); got != tc.want {
+			t.Errorf("Drop(%+v) = %v, want %v", tc.entry, got, tc.want)
+		}
+	}
+
+	// Counts survive an update that keeps the rule.
+	d.Set(rules[:1])
+	counts := d.Counts()
+	if len(counts) != 1 || counts[0].Name != "healthchecks" || counts[0].Dropped != 2 {
+		t.Fatalf("counts = %+v", counts)
+	}
+	if (*Dropper)(nil).Drop(cases[0].entry) {
+		t.Fatal("nil dropper dropped a line")
+	}
+}
+
+func TestParseDropRulesInvalid(t *testing.T) {
+	for _, raw := range []string{
+		`{}`,
+		`[{"service": "x"}]`,
+		`[{"name": "all"}]`,
+		`[{"name": "a", "level": "loud"}]`,
+		`[{"name": "a", "pattern": "("}]`,
+		`[{"name": "a", "service": "["}]`,
+		`[{"name": "a", "level": "info"}, {"name": "a", "level": "warn"}]`,
+	} {
+		if _, err := ParseDropRules(json.RawMessage(raw)); err == nil {
+			t.Errorf("ParseDropRules(%s) succeeded", raw)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package logs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+// ExtractRulesKey is the setting holding the field extraction rules as a
+// JSON array.
+const ExtractRulesKey = "logs.extract_rules"
+
+// grokPatterns are the named patterns an extraction pattern can refer to
+// as %{NAME} or, to capture the match as a field, %{NAME:field}.
+var grokPatterns = map[string]string{
+	"WORD":         `\b\w+\b`,
+	"NOTSPACE":     `\S+`,
+	"DATA":         `.*?`,
+	"GREEDYDATA":   `.*`,
+	"INT":          `[+-]?[0-9]+`,
+	"NUMBER":       `[+-]?[0-9]+(?:\.[0-9]+)?`,
+	"IP":           `[0-9A-Fa-f:.]+`,
+	"HOSTNAME":     `[0-9A-Za-z][0-9A-Za-z.\-]*`,
+	"USER":         `[A-Za-z0-9._@\-]+`,
+	"METHOD":       `[A-Z]+`,
+	"URIPATH":      `/[^\s?#]*`,
+	"URIPATHPARAM": `/[^\s#]*`,
+	"QS":           `"[^"]*"`,
+	"HTTPDATE":     `[0-9]{2}/[A-Za-z]{3}/[0-9]{4}:[0-9:]{8} [+\-][0-9]{4}`,
+	"ISO8601":      `[0-9]{4}-[0-9]{2}-[0-9]{2}[T ][0-9:]{8}(?:\.[0-9]+)?(?:Z|[+\-][0-9:]{4,5})?`,
+	"LOGLEVEL":     `(?i:trace|debug|info|notice|warn(?:ing)?|error|err|crit(?:ical)?|fatal|panic)`,
+	"DURATION":     `[0-9]+(?:\.[0-9]+)?(?:ns|us|µs|ms|s|m|h)`,
+	"UUID":         `[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}`,
+}
+
+var grokRef = regexp.MustCompile(`%\{(\w+)(?::(\w+))?\}`)
+
+// maxFieldBytes caps the value of an extracted field.
+const maxFieldBytes = 256
+
+// ExtractRule parses fields out of the messages of matching services.
+type ExtractRule struct {
+	Name string `json:"name"`
+	// Service is a name glob (path.Match syntax) matched against the
+	// service, with or without its "@host" suffix; empty matches all.
+	Service string `json:"service,omitempty"`
+	// Pattern is a regular expression whose named groups become fields.
+	// %{NAME:field} expands to the built-in pattern NAME captured as field,
+	// e.g. `%{IP:client} .* "%{METHOD:method} %{URIPATH:path}`.
+	Pattern string `json:"pattern"`
+}
+
+type extractRule struct {
+	ExtractRule
+	re *regexp.Regexp
+}
+
+// Extractor applies the extraction rules for all log ingestors.
+type Extractor struct {
+	mu    sync.RWMutex
+	rules []extractRule
+}
+
+func NewExtractor() *Extractor {
+	return &Extractor{}
+}
+
+// ParseExtractRules decodes and validates the value of ExtractRulesKey.
+func ParseExtractRules(raw json.RawMessage) ([]ExtractRule, error) {
+	var rules []ExtractRule
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, errors.New("must be an array of extraction rules")
+	}
+	seen := map[string]bool{}
+	for _, r := range rules {
+		if r.Name == "" {
+			return nil, errors.New("every rule needs a name")
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("rule %q: duplicate name", r.Name)
+		}
+		seen[r.Name] = true
+		if _, err := path.Match(r.Service, ""); err != nil {
+			return nil, fmt.Errorf("rule %q: invalid service pattern %q", r.Name, r.Service)
+		}
+		if _, err := compileGrok(r.Pattern); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+	}
+	return rules, nil
+}
+
+// compileGrok expands the %{NAME:field} references of pattern and compiles
+// it. The result has to capture at least one validly named field.
+func compileGrok(pattern string) (*regexp.Regexp, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return nil, errors.New("pattern is required")
+	}
+	var bad error
+	expanded := grokRef.ReplaceAllStringFunc(pattern, func(ref string) string {
+		m := grokRef.FindStringSubmatch(ref)
+		def, ok := grokPatterns[m[1]]
+		if !ok {
+			bad = fmt.Errorf("unknown pattern %%{%s}", m[1])
+			return ref
+		}
+		if m[2] == "" {
+			return "(?:" + def + ")"
+		}
+		return "(?P<" + m[2] + ">" + def + ")"
+	})
+	if bad != nil {
+		return nil, bad
+	}
+	re, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, err
+	}
+	named := 0
+	for _, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		if !db.FieldName.MatchString(name) {
+			return nil, fmt.Errorf("invalid field name %q", name)
+		}
+		named++
+	}
+	if named == 0 {
+		return nil, errors.New("pattern captures no fields; name them with %{NAME:field} or (?P<field>...)")
+	}
+	return re, nil
+}
+
+// Set replaces the rules. They are expected to come from ParseExtractRules.
+func (x *Extractor) Set(rules []ExtractRule) {
+	compiled := make([]extractRule, 0, len(rules))
+	for _, r := range rules {
+		re, err := compileGrok(r.Pattern)
+		if err != nil {
+			continue
+		}
+		compiled = append(compiled, extractRule{ExtractRule: r, re: re})
+	}
+	x.mu.Lock()
+	x.rules = compiled
+	x.mu.Unlock()
+}
+
+// Rules returns the current rules.
+func (x *Extractor) Rules() []ExtractRule {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	out := make([]ExtractRule, 0, len(x.rules))
+	for _, r := range x.rules {
+		out = append(out, r.ExtractRule)
+	}
+	return out
+}
+
+// GrokPatterns returns the names of the built-in patterns, sorted.
+func GrokPatterns() []string {
+	return slices.Sorted(maps.Keys(grokPatterns))
+}
+
+// Fields returns e's fields with those the rules matching its service
+// extract from its message added. Fields e already has, e.g. from an access
+// log format, are kept, and when rules capture the same field the first one
+// wins. A nil Extractor extracts nothing.
+func (x *Extractor) Fields(e models.LogEntry) map[string]string {
+	if x == nil {
+		return e.Fields
+	}
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	out, copied := e.Fields, false
+	for _, r := range x.rules {
+		if !matchService(r.Service, e.ServiceID) {
+			continue
+		}
+		m := r.re.FindStringSubmatch(e.Message)
+		if m == nil {
+			continue
+		}
+		for i, name := range r.re.SubexpNames() {
+			if name == "" || m[i] == "" {
+				continue
+			}
+			if _, ok := out[name]; ok {
+				continue
+			}
+			if !copied {
+				out, copied = maps.Clone(out), true
+				if out == nil {
+					out = map[string]string{}
+				}
+			}
+			v := m[i]
+			if len(v) > maxFieldBytes {
+				v = v[:maxFieldBytes]
+			}
+			out[name] = v
+		}
+	}
+	return out
+}
+
+// matchService reports whether the service glob matches serviceID, with or
+// without its "@host" suffix. An empty glob matches every service.
+func matchService(glob, serviceID string) bool {
+	if glob == "" {
+		return true
+	}
+	name, _, _ := strings.Cut(serviceID, "@")
+	full, _ := path.Match(glob, serviceID)
+	short, _ := path.Match(glob, name)
+	return full || short
+}
@@ -0,0 +1,62 @@
+package logs
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"dashi/internal/models"
+)
+
+func TestExtractor(t *testing.T) {
+	rules, err := ParseExtractRules(json.RawMessage(`[
+		{"name": "access", "service": "nginx*", "pattern": "^%{IP:client} \\S+ %{USER:user} \\[%{HTTPDATE}\\] \"%{METHOD:method} %{URIPATH:path}[^\"]*\" %{INT:status}"},
+		{"name": "timing", "pattern": "took (?P<duration>%{DURATION})"},
+		{"name": "status", "service": "api", "pattern": "status=%{INT:status}"}
+	]`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	x := NewExtractor()
+	x.Set(rules)
+	cases := []struct {
+		entry models.LogEntry
+		want  map[string]string
+	}{
+		{
+			models.LogEntry{ServiceID: "nginx@nas", Message: `10.0.0.7 - alice [21/Feb/2026:12:00:00 +0000] "GET /api/items?page=2 HTTP/1.1" 502 157`},
+			map[string]string{"client": "10.0.0.7", "user": "alice", "method": "GET", "path": "/api/items", "status": "502"},
+		},
+		{
+			models.LogEntry{ServiceID: "api", Message: `request done status=200 took 12.5ms`},
+			map[string]string{"duration": "12.5ms", "status": "200"},
+		},
+		{models.LogEntry{ServiceID: "worker", Message: `status=500`}, nil},
+		{models.LogEntry{ServiceID: "nginx", Message: `not an access line`}, nil},
+	}
+	for _, tc := range cases {
+		if got := x.Fields(tc.entry); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Fields(%q) = %v, want %v", tc.entry.Message, got, tc.want)
+		}
+	}
+	if (*Extractor)(nil).Fields(cases[0].entry) != nil {
+		t.Fatal("nil extractor extracted fields")
+	}
+}
+
+func TestParseExtractRulesInvalid(t *testing.T) {
+	for _, raw := range []string{
+		`{}`,
+		`[{"pattern": "%{INT:n}"}]`,
+		`[{"name": "a", "pattern": "%{INT:n}"}, {"name": "a", "pattern": "%{INT:n}"}]`,
+		`[{"name": "a", "pattern": ""}]`,
+		`[{"name": "a", "pattern": "%{NOPE:n}"}]`,
+		`[{"name": "a", "pattern": "no fields %{INT}"}]`,
+		`[{"name": "a", "pattern": "(?P<n>["}]`,
+		`[{"name": "a", "service": "[", "pattern": "%{INT:n}"}]`,
+	} {
+		if _, err := ParseExtractRules(json.RawMessage(raw)); err == nil {
+			t.Errorf("ParseExtractRules(%s) accepted", raw)
+		}
+	}
+}
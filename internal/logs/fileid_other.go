@@ -0,0 +1,9 @@
+//go:build !unix
+
+package logs
+
+import "os"
+
+// fileID is empty where inodes are not available; positions then only
+// apply while the file's size does not shrink.
+func fileID(os.FileInfo) string { return "" }
@@ -0,0 +1,346 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+const (
+	// FileKind is the pseudo host of services whose logs are tailed files.
+	FileKind = "file"
+	// tailPoll is how often files are globbed and read.
+	tailPoll = time.Second
+	// checkpointEvery is how often read positions are saved.
+	checkpointEvery = 10 * time.Second
+)
+
+// FileGlob is a set of host log files stored as one service. Name is the
+// service name; without one every file is its own service named after the
+// file, e.g. "access" for /var/log/nginx/access.log.
+type FileGlob struct {
+	Name    string
+	Pattern string
+}
+
+// ParseFileGlobs reads APP_LOG_FILES entries, each a glob or name=glob.
+func ParseFileGlobs(entries []string) ([]FileGlob, error) {
+	var out []FileGlob
+	for _, entry := range entries {
+		g := FileGlob{Pattern: strings.TrimSpace(entry)}
+		if name, pattern, ok := strings.Cut(entry, "="); ok {
+			g = FileGlob{Name: strings.TrimSpace(name), Pattern: strings.TrimSpace(pattern)}
+			if g.Name == "" || strings.ContainsAny(g.Name, "@/: ") {
+				return nil, fmt.Errorf("invalid log file name in %q", entry)
+			}
+		}
+		if !filepath.IsAbs(g.Pattern) {
+			return nil, fmt.Errorf("log file pattern %q must be an absolute path", g.Pattern)
+		}
+		if _, err := filepath.Match(g.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("log file pattern %q: %w", g.Pattern, err)
+		}
+		out = append(out, g)
+	}
+	return out, nil
+}
+
+// FileTail follows log files on the host dashi runs on. Rotated files are
+// read to the end before the new file is opened, truncated files are read
+// from the start, and read positions survive restarts. On first start an
+// existing file is read from its end, not from its start.
+type FileTail struct {
+	repo  *db.Repository
+	sink  *Sink
+	log   *slog.Logger
+	globs []FileGlob
+
+	files     map[string]*tailedFile
+	positions map[string]models.LogFilePosition
+	started   bool
+	lastSave  time.Time
+	wg        sync.WaitGroup
+	done      chan struct{}
+}
+
+type tailedFile struct {
+	path string
+	f    *os.File
+	info os.FileInfo
+	// offset is the position after the last complete line.
+	offset  int64
+	partial []byte
+	saved   int64
+	in      chan models.LogEntry
+	service string
+	cid     string
+}
+
+func NewFileTail(repo *db.Repository, sink *Sink, logger *slog.Logger, globs []FileGlob) *FileTail {
+	return &FileTail{repo: repo, sink: sink, log: logger, globs: globs, files: map[string]*tailedFile{}, positions: map[string]models.LogFilePosition{}, done: make(chan struct{})}
+}
+
+// Run follows the files until ctx is done.
+func (t *FileTail) Run(ctx context.Context) {
+	defer close(t.done)
+	t.load(ctx)
+	ticker := time.NewTicker(tailPoll)
+	defer ticker.Stop()
+	for {
+		t.poll(ctx)
+		select {
+		case <-ctx.Done():
+			t.close()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop waits for Run to return after its context was cancelled, so every
+// line read is handed to the sink and the positions are saved.
+func (t *FileTail) Stop(ctx context.Context) error {
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *FileTail) load(ctx context.Context) {
+	positions, err := t.repo.ListLogFilePositions(ctx)
+	if err != nil {
+		t.log.Warn("load log file positions", "err", err)
+	}
+	for _, p := range positions {
+		t.positions[p.Path] = p
+	}
+}
+
+func (t *FileTail) poll(ctx context.Context) {
+	matched := map[string]string{}
+	for _, g := range t.globs {
+		paths, _ := filepath.Glob(g.Pattern)
+		for _, p := range paths {
+			if _, ok := matched[p]; !ok {
+				matched[p] = g.Name
+			}
+		}
+	}
+	for path, tf := range t.files {
+		if _, ok := matched[path]; !ok {
+			t.read(tf)
+			t.closeFile(tf)
+			delete(t.files, path)
+			if err := t.repo.DeleteLogFilePosition(ctx, path); err != nil {
+				t.log.Warn("delete log file position", "path", path, "err", err)
+			}
+			delete(t.positions, path)
+		}
+	}
+	for path, name := range matched {
+		tf, ok := t.files[path]
+		if !ok {
+			if tf = t.open(ctx, path, name); tf == nil {
+				continue
+			}
+			t.files[path] = tf
+		}
+		t.follow(tf)
+	}
+	if !t.started {
+		// Forget files tailed before that no pattern matches anymore.
+		for path := range t.positions {
+			if _, ok := matched[path]; !ok {
+				_ = t.repo.DeleteLogFilePosition(ctx, path)
+				delete(t.positions, path)
+			}
+		}
+	}
+	t.started = true
+	if time.Since(t.lastSave) >= checkpointEvery {
+		t.save(ctx)
+	}
+}
+
+// open starts following path at its checkpoint, at the end of files that
+// existed before dashi first ran, or at the start of new files.
+func (t *FileTail) open(ctx context.Context, path, name string) *tailedFile {
+	f, err := os.Open(path)
+	if err != nil {
+		t.log.Warn("open log file", "path", path, "err", err)
+		return nil
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil
+	}
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), ".log")
+	}
+	svc, cid, err := t.sink.Source(ctx, Source{Kind: FileKind, Name: name})
+	if err != nil {
+		t.log.Warn("register log file", "path", path, "err", err)
+		f.Close()
+		return nil
+	}
+	var offset int64
+	if p, ok := t.positions[path]; ok {
+		if p.FileID == fileID(info) && p.Offset <= info.Size() {
+			offset = p.Offset
+		}
+	} else if !t.started {
+		offset = info.Size()
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		t.log.Warn("seek log file", "path", path, "err", err)
+		f.Close()
+		return nil
+	}
+	tf := &tailedFile{path: path, f: f, info: info, offset: offset, saved: -1, in: make(chan models.LogEntry, 256), service: svc, cid: cid}
+	out := make(chan models.LogEntry, 256)
+	go mergeLines(t.log, tf.in, out, newMerger(nil))
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		for e := range out {
+			t.sink.Write(e)
+		}
+	}()
+	t.log.Info("tail log file", "path", path, "service", svc, "offset", offset)
+	return tf
+}
+
+// follow reads new lines of tf, first finishing the old file when path
+// now names a different one.
+func (t *FileTail) follow(tf *tailedFile) {
+	info, err := os.Stat(tf.path)
+	if err != nil {
+		return
+	}
+	if !os.SameFile(info, tf.info) {
+		t.read(tf)
+		t.flushPartial(tf)
+		tf.f.Close()
+		f, err := os.Open(tf.path)
+		if err != nil {
+			t.log.Warn("reopen rotated log file", "path", tf.path, "err", err)
+			t.closeFile(tf)
+			delete(t.files, tf.path)
+			return
+		}
+		if info, err = f.Stat(); err != nil {
+			f.Close()
+			t.closeFile(tf)
+			delete(t.files, tf.path)
+			return
+		}
+		t.log.Info("log file rotated", "path", tf.path)
+		tf.f, tf.info, tf.offset, tf.partial = f, info, 0, nil
+	} else if info.Size() < tf.offset+int64(len(tf.partial)) {
+		t.log.Info("log file truncated", "path", tf.path)
+		if _, err := tf.f.Seek(0, io.SeekStart); err != nil {
+			return
+		}
+		tf.offset, tf.partial = 0, nil
+	}
+	t.read(tf)
+}
+
+// read passes the complete lines up to the end of the file on.
+func (t *FileTail) read(tf *tailedFile) {
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := tf.f.Read(buf)
+		if n > 0 {
+			tf.partial = append(tf.partial, buf[:n]...)
+			for {
+				i := bytes.IndexByte(tf.partial, '\n')
+				if i < 0 {
+					break
+				}
+				t.emit(tf, tf.partial[:i])
+				tf.offset += int64(i + 1)
+				tf.partial = tf.partial[i+1:]
+			}
+			if len(tf.partial) >= maxEventBytes {
+				t.flushPartial(tf)
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				t.log.Warn("read log file", "path", tf.path, "err", err)
+			}
+			return
+		}
+	}
+}
+
+// flushPartial emits a line the writer has not finished, e.g. because the
+// file was rotated or the line is too long.
+func (t *FileTail) flushPartial(tf *tailedFile) {
+	if len(tf.partial) == 0 {
+		return
+	}
+	t.emit(tf, tf.partial)
+	tf.offset += int64(len(tf.partial))
+	tf.partial = nil
+}
+
+func (t *FileTail) emit(tf *tailedFile, line []byte) {
+	msg := strings.TrimRight(string(line), " \t\r")
+	tf.in <- models.LogEntry{
+		TS:          time.Now().UTC(),
+		ServiceID:   tf.service,
+		ContainerID: tf.cid,
+		Level:       inferLevel(StripANSI(msg)),
+		Stream:      FileKind,
+		Message:     msg,
+	}
+}
+
+func (t *FileTail) closeFile(tf *tailedFile) {
+	tf.f.Close()
+	close(tf.in)
+}
+
+// save checkpoints the files whose position moved.
+func (t *FileTail) save(ctx context.Context) {
+	t.lastSave = time.Now()
+	for _, tf := range t.files {
+		if tf.offset == tf.saved {
+			continue
+		}
+		p := models.LogFilePosition{Path: tf.path, FileID: fileID(tf.info), Offset: tf.offset, UpdatedAt: time.Now().UTC()}
+		if err := t.repo.SaveLogFilePosition(ctx, p); err != nil {
+			t.log.Warn("save log file position", "path", tf.path, "err", err)
+			continue
+		}
+		tf.saved = tf.offset
+		t.positions[tf.path] = p
+	}
+}
+
+// close stops following all files and saves their positions.
+func (t *FileTail) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	t.save(ctx)
+	for path, tf := range t.files {
+		t.closeFile(tf)
+		delete(t.files, path)
+	}
+	t.wg.Wait()
+}
@@ -0,0 +1,145 @@
+package logs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"dashi/internal/db"
+)
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestFileTailFollowsRotationAndResumes(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	globs, err := ParseFileGlobs([]string{filepath.Join(dir, "*.log")})
+	if err != nil {
+		t.Fatalf("parse globs: %v", err)
+	}
+
+	sink := NewSink(repo, logger, Options{})
+	tail := NewFileTail(repo, sink, logger, globs)
+	appendFile(t, path, "before dashi started\n")
+	tail.poll(ctx)
+	appendFile(t, path, "ERROR first\n  at x\npartial")
+	tail.poll(ctx)
+	// Rotate: the writer finishes its line in the renamed file.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path+".1", " done\n")
+	appendFile(t, path, "second\n")
+	tail.poll(ctx)
+	tail.close()
+
+	// A restart resumes after the last line read.
+	appendFile(t, path, "third\n")
+	tail = NewFileTail(repo, sink, logger, globs)
+	tail.load(ctx)
+	tail.poll(ctx)
+	tail.close()
+	if err := sink.Stop(ctx); err != nil {
+		t.Fatalf("stop sink: %v", err)
+	}
+
+	got, err := repo.QueryLogs(ctx, db.LogQuery{ServiceID: "app@file"})
+	if err != nil {
+		t.Fatalf("query logs: %v", err)
+	}
+	var msgs []string
+	for _, e := range got {
+		msgs = append(msgs, e.Message)
+		if e.Message == "ERROR first\n  at x" && e.Level != "ERROR" {
+			t.Fatalf("level = %s, want ERROR", e.Level)
+		}
+	}
+	sort.Strings(msgs)
+	want := []string{"ERROR first\n  at x", "partial done", "second", "third"}
+	if len(msgs) != len(want) {
+		t.Fatalf("messages = %q, want %q", msgs, want)
+	}
+	for i := range want {
+		if msgs[i] != want[i] {
+			t.Fatalf("messages = %q, want %q", msgs, want)
+		}
+	}
+}
+
+func TestFileTailReadsTruncatedFileFromStart(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "job.log")
+
+	sink := NewSink(repo, logger, Options{})
+	tail := NewFileTail(repo, sink, logger, []FileGlob{{Name: "nightly", Pattern: path}})
+	tail.poll(ctx)
+	appendFile(t, path, "a long first run line\n")
+	tail.poll(ctx)
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "rerun\n")
+	tail.poll(ctx)
+	tail.close()
+	if err := sink.Stop(ctx); err != nil {
+		t.Fatalf("stop sink: %v", err)
+	}
+
+	got, err := repo.QueryLogs(ctx, db.LogQuery{ServiceID: "nightly@file"})
+	if err != nil {
+		t.Fatalf("query logs: %v", err)
+	}
+	if len(got) != 2 || got[0].Message != "rerun" && got[1].Message != "rerun" {
+		t.Fatalf("entries = %+v", got)
+	}
+}
+
+func TestParseFileGlobs(t *testing.T) {
+	globs, err := ParseFileGlobs([]string{"/var/log/syslog", "nginx=/var/log/nginx/*.log"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(globs) != 2 || globs[0].Name != "" || globs[1].Name != "nginx" || globs[1].Pattern != "/var/log/nginx/*.log" {
+		t.Fatalf("globs = %+v", globs)
+	}
+	for _, bad := range []string{"relative/*.log", "a b=/x.log", "/var/log/[.log"} {
+		if _, err := ParseFileGlobs([]string{bad}); err == nil {
+			t.Fatalf("ParseFileGlobs(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// Forward formats.
+const (
+	// ForwardLoki pushes to the Grafana Loki push API.
+	ForwardLoki = "loki"
+	// ForwardJSON posts a JSON array of entries.
+	ForwardJSON = "json"
+)
+
+const (
+	forwardQueue = 10000
+	forwardBatch = 1000
+	forwardEvery = time.Second
+	forwardTries = 3
+)
+
+// ForwardOptions configure a Forwarder. Basic auth credentials can be part
+// of URL.
+type ForwardOptions struct {
+	URL    string
+	Format string
+	// Token is sent as a bearer token.
+	Token string
+	// Tenant is sent as X-Scope-OrgID for multi-tenant Loki.
+	Tenant string
+}
+
+// Forwarder ships stored log entries to an external system in addition to
+// the local store. Entries are queued and sent in batches; when the
+// destination is down or slow the queue fills up and further entries are
+// dropped, never blocking ingestion.
+type Forwarder struct {
+	opts ForwardOptions
+	http *http.Client
+	log  *slog.Logger
+
+	mu      sync.RWMutex
+	in      chan models.LogEntry
+	stopped bool
+	done    chan struct{}
+
+	sent    atomic.Int64
+	dropped atomic.Int64
+}
+
+// NewForwarder validates opts and starts the sender.
+func NewForwarder(opts ForwardOptions, logger *slog.Logger) (*Forwarder, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid forward URL %q", opts.URL)
+	}
+	switch opts.Format {
+	case "":
+		opts.Format = ForwardLoki
+	case ForwardLoki, ForwardJSON:
+	default:
+		return nil, fmt.Errorf("unknown forward format %q, want %s or %s", opts.Format, ForwardLoki, ForwardJSON)
+	}
+	f := &Forwarder{
+		opts: opts,
+		http: &http.Client{Timeout: 10 * time.Second},
+		log:  logger,
+		in:   make(chan models.LogEntry, forwardQueue),
+		done: make(chan struct{}),
+	}
+	go f.run()
+	return f, nil
+}
+
+// Send queues entries without waiting. A nil Forwarder sends nothing.
+func (f *Forwarder) Send(entries []models.LogEntry) {
+	if f == nil {
+		return
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.stopped {
+		return
+	}
+	for _, e := range entries {
+		select {
+		case f.in <- e:
+		default:
+			f.dropped.Add(1)
+		}
+	}
+}
+
+// Counts returns the entries sent and dropped since start.
+func (f *Forwarder) Counts() (sent, dropped int64) {
+	return f.sent.Load(), f.dropped.Load()
+}
+
+// Stop sends the queued entries and waits for them, or for ctx to expire.
+func (f *Forwarder) Stop(ctx context.Context) error {
+	f.mu.Lock()
+	if !f.stopped {
+		f.stopped = true
+		close(f.in)
+	}
+	f.mu.Unlock()
+	select {
+	case <-f.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f *Forwarder) run() {
+	defer close(f.done)
+	t := time.NewTicker(forwardEvery)
+	defer t.Stop()
+	batch := make([]models.LogEntry, 0, forwardBatch)
+	failing := false
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := f.push(batch); err != nil {
+			f.dropped.Add(int64(len(batch)))
+			if !failing {
+				f.log.Warn("forward logs", "url", redactURL(f.opts.URL), "count", len(batch), "err", err)
+			}
+			failing = true
+		} else {
+			f.sent.Add(int64(len(batch)))
+			if failing {
+				f.log.Info("forward logs recovered", "url", redactURL(f.opts.URL))
+			}
+			failing = false
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case e, ok := <-f.in:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= forwardBatch {
+				flush()
+			}
+		case <-t.C:
+			flush()
+		}
+	}
+}
+
+// push sends one batch, retrying server errors and rate limiting.
+func (f *Forwarder) push(batch []models.LogEntry) error {
+	var body []byte
+	var err error
+	if f.opts.Format == ForwardJSON {
+		body, err = json.Marshal(jsonEntries(batch))
+	} else {
+		body, err = json.Marshal(lokiPush(batch))
+	}
+	if err != nil {
+		return err
+	}
+	for try := 1; ; try++ {
+		retry, err := f.post(body)
+		if err == nil || !retry || try == forwardTries {
+			return err
+		}
+		time.Sleep(time.Duration(try) * time.Second)
+	}
+}
+
+func (f *Forwarder) post(body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, f.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "dashi-forward")
+	if f.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.opts.Token)
+	}
+	if f.opts.Tenant != "" {
+		req.Header.Set("X-Scope-OrgID", f.opts.Tenant)
+	}
+	resp, err := f.http.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// lokiPush groups entries into streams labelled by service, host, level
+// and stream. Container IDs are left out of the labels to keep their
+// cardinality low.
+func lokiPush(batch []models.LogEntry) map[string][]*lokiStream {
+	streams := map[string]*lokiStream{}
+	var order []*lokiStream
+	for _, e := range batch {
+		service, host, _ := strings.Cut(e.ServiceID, "@")
+		key := e.ServiceID + "\x00" + e.Level + "\x00" + e.Stream
+		s, ok := streams[key]
+		if !ok {
+			s = &lokiStream{Stream: map[string]string{
+				"job":     "dashi",
+				"service": service,
+				"host":    host,
+				"level":   strings.ToLower(e.Level),
+				"stream":  e.Stream,
+			}}
+			streams[key] = s
+			order = append(order, s)
+		}
+		s.Values = append(s.Values, [2]string{strconv.FormatInt(e.TS.UnixNano(), 10), e.Message})
+	}
+	return map[string][]*lokiStream{"streams": order}
+}
+
+type jsonEntry struct {
+	TS          time.Time `json:"ts"`
+	Service     string    `json:"service"`
+	Host        string    `json:"host"`
+	ServiceID   string    `json:"service_id"`
+	ContainerID string    `json:"container_id"`
+	Level       string    `json:"level"`
+	Stream      string    `json:"stream"`
+	Message     string    `json:"message"`
+}
+
+func jsonEntries(batch []models.LogEntry) []jsonEntry {
+	out := make([]jsonEntry, 0, len(batch))
+	for _, e := range batch {
+		service, host, _ := strings.Cut(e.ServiceID, "@")
+		out = append(out, jsonEntry{TS: e.TS.UTC(), Service: service, Host: host, ServiceID: e.ServiceID, ContainerID: e.ContainerID, Level: e.Level, Stream: e.Stream, Message: e.Message})
+	}
+	return out
+}
+
+// redactURL hides credentials in u for logs.
+func redactURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	return parsed.Redacted()
+}
@@ -0,0 +1,105 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestForwarderPushesToLoki(t *testing.T) {
+	var mu sync.Mutex
+	var pushes []map[string][]lokiStream
+	var auth, tenant string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string][]lokiStream
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode push: %v", err)
+		}
+		mu.Lock()
+		pushes = append(pushes, body)
+		auth, tenant = r.Header.Get("Authorization"), r.Header.Get("X-Scope-OrgID")
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	f, err := NewForwarder(ForwardOptions{URL: srv.URL + "/loki/api/v1/push", Token: "t0k", Tenant: "home"}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("new forwarder: %v", err)
+	}
+	ts := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	f.Send([]models.LogEntry{
+		{TS: ts, ServiceID: "web@local", ContainerID: "c1", Level: "ERROR", Stream: "stderr", Message: "boom"},
+		{TS: ts.Add(time.Second), ServiceID: "web@local", ContainerID: "c1", Level: "ERROR", Stream: "stderr", Message: "again"},
+		{TS: ts, ServiceID: "db@nas", ContainerID: "c2", Level: "INFO", Stream: "stdout", Message: "ready"},
+	})
+	if err := f.Stop(context.Background()); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	f.Send([]models.LogEntry{{Message: "after stop"}})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(pushes) != 1 || len(pushes[0]["streams"]) != 2 {
+		t.Fatalf("pushes = %+v", pushes)
+	}
+	web := pushes[0]["streams"][0]
+	if web.Stream["service"] != "web" || web.Stream["host"] != "local" || web.Stream["level"] != "error" || len(web.Values) != 2 {
+		t.Fatalf("web stream = %+v", web)
+	}
+	if web.Values[0] != [2]string{"1772366400000000000", "boom"} {
+		t.Fatalf("first value = %v", web.Values[0])
+	}
+	if auth != "Bearer t0k" || tenant != "home" {
+		t.Fatalf("headers: auth=%q tenant=%q", auth, tenant)
+	}
+	if sent, dropped := f.Counts(); sent != 3 || dropped != 0 {
+		t.Fatalf("counts = %d sent, %d dropped", sent, dropped)
+	}
+}
+
+func TestForwarderJSONDoesNotRetryClientErrors(t *testing.T) {
+	calls := 0
+	var got []jsonEntry
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			_ = json.NewDecoder(r.Body).Decode(&got)
+			return
+		}
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	f, err := NewForwarder(ForwardOptions{URL: srv.URL, Format: ForwardJSON}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("new forwarder: %v", err)
+	}
+	if err := f.push([]models.LogEntry{{ServiceID: "web@local", Level: "INFO", Message: "hi"}}); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if len(got) != 1 || got[0].Service != "web" || got[0].Host != "local" || got[0].Message != "hi" {
+		t.Fatalf("entries = %+v", got)
+	}
+	if err := f.push([]models.LogEntry{{Message: "x"}}); err == nil || calls != 2 {
+		t.Fatalf("push to failing sink: err=%v calls=%d", err, calls)
+	}
+	_ = f.Stop(context.Background())
+}
+
+func TestNewForwarderValidates(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, o := range []ForwardOptions{{URL: "loki:3100"}, {URL: "ftp://x"}, {URL: "http://loki", Format: "syslog"}} {
+		if _, err := NewForwarder(o, logger); err == nil {
+			t.Fatalf("NewForwarder(%+v) succeeded", o)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package logs
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"log/slog"
 	"os"
 	"strings"
@@ -10,38 +12,123 @@ import (
 
 	"dashi/internal/db"
 	"dashi/internal/docker"
+	"dashi/internal/filter"
 	"dashi/internal/models"
+	"dashi/internal/selfmon"
+	"dashi/internal/supervise"
 )
 
 type Ingestor struct {
-	repo         *db.Repository
-	dc           *docker.Client
-	log          *slog.Logger
-	skipSelfLogs bool
-	selfID       string
+	repo       *db.Repository
+	dc         *docker.Client
+	log        *slog.Logger
+	selfID     string
+	filter     *filter.Filter
+	dockerHost string
+	opts       Options
 
-	mu      sync.Mutex
-	workers map[string]context.CancelFunc
+	mu       sync.Mutex
+	workers  map[string]context.CancelFunc
+	wg       sync.WaitGroup
+	prunedAt time.Time
 }
 
-func NewIngestor(repo *db.Repository, dc *docker.Client, logger *slog.Logger, skipSelfLogs bool) *Ingestor {
-	hostname, _ := os.Hostname()
-	return &Ingestor{repo: repo, dc: dc, log: logger, skipSelfLogs: skipSelfLogs, selfID: strings.TrimSpace(hostname), workers: map[string]context.CancelFunc{}}
+// positionTTL is how long the read position of a container whose logs
+// stopped is kept, e.g. for a container that is started again.
+const positionTTL = 7 * 24 * time.Hour
+
+type Options struct {
+	// SkipSelfLogs leaves out dashi's own container.
+	SkipSelfLogs bool
+	// A positive DedupWindow collapses consecutive identical lines of a
+	// container seen within that window into one row with a repeat count.
+	DedupWindow time.Duration
+	// KeepColors stores ANSI color codes instead of stripping them.
+	KeepColors bool
+	// Lines matching a rule of Drops are not stored.
+	Drops *Dropper
+	// RateLimit is the number of lines per second a container may log
+	// before the rest of that second is sampled, keeping one line in
+	// SampleEvery. Zero disables the limit; the dashi.log.rate_limit label
+	// overrides it per container.
+	RateLimit   int
+	SampleEvery int
+	// Stored entries are also sent to Forward, if set.
+	Forward *Forwarder
+	// Stats counts every line per service, including dropped ones.
+	Stats *Stats
+	// Extract parses fields out of the stored lines.
+	Extract *Extractor
+	// Stored entries are published to the followers of Tail, if set.
+	Tail *Tail
+	// Batches that fail to be stored are queued in Spill, if set, instead
+	// of being lost.
+	Spill *Spill
+	// QueueSize is the number of lines each container's pipeline queues,
+	// BatchSize the number stored per write; zero means 256 and 200.
+	QueueSize int
+	BatchSize int
+	// Budget bounds the lines waiting to be stored across pipelines. A
+	// Docker log worker stops reading while it is spent, as Docker keeps
+	// the lines; pushed lines beyond it are dropped and counted.
+	Budget *Budget
+}
+
+func (o Options) queueSize() int {
+	if o.QueueSize > 0 {
+		return o.QueueSize
+	}
+	return 256
+}
+
+func (o Options) batchSize() int {
+	if o.BatchSize > 0 {
+		return o.BatchSize
+	}
+	return 200
+}
+
+// NewIngestor follows the logs of the containers on the Docker endpoint
+// named dockerHost that flt allows.
+func NewIngestor(repo *db.Repository, dc *docker.Client, logger *slog.Logger, flt *filter.Filter, dockerHost string, opts Options) *Ingestor {
+	selfID := ""
+	if dockerHost == docker.LocalHost {
+		hostname, _ := os.Hostname()
+		selfID = strings.TrimSpace(hostname)
+	}
+	return &Ingestor{repo: repo, dc: dc, log: logger, selfID: selfID, filter: flt, dockerHost: dockerHost, opts: opts, workers: map[string]context.CancelFunc{}}
+}
+
+// Workers returns the number of containers whose logs are followed.
+func (i *Ingestor) Workers() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return len(i.workers)
 }
 
 func (i *Ingestor) Reconcile(ctx context.Context) {
 	containers, err := i.dc.ListContainers(ctx)
 	if err != nil {
-		i.log.Warn("log reconcile list containers", "err", err)
+		if !errors.Is(err, docker.ErrForbidden) {
+			i.log.Warn("log reconcile list containers", "err", err)
+		}
 		return
 	}
 	live := map[string]bool{}
 	for _, c := range containers {
-		if i.skipSelfLogs && i.isSelfContainer(c.ID) {
+		if i.opts.SkipSelfLogs && i.isSelfContainer(c.ID) {
+			continue
+		}
+		if len(c.Names) == 0 || !i.filter.Allows(c.Names[0], c.Labels) {
 			continue
 		}
 		live[c.ID] = true
-		i.ensureWorker(ctx, c.ID, inferServiceName(c))
+		rules, err := RulesFromLabels(c.Labels)
+		if err != nil {
+			// Keep ingesting with the default detection.
+			i.log.Warn("invalid log labels", "container", c.ID, "err", err)
+		}
+		i.ensureWorker(ctx, c.ID, docker.ServiceID(i.dockerHost, c), rules)
 	}
 	i.mu.Lock()
 	for id, cancel := range i.workers {
@@ -51,6 +138,12 @@ func (i *Ingestor) Reconcile(ctx context.Context) {
 		}
 	}
 	i.mu.Unlock()
+	if time.Since(i.prunedAt) >= time.Hour {
+		i.prunedAt = time.Now()
+		if _, err := i.repo.PruneLogStreamPositions(ctx, time.Now().Add(-positionTTL)); err != nil {
+			i.log.Warn("prune log positions", "err", err)
+		}
+	}
 }
 
 func (i *Ingestor) isSelfContainer(containerID string) bool {
@@ -60,7 +153,7 @@ func (i *Ingestor) isSelfContainer(containerID string) bool {
 	return containerID == i.selfID || strings.HasPrefix(containerID, i.selfID) || strings.HasPrefix(i.selfID, containerID)
 }
 
-func (i *Ingestor) ensureWorker(parent context.Context, containerID, serviceID string) {
+func (i *Ingestor) ensureWorker(parent context.Context, containerID, serviceID string, rules *Rules) {
 	i.mu.Lock()
 	if _, ok := i.workers[containerID]; ok {
 		i.mu.Unlock()
@@ -68,96 +161,267 @@ func (i *Ingestor) ensureWorker(parent context.Context, containerID, serviceID s
 	}
 	ctx, cancel := context.WithCancel(parent)
 	i.workers[containerID] = cancel
+	i.wg.Add(1)
 	i.mu.Unlock()
 
-	go i.runWorker(ctx, containerID, serviceID)
+	go func() {
+		defer i.wg.Done()
+		i.runWorker(ctx, containerID, serviceID, rules)
+	}()
 }
 
-func (i *Ingestor) runWorker(ctx context.Context, containerID, serviceID string) {
+// Stop cancels all log workers and waits for their pending batches to be
+// written, or for ctx to expire.
+func (i *Ingestor) Stop(ctx context.Context) error {
+	i.mu.Lock()
+	for id, cancel := range i.workers {
+		cancel()
+		delete(i.workers, id)
+	}
+	i.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		i.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (i *Ingestor) runWorker(ctx context.Context, containerID, serviceID string, rules *Rules) {
 	i.log.Info("start log worker", "container", containerID)
 	defer i.log.Info("stop log worker", "container", containerID)
-	entriesCh := make(chan models.LogEntry, 256)
-	go i.flushLoop(ctx, entriesCh)
+	entriesCh := make(chan models.LogEntry, i.opts.queueSize())
+	merged := make(chan models.LogEntry, i.opts.queueSize())
+	flushed := make(chan struct{})
+	pos := i.loadPosition(ctx, containerID, serviceID)
+	go mergeLines(i.log, entriesCh, merged, newMerger(rules))
+	go func() {
+		defer close(flushed)
+		// The writer drains merged even after ctx is cancelled, so it is
+		// restarted regardless.
+		smp := newSampler(rules.RateLimit(i.opts.RateLimit), i.opts.SampleEvery)
+		supervise.Loop(context.WithoutCancel(ctx), i.log, "log writer "+containerID, func() {
+			flushLoop(ctx, i.repo, i.log, i.opts, merged, smp, false, pos.stored)
+		})
+	}()
+
+	saved := make(chan struct{})
+	go func() {
+		defer close(saved)
+		i.checkpoint(ctx, containerID, pos, flushed)
+	}()
+	// A panic on a malformed stream restarts reading from the last position.
+	supervise.Loop(ctx, i.log, "log worker "+containerID, func() { i.follow(ctx, containerID, serviceID, rules, pos, entriesCh) })
+	close(entriesCh)
+	<-saved
+}
 
-	since := time.Now().Add(-1 * time.Minute)
-	first := true
+// follow reads the container's logs into entries until ctx is done,
+// reopening the stream whenever it ends.
+func (i *Ingestor) follow(ctx context.Context, containerID, serviceID string, rules *Rules, pos *streamPosition, entries chan<- models.LogEntry) {
 	for {
-		select {
-		case <-ctx.Done():
-			close(entriesCh)
+		if ctx.Err() != nil {
 			return
-		default:
 		}
-		tail := 0
-		if first {
-			// Bootstrap initial UI visibility with recent history, then switch to incremental follow.
-			since = time.Time{}
+		// Resume where the last line was read; a container whose logs were
+		// never read starts with recent history for the UI.
+		since, tail := pos.resume(), 0
+		if since.IsZero() {
 			tail = 500
-			first = false
 		}
 		rc, err := i.dc.Logs(ctx, containerID, since, true, tail)
+		if errors.Is(err, docker.ErrForbidden) {
+			sleepCtx(ctx, time.Minute)
+			continue
+		}
 		if err != nil {
 			i.log.Warn("open docker logs", "container", containerID, "err", err)
-			time.Sleep(2 * time.Second)
+			sleepCtx(ctx, 2*time.Second)
 			continue
 		}
-		err = ParseDockerStream(rc, serviceID, containerID, entriesCh)
-		_ = rc.Close()
-		if err != nil {
+		err = func() error {
+			// Closed even when parsing panics.
+			defer rc.Close()
+			return parseDockerStream(rc, serviceID, containerID, rules, i.opts.KeepColors, pos, entries)
+		}()
+		if err != nil && ctx.Err() == nil {
 			i.log.Warn("parse docker stream", "container", containerID, "err", err)
-			time.Sleep(1 * time.Second)
+			sleepCtx(ctx, 1*time.Second)
 		} else {
 			// Stream can end cleanly when Docker reconnects/rotates logs.
 			// Prevent a tight reconnect loop that can spike CPU.
-			time.Sleep(500 * time.Millisecond)
+			sleepCtx(ctx, 500*time.Millisecond)
 		}
-		// Keep a small overlap on reconnect to reduce missed lines without re-reading too much history.
-		since = time.Now().Add(-2 * time.Second)
 	}
 }
 
-func (i *Ingestor) flushLoop(ctx context.Context, in <-chan models.LogEntry) {
+// loadPosition returns the saved read position of the container's logs.
+// Missed lines found on resuming are recorded as a marker entry.
+func (i *Ingestor) loadPosition(ctx context.Context, containerID, serviceID string) *streamPosition {
+	saved, err := i.repo.GetLogStreamPosition(ctx, containerID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		i.log.Warn("load log position", "container", containerID, "err", err)
+	}
+	return newStreamPosition(saved, func(from, to time.Time) {
+		i.log.Warn("log gap detected", "container", containerID, "from", from, "to", to)
+		marker := []models.LogEntry{gapMarker(serviceID, containerID, from, to)}
+		if err := i.repo.InsertLogs(context.WithoutCancel(ctx), marker); err != nil {
+			i.log.Error("insert logs", "err", err, "count", 1)
+		}
+		i.opts.Tail.Publish(marker)
+	})
+}
+
+// checkpoint saves the position of the lines written every checkpointEvery,
+// and a last time once the worker's lines are flushed.
+func (i *Ingestor) checkpoint(ctx context.Context, containerID string, pos *streamPosition, flushed <-chan struct{}) {
+	t := time.NewTicker(checkpointEvery)
+	defer t.Stop()
+	save := func(ctx context.Context) {
+		p, ok := pos.changed(containerID)
+		if !ok {
+			return
+		}
+		if err := i.repo.SaveLogStreamPosition(ctx, p); err != nil {
+			i.log.Warn("save log position", "container", containerID, "err", err)
+		}
+	}
+	for {
+		select {
+		case <-t.C:
+			save(ctx)
+		case <-flushed:
+			save(context.WithoutCancel(ctx))
+			return
+		}
+	}
+}
+
+// flushLoop batches entries until in is closed. Writes deliberately outlive
+// worker cancellation so that lines already read are not lost on shutdown.
+// Drop rules apply first, then the rate limit of smp. Entries hold their
+// cost in opts.Budget until they are written: charged entries were
+// admitted by the sender, others are admitted here, waiting while the
+// budget is spent. Once the entries received are written or spilled,
+// stored, unless nil, is called with the latest timestamp received and
+// the number of entries received with it; dropped entries count as they
+// are done with, a merged one as a single line, which at worst reads a
+// few lines twice after a restart.
+func flushLoop(ctx context.Context, repo *db.Repository, logger *slog.Logger, opts Options, in <-chan models.LogEntry, smp *sampler, charged bool, stored func(ts time.Time, lines int)) {
 	t := time.NewTicker(2 * time.Second)
 	defer t.Stop()
-	batch := make([]models.LogEntry, 0, 200)
+	writeCtx := context.WithoutCancel(ctx)
+	size := opts.batchSize()
+	batch := make([]models.LogEntry, 0, size)
+	var held int64
+	var lastTS time.Time
+	var lastLines int
+	// Also after a panic, which loses the batch.
+	defer func() { opts.Budget.give(held) }()
 	flush := func() {
+		defer func() {
+			opts.Budget.give(held)
+			held = 0
+		}()
 		if len(batch) == 0 {
+			if stored != nil && lastLines > 0 {
+				stored(lastTS, lastLines)
+			}
 			return
 		}
-		if err := i.repo.InsertLogs(ctx, batch); err != nil {
-			i.log.Error("insert logs", "err", err, "count", len(batch))
+		written := true
+		if err := storeBatch(writeCtx, repo, opts.DedupWindow, batch); err != nil {
+			// Spilled lines are stored once the database takes writes
+			// again; a constraint violation would fail again.
+			if db.IsConstraint(err) || opts.Spill.Put(batch) != nil {
+				selfmon.LogWriteErrors.Add(int64(len(batch)))
+				logger.Error("insert logs", "err", err, "count", len(batch))
+				written = false
+			} else {
+				logger.Warn("insert logs, spilled to disk", "err", err, "count", len(batch))
+			}
+		}
+		if written && stored != nil && lastLines > 0 {
+			stored(lastTS, lastLines)
 		}
+		opts.Forward.Send(batch)
+		opts.Tail.Publish(batch)
 		batch = batch[:0]
 	}
 	for {
 		select {
-		case <-ctx.Done():
-			flush()
-			return
 		case e, ok := <-in:
 			if !ok {
+				if marker, ok := smp.close(); ok {
+					batch = append(batch, marker)
+				}
 				flush()
 				return
 			}
-			batch = append(batch, e)
-			if len(batch) >= 200 {
+			cost := entryCost(e)
+			if !charged && !opts.Budget.take(cost) {
+				// Writing this batch gives back its share first.
 				flush()
+				opts.Budget.wait(cost)
+			}
+			// Counted after the flush above, which this entry is not part of.
+			switch {
+			case e.TS.After(lastTS):
+				lastTS, lastLines = e.TS, 1
+			case e.TS.Equal(lastTS):
+				lastLines++
+			}
+			selfmon.LogLines.Add(1)
+			opts.Stats.Add(e)
+			if opts.Drops.Drop(e) {
+				selfmon.LogDropped.Add(1)
+				opts.Budget.give(cost)
+				continue
+			}
+			keep, marker, sampled := smp.allow(e)
+			if sampled {
+				batch = append(batch, marker)
+			}
+			if keep {
+				e.Fields = opts.Extract.Fields(e)
+				batch = append(batch, e)
+				held += cost
+			} else {
+				selfmon.LogDropped.Add(1)
+				opts.Budget.give(cost)
+			}
+			if len(batch) >= size {
+				flush()
+			}
+		case now := <-t.C:
+			if marker, ok := smp.expire(now); ok {
+				batch = append(batch, marker)
 			}
-		case <-t.C:
 			flush()
 		}
 	}
 }
 
-func inferServiceName(c docker.ContainerSummary) string {
-	if v := c.Labels["com.docker.compose.service"]; v != "" {
-		return v
+// storeBatch writes a batch of log lines, collapsing repeats within a
+// positive dedupWindow.
+func storeBatch(ctx context.Context, repo *db.Repository, dedupWindow time.Duration, batch []models.LogEntry) error {
+	if dedupWindow > 0 {
+		return repo.InsertLogsCollapsed(ctx, batch, dedupWindow)
 	}
-	if len(c.Names) > 0 {
-		return strings.TrimPrefix(c.Names[0], "/")
-	}
-	if len(c.ID) >= 12 {
-		return c.ID[:12]
+	return repo.InsertLogs(ctx, batch)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
 	}
-	return c.ID
 }
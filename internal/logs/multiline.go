@@ -0,0 +1,177 @@
+package logs
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"dashi/internal/models"
+	"dashi/internal/supervise"
+)
+
+const (
+	// mergeWait is how long an event waits for further continuation lines
+	// before it is written.
+	mergeWait = time.Second
+	// maxEventBytes caps a merged event; the next line starts a new one.
+	maxEventBytes = 16 << 10
+)
+
+var (
+	goPanic = regexp.MustCompile(`^(panic: |fatal error: )`)
+	// goFrame matches the unindented lines of a Go traceback: goroutine
+	// headers, function calls and "created by".
+	goFrame = regexp.MustCompile(`^(goroutine \d+ \[.*\]:|created by |\[signal |exit status \d+$|panic\(|[\w\-./]+\.[\w.()*\-]+\(.*\)$)`)
+	pyChain = []string{
+		"Traceback (most recent call last):",
+		"During handling of the above exception",
+		"The above exception was the direct cause",
+	}
+)
+
+// merger joins continuation lines, such as the frames of a Java, Python or
+// Go stack trace, into the entry they belong to.
+type merger struct {
+	off       bool
+	firstLine *regexp.Regexp
+
+	pending  *models.LogEntry
+	python   bool // inside a Python traceback
+	golang   bool // inside a Go panic
+	indented bool // the last line was indented
+	trace    bool // the event is a stack trace
+}
+
+func newMerger(r *Rules) *merger {
+	if r == nil {
+		return &merger{}
+	}
+	return &merger{off: r.multiline == "off", firstLine: r.firstLine}
+}
+
+// add takes the next line and returns the event it completes, if any.
+func (m *merger) add(e models.LogEntry) (models.LogEntry, bool) {
+	if m.pending != nil && e.Stream == m.pending.Stream && m.continues(e.Message) &&
+		len(m.pending.Message)+len(e.Message) < maxEventBytes {
+		m.pending.Message += "\n" + e.Message
+		if severity(e.Level) > severity(m.pending.Level) {
+			m.pending.Level = e.Level
+		}
+		return models.LogEntry{}, false
+	}
+	done, ok := m.flush()
+	e.Message = strings.TrimLeft(e.Message, " \t")
+	if m.off {
+		return e, true
+	}
+	m.pending = &e
+	if m.firstLine == nil {
+		m.golang = goPanic.MatchString(e.Message)
+		m.python = hasAnyPrefix(e.Message, pyChain)
+		m.trace = m.golang || m.python
+	}
+	return done, ok
+}
+
+// flush returns the pending event.
+func (m *merger) flush() (models.LogEntry, bool) {
+	if m.pending == nil {
+		return models.LogEntry{}, false
+	}
+	e := *m.pending
+	e.Message = strings.TrimRight(e.Message, " \t\n")
+	if m.trace && severity(e.Level) < severity("ERROR") {
+		e.Level = "ERROR"
+	}
+	m.pending, m.python, m.golang, m.indented, m.trace = nil, false, false, false, false
+	return e, true
+}
+
+// continues reports whether msg belongs to the pending event. With a
+// first-line pattern every line it does not match continues the event;
+// otherwise indented lines continue it, along with the markers and
+// exception lines of stack traces.
+func (m *merger) continues(msg string) bool {
+	if m.firstLine != nil {
+		return !m.firstLine.MatchString(msg)
+	}
+	indented := strings.HasPrefix(msg, " ") || strings.HasPrefix(msg, "\t")
+	prevIndented := m.indented
+	m.indented = indented
+	switch {
+	case indented:
+		if t := strings.TrimSpace(msg); strings.HasPrefix(t, "at ") || strings.HasPrefix(t, "File \"") {
+			m.trace = true
+		}
+		return true
+	case strings.HasPrefix(msg, "Caused by: "):
+		return true
+	case hasAnyPrefix(msg, pyChain):
+		m.python, m.trace = true, true
+		return true
+	case msg == "":
+		return m.python || m.golang
+	case m.golang:
+		return goFrame.MatchString(msg)
+	case m.python && prevIndented:
+		// The exception line ending a traceback, e.g. "ValueError: bad".
+		return true
+	}
+	return false
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func severity(level string) int {
+	switch level {
+	case "ERROR":
+		return 3
+	case "WARN":
+		return 2
+	case "INFO":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// mergeLines passes entries from in to out, joining continuation lines,
+// and closes out once in is closed and the last event is written. A panic
+// on some odd line restarts the merging rather than ending the stream.
+func mergeLines(logger *slog.Logger, in <-chan models.LogEntry, out chan<- models.LogEntry, m *merger) {
+	defer close(out)
+	supervise.Loop(context.Background(), logger, "log line merge", func() { merge(in, out, m) })
+}
+
+func merge(in <-chan models.LogEntry, out chan<- models.LogEntry, m *merger) {
+	timer := time.NewTimer(mergeWait)
+	defer timer.Stop()
+	for {
+		select {
+		case e, ok := <-in:
+			if !ok {
+				if done, ok := m.flush(); ok {
+					out <- done
+				}
+				return
+			}
+			if done, ok := m.add(e); ok {
+				out <- done
+			}
+			timer.Reset(mergeWait)
+		case <-timer.C:
+			if done, ok := m.flush(); ok {
+				out <- done
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package logs
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"dashi/internal/models"
+)
+
+func mergeAll(m *merger, lines ...string) []models.LogEntry {
+	var out []models.LogEntry
+	for _, l := range lines {
+		if e, ok := m.add(models.LogEntry{Stream: "stderr", Level: inferLevel(l), Message: l}); ok {
+			out = append(out, e)
+		}
+	}
+	if e, ok := m.flush(); ok {
+		out = append(out, e)
+	}
+	return out
+}
+
+func TestMergerStackTraces(t *testing.T) {
+	cases := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{"java", []string{
+			"Exception in thread \"main\" java.lang.IllegalStateException: bad",
+			"\tat com.example.App.run(App.java:12)",
+			"\tat com.example.App.main(App.java:5)",
+			"Caused by: java.io.IOException: closed",
+			"\t... 2 more",
+			"server stopped",
+		}, []string{"Exception in thread", "server stopped"}},
+		{"python", []string{
+			"handling request",
+			"Traceback (most recent call last):",
+			"  File \"app.py\", line 3, in <module>",
+			"    main()",
+			"ValueError: bad",
+			"next request",
+		}, []string{"handling request\nTraceback", "next request"}},
+		{"go", []string{
+			"panic: boom",
+			"",
+			"goroutine 1 [running]:",
+			"main.main()",
+			"\t/app/main.go:8 +0x18",
+			"exit status 2",
+			"restarting",
+		}, []string{"panic: boom", "restarting"}},
+	}
+	for _, tc := range cases {
+		got := mergeAll(newMerger(nil), tc.lines...)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: got %d entries: %q", tc.name, len(got), got)
+		}
+		for i, w := range tc.want {
+			if !strings.HasPrefix(got[i].Message, w) {
+				t.Errorf("%s: entry %d = %q, want prefix %q", tc.name, i, got[i].Message, w)
+			}
+		}
+		if got[0].Level != "ERROR" || got[1].Level != "INFO" {
+			t.Errorf("%s: levels %s, %s", tc.name, got[0].Level, got[1].Level)
+		}
+		if lines := strings.Count(got[0].Message, "\n") + 1; lines != len(tc.lines)-1 {
+			t.Errorf("%s: merged %d lines, want %d", tc.name, lines, len(tc.lines)-1)
+		}
+	}
+}
+
+func TestMergerFirstLineAndOff(t *testing.T) {
+	r, err := RulesFromLabels(map[string]string{MultilineLabel: `^\d{4}-`})
+	if err != nil {
+		t.Fatalf("rules: %v", err)
+	}
+	got := mergeAll(newMerger(r), "2026-02-08 query failed:", "SELECT *", "FROM t", "2026-02-08 done")
+	if len(got) != 2 || got[0].Message != "2026-02-08 query failed:\nSELECT *\nFROM t" {
+		t.Fatalf("first line merge: %q", got)
+	}
+	off, _ := RulesFromLabels(map[string]string{MultilineLabel: "off"})
+	got = mergeAll(newMerger(off), "error", "\tat frame")
+	if len(got) != 2 || got[1].Message != "at frame" {
+		t.Fatalf("off: %q", got)
+	}
+}
+
+func TestMergeLinesFlushesOnClose(t *testing.T) {
+	in := make(chan models.LogEntry, 4)
+	out := make(chan models.LogEntry, 4)
+	in <- models.LogEntry{Stream: "stdout", Level: "INFO", Message: "  indented head"}
+	in <- models.LogEntry{Stream: "stdout", Level: "INFO", Message: "\tcontinued"}
+	in <- models.LogEntry{Stream: "stderr", Level: "INFO", Message: "\tother stream"}
+	close(in)
+	mergeLines(slog.New(slog.NewTextHandler(io.Discard, nil)), in, out, newMerger(nil))
+	var got []string
+	for e := range out {
+		got = append(got, e.Message)
+	}
+	if len(got) != 2 || got[0] != "indented head\n\tcontinued" || got[1] != "other stream" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -7,23 +7,34 @@ import (
 	"io"
 	"strings"
 	"time"
+	"unicode"
 
 	"dashi/internal/models"
 )
 
-func ParseDockerStream(r io.Reader, serviceID, containerID string, out chan<- models.LogEntry) error {
+// ParseDockerStream reads a Docker log stream (with timestamps) into out,
+// one entry per line. Messages keep their indentation so that a merger can
+// tell continuation lines apart. Terminal escape sequences are removed,
+// except for color codes when keepColors is set. rules may be nil.
+func ParseDockerStream(r io.Reader, serviceID, containerID string, rules *Rules, keepColors bool, out chan<- models.LogEntry) error {
+	return parseDockerStream(r, serviceID, containerID, rules, keepColors, nil, out)
+}
+
+// parseDockerStream is ParseDockerStream, skipping the lines pos has
+// already seen when it is not nil.
+func parseDockerStream(r io.Reader, serviceID, containerID string, rules *Rules, keepColors bool, pos *streamPosition, out chan<- models.LogEntry) error {
 	br := bufio.NewReader(r)
 	for {
 		header, err := br.Peek(8)
 		if err != nil {
 			if err == io.EOF || err == io.ErrUnexpectedEOF {
-				return parsePlainStream(br, serviceID, containerID, out)
+				return parsePlainStream(br, serviceID, containerID, rules, keepColors, pos, out)
 			}
 			return err
 		}
 		// Docker uses an 8-byte multiplex header when container TTY is disabled.
 		if !isMultiplexHeader(header) {
-			return parsePlainStream(br, serviceID, containerID, out)
+			return parsePlainStream(br, serviceID, containerID, rules, keepColors, pos, out)
 		}
 		_, _ = br.Discard(8)
 		stream := "stdout"
@@ -38,7 +49,7 @@ func ParseDockerStream(r io.Reader, serviceID, containerID string, out chan<- mo
 		if _, err := io.ReadFull(br, payload); err != nil {
 			return err
 		}
-		emitEntry(string(payload), stream, serviceID, containerID, out)
+		emitEntry(string(payload), stream, serviceID, containerID, rules, keepColors, pos, out)
 	}
 }
 
@@ -52,31 +63,47 @@ func isMultiplexHeader(header []byte) bool {
 	return header[1] == 0 && header[2] == 0 && header[3] == 0
 }
 
-func parsePlainStream(br *bufio.Reader, serviceID, containerID string, out chan<- models.LogEntry) error {
+func parsePlainStream(br *bufio.Reader, serviceID, containerID string, rules *Rules, keepColors bool, pos *streamPosition, out chan<- models.LogEntry) error {
 	sc := bufio.NewScanner(br)
 	for sc.Scan() {
-		emitEntry(sc.Text(), "stdout", serviceID, containerID, out)
+		emitEntry(sc.Text(), "stdout", serviceID, containerID, rules, keepColors, pos, out)
 	}
 	return sc.Err()
 }
 
-func emitEntry(raw, stream, serviceID, containerID string, out chan<- models.LogEntry) {
-	msg := strings.TrimSpace(raw)
+func emitEntry(raw, stream, serviceID, containerID string, rules *Rules, keepColors bool, pos *streamPosition, out chan<- models.LogEntry) {
+	msg := strings.TrimRightFunc(raw, unicode.IsSpace)
 	ts := time.Now().UTC()
 	if p := strings.SplitN(msg, " ", 2); len(p) == 2 {
 		if t, err := time.Parse(time.RFC3339Nano, p[0]); err == nil {
 			ts = t.UTC()
 			msg = p[1]
+			if pos != nil && !pos.accept(ts) {
+				return
+			}
 		}
 	}
-	out <- models.LogEntry{
+	plain := StripANSI(msg)
+	if t, ok := rules.Time(plain); ok {
+		ts = t
+	}
+	if keepColors {
+		msg = stripANSIExceptColors(msg)
+	} else {
+		msg = plain
+	}
+	e := models.LogEntry{
 		TS:          ts,
 		ServiceID:   serviceID,
 		ContainerID: containerID,
-		Level:       inferLevel(msg),
+		Level:       rules.Level(plain),
 		Stream:      stream,
 		Message:     sanitizeMessage(msg),
 	}
+	if a, ok := rules.Access(plain); ok {
+		e.Fields = a.Fields()
+	}
+	out <- e
 }
 
 func inferLevel(msg string) string {
@@ -94,9 +121,8 @@ func inferLevel(msg string) string {
 }
 
 func sanitizeMessage(msg string) string {
-	msg = strings.TrimSpace(msg)
 	msg = strings.ReplaceAll(msg, "\x00", "")
-	msg = strings.TrimSpace(string(bytes.ToValidUTF8([]byte(msg), []byte("?"))))
+	msg = strings.TrimRightFunc(string(bytes.ToValidUTF8([]byte(msg), []byte("?"))), unicode.IsSpace)
 	if len(msg) > 4000 {
 		msg = msg[:4000]
 	}
@@ -31,7 +31,7 @@ func TestParseDockerStream(t *testing.T) {
 	buf := append(head, payload...)
 
 	out := make(chan models.LogEntry, 4)
-	if err := ParseDockerStream(bytes.NewReader(buf), "svc", "cid", out); err != nil {
+	if err := ParseDockerStream(bytes.NewReader(buf), "svc", "cid", nil, false, out); err != nil {
 		t.Fatalf("parse failed: %v", err)
 	}
 	close(out)
@@ -40,3 +40,22 @@ func TestParseDockerStream(t *testing.T) {
 		t.Fatalf("unexpected entry: %+v", entry)
 	}
 }
+
+func TestParseStripsANSI(t *testing.T) {
+	line := "2026-01-01T00:00:00Z \x1b[2K\x1b[31m[error]\x1b[0m disk full\n"
+	for _, tc := range []struct {
+		keepColors bool
+		want       string
+	}{
+		{false, "[error] disk full"},
+		{true, "\x1b[31m[error]\x1b[0m disk full"},
+	} {
+		out := make(chan models.LogEntry, 1)
+		if err := ParseDockerStream(bytes.NewReader([]byte(line)), "svc", "cid", nil, tc.keepColors, out); err != nil {
+			t.Fatalf("parse failed: %v", err)
+		}
+		if e := <-out; e.Message != tc.want || e.Level != "ERROR" {
+			t.Errorf("keepColors=%v: got %q (%s), want %q", tc.keepColors, e.Message, e.Level, tc.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package logs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// GapStream is the stream of the marker entries that report lines missed
+// between two reads of a container's logs.
+const GapStream = "gap"
+
+// streamPosition tracks how far a container's Docker log stream has been
+// read, by the Docker timestamps of its lines, so a reconnect resumes
+// exactly there. Only the position of lines already written is saved, so
+// lines read but lost with the process are read again after a restart.
+type streamPosition struct {
+	mu    sync.Mutex
+	ts    time.Time
+	lines int
+	// While resuming, lines up to ts are read again; skip counts the lines
+	// at ts still expected before new ones.
+	resuming bool
+	skip     int
+	// storedTS and storedLines are the position of the lines written;
+	// loadedTS and loadedLines the saved one this process started from.
+	storedTS    time.Time
+	storedLines int
+	loadedTS    time.Time
+	loadedLines int
+	dirty       bool
+	// onGap is called with the Docker timestamps around missed lines.
+	onGap func(from, to time.Time)
+}
+
+func newStreamPosition(p models.LogStreamPosition, onGap func(from, to time.Time)) *streamPosition {
+	return &streamPosition{ts: p.TS, lines: p.Lines, storedTS: p.TS, storedLines: p.Lines, loadedTS: p.TS, loadedLines: p.Lines, onGap: onGap}
+}
+
+// resume returns the time to request the logs since, zero when nothing
+// was read yet, and expects the lines read at that time to come again.
+func (p *streamPosition) resume() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ts.IsZero() {
+		return time.Time{}
+	}
+	p.resuming, p.skip = true, p.lines
+	return p.ts
+}
+
+// accept is called with the Docker timestamp of each line read and
+// reports whether the line is new. When the first new line after a resume
+// shows that lines at the position were not read again, they were rotated
+// out of Docker's log along with anything logged after them, which is
+// reported to onGap.
+func (p *streamPosition) accept(ts time.Time) bool {
+	p.mu.Lock()
+	var gapFrom time.Time
+	if p.resuming {
+		switch {
+		case ts.Before(p.ts):
+			p.mu.Unlock()
+			return false
+		case ts.Equal(p.ts) && p.skip > 0:
+			p.skip--
+			p.mu.Unlock()
+			return false
+		}
+		p.resuming = false
+		if p.skip > 0 {
+			gapFrom = p.ts
+		}
+	}
+	switch {
+	case ts.Equal(p.ts):
+		p.lines++
+	case ts.After(p.ts):
+		p.ts, p.lines = ts, 1
+	}
+	p.mu.Unlock()
+	if !gapFrom.IsZero() && p.onGap != nil {
+		p.onGap(gapFrom, ts)
+	}
+	return true
+}
+
+// stored moves the saved position to lines written up to ts, lines of
+// them at ts since this process started reading.
+func (p *streamPosition) stored(ts time.Time, lines int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Lines at the loaded position were counted by an earlier process.
+	if ts.Equal(p.loadedTS) {
+		lines += p.loadedLines
+	}
+	if ts.Before(p.storedTS) || ts.Equal(p.storedTS) && lines <= p.storedLines {
+		return
+	}
+	p.storedTS, p.storedLines, p.dirty = ts, lines, true
+}
+
+// changed returns the position of the lines written if it moved since the
+// last call.
+func (p *streamPosition) changed(containerID string) (models.LogStreamPosition, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.dirty {
+		return models.LogStreamPosition{}, false
+	}
+	p.dirty = false
+	return models.LogStreamPosition{ContainerID: containerID, TS: p.storedTS, Lines: p.storedLines, UpdatedAt: time.Now().UTC()}, true
+}
+
+func gapMarker(serviceID, containerID string, from, to time.Time) models.LogEntry {
+	return models.LogEntry{
+		TS:          to,
+		ServiceID:   serviceID,
+		ContainerID: containerID,
+		Level:       "WARN",
+		Stream:      GapStream,
+		Message: fmt.Sprintf("log gap detected: lines logged between %s and %s were rotated out of Docker's log before they were read",
+			from.Format(time.RFC3339Nano), to.Format(time.RFC3339Nano)),
+	}
+}
@@ -0,0 +1,119 @@
+package logs
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func readLines(t *testing.T, pos *streamPosition, lines ...string) []string {
+	t.Helper()
+	out := make(chan models.LogEntry, len(lines))
+	if err := parseDockerStream(strings.NewReader(strings.Join(lines, "\n")+"\n"), "svc", "cid", nil, false, pos, out); err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	close(out)
+	var got []string
+	for e := range out {
+		got = append(got, e.Message)
+	}
+	return got
+}
+
+func TestStreamPositionResumesExactly(t *testing.T) {
+	var gaps int
+	pos := newStreamPosition(models.LogStreamPosition{}, func(from, to time.Time) { gaps++ })
+	if since := pos.resume(); !since.IsZero() {
+		t.Fatalf("fresh position resumes since %v", since)
+	}
+	readLines(t, pos,
+		"2026-01-01T00:00:00.100000000Z a",
+		"2026-01-01T00:00:00.200000000Z b",
+		"2026-01-01T00:00:00.200000000Z c",
+	)
+
+	since := pos.resume()
+	if want := time.Date(2026, 1, 1, 0, 0, 0, 200000000, time.UTC); !since.Equal(want) {
+		t.Fatalf("resume since %v, want %v", since, want)
+	}
+	// A daemon rounding since down repeats older lines too.
+	got := readLines(t, pos,
+		"2026-01-01T00:00:00.100000000Z a",
+		"2026-01-01T00:00:00.200000000Z b",
+		"2026-01-01T00:00:00.200000000Z c",
+		"2026-01-01T00:00:00.200000000Z d",
+		"2026-01-01T00:00:00.300000000Z e",
+	)
+	if strings.Join(got, ",") != "d,e" || gaps != 0 {
+		t.Fatalf("resumed lines = %q, gaps = %d", got, gaps)
+	}
+	if _, ok := pos.changed("cid"); ok {
+		t.Fatal("position of lines not yet written reported")
+	}
+	pos.stored(time.Date(2026, 1, 1, 0, 0, 0, 300000000, time.UTC), 1)
+	p, ok := pos.changed("cid")
+	if !ok || p.Lines != 1 || p.TS.Nanosecond() != 300000000 {
+		t.Fatalf("position = %+v, %v", p, ok)
+	}
+	if _, ok := pos.changed("cid"); ok {
+		t.Fatal("unchanged position reported again")
+	}
+}
+
+func TestStreamPositionDetectsGap(t *testing.T) {
+	var from, to time.Time
+	last := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	pos := newStreamPosition(models.LogStreamPosition{TS: last, Lines: 1}, func(f, t time.Time) { from, to = f, t })
+	pos.resume()
+	got := readLines(t, pos, "2026-01-01T00:05:00Z after rotation")
+	if len(got) != 1 {
+		t.Fatalf("lines = %q", got)
+	}
+	if !from.Equal(last) || !to.Equal(last.Add(5*time.Minute)) {
+		t.Fatalf("gap = %v..%v", from, to)
+	}
+	if m := gapMarker("svc", "cid", from, to); m.Stream != GapStream || m.Level != "WARN" || !m.TS.Equal(to) {
+		t.Fatalf("marker = %+v", m)
+	}
+}
+
+func TestStreamPositionRereadsLinesLostBeforeTheirFlush(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	saved := models.LogStreamPosition{TS: t0, Lines: 2}
+	pos := newStreamPosition(saved, nil)
+	pos.resume()
+	got := readLines(t, pos,
+		"2026-01-01T00:00:00Z a",
+		"2026-01-01T00:00:00Z b",
+		"2026-01-01T00:00:00Z c",
+		"2026-01-01T00:00:01Z d",
+	)
+	if strings.Join(got, ",") != "c,d" {
+		t.Fatalf("lines = %q", got)
+	}
+	// c is written, then the process dies before d is: only c's position
+	// is saved, with the lines at t0 read by the earlier process.
+	pos.stored(t0, 1)
+	p, ok := pos.changed("cid")
+	if !ok || !p.TS.Equal(t0) || p.Lines != 3 {
+		t.Fatalf("position = %+v, %v", p, ok)
+	}
+	if _, ok := pos.changed("cid"); ok {
+		t.Fatal("position of d saved before it was written")
+	}
+
+	var gaps int
+	restarted := newStreamPosition(p, func(from, to time.Time) { gaps++ })
+	restarted.resume()
+	got = readLines(t, restarted,
+		"2026-01-01T00:00:00Z a",
+		"2026-01-01T00:00:00Z b",
+		"2026-01-01T00:00:00Z c",
+		"2026-01-01T00:00:01Z d",
+	)
+	if strings.Join(got, ",") != "d" || gaps != 0 {
+		t.Fatalf("lines after restart = %q, gaps = %d, want d read again", got, gaps)
+	}
+}
@@ -0,0 +1,320 @@
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Container labels that tell the ingestor how to read a service's log
+// lines when the substring matching of inferLevel gets them wrong.
+const (
+	// FormatLabel names a known line format: json, logfmt, or the access
+	// log formats nginx, apache, caddy and traefik.
+	FormatLabel = "dashi.log.format"
+	// LevelRegexLabel is a regular expression whose first group (or whole
+	// match) is the level; lines it does not match are INFO.
+	LevelRegexLabel = "dashi.log.level_regex"
+	// TimeRegexLabel is a regular expression whose first group (or whole
+	// match) is the time the application logged the line.
+	TimeRegexLabel = "dashi.log.time_regex"
+	// TimeLayoutLabel is the Go time layout for TimeRegexLabel, RFC 3339 by
+	// default.
+	TimeLayoutLabel = "dashi.log.time_layout"
+	// MultilineLabel is "auto" (the default) to join stack traces and
+	// indented lines into one entry, "off", or a regular expression matching
+	// the first line of every entry.
+	MultilineLabel = "dashi.log.multiline"
+	// RateLimitLabel overrides the lines per second budget of the
+	// container; 0 disables it.
+	RateLimitLabel = "dashi.log.rate_limit"
+)
+
+// Formats lists the values accepted by FormatLabel.
+var Formats = []string{"json", "logfmt", "nginx", "apache", "caddy", "traefik"}
+
+var (
+	logfmtLevel = regexp.MustCompile(`(?:^|\s)(?:level|lvl|severity)="?([A-Za-z]+)`)
+	logfmtTime  = regexp.MustCompile(`(?:^|\s)(?:time|ts)="?([0-9][0-9T:.+\-Z]+)`)
+	// nginxStatus matches the status after the request of a combined access
+	// log line: "GET / HTTP/1.1" 502 157.
+	nginxStatus = regexp.MustCompile(`" ([1-5][0-9]{2}) `)
+	nginxLevel  = regexp.MustCompile(`^\S+ \S+ \[([a-z]+)\] `)
+	// apacheLevel matches the level of an error log line: [core:error].
+	apacheLevel   = regexp.MustCompile(`^\[[^\]]+\] \[(?:[a-z_]+:)?([a-z]+)[0-9]*\] `)
+	nginxAccessTS = regexp.MustCompile(`\[([0-9]{2}/[A-Za-z]{3}/[0-9]{4}:[0-9:]{8} [+\-][0-9]{4})\]`)
+	nginxErrorTS  = regexp.MustCompile(`^([0-9]{4}/[0-9]{2}/[0-9]{2} [0-9:]{8}) \[`)
+	jsonLevelKeys = []string{"level", "lvl", "severity", "loglevel"}
+	jsonTimeKeys  = []string{"time", "ts", "timestamp", "@timestamp"}
+)
+
+// Rules override level and timestamp detection for one container. A nil
+// *Rules falls back to inferLevel and Docker's timestamps.
+type Rules struct {
+	format    string
+	level     *regexp.Regexp
+	time      *regexp.Regexp
+	layout    string
+	multiline string
+	firstLine *regexp.Regexp
+	rateLimit *int
+}
+
+// RulesFromLabels reads the dashi.log.* labels of a container. It returns
+// nil when none are set.
+func RulesFromLabels(labels map[string]string) (*Rules, error) {
+	format := strings.ToLower(strings.TrimSpace(labels[FormatLabel]))
+	level, ts, multiline := labels[LevelRegexLabel], labels[TimeRegexLabel], strings.TrimSpace(labels[MultilineLabel])
+	rate := strings.TrimSpace(labels[RateLimitLabel])
+	if format == "" && level == "" && ts == "" && multiline == "" && rate == "" {
+		return nil, nil
+	}
+	r := &Rules{format: format, layout: labels[TimeLayoutLabel], multiline: multiline}
+	if format != "" && !validFormat(format) {
+		return nil, fmt.Errorf("%s: unknown format %q, want one of %s", FormatLabel, format, strings.Join(Formats, ", "))
+	}
+	var err error
+	if level != "" {
+		if r.level, err = regexp.Compile(level); err != nil {
+			return nil, fmt.Errorf("%s: %w", LevelRegexLabel, err)
+		}
+	}
+	if ts != "" {
+		if r.time, err = regexp.Compile(ts); err != nil {
+			return nil, fmt.Errorf("%s: %w", TimeRegexLabel, err)
+		}
+	}
+	if multiline != "" && multiline != "auto" && multiline != "off" {
+		if r.firstLine, err = regexp.Compile(multiline); err != nil {
+			return nil, fmt.Errorf("%s: %w", MultilineLabel, err)
+		}
+	}
+	if rate != "" {
+		n, err := strconv.Atoi(rate)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s: must be a number of lines per second", RateLimitLabel)
+		}
+		r.rateLimit = &n
+	}
+	if r.layout == "" {
+		r.layout = time.RFC3339Nano
+	}
+	return r, nil
+}
+
+func validFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// RateLimit returns the lines per second budget, def unless the container
+// sets its own.
+func (r *Rules) RateLimit(def int) int {
+	if r == nil || r.rateLimit == nil {
+		return def
+	}
+	return *r.rateLimit
+}
+
+// Access parses msg as a request when the format is an access log format.
+func (r *Rules) Access(msg string) (AccessRecord, bool) {
+	if r == nil || !accessFormats[r.format] {
+		return AccessRecord{}, false
+	}
+	return parseAccess(r.format, msg)
+}
+
+// Level returns the level of msg: from the level regex if set, else from
+// the format, else from inferLevel.
+func (r *Rules) Level(msg string) string {
+	if r == nil {
+		return inferLevel(msg)
+	}
+	if r.level != nil {
+		if m := submatch(r.level, msg); m != "" {
+			return normalizeLevel(m)
+		}
+		return "INFO"
+	}
+	if a, ok := r.Access(msg); ok {
+		return normalizeLevel(strconv.Itoa(a.Status))
+	}
+	switch r.format {
+	case "json", "caddy":
+		if v, ok := jsonField(msg, jsonLevelKeys); ok {
+			if lvl := jsonLevel(v); lvl != "" {
+				return lvl
+			}
+		}
+	case "logfmt", "traefik":
+		if m := logfmtLevel.FindStringSubmatch(msg); m != nil {
+			return normalizeLevel(m[1])
+		}
+		if v, ok := jsonField(msg, jsonLevelKeys); ok {
+			if lvl := jsonLevel(v); lvl != "" {
+				return lvl
+			}
+		}
+	case "nginx":
+		if m := nginxLevel.FindStringSubmatch(msg); m != nil {
+			return normalizeLevel(m[1])
+		}
+		if m := nginxStatus.FindStringSubmatch(msg); m != nil {
+			return normalizeLevel(m[1])
+		}
+	case "apache":
+		if m := apacheLevel.FindStringSubmatch(msg); m != nil {
+			return normalizeLevel(m[1])
+		}
+	}
+	return inferLevel(msg)
+}
+
+// Time returns the time the application wrote into msg, if the rules
+// say where to find it and it parses.
+func (r *Rules) Time(msg string) (time.Time, bool) {
+	if r == nil {
+		return time.Time{}, false
+	}
+	if r.time != nil {
+		return parseTime(r.layout, submatch(r.time, msg))
+	}
+	switch r.format {
+	case "json", "caddy":
+		if v, ok := jsonField(msg, jsonTimeKeys); ok {
+			return jsonTime(v)
+		}
+	case "logfmt", "traefik":
+		if m := logfmtTime.FindStringSubmatch(msg); m != nil {
+			return parseTime(time.RFC3339Nano, m[1])
+		}
+		if v, ok := jsonField(msg, jsonTimeKeys); ok {
+			return jsonTime(v)
+		}
+		if m := nginxAccessTS.FindStringSubmatch(msg); m != nil {
+			return parseTime("02/Jan/2006:15:04:05 -0700", m[1])
+		}
+	case "apache":
+		if m := nginxAccessTS.FindStringSubmatch(msg); m != nil {
+			return parseTime("02/Jan/2006:15:04:05 -0700", m[1])
+		}
+	case "nginx":
+		if m := nginxAccessTS.FindStringSubmatch(msg); m != nil {
+			return parseTime("02/Jan/2006:15:04:05 -0700", m[1])
+		}
+		if m := nginxErrorTS.FindStringSubmatch(msg); m != nil {
+			return parseTime("2006/01/02 15:04:05", m[1])
+		}
+	}
+	return time.Time{}, false
+}
+
+// submatch returns the first group of re in s, or the whole match when re
+// has no groups.
+func submatch(re *regexp.Regexp, s string) string {
+	m := re.FindStringSubmatch(s)
+	switch {
+	case m == nil:
+		return ""
+	case len(m) > 1:
+		return m[1]
+	default:
+		return m[0]
+	}
+}
+
+func parseTime(layout, s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.UTC(), true
+}
+
+// normalizeLevel maps level names of common loggers and syslog, and HTTP
+// status codes (5xx ERROR, 4xx WARN), to dashi's four levels.
+func normalizeLevel(s string) string {
+	if n, err := strconv.Atoi(s); err == nil && n >= 100 && n < 600 {
+		switch {
+		case n >= 500:
+			return "ERROR"
+		case n >= 400:
+			return "WARN"
+		default:
+			return "INFO"
+		}
+	}
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "ERROR", "ERR", "E", "FATAL", "PANIC", "CRIT", "CRITICAL", "ALERT", "EMERG", "EMERGENCY":
+		return "ERROR"
+	case "WARN", "WARNING", "W":
+		return "WARN"
+	case "DEBUG", "DBG", "D", "TRACE":
+		return "DEBUG"
+	default:
+		return "INFO"
+	}
+}
+
+func jsonField(msg string, keys []string) (any, bool) {
+	if !strings.HasPrefix(msg, "{") {
+		return nil, false
+	}
+	var m map[string]any
+	if err := json.Unmarshal([]byte(msg), &m); err != nil {
+		return nil, false
+	}
+	for _, k := range keys {
+		if v, ok := m[k]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// jsonLevel reads a level name or a pino/bunyan level number (10 trace to
+// 60 fatal).
+func jsonLevel(v any) string {
+	switch v := v.(type) {
+	case string:
+		return normalizeLevel(v)
+	case float64:
+		switch {
+		case v >= 50:
+			return "ERROR"
+		case v >= 40:
+			return "WARN"
+		case v >= 30:
+			return "INFO"
+		default:
+			return "DEBUG"
+		}
+	}
+	return ""
+}
+
+// jsonTime reads an RFC 3339 string or a Unix time in seconds (zap) or
+// milliseconds (pino).
+func jsonTime(v any) (time.Time, bool) {
+	switch v := v.(type) {
+	case string:
+		return parseTime(time.RFC3339Nano, v)
+	case float64:
+		if v > 1e12 {
+			return time.UnixMilli(int64(v)).UTC(), true
+		}
+		sec := int64(v)
+		return time.Unix(sec, int64((v-float64(sec))*1e9)).UTC(), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,75 @@
+package logs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRulesLevel(t *testing.T) {
+	nginx, err := RulesFromLabels(map[string]string{FormatLabel: "nginx"})
+	if err != nil {
+		t.Fatalf("nginx rules: %v", err)
+	}
+	custom, err := RulesFromLabels(map[string]string{LevelRegexLabel: `^\[(\w+)\]`})
+	if err != nil {
+		t.Fatalf("custom rules: %v", err)
+	}
+	jsonRules, _ := RulesFromLabels(map[string]string{FormatLabel: "json"})
+	logfmt, _ := RulesFromLabels(map[string]string{FormatLabel: "logfmt"})
+	cases := []struct {
+		rules *Rules
+		msg   string
+		want  string
+	}{
+		{nginx, `10.0.0.1 - - [08/Feb/2026:10:00:00 +0000] "GET /errors HTTP/1.1" 200 512 "-" "curl"`, "INFO"},
+		{nginx, `10.0.0.1 - - [08/Feb/2026:10:00:00 +0000] "GET / HTTP/1.1" 502 157 "-" "curl"`, "ERROR"},
+		{nginx, `10.0.0.1 - - [08/Feb/2026:10:00:00 +0000] "GET /x HTTP/1.1" 404 0 "-" "curl"`, "WARN"},
+		{nginx, `2026/02/08 10:00:00 [crit] 29#29: *1 connect() failed`, "ERROR"},
+		{custom, `[warning] disk almost full`, "WARN"},
+		{custom, `no error here`, "INFO"},
+		{jsonRules, `{"level":"info","msg":"retrying after error"}`, "INFO"},
+		{jsonRules, `{"level":50,"msg":"boom"}`, "ERROR"},
+		{jsonRules, `plain ERROR banner`, "ERROR"},
+		{logfmt, `time=2026-02-08T10:00:00Z level=debug msg="no error"`, "DEBUG"},
+		{nil, `fatal error happened`, "ERROR"},
+	}
+	for _, tc := range cases {
+		if got := tc.rules.Level(tc.msg); got != tc.want {
+			t.Errorf("Level(%q) = %s, want %s", tc.msg, got, tc.want)
+		}
+	}
+}
+
+func TestRulesTime(t *testing.T) {
+	want := time.Date(2026, 2, 8, 10, 0, 0, 0, time.UTC)
+	nginx, _ := RulesFromLabels(map[string]string{FormatLabel: "nginx"})
+	if got, ok := nginx.Time(`10.0.0.1 - - [08/Feb/2026:11:00:00 +0100] "GET / HTTP/1.1" 200 1`); !ok || !got.Equal(want) {
+		t.Fatalf("nginx time = %v, %v", got, ok)
+	}
+	jsonRules, _ := RulesFromLabels(map[string]string{FormatLabel: "json"})
+	if got, ok := jsonRules.Time(`{"time":1770544800000,"level":30}`); !ok || !got.Equal(want) {
+		t.Fatalf("json time = %v, %v", got, ok)
+	}
+	custom, err := RulesFromLabels(map[string]string{TimeRegexLabel: `^(\S+ \S+)`, TimeLayoutLabel: "2006-01-02 15:04:05"})
+	if err != nil {
+		t.Fatalf("custom rules: %v", err)
+	}
+	if got, ok := custom.Time(`2026-02-08 10:00:00 started`); !ok || !got.Equal(want) {
+		t.Fatalf("custom time = %v, %v", got, ok)
+	}
+	if _, ok := custom.Time(`started`); ok {
+		t.Fatal("expected no time without a match")
+	}
+}
+
+func TestRulesFromLabels(t *testing.T) {
+	if r, err := RulesFromLabels(map[string]string{"other": "x"}); r != nil || err != nil {
+		t.Fatalf("expected no rules, got %v, %v", r, err)
+	}
+	if _, err := RulesFromLabels(map[string]string{FormatLabel: "xml"}); err == nil {
+		t.Fatal("expected an unknown format error")
+	}
+	if _, err := RulesFromLabels(map[string]string{LevelRegexLabel: "("}); err == nil {
+		t.Fatal("expected a regex error")
+	}
+}
@@ -0,0 +1,82 @@
+package logs
+
+import (
+	"fmt"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// SampledStream is the stream of the marker entries that report sampling.
+const SampledStream = "dashi"
+
+// sampler enforces a per-second line budget for one container. Seconds are
+// taken from the line timestamps, so the history read when a worker starts
+// is not mistaken for a burst.
+type sampler struct {
+	limit int
+	every int
+
+	window      time.Time
+	seen, kept  int
+	serviceID   string
+	containerID string
+}
+
+// newSampler returns nil, which keeps every line, when limit is not
+// positive.
+func newSampler(limit, every int) *sampler {
+	if limit <= 0 {
+		return nil
+	}
+	return &sampler{limit: limit, every: max(every, 1)}
+}
+
+// allow reports whether e is kept. When e starts a new second it also
+// returns the marker of the previous one, if lines were sampled out then.
+func (s *sampler) allow(e models.LogEntry) (keep bool, marker models.LogEntry, ok bool) {
+	if s == nil {
+		return true, models.LogEntry{}, false
+	}
+	if sec := e.TS.Truncate(time.Second); !sec.Equal(s.window) {
+		marker, ok = s.close()
+		s.window = sec
+	}
+	s.serviceID, s.containerID = e.ServiceID, e.ContainerID
+	s.seen++
+	if s.seen <= s.limit || (s.seen-s.limit)%s.every == 0 {
+		s.kept++
+		return true, marker, ok
+	}
+	return false, marker, ok
+}
+
+// expire closes the current second once now is past it, so the marker of
+// a burst is written without waiting for the next line.
+func (s *sampler) expire(now time.Time) (models.LogEntry, bool) {
+	if s == nil || now.Sub(s.window) < time.Second {
+		return models.LogEntry{}, false
+	}
+	return s.close()
+}
+
+// close ends the current second and returns its marker if lines were
+// sampled out.
+func (s *sampler) close() (models.LogEntry, bool) {
+	if s == nil {
+		return models.LogEntry{}, false
+	}
+	seen, kept := s.seen, s.kept
+	s.seen, s.kept = 0, 0
+	if seen == kept {
+		return models.LogEntry{}, false
+	}
+	return models.LogEntry{
+		TS:          s.window,
+		ServiceID:   s.serviceID,
+		ContainerID: s.containerID,
+		Level:       "WARN",
+		Stream:      SampledStream,
+		Message:     fmt.Sprintf("logs sampled: kept %d of %d lines in this second (limit %d/s, then 1 in %d)", kept, seen, s.limit, s.every),
+	}, true
+}
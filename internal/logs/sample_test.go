@@ -0,0 +1,62 @@
+package logs
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestSampler(t *testing.T) {
+	s := newSampler(3, 5)
+	start := time.Date(2026, 2, 8, 10, 0, 0, 0, time.UTC)
+	kept := 0
+	for i := 0; i < 23; i++ {
+		keep, _, ok := s.allow(models.LogEntry{TS: start.Add(time.Duration(i) * time.Millisecond), ContainerID: "c1"})
+		if ok {
+			t.Fatalf("line %d: unexpected marker", i)
+		}
+		if keep {
+			kept++
+		}
+	}
+	// 3 within the limit, then lines 8, 13, 18 and 23.
+	if kept != 7 {
+		t.Fatalf("kept %d lines, want 7", kept)
+	}
+	if _, ok := s.expire(start.Add(500 * time.Millisecond)); ok {
+		t.Fatal("expired a second that is not over")
+	}
+	keep, marker, ok := s.allow(models.LogEntry{TS: start.Add(time.Second), ContainerID: "c1"})
+	if !keep || !ok {
+		t.Fatalf("next second: keep=%v marker=%v", keep, ok)
+	}
+	if marker.Stream != SampledStream || marker.ContainerID != "c1" || !marker.TS.Equal(start) || !strings.Contains(marker.Message, "kept 7 of 23 lines") {
+		t.Fatalf("unexpected marker: %+v", marker)
+	}
+	if _, ok := s.expire(start.Add(3 * time.Second)); ok {
+		t.Fatal("marker for a second within the limit")
+	}
+
+	var off *sampler = newSampler(0, 100)
+	if keep, _, ok := off.allow(models.LogEntry{TS: start}); !keep || ok {
+		t.Fatal("disabled sampler dropped a line")
+	}
+}
+
+func TestRulesRateLimit(t *testing.T) {
+	r, err := RulesFromLabels(map[string]string{RateLimitLabel: "0"})
+	if err != nil {
+		t.Fatalf("rules: %v", err)
+	}
+	if got := r.RateLimit(500); got != 0 {
+		t.Fatalf("RateLimit = %d, want 0", got)
+	}
+	if got := (*Rules)(nil).RateLimit(500); got != 500 {
+		t.Fatalf("default RateLimit = %d", got)
+	}
+	if _, err := RulesFromLabels(map[string]string{RateLimitLabel: "fast"}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,163 @@
+package logs
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+	"dashi/internal/supervise"
+)
+
+// sourceRefresh is how often the service and container rows of an active
+// source are touched, which keeps their last seen time current.
+const sourceRefresh = time.Minute
+
+// Source names logs that do not come from a container's Docker logs, e.g.
+// GELF messages. A source is stored as a service called Name on the pseudo
+// host Kind, with one container, so its logs are searched, filtered and
+// alerted on like a container's.
+type Source struct {
+	Kind string
+	Name string
+}
+
+func (s Source) serviceID() string   { return s.Name + "@" + s.Kind }
+func (s Source) containerID() string { return s.Kind + ":" + s.Name }
+
+// Sink feeds log entries from inputs other than the Docker logs API
+// through the same drop rules, rate limit and batched writes as the
+// Ingestor, with one pipeline per container.
+type Sink struct {
+	repo *db.Repository
+	log  *slog.Logger
+	opts Options
+
+	mu        sync.RWMutex
+	pipelines map[string]chan<- models.LogEntry
+	sources   map[Source]time.Time
+	stopped   bool
+	wg        sync.WaitGroup
+}
+
+// NewSink creates a sink. opts.SkipSelfLogs and opts.KeepColors do not
+// apply: messages arrive without escape sequences or are stripped.
+func NewSink(repo *db.Repository, logger *slog.Logger, opts Options) *Sink {
+	return &Sink{repo: repo, log: logger, opts: opts, pipelines: map[string]chan<- models.LogEntry{}, sources: map[Source]time.Time{}}
+}
+
+// Source returns the service and container the logs of src are stored
+// under, creating them on first use.
+func (s *Sink) Source(ctx context.Context, src Source) (serviceID, containerID string, err error) {
+	now := time.Now().UTC()
+	s.mu.Lock()
+	last, ok := s.sources[src]
+	s.mu.Unlock()
+	if !ok || now.Sub(last) >= sourceRefresh {
+		err := s.repo.UpsertServiceAndContainer(ctx,
+			models.Service{ID: src.serviceID(), Host: src.Kind, Name: src.Name, LabelsJSON: "{}", Status: "external"},
+			models.Container{ID: src.containerID(), ServiceID: src.serviceID(), Host: src.Kind, Name: src.Name, Status: "external", LastSeenAt: now},
+		)
+		if err != nil {
+			return "", "", err
+		}
+		s.mu.Lock()
+		s.sources[src] = now
+		s.mu.Unlock()
+	}
+	return src.serviceID(), src.containerID(), nil
+}
+
+// Write queues e, whose ServiceID and ContainerID have to exist, e.g. from
+// Source. Messages are stripped of escape sequences and truncated like
+// container logs. Lines that find their container's queue full or the
+// memory budget spent are dropped and counted, as the inputs cannot be
+// paused.
+func (s *Sink) Write(e models.LogEntry) {
+	e.Message = sanitizeMessage(StripANSI(e.Message))
+	if e.TS.IsZero() {
+		e.TS = time.Now().UTC()
+	}
+	cost := entryCost(e)
+	if !s.opts.Budget.take(cost) {
+		overflow(e.ContainerID)
+		return
+	}
+	s.mu.RLock()
+	in, ok := s.pipelines[e.ContainerID]
+	if !ok && !s.stopped {
+		s.mu.RUnlock()
+		s.start(e.ContainerID)
+		s.mu.RLock()
+		in, ok = s.pipelines[e.ContainerID]
+	}
+	// Sending under the read lock keeps Stop from closing the channel
+	// mid-send, without serializing writers.
+	sent := false
+	if ok {
+		select {
+		case in <- e:
+			sent = true
+		default:
+			overflow(e.ContainerID)
+		}
+	}
+	s.mu.RUnlock()
+	if !sent {
+		s.opts.Budget.give(cost)
+	}
+}
+
+func (s *Sink) start(containerID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pipelines[containerID]; ok || s.stopped {
+		return
+	}
+	ch := make(chan models.LogEntry, s.opts.queueSize())
+	s.pipelines[containerID] = ch
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		smp := newSampler(s.opts.RateLimit, s.opts.SampleEvery)
+		supervise.Loop(context.Background(), s.log, "log writer "+containerID, func() {
+			flushLoop(context.Background(), s.repo, s.log, s.opts, ch, smp, true, nil)
+		})
+	}()
+}
+
+// Stop ends the pipelines and waits for their pending batches to be
+// written, or for ctx to expire. Later writes are discarded.
+func (s *Sink) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopped = true
+	for id, in := range s.pipelines {
+		close(in)
+		delete(s.pipelines, id)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ParseLevel maps a level name sent with a log line to dashi's levels, or
+// infers the level from msg when there is none.
+func ParseLevel(name, msg string) string {
+	if strings.TrimSpace(name) == "" {
+		return inferLevel(msg)
+	}
+	return normalizeLevel(name)
+}
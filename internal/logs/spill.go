@@ -0,0 +1,189 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+	"dashi/internal/selfmon"
+)
+
+// errSpillFull is returned by Spill.Put when the queue holds its maximum.
+var errSpillFull = errors.New("log spill queue is full")
+
+// Spill queues log batches that could not be stored, e.g. while the
+// database is locked or the disk is full, as files in a directory, and
+// writes them later in the order they were queued. Batches survive a
+// restart.
+type Spill struct {
+	repo   *db.Repository
+	log    *slog.Logger
+	dir    string
+	max    int64
+	window time.Duration
+
+	mu    sync.Mutex
+	size  int64
+	files int
+	seq   uint64
+	// replayMu keeps replays from running concurrently.
+	replayMu sync.Mutex
+}
+
+// NewSpill opens the queue in dir, creating it, which holds up to maxBytes
+// of batches. Replayed batches are stored like flushLoop stores them, with
+// dedupWindow.
+func NewSpill(repo *db.Repository, dir string, maxBytes int64, dedupWindow time.Duration, logger *slog.Logger) (*Spill, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create log spill directory: %w", err)
+	}
+	// Files a crash left half written were never queued.
+	tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	for _, tmp := range tmps {
+		_ = os.Remove(tmp)
+	}
+	s := &Spill{repo: repo, log: logger, dir: dir, max: maxBytes, window: dedupWindow}
+	names, err := s.pending()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if st, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			s.size += st.Size()
+			s.files++
+		}
+	}
+	if s.files > 0 {
+		logger.Info("log spill queue has pending batches", "batches", s.files, "bytes", s.size)
+	}
+	return s, nil
+}
+
+// Pending returns the number of queued batches and their size in bytes.
+func (s *Spill) Pending() (batches int, bytes int64) {
+	if s == nil {
+		return 0, 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.files, s.size
+}
+
+// Put queues batch. It fails when the queue is full or the file cannot be
+// written, e.g. on a full disk, and the batch is lost.
+func (s *Spill) Put(batch []models.LogEntry) error {
+	if s == nil {
+		return errors.New("log spill is disabled")
+	}
+	raw, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if s.size+int64(len(raw)) > s.max {
+		s.mu.Unlock()
+		return errSpillFull
+	}
+	s.seq++
+	// Names sort in the order batches were queued, also across restarts.
+	name := fmt.Sprintf("%020d-%06d.json", time.Now().UnixNano(), s.seq%1_000_000)
+	s.size += int64(len(raw))
+	s.files++
+	s.mu.Unlock()
+
+	path := filepath.Join(s.dir, name)
+	if err := os.WriteFile(path+".tmp", raw, 0o600); err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		_ = os.Remove(path + ".tmp")
+		s.forget(int64(len(raw)))
+		return fmt.Errorf("spill log batch: %w", err)
+	}
+	selfmon.LogSpilled.Add(int64(len(batch)))
+	return nil
+}
+
+// Replay stores the queued batches oldest first and removes them. It stops
+// at the first batch that fails to be stored, which is tried again on the
+// next replay.
+func (s *Spill) Replay(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+	names, err := s.pending()
+	if err != nil {
+		s.log.Warn("list spilled log batches", "err", err)
+		return
+	}
+	replayed := 0
+	for _, name := range names {
+		if ctx.Err() != nil {
+			break
+		}
+		path := filepath.Join(s.dir, name)
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			s.log.Warn("read spilled log batch", "file", name, "err", err)
+			return
+		}
+		var batch []models.LogEntry
+		if err := json.Unmarshal(raw, &batch); err != nil {
+			// A batch cut short by a crash cannot be replayed.
+			s.log.Error("discard corrupt spilled log batch", "file", name, "err", err)
+		} else if err := storeBatch(ctx, s.repo, s.window, batch); db.IsConstraint(err) {
+			// E.g. its container was purged meanwhile; it would block the
+			// queue for good.
+			selfmon.LogWriteErrors.Add(int64(len(batch)))
+			s.log.Error("discard spilled log batch", "file", name, "err", err, "count", len(batch))
+			batch = nil
+		} else if err != nil {
+			s.log.Warn("replay spilled log batch", "file", name, "err", err, "count", len(batch))
+			return
+		}
+		if err := os.Remove(path); err != nil {
+			s.log.Warn("remove spilled log batch", "file", name, "err", err)
+			return
+		}
+		s.forget(int64(len(raw)))
+		replayed += len(batch)
+	}
+	if replayed > 0 {
+		s.log.Info("replayed spilled log lines", "count", replayed)
+	}
+}
+
+func (s *Spill) forget(bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.size -= bytes
+	s.files--
+}
+
+// pending returns the names of the queued batches, oldest first.
+func (s *Spill) pending() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read log spill directory: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
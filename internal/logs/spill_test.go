@@ -0,0 +1,153 @@
+package logs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+func TestSpillQueuesFailedBatchesAndReplays(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "spill")
+	spill, err := NewSpill(repo, dir, 1<<20, 0, logger)
+	if err != nil {
+		t.Fatalf("new spill: %v", err)
+	}
+
+	if err := repo.UpsertServiceAndContainer(ctx, models.Service{ID: "svc", Name: "svc", LabelsJSON: "{}"}, models.Container{ID: "c1", ServiceID: "svc", Name: "c1"}); err != nil {
+		t.Fatalf("store container: %v", err)
+	}
+	// Every write fails until the table is back.
+	if _, err := sqldb.Exec(`ALTER TABLE logs RENAME TO logs_away`); err != nil {
+		t.Fatalf("rename logs: %v", err)
+	}
+	in := make(chan models.LogEntry)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		flushLoop(ctx, repo, logger, Options{Spill: spill}, in, newSampler(0, 1), false, nil)
+	}()
+	now := time.Now().UTC()
+	for i, msg := range []string{"first", "second", "third"} {
+		in <- models.LogEntry{TS: now.Add(time.Duration(i) * time.Second), ServiceID: "svc", ContainerID: "c1", Stream: "stdout", Message: msg}
+	}
+	close(in)
+	<-done
+	if n, _ := spill.Pending(); n != 1 {
+		t.Fatalf("pending batches = %d, want 1", n)
+	}
+	spill.Replay(ctx)
+	if n, _ := spill.Pending(); n != 1 {
+		t.Fatalf("pending batches after failed replay = %d, want 1", n)
+	}
+
+	// A restart finds the queue; once writes work it is stored and emptied.
+	again, err := NewSpill(repo, dir, 1<<20, 0, logger)
+	if err != nil {
+		t.Fatalf("reopen spill: %v", err)
+	}
+	if n, size := again.Pending(); n != 1 || size == 0 {
+		t.Fatalf("reopened queue has %d batches of %d bytes", n, size)
+	}
+	if _, err := sqldb.Exec(`ALTER TABLE logs_away RENAME TO logs`); err != nil {
+		t.Fatalf("rename logs back: %v", err)
+	}
+	again.Replay(ctx)
+	var stored int
+	if err := sqldb.QueryRow(`SELECT COUNT(*) FROM logs WHERE container_id = 'c1'`).Scan(&stored); err != nil || stored != 3 {
+		t.Fatalf("stored %d lines, err %v, want 3", stored, err)
+	}
+	if n, size := again.Pending(); n != 0 || size != 0 {
+		t.Fatalf("queue after replay has %d batches of %d bytes", n, size)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("spill directory still holds %d files", len(files))
+	}
+
+	// A batch that can never be stored does not block the queue.
+	if err := again.Put([]models.LogEntry{{TS: now, ServiceID: "purged", ContainerID: "gone", Message: "orphan"}}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	again.Replay(ctx)
+	if n, _ := again.Pending(); n != 0 {
+		t.Fatalf("orphaned batch still queued")
+	}
+
+	small, err := NewSpill(repo, t.TempDir(), 10, 0, logger)
+	if err != nil {
+		t.Fatalf("new spill: %v", err)
+	}
+	if err := small.Put([]models.LogEntry{{TS: now, Message: "too big"}}); err != errSpillFull {
+		t.Fatalf("put into a full queue = %v, want errSpillFull", err)
+	}
+}
+
+func TestFlushLoopReportsStoredLines(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+	if err := repo.UpsertServiceAndContainer(ctx, models.Service{ID: "svc", Name: "svc", LabelsJSON: "{}"}, models.Container{ID: "c1", ServiceID: "svc", Name: "c1"}); err != nil {
+		t.Fatalf("store container: %v", err)
+	}
+
+	type report struct {
+		ts            time.Time
+		lines, stored int
+	}
+	var reports []report
+	stored := func(ts time.Time, lines int) {
+		entries, err := repo.QueryLogs(ctx, db.LogQuery{ServiceID: "svc", Limit: 10})
+		if err != nil {
+			t.Errorf("query logs: %v", err)
+		}
+		reports = append(reports, report{ts, lines, len(entries)})
+	}
+	in := make(chan models.LogEntry)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		flushLoop(ctx, repo, logger, Options{BatchSize: 2}, in, newSampler(0, 1), false, stored)
+	}()
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, ts := range []time.Time{t0, t0.Add(time.Second), t0.Add(time.Second)} {
+		in <- models.LogEntry{TS: ts, ServiceID: "svc", ContainerID: "c1", Stream: "stdout", Message: string(rune('a' + i))}
+	}
+	close(in)
+	<-done
+	// Reported only once written: after the full batch of two, then the
+	// last line at close.
+	want := []report{{t0.Add(time.Second), 1, 2}, {t0.Add(time.Second), 2, 3}}
+	if len(reports) != len(want) {
+		t.Fatalf("reports = %+v, want %+v", reports, want)
+	}
+	for i, r := range reports {
+		if !r.ts.Equal(want[i].ts) || r.lines != want[i].lines || r.stored != want[i].stored {
+			t.Fatalf("reports = %+v, want %+v", reports, want)
+		}
+	}
+}
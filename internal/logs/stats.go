@@ -0,0 +1,105 @@
+package logs
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+// Stats counts the lines, error lines and bytes each service logs per
+// minute, and the requests of access logs parsed by their format. Lines are
+// counted as they arrive, before drop rules and sampling, so the metrics
+// show what services write rather than what is stored.
+type Stats struct {
+	mu      sync.Mutex
+	minutes map[time.Time]map[string]*models.ServiceLogMetric
+	now     func() time.Time
+}
+
+func NewStats() *Stats {
+	return &Stats{minutes: map[time.Time]map[string]*models.ServiceLogMetric{}, now: time.Now}
+}
+
+// Add counts e in the current minute. A nil Stats counts nothing.
+func (s *Stats) Add(e models.LogEntry) {
+	if s == nil {
+		return
+	}
+	minute := s.now().UTC().Truncate(time.Minute)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	services, ok := s.minutes[minute]
+	if !ok {
+		services = map[string]*models.ServiceLogMetric{}
+		s.minutes[minute] = services
+	}
+	m, ok := services[e.ServiceID]
+	if !ok {
+		m = &models.ServiceLogMetric{TS: minute, ServiceID: e.ServiceID}
+		services[e.ServiceID] = m
+	}
+	m.Lines++
+	if e.Level == "ERROR" {
+		m.ErrorLines++
+	}
+	m.Bytes += int64(len(e.Message))
+	if status, err := strconv.Atoi(e.Fields["status"]); err == nil {
+		m.Requests++
+		if status >= 500 {
+			m.Requests5xx++
+		}
+		if ms, err := strconv.ParseFloat(e.Fields["duration_ms"], 64); err == nil {
+			m.TimedRequests++
+			m.LatencyMS += ms
+		}
+	}
+}
+
+// Flush stores the finished minutes, or all of them on shutdown. Counts
+// that fail to store are kept for the next flush.
+func (s *Stats) Flush(ctx context.Context, repo *db.Repository, all bool) error {
+	current := s.now().UTC().Truncate(time.Minute)
+	var out []models.ServiceLogMetric
+	s.mu.Lock()
+	taken := map[time.Time]map[string]*models.ServiceLogMetric{}
+	for minute, services := range s.minutes {
+		if !all && !minute.Before(current) {
+			continue
+		}
+		for _, m := range services {
+			out = append(out, *m)
+		}
+		taken[minute] = services
+		delete(s.minutes, minute)
+	}
+	s.mu.Unlock()
+	if err := repo.AddServiceLogMetrics(ctx, out); err != nil {
+		s.mu.Lock()
+		for minute, services := range taken {
+			if cur, ok := s.minutes[minute]; ok {
+				for id, m := range services {
+					if c, ok := cur[id]; ok {
+						c.Lines += m.Lines
+						c.ErrorLines += m.ErrorLines
+						c.Bytes += m.Bytes
+						c.Requests += m.Requests
+						c.Requests5xx += m.Requests5xx
+						c.TimedRequests += m.TimedRequests
+						c.LatencyMS += m.LatencyMS
+					} else {
+						cur[id] = m
+					}
+				}
+			} else {
+				s.minutes[minute] = services
+			}
+		}
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package logs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+func TestStatsFlushesFinishedMinutes(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+
+	now := time.Date(2026, 2, 21, 12, 0, 10, 0, time.UTC)
+	s := NewStats()
+	s.now = func() time.Time { return now }
+	s.Add(models.LogEntry{ServiceID: "web", Level: "ERROR", Message: "boom"})
+	s.Add(models.LogEntry{ServiceID: "web", Level: "INFO", Message: "ok", Fields: map[string]string{"status": "503", "duration_ms": "12.5"}})
+	s.Add(models.LogEntry{ServiceID: "web", Level: "INFO", Message: "", Fields: map[string]string{"status": "200"}})
+	now = now.Add(time.Minute)
+	s.Add(models.LogEntry{ServiceID: "web", Level: "INFO", Message: "later"})
+
+	if err := s.Flush(ctx, repo, false); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	got, err := repo.ServiceLogMetrics(ctx, "web", time.Time{})
+	if err != nil {
+		t.Fatalf("metrics: %v", err)
+	}
+	if len(got) != 1 || got[0].Lines != 3 || got[0].ErrorLines != 1 || got[0].Bytes != 6 ||
+		got[0].Requests != 2 || got[0].Requests5xx != 1 || got[0].TimedRequests != 1 || got[0].LatencyMS != 12.5 {
+		t.Fatalf("after flush = %+v", got)
+	}
+	if err := s.Flush(ctx, repo, true); err != nil {
+		t.Fatalf("final flush: %v", err)
+	}
+	if got, _ = repo.ServiceLogMetrics(ctx, "web", time.Time{}); len(got) != 2 || got[1].Lines != 1 {
+		t.Fatalf("after final flush = %+v", got)
+	}
+}
@@ -0,0 +1,72 @@
+package logs
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"dashi/internal/models"
+)
+
+// tailBuffer is how many entries a follower may fall behind before newer
+// ones are skipped for it.
+const tailBuffer = 512
+
+// Tail fans stored log entries out to live followers, e.g. a browser
+// following the logs view. Slow followers skip entries rather than holding
+// up ingestion.
+type Tail struct {
+	mu        sync.RWMutex
+	followers map[*Follower]struct{}
+}
+
+func NewTail() *Tail {
+	return &Tail{followers: map[*Follower]struct{}{}}
+}
+
+// Follower receives the entries published after it subscribed.
+type Follower struct {
+	C       <-chan models.LogEntry
+	ch      chan models.LogEntry
+	skipped atomic.Int64
+}
+
+// Skipped returns and resets the number of entries skipped because the
+// follower fell behind.
+func (f *Follower) Skipped() int64 {
+	return f.skipped.Swap(0)
+}
+
+// Follow subscribes a follower; call Unfollow when done.
+func (t *Tail) Follow() *Follower {
+	ch := make(chan models.LogEntry, tailBuffer)
+	f := &Follower{C: ch, ch: ch}
+	t.mu.Lock()
+	t.followers[f] = struct{}{}
+	t.mu.Unlock()
+	return f
+}
+
+func (t *Tail) Unfollow(f *Follower) {
+	t.mu.Lock()
+	delete(t.followers, f)
+	t.mu.Unlock()
+}
+
+// Publish hands entries to every follower without blocking. A nil Tail
+// publishes nothing.
+func (t *Tail) Publish(entries []models.LogEntry) {
+	if t == nil || len(entries) == 0 {
+		return
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for f := range t.followers {
+		for _, e := range entries {
+			select {
+			case f.ch <- e:
+			default:
+				f.skipped.Add(1)
+			}
+		}
+	}
+}
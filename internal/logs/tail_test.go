@@ -0,0 +1,36 @@
+package logs
+
+import (
+	"testing"
+
+	"dashi/internal/models"
+)
+
+func TestTailSkipsForSlowFollowers(t *testing.T) {
+	tail := NewTail()
+	f := tail.Follow()
+	entries := make([]models.LogEntry, tailBuffer+3)
+	for i := range entries {
+		entries[i].Message = "line"
+	}
+	tail.Publish(entries)
+	if got := len(f.C); got != tailBuffer {
+		t.Fatalf("buffered %d entries, want %d", got, tailBuffer)
+	}
+	if got := f.Skipped(); got != 3 {
+		t.Fatalf("skipped = %d, want 3", got)
+	}
+	if got := f.Skipped(); got != 0 {
+		t.Fatalf("skipped after reset = %d, want 0", got)
+	}
+
+	tail.Unfollow(f)
+	<-f.C
+	tail.Publish(entries[:1])
+	if got := len(f.C); got != tailBuffer-1 {
+		t.Fatalf("unfollowed follower got entries: %d buffered", got)
+	}
+
+	var none *Tail
+	none.Publish(entries)
+}
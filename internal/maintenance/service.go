@@ -0,0 +1,74 @@
+// Package maintenance keeps SQLite files compact: it returns free pages with
+// incremental vacuum and checkpoints the WAL once it grows past a limit.
+// On Postgres it does nothing; autovacuum covers the same ground there.
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"dashi/internal/db"
+)
+
+type Service struct {
+	repo        *db.Repository
+	walLimit    int64
+	vacuumPages int
+	log         *slog.Logger
+}
+
+func NewService(repo *db.Repository, walLimit int64, vacuumPages int, logger *slog.Logger) *Service {
+	return &Service{repo: repo, walLimit: walLimit, vacuumPages: vacuumPages, log: logger}
+}
+
+func (s *Service) Run(ctx context.Context) {
+	files, err := s.repo.StorageFiles(ctx)
+	if errors.Is(err, db.ErrUnsupported) {
+		return
+	}
+	if err != nil {
+		s.log.Error("read storage stats", "err", err)
+		return
+	}
+	var wal int64
+	for _, f := range files {
+		wal += f.WALBytes
+		s.vacuum(ctx, f)
+	}
+	if s.walLimit > 0 && wal > s.walLimit {
+		if err := s.repo.CheckpointWAL(ctx); err != nil {
+			s.log.Warn("wal checkpoint incomplete", "err", err, "wal_bytes", wal)
+			return
+		}
+		s.log.Info("wal checkpointed", "wal_bytes", wal, "limit_bytes", s.walLimit)
+	}
+}
+
+func (s *Service) vacuum(ctx context.Context, f db.StorageFile) {
+	if f.AutoVacuum != 2 {
+		s.log.Info("enabling incremental vacuum", "schema", f.Schema, "path", f.Path, "free_bytes", f.FreeBytes())
+		if err := s.repo.EnableIncrementalVacuum(ctx, f.Schema); err != nil {
+			s.log.Error("enable incremental vacuum", "err", err, "schema", f.Schema)
+		}
+		return
+	}
+	if f.FreePages == 0 {
+		return
+	}
+	if err := s.repo.IncrementalVacuum(ctx, f.Schema, s.vacuumPages); err != nil {
+		s.log.Error("incremental vacuum", "err", err, "schema", f.Schema)
+		return
+	}
+	after, err := s.repo.StorageFiles(ctx)
+	if err != nil {
+		s.log.Warn("read storage stats", "err", err)
+		return
+	}
+	for _, a := range after {
+		if a.Schema == f.Schema {
+			s.log.Info("incremental vacuum completed", "schema", f.Schema,
+				"reclaimed_bytes", (f.Pages-a.Pages)*f.PageSize, "free_bytes", a.FreeBytes())
+		}
+	}
+}
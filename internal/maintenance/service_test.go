@@ -0,0 +1,61 @@
+package maintenance
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+func TestRunReclaimsFreePages(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	svc := NewService(repo, 1, 0, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// First run switches the file to incremental auto-vacuum.
+	svc.Run(ctx)
+	files, err := repo.StorageFiles(ctx)
+	if err != nil {
+		t.Fatalf("storage files: %v", err)
+	}
+	if files[0].AutoVacuum != 2 {
+		t.Fatalf("auto_vacuum = %d, want 2", files[0].AutoVacuum)
+	}
+
+	now := time.Now().UTC()
+	batch := make([]models.HostMetric, 5000)
+	for i := range batch {
+		batch[i] = models.HostMetric{TS: now.Add(-48 * time.Hour)}
+	}
+	if err := repo.InsertMetricsBatch(ctx, batch, nil); err != nil {
+		t.Fatalf("insert metrics: %v", err)
+	}
+	if err := repo.DeleteMetricsOlderThan(ctx, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("delete metrics: %v", err)
+	}
+	before, _ := repo.StorageFiles(ctx)
+	if before[0].FreePages == 0 {
+		t.Fatal("expected free pages after delete")
+	}
+
+	svc.Run(ctx)
+	after, _ := repo.StorageFiles(ctx)
+	if after[0].FreePages != 0 {
+		t.Fatalf("free pages after vacuum = %d, want 0", after[0].FreePages)
+	}
+	if after[0].WALBytes != 0 {
+		t.Fatalf("wal bytes after checkpoint = %d, want 0", after[0].WALBytes)
+	}
+}
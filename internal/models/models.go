@@ -15,6 +15,67 @@ type HostMetric struct {
 	Load5          float64
 	Load15         float64
 	UptimeSec      int64
+	// Bytes per second since the previous sample.
+	NetRXRate      float64
+	NetTXRate      float64
+	SwapUsedBytes  int64
+	SwapTotalBytes int64
+	// Disk I/O summed over physical disks; DiskUtilPct is the busiest
+	// disk's. Disks holds the per-device figures.
+	DiskReadRate  float64
+	DiskWriteRate float64
+	DiskReadIOPS  float64
+	DiskWriteIOPS float64
+	DiskUtilPct   float64
+	Disks         []DiskIO
+	// Hottest CPU and NVMe sensors in °C, 0 without such a sensor.
+	CPUTempC     float64
+	NVMeTempC    float64
+	Temperatures []Temperature
+	// TCP sockets by state; TCPConns counts all but listening ones.
+	TCPEstablished int64
+	TCPSynRecv     int64
+	TCPTimeWait    int64
+	TCPCloseWait   int64
+	TCPListen      int64
+	TCPConns       int64
+}
+
+// Temperature is one hwmon or thermal zone reading, Sensor being
+// "<chip>/<label>".
+type Temperature struct {
+	TS     time.Time
+	Sensor string
+	TempC  float64
+}
+
+// SelfMetric is a sample of dashi's own health. Rates are per second and
+// counts cover the time since the previous sample.
+type SelfMetric struct {
+	TS             time.Time
+	LogLinesRate   float64
+	LogDroppedRate float64
+	LogWriteErrors int64
+	LogWorkers     int
+	DBSizeBytes    int64
+	QueryRate      float64
+	QueryAvgMS     float64
+	Goroutines     int
+	HeapBytes      int64
+	NotifyFailures int64
+	Panics         int64
+	LogOverflow    int64
+}
+
+// DiskIO is the I/O of one block device over a sampling interval.
+type DiskIO struct {
+	TS        time.Time
+	Device    string
+	ReadRate  float64
+	WriteRate float64
+	ReadIOPS  float64
+	WriteIOPS float64
+	UtilPct   float64
 }
 
 type ContainerMetric struct {
@@ -27,6 +88,59 @@ type ContainerMetric struct {
 	NetTXBytes    int64
 	BlkReadBytes  int64
 	BlkWriteBytes int64
+	// Bytes per second since the previous sample of the same container.
+	NetRXRate    float64
+	NetTXRate    float64
+	BlkReadRate  float64
+	BlkWriteRate float64
+	// Process and open file counts with their limits; a zero limit means
+	// unlimited or unknown. FDs cover the container's main process only.
+	Pids      int64
+	PidsLimit int64
+	FDs       int64
+	FDLimit   int64
+}
+
+// HostMetricRollup aggregates host samples into a fixed-width bucket. The
+// embedded HostMetric holds averages (byte counters hold the bucket maximum,
+// rates the bucket average) and its TS is the bucket start.
+type HostMetricRollup struct {
+	HostMetric
+	Resolution time.Duration
+	Samples    int
+	CPUPctMin  float64
+	CPUPctMax  float64
+	MemUsedMax int64
+	Load1Max   float64
+}
+
+type ContainerMetricRollup struct {
+	ContainerMetric
+	Resolution time.Duration
+	Samples    int
+	CPUPctMin  float64
+	CPUPctMax  float64
+	MemUsedMax int64
+}
+
+// ContainerUsage sums up a container's samples over a window: the average
+// and peak CPU, memory, network (received plus sent) and block I/O (read
+// plus written). Peaks over rollups are those of their bucket averages,
+// except for CPU and memory, whose rollups keep the true maximum.
+type ContainerUsage struct {
+	ContainerID string
+	ServiceID   string
+	Name        string
+	Host        string
+	Samples     int
+	CPUAvg      float64
+	CPUMax      float64
+	MemAvg      float64
+	MemMax      int64
+	NetAvg      float64
+	NetMax      float64
+	BlkAvg      float64
+	BlkMax      float64
 }
 
 type LogEntry struct {
@@ -36,10 +150,25 @@ type LogEntry struct {
 	Level       string
 	Stream      string
 	Message     string
+	// RepeatCount is how many consecutive identical lines this row stands
+	// for; LastSeen is set once it exceeds one.
+	RepeatCount int
+	LastSeen    *time.Time
+	// Fields are extracted from Message by the logs.extract_rules setting.
+	Fields map[string]string
+}
+
+// LogBucket counts the log lines of one histogram bucket starting at TS,
+// repeats included, in total and by level.
+type LogBucket struct {
+	TS     time.Time
+	Total  int64
+	Levels map[string]int64
 }
 
 type Service struct {
 	ID         string
+	Host       string
 	Name       string
 	Image      string
 	LabelsJSON string
@@ -47,13 +176,176 @@ type Service struct {
 }
 
 type Container struct {
-	ID           string
-	ServiceID    string
-	Name         string
-	Status       string
+	ID        string
+	ServiceID string
+	Host      string
+	Name      string
+	Status    string
+	// Health is the Docker healthcheck status ("healthy", "unhealthy",
+	// "starting"), empty when the container has no healthcheck.
+	Health       string
 	StartedAt    *time.Time
 	LastSeenAt   time.Time
 	RestartCount int
+	// Ports are the published port mappings, Networks the attached
+	// networks and Links the names of linked containers, all as of the
+	// last inspect.
+	Ports    []Port
+	Networks []Network
+	Links    []string
+}
+
+// Port is a container port, published on the host when HostPort is set.
+type Port struct {
+	HostIP        string
+	HostPort      int
+	ContainerPort int
+	Protocol      string
+}
+
+// Network is a network a container is attached to and its address there.
+type Network struct {
+	Name string
+	IP   string
+}
+
+// Host is a Docker endpoint, agent or pseudo host of a log source that
+// services, containers, samples and log lines belong to.
+type Host struct {
+	ID          int64
+	Name        string
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+}
+
+// Agent is a registered dashi agent, named by the Docker host it pushes
+// for. It is offline once no heartbeat arrived by Deadline.
+type Agent struct {
+	Host            string
+	OS              string
+	Arch            string
+	Kernel          string
+	DockerVersion   string
+	IntervalSec     int
+	RemoteAddr      string
+	RegisteredAt    time.Time
+	LastHeartbeatAt time.Time
+	Deadline        time.Time
+}
+
+// DockerHost summarizes one monitored Docker endpoint.
+type DockerHost struct {
+	Name       string
+	Containers int
+	Running    int
+	// CPUPct and MemUsedBytes sum the latest samples of the running
+	// containers.
+	CPUPct       float64
+	MemUsedBytes int64
+	// Metric is the latest host sample, nil for hosts dashi does not
+	// sample.
+	Metric       *HostMetric
+	ActiveAlerts int
+	// Agent is the host's agent, nil for hosts dashi reaches itself.
+	Agent *Agent
+}
+
+// Process is one row of a container's process list.
+type Process struct {
+	PID      int
+	User     string
+	CPUPct   float64
+	RSSBytes int64
+	Command  string
+}
+
+// ContainerConfig is how a container was started. Values that look like
+// secrets are replaced by RedactedValue before they leave the docker
+// package.
+type ContainerConfig struct {
+	Image         string
+	Entrypoint    []string
+	Command       []string
+	WorkingDir    string
+	User          string
+	Env           []EnvVar
+	Mounts        []Mount
+	RestartPolicy string
+	MaxRetries    int
+}
+
+const RedactedValue = "********"
+
+type EnvVar struct {
+	Name     string
+	Value    string
+	Redacted bool
+}
+
+type Mount struct {
+	Type        string
+	Source      string
+	Destination string
+	Mode        string
+	ReadWrite   bool
+}
+
+// PruneReport is what a Docker prune removed, or would remove when DryRun
+// is set.
+type PruneReport struct {
+	Host           string
+	Kind           string
+	DryRun         bool
+	Items          []string
+	ReclaimedBytes int64
+}
+
+// AuditEvent records an administrative action taken through dashi.
+type AuditEvent struct {
+	ID     int64
+	TS     time.Time
+	Action string
+	Target string
+	Detail string
+	// Source is the remote address of the request.
+	Source string
+}
+
+// ImageUpdate is the result of comparing a service's image with its
+// registry.
+type ImageUpdate struct {
+	ServiceID       string
+	Image           string
+	CurrentDigest   string
+	LatestDigest    string
+	UpdateAvailable bool
+	CheckedAt       time.Time
+	// Error explains why the last check could not compare digests.
+	Error string
+}
+
+// VolumeUsage is one disk usage sample of a Docker volume.
+type VolumeUsage struct {
+	TS        time.Time
+	Host      string
+	Name      string
+	Driver    string
+	SizeBytes int64
+	RefCount  int
+	// GrowthBytes is the size change over the queried window; it is only
+	// set when listing.
+	GrowthBytes int64
+}
+
+// ImageUsage is the disk usage of an image as of the last collection.
+type ImageUsage struct {
+	Host        string
+	ID          string
+	Tags        []string
+	SizeBytes   int64
+	SharedBytes int64
+	Containers  int
+	UpdatedAt   time.Time
 }
 
 type AlertRule struct {
@@ -67,4 +359,305 @@ type AlertRule struct {
 	ForSeconds      int
 	CooldownSeconds int
 	Enabled         bool
+	// LabelSelector limits container rules to services whose labels match,
+	// e.g. "env=prod". Empty matches everything.
+	LabelSelector string
+}
+
+// RetentionPolicy holds how many days each kind of data is kept.
+type RetentionPolicy struct {
+	LogsDays    int
+	MetricsDays int
+	RollupDays  int
+	AlertsDays  int
+}
+
+// Check is a user-defined HTTP(S) probe. ExpectStatus 0 accepts any 2xx or
+// 3xx response; ExpectBody, when set, must occur in the response body.
+type Check struct {
+	ID           int64
+	Name         string
+	URL          string
+	Method       string
+	ExpectStatus int
+	ExpectBody   string
+	IntervalSec  int
+	TimeoutSec   int
+	Enabled      bool
+}
+
+// CheckResult is the outcome of one probe. Error says why a failed probe
+// failed.
+type CheckResult struct {
+	CheckID    int64
+	TS         time.Time
+	OK         bool
+	StatusCode int
+	LatencyMS  int64
+	Error      string
+}
+
+// CheckStatus is a check with its last result and availability over a
+// window.
+type CheckStatus struct {
+	Check
+	Last         *CheckResult
+	Probes       int
+	UptimePct    float64
+	AvgLatencyMS float64
+}
+
+// Heartbeat is an inbound monitor: a job calls its ping URL at least every
+// PeriodSec, and is considered down once PeriodSec plus GraceSec pass
+// without a ping (counted from CreatedAt until the first one).
+type Heartbeat struct {
+	ID         int64
+	Name       string
+	Token      string
+	PeriodSec  int
+	GraceSec   int
+	CreatedAt  time.Time
+	LastPingAt *time.Time
+	// LastFailAt is set when the job reports a failure through the /fail
+	// ping URL.
+	LastFailAt *time.Time
+}
+
+// Dashboard is a named layout of the index page: its widgets, in order.
+type Dashboard struct {
+	Name      string
+	Widgets   []Widget
+	UpdatedAt time.Time
+}
+
+// Widget is a panel of a dashboard. Column is "left" or "main"; Services
+// and Range narrow the widgets that take them. It is stored as JSON.
+type Widget struct {
+	Type     string   `json:"type"`
+	Column   string   `json:"column"`
+	Services []string `json:"services,omitempty"`
+	Range    string   `json:"range,omitempty"`
+}
+
+// SearchHit is a record matching a global search. Kind is "service",
+// "container", "image", "label", "alert" or "log"; Text is what matched and
+// ContainerID the container it leads to, if any.
+type SearchHit struct {
+	Kind        string
+	ID          string
+	Name        string
+	Text        string
+	ServiceID   string
+	ContainerID string
+	TS          time.Time
+}
+
+// Deployment is a release of a service reported by a deploy webhook.
+// Source is "github", "gitlab" or "webhook".
+type Deployment struct {
+	ID          int64
+	TS          time.Time
+	ServiceID   string
+	Source      string
+	Version     string
+	Environment string
+	Description string
+	URL         string
+}
+
+// LogArchive is a segment of logs exported to object storage before
+// retention deleted them: the lines of one service and UTC day, gzipped
+// NDJSON under Key. Cutoff is the retention cutoff of the run that wrote it.
+type LogArchive struct {
+	ID        int64
+	Key       string
+	Day       string
+	ServiceID string
+	From      time.Time
+	To        time.Time
+	Lines     int
+	Bytes     int64
+	Cutoff    time.Time
+	CreatedAt time.Time
+}
+
+// ServiceLogMetric counts the log lines a service wrote in the minute
+// starting at TS, before drop rules and sampling. ErrorLines are lines at
+// level ERROR; Bytes is the size of the messages. Requests counts the lines
+// parsed as access log requests, Requests5xx those answered with a 5xx
+// status, and LatencyMS sums the request times of the TimedRequests that
+// logged one.
+type ServiceLogMetric struct {
+	TS            time.Time
+	ServiceID     string
+	Lines         int64
+	ErrorLines    int64
+	Bytes         int64
+	Requests      int64
+	Requests5xx   int64
+	TimedRequests int64
+	LatencyMS     float64
+}
+
+// LogFilePosition is how far a tailed log file has been read. FileID
+// identifies the file (device and inode) so a file rotated while dashi was
+// down is read from the start instead of from the old offset.
+type LogFilePosition struct {
+	Path      string
+	FileID    string
+	Offset    int64
+	UpdatedAt time.Time
+}
+
+// LogStreamPosition is how far a container's Docker log stream has been
+// read: the Docker timestamp of the last line and how many lines read had
+// exactly that timestamp, so a resumed stream skips them.
+type LogStreamPosition struct {
+	ContainerID string
+	TS          time.Time
+	Lines       int
+	UpdatedAt   time.Time
+}
+
+// ClockOffset is the last clock comparison of a Docker host against a
+// reference: Source "ntp" compares dashi's host with an NTP server, "docker"
+// compares the daemon's clock with dashi's. OffsetMS is positive when the
+// compared clock is ahead.
+type ClockOffset struct {
+	Host      string
+	Source    string
+	OffsetMS  float64
+	CheckedAt time.Time
+	// Error explains why the last comparison failed; OffsetMS then keeps
+	// the previous value.
+	Error string
+}
+
+// HostReboot is a restart of the monitored host, detected from its uptime
+// going backwards between two samples.
+type HostReboot struct {
+	ID       int64
+	BootedAt time.Time
+	// LastSeenAt is the last sample before the reboot, so BootedAt minus
+	// LastSeenAt bounds the downtime.
+	LastSeenAt    time.Time
+	PrevUptimeSec int64
+	DetectedAt    time.Time
+}
+
+// RestartEvent is a restart of a container, or its replacement by a new
+// container of the same service, as the collector saw it.
+type RestartEvent struct {
+	ID          int64
+	ContainerID string
+	ServiceID   string
+	Host        string
+	Name        string
+	// Reason is "restart" for a container started again, "oom" for one
+	// seen killed out of memory, and "replaced" for a new container taking
+	// over from PrevContainerID.
+	Reason          string
+	PrevContainerID string
+	// ExitCode is that of the exit before the restart, nil when the
+	// container ran again before it was inspected.
+	ExitCode     *int
+	RestartCount int
+	// FinishedAt is when the previous run ended and StartedAt when the next
+	// began, nil when unknown.
+	FinishedAt *time.Time
+	StartedAt  *time.Time
+	DetectedAt time.Time
+}
+
+// ContainerStatusChange is a container taking on Status at TS.
+type ContainerStatusChange struct {
+	ContainerID string
+	ServiceID   string
+	Status      string
+	TS          time.Time
+}
+
+// ContainerOutage is a "Container unavailable" alert of a container: it was
+// unreachable from From until To, nil while still firing.
+type ContainerOutage struct {
+	ContainerID string
+	ServiceID   string
+	From        time.Time
+	To          *time.Time
+}
+
+// AlertNote is a timestamped note on an alert, such as what was done about
+// it or its root cause.
+type AlertNote struct {
+	ID      int64
+	AlertID int64
+	TS      time.Time
+	Author  string
+	Text    string
+}
+
+// AlertWithTarget is an alert with its rule and, for alerts on a container
+// or service, the names of the container, service and host.
+type AlertWithTarget struct {
+	ID         int64
+	RuleName   string
+	MetricKey  string
+	TargetType string
+	Target     string
+	Status     string
+	Summary    string
+	Started    time.Time
+	Ended      *time.Time
+	Container  string
+	Service    string
+	Host       string
+}
+
+// StoragePool is the last known state of a ZFS pool or btrfs filesystem on
+// the local host.
+type StoragePool struct {
+	// Type is "zfs" or "btrfs"; btrfs filesystems are named by label, or
+	// by UUID when unlabeled.
+	Type string
+	Name string
+	// Health is the zpool state (ONLINE, DEGRADED, FAULTED, ...). btrfs
+	// filesystems are ONLINE, or DEGRADED while a device is missing.
+	Health     string
+	SizeBytes  int64
+	AllocBytes int64
+	// DeviceErrors sums the read, write and checksum errors of the pool's
+	// devices; DataErrors counts files with permanent errors (ZFS only).
+	DeviceErrors int64
+	DataErrors   int64
+	// ScrubState is "none", "scrubbing", "finished" or "canceled" (ZFS
+	// only; empty for btrfs). ScrubErrors and ScrubAt describe the last
+	// finished scrub.
+	ScrubState  string
+	ScrubErrors int64
+	ScrubAt     *time.Time
+	CheckedAt   time.Time
+}
+
+// Site is another dashi instance whose summary this one polls, e.g. the
+// instance of another site.
+type Site struct {
+	Name      string
+	URL       string
+	CheckedAt time.Time
+	// LastOKAt is when the summary was last fetched, nil if it never was.
+	LastOKAt *time.Time
+	// Error explains why the last poll failed; SummaryJSON then keeps the
+	// previous summary.
+	Error       string
+	SummaryJSON string
+}
+
+// ScrapeSample is a value of a series scraped from a Prometheus exporter.
+// Counters are stored as their per-second rate since the previous scrape.
+type ScrapeSample struct {
+	TS     time.Time
+	Target string
+	Metric string
+	Labels map[string]string
+	Value  float64
 }
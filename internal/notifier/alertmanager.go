@@ -0,0 +1,86 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AlertmanagerAlert is an alert in the format of Alertmanager's
+// POST /api/v2/alerts.
+type AlertmanagerAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       *time.Time        `json:"endsAt,omitempty"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+// Alertmanager posts alerts to one or more Alertmanagers. Like Prometheus,
+// it sends every alert to each of them, as the members of a cluster
+// deduplicate them.
+type Alertmanager struct {
+	URLs []string
+	HTTP *http.Client
+}
+
+func NewAlertmanager(urls []string) *Alertmanager {
+	return &Alertmanager{URLs: urls, HTTP: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (a *Alertmanager) Enabled() bool {
+	return len(a.URLs) > 0
+}
+
+// Send posts alerts to every Alertmanager and returns the errors of those
+// that failed.
+func (a *Alertmanager) Send(ctx context.Context, alerts []AlertmanagerAlert) error {
+	if !a.Enabled() {
+		return fmt.Errorf("alertmanager not configured")
+	}
+	b, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, u := range a.URLs {
+		if err := a.post(ctx, strings.TrimRight(u, "/")+"/api/v2/alerts", b); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", redactURL(u), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (a *Alertmanager) post(ctx context.Context, u string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := a.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	resp, _ := io.ReadAll(io.LimitReader(res.Body, 2048))
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("alertmanager status %d: %s", res.StatusCode, strings.TrimSpace(string(resp)))
+	}
+	return nil
+}
+
+// redactURL hides the password of u for logs.
+func redactURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	return parsed.Redacted()
+}
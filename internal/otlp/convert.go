@@ -0,0 +1,278 @@
+package otlp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"dashi/internal/logs"
+	"dashi/internal/models"
+	"dashi/internal/scrape"
+)
+
+const (
+	// Kind is the pseudo host of the services sending OTLP logs.
+	Kind = "otlp"
+	// Stream is the stream of the log entries received over OTLP.
+	Stream = "otlp"
+	// unknownService is the service.name the OpenTelemetry SDKs fall back
+	// to, used for resources without one.
+	unknownService = "unknown_service"
+	maxServiceName = 100
+)
+
+// Record is a log record with the service that sent it.
+type Record struct {
+	// Service is the resource's service.name, made a valid source name.
+	Service string
+	// Container is the resource's container.id, "" without one.
+	Container string
+	models.LogEntry
+}
+
+// Records returns the log records of req with a message, timestamped now
+// when they carry no time. Their attributes, trace and span IDs become
+// fields.
+func (req LogsRequest) Records(now time.Time) []Record {
+	var out []Record
+	for _, rl := range req.ResourceLogs {
+		service := ServiceName(rl.Resource.Attributes)
+		container := stringAttr(rl.Resource.Attributes, "container.id")
+		for _, sl := range rl.ScopeLogs {
+			for _, rec := range sl.LogRecords {
+				msg := rec.Body.String()
+				if strings.TrimSpace(msg) == "" {
+					continue
+				}
+				ts := now
+				if rec.TimeUnixNano != 0 {
+					ts = unixNano(rec.TimeUnixNano)
+				} else if rec.ObservedTimeUnixNano != 0 {
+					ts = unixNano(rec.ObservedTimeUnixNano)
+				}
+				fields := attributes(rec.Attributes)
+				if rec.TraceID != "" {
+					fields["trace_id"] = rec.TraceID
+				}
+				if rec.SpanID != "" {
+					fields["span_id"] = rec.SpanID
+				}
+				if len(fields) == 0 {
+					fields = nil
+				}
+				out = append(out, Record{Service: service, Container: container, LogEntry: models.LogEntry{
+					TS:      ts,
+					Stream:  Stream,
+					Level:   level(rec.SeverityNumber, rec.SeverityText, msg),
+					Message: msg,
+					Fields:  fields,
+				}})
+			}
+		}
+	}
+	return out
+}
+
+// level maps a severity number to dashi's levels, falling back to the
+// severity text and the message.
+func level(number int, text, msg string) string {
+	switch {
+	case number >= 17:
+		return "ERROR"
+	case number >= 13:
+		return "WARN"
+	case number >= 9:
+		return "INFO"
+	case number >= 1:
+		return "DEBUG"
+	}
+	return logs.ParseLevel(text, msg)
+}
+
+// Converter turns OTLP metrics into series stored like scraped ones, under
+// the name of the sending service as their target. Monotonic sums, and the
+// counts and sums of histograms and summaries, become per-second rates.
+type Converter struct {
+	rates *scrape.Rates
+}
+
+func NewConverter() *Converter {
+	return &Converter{rates: scrape.NewRates()}
+}
+
+// Samples returns the series of req, timestamped now where they carry no
+// time. Data points without a finite value, and cumulative ones until
+// their second export, are left out.
+func (c *Converter) Samples(req MetricsRequest, now time.Time) []models.ScrapeSample {
+	var out []models.ScrapeSample
+	for _, rm := range req.ResourceMetrics {
+		service := ServiceName(rm.Resource.Attributes)
+		add := func(metric string, attrs []KeyValue, start, ts Uint64, value float64, temporality int) {
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				return
+			}
+			labels := attributes(attrs)
+			at := now
+			if ts != 0 {
+				at = unixNano(ts)
+			}
+			switch temporality {
+			case Cumulative:
+				rate, ok := c.rates.Rate(service+"\x00"+scrape.SeriesName(metric, labels), at, value)
+				if !ok {
+					return
+				}
+				value = rate
+			case Delta:
+				if start == 0 || start >= ts {
+					return
+				}
+				value /= unixNano(ts).Sub(unixNano(start)).Seconds()
+			}
+			out = append(out, models.ScrapeSample{TS: at, Target: service, Metric: metric, Labels: labels, Value: value})
+		}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name == "" {
+					continue
+				}
+				switch {
+				case m.Gauge != nil:
+					for _, p := range m.Gauge.DataPoints {
+						add(m.Name, p.Attributes, p.StartTimeUnixNano, p.TimeUnixNano, p.value(), 0)
+					}
+				case m.Sum != nil:
+					// Non-monotonic sums, such as a queue length, are kept
+					// as they are.
+					temporality := 0
+					if m.Sum.IsMonotonic {
+						temporality = m.Sum.AggregationTemporality
+					}
+					for _, p := range m.Sum.DataPoints {
+						add(m.Name, p.Attributes, p.StartTimeUnixNano, p.TimeUnixNano, p.value(), temporality)
+					}
+				default:
+					h := m.Histogram
+					if h == nil {
+						h = m.ExponentialHistogram
+					}
+					if h == nil {
+						h = m.Summary
+					}
+					if h == nil {
+						continue
+					}
+					for _, p := range h.DataPoints {
+						add(m.Name+"_count", p.Attributes, p.StartTimeUnixNano, p.TimeUnixNano, float64(p.Count), h.AggregationTemporality)
+						if p.Sum != nil {
+							add(m.Name+"_sum", p.Attributes, p.StartTimeUnixNano, p.TimeUnixNano, float64(*p.Sum), h.AggregationTemporality)
+						}
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+func (p NumberDataPoint) value() float64 {
+	switch {
+	case p.AsDouble != nil:
+		return float64(*p.AsDouble)
+	case p.AsInt != nil:
+		return float64(*p.AsInt)
+	}
+	return math.NaN()
+}
+
+// ServiceName returns the service.name of a resource as a valid source
+// name: letters, digits, '.', '_' and '-', starting with a letter or digit.
+func ServiceName(attrs []KeyValue) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return '-'
+	}, strings.TrimSpace(stringAttr(attrs, "service.name")))
+	name = strings.TrimLeft(name, "._-")
+	if len(name) > maxServiceName {
+		name = name[:maxServiceName]
+	}
+	if name == "" {
+		return unknownService
+	}
+	return name
+}
+
+func stringAttr(attrs []KeyValue, key string) string {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value.String()
+		}
+	}
+	return ""
+}
+
+func attributes(attrs []KeyValue) map[string]string {
+	out := make(map[string]string, len(attrs))
+	for _, kv := range attrs {
+		if kv.Key != "" {
+			out[kv.Key] = kv.Value.String()
+		}
+	}
+	return out
+}
+
+func unixNano(ns Uint64) time.Time {
+	return time.Unix(0, int64(ns)).UTC()
+}
+
+// String renders v as text: scalars as they are, bytes in base64 and
+// arrays and maps as JSON.
+func (v AnyValue) String() string {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return strconv.FormatBool(*v.BoolValue)
+	case v.IntValue != nil:
+		return strconv.FormatInt(int64(*v.IntValue), 10)
+	case v.DoubleValue != nil:
+		return strconv.FormatFloat(float64(*v.DoubleValue), 'g', -1, 64)
+	case v.BytesValue != nil:
+		return base64.StdEncoding.EncodeToString(v.BytesValue)
+	case v.ArrayValue == nil && v.KvlistValue == nil:
+		return ""
+	}
+	raw, _ := json.Marshal(v.plain())
+	return string(raw)
+}
+
+// plain is v as a JSON value.
+func (v AnyValue) plain() any {
+	switch {
+	case v.ArrayValue != nil:
+		out := make([]any, 0, len(v.ArrayValue.Values))
+		for _, e := range v.ArrayValue.Values {
+			out = append(out, e.plain())
+		}
+		return out
+	case v.KvlistValue != nil:
+		out := make(map[string]any, len(v.KvlistValue.Values))
+		for _, kv := range v.KvlistValue.Values {
+			out[kv.Key] = kv.Value.plain()
+		}
+		return out
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		return int64(*v.IntValue)
+	case v.DoubleValue != nil && !math.IsNaN(float64(*v.DoubleValue)) && !math.IsInf(float64(*v.DoubleValue), 0):
+		return float64(*v.DoubleValue)
+	}
+	return v.String()
+}
@@ -0,0 +1,207 @@
+// Package otlp decodes OpenTelemetry OTLP/HTTP exports of logs and metrics,
+// in protobuf or JSON encoding, into dashi's log entries and series.
+package otlp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// The subset of the OTLP request messages dashi reads. The JSON tags follow
+// the OTLP/JSON mapping; the protobuf decoder in proto.go fills the same
+// types.
+
+type LogsRequest struct {
+	ResourceLogs []ResourceLogs `json:"resourceLogs"`
+}
+
+type ResourceLogs struct {
+	Resource  Resource    `json:"resource"`
+	ScopeLogs []ScopeLogs `json:"scopeLogs"`
+}
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+type ScopeLogs struct {
+	LogRecords []LogRecord `json:"logRecords"`
+}
+
+type LogRecord struct {
+	TimeUnixNano         Uint64     `json:"timeUnixNano"`
+	ObservedTimeUnixNano Uint64     `json:"observedTimeUnixNano"`
+	SeverityNumber       int        `json:"severityNumber"`
+	SeverityText         string     `json:"severityText"`
+	Body                 AnyValue   `json:"body"`
+	Attributes           []KeyValue `json:"attributes"`
+	// TraceID and SpanID are hex encoded.
+	TraceID string `json:"traceId"`
+	SpanID  string `json:"spanId"`
+}
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// AnyValue holds one of its fields, or none for an empty value.
+type AnyValue struct {
+	StringValue *string       `json:"stringValue,omitempty"`
+	BoolValue   *bool         `json:"boolValue,omitempty"`
+	IntValue    *Int64        `json:"intValue,omitempty"`
+	DoubleValue *Float64      `json:"doubleValue,omitempty"`
+	ArrayValue  *ArrayValue   `json:"arrayValue,omitempty"`
+	KvlistValue *KeyValueList `json:"kvlistValue,omitempty"`
+	BytesValue  []byte        `json:"bytesValue,omitempty"`
+}
+
+type ArrayValue struct {
+	Values []AnyValue `json:"values"`
+}
+
+type KeyValueList struct {
+	Values []KeyValue `json:"values"`
+}
+
+type MetricsRequest struct {
+	ResourceMetrics []ResourceMetrics `json:"resourceMetrics"`
+}
+
+type ResourceMetrics struct {
+	Resource     Resource       `json:"resource"`
+	ScopeMetrics []ScopeMetrics `json:"scopeMetrics"`
+}
+
+type ScopeMetrics struct {
+	Metrics []Metric `json:"metrics"`
+}
+
+// Metric holds one of Gauge, Sum, Histogram, ExponentialHistogram or
+// Summary.
+type Metric struct {
+	Name                 string     `json:"name"`
+	Unit                 string     `json:"unit"`
+	Gauge                *Gauge     `json:"gauge,omitempty"`
+	Sum                  *Sum       `json:"sum,omitempty"`
+	Histogram            *Histogram `json:"histogram,omitempty"`
+	ExponentialHistogram *Histogram `json:"exponentialHistogram,omitempty"`
+	Summary              *Histogram `json:"summary,omitempty"`
+}
+
+// Aggregation temporalities of sums and histograms.
+const (
+	Delta      = 1
+	Cumulative = 2
+)
+
+type Gauge struct {
+	DataPoints []NumberDataPoint `json:"dataPoints"`
+}
+
+type Sum struct {
+	DataPoints             []NumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type NumberDataPoint struct {
+	Attributes        []KeyValue `json:"attributes"`
+	StartTimeUnixNano Uint64     `json:"startTimeUnixNano"`
+	TimeUnixNano      Uint64     `json:"timeUnixNano"`
+	AsDouble          *Float64   `json:"asDouble,omitempty"`
+	AsInt             *Int64     `json:"asInt,omitempty"`
+}
+
+// Histogram stands for histograms, exponential histograms and summaries, of
+// which only the count and sum of observations are kept. Summaries are
+// always cumulative.
+type Histogram struct {
+	DataPoints             []HistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type HistogramDataPoint struct {
+	Attributes        []KeyValue `json:"attributes"`
+	StartTimeUnixNano Uint64     `json:"startTimeUnixNano"`
+	TimeUnixNano      Uint64     `json:"timeUnixNano"`
+	Count             Uint64     `json:"count"`
+	Sum               *Float64   `json:"sum,omitempty"`
+}
+
+// Uint64 is a 64-bit integer, which OTLP/JSON encodes as a decimal string;
+// plain numbers are accepted too.
+type Uint64 uint64
+
+func (v *Uint64) UnmarshalJSON(b []byte) error {
+	n, err := strconv.ParseUint(unquote(b), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %s", b)
+	}
+	*v = Uint64(n)
+	return nil
+}
+
+// Int64 is a signed Uint64.
+type Int64 int64
+
+func (v *Int64) UnmarshalJSON(b []byte) error {
+	n, err := strconv.ParseInt(unquote(b), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %s", b)
+	}
+	*v = Int64(n)
+	return nil
+}
+
+// Float64 is a double, which OTLP/JSON encodes as a number or as "NaN",
+// "Infinity" or "-Infinity".
+type Float64 float64
+
+func (v *Float64) UnmarshalJSON(b []byte) error {
+	var f float64
+	switch s := unquote(b); s {
+	case "NaN":
+		f = math.NaN()
+	case "Infinity":
+		f = math.Inf(1)
+	case "-Infinity":
+		f = math.Inf(-1)
+	default:
+		var err error
+		if f, err = strconv.ParseFloat(s, 64); err != nil {
+			return fmt.Errorf("invalid number %s", b)
+		}
+	}
+	*v = Float64(f)
+	return nil
+}
+
+func unquote(b []byte) string {
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		return s
+	}
+	return string(b)
+}
+
+// UnmarshalLogs decodes an export of logs, protobuf or else JSON encoded.
+func UnmarshalLogs(b []byte, protobuf bool) (LogsRequest, error) {
+	var req LogsRequest
+	if protobuf {
+		return req, decodeLogsRequest(b, &req)
+	}
+	return req, json.Unmarshal(b, &req)
+}
+
+// UnmarshalMetrics decodes an export of metrics, protobuf or else JSON
+// encoded.
+func UnmarshalMetrics(b []byte, protobuf bool) (MetricsRequest, error) {
+	var req MetricsRequest
+	if protobuf {
+		return req, decodeMetricsRequest(b, &req)
+	}
+	return req, json.Unmarshal(b, &req)
+}
@@ -0,0 +1,158 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+const logsJSON = `{"resourceLogs": [{
+	"resource": {"attributes": [
+		{"key": "service.name", "value": {"stringValue": "checkout api"}},
+		{"key": "container.id", "value": {"stringValue": "abc123"}}]},
+	"scopeLogs": [{"logRecords": [
+		{"timeUnixNano": "1767323045000000000", "severityNumber": 17, "body": {"stringValue": "payment failed"},
+		 "attributes": [{"key": "order", "value": {"intValue": "42"}}, {"key": "tags", "value": {"arrayValue": {"values": [{"stringValue": "a"}, {"boolValue": true}]}}}],
+		 "traceId": "5b8efff798038103d269b633813fc60c"},
+		{"severityText": "warn", "body": {"stringValue": "slow"}},
+		{"body": {}}]}]}]}`
+
+func TestLogsJSON(t *testing.T) {
+	req, err := UnmarshalLogs([]byte(logsJSON), false)
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	now := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	recs := req.Records(now)
+	if len(recs) != 2 {
+		t.Fatalf("records = %+v", recs)
+	}
+	first := recs[0]
+	if first.Service != "checkout-api" || first.Container != "abc123" || first.Level != "ERROR" || first.Message != "payment failed" ||
+		!first.TS.Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)) || first.Stream != Stream {
+		t.Fatalf("first = %+v", first)
+	}
+	if first.Fields["order"] != "42" || first.Fields["tags"] != `["a",true]` || first.Fields["trace_id"] != "5b8efff798038103d269b633813fc60c" {
+		t.Fatalf("fields = %v", first.Fields)
+	}
+	if second := recs[1]; second.Level != "WARN" || !second.TS.Equal(now) || second.Fields != nil {
+		t.Fatalf("second = %+v", second)
+	}
+}
+
+func TestServiceName(t *testing.T) {
+	for in, want := range map[string]string{
+		"":             unknownService,
+		"  billing  ":  "billing",
+		"_svc/worker:": "svc-worker-",
+	} {
+		attrs := []KeyValue{{Key: "service.name", Value: AnyValue{StringValue: &in}}}
+		if got := ServiceName(attrs); got != want {
+			t.Fatalf("ServiceName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// pb builds protobuf messages for the tests.
+type pb []byte
+
+func (b pb) tag(field, typ int) pb { return binary.AppendUvarint(b, uint64(field<<3|typ)) }
+func (b pb) varint(field int, v uint64) pb {
+	return binary.AppendUvarint(b.tag(field, wireVarint), v)
+}
+func (b pb) fixed64(field int, v uint64) pb {
+	return binary.LittleEndian.AppendUint64(b.tag(field, wireFixed64), v)
+}
+func (b pb) double(field int, v float64) pb { return b.fixed64(field, math.Float64bits(v)) }
+func (b pb) bytes(field int, v []byte) pb {
+	return append(binary.AppendUvarint(b.tag(field, wireBytes), uint64(len(v))), v...)
+}
+func (b pb) str(field int, v string) pb { return b.bytes(field, []byte(v)) }
+
+func attr(key, value string) pb {
+	return pb{}.str(1, key).bytes(2, pb{}.str(1, value))
+}
+
+func TestLogsProtobuf(t *testing.T) {
+	rec := pb{}.fixed64(1, 1767323045000000000).varint(2, 10).bytes(5, pb{}.str(1, "order placed")).
+		bytes(6, attr("user", "ada")).bytes(9, []byte{0xab, 0xcd}).fixed64(12, 7) // field 12 is unknown
+	body := pb{}.bytes(1, pb{}.
+		bytes(1, pb{}.bytes(1, attr("service.name", "shop"))).
+		bytes(2, pb{}.bytes(1, pb{}.str(1, "scope")).bytes(2, rec)))
+	req, err := UnmarshalLogs(body, true)
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	recs := req.Records(time.Now())
+	if len(recs) != 1 || recs[0].Service != "shop" || recs[0].Level != "INFO" || recs[0].Message != "order placed" ||
+		recs[0].Fields["user"] != "ada" || recs[0].Fields["trace_id"] != "abcd" || recs[0].TS.Year() != 2026 {
+		t.Fatalf("records = %+v", recs)
+	}
+	if _, err := UnmarshalLogs(body[:len(body)-3], true); err == nil {
+		t.Fatal("truncated export accepted")
+	}
+}
+
+func TestMetricsProtobufToSamples(t *testing.T) {
+	start := uint64(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	at := func(sec int) uint64 { return start + uint64(sec)*uint64(time.Second) }
+	export := func(sec int, requests float64) []byte {
+		point := func(p pb) pb { return p.fixed64(2, start).fixed64(3, at(sec)) }
+		metrics := pb{}.
+			bytes(2, pb{}.str(1, "queue.depth").bytes(5, pb{}.bytes(1, point(pb{}).double(4, 7)))).
+			bytes(2, pb{}.str(1, "http.requests").bytes(7, pb{}.
+				bytes(1, point(pb{}.bytes(7, attr("route", "/pay"))).fixed64(6, uint64(requests))).
+				varint(2, Cumulative).varint(3, 1))).
+			bytes(2, pb{}.str(1, "jobs.done").bytes(7, pb{}.
+				bytes(1, point(pb{}).double(4, 30)).varint(2, Delta).varint(3, 1))).
+			bytes(2, pb{}.str(1, "latency").bytes(9, pb{}.
+				bytes(1, point(pb{}.bytes(9, attr("route", "/pay"))).fixed64(4, uint64(requests)).double(5, requests/10)).
+				varint(2, Cumulative)))
+		return pb{}.bytes(1, pb{}.bytes(1, pb{}.bytes(1, attr("service.name", "shop"))).bytes(2, metrics))
+	}
+	c := NewConverter()
+	decode := func(b []byte) MetricsRequest {
+		req, err := UnmarshalMetrics(b, true)
+		if err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		return req
+	}
+	first := c.Samples(decode(export(10, 100)), time.Now())
+	got := map[string]float64{}
+	for _, s := range first {
+		got[s.Metric] = s.Value
+		if s.Target != "shop" {
+			t.Fatalf("sample = %+v", s)
+		}
+	}
+	// Cumulative series only have a rate from their second export.
+	if len(got) != 2 || got["queue.depth"] != 7 || got["jobs.done"] != 3 {
+		t.Fatalf("first export = %+v", first)
+	}
+	second := c.Samples(decode(export(20, 150)), time.Now())
+	got = map[string]float64{}
+	for _, s := range second {
+		got[s.Metric] = s.Value
+		if s.Metric == "http.requests" && s.Labels["route"] != "/pay" {
+			t.Fatalf("labels = %v", s.Labels)
+		}
+	}
+	if got["http.requests"] != 5 || got["latency_count"] != 5 || got["latency_sum"] != 0.5 || got["jobs.done"] != 1.5 {
+		t.Fatalf("second export = %+v", second)
+	}
+}
+
+func TestMetricsJSON(t *testing.T) {
+	raw := `{"resourceMetrics": [{"scopeMetrics": [{"metrics": [
+		{"name": "temp", "gauge": {"dataPoints": [{"asDouble": 21.5, "timeUnixNano": "1767323045000000000"}, {"asDouble": "NaN"}, {"asInt": "3"}]}}]}]}]}`
+	req, err := UnmarshalMetrics([]byte(raw), false)
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	samples := NewConverter().Samples(req, time.Now())
+	if len(samples) != 2 || samples[0].Target != unknownService || samples[0].Value != 21.5 || samples[1].Value != 3 {
+		t.Fatalf("samples = %+v", samples)
+	}
+}
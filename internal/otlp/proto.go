@@ -0,0 +1,491 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated protobuf message")
+
+// wire reads the fields of one protobuf message.
+type wire struct {
+	b []byte
+}
+
+// fields calls fn with the number and wire type of each field of message
+// b; fn reads the field's value or skips it.
+func fields(b []byte, fn func(w *wire, field, typ int) error) error {
+	w := &wire{b: b}
+	for len(w.b) > 0 {
+		key, err := w.uvarint()
+		if err != nil {
+			return err
+		}
+		field, typ := int(key>>3), int(key&7)
+		if field == 0 {
+			return errors.New("invalid protobuf field number 0")
+		}
+		if err := fn(w, field, typ); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *wire) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(w.b)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	w.b = w.b[n:]
+	return v, nil
+}
+
+func (w *wire) varint(typ int) (uint64, error) {
+	if typ != wireVarint {
+		return 0, fmt.Errorf("protobuf wire type %d where a varint was expected", typ)
+	}
+	return w.uvarint()
+}
+
+func (w *wire) fixed64(typ int) (uint64, error) {
+	if typ != wireFixed64 {
+		return 0, fmt.Errorf("protobuf wire type %d where a fixed64 was expected", typ)
+	}
+	if len(w.b) < 8 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint64(w.b)
+	w.b = w.b[8:]
+	return v, nil
+}
+
+func (w *wire) double(typ int) (Float64, error) {
+	v, err := w.fixed64(typ)
+	return Float64(math.Float64frombits(v)), err
+}
+
+func (w *wire) bytes(typ int) ([]byte, error) {
+	if typ != wireBytes {
+		return nil, fmt.Errorf("protobuf wire type %d where bytes were expected", typ)
+	}
+	n, err := w.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(w.b)) {
+		return nil, errTruncated
+	}
+	v := w.b[:n]
+	w.b = w.b[n:]
+	return v, nil
+}
+
+func (w *wire) string(typ int) (string, error) {
+	b, err := w.bytes(typ)
+	return string(b), err
+}
+
+func (w *wire) skip(typ int) error {
+	var err error
+	switch typ {
+	case wireVarint:
+		_, err = w.uvarint()
+	case wireFixed64:
+		_, err = w.fixed64(typ)
+	case wireBytes:
+		_, err = w.bytes(typ)
+	case wireFixed32:
+		if len(w.b) < 4 {
+			return errTruncated
+		}
+		w.b = w.b[4:]
+	default:
+		err = fmt.Errorf("unsupported protobuf wire type %d", typ)
+	}
+	return err
+}
+
+// message decodes the embedded message of a field with dec.
+func message[T any](w *wire, typ int, dec func([]byte, *T) error) (T, error) {
+	var v T
+	b, err := w.bytes(typ)
+	if err != nil {
+		return v, err
+	}
+	return v, dec(b, &v)
+}
+
+func decodeLogsRequest(b []byte, req *LogsRequest) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		if field != 1 {
+			return w.skip(typ)
+		}
+		rl, err := message(w, typ, decodeResourceLogs)
+		req.ResourceLogs = append(req.ResourceLogs, rl)
+		return err
+	})
+}
+
+func decodeResourceLogs(b []byte, rl *ResourceLogs) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		var err error
+		switch field {
+		case 1:
+			rl.Resource, err = message(w, typ, decodeResource)
+		case 2:
+			var sl ScopeLogs
+			sl, err = message(w, typ, decodeScopeLogs)
+			rl.ScopeLogs = append(rl.ScopeLogs, sl)
+		default:
+			err = w.skip(typ)
+		}
+		return err
+	})
+}
+
+func decodeResource(b []byte, r *Resource) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		if field != 1 {
+			return w.skip(typ)
+		}
+		kv, err := message(w, typ, decodeKeyValue)
+		r.Attributes = append(r.Attributes, kv)
+		return err
+	})
+}
+
+func decodeScopeLogs(b []byte, sl *ScopeLogs) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		if field != 2 {
+			return w.skip(typ)
+		}
+		rec, err := message(w, typ, decodeLogRecord)
+		sl.LogRecords = append(sl.LogRecords, rec)
+		return err
+	})
+}
+
+func decodeLogRecord(b []byte, rec *LogRecord) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		switch field {
+		case 1:
+			v, err := w.fixed64(typ)
+			rec.TimeUnixNano = Uint64(v)
+			return err
+		case 11:
+			v, err := w.fixed64(typ)
+			rec.ObservedTimeUnixNano = Uint64(v)
+			return err
+		case 2:
+			v, err := w.varint(typ)
+			rec.SeverityNumber = int(v)
+			return err
+		case 3:
+			v, err := w.string(typ)
+			rec.SeverityText = v
+			return err
+		case 5:
+			v, err := message(w, typ, decodeAnyValue)
+			rec.Body = v
+			return err
+		case 6:
+			kv, err := message(w, typ, decodeKeyValue)
+			rec.Attributes = append(rec.Attributes, kv)
+			return err
+		case 9:
+			v, err := w.bytes(typ)
+			rec.TraceID = hex.EncodeToString(v)
+			return err
+		case 10:
+			v, err := w.bytes(typ)
+			rec.SpanID = hex.EncodeToString(v)
+			return err
+		}
+		return w.skip(typ)
+	})
+}
+
+func decodeKeyValue(b []byte, kv *KeyValue) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		var err error
+		switch field {
+		case 1:
+			kv.Key, err = w.string(typ)
+		case 2:
+			kv.Value, err = message(w, typ, decodeAnyValue)
+		default:
+			err = w.skip(typ)
+		}
+		return err
+	})
+}
+
+func decodeAnyValue(b []byte, v *AnyValue) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		switch field {
+		case 1:
+			s, err := w.string(typ)
+			v.StringValue = &s
+			return err
+		case 2:
+			n, err := w.varint(typ)
+			ok := n != 0
+			v.BoolValue = &ok
+			return err
+		case 3:
+			n, err := w.varint(typ)
+			i := Int64(n)
+			v.IntValue = &i
+			return err
+		case 4:
+			f, err := w.double(typ)
+			v.DoubleValue = &f
+			return err
+		case 5:
+			arr, err := message(w, typ, decodeArrayValue)
+			v.ArrayValue = &arr
+			return err
+		case 6:
+			list, err := message(w, typ, decodeKeyValueList)
+			v.KvlistValue = &list
+			return err
+		case 7:
+			raw, err := w.bytes(typ)
+			v.BytesValue = raw
+			return err
+		}
+		return w.skip(typ)
+	})
+}
+
+func decodeArrayValue(b []byte, arr *ArrayValue) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		if field != 1 {
+			return w.skip(typ)
+		}
+		v, err := message(w, typ, decodeAnyValue)
+		arr.Values = append(arr.Values, v)
+		return err
+	})
+}
+
+func decodeKeyValueList(b []byte, list *KeyValueList) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		if field != 1 {
+			return w.skip(typ)
+		}
+		kv, err := message(w, typ, decodeKeyValue)
+		list.Values = append(list.Values, kv)
+		return err
+	})
+}
+
+func decodeMetricsRequest(b []byte, req *MetricsRequest) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		if field != 1 {
+			return w.skip(typ)
+		}
+		rm, err := message(w, typ, decodeResourceMetrics)
+		req.ResourceMetrics = append(req.ResourceMetrics, rm)
+		return err
+	})
+}
+
+func decodeResourceMetrics(b []byte, rm *ResourceMetrics) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		var err error
+		switch field {
+		case 1:
+			rm.Resource, err = message(w, typ, decodeResource)
+		case 2:
+			var sm ScopeMetrics
+			sm, err = message(w, typ, decodeScopeMetrics)
+			rm.ScopeMetrics = append(rm.ScopeMetrics, sm)
+		default:
+			err = w.skip(typ)
+		}
+		return err
+	})
+}
+
+func decodeScopeMetrics(b []byte, sm *ScopeMetrics) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		if field != 2 {
+			return w.skip(typ)
+		}
+		m, err := message(w, typ, decodeMetric)
+		sm.Metrics = append(sm.Metrics, m)
+		return err
+	})
+}
+
+func decodeMetric(b []byte, m *Metric) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		var err error
+		switch field {
+		case 1:
+			m.Name, err = w.string(typ)
+		case 3:
+			m.Unit, err = w.string(typ)
+		case 5:
+			var g Gauge
+			g, err = message(w, typ, decodeGauge)
+			m.Gauge = &g
+		case 7:
+			var s Sum
+			s, err = message(w, typ, decodeSum)
+			m.Sum = &s
+		case 9:
+			var h Histogram
+			h, err = message(w, typ, histogramDecoder(decodeHistogramDataPoint))
+			m.Histogram = &h
+		case 10:
+			var h Histogram
+			h, err = message(w, typ, histogramDecoder(decodeExponentialHistogramDataPoint))
+			m.ExponentialHistogram = &h
+		case 11:
+			var h Histogram
+			h, err = message(w, typ, histogramDecoder(decodeSummaryDataPoint))
+			h.AggregationTemporality = Cumulative
+			m.Summary = &h
+		default:
+			err = w.skip(typ)
+		}
+		return err
+	})
+}
+
+func decodeGauge(b []byte, g *Gauge) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		if field != 1 {
+			return w.skip(typ)
+		}
+		p, err := message(w, typ, decodeNumberDataPoint)
+		g.DataPoints = append(g.DataPoints, p)
+		return err
+	})
+}
+
+func decodeSum(b []byte, s *Sum) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		switch field {
+		case 1:
+			p, err := message(w, typ, decodeNumberDataPoint)
+			s.DataPoints = append(s.DataPoints, p)
+			return err
+		case 2:
+			v, err := w.varint(typ)
+			s.AggregationTemporality = int(v)
+			return err
+		case 3:
+			v, err := w.varint(typ)
+			s.IsMonotonic = v != 0
+			return err
+		}
+		return w.skip(typ)
+	})
+}
+
+func decodeNumberDataPoint(b []byte, p *NumberDataPoint) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		switch field {
+		case 7:
+			kv, err := message(w, typ, decodeKeyValue)
+			p.Attributes = append(p.Attributes, kv)
+			return err
+		case 2:
+			v, err := w.fixed64(typ)
+			p.StartTimeUnixNano = Uint64(v)
+			return err
+		case 3:
+			v, err := w.fixed64(typ)
+			p.TimeUnixNano = Uint64(v)
+			return err
+		case 4:
+			f, err := w.double(typ)
+			p.AsDouble = &f
+			return err
+		case 6:
+			v, err := w.fixed64(typ)
+			i := Int64(v)
+			p.AsInt = &i
+			return err
+		}
+		return w.skip(typ)
+	})
+}
+
+// histogramDecoder decodes a histogram, exponential histogram or summary,
+// which differ in their data points.
+func histogramDecoder(point func([]byte, *HistogramDataPoint) error) func([]byte, *Histogram) error {
+	return func(b []byte, h *Histogram) error {
+		return fields(b, func(w *wire, field, typ int) error {
+			switch field {
+			case 1:
+				p, err := message(w, typ, point)
+				h.DataPoints = append(h.DataPoints, p)
+				return err
+			case 2:
+				v, err := w.varint(typ)
+				h.AggregationTemporality = int(v)
+				return err
+			}
+			return w.skip(typ)
+		})
+	}
+}
+
+// histogramPoint decodes the fields histogram, exponential histogram and
+// summary data points share: times, count and sum, with the attributes
+// under field attributes.
+func histogramPoint(b []byte, p *HistogramDataPoint, attributes int) error {
+	return fields(b, func(w *wire, field, typ int) error {
+		switch field {
+		case attributes:
+			kv, err := message(w, typ, decodeKeyValue)
+			p.Attributes = append(p.Attributes, kv)
+			return err
+		case 2:
+			v, err := w.fixed64(typ)
+			p.StartTimeUnixNano = Uint64(v)
+			return err
+		case 3:
+			v, err := w.fixed64(typ)
+			p.TimeUnixNano = Uint64(v)
+			return err
+		case 4:
+			v, err := w.fixed64(typ)
+			p.Count = Uint64(v)
+			return err
+		case 5:
+			f, err := w.double(typ)
+			p.Sum = &f
+			return err
+		}
+		return w.skip(typ)
+	})
+}
+
+func decodeHistogramDataPoint(b []byte, p *HistogramDataPoint) error {
+	return histogramPoint(b, p, 9)
+}
+
+func decodeExponentialHistogramDataPoint(b []byte, p *HistogramDataPoint) error {
+	return histogramPoint(b, p, 1)
+}
+
+func decodeSummaryDataPoint(b []byte, p *HistogramDataPoint) error {
+	return histogramPoint(b, p, 7)
+}
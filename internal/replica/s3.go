@@ -0,0 +1,181 @@
+package replica
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 is a minimal path-style client for S3-compatible object stores
+// (AWS, MinIO, Backblaze, R2), signed with AWS Signature Version 4.
+type S3 struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	HTTP      *http.Client
+	now       func() time.Time
+}
+
+func NewS3(endpoint, region, bucket, accessKey, secretKey string) *S3 {
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3{
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		Region:    region,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		HTTP:      &http.Client{Timeout: 5 * time.Minute},
+		now:       time.Now,
+	}
+}
+
+var ErrNotFound = fmt.Errorf("object not found")
+
+func (c *S3) Put(ctx context.Context, key string, body []byte) error {
+	return c.put(ctx, key, bytes.NewReader(body), int64(len(body)), sha256Hex(body))
+}
+
+// PutFile uploads the file at name. The file is read twice, to hash and to
+// send it, so it never has to fit in memory.
+func (c *S3) PutFile(ctx context.Context, key, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return c.put(ctx, key, f, size, hex.EncodeToString(h.Sum(nil)))
+}
+
+// put sends size bytes of body, which S3 needs up front: it does not take
+// chunked uploads without aws-chunked signing.
+func (c *S3) put(ctx context.Context, key string, body io.Reader, size int64, payloadHash string) error {
+	req, err := c.newRequest(ctx, http.MethodPut, key, body, payloadHash)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 2048))
+		return fmt.Errorf("s3 put %s status %d: %s", key, res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Get returns the object body; the caller must close it.
+func (c *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, key, nil, sha256Hex(nil))
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil, ErrNotFound
+	}
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 2048))
+		return nil, fmt.Errorf("s3 get %s status %d: %s", key, res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return res.Body, nil
+}
+
+func (c *S3) newRequest(ctx context.Context, method, key string, body io.Reader, payloadHash string) (*http.Request, error) {
+	segments := strings.Split(c.Bucket+"/"+strings.TrimPrefix(key, "/"), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	if body == nil {
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.Endpoint+"/"+strings.Join(segments, "/"), body)
+	if err != nil {
+		return nil, err
+	}
+	sign(req, payloadHash, c.AccessKey, c.SecretKey, c.Region, c.now().UTC())
+	return req, nil
+}
+
+// sign adds SigV4 headers, signing host, x-amz-* and any headers already set
+// on req, for a body hashing to payloadHash.
+func sign(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
@@ -0,0 +1,80 @@
+package replica
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"dashi/internal/db"
+)
+
+func TestReplicateThenRestore(t *testing.T) {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			b, _ := io.ReadAll(r.Body)
+			// Uploads have a length and the hash of what was sent, as S3
+			// takes neither chunked nor mis-signed bodies.
+			if r.ContentLength != int64(len(b)) || len(r.TransferEncoding) > 0 || r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(b) {
+				t.Errorf("PUT length %d of %d, encoding %v", r.ContentLength, len(b), r.TransferEncoding)
+			}
+			objects[r.URL.Path] = b
+		case http.MethodGet:
+			b, ok := objects[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(b)
+		}
+	}))
+	defer srv.Close()
+	store := NewS3(srv.URL, "", "bucket", "key", "secret")
+
+	dir := t.TempDir()
+	sqldb, err := db.Open(filepath.Join(dir, "app.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	svc := NewService(db.NewRepository(sqldb), store, "dashi", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if _, err := svc.replicate(context.Background()); err != nil {
+		t.Fatalf("replicate: %v", err)
+	}
+	if _, ok := objects["/bucket/dashi/latest.db.gz"]; !ok {
+		t.Fatalf("replica not uploaded, objects: %v", len(objects))
+	}
+
+	restorePath := filepath.Join(dir, "restored", "app.db")
+	restored, err := Restore(context.Background(), store, "dashi", restorePath)
+	if err != nil || !restored {
+		t.Fatalf("restore = %v, %v", restored, err)
+	}
+	rdb, err := sql.Open("sqlite3", restorePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+	var rules int
+	if err := rdb.QueryRow(`SELECT COUNT(*) FROM alert_rules`).Scan(&rules); err != nil || rules == 0 {
+		t.Fatalf("restored db rules = %d, err %v", rules, err)
+	}
+
+	again, err := Restore(context.Background(), store, "dashi", restorePath)
+	if err != nil || again {
+		t.Fatalf("restore over existing db = %v, %v; want no-op", again, err)
+	}
+}
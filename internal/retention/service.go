@@ -2,30 +2,143 @@ package retention
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"time"
 
 	"dashi/internal/db"
+	"dashi/internal/models"
+	"dashi/internal/settings"
 )
 
+// MaxDays caps every retention window.
+const MaxDays = 3650
+
 type Service struct {
-	repo          *db.Repository
-	retentionDays int
-	log           *slog.Logger
+	repo     *db.Repository
+	settings *settings.Store
+	defaults models.RetentionPolicy
+	// archiveAfter is how long a container may stay missing before it is
+	// archived; zero disables archiving.
+	archiveAfter time.Duration
+	// logArchiver, when set, exports logs before they are deleted.
+	logArchiver LogArchiver
+	log         *slog.Logger
+}
+
+// LogArchiver exports the logs older than cutoff, e.g. a
+// logarchive.Archiver. Logs are only deleted after it succeeded.
+type LogArchiver interface {
+	Archive(ctx context.Context, cutoff time.Time) error
+}
+
+func NewService(repo *db.Repository, store *settings.Store, defaults models.RetentionPolicy, archiveAfter time.Duration, logArchiver LogArchiver, logger *slog.Logger) *Service {
+	store.Validate("retention", validateDays)
+	return &Service{repo: repo, settings: store, defaults: Normalize(defaults), archiveAfter: archiveAfter, logArchiver: logArchiver, log: logger}
+}
+
+func validateDays(_ string, v json.RawMessage) error {
+	var n int
+	if err := json.Unmarshal(v, &n); err != nil || n <= 0 || n > MaxDays {
+		return errors.New("must be a whole number of days between 1 and 3650")
+	}
+	return nil
+}
+
+// Normalize fills unset windows and keeps rollups at least as long as the
+// raw samples they summarize.
+func Normalize(p models.RetentionPolicy) models.RetentionPolicy {
+	if p.LogsDays <= 0 {
+		p.LogsDays = 14
+	}
+	if p.MetricsDays <= 0 {
+		p.MetricsDays = 14
+	}
+	if p.AlertsDays <= 0 {
+		p.AlertsDays = 14
+	}
+	if p.RollupDays < p.MetricsDays {
+		p.RollupDays = p.MetricsDays
+	}
+	return p
+}
+
+func (s *Service) Defaults() models.RetentionPolicy { return s.defaults }
+
+// Policy returns the effective policy, including overrides from settings.
+func (s *Service) Policy(ctx context.Context) models.RetentionPolicy {
+	return Normalize(models.RetentionPolicy{
+		LogsDays:    s.settings.Int(ctx, "retention.logs_days", s.defaults.LogsDays),
+		MetricsDays: s.settings.Int(ctx, "retention.metrics_days", s.defaults.MetricsDays),
+		RollupDays:  s.settings.Int(ctx, "retention.rollups_days", s.defaults.RollupDays),
+		AlertsDays:  s.settings.Int(ctx, "retention.alerts_days", s.defaults.AlertsDays),
+	})
 }
 
-func NewService(repo *db.Repository, days int, logger *slog.Logger) *Service {
-	if days <= 0 {
-		days = 14
+// Save stores p as the runtime override of the configured defaults.
+func (s *Service) Save(ctx context.Context, p models.RetentionPolicy) error {
+	p = Normalize(p)
+	for key, days := range map[string]int{
+		"retention.logs_days":    p.LogsDays,
+		"retention.metrics_days": p.MetricsDays,
+		"retention.rollups_days": p.RollupDays,
+		"retention.alerts_days":  p.AlertsDays,
+	} {
+		if err := s.settings.Set(ctx, key, days); err != nil {
+			return err
+		}
 	}
-	return &Service{repo: repo, retentionDays: days, log: logger}
+	return nil
 }
 
 func (s *Service) Run(ctx context.Context) {
-	cutoff := time.Now().UTC().AddDate(0, 0, -s.retentionDays)
-	if err := s.repo.DeleteOlderThan(ctx, cutoff); err != nil {
-		s.log.Error("retention cleanup failed", "err", err)
-	} else {
-		s.log.Info("retention cleanup completed", "cutoff", cutoff)
+	p := s.Policy(ctx)
+	now := time.Now().UTC()
+	if s.archiveAfter > 0 {
+		n, err := s.repo.ArchiveMissingContainers(ctx, now.Add(-s.archiveAfter))
+		if err != nil {
+			s.log.Error("archive missing containers failed", "err", err)
+		} else if n > 0 {
+			s.log.Info("archived missing containers", "count", n)
+		}
+	}
+	steps := []struct {
+		name string
+		days int
+		fn   func(context.Context, time.Time) error
+	}{
+		{"logs", p.LogsDays, s.deleteLogs},
+		{"metrics", p.MetricsDays, s.repo.DeleteMetricsOlderThan},
+		{"rollups", p.RollupDays, s.repo.DeleteRollupsOlderThan},
+		{"alerts", p.AlertsDays, s.repo.DeleteAlertsOlderThan},
+		// Deployments mark charts as long as rollups cover them.
+		{"deployments", p.RollupDays, s.repo.DeleteDeploymentsOlderThan},
+		{"restart events", p.RollupDays, s.repo.DeleteRestartEventsOlderThan},
+		// Availability looks back 30 days at most.
+		{"status changes", max(p.RollupDays, 30), s.repo.DeleteStatusChangesOlderThan},
+		// Agents repeat a push within minutes, if at all.
+		{"ingest keys", 1, s.repo.DeleteIngestKeysOlderThan},
+	}
+	for _, step := range steps {
+		cutoff := now.AddDate(0, 0, -step.days)
+		if err := step.fn(ctx, cutoff); err != nil {
+			s.log.Error("retention cleanup failed", "err", err, "data", step.name)
+			continue
+		}
+		s.log.Info("retention cleanup completed", "data", step.name, "cutoff", cutoff)
+	}
+	s.repo.Compact(ctx)
+}
+
+// deleteLogs archives the logs older than cutoff first, if archiving is set
+// up, and keeps them when that fails.
+func (s *Service) deleteLogs(ctx context.Context, cutoff time.Time) error {
+	if s.logArchiver != nil {
+		if err := s.logArchiver.Archive(ctx, cutoff); err != nil {
+			return fmt.Errorf("archive logs: %w", err)
+		}
 	}
+	return s.repo.DeleteLogsOlderThan(ctx, cutoff)
 }
@@ -0,0 +1,78 @@
+package rollup
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"dashi/internal/db"
+)
+
+// Resolutions are the bucket widths maintained by the rollup job, finest first.
+var Resolutions = []time.Duration{time.Minute, 5 * time.Minute, time.Hour}
+
+type Service struct {
+	repo *db.Repository
+	log  *slog.Logger
+	now  func() time.Time
+}
+
+func NewService(repo *db.Repository, logger *slog.Logger) *Service {
+	return &Service{repo: repo, log: logger, now: time.Now}
+}
+
+// Run rolls up every bucket that has closed since the previous run. The most
+// recent existing bucket is recomputed to pick up late samples.
+func (s *Service) Run(ctx context.Context) {
+	now := s.now().UTC()
+	for _, res := range Resolutions {
+		to := now.Truncate(res)
+		from, err := s.repo.LatestRollupBucket(ctx, res)
+		if err != nil {
+			s.log.Error("load latest rollup bucket", "err", err, "resolution", res)
+			continue
+		}
+		if !from.IsZero() && !from.Before(to) {
+			continue
+		}
+		if err := s.repo.RollupHostMetrics(ctx, res, from, to); err != nil {
+			s.log.Error("rollup host metrics", "err", err, "resolution", res)
+			continue
+		}
+		if err := s.repo.RollupContainerMetrics(ctx, res, from, to); err != nil {
+			s.log.Error("rollup container metrics", "err", err, "resolution", res)
+		}
+	}
+}
+
+// PickResolution returns the coarsest-needed resolution for a query range,
+// or 0 when raw samples should be served.
+func PickResolution(rng time.Duration) time.Duration {
+	switch {
+	case rng <= 3*time.Hour:
+		return 0
+	case rng <= 24*time.Hour:
+		return time.Minute
+	case rng <= 7*24*time.Hour:
+		return 5 * time.Minute
+	default:
+		return time.Hour
+	}
+}
+
+// ParseResolution accepts "raw" or one of the maintained resolutions.
+func ParseResolution(v string) (time.Duration, bool) {
+	if v == "raw" {
+		return 0, true
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, false
+	}
+	for _, res := range Resolutions {
+		if d == res {
+			return d, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,66 @@
+package rollup
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+func TestRunAggregatesHostMetrics(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+
+	base := time.Date(2026, 2, 21, 12, 0, 0, 0, time.UTC)
+	for i, cpu := range []float64{10, 20, 60} {
+		m := models.HostMetric{TS: base.Add(time.Duration(i) * 10 * time.Second), CPUPct: cpu, MemTotalBytes: 100}
+		if err := repo.InsertHostMetric(ctx, m); err != nil {
+			t.Fatalf("insert host metric: %v", err)
+		}
+	}
+
+	svc := NewService(repo, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	svc.now = func() time.Time { return base.Add(2 * time.Minute) }
+	svc.Run(ctx)
+
+	rollups, err := repo.HostMetricRollups(ctx, time.Minute, base.Add(-time.Hour), 10)
+	if err != nil {
+		t.Fatalf("host rollups: %v", err)
+	}
+	if len(rollups) != 1 {
+		t.Fatalf("rollups len = %d, want 1", len(rollups))
+	}
+	got := rollups[0]
+	if !got.TS.Equal(base) || got.Samples != 3 || got.CPUPct != 30 || got.CPUPctMin != 10 || got.CPUPctMax != 60 {
+		t.Fatalf("unexpected rollup: %+v", got)
+	}
+}
+
+func TestPickResolution(t *testing.T) {
+	cases := []struct {
+		rng  time.Duration
+		want time.Duration
+	}{
+		{time.Hour, 0},
+		{12 * time.Hour, time.Minute},
+		{72 * time.Hour, 5 * time.Minute},
+		{30 * 24 * time.Hour, time.Hour},
+	}
+	for _, tc := range cases {
+		if got := PickResolution(tc.rng); got != tc.want {
+			t.Fatalf("PickResolution(%s) = %s, want %s", tc.rng, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Package schedule provides the jittered tickers dashi's periodic jobs run
+// on, so jobs sharing an interval do not hit Docker and the database in the
+// same instant.
+package schedule
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Ticker delivers ticks about every interval, each wait moved by up to a
+// tenth of the interval either way. Like time.Ticker it drops ticks for a
+// slow receiver.
+type Ticker struct {
+	C <-chan time.Time
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewTicker starts a ticker with interval d, which must be positive.
+func NewTicker(d time.Duration) *Ticker {
+	if d <= 0 {
+		panic("schedule: non-positive interval for NewTicker")
+	}
+	c := make(chan time.Time, 1)
+	t := &Ticker{C: c, stop: make(chan struct{}), done: make(chan struct{})}
+	go t.run(d, c)
+	return t
+}
+
+// Stop turns the ticker off; no tick is sent once it returns. It does not
+// close C.
+func (t *Ticker) Stop() {
+	t.once.Do(func() { close(t.stop) })
+	<-t.done
+}
+
+func (t *Ticker) run(d time.Duration, c chan time.Time) {
+	defer close(t.done)
+	timer := time.NewTimer(Jitter(d))
+	defer timer.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case now := <-timer.C:
+			select {
+			case c <- now:
+			default:
+			}
+			timer.Reset(Jitter(d))
+		}
+	}
+}
+
+// Jitter returns d moved by a random amount of up to a tenth of d.
+func Jitter(d time.Duration) time.Duration {
+	spread := int64(d / 10)
+	if spread <= 0 {
+		return d
+	}
+	return d + time.Duration(rand.Int64N(2*spread+1)-spread)
+}
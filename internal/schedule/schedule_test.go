@@ -0,0 +1,41 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJitterStaysWithinATenth(t *testing.T) {
+	d := time.Minute
+	for range 1000 {
+		if j := Jitter(d); j < 54*time.Second || j > 66*time.Second {
+			t.Fatalf("Jitter(%v) = %v", d, j)
+		}
+	}
+	if j := Jitter(5); j != 5 {
+		t.Fatalf("Jitter(5ns) = %v, want 5ns", j)
+	}
+}
+
+func TestTickerTicksUntilStopped(t *testing.T) {
+	tk := NewTicker(10 * time.Millisecond)
+	for range 3 {
+		select {
+		case <-tk.C:
+		case <-time.After(time.Second):
+			t.Fatal("no tick within a second")
+		}
+	}
+	tk.Stop()
+	tk.Stop()
+	// At most one tick was buffered before Stop.
+	select {
+	case <-tk.C:
+	default:
+	}
+	select {
+	case <-tk.C:
+		t.Fatal("tick after Stop")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
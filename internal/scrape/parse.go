@@ -0,0 +1,148 @@
+package scrape
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Sample is one series of a Prometheus text exposition.
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+	// Counter marks cumulative values: counters and the buckets, counts
+	// and sums of histograms and summaries.
+	Counter bool
+}
+
+// Parse reads the Prometheus text exposition format (version 0.0.4, which
+// OpenMetrics exporters also serve). Timestamps are ignored.
+func Parse(r io.Reader) ([]Sample, error) {
+	types := map[string]string{}
+	var out []Sample
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if f := strings.Fields(line); len(f) >= 4 && f[1] == "TYPE" {
+				types[f[2]] = f[3]
+			}
+			continue
+		}
+		s, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		s.Counter = cumulative(types, s.Name)
+		out = append(out, s)
+	}
+	return out, sc.Err()
+}
+
+// cumulative reports whether series name of a family typed in types only
+// ever grows.
+func cumulative(types map[string]string, name string) bool {
+	if types[name] == "counter" {
+		return true
+	}
+	for _, suffix := range []string{"_total", "_bucket", "_count", "_sum"} {
+		base, ok := strings.CutSuffix(name, suffix)
+		if !ok {
+			continue
+		}
+		switch types[base] {
+		case "counter":
+			return suffix == "_total"
+		case "histogram", "summary":
+			return suffix != "_total"
+		}
+	}
+	return false
+}
+
+// parseLine parses `name{label="value",...} value [timestamp]`.
+func parseLine(line string) (Sample, error) {
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return Sample{}, fmt.Errorf("no value in %q", line)
+	}
+	s := Sample{Name: line[:end], Labels: map[string]string{}}
+	rest := line[end:]
+	if strings.HasPrefix(rest, "{") {
+		var err error
+		if rest, err = parseLabels(rest[1:], s.Labels); err != nil {
+			return Sample{}, err
+		}
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return Sample{}, fmt.Errorf("malformed value in %q", line)
+	}
+	v, err := parseValue(fields[0])
+	if err != nil {
+		return Sample{}, err
+	}
+	s.Value = v
+	return s, nil
+}
+
+// parseLabels reads the label pairs after the opening brace into labels
+// and returns what follows the closing one.
+func parseLabels(in string, labels map[string]string) (string, error) {
+	for {
+		in = strings.TrimLeft(in, " \t")
+		if strings.HasPrefix(in, "}") {
+			return in[1:], nil
+		}
+		eq := strings.IndexByte(in, '=')
+		if eq <= 0 || len(in) < eq+2 || in[eq+1] != '"' {
+			return "", fmt.Errorf("malformed labels at %q", in)
+		}
+		name := strings.TrimSpace(in[:eq])
+		var value strings.Builder
+		i := eq + 2
+		for ; i < len(in) && in[i] != '"'; i++ {
+			if in[i] == '\\' && i+1 < len(in) {
+				i++
+				switch in[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(in[i])
+				}
+				continue
+			}
+			value.WriteByte(in[i])
+		}
+		if i >= len(in) {
+			return "", fmt.Errorf("unterminated value of label %s", name)
+		}
+		labels[name] = value.String()
+		in = strings.TrimLeft(in[i+1:], " \t")
+		in = strings.TrimPrefix(in, ",")
+	}
+}
+
+func parseValue(v string) (float64, error) {
+	switch v {
+	case "+Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value %q is not a number", v)
+	}
+	return f, nil
+}
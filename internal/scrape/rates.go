@@ -0,0 +1,54 @@
+package scrape
+
+import (
+	"sync"
+	"time"
+)
+
+// maxRates bounds the series a Rates remembers; past it, it starts over.
+const maxRates = 100000
+
+// counter is the last value of a cumulative series.
+type counter struct {
+	ts    time.Time
+	value float64
+}
+
+// Rates turns cumulative series, such as Prometheus counters, into
+// per-second rates.
+type Rates struct {
+	mu   sync.Mutex
+	last map[string]counter
+}
+
+func NewRates() *Rates {
+	return &Rates{last: map[string]counter{}}
+}
+
+// Rate records value of series key at ts and returns its rate since the
+// previous value, false for the first value, one older than the previous
+// or one that went down because the counter was reset, e.g. by a restart.
+func (r *Rates) Rate(key string, ts time.Time, value float64) (float64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prev, ok := r.last[key]
+	if !ok && len(r.last) >= maxRates {
+		clear(r.last)
+	}
+	r.last[key] = counter{ts: ts, value: value}
+	if !ok || value < prev.value || !ts.After(prev.ts) {
+		return 0, false
+	}
+	return (value - prev.value) / ts.Sub(prev.ts).Seconds(), true
+}
+
+// Retain forgets the series whose key keep rejects.
+func (r *Rates) Retain(keep func(key string) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.last {
+		if !keep(key) {
+			delete(r.last, key)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package scrape
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+)
+
+const exposition = `# HELP http_requests_total Requests served.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",path="/a \"b\"\\c"} 100 1700000000000
+http_requests_total{method="POST"} 10
+# TYPE queue_depth gauge
+queue_depth 7
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 1.5
+latency_seconds_count 3
+ratio NaN
+`
+
+func TestParse(t *testing.T) {
+	samples, err := Parse(strings.NewReader(exposition))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(samples) != 7 {
+		t.Fatalf("samples = %+v", samples)
+	}
+	first := samples[0]
+	if first.Name != "http_requests_total" || first.Value != 100 || !first.Counter || first.Labels["path"] != `/a "b"\c` || first.Labels["method"] != "GET" {
+		t.Fatalf("first = %+v", first)
+	}
+	for _, s := range samples {
+		want := s.Name != "queue_depth" && s.Name != "ratio"
+		if s.Counter != want {
+			t.Fatalf("%s counter = %v", s.Name, s.Counter)
+		}
+	}
+	if _, err := Parse(strings.NewReader("broken{le=\"1\" 3\n")); err == nil || !strings.HasPrefix(err.Error(), "line 1:") {
+		t.Fatalf("malformed err = %v", err)
+	}
+}
+
+func TestParseTargetsAndPanels(t *testing.T) {
+	for raw, want := range map[string]string{
+		`{}`: "must be an array of scrape targets",
+		`[{"name": "a", "url": "ftp://x", "metrics": ["up"]}]`:                                                     `target "a": "ftp://x" is not an http(s) URL`,
+		`[{"name": "a", "url": "http://x/metrics"}]`:                                                               `target "a": list the metrics to keep`,
+		`[{"name": "a", "url": "http://x", "metrics": ["["]}]`:                                                     `target "a": invalid metric pattern "["`,
+		`[{"name": "a", "url": "http://x", "metrics": ["*"]}, {"name": "a", "url": "http://y", "metrics": ["*"]}]`: `target "a": duplicate name`,
+	} {
+		if _, err := ParseTargets(json.RawMessage(raw)); err == nil || err.Error() != want {
+			t.Fatalf("ParseTargets(%s) err = %v, want %q", raw, err, want)
+		}
+	}
+	panels, err := ParsePanels(json.RawMessage(`[{"target": "app", "metric": "queue_depth"}]`))
+	if err != nil || panels[0].Title != "app queue_depth" {
+		t.Fatalf("panels = %+v, err %v", panels, err)
+	}
+	if _, err := ParsePanels(json.RawMessage(`[{"target": "app"}]`)); err == nil {
+		t.Fatal("panel without metric accepted")
+	}
+}
+
+func TestScrapeStoresRatesAndUp(t *testing.T) {
+	requests, down := 100, false
+	exporter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = io.WriteString(w, "# TYPE http_requests_total counter\n")
+		_, _ = io.WriteString(w, "http_requests_total{method=\"GET\"} "+strconv.Itoa(requests)+"\n")
+		_, _ = io.WriteString(w, "queue_depth 7\nother_metric 1\n")
+	}))
+	defer exporter.Close()
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	s := NewScraper(repo, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	now := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	s.now = func() time.Time { return now }
+	s.Set([]Target{{Name: "app", URL: exporter.URL, Metrics: []string{"http_*", "queue_depth"}}})
+
+	s.Scrape(ctx)
+	now, requests = now.Add(30*time.Second), 160
+	s.Scrape(ctx)
+	rates, err := repo.ScrapeSeries(ctx, "app", "http_requests_total", now.Add(-time.Hour))
+	if err != nil || len(rates) != 1 || rates[0].Value != 2 || rates[0].Labels["method"] != "GET" {
+		t.Fatalf("rates = %+v, err %v", rates, err)
+	}
+	if st := s.Statuses()[0]; st.Series != 2 || st.Error != "" {
+		t.Fatalf("status = %+v", st)
+	}
+
+	now, down = now.Add(30*time.Second), true
+	s.Scrape(ctx)
+	up, err := repo.ScrapeSeries(ctx, "app", UpMetric, now.Add(-time.Hour))
+	if err != nil || len(up) != 3 || up[1].Value != 1 || up[2].Value != 0 {
+		t.Fatalf("up = %+v, err %v", up, err)
+	}
+	if st := s.Statuses()[0]; st.Error != "500 Internal Server Error" {
+		t.Fatalf("status after failure = %+v", st)
+	}
+	if other, _ := repo.ScrapeSeries(ctx, "app", "other_metric", now.Add(-time.Hour)); len(other) != 0 {
+		t.Fatalf("unselected series stored: %+v", other)
+	}
+}
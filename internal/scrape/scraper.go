@@ -0,0 +1,297 @@
+// Package scrape scrapes Prometheus exporters for the series they are
+// configured to keep, so apps that expose /metrics can be charted and
+// alerted on next to their containers.
+package scrape
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+const (
+	// TargetsKey is the setting holding the targets as a JSON array.
+	TargetsKey = "scrape.targets"
+	// PanelsKey is the setting holding the chart panels as a JSON array.
+	PanelsKey = "scrape.panels"
+	// UpMetric is the series recorded for every scrape: 1 when it
+	// succeeded, 0 when it failed.
+	UpMetric = "up"
+	// maxSeries bounds the series kept per target and scrape, so a glob
+	// matching a high-cardinality metric does not flood the database.
+	maxSeries = 500
+	// maxBody bounds the exposition read from a target.
+	maxBody = 16 << 20
+)
+
+// Target is an exporter endpoint and the series to keep of it.
+type Target struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Metrics are name globs (path.Match syntax) of the series to keep.
+	Metrics []string `json:"metrics"`
+}
+
+// Panel charts the series of a metric scraped from a target, optionally
+// only those whose labels match Labels (a label selector such as
+// "method=GET,!le").
+type Panel struct {
+	Title  string `json:"title"`
+	Target string `json:"target"`
+	Metric string `json:"metric"`
+	Labels string `json:"labels,omitempty"`
+}
+
+// ParseTargets decodes and validates the value of TargetsKey.
+func ParseTargets(raw json.RawMessage) ([]Target, error) {
+	var targets []Target
+	if err := json.Unmarshal(raw, &targets); err != nil {
+		return nil, errors.New("must be an array of scrape targets")
+	}
+	seen := map[string]bool{}
+	for _, t := range targets {
+		if t.Name == "" {
+			return nil, errors.New("every target needs a name")
+		}
+		if seen[t.Name] {
+			return nil, fmt.Errorf("target %q: duplicate name", t.Name)
+		}
+		seen[t.Name] = true
+		if u, err := url.Parse(t.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("target %q: %q is not an http(s) URL", t.Name, t.URL)
+		}
+		if len(t.Metrics) == 0 {
+			return nil, fmt.Errorf("target %q: list the metrics to keep", t.Name)
+		}
+		for _, m := range t.Metrics {
+			if _, err := path.Match(m, ""); err != nil || m == "" {
+				return nil, fmt.Errorf("target %q: invalid metric pattern %q", t.Name, m)
+			}
+		}
+	}
+	return targets, nil
+}
+
+// ParsePanels decodes and validates the value of PanelsKey.
+func ParsePanels(raw json.RawMessage) ([]Panel, error) {
+	var panels []Panel
+	if err := json.Unmarshal(raw, &panels); err != nil {
+		return nil, errors.New("must be an array of panels")
+	}
+	for i, p := range panels {
+		if p.Target == "" || p.Metric == "" {
+			return nil, fmt.Errorf("panel %d: target and metric are required", i+1)
+		}
+		if _, err := db.ParseLabelSelector(p.Labels); err != nil {
+			return nil, fmt.Errorf("panel %d: %w", i+1, err)
+		}
+		if p.Title == "" {
+			panels[i].Title = p.Target + " " + p.Metric
+		}
+	}
+	return panels, nil
+}
+
+// Status is the outcome of the last scrape of a target.
+type Status struct {
+	Target
+	ScrapedAt time.Time
+	Duration  time.Duration
+	Series    int
+	// Dropped counts the matching series beyond maxSeries.
+	Dropped int
+	Error   string
+}
+
+// Scraper scrapes its targets and stores the series they keep. Counters are
+// stored as per-second rates, from the second scrape on.
+type Scraper struct {
+	repo *db.Repository
+	http *http.Client
+	log  *slog.Logger
+	now  func() time.Time
+
+	rates *Rates
+
+	mu      sync.Mutex
+	targets []Target
+	status  map[string]Status
+}
+
+func NewScraper(repo *db.Repository, logger *slog.Logger) *Scraper {
+	return &Scraper{
+		repo:   repo,
+		http:   &http.Client{Timeout: 10 * time.Second},
+		log:    logger,
+		now:    time.Now,
+		rates:  NewRates(),
+		status: map[string]Status{},
+	}
+}
+
+// Set replaces the targets.
+func (s *Scraper) Set(targets []Target) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targets = targets
+	keep := map[string]bool{}
+	for _, t := range targets {
+		keep[t.Name] = true
+	}
+	for name := range s.status {
+		if !keep[name] {
+			delete(s.status, name)
+		}
+	}
+	s.rates.Retain(func(key string) bool {
+		name, _, _ := strings.Cut(key, "\x00")
+		return keep[name]
+	})
+}
+
+// Statuses returns the targets with their last scrape, by name.
+func (s *Scraper) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Status, 0, len(s.targets))
+	for _, t := range s.targets {
+		st := s.status[t.Name]
+		st.Target = t
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Run scrapes every interval until ctx is done.
+func (s *Scraper) Run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		s.Scrape(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Scrape scrapes every target once and stores what it keeps, with an up
+// sample per target.
+func (s *Scraper) Scrape(ctx context.Context) {
+	s.mu.Lock()
+	targets := s.targets
+	s.mu.Unlock()
+	for _, t := range targets {
+		start := s.now()
+		samples, err := s.fetch(ctx, t)
+		if ctx.Err() != nil {
+			return
+		}
+		st := Status{ScrapedAt: start.UTC(), Duration: s.now().Sub(start)}
+		up := 1.0
+		if err != nil {
+			s.log.Warn("scrape failed", "target", t.Name, "err", err)
+			st.Error, up = err.Error(), 0
+		}
+		kept := s.keep(t, samples, start.UTC(), &st)
+		kept = append(kept, models.ScrapeSample{TS: start.UTC(), Target: t.Name, Metric: UpMetric, Value: up})
+		if err := s.repo.InsertScrapeSamples(ctx, kept); err != nil {
+			s.log.Warn("store scraped samples", "target", t.Name, "err", err)
+		}
+		s.mu.Lock()
+		s.status[t.Name] = st
+		s.mu.Unlock()
+	}
+}
+
+func (s *Scraper) fetch(ctx context.Context, t Target) ([]Sample, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+	req.Header.Set("User-Agent", "dashi-scraper")
+	res, err := s.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New(res.Status)
+	}
+	samples, err := Parse(io.LimitReader(res.Body, maxBody))
+	if err != nil {
+		return nil, fmt.Errorf("parse metrics: %w", err)
+	}
+	return samples, nil
+}
+
+// keep returns the samples of t's metrics, with counters turned into rates,
+// and counts them in st.
+func (s *Scraper) keep(t Target, samples []Sample, ts time.Time, st *Status) []models.ScrapeSample {
+	var out []models.ScrapeSample
+	for _, sample := range samples {
+		if !matchAny(t.Metrics, sample.Name) || math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		if st.Series == maxSeries {
+			st.Dropped++
+			continue
+		}
+		st.Series++
+		value := sample.Value
+		if sample.Counter {
+			rate, ok := s.rates.Rate(t.Name+"\x00"+SeriesName(sample.Name, sample.Labels), ts, sample.Value)
+			if !ok {
+				continue
+			}
+			value = rate
+		}
+		out = append(out, models.ScrapeSample{TS: ts, Target: t.Name, Metric: sample.Name, Labels: sample.Labels, Value: value})
+	}
+	return out
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SeriesName renders a series as Prometheus does, e.g.
+// `http_requests_total{code="200",method="GET"}`.
+func SeriesName(metric string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return metric
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+strconv.Quote(labels[k]))
+	}
+	return metric + "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,83 @@
+package selfmon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// pingEvery is the least time between pings. Collector ticks come every
+// few seconds, more often than healthchecks.io and similar services accept.
+const pingEvery = time.Minute
+
+// Pinger reports successful collector ticks to an external uptime monitor,
+// such as a healthchecks.io check, so a dashi that stopped collecting is
+// noticed by something other than itself.
+type Pinger struct {
+	url  string
+	http *http.Client
+	log  *slog.Logger
+	now  func() time.Time
+
+	mu       sync.Mutex
+	last     time.Time
+	inflight bool
+}
+
+// NewPinger pings pingURL with a GET request. A nil Pinger ignores ticks, so
+// callers need not check whether APP_HEALTHCHECK_URL is set.
+func NewPinger(pingURL string, logger *slog.Logger) *Pinger {
+	if pingURL == "" {
+		return nil
+	}
+	return &Pinger{url: pingURL, http: &http.Client{Timeout: 10 * time.Second}, log: logger, now: time.Now}
+}
+
+// Tick notes a successful collector tick. It pings in the background unless
+// a ping is in flight or the last one, successful or not, was less than a
+// minute ago.
+func (p *Pinger) Tick() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	now := p.now()
+	if p.inflight || now.Sub(p.last) < pingEvery {
+		p.mu.Unlock()
+		return
+	}
+	p.inflight = true
+	p.mu.Unlock()
+	go func() {
+		err := p.ping()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.inflight, p.last = false, now
+		if err != nil {
+			p.log.Warn("healthcheck ping", "err", err)
+		}
+	}()
+}
+
+func (p *Pinger) ping() error {
+	resp, err := p.http.Get(p.url)
+	if err != nil {
+		// The URL embeds the check's secret, so only the cause is logged.
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
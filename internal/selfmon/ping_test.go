@@ -0,0 +1,50 @@
+package selfmon
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPingerThrottlesTicks(t *testing.T) {
+	var pings atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pings.Add(1)
+	}))
+	defer srv.Close()
+
+	p := NewPinger(srv.URL, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	start := time.Now()
+	p.now = func() time.Time { return start }
+	wait := func(want int32) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+			p.mu.Lock()
+			idle := !p.inflight
+			p.mu.Unlock()
+			if idle && pings.Load() == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("pings = %d, want %d", pings.Load(), want)
+			}
+		}
+	}
+
+	p.Tick()
+	wait(1)
+	p.Tick()
+	p.now = func() time.Time { return start.Add(30 * time.Second) }
+	p.Tick()
+	wait(1)
+	p.now = func() time.Time { return start.Add(time.Minute) }
+	p.Tick()
+	wait(2)
+
+	// Without a URL there is no Pinger, and ticks are ignored.
+	NewPinger("", nil).Tick()
+}
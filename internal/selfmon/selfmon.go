@@ -0,0 +1,121 @@
+// Package selfmon measures dashi itself: the counters below are bumped by
+// the log pipelines and the alert engine, and a Sampler periodically stores
+// them with runtime and database figures as self metrics.
+package selfmon
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+// The counters are also served as expvar variables on APP_DEBUG_ADDR.
+var (
+	// LogLines counts the log lines read from every input.
+	LogLines = expvar.NewInt("log_lines")
+	// LogDropped counts lines left out by drop rules and rate limit sampling.
+	LogDropped = expvar.NewInt("log_dropped")
+	// LogWriteErrors counts lines of batches that failed to be stored.
+	LogWriteErrors = expvar.NewInt("log_write_errors")
+	// LogSpilled counts lines of batches queued on disk to be stored later.
+	LogSpilled = expvar.NewInt("log_spilled")
+	// LogOverflow counts pushed lines dropped because their queue was full
+	// or the log memory budget spent; LogOverflowByContainer splits them up
+	// by container ID.
+	LogOverflow            = expvar.NewInt("log_overflow")
+	LogOverflowByContainer = expvar.NewMap("log_overflow_by_container")
+	// NotifyFailures counts notifications that failed after every retry.
+	NotifyFailures = expvar.NewInt("notify_failures")
+	// Panics counts panics recovered in supervised workers.
+	Panics = expvar.NewInt("panics")
+)
+
+// Overflows returns LogOverflowByContainer as a map.
+func Overflows() map[string]int64 {
+	out := map[string]int64{}
+	LogOverflowByContainer.Do(func(kv expvar.KeyValue) {
+		if n, ok := kv.Value.(*expvar.Int); ok {
+			out[kv.Key] = n.Value()
+		}
+	})
+	return out
+}
+
+// Sampler turns the counters into per-sample rates and stores them.
+type Sampler struct {
+	repo    *db.Repository
+	log     *slog.Logger
+	workers func() int
+	now     func() time.Time
+
+	prev     counters
+	prevTS   time.Time
+	prevQ    int64
+	prevQDur time.Duration
+}
+
+type counters struct {
+	lines, dropped, writeErrors, notifyFailures, panics, overflow int64
+}
+
+func read() counters {
+	return counters{LogLines.Value(), LogDropped.Value(), LogWriteErrors.Value(), NotifyFailures.Value(), Panics.Value(), LogOverflow.Value()}
+}
+
+// NewSampler creates a sampler for the database of repo; workers reports
+// the number of running log workers. Rates of the first sample cover the
+// time since NewSampler.
+func NewSampler(repo *db.Repository, logger *slog.Logger, workers func() int) *Sampler {
+	s := &Sampler{repo: repo, log: logger, workers: workers, now: time.Now, prev: read(), prevTS: time.Now()}
+	s.prevQ, s.prevQDur = repo.QueryStats()
+	return s
+}
+
+// Collect stores a self metric sample.
+func (s *Sampler) Collect(ctx context.Context) {
+	if err := s.repo.InsertSelfMetric(ctx, s.Sample(ctx)); err != nil {
+		s.log.Warn("store self metric", "err", err)
+	}
+}
+
+// Sample measures dashi since the previous sample.
+func (s *Sampler) Sample(ctx context.Context) models.SelfMetric {
+	now := s.now()
+	cur := read()
+	q, qDur := s.repo.QueryStats()
+	secs := now.Sub(s.prevTS).Seconds()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	m := models.SelfMetric{
+		TS:             now.UTC(),
+		LogWriteErrors: cur.writeErrors - s.prev.writeErrors,
+		NotifyFailures: cur.notifyFailures - s.prev.notifyFailures,
+		Panics:         cur.panics - s.prev.panics,
+		LogOverflow:    cur.overflow - s.prev.overflow,
+		Goroutines:     runtime.NumGoroutine(),
+		HeapBytes:      int64(mem.HeapAlloc),
+	}
+	if secs > 0 {
+		m.LogLinesRate = float64(cur.lines-s.prev.lines) / secs
+		m.LogDroppedRate = float64(cur.dropped-s.prev.dropped) / secs
+		m.QueryRate = float64(q-s.prevQ) / secs
+	}
+	if n := q - s.prevQ; n > 0 {
+		m.QueryAvgMS = float64((qDur-s.prevQDur)/time.Microsecond) / 1000 / float64(n)
+	}
+	if s.workers != nil {
+		m.LogWorkers = s.workers()
+	}
+	if size, err := s.repo.Size(ctx); err == nil {
+		m.DBSizeBytes = size
+	} else {
+		s.log.Warn("read database size", "err", err)
+	}
+	s.prev, s.prevTS, s.prevQ, s.prevQDur = cur, now, q, qDur
+	return m
+}
@@ -0,0 +1,54 @@
+package selfmon
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+)
+
+func TestSamplerStoresRatesSincePreviousSample(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+
+	s := NewSampler(repo, slog.New(slog.NewTextHandler(io.Discard, nil)), func() int { return 3 })
+	start := time.Now()
+	s.prevTS = start
+	s.now = func() time.Time { return start.Add(10 * time.Second) }
+	LogLines.Add(200)
+	LogDropped.Add(20)
+	LogWriteErrors.Add(5)
+	NotifyFailures.Add(1)
+	if _, err := repo.ListContainers(ctx); err != nil {
+		t.Fatalf("list containers: %v", err)
+	}
+
+	s.Collect(ctx)
+	m, ok, err := repo.LatestSelfMetric(ctx)
+	if err != nil || !ok {
+		t.Fatalf("latest self metric: %v, %v", ok, err)
+	}
+	if m.LogLinesRate != 20 || m.LogDroppedRate != 2 || m.LogWriteErrors != 5 || m.NotifyFailures != 1 || m.LogWorkers != 3 {
+		t.Fatalf("sample = %+v", m)
+	}
+	if m.DBSizeBytes <= 0 || m.QueryRate <= 0 || m.Goroutines <= 0 || m.HeapBytes <= 0 {
+		t.Fatalf("runtime and database figures missing: %+v", m)
+	}
+
+	// Counts start over with the next sample.
+	s.now = func() time.Time { return start.Add(20 * time.Second) }
+	if next := s.Sample(ctx); next.LogLinesRate != 0 || next.LogWriteErrors != 0 || next.NotifyFailures != 0 {
+		t.Fatalf("next sample = %+v", next)
+	}
+}
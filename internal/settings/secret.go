@@ -0,0 +1,111 @@
+package settings
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sealedPrefix marks a secret value encrypted by a Cipher. Sealed values
+// are stored as JSON strings so the settings table keeps holding JSON.
+const sealedPrefix = "enc:v1:"
+
+// Cipher encrypts the values of secret keys (see IsSecret) at rest with
+// AES-256-GCM.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher derives the encryption key from passphrase, which may be any
+// string, e.g. the contents of a generated key file.
+func NewCipher(passphrase string) (*Cipher, error) {
+	if passphrase == "" {
+		return nil, errors.New("empty secret key")
+	}
+	key := sha256.Sum256([]byte(passphrase))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// LoadOrCreateKey returns the key stored at path, creating a random one
+// readable only by its owner when the file does not exist.
+func LoadOrCreateKey(path string) (string, error) {
+	raw, err := os.ReadFile(path)
+	if err == nil {
+		key := strings.TrimSpace(string(raw))
+		if key == "" {
+			return "", fmt.Errorf("secret key file %s is empty", path)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	key := base64.StdEncoding.EncodeToString(buf)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(key+"\n"), 0o600); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (c *Cipher) seal(raw json.RawMessage) (json.RawMessage, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := c.aead.Seal(nonce, nonce, raw, nil)
+	return json.Marshal(sealedPrefix + base64.StdEncoding.EncodeToString(sealed))
+}
+
+// open decrypts a sealed value; other values are returned as they are, so
+// secrets stored before encryption was enabled stay readable.
+func (c *Cipher) open(raw json.RawMessage) (json.RawMessage, error) {
+	s, ok := sealedString(raw)
+	if !ok {
+		return raw, nil
+	}
+	if c == nil {
+		return nil, errors.New("secret is encrypted but no key is configured")
+	}
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(data) < c.aead.NonceSize() {
+		return nil, errors.New("malformed encrypted secret")
+	}
+	n := c.aead.NonceSize()
+	plain, err := c.aead.Open(nil, data[:n], data[n:], nil)
+	if err != nil {
+		return nil, errors.New("cannot decrypt secret; was the secret key changed?")
+	}
+	return plain, nil
+}
+
+// sealedString returns the encrypted payload of a sealed value.
+func sealedString(raw json.RawMessage) (string, bool) {
+	var s string
+	if json.Unmarshal(raw, &s) != nil {
+		return "", false
+	}
+	return strings.CutPrefix(s, sealedPrefix)
+}
@@ -0,0 +1,287 @@
+// Package settings is the runtime-editable configuration store. Values are
+// JSON documents under namespaced keys such as "telegram.token" or
+// "retention.logs_days"; features register validators and change hooks for
+// their namespace instead of adding bespoke repository methods.
+package settings
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"dashi/internal/db"
+)
+
+var keyPattern = regexp.MustCompile(`^[a-z0-9_]+(\.[a-z0-9_]+)+$`)
+
+// ErrInvalid wraps key and value validation failures.
+var ErrInvalid = errors.New("invalid setting")
+
+// Validator checks a value before it is stored.
+type Validator func(key string, value json.RawMessage) error
+
+// Hook runs after a key in its namespace was set or deleted.
+type Hook func(ctx context.Context, key string)
+
+type Store struct {
+	repo *db.Repository
+	log  *slog.Logger
+
+	mu         sync.RWMutex
+	validators map[string][]Validator
+	hooks      map[string][]Hook
+	cipher     *Cipher
+}
+
+func NewStore(repo *db.Repository, logger *slog.Logger) *Store {
+	return &Store{repo: repo, log: logger, validators: map[string][]Validator{}, hooks: map[string][]Hook{}}
+}
+
+// Namespace returns the part of key before the first dot.
+func Namespace(key string) string {
+	ns, _, _ := strings.Cut(key, ".")
+	return ns
+}
+
+// IsSecret reports whether a key holds a credential that must not be
+// echoed back by the API.
+func IsSecret(key string) bool {
+	last := key[strings.LastIndex(key, ".")+1:]
+	for _, s := range []string{"token", "secret", "password"} {
+		if strings.Contains(last, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetCipher makes the store encrypt the values of secret keys it writes
+// from now on; see EncryptSecrets for those written before.
+func (s *Store) SetCipher(c *Cipher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cipher = c
+}
+
+func (s *Store) getCipher() *Cipher {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cipher
+}
+
+// Seal returns the form raw is stored in under key: encrypted for secret
+// keys when a cipher is set, unchanged otherwise. Callers that write
+// settings in bulk use it after Check.
+func (s *Store) Seal(key string, raw json.RawMessage) (string, error) {
+	c := s.getCipher()
+	if c == nil || !IsSecret(key) {
+		return string(raw), nil
+	}
+	sealed, err := c.seal(raw)
+	if err != nil {
+		return "", fmt.Errorf("encrypt setting %s: %w", key, err)
+	}
+	return string(sealed), nil
+}
+
+// unseal decrypts a stored secret value.
+func (s *Store) unseal(key string, raw json.RawMessage) (json.RawMessage, error) {
+	if !IsSecret(key) {
+		return raw, nil
+	}
+	plain, err := s.getCipher().open(raw)
+	if err != nil {
+		return nil, fmt.Errorf("setting %s: %w", key, err)
+	}
+	return plain, nil
+}
+
+// EncryptSecrets encrypts the secret values still stored in plain text,
+// returning how many it changed. It does nothing without a cipher.
+func (s *Store) EncryptSecrets(ctx context.Context) (int, error) {
+	if s.getCipher() == nil {
+		return 0, nil
+	}
+	rows, err := s.repo.ListSettings(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for key, v := range rows {
+		if !IsSecret(key) {
+			continue
+		}
+		if _, sealed := sealedString(json.RawMessage(v)); sealed {
+			continue
+		}
+		stored, err := s.Seal(key, json.RawMessage(v))
+		if err != nil {
+			return n, err
+		}
+		if err := s.repo.SetSetting(ctx, key, stored); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (s *Store) Validate(namespace string, fn Validator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validators[namespace] = append(s.validators[namespace], fn)
+}
+
+func (s *Store) OnChange(namespace string, fn Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks[namespace] = append(s.hooks[namespace], fn)
+}
+
+// Get decodes the value of key into dst and reports whether it was set.
+func (s *Store) Get(ctx context.Context, key string, dst any) (bool, error) {
+	raw, err := s.repo.GetSetting(ctx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	plain, err := s.unseal(key, json.RawMessage(raw))
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(plain, dst); err != nil {
+		return false, fmt.Errorf("decode setting %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// List returns the raw values of all keys in namespace, or of every key
+// when namespace is empty.
+func (s *Store) List(ctx context.Context, namespace string) (map[string]json.RawMessage, error) {
+	prefix := ""
+	if namespace != "" {
+		prefix = namespace + "."
+	}
+	rows, err := s.repo.ListSettings(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]json.RawMessage, len(rows))
+	for k, v := range rows {
+		plain, err := s.unseal(k, json.RawMessage(v))
+		if err != nil {
+			return nil, err
+		}
+		out[k] = plain
+	}
+	return out, nil
+}
+
+// Set stores v as JSON under key.
+func (s *Store) Set(ctx context.Context, key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.SetRaw(ctx, key, raw)
+}
+
+// SetRaw validates and stores an already encoded JSON value.
+func (s *Store) SetRaw(ctx context.Context, key string, raw json.RawMessage) error {
+	if err := s.Check(key, raw); err != nil {
+		return err
+	}
+	stored, err := s.Seal(key, raw)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.SetSetting(ctx, key, stored); err != nil {
+		return err
+	}
+	s.Notify(ctx, key)
+	return nil
+}
+
+// Check runs the key format and namespace validators without storing
+// anything, for callers that write settings in bulk.
+func (s *Store) Check(key string, raw json.RawMessage) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("%w: key %q must look like namespace.name", ErrInvalid, key)
+	}
+	if !json.Valid(raw) {
+		return fmt.Errorf("%w: value of %s is not JSON", ErrInvalid, key)
+	}
+	s.mu.RLock()
+	validators := s.validators[Namespace(key)]
+	s.mu.RUnlock()
+	for _, fn := range validators {
+		if err := fn(key, raw); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalid, key, err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	if err := s.repo.DeleteSetting(ctx, key); err != nil {
+		return err
+	}
+	s.Notify(ctx, key)
+	return nil
+}
+
+// Notify runs the change hooks registered for the namespace of key.
+func (s *Store) Notify(ctx context.Context, key string) {
+	s.mu.RLock()
+	hooks := s.hooks[Namespace(key)]
+	s.mu.RUnlock()
+	for _, fn := range hooks {
+		fn(ctx, key)
+	}
+}
+
+// Reload runs the change hooks of every namespace, picking up values
+// written to the database behind the store's back.
+func (s *Store) Reload(ctx context.Context) {
+	s.mu.RLock()
+	namespaces := make([]string, 0, len(s.hooks))
+	for ns := range s.hooks {
+		namespaces = append(namespaces, ns)
+	}
+	s.mu.RUnlock()
+	slices.Sort(namespaces)
+	for _, ns := range namespaces {
+		s.Notify(ctx, ns)
+	}
+}
+
+// String returns the string stored under key, or def when unset or empty.
+func (s *Store) String(ctx context.Context, key, def string) string {
+	var v string
+	if ok, err := s.Get(ctx, key, &v); err != nil {
+		s.log.Warn("read setting", "key", key, "err", err)
+	} else if ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// Int returns the integer stored under key, or def when unset.
+func (s *Store) Int(ctx context.Context, key string, def int) int {
+	var v int
+	if ok, err := s.Get(ctx, key, &v); err != nil {
+		s.log.Warn("read setting", "key", key, "err", err)
+	} else if ok {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,136 @@
+package settings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"dashi/internal/db"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	return NewStore(db.NewRepository(sqldb), slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestStoreSetGetAndHooks(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	var fired []string
+	s.OnChange("telegram", func(_ context.Context, key string) { fired = append(fired, key) })
+	s.Validate("retention", func(_ string, v json.RawMessage) error {
+		var n int
+		if err := json.Unmarshal(v, &n); err != nil || n <= 0 {
+			return fmt.Errorf("must be a positive integer")
+		}
+		return nil
+	})
+
+	if err := s.Set(ctx, "telegram.chat_id", "42"); err != nil {
+		t.Fatalf("set chat id: %v", err)
+	}
+	if got := s.String(ctx, "telegram.chat_id", "fallback"); got != "42" {
+		t.Fatalf("chat id = %q, want 42", got)
+	}
+	if got := s.String(ctx, "telegram.token", "fallback"); got != "fallback" {
+		t.Fatalf("unset token = %q, want fallback", got)
+	}
+	if len(fired) != 1 || fired[0] != "telegram.chat_id" {
+		t.Fatalf("hooks fired for %v", fired)
+	}
+
+	if err := s.Set(ctx, "retention.logs_days", -1); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("negative retention err = %v, want ErrInvalid", err)
+	}
+	if err := s.Set(ctx, "nonamespace", 1); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("bare key err = %v, want ErrInvalid", err)
+	}
+	if err := s.Set(ctx, "retention.logs_days", 30); err != nil {
+		t.Fatalf("set retention: %v", err)
+	}
+	if got := s.Int(ctx, "retention.logs_days", 14); got != 30 {
+		t.Fatalf("logs days = %d, want 30", got)
+	}
+
+	all, err := s.List(ctx, "telegram")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(all) != 1 || string(all["telegram.chat_id"]) != `"42"` {
+		t.Fatalf("unexpected list: %v", all)
+	}
+
+	if err := s.Delete(ctx, "telegram.chat_id"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got := s.String(ctx, "telegram.chat_id", ""); got != "" {
+		t.Fatalf("deleted chat id = %q", got)
+	}
+	if len(fired) != 2 {
+		t.Fatalf("hooks fired %d times, want 2", len(fired))
+	}
+
+	s.Reload(ctx)
+	if len(fired) != 3 || fired[2] != "telegram" {
+		t.Fatalf("reload fired hooks for %v", fired)
+	}
+}
+
+func TestStoreEncryptsSecrets(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	// Stored before encryption was enabled.
+	if err := s.Set(ctx, "telegram.token", "old-token"); err != nil {
+		t.Fatalf("set token: %v", err)
+	}
+	key, err := LoadOrCreateKey(t.TempDir() + "/secret.key")
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatalf("new cipher: %v", err)
+	}
+	s.SetCipher(c)
+	if n, err := s.EncryptSecrets(ctx); err != nil || n != 1 {
+		t.Fatalf("encrypt secrets = %d, %v", n, err)
+	}
+	if err := s.Set(ctx, "telegram.chat_id", "42"); err != nil {
+		t.Fatalf("set chat id: %v", err)
+	}
+
+	raw, _ := s.repo.GetSetting(ctx, "telegram.token")
+	if strings.Contains(raw, "old-token") || !strings.Contains(raw, sealedPrefix) {
+		t.Fatalf("token stored as %s", raw)
+	}
+	if raw, _ := s.repo.GetSetting(ctx, "telegram.chat_id"); raw != `"42"` {
+		t.Fatalf("non-secret stored as %s", raw)
+	}
+	if got := s.String(ctx, "telegram.token", ""); got != "old-token" {
+		t.Fatalf("token = %q", got)
+	}
+	all, err := s.List(ctx, "telegram")
+	if err != nil || string(all["telegram.token"]) != `"old-token"` {
+		t.Fatalf("list = %s, %v", all, err)
+	}
+
+	other, _ := NewCipher("another key")
+	s.SetCipher(other)
+	if _, err := s.Get(ctx, "telegram.token", new(string)); err == nil {
+		t.Fatal("token decrypted with the wrong key")
+	}
+}
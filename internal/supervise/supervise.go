@@ -0,0 +1,63 @@
+// Package supervise keeps a panic in one worker from taking down dashi or
+// silently ending the work it does: panics are recovered, logged with their
+// stack and counted in the dashi_panics self metric.
+package supervise
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"dashi/internal/selfmon"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// Loop runs fn until it returns without panicking. After a panic fn is
+// started again, waiting a second first and twice as long after each panic
+// in a row, up to a minute; a run of a minute or more resets the wait. Loop
+// gives up when ctx is done while waiting.
+func Loop(ctx context.Context, logger *slog.Logger, name string, fn func()) {
+	backoff := minBackoff
+	for {
+		start := time.Now()
+		if Call(logger, name, fn) {
+			return
+		}
+		if time.Since(start) >= maxBackoff {
+			backoff = minBackoff
+		}
+		logger.Warn("restarting after panic", "worker", name, "backoff", backoff)
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// Call runs fn once and reports whether it returned without panicking.
+func Call(logger *slog.Logger, name string, fn func()) (ok bool) {
+	defer Recover(logger, name)
+	fn()
+	return true
+}
+
+// Recover, when deferred, ends a panicking call normally. It suits
+// periodic tasks such as a collector tick, which the next tick starts again.
+func Recover(logger *slog.Logger, name string) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	selfmon.Panics.Add(1)
+	logger.Error("recovered panic", "worker", name, "panic", fmt.Sprint(v), "stack", string(debug.Stack()))
+}
@@ -0,0 +1,47 @@
+package supervise
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"dashi/internal/selfmon"
+)
+
+func TestLoopRestartsAfterPanic(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	before := selfmon.Panics.Value()
+	runs := 0
+	Loop(context.Background(), logger, "test", func() {
+		runs++
+		if runs == 1 {
+			panic("malformed frame")
+		}
+	})
+	if runs != 2 {
+		t.Fatalf("runs = %d, want 2", runs)
+	}
+	if got := selfmon.Panics.Value() - before; got != 1 {
+		t.Fatalf("panics counted = %d, want 1", got)
+	}
+
+	// A done context ends the wait before a restart.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runs = 0
+	Loop(ctx, logger, "test", func() { runs++; panic("again") })
+	if runs != 1 {
+		t.Fatalf("runs with a done context = %d, want 1", runs)
+	}
+}
+
+func TestCall(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if !Call(logger, "test", func() {}) {
+		t.Fatal("Call reported a panic for a normal return")
+	}
+	if Call(logger, "test", func() { panic(nil) }) {
+		t.Fatal("Call missed a panic")
+	}
+}
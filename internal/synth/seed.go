@@ -0,0 +1,137 @@
+package synth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+// History sizes what Seed writes.
+type History struct {
+	// Span is how far back samples and log lines go.
+	Span time.Duration
+	// Interval is the time between samples.
+	Interval time.Duration
+	// LogsPerMinute is the number of log lines of the whole fleet.
+	LogsPerMinute int
+	// Alerts is the number of alerts; the newest few are left firing.
+	Alerts int
+}
+
+// Counts reports what Seed wrote.
+type Counts struct {
+	Containers int
+	Samples    int
+	Logs       int
+	Alerts     int
+}
+
+// samplesPerTx bounds the sample steps written per transaction.
+const samplesPerTx = 360
+
+// Seed writes the fleet's services and containers and a history of host
+// and container samples, log lines and alerts ending at now into repo.
+func (f *Fleet) Seed(ctx context.Context, repo *db.Repository, h History, now time.Time) (Counts, error) {
+	var n Counts
+	if h.Interval <= 0 {
+		h.Interval = 10 * time.Second
+	}
+	for _, st := range f.Stacks {
+		for _, c := range st.Containers {
+			if err := repo.UpsertServiceAndContainer(ctx, st.Service, c); err != nil {
+				return n, fmt.Errorf("store container %s: %w", c.Name, err)
+			}
+			n.Containers++
+		}
+	}
+
+	from := now.Add(-h.Span).Truncate(h.Interval)
+	var hosts []models.HostMetric
+	var containers []models.ContainerMetric
+	flush := func() error {
+		if err := repo.InsertMetricsBatch(ctx, hosts, containers); err != nil {
+			return fmt.Errorf("store samples: %w", err)
+		}
+		n.Samples += len(hosts) + len(containers)
+		hosts, containers = hosts[:0], containers[:0]
+		return nil
+	}
+	for ts := from; !ts.After(now); ts = ts.Add(h.Interval) {
+		hm, cms := f.Sample(ts, h.Interval)
+		hosts = append(hosts, hm)
+		containers = append(containers, cms...)
+		if len(hosts) == samplesPerTx {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return n, err
+	}
+
+	if h.LogsPerMinute > 0 {
+		// An hour of lines per transaction.
+		for start := now.Add(-h.Span); start.Before(now); start = start.Add(time.Hour) {
+			end := start.Add(time.Hour)
+			if end.After(now) {
+				end = now
+			}
+			entries := f.Logs(end, end.Sub(start), int(float64(h.LogsPerMinute)*end.Sub(start).Minutes()))
+			if err := repo.InsertLogs(ctx, entries); err != nil {
+				return n, fmt.Errorf("store logs: %w", err)
+			}
+			n.Logs += len(entries)
+		}
+	}
+
+	written, err := f.seedAlerts(ctx, repo, h, now)
+	n.Alerts = written
+	return n, err
+}
+
+// seedAlerts spreads alerts of the enabled container rules over the
+// history, each recovering after a few minutes, and leaves the newest
+// ones firing.
+func (f *Fleet) seedAlerts(ctx context.Context, repo *db.Repository, h History, now time.Time) (int, error) {
+	if h.Alerts <= 0 || h.Span <= 0 {
+		return 0, nil
+	}
+	rules, err := repo.ListRules(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("load rules: %w", err)
+	}
+	var usable []models.AlertRule
+	for _, r := range rules {
+		if r.Enabled && r.TargetType == "container" {
+			usable = append(usable, r)
+		}
+	}
+	containers := f.Containers()
+	if len(usable) == 0 || len(containers) == 0 {
+		return 0, nil
+	}
+	firing := min(3, h.Alerts)
+	for i := range h.Alerts {
+		rule := usable[f.rnd.IntN(len(usable))]
+		c := containers[f.rnd.IntN(len(containers))]
+		started := now.Add(-h.Span + time.Duration(int64(h.Span)*int64(i)/int64(h.Alerts))).UTC()
+		value := rule.Threshold * (1 + f.rnd.Float64()/2)
+		// Summaries and details read like the alert engine's.
+		summary := fmt.Sprintf("ALERT %s [%s] value=%.2f threshold %s %.2f", rule.Name, c.ID[:12], value, rule.Operator, rule.Threshold)
+		details := map[string]any{"value": value, "target": c.ID[:12]}
+		if _, err := repo.CreateAlert(ctx, rule.ID, c.ID, "firing", summary, details, started); err != nil {
+			return i, fmt.Errorf("store alert: %w", err)
+		}
+		if i < h.Alerts-firing {
+			ended := started.Add(time.Duration(2+f.rnd.IntN(30)) * time.Minute)
+			if err := repo.CloseAlert(ctx, rule.ID, c.ID, ended); err != nil {
+				return i, fmt.Errorf("recover alert: %w", err)
+			}
+		}
+	}
+	return h.Alerts, nil
+}
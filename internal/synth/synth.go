@@ -0,0 +1,295 @@
+// Package synth generates realistic-looking services, metrics, logs and
+// alerts, for developing the UI without a busy Docker host, testing query
+// performance at volume and reproducing scaling problems users report.
+package synth
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"dashi/internal/models"
+)
+
+// Options sizes a fleet.
+type Options struct {
+	// Host is the Docker host the services run on; "local" or empty for
+	// the machine dashi runs on.
+	Host     string
+	Services int
+	// Replicas is the number of containers per service.
+	Replicas int
+	// Seed makes the fleet and everything it generates reproducible.
+	Seed uint64
+}
+
+// Stack is a generated service with its containers.
+type Stack struct {
+	Service    models.Service
+	Containers []models.Container
+}
+
+// Fleet holds generated services and the state their samples and log
+// lines continue from.
+type Fleet struct {
+	Stacks []Stack
+
+	rnd    *rand.Rand
+	kinds  map[string]kind
+	usage  map[string]*usage
+	host   models.HostMetric
+	uptime time.Time
+}
+
+// usage is the resource profile and counters of one container.
+type usage struct {
+	cpu, mem            float64
+	memLimit            int64
+	netRX, netTX        int64
+	blkRead, blkWrite   int64
+	netRate, blkRate    float64
+	pids, fds, fdsLimit int64
+}
+
+// kind describes what a generated service looks like and logs.
+type kind struct {
+	name, image string
+	// port is the container port published on the host, 0 for none.
+	port int
+	// cpu and mem are typical usage in percent and MiB.
+	cpu, mem float64
+	labels   map[string]string
+	logs     func(r *rand.Rand) (level, message string)
+}
+
+var kinds = []kind{
+	{"web", "nginx:1.27", 80, 2, 40, map[string]string{"tier": "frontend", "com.docker.compose.depends_on": "api:service_started:false"}, accessLog},
+	{"api", "ghcr.io/example/api:2.4.1", 8080, 15, 300, map[string]string{"tier": "backend", "com.docker.compose.depends_on": "postgres:service_healthy:false,redis:service_started:false"}, appLog},
+	{"worker", "ghcr.io/example/worker:2.4.1", 0, 35, 500, map[string]string{"tier": "backend", "com.docker.compose.depends_on": "postgres:service_healthy:false,redis:service_started:false"}, appLog},
+	{"postgres", "postgres:16", 0, 8, 900, map[string]string{"tier": "data"}, postgresLog},
+	{"redis", "redis:7", 0, 1, 120, map[string]string{"tier": "data"}, redisLog},
+	{"traefik", "traefik:v3.1", 443, 1, 60, map[string]string{"tier": "edge"}, accessLog},
+	{"grafana", "grafana/grafana:11.2.0", 3000, 3, 200, map[string]string{"tier": "monitoring", "com.docker.compose.depends_on": "prometheus:service_started:false"}, appLog},
+	{"prometheus", "prom/prometheus:v2.54.1", 9090, 6, 700, map[string]string{"tier": "monitoring"}, appLog},
+	{"minio", "minio/minio:latest", 9000, 4, 350, map[string]string{"tier": "data"}, appLog},
+	{"nextcloud", "nextcloud:29", 80, 5, 450, map[string]string{"tier": "apps"}, accessLog},
+	{"gitea", "gitea/gitea:1.22", 3000, 2, 250, map[string]string{"tier": "apps"}, appLog},
+	{"jellyfin", "jellyfin/jellyfin:10.9", 8096, 20, 1200, map[string]string{"tier": "apps"}, appLog},
+}
+
+// NewFleet generates the services and containers of a fleet whose
+// containers started a week before now.
+func NewFleet(opts Options, now time.Time) *Fleet {
+	if opts.Services <= 0 {
+		opts.Services = 10
+	}
+	if opts.Replicas <= 0 {
+		opts.Replicas = 1
+	}
+	host := opts.Host
+	if host == "" {
+		host = "local"
+	}
+	seed := opts.Seed
+	if host != "local" {
+		// Fleets of other hosts get their own container IDs.
+		h := fnv.New64a()
+		h.Write([]byte(host))
+		seed ^= h.Sum64()
+	}
+	f := &Fleet{
+		rnd:    rand.New(rand.NewPCG(seed, seed^0x5eed)),
+		kinds:  map[string]kind{},
+		usage:  map[string]*usage{},
+		uptime: now.Add(-30 * 24 * time.Hour),
+	}
+	started := now.Add(-7 * 24 * time.Hour).UTC()
+	for i := range opts.Services {
+		k := kinds[i%len(kinds)]
+		name := k.name
+		if n := i / len(kinds); n > 0 {
+			name = fmt.Sprintf("%s-%d", k.name, n+1)
+		}
+		id := name
+		if host != "local" {
+			id = name + "@" + host
+		}
+		labels := map[string]string{"com.docker.compose.project": "demo", "com.docker.compose.service": name, "env": []string{"prod", "staging"}[i%2]}
+		for key, v := range k.labels {
+			labels[key] = v
+		}
+		raw, _ := json.Marshal(labels)
+		st := Stack{Service: models.Service{ID: id, Host: host, Name: name, Image: k.image, LabelsJSON: string(raw), Status: "running"}}
+		for r := range opts.Replicas {
+			c := models.Container{
+				ID:           f.hexID(),
+				ServiceID:    id,
+				Host:         host,
+				Name:         fmt.Sprintf("demo-%s-%d", name, r+1),
+				Status:       "running",
+				Health:       "healthy",
+				StartedAt:    &started,
+				LastSeenAt:   now.UTC(),
+				RestartCount: f.rnd.IntN(3),
+				Networks:     []models.Network{{Name: "demo_default", IP: fmt.Sprintf("172.18.%d.%d", i/250, 2+(i*opts.Replicas+r)%250)}},
+			}
+			if k.port > 0 && r == 0 {
+				c.Ports = []models.Port{{HostIP: "0.0.0.0", HostPort: 8000 + i, ContainerPort: k.port, Protocol: "tcp"}}
+			}
+			st.Containers = append(st.Containers, c)
+			f.kinds[c.ID] = k
+			f.usage[c.ID] = &usage{
+				cpu:      k.cpu * (0.5 + f.rnd.Float64()),
+				mem:      k.mem * (0.7 + 0.6*f.rnd.Float64()) * (1 << 20),
+				memLimit: int64(k.mem*4) << 20,
+				netRate:  k.cpu * 20_000 * (0.5 + f.rnd.Float64()),
+				blkRate:  k.mem * 200 * f.rnd.Float64(),
+				pids:     int64(5 + f.rnd.IntN(40)),
+				fds:      int64(20 + f.rnd.IntN(200)),
+				fdsLimit: 1 << 20,
+			}
+		}
+		f.Stacks = append(f.Stacks, st)
+	}
+	return f
+}
+
+// Containers returns the containers of all services.
+func (f *Fleet) Containers() []models.Container {
+	var out []models.Container
+	for _, st := range f.Stacks {
+		out = append(out, st.Containers...)
+	}
+	return out
+}
+
+// Sample returns a host sample and a sample of every container at ts, step
+// after the previous one. Usage follows a daily cycle with noise, so charts
+// over days look like a real host's.
+func (f *Fleet) Sample(ts time.Time, step time.Duration) (models.HostMetric, []models.ContainerMetric) {
+	ts = ts.UTC()
+	secs := step.Seconds()
+	// Busiest in the afternoon, quietest at night.
+	day := 0.65 + 0.35*math.Sin(2*math.Pi*(float64(ts.Hour()*60+ts.Minute())/1440-0.375))
+	out := make([]models.ContainerMetric, 0, len(f.usage))
+	var cpu float64
+	var mem int64
+	var netRX, netTX float64
+	for _, c := range f.Containers() {
+		u := f.usage[c.ID]
+		pct := max(0, u.cpu*day*(0.8+0.4*f.rnd.Float64()))
+		// Memory drifts slowly, like caches filling and being freed.
+		u.mem = max(8<<20, u.mem*(0.998+0.004*f.rnd.Float64()))
+		rx := u.netRate * day * (0.5 + f.rnd.Float64())
+		tx := rx * 0.6
+		rd := u.blkRate * day * f.rnd.Float64()
+		wr := u.blkRate * day * f.rnd.Float64() * 1.5
+		u.netRX += int64(rx * secs)
+		u.netTX += int64(tx * secs)
+		u.blkRead += int64(rd * secs)
+		u.blkWrite += int64(wr * secs)
+		out = append(out, models.ContainerMetric{
+			TS: ts, ContainerID: c.ID, CPUPct: pct, MemUsedBytes: int64(u.mem), MemLimitBytes: u.memLimit,
+			NetRXBytes: u.netRX, NetTXBytes: u.netTX, BlkReadBytes: u.blkRead, BlkWriteBytes: u.blkWrite,
+			NetRXRate: rx, NetTXRate: tx, BlkReadRate: rd, BlkWriteRate: wr,
+			Pids: u.pids, FDs: u.fds + int64(f.rnd.IntN(10)), FDLimit: u.fdsLimit,
+		})
+		cpu += pct
+		mem += int64(u.mem)
+		netRX += rx
+		netTX += tx
+	}
+	const memTotal, diskTotal = 32 << 30, 1 << 40
+	h := &f.host
+	h.NetRXBytes += int64(netRX * secs)
+	h.NetTXBytes += int64(netTX * secs)
+	load := min(cpu/100*8, 8) + f.rnd.Float64()*0.3
+	hm := models.HostMetric{
+		TS: ts, CPUPct: min(100, 3+cpu/8), MemUsedBytes: min(memTotal, mem+4<<30), MemTotalBytes: memTotal,
+		NetRXBytes: h.NetRXBytes, NetTXBytes: h.NetTXBytes, NetRXRate: netRX, NetTXRate: netTX,
+		DiskUsedBytes: diskTotal * 2 / 5, DiskTotalBytes: diskTotal,
+		Load1: load, Load5: load * 0.9, Load15: load * 0.8, UptimeSec: int64(ts.Sub(f.uptime).Seconds()),
+		SwapTotalBytes: 4 << 30, SwapUsedBytes: 200 << 20,
+		DiskReadRate: 2e6 * day * f.rnd.Float64(), DiskWriteRate: 5e6 * day * f.rnd.Float64(), DiskUtilPct: 10 * day * f.rnd.Float64(),
+		CPUTempC: 40 + 20*day + 3*f.rnd.Float64(),
+	}
+	return hm, out
+}
+
+// Logs returns n log lines spread over the interval ending at ts, oldest
+// first, from randomly picked containers.
+func (f *Fleet) Logs(ts time.Time, interval time.Duration, n int) []models.LogEntry {
+	containers := f.Containers()
+	if len(containers) == 0 || n <= 0 {
+		return nil
+	}
+	out := make([]models.LogEntry, 0, n)
+	for i := range n {
+		c := containers[f.rnd.IntN(len(containers))]
+		level, msg := f.kinds[c.ID].logs(f.rnd)
+		stream := "stdout"
+		if level == "ERROR" || level == "WARN" {
+			stream = "stderr"
+		}
+		at := ts.Add(-interval + time.Duration(int64(interval)*int64(i+1)/int64(n))).UTC()
+		out = append(out, models.LogEntry{TS: at, ServiceID: c.ServiceID, ContainerID: c.ID, Level: level, Stream: stream, Message: msg})
+	}
+	return out
+}
+
+func (f *Fleet) hexID() string {
+	var b strings.Builder
+	for range 4 {
+		fmt.Fprintf(&b, "%016x", f.rnd.Uint64())
+	}
+	return b.String()
+}
+
+var paths = []string{"/", "/api/v1/items", "/api/v1/items/42", "/login", "/static/app.js", "/healthz", "/api/v1/search?q=docker", "/favicon.ico"}
+
+func accessLog(r *rand.Rand) (string, string) {
+	status, level := 200, "INFO"
+	switch n := r.IntN(100); {
+	case n < 2:
+		status, level = 502, "ERROR"
+	case n < 6:
+		status, level = 404, "WARN"
+	case n < 10:
+		status = 304
+	}
+	ip := fmt.Sprintf("192.168.%d.%d", r.IntN(4), 2+r.IntN(250))
+	return level, fmt.Sprintf(`%s - - "%s %s HTTP/1.1" %d %d %.3f`, ip, []string{"GET", "GET", "GET", "POST"}[r.IntN(4)], paths[r.IntN(len(paths))], status, 200+r.IntN(50_000), r.ExpFloat64()*0.05)
+}
+
+func appLog(r *rand.Rand) (string, string) {
+	switch n := r.IntN(100); {
+	case n < 2:
+		return "ERROR", fmt.Sprintf(`{"level":"error","msg":"request failed","err":"context deadline exceeded","duration_ms":%d}`, 5000+r.IntN(5000))
+	case n < 7:
+		return "WARN", fmt.Sprintf(`{"level":"warn","msg":"slow query","duration_ms":%d}`, 500+r.IntN(2000))
+	case n < 15:
+		return "DEBUG", fmt.Sprintf(`{"level":"debug","msg":"cache lookup","hit":%t}`, r.IntN(2) == 0)
+	}
+	return "INFO", fmt.Sprintf(`{"level":"info","msg":"handled request","status":200,"duration_ms":%d,"user_id":%d}`, r.IntN(200), r.IntN(5000))
+}
+
+func postgresLog(r *rand.Rand) (string, string) {
+	switch n := r.IntN(100); {
+	case n < 2:
+		return "ERROR", `ERROR:  duplicate key value violates unique constraint "users_email_key"`
+	case n < 10:
+		return "INFO", fmt.Sprintf("LOG:  duration: %.3f ms  statement: SELECT * FROM items WHERE owner_id = $1", 100+r.Float64()*900)
+	}
+	return "INFO", fmt.Sprintf("LOG:  checkpoint complete: wrote %d buffers (%.1f%%)", r.IntN(5000), r.Float64()*10)
+}
+
+func redisLog(r *rand.Rand) (string, string) {
+	if r.IntN(50) == 0 {
+		return "WARN", "1:M WARNING Memory overcommit must be enabled!"
+	}
+	return "INFO", fmt.Sprintf("1:M * %d changes in 60 seconds. Saving...", 1+r.IntN(10_000))
+}
@@ -0,0 +1,62 @@
+package synth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+)
+
+func TestFleetIsReproducible(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	a := NewFleet(Options{Host: "demo", Services: 14, Replicas: 2, Seed: 7}, now)
+	b := NewFleet(Options{Host: "demo", Services: 14, Replicas: 2, Seed: 7}, now)
+	if len(a.Stacks) != 14 || len(a.Containers()) != 28 {
+		t.Fatalf("fleet has %d services and %d containers", len(a.Stacks), len(a.Containers()))
+	}
+	if a.Stacks[12].Service.ID != "web-2@demo" || a.Containers()[0].ID != b.Containers()[0].ID {
+		t.Fatalf("services differ: %s, %s vs %s", a.Stacks[12].Service.ID, a.Containers()[0].ID, b.Containers()[0].ID)
+	}
+	_, first := a.Sample(now, 10*time.Second)
+	_, again := a.Sample(now.Add(10*time.Second), 10*time.Second)
+	for i, m := range again {
+		if m.NetRXBytes < first[i].NetRXBytes || m.CPUPct < 0 || m.MemUsedBytes <= 0 {
+			t.Fatalf("sample %d not plausible: %+v after %+v", i, m, first[i])
+		}
+	}
+	logs := a.Logs(now, time.Minute, 50)
+	if len(logs) != 50 || logs[0].TS.Before(now.Add(-time.Minute)) || logs[49].TS.After(now) {
+		t.Fatalf("logs span %v..%v, want within the last minute", logs[0].TS, logs[len(logs)-1].TS)
+	}
+}
+
+func TestSeedWritesHistory(t *testing.T) {
+	sqldb, err := db.Open(t.TempDir() + "/seed.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	f := NewFleet(Options{Host: "demo", Services: 3, Seed: 1}, now)
+	n, err := f.Seed(ctx, repo, History{Span: time.Hour, Interval: time.Minute, LogsPerMinute: 10, Alerts: 5}, now)
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if n.Containers != 3 || n.Samples != 61*4 || n.Logs == 0 || n.Alerts != 5 {
+		t.Fatalf("counts = %+v", n)
+	}
+	rows, err := repo.ListServicesWithHealth(ctx, 0, 0, 10, false, "demo", nil)
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("services = %+v, err %v", rows, err)
+	}
+	if firing, err := repo.ActiveAlertCount(ctx); err != nil || firing != 3 {
+		t.Fatalf("firing alerts = %d, err %v", firing, err)
+	}
+}
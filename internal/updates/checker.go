@@ -0,0 +1,130 @@
+// Package updates compares the images of running services with their
+// registries and records which services have a newer image available.
+package updates
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/docker"
+	"dashi/internal/filter"
+	"dashi/internal/models"
+)
+
+type Checker struct {
+	repo   *db.Repository
+	dc     *docker.Client
+	log    *slog.Logger
+	filter *filter.Filter
+	host   string
+	auth   map[string]docker.RegistryAuth
+	now    func() time.Time
+}
+
+// NewChecker checks the services of one Docker host. auth holds credentials
+// by registry host ("docker.io", "ghcr.io", ...); lookups for other
+// registries are anonymous.
+func NewChecker(repo *db.Repository, dc *docker.Client, logger *slog.Logger, flt *filter.Filter, dockerHost string, auth map[string]docker.RegistryAuth) *Checker {
+	return &Checker{repo: repo, dc: dc, log: logger, filter: flt, host: dockerHost, auth: auth, now: time.Now}
+}
+
+// Run checks immediately and then every interval until ctx is done. Registry
+// lookups can be slow, so it runs on its own goroutine rather than the main
+// loop.
+func (c *Checker) Run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		c.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Check records an ImageUpdate for every running service. Each image and
+// reference is looked up once per run even when several services share it.
+func (c *Checker) Check(ctx context.Context) {
+	containers, err := c.dc.ListContainers(ctx)
+	if err != nil {
+		if !errors.Is(err, docker.ErrForbidden) {
+			c.log.Warn("list containers", "err", err)
+		}
+		return
+	}
+	images := map[string]docker.ImageInspect{}
+	latest := map[string]string{}
+	lookupErr := map[string]error{}
+	done := map[string]bool{}
+	checked, available := 0, 0
+	for _, ct := range containers {
+		if ct.State != "running" || len(ct.Names) == 0 || !c.filter.Allows(ct.Names[0], ct.Labels) {
+			continue
+		}
+		svcID := docker.ServiceID(c.host, ct)
+		if done[svcID] {
+			continue
+		}
+		done[svcID] = true
+
+		u := models.ImageUpdate{ServiceID: svcID, Image: ct.Image, CheckedAt: c.now().UTC()}
+		ref := docker.ParseReference(ct.Image)
+		switch {
+		case strings.HasPrefix(ct.Image, "sha256:"):
+			u.Error = "container runs an untagged image"
+		case ref.Tag == "":
+			u.CurrentDigest = ref.Digest
+			u.Error = "image is pinned to a digest"
+		default:
+			img, ok := images[ct.ImageID]
+			if !ok {
+				if img, err = c.dc.InspectImage(ctx, ct.ImageID); err != nil && !errors.Is(err, docker.ErrForbidden) {
+					c.log.Warn("inspect image", "image", ct.Image, "err", err)
+				}
+				images[ct.ImageID] = img
+			}
+			u.CurrentDigest = img.RepoDigest(ref)
+			if u.CurrentDigest == "" {
+				u.Error = "image has no registry digest"
+				break
+			}
+			key := ref.String()
+			if _, ok := latest[key]; !ok && lookupErr[key] == nil {
+				var auth *docker.RegistryAuth
+				if a, ok := c.auth[ref.Registry]; ok {
+					auth = &a
+				}
+				digest, err := c.dc.DistributionDigest(ctx, key, auth)
+				if err != nil {
+					if !errors.Is(err, docker.ErrForbidden) {
+						c.log.Warn("registry lookup failed", "image", key, "err", err)
+					}
+					lookupErr[key] = err
+				} else {
+					latest[key] = digest
+				}
+			}
+			if err := lookupErr[key]; err != nil {
+				u.Error = err.Error()
+				break
+			}
+			u.LatestDigest = latest[key]
+			u.UpdateAvailable = u.LatestDigest != u.CurrentDigest
+		}
+		if err := c.repo.SaveImageUpdate(ctx, u); err != nil {
+			c.log.Error("save image update", "service", svcID, "err", err)
+			continue
+		}
+		checked++
+		if u.UpdateAvailable {
+			available++
+		}
+	}
+	c.log.Info("image update check finished", "services", checked, "updates", available)
+}
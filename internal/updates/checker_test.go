@@ -0,0 +1,88 @@
+package updates
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/docker"
+	"dashi/internal/models"
+)
+
+func TestCheckComparesRepoDigestWithRegistry(t *testing.T) {
+	var gotAuth docker.RegistryAuth
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/containers/json":
+			_, _ = io.WriteString(w, `[
+				{"Id":"c1","Names":["/web"],"Image":"nginx:1.27","ImageID":"sha256:img1","State":"running","Labels":{"com.docker.compose.service":"web"}},
+				{"Id":"c2","Names":["/api"],"Image":"ghcr.io/acme/api:v2","ImageID":"sha256:img2","State":"running","Labels":{"com.docker.compose.service":"api"}}
+			]`)
+		case r.URL.Path == "/images/sha256:img1/json":
+			_, _ = io.WriteString(w, `{"Id":"sha256:img1","RepoDigests":["nginx@sha256:old"]}`)
+		case r.URL.Path == "/images/sha256:img2/json":
+			_, _ = io.WriteString(w, `{"Id":"sha256:img2","RepoDigests":["ghcr.io/acme/api@sha256:same"]}`)
+		case r.URL.Path == "/distribution/docker.io/library/nginx:1.27/json":
+			_, _ = io.WriteString(w, `{"Descriptor":{"digest":"sha256:new"}}`)
+		case r.URL.Path == "/distribution/ghcr.io/acme/api:v2/json":
+			b, _ := base64.URLEncoding.DecodeString(r.Header.Get("X-Registry-Auth"))
+			_ = json.Unmarshal(b, &gotAuth)
+			_, _ = io.WriteString(w, `{"Descriptor":{"digest":"sha256:same"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer daemon.Close()
+	dc, err := docker.Dial("tcp://"+strings.TrimPrefix(daemon.URL, "http://"), "")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	ctx := context.Background()
+	for _, id := range []string{"web", "api"} {
+		err := repo.UpsertServiceAndContainer(ctx,
+			models.Service{ID: id, Name: id, Image: "img", LabelsJSON: "{}", Status: "running"},
+			models.Container{ID: "c-" + id, ServiceID: id, Name: id, Status: "running", LastSeenAt: time.Now()},
+		)
+		if err != nil {
+			t.Fatalf("seed %s: %v", id, err)
+		}
+	}
+
+	auth := map[string]docker.RegistryAuth{"ghcr.io": {Username: "bot", Password: "token"}}
+	NewChecker(repo, dc, slog.New(slog.NewTextHandler(io.Discard, nil)), nil, docker.LocalHost, auth).Check(ctx)
+
+	updates, err := repo.ListImageUpdates(ctx)
+	if err != nil {
+		t.Fatalf("list updates: %v", err)
+	}
+	if len(updates) != 2 {
+		t.Fatalf("updates = %+v, want 2", updates)
+	}
+	if u := updates[0]; u.ServiceID != "web" || !u.UpdateAvailable || u.LatestDigest != "sha256:new" {
+		t.Fatalf("web = %+v, want update to sha256:new", u)
+	}
+	if u := updates[1]; u.ServiceID != "api" || u.UpdateAvailable || u.Error != "" {
+		t.Fatalf("api = %+v, want up to date", u)
+	}
+	if gotAuth.Username != "bot" || gotAuth.Password != "token" {
+		t.Fatalf("registry auth = %+v", gotAuth)
+	}
+}
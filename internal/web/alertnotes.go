@@ -0,0 +1,181 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/models"
+)
+
+// Limits of an alert note, in characters.
+const (
+	alertNoteMaxText   = 4000
+	alertNoteMaxAuthor = 100
+)
+
+// newAlertNote validates a note to be added to an alert.
+func newAlertNote(alertID int64, in api.NewAlertNote) (models.AlertNote, error) {
+	n := models.AlertNote{AlertID: alertID, TS: time.Now().UTC(), Author: strings.TrimSpace(in.Author), Text: strings.TrimSpace(in.Text)}
+	switch {
+	case n.Text == "":
+		return n, errors.New("text is required")
+	case len([]rune(n.Text)) > alertNoteMaxText:
+		return n, fmt.Errorf("text is longer than %d characters", alertNoteMaxText)
+	case len([]rune(n.Author)) > alertNoteMaxAuthor:
+		return n, fmt.Errorf("author is longer than %d characters", alertNoteMaxAuthor)
+	}
+	return n, nil
+}
+
+// withAlertNotes adds the notes of each alert row under "notes"; rows
+// without an ID, such as deployments, are left alone.
+func (s *Server) withAlertNotes(ctx context.Context, rows []map[string]any) error {
+	ids := make([]int64, 0, len(rows))
+	for _, a := range rows {
+		if id, ok := a["id"].(int64); ok {
+			ids = append(ids, id)
+		}
+	}
+	notes, err := s.repo.AlertNotes(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("load alert notes: %w", err)
+	}
+	for _, a := range rows {
+		if id, ok := a["id"].(int64); ok && len(notes[id]) > 0 {
+			a["notes"] = notes[id]
+		}
+	}
+	return nil
+}
+
+// alertNotePath splits "{id}/notes" and "{id}/notes/{note}" after prefix;
+// note is 0 without one.
+func alertNotePath(path, prefix string) (alertID, note int64, ok bool) {
+	rest, found := strings.CutPrefix(path, prefix)
+	if !found {
+		return 0, 0, false
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[1] != "notes" {
+		return 0, 0, false
+	}
+	alertID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || alertID <= 0 {
+		return 0, 0, false
+	}
+	if len(parts) == 3 {
+		if note, err = strconv.ParseInt(parts[2], 10, 64); err != nil || note <= 0 {
+			return 0, 0, false
+		}
+	}
+	return alertID, note, true
+}
+
+// handleV1AlertNotes serves GET and POST /api/v1/alerts/{id}/notes and
+// DELETE /api/v1/alerts/{id}/notes/{note}.
+func (s *Server) handleV1AlertNotes(w http.ResponseWriter, r *http.Request) {
+	alertID, noteID, ok := alertNotePath(r.URL.Path, apiV1Prefix+"/alerts/")
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "not found")
+		return
+	}
+	switch {
+	case noteID == 0 && r.Method == http.MethodGet:
+		found, err := s.repo.AlertExists(r.Context(), alertID)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !found {
+			writeAPIError(w, http.StatusNotFound, "alert not found")
+			return
+		}
+		notes, err := s.repo.AlertNotes(r.Context(), []int64{alertID})
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, api.AlertNotes{Items: api.AlertNotesFrom(notes[alertID])})
+	case noteID == 0 && r.Method == http.MethodPost:
+		var in api.NewAlertNote
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&in); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid note: "+err.Error())
+			return
+		}
+		n, err := newAlertNote(alertID, in)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		n.ID, err = s.repo.AddAlertNote(r.Context(), n)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeAPIError(w, http.StatusNotFound, "alert not found")
+			return
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, api.AlertNoteFrom(n))
+	case noteID != 0 && r.Method == http.MethodDelete:
+		found, err := s.repo.DeleteAlertNote(r.Context(), alertID, noteID)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !found {
+			writeAPIError(w, http.StatusNotFound, "note not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAlertNotesFragment adds a note from the alerts panel, POST
+// /fragments/alerts/{id}/notes, or deletes one, POST
+// /fragments/alerts/{id}/notes/{note}, and renders the panel again.
+func (s *Server) handleAlertNotesFragment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	alertID, noteID, ok := alertNotePath(r.URL.Path, "/fragments/alerts/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if noteID != 0 {
+		if _, err := s.repo.DeleteAlertNote(r.Context(), alertID, noteID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.renderAlertsFragment(w, r)
+		return
+	}
+	n, err := newAlertNote(alertID, api.NewAlertNote{Author: r.FormValue("author"), Text: r.FormValue("text")})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := s.repo.AddAlertNote(r.Context(), n); errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "alert not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.renderAlertsFragment(w, r)
+}
@@ -0,0 +1,82 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/api"
+)
+
+func TestAlertNotes(t *testing.T) {
+	s, repo := newTestServer(t, Options{})
+	ctx := context.Background()
+	rules, err := repo.ListRules(ctx)
+	if err != nil || len(rules) == 0 {
+		t.Fatalf("rules = %v, %v", rules, err)
+	}
+	alertID, err := repo.CreateAlert(ctx, rules[0].ID, "host", "firing", "disk full", nil, time.Now())
+	if err != nil {
+		t.Fatalf("create alert: %v", err)
+	}
+	h := s.Routes()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if strings.HasPrefix(path, "/fragments/") {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	notes := "/api/v1/alerts/" + strconv.FormatInt(alertID, 10) + "/notes"
+
+	rec := do(http.MethodPost, notes, `{"author": "ops", "text": " pruned old images "}`)
+	var note api.AlertNote
+	if err := json.Unmarshal(rec.Body.Bytes(), &note); err != nil || rec.Code != http.StatusCreated || note.Text != "pruned old images" || note.AlertID != alertID {
+		t.Fatalf("add note = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, notes, `{"text": "  "}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("empty note = %d, want 400", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/v1/alerts/999/notes", `{"text": "x"}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("note on unknown alert = %d, want 404", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/v1/alerts/999/notes", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("notes of unknown alert = %d, want 404", rec.Code)
+	}
+	rec = do(http.MethodGet, notes, "")
+	var list api.AlertNotes
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list.Items) != 1 || list.Items[0].Author != "ops" {
+		t.Fatalf("notes = %s", rec.Body)
+	}
+
+	// Alerts carry their notes.
+	rec = do(http.MethodGet, "/api/v1/alerts", "")
+	var alerts api.Alerts
+	if err := json.Unmarshal(rec.Body.Bytes(), &alerts); err != nil || len(alerts.Items) != 1 || len(alerts.Items[0].Notes) != 1 {
+		t.Fatalf("alerts = %s", rec.Body)
+	}
+
+	form := url.Values{"text": {"root cause: build cache"}}
+	rec = do(http.MethodPost, "/fragments/alerts/"+strconv.FormatInt(alertID, 10)+"/notes", form.Encode())
+	if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, "pruned old images") || !strings.Contains(body, "root cause: build cache") {
+		t.Fatalf("alerts fragment = %d\n%s", rec.Code, body)
+	}
+
+	if rec := do(http.MethodDelete, notes+"/"+strconv.FormatInt(note.ID, 10), ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete note = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodDelete, notes+"/"+strconv.FormatInt(note.ID, 10), ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("delete note again = %d, want 404", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/v1/alerts/x/notes", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("bad alert id = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,82 @@
+package web
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strconv"
+	"strings"
+)
+
+// ansiHTML renders the ANSI color codes kept in log messages
+// (APP_LOG_COLORS) as spans with ansi-* classes and escapes everything else.
+// Messages without escape sequences come out as plain escaped text.
+func ansiHTML(s string) template.HTML {
+	if !strings.Contains(s, "\x1b[") {
+		return template.HTML(html.EscapeString(s))
+	}
+	var b strings.Builder
+	fg, bold := -1, false
+	write := func(text string) {
+		if text == "" {
+			return
+		}
+		var classes []string
+		if fg >= 0 {
+			classes = append(classes, fmt.Sprintf("ansi-fg-%d", fg))
+		}
+		if bold {
+			classes = append(classes, "ansi-bold")
+		}
+		if len(classes) == 0 {
+			b.WriteString(html.EscapeString(text))
+			return
+		}
+		fmt.Fprintf(&b, `<span class="%s">%s</span>`, strings.Join(classes, " "), html.EscapeString(text))
+	}
+	for {
+		i := strings.Index(s, "\x1b[")
+		if i < 0 {
+			write(s)
+			break
+		}
+		write(s[:i])
+		s = s[i+2:]
+		end := strings.IndexByte(s, 'm')
+		if end < 0 {
+			// A sequence cut off by truncation.
+			break
+		}
+		params := strings.Split(s[:end], ";")
+		s = s[end+1:]
+		for j := 0; j < len(params); j++ {
+			n, _ := strconv.Atoi(params[j])
+			switch {
+			case n == 0:
+				fg, bold = -1, false
+			case n == 1:
+				bold = true
+			case n == 22:
+				bold = false
+			case n >= 30 && n <= 37:
+				fg = n - 30
+			case n >= 90 && n <= 97:
+				fg = n - 90 + 8
+			case n == 39:
+				fg = -1
+			case n == 38 || n == 48:
+				// Extended colors: 5;n from the 256-color palette, of which
+				// the first 16 are the basic ones, or 2;r;g;b.
+				if j+2 < len(params) && params[j+1] == "5" {
+					if c, err := strconv.Atoi(params[j+2]); err == nil && c < 16 && n == 38 {
+						fg = c
+					}
+					j += 2
+				} else if j+4 < len(params) && params[j+1] == "2" {
+					j += 4
+				}
+			}
+		}
+	}
+	return template.HTML(b.String())
+}
@@ -0,0 +1,18 @@
+package web
+
+import "testing"
+
+func TestANSIHTML(t *testing.T) {
+	cases := map[string]string{
+		"plain <b>":                                  "plain &lt;b&gt;",
+		"\x1b[31mERROR\x1b[0m done":                  `<span class="ansi-fg-1">ERROR</span> done`,
+		"\x1b[1;92mok\x1b[22m still green":           `<span class="ansi-fg-10 ansi-bold">ok</span><span class="ansi-fg-10"> still green</span>`,
+		"\x1b[38;5;3mwarn\x1b[39m \x1b[38;2;1;2;3mx": `<span class="ansi-fg-3">warn</span> x`,
+		"cut \x1b[3":                                 "cut ",
+	}
+	for in, want := range cases {
+		if got := string(ansiHTML(in)); got != want {
+			t.Errorf("ansiHTML(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,570 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/db"
+	"dashi/internal/docker"
+	"dashi/internal/rollup"
+)
+
+const apiV1Prefix = "/api/" + api.Version
+
+func (s *Server) registerAPIV1(mux *http.ServeMux) {
+	mux.HandleFunc(apiV1Prefix+"/metrics/host", disabled(s.opts.MetricsDisabled, "metric collection", s.handleV1HostMetrics))
+	mux.HandleFunc(apiV1Prefix+"/metrics/disks", disabled(s.opts.MetricsDisabled, "metric collection", s.handleV1DiskMetrics))
+	mux.HandleFunc(apiV1Prefix+"/metrics/temperatures", disabled(s.opts.MetricsDisabled, "metric collection", s.handleV1Temperatures))
+	mux.HandleFunc(apiV1Prefix+"/metrics/container/", disabled(s.opts.MetricsDisabled, "metric collection", s.handleV1ContainerMetrics))
+	mux.HandleFunc(apiV1Prefix+"/metrics/compare", disabled(s.opts.MetricsDisabled, "metric collection", s.handleV1Compare))
+	mux.HandleFunc(apiV1Prefix+"/metrics/top", disabled(s.opts.MetricsDisabled, "metric collection", s.handleV1Top))
+	mux.HandleFunc(apiV1Prefix+"/scrape/targets", disabled(s.opts.MetricsDisabled, "metric collection", s.handleV1ScrapeTargets))
+	mux.HandleFunc(apiV1Prefix+"/scrape/series", disabled(s.opts.MetricsDisabled, "metric collection", s.handleV1ScrapeSeries))
+	mux.HandleFunc(apiV1Prefix+"/health", s.handleV1Health)
+	mux.HandleFunc(apiV1Prefix+"/hosts", s.handleV1Hosts)
+	mux.HandleFunc(apiV1Prefix+"/agents", s.handleV1Agents)
+	mux.HandleFunc(apiV1Prefix+"/agents/", s.handleV1Agent)
+	mux.HandleFunc(apiV1Prefix+"/summary", s.handleV1Summary)
+	mux.HandleFunc(apiV1Prefix+"/sites", s.handleV1Sites)
+	mux.HandleFunc(apiV1Prefix+"/containers/", s.handleV1Container)
+	mux.HandleFunc(apiV1Prefix+"/services", s.handleV1Services)
+	mux.HandleFunc(apiV1Prefix+"/services/", s.handleV1Service)
+	mux.HandleFunc(apiV1Prefix+"/checks", s.handleV1Checks)
+	mux.HandleFunc(apiV1Prefix+"/checks/", s.handleV1Check)
+	mux.HandleFunc(apiV1Prefix+"/heartbeats", s.handleV1Heartbeats)
+	mux.HandleFunc(apiV1Prefix+"/heartbeats/", s.handleV1Heartbeat)
+	mux.HandleFunc(apiV1Prefix+"/images/updates", s.handleV1ImageUpdates)
+	mux.HandleFunc(apiV1Prefix+"/clock", s.handleV1Clock)
+	mux.HandleFunc(apiV1Prefix+"/reboots", s.handleV1Reboots)
+	mux.HandleFunc(apiV1Prefix+"/deployments", s.handleV1Deployments)
+	mux.HandleFunc(apiV1Prefix+"/restarts", s.handleV1Restarts)
+	mux.HandleFunc(apiV1Prefix+"/availability", s.handleV1Availability)
+	mux.HandleFunc(apiV1Prefix+"/topology", s.handleV1Topology)
+	mux.HandleFunc(apiV1Prefix+"/storage", s.handleV1Storage)
+	mux.HandleFunc(apiV1Prefix+"/pools", s.handleV1Pools)
+	mux.HandleFunc(apiV1Prefix+"/internals", s.handleV1Internals)
+	mux.HandleFunc(apiV1Prefix+"/logs", disabled(s.opts.LogsDisabled, "log ingestion", s.handleV1Logs))
+	mux.HandleFunc(apiV1Prefix+"/logs/histogram", disabled(s.opts.LogsDisabled, "log ingestion", s.handleV1LogHistogram))
+	mux.HandleFunc(apiV1Prefix+"/logs/groups", disabled(s.opts.LogsDisabled, "log ingestion", s.handleV1LogGroups))
+	mux.HandleFunc(apiV1Prefix+"/logs/drops", disabled(s.opts.LogsDisabled, "log ingestion", s.handleV1LogDrops))
+	mux.HandleFunc(apiV1Prefix+"/logs/stream", disabled(s.opts.LogsDisabled, "log ingestion", s.handleV1LogStream))
+	mux.HandleFunc(apiV1Prefix+"/logs/archives", disabled(s.opts.LogsDisabled, "log ingestion", s.handleV1LogArchives))
+	mux.HandleFunc(apiV1Prefix+"/logs/archives/", disabled(s.opts.LogsDisabled, "log ingestion", s.handleV1LogArchiveRestore))
+	mux.HandleFunc(apiV1Prefix+"/alerts", disabled(s.opts.AlertsDisabled, "alerting", s.handleV1Alerts))
+	mux.HandleFunc(apiV1Prefix+"/alerts/", disabled(s.opts.AlertsDisabled, "alerting", s.handleV1AlertNotes))
+	mux.HandleFunc(apiV1Prefix+"/alerts/test-telegram", disabled(s.opts.AlertsDisabled, "alerting", s.handleV1TestTelegram))
+	mux.HandleFunc(apiV1Prefix+"/preferences", s.handleV1Preferences)
+	mux.HandleFunc(apiV1Prefix+"/dashboards", s.handleV1Dashboards)
+	mux.HandleFunc(apiV1Prefix+"/dashboards/", s.handleV1Dashboard)
+	mux.HandleFunc(apiV1Prefix+"/settings", s.handleV1Settings)
+	mux.HandleFunc(apiV1Prefix+"/settings/", s.handleV1Setting)
+	mux.HandleFunc(apiV1Prefix+"/admin/backup", s.handleV1Backup)
+	mux.HandleFunc(apiV1Prefix+"/admin/config", s.handleV1Config)
+	mux.HandleFunc(apiV1Prefix+"/admin/reload", s.handleV1Reload)
+	mux.HandleFunc(apiV1Prefix+"/admin/prune", s.handleV1Prune)
+	mux.HandleFunc(apiV1Prefix+"/admin/audit", s.handleV1Audit)
+}
+
+// disabled answers 404 instead of calling next when the subsystem named
+// what is switched off.
+func disabled(off bool, what string, next http.HandlerFunc) http.HandlerFunc {
+	if !off {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			writeAPIError(w, http.StatusNotFound, what+" is disabled")
+			return
+		}
+		http.Error(w, what+" is disabled", http.StatusNotFound)
+	}
+}
+
+// deprecated marks a legacy route and points clients at its /api/v1 successor.
+func deprecated(successor string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+">; rel=\"successor-version\"")
+		next(w, r)
+	}
+}
+
+func (s *Server) handleV1HostMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	rng := parseRange(r.URL.Query().Get("range"))
+	res, ok := queryResolution(r, rng)
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, "invalid resolution")
+		return
+	}
+	from := time.Now().Add(-rng)
+	out := api.HostMetrics{Range: rng.String(), Resolution: resolutionName(res)}
+	if res == 0 {
+		metrics, err := s.repo.RecentHostMetrics(r.Context(), from, 4096)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		out.Items = api.HostMetricsFrom(metrics)
+	} else {
+		metrics, err := s.repo.HostMetricRollups(r.Context(), res, from, 4096)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		out.Items = api.HostMetricRollupsFrom(metrics)
+	}
+	writeJSON(w, out)
+}
+
+// handleV1DiskMetrics serves raw per-device samples; host metric rollups
+// carry the summed rates for longer ranges.
+func (s *Server) handleV1DiskMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	rng := parseRange(r.URL.Query().Get("range"))
+	disks, err := s.repo.RecentDiskIO(r.Context(), time.Now().Add(-rng), 16384)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.DiskIOMetrics{Range: rng.String(), Items: api.DiskIOFrom(disks)})
+}
+
+func (s *Server) handleV1Temperatures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	rng := parseRange(r.URL.Query().Get("range"))
+	temps, err := s.repo.RecentTemperatures(r.Context(), time.Now().Add(-rng), 16384)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.Temperatures{Range: rng.String(), Items: api.TemperaturesFrom(temps)})
+}
+
+func (s *Server) handleV1ContainerMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	containerID := strings.TrimPrefix(r.URL.Path, apiV1Prefix+"/metrics/container/")
+	if containerID == "" || strings.Contains(containerID, "/") {
+		writeAPIError(w, http.StatusNotFound, "container not found")
+		return
+	}
+	rng := parseRange(r.URL.Query().Get("range"))
+	res, ok := queryResolution(r, rng)
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, "invalid resolution")
+		return
+	}
+	from := time.Now().Add(-rng)
+	out := api.ContainerMetrics{ContainerID: containerID, Range: rng.String(), Resolution: resolutionName(res)}
+	if res == 0 {
+		metrics, err := s.repo.RecentContainerMetrics(r.Context(), containerID, from, 4096)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		out.Items = api.ContainerMetricsFrom(metrics)
+	} else {
+		metrics, err := s.repo.ContainerMetricRollups(r.Context(), containerID, res, from, 4096)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		out.Items = api.ContainerMetricRollupsFrom(metrics)
+	}
+	writeJSON(w, out)
+}
+
+func (s *Server) handleV1Logs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	f := logFiltersFromQuery(r)
+	lq, err := logQueryFrom(r, f)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	entries, err := s.repo.QueryLogs(r.Context(), lq)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.Logs{Filters: f, Items: api.LogEntriesFrom(entries)})
+}
+
+func (s *Server) handleV1LogGroups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	groupBy := queryGroupBy(r)
+	if groupBy == "" {
+		writeAPIError(w, http.StatusBadRequest, "group_by is required")
+		return
+	}
+	f := logFiltersFromQuery(r)
+	lq, err := logQueryFrom(r, f)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	groups, err := s.repo.GroupLogs(r.Context(), groupBy, lq)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, api.LogGroups{GroupBy: groupBy, Filters: f, Groups: api.LogGroupsFrom(groups)})
+}
+
+func (s *Server) handleV1LogDrops(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	out := api.LogDrops{Items: []api.LogDrop{}}
+	if s.opts.LogDrops != nil {
+		for _, c := range s.opts.LogDrops.Counts() {
+			out.Items = append(out.Items, api.LogDrop{Name: c.Name, Service: c.Service, Level: c.Level, Pattern: c.Pattern, Dropped: c.Dropped})
+		}
+	}
+	writeJSON(w, out)
+}
+
+func (s *Server) handleV1Hosts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	hosts, err := s.repo.ListHosts(r.Context())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.Hosts{Items: api.HostsFrom(hosts, time.Now())})
+}
+
+// handleV1Services lists running services by their latest CPU and memory
+// use, highest first.
+func (s *Server) handleV1Services(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	labels, err := queryLabels(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	host := strings.TrimSpace(q.Get("host"))
+	rows, err := s.repo.ListServicesWithHealth(r.Context(), 0, 0, limit, q.Get("include_missing") == "1", host, labels)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.Services{Items: api.ServicesFrom(rows)})
+}
+
+// handleV1Alerts lists the alerts started within the range, newest first,
+// optionally only those with the given status (firing or recovered) or on
+// the given host.
+func (s *Server) handleV1Alerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	q := r.URL.Query()
+	rng := 24 * time.Hour
+	if q.Get("range") != "" {
+		rng = parseRange(q.Get("range"))
+	}
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	rows, err := s.repo.RecentAlerts(r.Context(), time.Now().Add(-rng), limit, strings.TrimSpace(q.Get("host")))
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := s.withAlertNotes(r.Context(), rows); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	items := api.AlertsFrom(rows)
+	if status := q.Get("status"); status != "" {
+		items = slices.DeleteFunc(items, func(a api.Alert) bool { return a.Status != status })
+	}
+	writeJSON(w, api.Alerts{Range: rng.String(), Items: items})
+}
+
+func (s *Server) handleV1ImageUpdates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	updates, err := s.repo.ListImageUpdates(r.Context())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.ImageUpdates{Items: api.ImageUpdatesFrom(updates)})
+}
+
+func (s *Server) handleV1Clock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	offsets, err := s.repo.ListClockOffsets(r.Context())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.ClockOffsets{Items: api.ClockOffsetsFrom(offsets)})
+}
+
+func (s *Server) handleV1Reboots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	reboots, err := s.repo.RecentHostReboots(r.Context(), limit)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.HostReboots{Items: api.HostRebootsFrom(reboots)})
+}
+
+func (s *Server) handleV1Storage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	volumes, images, err := s.storage(r)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.StorageFrom(volumes, images))
+}
+
+func (s *Server) handleV1Pools(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	pools, err := s.repo.ListStoragePools(r.Context())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.StoragePools{Items: api.StoragePoolsFrom(pools)})
+}
+
+func (s *Server) handleV1Health(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	out := api.Health{Status: "ok", Database: "ok", Docker: []api.DockerHealth{}}
+	if err := s.repo.DB().PingContext(r.Context()); err != nil {
+		out.Status, out.Database = "unavailable", err.Error()
+	}
+	hosts := s.opts.DockerHosts
+	if len(hosts) == 0 && s.docker != nil {
+		hosts = map[string]*docker.Client{docker.LocalHost: s.docker}
+	}
+	names := make([]string, 0, len(hosts))
+	for name := range hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dc := hosts[name]
+		h := api.DockerHealth{Host: name, Status: "ok", Denied: dc.Denied()}
+		if err := dc.Ping(r.Context()); err != nil {
+			h.Status, h.Error = "unreachable", err.Error()
+		} else if len(h.Denied) > 0 {
+			h.Status = "degraded"
+		}
+		if h.Status != "ok" && out.Status == "ok" {
+			out.Status = "degraded"
+		}
+		out.Docker = append(out.Docker, h)
+	}
+	for _, f := range []struct {
+		name string
+		off  bool
+	}{{"logs", s.opts.LogsDisabled}, {"alerts", s.opts.AlertsDisabled}, {"metrics", s.opts.MetricsDisabled}} {
+		if f.off {
+			out.Disabled = append(out.Disabled, f.name)
+		}
+	}
+	if out.ConfigWarnings = s.opts.ConfigWarnings; len(out.ConfigWarnings) > 0 && out.Status == "ok" {
+		out.Status = "degraded"
+	}
+	if out.Status == "unavailable" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(out)
+		return
+	}
+	writeJSON(w, out)
+}
+
+func (s *Server) handleV1TestTelegram(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if err := s.notify.Send(r.Context(), "Dashi test alert: Telegram integration is working"); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.Status{Status: "ok"})
+}
+
+// queryResolution honours an explicit ?resolution= (raw, 1m, 5m, 1h) and
+// otherwise picks one from the range so responses stay a few thousand points.
+func queryResolution(r *http.Request, rng time.Duration) (time.Duration, bool) {
+	v := strings.TrimSpace(r.URL.Query().Get("resolution"))
+	if v == "" {
+		return rollup.PickResolution(rng), true
+	}
+	return rollup.ParseResolution(v)
+}
+
+func resolutionName(res time.Duration) string {
+	if res == 0 {
+		return "raw"
+	}
+	return res.String()
+}
+
+func logFiltersFromQuery(r *http.Request) api.LogFilters {
+	q := r.URL.Query()
+	return api.LogFilters{
+		Service: q.Get("service"),
+		Host:    q.Get("host"),
+		Query:   q.Get("q"),
+		Level:   q.Get("level"),
+		Stream:  q.Get("stream"),
+		Labels:  q.Get("labels"),
+		Range:   q.Get("range"),
+		From:    q.Get("from"),
+		To:      q.Get("to"),
+		Fields:  queryFields(r),
+	}
+}
+
+func logQueryFrom(r *http.Request, f api.LogFilters) (db.LogQuery, error) {
+	labels, err := db.ParseLabelSelector(f.Labels)
+	if err != nil {
+		return db.LogQuery{}, err
+	}
+	if err := validateFields(f.Fields); err != nil {
+		return db.LogQuery{}, err
+	}
+	from, to, err := queryLogWindow(r)
+	if err != nil {
+		return db.LogQuery{}, err
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	lq := db.LogQuery{
+		Host:   f.Host,
+		Query:  f.Query,
+		Level:  f.Level,
+		Stream: f.Stream,
+		Labels: labels,
+		Fields: f.Fields,
+		From:   from,
+		To:     to,
+		Limit:  limit,
+	}
+	if services := splitServices(f.Service); len(services) == 1 {
+		lq.ServiceID = services[0]
+	} else {
+		lq.ServiceIDs = services
+	}
+	return lq, nil
+}
+
+// queryLogWindow returns the ?from= and ?to= (RFC 3339) bounds of a log
+// query, from falling back to the start of ?range=; both may be nil.
+func queryLogWindow(r *http.Request) (from, to *time.Time, err error) {
+	from = queryRangeStart(r)
+	for _, b := range []struct {
+		key string
+		dst **time.Time
+	}{{"from", &from}, {"to", &to}} {
+		v := strings.TrimSpace(r.URL.Query().Get(b.key))
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s must be an RFC 3339 time", b.key)
+		}
+		t = t.UTC()
+		*b.dst = &t
+	}
+	return from, to, nil
+}
+
+// queryWindow returns the window of ?from= and ?to= (RFC 3339) when given,
+// and otherwise the ?range= up to now.
+func queryWindow(r *http.Request) (from, to time.Time, err error) {
+	q := r.URL.Query()
+	to = time.Now().UTC()
+	if v := q.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, errors.New("to must be an RFC 3339 time")
+		}
+		to = to.UTC()
+	}
+	from = to.Add(-parseRange(q.Get("range")))
+	if v := q.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, errors.New("from must be an RFC 3339 time")
+		}
+		from = from.UTC()
+	}
+	if !from.Before(to) {
+		return from, to, errors.New("from must be before to")
+	}
+	return from, to, nil
+}
+
+func writeAPIError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(api.Error{Error: msg})
+}
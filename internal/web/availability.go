@@ -0,0 +1,196 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/models"
+)
+
+// availabilityWindows are the windows availability is reported over.
+var availabilityWindows = []struct {
+	label string
+	rng   time.Duration
+}{{"24h", 24 * time.Hour}, {"7d", 7 * 24 * time.Hour}, {"30d", 30 * 24 * time.Hour}}
+
+// serviceHistory is what availability is computed from: the status changes
+// and outages of a service's containers, by container.
+type serviceHistory struct {
+	changes map[string][]models.ContainerStatusChange
+	outages map[string][]models.ContainerOutage
+}
+
+// up reports whether a container of the service ran at t without an
+// unavailable alert firing for it.
+func (h serviceHistory) up(t time.Time) bool {
+	for id, changes := range h.changes {
+		i := sort.Search(len(changes), func(i int) bool { return changes[i].TS.After(t) })
+		if i == 0 || changes[i-1].Status != "running" {
+			continue
+		}
+		if !slices.ContainsFunc(h.outages[id], func(o models.ContainerOutage) bool {
+			return !o.From.After(t) && (o.To == nil || o.To.After(t))
+		}) {
+			return true
+		}
+	}
+	return false
+}
+
+// window computes the availability between from and to. The time before
+// the first recorded status of any container is not observed.
+func (h serviceHistory) window(label string, from, to time.Time) api.AvailabilityWindow {
+	out := api.AvailabilityWindow{Range: label, From: from, To: to}
+	start := to
+	bounds := []time.Time{from, to}
+	for _, changes := range h.changes {
+		start = minTime(start, changes[0].TS)
+		for _, c := range changes {
+			bounds = append(bounds, c.TS)
+		}
+	}
+	for _, outages := range h.outages {
+		for _, o := range outages {
+			bounds = append(bounds, o.From)
+			if o.To != nil {
+				bounds = append(bounds, *o.To)
+			}
+		}
+	}
+	start = maxTime(start, from)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i].Before(bounds[j]) })
+	var observed, down time.Duration
+	wasUp := true
+	for i := 0; i+1 < len(bounds); i++ {
+		a, b := maxTime(bounds[i], start), minTime(bounds[i+1], to)
+		if !a.Before(b) {
+			continue
+		}
+		observed += b.Sub(a)
+		up := h.up(a)
+		if !up {
+			down += b.Sub(a)
+			if wasUp {
+				out.Incidents++
+			}
+		}
+		wasUp = up
+	}
+	out.ObservedSec, out.DowntimeSec = int64(observed.Seconds()), int64(down.Seconds())
+	if observed > 0 {
+		pct := 100 * float64(observed-down) / float64(observed)
+		out.UptimePct = &pct
+	}
+	return out
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// availability returns the availability of the service serviceID, or of
+// every service with a recorded status for an empty one, by service ID.
+func (s *Server) availability(ctx context.Context, serviceID string, now time.Time) ([]api.Availability, error) {
+	from := now.Add(-availabilityWindows[len(availabilityWindows)-1].rng)
+	changes, err := s.repo.ContainerStatusHistory(ctx, serviceID, from, now)
+	if err != nil {
+		return nil, err
+	}
+	outages, err := s.repo.ContainerOutages(ctx, serviceID, from, now)
+	if err != nil {
+		return nil, err
+	}
+	services := map[string]serviceHistory{}
+	history := func(id string) serviceHistory {
+		h, ok := services[id]
+		if !ok {
+			h = serviceHistory{changes: map[string][]models.ContainerStatusChange{}, outages: map[string][]models.ContainerOutage{}}
+			services[id] = h
+		}
+		return h
+	}
+	for _, c := range changes {
+		h := history(c.ServiceID)
+		h.changes[c.ContainerID] = append(h.changes[c.ContainerID], c)
+	}
+	for _, o := range outages {
+		h := history(o.ServiceID)
+		h.outages[o.ContainerID] = append(h.outages[o.ContainerID], o)
+	}
+	if serviceID != "" {
+		history(serviceID)
+	}
+	out := make([]api.Availability, 0, len(services))
+	for id, h := range services {
+		a := api.Availability{ServiceID: id}
+		for _, w := range availabilityWindows {
+			a.Windows = append(a.Windows, h.window(w.label, now.Add(-w.rng), now))
+		}
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ServiceID < out[j].ServiceID })
+	return out, nil
+}
+
+// handleV1Availability serves GET /api/v1/availability, the availability
+// of every service.
+func (s *Server) handleV1Availability(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	items, err := s.availability(r.Context(), "", time.Now().UTC())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.Availabilities{Items: items})
+}
+
+// handleV1ServiceAvailability serves GET /api/v1/services/{id}/availability.
+func (s *Server) handleV1ServiceAvailability(w http.ResponseWriter, r *http.Request, id string) {
+	_, _, err := s.repo.ServiceDetail(r.Context(), id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeAPIError(w, http.StatusNotFound, "service not found")
+		return
+	case err != nil:
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	items, err := s.availability(r.Context(), id, time.Now().UTC())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, items[0])
+}
+
+// handleServiceAvailabilityFragment shows the availability of a service on
+// its containers' pages.
+func (s *Server) handleServiceAvailabilityFragment(w http.ResponseWriter, r *http.Request, id string) {
+	data := map[string]any{"serviceID": id}
+	items, err := s.availability(r.Context(), id, time.Now().UTC())
+	if err != nil {
+		data["error"] = err.Error()
+	} else {
+		data["windows"] = items[0].Windows
+	}
+	_ = s.tpl.ExecuteTemplate(w, "fragment_availability.html", data)
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/models"
+)
+
+func TestServiceHistoryWindow(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(h float64) time.Time { return t0.Add(time.Duration(h * float64(time.Hour))) }
+	change := func(id, status string, h float64) models.ContainerStatusChange {
+		return models.ContainerStatusChange{ContainerID: id, ServiceID: "web", Status: status, TS: at(h)}
+	}
+	end := at(5)
+	h := serviceHistory{
+		// Replica a runs from the start and exits at 2h; b covers 1h to 3h,
+		// then a runs again from 4h but was unreachable from 4.5h.
+		changes: map[string][]models.ContainerStatusChange{
+			"a": {change("a", "running", 0), change("a", "exited", 2), change("a", "running", 4)},
+			"b": {change("b", "running", 1), change("b", "missing", 3)},
+		},
+		outages: map[string][]models.ContainerOutage{
+			"a": {{ContainerID: "a", ServiceID: "web", From: at(4.5), To: &end}},
+		},
+	}
+	w := h.window("6h", at(-1), at(6))
+	// Observed from 0h, down 3h-4h and 4.5h-5h.
+	if w.ObservedSec != 6*3600 || w.DowntimeSec != 5400 || w.Incidents != 2 || w.UptimePct == nil || *w.UptimePct != 75 {
+		t.Fatalf("window = %+v", w)
+	}
+	if w := h.window("1h", at(1), at(2)); w.DowntimeSec != 0 || *w.UptimePct != 100 {
+		t.Fatalf("window without downtime = %+v", w)
+	}
+	if w := (serviceHistory{}).window("24h", at(0), at(24)); w.UptimePct != nil || w.ObservedSec != 0 {
+		t.Fatalf("window without history = %+v", w)
+	}
+}
+
+func TestServiceAvailability(t *testing.T) {
+	s, repo := newTestServer(t, Options{})
+	ctx := context.Background()
+	svc := models.Service{ID: "web", Name: "web", Image: "img", LabelsJSON: "{}", Status: "running"}
+	if err := repo.UpsertServiceAndContainer(ctx, svc, models.Container{ID: "web1", ServiceID: "web", Name: "web", Status: "running"}); err != nil {
+		t.Fatalf("store container: %v", err)
+	}
+	if err := repo.SetContainerState(ctx, "web1", "exited", ""); err != nil {
+		t.Fatalf("set state: %v", err)
+	}
+	// The container ran from 10h ago and exited 4h ago.
+	now := time.Now().UTC().Truncate(time.Second)
+	for status, ago := range map[string]time.Duration{"running": 10 * time.Hour, "exited": 4 * time.Hour} {
+		if _, err := repo.DB().Exec(`UPDATE container_status_changes SET ts=? WHERE container_id='web1' AND status=?`, now.Add(-ago), status); err != nil {
+			t.Fatalf("date %s: %v", status, err)
+		}
+	}
+	h := s.Routes()
+
+	items, err := s.availability(ctx, "web", now)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("availability = %+v, %v", items, err)
+	}
+	if w := items[0].Windows[0]; w.ObservedSec != 10*3600 || w.DowntimeSec != 4*3600 || w.UptimePct == nil || *w.UptimePct != 60 || w.Incidents != 1 {
+		t.Fatalf("24h window = %+v, want 6h up of 10h observed", w)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/services/web/availability", nil))
+	var out api.Availability
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("availability = %d %s", rec.Code, rec.Body)
+	}
+	if len(out.Windows) != 3 || out.Windows[2].Range != "30d" || out.Windows[0].Incidents != 1 {
+		t.Fatalf("windows = %+v", out.Windows)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/services/nope/availability", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown service = %d, want 404", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/availability", nil))
+	var all api.Availabilities
+	if err := json.Unmarshal(rec.Body.Bytes(), &all); err != nil || len(all.Items) != 1 || all.Items[0].ServiceID != "web" {
+		t.Fatalf("availabilities = %s", rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fragments/service/web/availability", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "30d") || !strings.Contains(body, "%") {
+		t.Fatalf("availability fragment:\n%s", body)
+	}
+}
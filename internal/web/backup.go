@@ -0,0 +1,53 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"dashi/internal/db"
+)
+
+// handleV1Backup streams a consistent database snapshot as a download.
+func (s *Server) handleV1Backup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.opts.Backup == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, "backups are not configured")
+		return
+	}
+	tmp, err := os.MkdirTemp("", "dashi-backup-")
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer os.RemoveAll(tmp)
+
+	path, err := s.opts.Backup.Snapshot(r.Context(), tmp)
+	if errors.Is(err, db.ErrUnsupported) {
+		writeAPIError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		s.log.Error("backup snapshot", "err", err)
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filepath.Base(path)+`"`)
+	http.ServeContent(w, r, filepath.Base(path), st.ModTime(), f)
+}
@@ -0,0 +1,185 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/checks"
+	"dashi/internal/models"
+)
+
+func (s *Server) handleV1Checks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		statuses, err := s.repo.CheckStatuses(r.Context(), time.Now().Add(-24*time.Hour))
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, api.Checks{Items: api.ChecksFrom(statuses)})
+	case http.MethodPost:
+		c, ok := decodeCheck(w, r)
+		if !ok {
+			return
+		}
+		id, err := s.repo.CreateCheck(r.Context(), c)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.ID = id
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, api.CheckFrom(models.CheckStatus{Check: c}))
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleV1Check(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, apiV1Prefix+"/checks/"), 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, "check not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		status, err := s.checkStatus(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeAPIError(w, http.StatusNotFound, "check not found")
+			return
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		rng := parseRange(r.URL.Query().Get("range"))
+		results, err := s.repo.CheckResults(r.Context(), id, time.Now().Add(-rng), 4096)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, api.CheckHistory{Check: api.CheckFrom(status), Range: rng.String(), Items: api.CheckResultsFrom(results)})
+	case http.MethodPut:
+		c, ok := decodeCheck(w, r)
+		if !ok {
+			return
+		}
+		c.ID = id
+		err := s.repo.UpdateCheck(r.Context(), c)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeAPIError(w, http.StatusNotFound, "check not found")
+			return
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, api.CheckFrom(models.CheckStatus{Check: c}))
+	case http.MethodDelete:
+		err := s.repo.DeleteCheck(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeAPIError(w, http.StatusNotFound, "check not found")
+			return
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// decodeCheck reads and validates a check definition, answering 400 itself
+// when it is invalid.
+func decodeCheck(w http.ResponseWriter, r *http.Request) (models.Check, bool) {
+	var in api.Check
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid check: "+err.Error())
+		return models.Check{}, false
+	}
+	c := models.Check{Name: in.Name, URL: in.URL, Method: in.Method, ExpectStatus: in.ExpectStatus, ExpectBody: in.ExpectBody,
+		IntervalSec: in.IntervalSec, TimeoutSec: in.TimeoutSec, Enabled: in.Enabled == nil || *in.Enabled}
+	if err := checks.Normalize(&c); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid check: "+err.Error())
+		return models.Check{}, false
+	}
+	return c, true
+}
+
+// checkStatus returns one check with its 24 hour summary, sql.ErrNoRows for
+// unknown checks.
+func (s *Server) checkStatus(ctx context.Context, id int64) (models.CheckStatus, error) {
+	statuses, err := s.repo.CheckStatuses(ctx, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		return models.CheckStatus{}, err
+	}
+	for _, st := range statuses {
+		if st.ID == id {
+			return st, nil
+		}
+	}
+	return models.CheckStatus{}, sql.ErrNoRows
+}
+
+func (s *Server) handleUptime(w http.ResponseWriter, r *http.Request) {
+	_ = s.tpl.ExecuteTemplate(w, "uptime.html", nil)
+}
+
+// handleChecksFragment renders the check table. POST adds a check from the
+// uptime page form first; validation errors are shown above the table.
+func (s *Server) handleChecksFragment(w http.ResponseWriter, r *http.Request) {
+	data := map[string]any{}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		status, _ := strconv.Atoi(r.FormValue("expect_status"))
+		interval, _ := strconv.Atoi(r.FormValue("interval_sec"))
+		timeout, _ := strconv.Atoi(r.FormValue("timeout_sec"))
+		c := models.Check{Name: r.FormValue("name"), URL: r.FormValue("url"), Method: r.FormValue("method"), ExpectStatus: status,
+			ExpectBody: r.FormValue("expect_body"), IntervalSec: interval, TimeoutSec: timeout, Enabled: true}
+		if err := checks.Normalize(&c); err != nil {
+			data["error"] = err.Error()
+		} else if _, err := s.repo.CreateCheck(r.Context(), c); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.renderChecks(w, r, data)
+}
+
+func (s *Server) handleChecksDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err := s.repo.DeleteCheck(r.Context(), id); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.renderChecks(w, r, map[string]any{})
+}
+
+func (s *Server) renderChecks(w http.ResponseWriter, r *http.Request, data map[string]any) {
+	statuses, err := s.repo.CheckStatuses(r.Context(), time.Now().Add(-24*time.Hour))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data["checks"] = statuses
+	_ = s.tpl.ExecuteTemplate(w, "fragment_checks.html", data)
+}
@@ -0,0 +1,181 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/docker"
+	"dashi/internal/models"
+)
+
+const (
+	// compareMaxSeries and compareMaxPoints bound a comparison's response.
+	compareMaxSeries = 20
+	compareMaxPoints = 1000
+)
+
+// compareAveraged are the metrics a service's series averages over its
+// containers; the others are summed, as a service's memory is that of all
+// its replicas.
+var compareAveraged = map[string]bool{"mem_pct": true}
+
+// errUnknownTarget reports a container or service not known to dashi.
+var errUnknownTarget = errors.New("unknown")
+
+// comparedTarget is a series of a comparison and the containers it draws.
+type comparedTarget struct {
+	series     api.ComparedSeries
+	containers []models.Container
+}
+
+// compareTargets resolves ?containers= and ?services= into their series,
+// in the order asked for.
+func (s *Server) compareTargets(ctx context.Context, containerIDs, serviceIDs []string) ([]comparedTarget, error) {
+	var out []comparedTarget
+	for _, id := range containerIDs {
+		c, err := s.repo.Container(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w container %q", errUnknownTarget, id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := c.Name
+		if c.Host != "" && c.Host != docker.LocalHost {
+			name += "@" + c.Host
+		}
+		out = append(out, comparedTarget{series: api.ComparedSeries{Type: "container", ID: c.ID, Name: name}, containers: []models.Container{c}})
+	}
+	for _, id := range serviceIDs {
+		containers, err := s.repo.ServiceContainers(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if len(containers) == 0 {
+			return nil, fmt.Errorf("%w service %q", errUnknownTarget, id)
+		}
+		out = append(out, comparedTarget{series: api.ComparedSeries{Type: "service", ID: id, Name: id}, containers: containers})
+	}
+	return out, nil
+}
+
+// resample averages the field of metrics into n buckets of step from start.
+func resample(metrics []models.ContainerMetric, field func(models.ContainerMetric) float64, start time.Time, step time.Duration, n int) (sums []float64, counts []int) {
+	sums, counts = make([]float64, n), make([]int, n)
+	for _, m := range metrics {
+		i := int(m.TS.Sub(start) / step)
+		if m.TS.Before(start) || i >= n {
+			continue
+		}
+		sums[i] += field(m)
+		counts[i]++
+	}
+	return sums, counts
+}
+
+// handleV1Compare serves GET /api/v1/metrics/compare: one metric of several
+// containers and services on common timestamps, for overlay charts.
+func (s *Server) handleV1Compare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	q := r.URL.Query()
+	metric := q.Get("metric")
+	field, ok := grafanaContainerFields[metric]
+	if !ok {
+		names := make([]string, 0, len(grafanaContainerFields))
+		for name := range grafanaContainerFields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		writeAPIError(w, http.StatusBadRequest, "metric must be one of "+strings.Join(names, ", "))
+		return
+	}
+	containerIDs, serviceIDs := splitServices(q.Get("containers")), splitServices(q.Get("services"))
+	switch n := len(containerIDs) + len(serviceIDs); {
+	case n == 0:
+		writeAPIError(w, http.StatusBadRequest, "containers or services is required")
+		return
+	case n > compareMaxSeries:
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("at most %d containers and services can be compared", compareMaxSeries))
+		return
+	}
+	from, to, err := queryWindow(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	res, ok := queryResolution(r, to.Sub(from))
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, "invalid resolution")
+		return
+	}
+	step := histogramStep(to.Sub(from), 200)
+	if v := q.Get("step"); v != "" {
+		if step, err = time.ParseDuration(v); err != nil || step <= 0 {
+			writeAPIError(w, http.StatusBadRequest, "invalid step")
+			return
+		}
+	}
+	// Buckets narrower than the samples would mostly be empty.
+	step = max(step, res)
+	start := from.Truncate(step)
+	n := int((to.Sub(start) + step - 1) / step)
+	if n > compareMaxPoints {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("step too small: at most %d points per series", compareMaxPoints))
+		return
+	}
+	targets, err := s.compareTargets(r.Context(), containerIDs, serviceIDs)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errUnknownTarget) {
+			status = http.StatusBadRequest
+		}
+		writeAPIError(w, status, err.Error())
+		return
+	}
+	out := api.MetricComparison{Metric: metric, From: from, To: to, StepSec: int64(step.Seconds()), Resolution: resolutionName(res),
+		Timestamps: make([]time.Time, n), Series: make([]api.ComparedSeries, 0, len(targets))}
+	for i := range out.Timestamps {
+		out.Timestamps[i] = start.Add(time.Duration(i) * step)
+	}
+	for _, t := range targets {
+		// A service's bucket adds up, or averages, its containers' averages.
+		total, present := make([]float64, n), make([]int, n)
+		for _, c := range t.containers {
+			metrics, err := s.containerMetrics(r.Context(), c.ID, start, res, grafanaMaxRows)
+			if err != nil {
+				writeAPIError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			sums, counts := resample(metrics, field, start, step, n)
+			for i := range sums {
+				if counts[i] > 0 {
+					total[i] += sums[i] / float64(counts[i])
+					present[i]++
+				}
+			}
+		}
+		t.series.Values = make([]*float64, n)
+		for i := range total {
+			if present[i] == 0 {
+				continue
+			}
+			v := total[i]
+			if compareAveraged[metric] {
+				v /= float64(present[i])
+			}
+			t.series.Values[i] = &v
+		}
+		out.Series = append(out.Series, t.series)
+	}
+	writeJSON(w, out)
+}
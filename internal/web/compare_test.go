@@ -0,0 +1,91 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/models"
+)
+
+func TestCompareMetrics(t *testing.T) {
+	s, repo := newTestServer(t, Options{})
+	ctx := context.Background()
+	for _, c := range []models.Container{{ID: "api1", ServiceID: "api", Name: "api-1"}, {ID: "api2", ServiceID: "api", Name: "api-2"}, {ID: "db1", ServiceID: "db", Name: "db"}} {
+		svc := models.Service{ID: c.ServiceID, Name: c.ServiceID, Image: "img", LabelsJSON: "{}", Status: "running"}
+		c.Status = "running"
+		if err := repo.UpsertServiceAndContainer(ctx, svc, c); err != nil {
+			t.Fatalf("store container: %v", err)
+		}
+	}
+	// api runs two replicas for half an hour; db only reported for ten
+	// minutes.
+	base := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Hour)
+	for m := 0; m < 30; m++ {
+		ts := base.Add(time.Duration(m) * time.Minute)
+		samples := []models.ContainerMetric{
+			{TS: ts, ContainerID: "api1", MemUsedBytes: 100 << 20},
+			{TS: ts, ContainerID: "api2", MemUsedBytes: 50 << 20},
+		}
+		if m < 10 {
+			samples = append(samples, models.ContainerMetric{TS: ts, ContainerID: "db1", MemUsedBytes: 200 << 20})
+		}
+		for _, s := range samples {
+			if err := repo.InsertContainerMetric(ctx, s); err != nil {
+				t.Fatalf("insert container metric: %v", err)
+			}
+		}
+	}
+	h := s.Routes()
+	compare := func(query string) (api.MetricComparison, *httptest.ResponseRecorder) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/metrics/compare?"+query, nil))
+		var out api.MetricComparison
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		return out, rec
+	}
+
+	window := "&from=" + base.Format(time.RFC3339) + "&to=" + base.Add(time.Hour).Format(time.RFC3339)
+	out, rec := compare("metric=mem_used_bytes&services=api&containers=db1&step=10m" + window)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("compare = %d %s", rec.Code, rec.Body)
+	}
+	if len(out.Timestamps) != 6 || !out.Timestamps[0].Equal(base) || out.StepSec != 600 || len(out.Series) != 2 {
+		t.Fatalf("comparison = %+v, want 2 series over 6 buckets of 10m", out)
+	}
+	db1, svc := out.Series[0], out.Series[1]
+	if db1.Type != "container" || db1.Name != "db" || svc.Type != "service" || svc.ID != "api" {
+		t.Fatalf("series = %+v, %+v, want db1 then api", db1, svc)
+	}
+	for i, v := range svc.Values {
+		switch {
+		case i < 3 && (v == nil || *v != 150<<20):
+			t.Errorf("api bucket %d = %v, want both replicas summed", i, v)
+		case i >= 3 && v != nil:
+			t.Errorf("api bucket %d = %v, want null", i, *v)
+		}
+	}
+	if v := db1.Values[0]; v == nil || *v != 200<<20 || db1.Values[1] != nil {
+		t.Errorf("db values = %v, want one bucket", db1.Values)
+	}
+
+	out, _ = compare("metric=mem_used_bytes&services=api&step=1m" + window)
+	if len(out.Series) != 1 || len(out.Series[0].Values) != 60 {
+		t.Fatalf("1m comparison = %+v, want 60 points", out)
+	}
+
+	for _, query := range []string{
+		"metric=bogus&services=api",
+		"metric=cpu_pct",
+		"metric=cpu_pct&services=nope",
+		"metric=cpu_pct&services=api&step=1s&range=24h",
+	} {
+		if _, rec := compare(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", query, rec.Code)
+		}
+	}
+}
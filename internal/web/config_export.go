@@ -0,0 +1,179 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/db"
+	"dashi/internal/models"
+	"dashi/internal/settings"
+)
+
+// handleV1Config exports (GET) or imports (PUT/POST) rules, settings and
+// preferences as one JSON document, to move a setup between instances.
+func (s *Server) handleV1Config(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.exportConfig(w, r)
+	case http.MethodPut, http.MethodPost:
+		s.importConfig(w, r)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) exportConfig(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rules, err := s.repo.ListRules(ctx)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	values, err := s.opts.Settings.List(ctx, "")
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	prefs, err := s.repo.ListPreferences(ctx)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	now := time.Now().UTC()
+	doc := api.ConfigDocument{
+		Version:     api.ConfigVersion,
+		ExportedAt:  now,
+		Rules:       make([]api.AlertRule, 0, len(rules)),
+		Settings:    map[string]json.RawMessage{},
+		Preferences: map[string]json.RawMessage{},
+	}
+	for _, rule := range rules {
+		doc.Rules = append(doc.Rules, api.AlertRuleFrom(rule))
+	}
+	// Secrets are write-only over the API: List decrypts them, so they
+	// never leave in an export.
+	for k, v := range values {
+		if !settings.IsSecret(k) {
+			doc.Settings[k] = v
+		}
+	}
+	for owner, v := range prefs {
+		doc.Preferences[owner] = json.RawMessage(v)
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="dashi-config-`+now.Format("20060102-150405")+`.json"`)
+	writeJSON(w, doc)
+}
+
+func (s *Server) importConfig(w http.ResponseWriter, r *http.Request) {
+	var doc api.ConfigDocument
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4<<20)).Decode(&doc); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid config document: "+err.Error())
+		return
+	}
+	res, err := s.ImportConfig(r.Context(), doc)
+	if errors.Is(err, ErrInvalidConfig) {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, res)
+}
+
+// ErrInvalidConfig wraps the validation failures of ImportConfig.
+var ErrInvalidConfig = errors.New("invalid config document")
+
+// ImportConfig validates doc and applies it like PUT /api/v1/config, e.g.
+// for the configuration file read on a reload.
+func (s *Server) ImportConfig(ctx context.Context, doc api.ConfigDocument) (api.ConfigImportResult, error) {
+	if doc.Version != api.ConfigVersion {
+		return api.ConfigImportResult{}, fmt.Errorf("%w: unsupported config version %d", ErrInvalidConfig, doc.Version)
+	}
+	in := db.ConfigImport{Settings: map[string]string{}, Preferences: map[string]string{}}
+	seen := map[string]bool{}
+	for _, rule := range doc.Rules {
+		m := rule.Model()
+		if err := validateRule(m); err != nil {
+			return api.ConfigImportResult{}, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+		if seen[m.Name] {
+			return api.ConfigImportResult{}, fmt.Errorf("%w: duplicate rule name %s", ErrInvalidConfig, m.Name)
+		}
+		seen[m.Name] = true
+		in.Rules = append(in.Rules, m)
+	}
+	for k, v := range doc.Settings {
+		if err := s.opts.Settings.Check(k, v); err != nil {
+			return api.ConfigImportResult{}, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+		stored, err := s.opts.Settings.Seal(k, v)
+		if err != nil {
+			return api.ConfigImportResult{}, err
+		}
+		in.Settings[k] = stored
+	}
+	for owner, v := range doc.Preferences {
+		if owner == "" || !json.Valid(v) {
+			return api.ConfigImportResult{}, fmt.Errorf("%w: invalid preferences for %s", ErrInvalidConfig, owner)
+		}
+		in.Preferences[owner] = string(v)
+	}
+	if err := s.repo.ImportConfig(ctx, in); err != nil {
+		return api.ConfigImportResult{}, err
+	}
+	for k := range in.Settings {
+		s.opts.Settings.Notify(ctx, k)
+	}
+	s.log.Info("configuration imported", "rules", len(in.Rules), "settings", len(in.Settings), "preferences", len(in.Preferences))
+	return api.ConfigImportResult{Rules: len(in.Rules), Settings: len(in.Settings), Preferences: len(in.Preferences)}, nil
+}
+
+// handleV1Reload applies the configuration file again and reloads the
+// settings, as SIGHUP does.
+func (s *Server) handleV1Reload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.opts.Reload == nil {
+		writeAPIError(w, http.StatusNotImplemented, "reload is not available")
+		return
+	}
+	if err := s.opts.Reload(r.Context()); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.ReloadResult{Reloaded: true})
+}
+
+func validateRule(r models.AlertRule) error {
+	if strings.TrimSpace(r.Name) == "" || r.MetricKey == "" {
+		return fmt.Errorf("rule needs a name and metric_key")
+	}
+	switch r.TargetType {
+	case "host", "container", "volume", "check", "heartbeat", "agent", "scrape", "pool", "dashi":
+	default:
+		return fmt.Errorf("rule %s: target_type must be host, container, volume, check, heartbeat, agent, scrape, pool or dashi", r.Name)
+	}
+	switch r.Operator {
+	case ">", ">=", "<", "<=", "==":
+	default:
+		return fmt.Errorf("rule %s: unsupported operator %q", r.Name, r.Operator)
+	}
+	if r.ForSeconds < 0 || r.CooldownSeconds < 0 {
+		return fmt.Errorf("rule %s: durations must not be negative", r.Name)
+	}
+	if _, err := db.ParseLabelSelector(r.LabelSelector); err != nil {
+		return fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dashi/internal/api"
+	"dashi/internal/settings"
+)
+
+func TestImportConfigAndReload(t *testing.T) {
+	reloads := 0
+	s, repo := newTestServer(t, Options{Reload: func(context.Context) error {
+		reloads++
+		return nil
+	}})
+	st := settings.NewStore(repo, s.log)
+	var notified []string
+	st.OnChange("telegram", func(_ context.Context, key string) { notified = append(notified, key) })
+	s.opts.Settings = st
+	ctx := context.Background()
+
+	_, err := s.ImportConfig(ctx, api.ConfigDocument{Version: api.ConfigVersion + 1})
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("wrong version err = %v, want ErrInvalidConfig", err)
+	}
+	res, err := s.ImportConfig(ctx, api.ConfigDocument{
+		Version:  api.ConfigVersion,
+		Settings: map[string]json.RawMessage{"telegram.chat_id": json.RawMessage(`"42"`)},
+	})
+	if err != nil || res.Settings != 1 {
+		t.Fatalf("import = %+v, %v", res, err)
+	}
+	if len(notified) != 1 || notified[0] != "telegram.chat_id" {
+		t.Fatalf("hooks fired for %v", notified)
+	}
+
+	h := s.Routes()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/reload", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET reload status = %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/reload", nil))
+	var reloaded api.ReloadResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &reloaded); err != nil || rec.Code != http.StatusOK || !reloaded.Reloaded || reloads != 1 {
+		t.Fatalf("reload status = %d %s, reloads = %d", rec.Code, rec.Body, reloads)
+	}
+}
+
+func TestExportConfigLeavesOutSecrets(t *testing.T) {
+	s, repo := newTestServer(t, Options{})
+	st := settings.NewStore(repo, s.log)
+	s.opts.Settings = st
+	ctx := context.Background()
+	for k, v := range map[string]string{"telegram.token": "123:do-not-export", "telegram.chat_id": "42", "smtp.password": "hunter2"} {
+		if err := st.Set(ctx, k, v); err != nil {
+			t.Fatalf("set %s: %v", k, err)
+		}
+	}
+	h := s.Routes()
+
+	// The flag that used to include them is ignored.
+	for _, path := range []string{"/api/v1/admin/config", "/api/v1/admin/config?include_secrets=1"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		body := rec.Body.String()
+		if rec.Code != http.StatusOK || strings.Contains(body, "do-not-export") || strings.Contains(body, "hunter2") {
+			t.Fatalf("GET %s = %d %s", path, rec.Code, body)
+		}
+		var doc api.ConfigDocument
+		if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if _, ok := doc.Settings["telegram.token"]; ok || string(doc.Settings["telegram.chat_id"]) != `"42"` {
+			t.Fatalf("settings = %s", body)
+		}
+	}
+}
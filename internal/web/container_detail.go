@@ -0,0 +1,156 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/db"
+	"dashi/internal/models"
+	"dashi/internal/rollup"
+	"dashi/internal/scrape"
+)
+
+// containerCharts are the charts of the container page, by the
+// grafanaContainerFields they draw.
+var containerCharts = []struct {
+	title  string
+	fields []string
+}{
+	{"CPU %", []string{"cpu_pct"}},
+	{"Memory (bytes)", []string{"mem_used_bytes"}},
+	{"Network (bytes/s)", []string{"net_rx_rate", "net_tx_rate"}},
+	{"Block I/O (bytes/s)", []string{"blk_read_rate", "blk_write_rate"}},
+}
+
+// containerMetrics returns a container's samples since from at resolution
+// res, raw samples for 0 and rollups otherwise.
+func (s *Server) containerMetrics(ctx context.Context, id string, from time.Time, res time.Duration, limit int) ([]models.ContainerMetric, error) {
+	if res == 0 {
+		return s.repo.RecentContainerMetrics(ctx, id, from, limit)
+	}
+	rollups, err := s.repo.ContainerMetricRollups(ctx, id, res, from, limit)
+	metrics := make([]models.ContainerMetric, 0, len(rollups))
+	for _, m := range rollups {
+		metrics = append(metrics, m.ContainerMetric)
+	}
+	return metrics, err
+}
+
+// handleContainerPage shows one container: its state, charts, alerts and
+// logs, the latter three loaded as fragments.
+func (s *Server) handleContainerPage(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/containers/")
+	c, err := s.repo.Container(r.Context(), id)
+	switch {
+	case id == "" || strings.Contains(id, "/") || errors.Is(err, sql.ErrNoRows):
+		http.NotFound(w, r)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	svc, _, err := s.repo.ServiceDetail(r.Context(), c.ServiceID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = s.tpl.ExecuteTemplate(w, "container.html", map[string]any{
+		"container":    c,
+		"service":      svc,
+		"endpointHost": s.endpointHost(c.Host, r),
+		"metrics":      !s.opts.MetricsDisabled,
+		"alerts":       !s.opts.AlertsDisabled,
+		"logs":         !s.opts.LogsDisabled,
+	})
+}
+
+// handleContainerSubroutes serves /fragments/container/{id}/charts,
+// /fragments/container/{id}/alerts and /fragments/container/{id}/logs.
+func (s *Server) handleContainerSubroutes(w http.ResponseWriter, r *http.Request) {
+	id, part, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/fragments/container/"), "/")
+	c, err := s.repo.Container(r.Context(), id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.NotFound(w, r)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	switch part {
+	case "charts":
+		disabled(s.opts.MetricsDisabled, "metric collection", func(w http.ResponseWriter, r *http.Request) { s.containerChartsFragment(w, r, c) })(w, r)
+	case "alerts":
+		disabled(s.opts.AlertsDisabled, "alerting", func(w http.ResponseWriter, r *http.Request) { s.containerAlertsFragment(w, r, c) })(w, r)
+	case "logs":
+		disabled(s.opts.LogsDisabled, "log ingestion", func(w http.ResponseWriter, r *http.Request) { s.containerLogsFragment(w, r, c) })(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) containerChartsFragment(w http.ResponseWriter, r *http.Request, c models.Container) {
+	param := r.URL.Query().Get("range")
+	if param == "" {
+		param = "1h"
+	}
+	rng := parseRange(param)
+	now := time.Now().UTC()
+	data := map[string]any{"container": c, "range": param, "ranges": []string{"1h", "6h", "24h"}}
+	metrics, err := s.containerMetrics(r.Context(), c.ID, now.Add(-rng), rollup.PickResolution(rng), 4096)
+	if err != nil {
+		data["error"] = err.Error()
+		_ = s.tpl.ExecuteTemplate(w, "fragment_container_charts.html", data)
+		return
+	}
+	charts := make([]scrapeChart, 0, len(containerCharts))
+	for _, cc := range containerCharts {
+		series := make([]api.ScrapeSeries, 0, len(cc.fields))
+		for _, name := range cc.fields {
+			field := grafanaContainerFields[name]
+			ser := api.ScrapeSeries{Name: name, Points: make([]api.ScrapePoint, 0, len(metrics))}
+			for _, m := range metrics {
+				ser.Points = append(ser.Points, api.ScrapePoint{TS: m.TS, Value: field(m)})
+			}
+			series = append(series, ser)
+		}
+		chart := scrapeChart{Panel: scrape.Panel{Title: cc.title}}
+		chart.Lines, chart.Min, chart.Max = scrapeLines(series, now.Add(-rng), now, 100)
+		charts = append(charts, chart)
+	}
+	data["charts"] = charts
+	if len(metrics) > 0 {
+		data["latest"] = metrics[len(metrics)-1]
+	}
+	_ = s.tpl.ExecuteTemplate(w, "fragment_container_charts.html", data)
+}
+
+func (s *Server) containerAlertsFragment(w http.ResponseWriter, r *http.Request, c models.Container) {
+	since := time.Now().Add(-7 * 24 * time.Hour)
+	data := map[string]any{"container": c}
+	alerts, err := s.repo.ContainerAlerts(r.Context(), c.ID, c.ServiceID, since, false, 50)
+	if err == nil {
+		data["alerts"] = alerts
+		data["restarts"], err = s.repo.ContainerAlerts(r.Context(), c.ID, c.ServiceID, since, true, 50)
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	_ = s.tpl.ExecuteTemplate(w, "fragment_container_alerts.html", data)
+}
+
+func (s *Server) containerLogsFragment(w http.ResponseWriter, r *http.Request, c models.Container) {
+	q := r.URL.Query()
+	entries, err := s.repo.QueryLogs(r.Context(), db.LogQuery{ServiceID: c.ServiceID, ContainerID: c.ID, Query: q.Get("q"), Level: q.Get("level"),
+		From: queryRangeStart(r), Limit: 100})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = s.tpl.ExecuteTemplate(w, "fragment_logs.html", map[string]any{"entries": entries, "serviceID": c.ServiceID, "title": "Logs of " + c.Name})
+}
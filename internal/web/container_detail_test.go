@@ -0,0 +1,89 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestContainerPage(t *testing.T) {
+	s, repo := newTestServer(t, Options{})
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	svc := models.Service{ID: "web", Name: "web", Image: "nginx:1.27", LabelsJSON: "{}", Status: "running"}
+	for _, id := range []string{"c1", "c2"} {
+		c := models.Container{ID: id, ServiceID: "web", Name: "web-" + id, Status: "running", Health: "healthy", RestartCount: 2}
+		if err := repo.UpsertServiceAndContainer(ctx, svc, c); err != nil {
+			t.Fatalf("store container: %v", err)
+		}
+	}
+	for m := 3; m >= 1; m-- {
+		if err := repo.InsertContainerMetric(ctx, models.ContainerMetric{TS: now.Add(-time.Duration(m) * time.Minute), ContainerID: "c1",
+			CPUPct: float64(10 * m), MemUsedBytes: 1 << 20, NetRXRate: 100, NetTXRate: 50}); err != nil {
+			t.Fatalf("insert container metric: %v", err)
+		}
+	}
+	if err := repo.InsertLogs(ctx, []models.LogEntry{
+		{TS: now.Add(-time.Minute), ServiceID: "web", ContainerID: "c1", Level: "ERROR", Stream: "stderr", Message: "upstream timed out"},
+		{TS: now.Add(-time.Minute), ServiceID: "web", ContainerID: "c2", Level: "INFO", Stream: "stdout", Message: "from the other replica"},
+	}); err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+	rules, err := repo.ListRules(ctx)
+	if err != nil {
+		t.Fatalf("list rules: %v", err)
+	}
+	ruleIDs := map[string]int64{}
+	for _, r := range rules {
+		ruleIDs[r.Name] = r.ID
+	}
+	for _, a := range []struct {
+		rule, target, summary string
+	}{
+		{"Container restarted", "c1", "web-c1 restarted"},
+		{"Container restarted", "c2", "web-c2 restarted"},
+		{"Error logs spiking", "web", "web logs errors"},
+	} {
+		if _, err := repo.CreateAlert(ctx, ruleIDs[a.rule], a.target, "firing", a.summary, nil, now.Add(-time.Hour)); err != nil {
+			t.Fatalf("create alert: %v", err)
+		}
+	}
+	h := s.Routes()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/containers/c1")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "nginx:1.27") || !strings.Contains(rec.Body.String(), "/fragments/container/c1/charts") {
+		t.Fatalf("page = %d %s", rec.Code, rec.Body)
+	}
+	if rec := get("/containers/nope"); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown container = %d", rec.Code)
+	}
+
+	rec = get("/fragments/container/c1/charts")
+	if body := rec.Body.String(); rec.Code != http.StatusOK || strings.Count(body, `<svg class="scrape-chart"`) != 4 || !strings.Contains(body, "cpu_pct 10") {
+		t.Fatalf("charts = %d %s", rec.Code, body)
+	}
+
+	rec = get("/fragments/container/c1/alerts")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "web logs errors") || !strings.Contains(body, "web-c1 restarted") || strings.Contains(body, "web-c2") {
+		t.Fatalf("alerts = %d %s", rec.Code, body)
+	}
+
+	rec = get("/fragments/container/c1/logs")
+	if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, "upstream timed out") || strings.Contains(body, "other replica") {
+		t.Fatalf("logs = %d %s", rec.Code, body)
+	}
+	if rec := get("/fragments/container/c1/other"); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown fragment = %d", rec.Code)
+	}
+}
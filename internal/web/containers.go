@@ -0,0 +1,196 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"dashi/internal/api"
+	"dashi/internal/db"
+	"dashi/internal/docker"
+	"dashi/internal/models"
+)
+
+// handleV1Container archives (POST .../archive) or purges (DELETE) a
+// container that Docker no longer runs, and lists the processes (GET
+// .../processes) or shows the redacted configuration (GET .../config) of a
+// running one.
+func (s *Server) handleV1Container(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, apiV1Prefix+"/containers/")
+	id, action, _ := strings.Cut(rest, "/")
+	if id == "" {
+		writeAPIError(w, http.StatusNotFound, "container not found")
+		return
+	}
+	if action == "processes" {
+		if r.Method != http.MethodGet {
+			writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		procs, err := s.processes(r.Context(), id)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			writeAPIError(w, http.StatusNotFound, "container not found")
+		case err != nil:
+			writeAPIError(w, http.StatusBadGateway, err.Error())
+		default:
+			writeJSON(w, api.Processes{ContainerID: id, Items: api.ProcessesFrom(procs)})
+		}
+		return
+	}
+	if action == "config" {
+		if r.Method != http.MethodGet {
+			writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		cfg, err := s.containerConfig(r.Context(), id)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			writeAPIError(w, http.StatusNotFound, "container not found")
+		case err != nil:
+			writeAPIError(w, http.StatusBadGateway, err.Error())
+		default:
+			writeJSON(w, api.ContainerConfigFrom(id, cfg))
+		}
+		return
+	}
+	var err error
+	switch {
+	case action == "archive" && r.Method == http.MethodPost:
+		err = s.containerLifecycle(r.Context(), "archive", id)
+	case action == "" && r.Method == http.MethodDelete:
+		err = s.containerLifecycle(r.Context(), "purge", id)
+	case action == "archive" || action == "":
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	default:
+		writeAPIError(w, http.StatusNotFound, "not found")
+		return
+	}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeAPIError(w, http.StatusNotFound, "container not found")
+	case errors.Is(err, db.ErrContainerActive):
+		writeAPIError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, api.Status{Status: "ok"})
+	}
+}
+
+// handleServicesLifecycle runs an archive or purge from the services panel
+// and re-renders it.
+func (s *Server) handleServicesLifecycle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err := s.containerLifecycle(r.Context(), r.FormValue("action"), r.FormValue("container_id"))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "container not found", http.StatusNotFound)
+		return
+	case errors.Is(err, db.ErrContainerActive):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.handleServicesFragment(w, r)
+}
+
+func (s *Server) containerLifecycle(ctx context.Context, action, id string) error {
+	switch action {
+	case "archive":
+		if err := s.repo.ArchiveContainer(ctx, id); err != nil {
+			return err
+		}
+	case "purge":
+		if err := s.repo.PurgeContainer(ctx, id); err != nil {
+			return err
+		}
+	default:
+		return errors.New("invalid container action")
+	}
+	s.log.Info("container "+action+"d", "container", id)
+	return nil
+}
+
+// handleProcessesFragment renders the process list of a container on demand.
+func (s *Server) handleProcessesFragment(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("container_id")
+	procs, err := s.processes(r.Context(), id)
+	data := map[string]any{"containerID": id, "name": r.URL.Query().Get("name"), "processes": procs}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		data["error"] = "container not found"
+	case err != nil:
+		data["error"] = err.Error()
+	}
+	_ = s.tpl.ExecuteTemplate(w, "fragment_processes.html", data)
+}
+
+// processes asks the daemon running container id for its process list,
+// busiest first.
+func (s *Server) processes(ctx context.Context, id string) ([]models.Process, error) {
+	dc, err := s.containerDocker(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	procs, err := dc.Top(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(procs, func(i, j int) bool { return procs[i].CPUPct > procs[j].CPUPct })
+	return procs, nil
+}
+
+// handleConfigFragment renders the redacted configuration of a container.
+func (s *Server) handleConfigFragment(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("container_id")
+	cfg, err := s.containerConfig(r.Context(), id)
+	data := map[string]any{"containerID": id, "name": r.URL.Query().Get("name"), "config": cfg}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		data["error"] = "container not found"
+	case err != nil:
+		data["error"] = err.Error()
+	}
+	_ = s.tpl.ExecuteTemplate(w, "fragment_config.html", data)
+}
+
+func (s *Server) containerConfig(ctx context.Context, id string) (models.ContainerConfig, error) {
+	dc, err := s.containerDocker(ctx, id)
+	if err != nil {
+		return models.ContainerConfig{}, err
+	}
+	return dc.ContainerConfig(ctx, id)
+}
+
+// errNoDocker is returned for requests to Docker in demo mode.
+var errNoDocker = errors.New("not available in demo mode, which has no Docker host")
+
+// containerDocker returns the client of the Docker host running container
+// id, sql.ErrNoRows for unknown containers.
+func (s *Server) containerDocker(ctx context.Context, id string) (*docker.Client, error) {
+	host, err := s.repo.ContainerHost(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := s.opts.DockerHosts[host]; ok {
+		return c, nil
+	}
+	if s.docker == nil {
+		return nil, errNoDocker
+	}
+	return s.docker, nil
+}
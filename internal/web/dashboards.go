@@ -0,0 +1,238 @@
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/models"
+)
+
+// defaultDashboard is the layout shown until one named "default" is saved.
+const defaultDashboard = "default"
+
+var dashboardName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// widgetType is a kind of dashboard widget: the element ID its fragment
+// and the fragments it triggers target, and the subsystem it needs.
+type widgetType struct {
+	id string
+	// fragment builds the URL the widget loads, empty for static ones.
+	fragment func(w models.Widget, host string) string
+	// services and ranged mark the widgets honouring Services and Range.
+	services, ranged bool
+	off              func(o Options) bool
+}
+
+func hostQuery(path, host string) string {
+	if host == "" {
+		return path
+	}
+	return path + "?host=" + url.QueryEscape(host)
+}
+
+var widgetTypes = map[string]widgetType{
+	"overview": {id: "overview", fragment: func(_ models.Widget, host string) string { return hostQuery("/fragments/overview", host) },
+		off: func(o Options) bool { return o.MetricsDisabled }},
+	"sites":    {id: "sites", fragment: func(models.Widget, string) string { return "/fragments/sites" }},
+	"hosts":    {id: "hosts", fragment: func(models.Widget, string) string { return "/fragments/hosts" }},
+	"services": {id: "services", fragment: func(_ models.Widget, host string) string { return hostQuery("/fragments/services", host) }},
+	"top": {id: "top", ranged: true, fragment: func(w models.Widget, host string) string {
+		q := url.Values{"range": {w.Range}}
+		if w.Range == "" {
+			q.Set("range", "24h")
+		}
+		if host != "" {
+			q.Set("host", host)
+		}
+		return "/fragments/top?" + q.Encode()
+	}, off: func(o Options) bool { return o.MetricsDisabled }},
+	"processes":   {id: "processes"},
+	"alerts":      {id: "alerts", fragment: func(_ models.Widget, host string) string { return hostQuery("/fragments/alerts", host) }, off: func(o Options) bool { return o.AlertsDisabled }},
+	"checks":      {id: "checks", fragment: func(models.Widget, string) string { return "/fragments/checks" }},
+	"heartbeats":  {id: "heartbeats", fragment: func(models.Widget, string) string { return "/fragments/heartbeats" }},
+	"log_filters": {id: "logs-filter", off: func(o Options) bool { return o.LogsDisabled }},
+	"logs": {id: "logs-panel", services: true, ranged: true, fragment: func(w models.Widget, host string) string {
+		q := url.Values{}
+		if len(w.Services) > 0 {
+			q.Set("service", strings.Join(w.Services, ","))
+		}
+		if w.Range != "" {
+			q.Set("range", w.Range)
+		}
+		if host != "" {
+			q.Set("host", host)
+		}
+		return "/fragments/logs?" + q.Encode()
+	}, off: func(o Options) bool { return o.LogsDisabled }},
+}
+
+// defaultWidgets is the dashboard dashi ships with.
+var defaultWidgets = []models.Widget{
+	{Type: "overview", Column: "left"},
+	{Type: "log_filters", Column: "left"},
+	{Type: "sites", Column: "main"},
+	{Type: "hosts", Column: "main"},
+	{Type: "services", Column: "main"},
+	{Type: "top", Column: "main"},
+	{Type: "processes", Column: "main"},
+	{Type: "alerts", Column: "main"},
+	{Type: "logs", Column: "main"},
+}
+
+// validateWidgets checks a layout before it is stored: known types, each
+// at most once as their elements are targeted by ID, and options only
+// where they apply.
+func validateWidgets(widgets []models.Widget) error {
+	seen := map[string]bool{}
+	for i, w := range widgets {
+		t, ok := widgetTypes[w.Type]
+		if !ok {
+			return fmt.Errorf("widget %d: unknown type %q", i, w.Type)
+		}
+		if seen[w.Type] {
+			return fmt.Errorf("widget %d: %s appears twice", i, w.Type)
+		}
+		seen[w.Type] = true
+		if w.Column != "left" && w.Column != "main" {
+			return fmt.Errorf("widget %d: column must be left or main", i)
+		}
+		if len(w.Services) > 0 && !t.services {
+			return fmt.Errorf("widget %d: %s does not take services", i, w.Type)
+		}
+		if w.Range != "" {
+			if !t.ranged {
+				return fmt.Errorf("widget %d: %s does not take a range", i, w.Type)
+			}
+			if d, err := time.ParseDuration(w.Range); err != nil || d <= 0 {
+				return fmt.Errorf("widget %d: invalid range %q", i, w.Range)
+			}
+		}
+	}
+	return nil
+}
+
+// dashboard returns the layout stored as name, the shipped one for an
+// unsaved default, and sql.ErrNoRows for other unknown names.
+func (s *Server) dashboard(r *http.Request, name string) (models.Dashboard, error) {
+	d, err := s.repo.Dashboard(r.Context(), name)
+	if errors.Is(err, sql.ErrNoRows) && name == defaultDashboard {
+		return models.Dashboard{Name: name, Widgets: defaultWidgets}, nil
+	}
+	return d, err
+}
+
+// dashWidget is a widget as the index page renders it.
+type dashWidget struct {
+	Type, ID, URL, Host string
+}
+
+// dashboardColumns resolves d's widgets for the index page into its two
+// columns, leaving out those of disabled subsystems and those that do not
+// apply: sites without federation and hosts with a single or a selected
+// host.
+func (s *Server) dashboardColumns(d models.Dashboard, host string, hosts []string) (left, main []dashWidget) {
+	filters := false
+	for _, w := range d.Widgets {
+		filters = filters || w.Type == "log_filters"
+	}
+	for _, w := range d.Widgets {
+		t := widgetTypes[w.Type]
+		switch {
+		case t.off != nil && t.off(s.opts):
+			continue
+		case w.Type == "sites" && (!s.opts.Federation || host != ""):
+			continue
+		case w.Type == "hosts" && (len(hosts) == 0 || host != ""):
+			continue
+		}
+		dw := dashWidget{Type: w.Type, ID: t.id, Host: host}
+		// The filter form loads the logs panel itself.
+		if t.fragment != nil && !(w.Type == "logs" && filters) {
+			dw.URL = t.fragment(w, host)
+		}
+		if w.Column == "left" {
+			left = append(left, dw)
+		} else {
+			main = append(main, dw)
+		}
+	}
+	return left, main
+}
+
+// handleV1Dashboards lists the stored dashboard layouts.
+func (s *Server) handleV1Dashboards(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	dashboards, err := s.repo.Dashboards(r.Context())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.Dashboards{Items: api.DashboardsFrom(dashboards)})
+}
+
+// handleV1Dashboard serves GET, PUT and DELETE /api/v1/dashboards/{name}.
+// Deleting "default" restores the shipped layout.
+func (s *Server) handleV1Dashboard(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, apiV1Prefix+"/dashboards/")
+	if !dashboardName.MatchString(name) {
+		writeAPIError(w, http.StatusNotFound, "not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		d, err := s.dashboard(r, name)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeAPIError(w, http.StatusNotFound, "dashboard not found")
+			return
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, api.DashboardFrom(d))
+	case http.MethodPut:
+		var in api.Dashboard
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&in); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid dashboard: "+err.Error())
+			return
+		}
+		d := models.Dashboard{Name: name, Widgets: make([]models.Widget, 0, len(in.Widgets)), UpdatedAt: time.Now().UTC()}
+		for _, w := range in.Widgets {
+			d.Widgets = append(d.Widgets, models.Widget{Type: w.Type, Column: w.Column, Services: w.Services, Range: w.Range})
+		}
+		if err := validateWidgets(d.Widgets); err != nil {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := s.repo.SaveDashboard(r.Context(), d); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, api.DashboardFrom(d))
+	case http.MethodDelete:
+		found, err := s.repo.DeleteDashboard(r.Context(), name)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !found {
+			writeAPIError(w, http.StatusNotFound, "dashboard not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
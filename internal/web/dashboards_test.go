@@ -0,0 +1,85 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dashi/internal/api"
+)
+
+func TestDashboards(t *testing.T) {
+	s, _ := newTestServer(t, Options{})
+	h := s.Routes()
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/api/v1/dashboards/default", "")
+	var d api.Dashboard
+	if err := json.Unmarshal(rec.Body.Bytes(), &d); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("get default = %d %s", rec.Code, rec.Body)
+	}
+	if len(d.Widgets) != len(defaultWidgets) || d.UpdatedAt != nil {
+		t.Fatalf("default dashboard = %+v, want the shipped layout", d)
+	}
+	if rec := do(http.MethodGet, "/api/v1/dashboards/nope", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("get unknown = %d, want 404", rec.Code)
+	}
+
+	for _, body := range []string{
+		`{"widgets":[{"type":"graph","column":"main"}]}`,
+		`{"widgets":[{"type":"alerts","column":"main"},{"type":"alerts","column":"left"}]}`,
+		`{"widgets":[{"type":"services","column":"main","range":"6h"}]}`,
+		`{"widgets":[{"type":"logs","column":"right"}]}`,
+		`{"widgets":[{"type":"logs","column":"main","range":"soon"}]}`,
+	} {
+		if rec := do(http.MethodPut, "/api/v1/dashboards/default", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("put %s = %d, want 400", body, rec.Code)
+		}
+	}
+
+	layout := `{"widgets":[{"type":"checks","column":"left"},{"type":"logs","column":"main","services":["api"],"range":"6h"}]}`
+	if rec := do(http.MethodPut, "/api/v1/dashboards/default", layout); rec.Code != http.StatusOK {
+		t.Fatalf("put = %d %s", rec.Code, rec.Body)
+	}
+	rec = do(http.MethodGet, "/api/v1/dashboards/default", "")
+	d = api.Dashboard{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &d)
+	if len(d.Widgets) != 2 || d.Widgets[1].Range != "6h" || d.UpdatedAt == nil {
+		t.Fatalf("saved dashboard = %+v", d)
+	}
+	if rec := do(http.MethodPut, "/api/v1/dashboards/ops", `{"widgets":[{"type":"alerts","column":"main"}]}`); rec.Code != http.StatusOK {
+		t.Fatalf("put ops = %d %s", rec.Code, rec.Body)
+	}
+	var list api.Dashboards
+	_ = json.Unmarshal(do(http.MethodGet, "/api/v1/dashboards", "").Body.Bytes(), &list)
+	if len(list.Items) != 2 {
+		t.Fatalf("dashboards = %+v, want default and ops", list.Items)
+	}
+
+	body := do(http.MethodGet, "/", "").Body.String()
+	if !strings.Contains(body, `id="checks"`) || strings.Contains(body, `id="top"`) {
+		t.Fatalf("index does not follow the saved layout:\n%s", body)
+	}
+	if !strings.Contains(body, "/fragments/logs?range=6h&amp;service=api") {
+		t.Fatalf("logs widget does not load its options:\n%s", body)
+	}
+	if body := do(http.MethodGet, "/?dashboard=ops", "").Body.String(); !strings.Contains(body, `id="alerts"`) || strings.Contains(body, `id="checks"`) {
+		t.Fatalf("index does not show the ops layout:\n%s", body)
+	}
+	if rec := do(http.MethodGet, "/?dashboard=nope", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown dashboard = %d, want 404", rec.Code)
+	}
+
+	if rec := do(http.MethodDelete, "/api/v1/dashboards/ops", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete = %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, "/api/v1/dashboards/ops", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("second delete = %d, want 404", rec.Code)
+	}
+}
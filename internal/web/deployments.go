@@ -0,0 +1,110 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/deploy"
+	"dashi/internal/models"
+)
+
+// handleIngestDeployments records a deployment reported by a GitHub or
+// GitLab webhook or a CI job, for the service named by ?service= or else
+// by the payload. Events that are not a finished deployment are skipped.
+func (s *Server) handleIngestDeployments(w http.ResponseWriter, r *http.Request) {
+	call, body, ok := s.ingestBody(w, r)
+	if !ok {
+		return
+	}
+	ev, ok, err := deploy.Parse(r.Header, body, time.Now().UTC())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !ok {
+		s.writeIngestResult(w, r, call, api.IngestResult{Skipped: 1})
+		return
+	}
+	name := r.URL.Query().Get("service")
+	if name == "" {
+		name = ev.Service
+	}
+	if name == "" {
+		writeAPIError(w, http.StatusBadRequest, "service is required")
+		return
+	}
+	serviceID, err := s.repo.ResolveService(r.Context(), name)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeAPIError(w, http.StatusNotFound, "unknown service "+name+"; name it with ?service=")
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	d := models.Deployment{TS: ev.TS, ServiceID: serviceID, Source: ev.Source, Version: ev.Version,
+		Environment: ev.Environment, Description: ev.Description, URL: ev.URL}
+	if _, err := s.repo.InsertDeployment(r.Context(), d); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.log.Info("deployment recorded", "service", serviceID, "version", d.Version, "source", d.Source)
+	s.writeIngestResult(w, r, call, api.IngestResult{Accepted: 1})
+}
+
+func (s *Server) handleV1Deployments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	q := r.URL.Query()
+	rng := parseRange(q.Get("range"))
+	now := time.Now()
+	deployments, err := s.repo.Deployments(r.Context(), now.Add(-rng), now, q.Get("service"), q.Get("host"), 500)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.Deployments{Range: rng.String(), Items: api.DeploymentsFrom(deployments)})
+}
+
+// deployMarker is a deployment drawn as a vertical line on a chart.
+type deployMarker struct {
+	X     float64
+	Label string
+}
+
+// deployMarkers places deployments on a chart width wide starting at
+// start, where each unit spans unit; those outside are left out.
+func deployMarkers(deployments []models.Deployment, start time.Time, unit time.Duration, width float64) []deployMarker {
+	var out []deployMarker
+	for _, d := range deployments {
+		x := float64(d.TS.Sub(start)) / float64(unit)
+		if x < 0 || x > width {
+			continue
+		}
+		label := d.TS.Format("15:04") + " deployed " + d.ServiceID
+		if d.Version != "" {
+			label += " " + d.Version
+		}
+		out = append(out, deployMarker{X: x, Label: label})
+	}
+	return out
+}
+
+// deploymentAlertRows returns deployments as rows of the alert timeline.
+func deploymentAlertRows(deployments []models.Deployment) []map[string]any {
+	out := make([]map[string]any, 0, len(deployments))
+	for _, d := range deployments {
+		summary := d.Version
+		if d.Description != "" {
+			summary = strings.TrimSpace(summary + " " + d.Description)
+		}
+		out = append(out, map[string]any{"status": "deployed", "rule_name": d.ServiceID, "summary": summary, "started": d.TS, "url": d.URL})
+	}
+	return out
+}
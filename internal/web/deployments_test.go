@@ -0,0 +1,82 @@
+package web
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/models"
+)
+
+func TestDeploymentWebhooks(t *testing.T) {
+	s, repo := newTestServer(t, Options{IngestToken: "secret"})
+	ctx := context.Background()
+	svc := models.Service{ID: "shop", Name: "shop", Image: "img", LabelsJSON: "{}", Status: "running"}
+	if err := repo.UpsertServiceAndContainer(ctx, svc, models.Container{ID: "c1", ServiceID: "shop", Name: "shop", Status: "running"}); err != nil {
+		t.Fatalf("store container: %v", err)
+	}
+	h := s.Routes()
+	post := func(path, body string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	sign := func(secret, body string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	release := `{"action": "published", "release": {"tag_name": "v2.0.0"}, "repository": {"name": "shop"}}`
+	if rec := post("/api/ingest/deployments", release, map[string]string{"X-GitHub-Event": "release", "X-Hub-Signature-256": sign("wrong", release)}); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("badly signed webhook = %d", rec.Code)
+	}
+	if rec := post("/api/ingest/deployments", release, map[string]string{"X-GitHub-Event": "release", "X-Hub-Signature-256": sign("secret", release)}); rec.Code != http.StatusAccepted {
+		t.Fatalf("github webhook = %d %s", rec.Code, rec.Body)
+	}
+	if rec := post("/api/ingest/deployments", `{"status": "running"}`, map[string]string{"X-Gitlab-Event": "Deployment Hook", "X-Gitlab-Token": "secret"}); rec.Code != http.StatusAccepted ||
+		!strings.Contains(rec.Body.String(), `"skipped":1`) {
+		t.Fatalf("running gitlab deployment = %d %s", rec.Code, rec.Body)
+	}
+	if rec := post("/api/ingest/deployments", `{"version": "v2.0.1"}`, map[string]string{"Authorization": "Bearer secret"}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("deployment without service = %d", rec.Code)
+	}
+	if rec := post("/api/ingest/deployments?service=nope", `{"version": "v2.0.1"}`, map[string]string{"Authorization": "Bearer secret"}); rec.Code != http.StatusNotFound {
+		t.Fatalf("deployment of unknown service = %d", rec.Code)
+	}
+	if rec := post("/api/ingest/deployments?service=shop", `{"version": "v2.0.1", "description": "hotfix", "url": "https://ci.example/1"}`,
+		map[string]string{"Authorization": "Bearer secret"}); rec.Code != http.StatusAccepted {
+		t.Fatalf("generic deployment = %d %s", rec.Code, rec.Body)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/deployments?service=shop&range=1h", nil))
+	var list api.Deployments
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list.Items) != 2 ||
+		list.Items[0].Version != "v2.0.1" || list.Items[0].Source != "webhook" || list.Items[1].Version != "v2.0.0" || list.Items[1].Source != "github" {
+		t.Fatalf("deployments = %s", rec.Body)
+	}
+
+	if _, err := repo.CreateAlert(ctx, 1, "host", "firing", "CPU high", nil, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("create alert: %v", err)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fragments/alerts", nil))
+	body := rec.Body.String()
+	deployed, alert := strings.Index(body, "v2.0.1 hotfix"), strings.Index(body, "CPU high")
+	if deployed < 0 || alert < 0 || deployed > alert || !strings.Contains(body, `href="https://ci.example/1"`) {
+		t.Fatalf("alert timeline = %s", body)
+	}
+}
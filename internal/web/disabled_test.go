@@ -0,0 +1,33 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDisabledSubsystems(t *testing.T) {
+	s, _ := newTestServer(t, Options{LogsDisabled: true, AlertsDisabled: true})
+	h := s.Routes()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	page := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(page, `id="overview"`) {
+		t.Fatalf("index status = %d, overview missing", rec.Code)
+	}
+	for _, panel := range []string{`id="logs-filter"`, `id="logs-panel"`, `id="alerts"`} {
+		if strings.Contains(page, panel) {
+			t.Errorf("index shows %s of a disabled subsystem", panel)
+		}
+	}
+
+	for _, path := range []string{"/api/v1/logs", "/api/v1/alerts", "/fragments/logs", "/settings/rules"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "disabled") {
+			t.Errorf("%s: status = %d (%s), want 404", path, rec.Code, rec.Body)
+		}
+	}
+}
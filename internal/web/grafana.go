@@ -0,0 +1,401 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/docker"
+	"dashi/internal/models"
+	"dashi/internal/rollup"
+)
+
+// grafanaPrefix serves the contract of Grafana's JSON datasource plugin
+// (simpod-json-datasource) and of the older SimpleJSON one, so dashboards
+// can chart dashi's stored metrics. Targets are named
+// host:<field>, container:<field>{<labels>} and scrape:<target>:<metric>{<labels>}.
+const grafanaPrefix = "/api/grafana"
+
+// grafanaMaxRows bounds the samples read per container or host series;
+// a year of hourly rollups fits.
+const grafanaMaxRows = 10000
+
+var grafanaHostFields = map[string]func(models.HostMetric) float64{
+	"cpu_pct":         func(m models.HostMetric) float64 { return m.CPUPct },
+	"mem_pct":         func(m models.HostMetric) float64 { return pct(m.MemUsedBytes, m.MemTotalBytes) },
+	"mem_used_bytes":  func(m models.HostMetric) float64 { return float64(m.MemUsedBytes) },
+	"swap_pct":        func(m models.HostMetric) float64 { return pct(m.SwapUsedBytes, m.SwapTotalBytes) },
+	"disk_pct":        func(m models.HostMetric) float64 { return pct(m.DiskUsedBytes, m.DiskTotalBytes) },
+	"disk_used_bytes": func(m models.HostMetric) float64 { return float64(m.DiskUsedBytes) },
+	"load1":           func(m models.HostMetric) float64 { return m.Load1 },
+	"load5":           func(m models.HostMetric) float64 { return m.Load5 },
+	"load15":          func(m models.HostMetric) float64 { return m.Load15 },
+	"net_rx_rate":     func(m models.HostMetric) float64 { return m.NetRXRate },
+	"net_tx_rate":     func(m models.HostMetric) float64 { return m.NetTXRate },
+	"disk_read_rate":  func(m models.HostMetric) float64 { return m.DiskReadRate },
+	"disk_write_rate": func(m models.HostMetric) float64 { return m.DiskWriteRate },
+	"disk_util_pct":   func(m models.HostMetric) float64 { return m.DiskUtilPct },
+	"cpu_temp_c":      func(m models.HostMetric) float64 { return m.CPUTempC },
+	"nvme_temp_c":     func(m models.HostMetric) float64 { return m.NVMeTempC },
+	"tcp_established": func(m models.HostMetric) float64 { return float64(m.TCPEstablished) },
+	"tcp_conns":       func(m models.HostMetric) float64 { return float64(m.TCPConns) },
+}
+
+var grafanaContainerFields = map[string]func(models.ContainerMetric) float64{
+	"cpu_pct":        func(m models.ContainerMetric) float64 { return m.CPUPct },
+	"mem_used_bytes": func(m models.ContainerMetric) float64 { return float64(m.MemUsedBytes) },
+	"mem_pct":        func(m models.ContainerMetric) float64 { return pct(m.MemUsedBytes, m.MemLimitBytes) },
+	"net_rx_rate":    func(m models.ContainerMetric) float64 { return m.NetRXRate },
+	"net_tx_rate":    func(m models.ContainerMetric) float64 { return m.NetTXRate },
+	"blk_read_rate":  func(m models.ContainerMetric) float64 { return m.BlkReadRate },
+	"blk_write_rate": func(m models.ContainerMetric) float64 { return m.BlkWriteRate },
+	"pids":           func(m models.ContainerMetric) float64 { return float64(m.Pids) },
+	"fds":            func(m models.ContainerMetric) float64 { return float64(m.FDs) },
+}
+
+type grafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type grafanaQueryRequest struct {
+	Range         grafanaRange `json:"range"`
+	MaxDataPoints int          `json:"maxDataPoints"`
+	Targets       []struct {
+		Target string `json:"target"`
+		Hide   bool   `json:"hide"`
+	} `json:"targets"`
+}
+
+// grafanaSeries is a time series response; datapoints are
+// [value, unix milliseconds] pairs, oldest first.
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+type grafanaAnnotationRequest struct {
+	Range      grafanaRange    `json:"range"`
+	Annotation json.RawMessage `json:"annotation"`
+}
+
+type grafanaAnnotation struct {
+	Annotation json.RawMessage `json:"annotation"`
+	Time       int64           `json:"time"`
+	TimeEnd    int64           `json:"timeEnd,omitempty"`
+	Title      string          `json:"title"`
+	Text       string          `json:"text"`
+	Tags       []string        `json:"tags"`
+}
+
+type grafanaMetric struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+func (s *Server) handleGrafana(w http.ResponseWriter, r *http.Request) {
+	switch strings.Trim(strings.TrimPrefix(r.URL.Path, grafanaPrefix), "/") {
+	case "":
+		// The datasource's connection test.
+		if r.Method != http.MethodGet {
+			writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeJSON(w, map[string]string{"status": "ok"})
+	case "search":
+		var in struct {
+			Target string `json:"target"`
+		}
+		if !grafanaBody(w, r, &in) {
+			return
+		}
+		names, err := s.grafanaTargets(r.Context(), in.Target)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, names)
+	case "metrics":
+		var in struct {
+			Metric string `json:"metric"`
+		}
+		if !grafanaBody(w, r, &in) {
+			return
+		}
+		names, err := s.grafanaTargets(r.Context(), in.Metric)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		out := make([]grafanaMetric, 0, len(names))
+		for _, name := range names {
+			out = append(out, grafanaMetric{Label: name, Value: name})
+		}
+		writeJSON(w, out)
+	case "query":
+		s.handleGrafanaQuery(w, r)
+	case "annotations":
+		s.handleGrafanaAnnotations(w, r)
+	default:
+		writeAPIError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) handleGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	var in grafanaQueryRequest
+	if !grafanaBody(w, r, &in) {
+		return
+	}
+	if in.Range.From.IsZero() || !in.Range.To.After(in.Range.From) {
+		writeAPIError(w, http.StatusBadRequest, "invalid range")
+		return
+	}
+	out := []grafanaSeries{}
+	for _, t := range in.Targets {
+		if t.Hide || strings.TrimSpace(t.Target) == "" {
+			continue
+		}
+		series, err := s.grafanaSeries(r.Context(), strings.TrimSpace(t.Target), in.Range.From, in.Range.To)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		for _, ser := range series {
+			ser.Datapoints = downsample(ser.Datapoints, in.Range.From, in.Range.To, in.MaxDataPoints)
+			out = append(out, ser)
+		}
+	}
+	writeJSON(w, out)
+}
+
+// handleGrafanaAnnotations marks the alerts started and the deployments
+// made in the range, those of rules or services whose name contains the
+// annotation's query when it has one.
+func (s *Server) handleGrafanaAnnotations(w http.ResponseWriter, r *http.Request) {
+	var in grafanaAnnotationRequest
+	if !grafanaBody(w, r, &in) {
+		return
+	}
+	to := in.Range.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	var ann struct {
+		Query string `json:"query"`
+	}
+	_ = json.Unmarshal(in.Annotation, &ann)
+	q := strings.ToLower(strings.TrimSpace(ann.Query))
+	out := []grafanaAnnotation{}
+	if !s.opts.AlertsDisabled {
+		alerts, err := s.repo.RecentAlerts(r.Context(), in.Range.From, 1000, "")
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, a := range alerts {
+			started, _ := a["started"].(time.Time)
+			rule, _ := a["rule_name"].(string)
+			if started.After(to) || !strings.Contains(strings.ToLower(rule), q) {
+				continue
+			}
+			item := grafanaAnnotation{Annotation: in.Annotation, Time: started.UnixMilli(), Title: rule,
+				Tags: []string{fmt.Sprint(a["status"])}}
+			item.Text, _ = a["summary"].(string)
+			if ended, ok := a["ended"].(time.Time); ok {
+				item.TimeEnd = ended.UnixMilli()
+			}
+			out = append(out, item)
+		}
+	}
+	deployments, err := s.repo.Deployments(r.Context(), in.Range.From, to, "", "", 1000)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for _, d := range deployments {
+		if !strings.Contains(strings.ToLower(d.ServiceID), q) {
+			continue
+		}
+		out = append(out, grafanaAnnotation{Annotation: in.Annotation, Time: d.TS.UnixMilli(), Title: strings.TrimSpace("Deployed " + d.ServiceID + " " + d.Version),
+			Text: d.Description, Tags: []string{"deployment", d.ServiceID}})
+	}
+	writeJSON(w, out)
+}
+
+func grafanaBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(v); err != nil && err != io.EOF {
+		writeAPIError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// grafanaTargets lists the queryable targets containing filter: the host
+// and container fields, and the metrics scraped in the last day.
+func (s *Server) grafanaTargets(ctx context.Context, filter string) ([]string, error) {
+	var names []string
+	for field := range grafanaHostFields {
+		names = append(names, "host:"+field)
+	}
+	for field := range grafanaContainerFields {
+		names = append(names, "container:"+field)
+	}
+	samples, err := s.repo.LatestScrapeSamples(ctx, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, sample := range samples {
+		name := "scrape:" + sample.Target + ":" + sample.Metric
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	out := names[:0]
+	for _, name := range names {
+		if strings.Contains(name, filter) {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// grafanaSeries returns the series of target between from and to, from
+// raw samples or rollups as the metric endpoints pick them. Container and
+// scrape series without samples in the range are left out.
+func (s *Server) grafanaSeries(ctx context.Context, target string, from, to time.Time) ([]grafanaSeries, error) {
+	kind, rest, _ := strings.Cut(target, ":")
+	selector := ""
+	if i := strings.IndexByte(rest, '{'); i >= 0 && strings.HasSuffix(rest, "}") {
+		rest, selector = rest[:i], rest[i+1:len(rest)-1]
+	}
+	sel, err := db.ParseLabelSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	res := rollup.PickResolution(to.Sub(from))
+	var out []grafanaSeries
+	switch kind {
+	case "host":
+		field, ok := grafanaHostFields[rest]
+		if !ok {
+			return nil, fmt.Errorf("unknown host metric %q", rest)
+		}
+		var metrics []models.HostMetric
+		if res == 0 {
+			metrics, err = s.repo.RecentHostMetrics(ctx, from, grafanaMaxRows)
+		} else {
+			var rollups []models.HostMetricRollup
+			rollups, err = s.repo.HostMetricRollups(ctx, res, from, grafanaMaxRows)
+			for _, m := range rollups {
+				metrics = append(metrics, m.HostMetric)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		ser := grafanaSeries{Target: target, Datapoints: [][2]float64{}}
+		for _, m := range metrics {
+			if !m.TS.After(to) {
+				ser.Datapoints = append(ser.Datapoints, [2]float64{field(m), float64(m.TS.UnixMilli())})
+			}
+		}
+		out = append(out, ser)
+	case "container":
+		field, ok := grafanaContainerFields[rest]
+		if !ok {
+			return nil, fmt.Errorf("unknown container metric %q", rest)
+		}
+		containers, err := s.repo.ListContainers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+		for _, c := range containers {
+			if !sel.Matches(map[string]string{"service": c.ServiceID, "host": c.Host, "container": c.Name}) {
+				continue
+			}
+			metrics, err := s.containerMetrics(ctx, c.ID, from, res, grafanaMaxRows)
+			if err != nil {
+				return nil, err
+			}
+			name := c.Name
+			if c.Host != "" && c.Host != docker.LocalHost {
+				name += "@" + c.Host
+			}
+			ser := grafanaSeries{Target: name, Datapoints: [][2]float64{}}
+			for _, m := range metrics {
+				if !m.TS.After(to) {
+					ser.Datapoints = append(ser.Datapoints, [2]float64{field(m), float64(m.TS.UnixMilli())})
+				}
+			}
+			if len(ser.Datapoints) > 0 {
+				out = append(out, ser)
+			}
+		}
+	case "scrape":
+		name, metric, ok := strings.Cut(rest, ":")
+		if !ok || name == "" || metric == "" {
+			return nil, fmt.Errorf("scrape targets are scrape:<target>:<metric>, got %q", target)
+		}
+		series, err := s.scrapeSeries(ctx, name, metric, selector, from)
+		if err != nil {
+			return nil, err
+		}
+		for _, sr := range series {
+			ser := grafanaSeries{Target: sr.Name, Datapoints: [][2]float64{}}
+			for _, p := range sr.Points {
+				if !p.TS.After(to) {
+					ser.Datapoints = append(ser.Datapoints, [2]float64{p.Value, float64(p.TS.UnixMilli())})
+				}
+			}
+			if len(ser.Datapoints) > 0 {
+				out = append(out, ser)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown target %q: want host:, container: or scrape:", target)
+	}
+	return out, nil
+}
+
+// downsample averages points into at most n equal buckets between from and
+// to, each stamped with its start, when there are more than n.
+func downsample(points [][2]float64, from, to time.Time, n int) [][2]float64 {
+	if n <= 0 || len(points) <= n {
+		return points
+	}
+	start := float64(from.UnixMilli())
+	width := float64(to.Sub(from).Milliseconds()) / float64(n)
+	out := make([][2]float64, 0, n)
+	bucket, sum, count := -1, 0.0, 0
+	flush := func() {
+		if count > 0 {
+			out = append(out, [2]float64{sum / float64(count), math.Floor(start + float64(bucket)*width)})
+		}
+	}
+	for _, p := range points {
+		b := int((p[1] - start) / width)
+		if b != bucket {
+			flush()
+			bucket, sum, count = b, 0, 0
+		}
+		sum += p[0]
+		count++
+	}
+	flush()
+	return out
+}
@@ -0,0 +1,94 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/models"
+)
+
+func TestGrafanaDatasource(t *testing.T) {
+	s, repo := newTestServer(t, Options{})
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	svc := models.Service{ID: "web", Name: "web", Image: "img", LabelsJSON: "{}", Status: "running"}
+	if err := repo.UpsertServiceAndContainer(ctx, svc, models.Container{ID: "c1", ServiceID: "web", Name: "web", Status: "running"}); err != nil {
+		t.Fatalf("store container: %v", err)
+	}
+	for m := 4; m >= 1; m-- {
+		ts := now.Add(-time.Duration(m) * time.Minute)
+		if err := repo.InsertHostMetric(ctx, models.HostMetric{TS: ts, CPUPct: float64(10 * m), MemUsedBytes: 1, MemTotalBytes: 4}); err != nil {
+			t.Fatalf("insert host metric: %v", err)
+		}
+		if err := repo.InsertContainerMetric(ctx, models.ContainerMetric{TS: ts, ContainerID: "c1", CPUPct: float64(m)}); err != nil {
+			t.Fatalf("insert container metric: %v", err)
+		}
+	}
+	if err := repo.InsertScrapeSamples(ctx, []models.ScrapeSample{{TS: now.Add(-time.Minute), Target: "app", Metric: "queue",
+		Labels: map[string]string{"q": "mail"}, Value: 3}}); err != nil {
+		t.Fatalf("insert samples: %v", err)
+	}
+	if _, err := repo.CreateAlert(ctx, 1, "host", "firing", "CPU high", nil, now.Add(-30*time.Second)); err != nil {
+		t.Fatalf("create alert: %v", err)
+	}
+	h := s.Routes()
+	post := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/grafana/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("connection test = %d", rec.Code)
+	}
+	rec = post("/api/grafana/search", `{"target": "cpu_pct"}`)
+	var names []string
+	if err := json.Unmarshal(rec.Body.Bytes(), &names); err != nil || strings.Join(names, " ") != "container:cpu_pct host:cpu_pct" {
+		t.Fatalf("search = %d %s", rec.Code, rec.Body)
+	}
+	rec = post("/api/grafana/search", `{"target": ""}`)
+	if !strings.Contains(rec.Body.String(), `"scrape:app:queue"`) {
+		t.Fatalf("search = %s", rec.Body)
+	}
+
+	rng := `"range": {"from": "` + now.Add(-time.Hour).Format(time.RFC3339) + `", "to": "` + now.Add(-90*time.Second).Format(time.RFC3339) + `"}`
+	rec = post("/api/grafana/query", `{`+rng+`, "maxDataPoints": 100, "targets": [
+		{"refId": "A", "target": "host:mem_pct"},
+		{"refId": "B", "target": "container:cpu_pct{service=web}"},
+		{"refId": "C", "target": "container:cpu_pct{service=db}"},
+		{"refId": "D", "target": "scrape:app:queue{q=mail}"},
+		{"refId": "E", "target": "host:cpu_pct", "hide": true}]}`)
+	var series []grafanaSeries
+	if err := json.Unmarshal(rec.Body.Bytes(), &series); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("query = %d %s", rec.Code, rec.Body)
+	}
+	// The range ends before the newest samples.
+	if len(series) != 2 || series[0].Target != "host:mem_pct" || len(series[0].Datapoints) != 3 || series[0].Datapoints[0][0] != 25 ||
+		series[0].Datapoints[0][1] != float64(now.Add(-4*time.Minute).UnixMilli()) || series[1].Target != "web" || len(series[1].Datapoints) != 3 {
+		t.Fatalf("series = %+v", series)
+	}
+	rec = post("/api/grafana/query", `{`+rng+`, "maxDataPoints": 1, "targets": [{"target": "host:cpu_pct"}]}`)
+	if err := json.Unmarshal(rec.Body.Bytes(), &series); err != nil || len(series) != 1 || len(series[0].Datapoints) != 1 || series[0].Datapoints[0][0] != 30 {
+		t.Fatalf("downsampled = %s", rec.Body)
+	}
+	if rec := post("/api/grafana/query", `{`+rng+`, "targets": [{"target": "host:nope"}]}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown target = %d", rec.Code)
+	}
+
+	rec = post("/api/grafana/annotations", `{`+rng+`, "annotation": {"name": "alerts", "query": ""}}`)
+	var anns []grafanaAnnotation
+	if err := json.Unmarshal(rec.Body.Bytes(), &anns); err != nil || len(anns) != 0 {
+		t.Fatalf("annotations before the alert = %s", rec.Body)
+	}
+	rec = post("/api/grafana/annotations", `{"range": {"from": "`+now.Add(-time.Hour).Format(time.RFC3339)+`", "to": "`+now.Format(time.RFC3339)+`"}, "annotation": {"name": "alerts"}}`)
+	if err := json.Unmarshal(rec.Body.Bytes(), &anns); err != nil || len(anns) != 1 || anns[0].Text != "CPU high" || anns[0].Tags[0] != "firing" || !strings.Contains(string(anns[0].Annotation), `"alerts"`) {
+		t.Fatalf("annotations = %s", rec.Body)
+	}
+}
@@ -0,0 +1,218 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/checks"
+	"dashi/internal/models"
+)
+
+// handlePing serves the ping URLs of heartbeats: /ping/{token} and
+// /ping/{token}/fail. Cron jobs call it with curl, so any method is
+// accepted and the answer is plain text.
+func (s *Server) handlePing(w http.ResponseWriter, r *http.Request) {
+	token, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/ping/"), "/")
+	if token == "" || (action != "" && action != "fail") {
+		http.NotFound(w, r)
+		return
+	}
+	err := s.repo.PingHeartbeat(r.Context(), token, action == "fail", time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = io.WriteString(w, "OK\n")
+}
+
+// handleKumaPush takes pings in Uptime Kuma's push URL format,
+// /api/push/{token}?status=up|down&msg=&ping=, so jobs set up for Kuma
+// only need a new host. msg and ping are ignored.
+func (s *Server) handleKumaPush(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/api/push/")
+	err := sql.ErrNoRows
+	if token != "" && !strings.Contains(token, "/") {
+		err = s.repo.PingHeartbeat(r.Context(), token, r.URL.Query().Get("status") == "down", time.Now())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": false, "msg": "Monitor not found or not active."})
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": false, "msg": err.Error()})
+	default:
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	}
+}
+
+func (s *Server) handleV1Heartbeats(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		heartbeats, err := s.repo.ListHeartbeats(r.Context())
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		out := api.Heartbeats{Items: make([]api.Heartbeat, 0, len(heartbeats))}
+		for _, h := range heartbeats {
+			out.Items = append(out.Items, heartbeatFrom(r, h))
+		}
+		writeJSON(w, out)
+	case http.MethodPost:
+		var in api.Heartbeat
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&in); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid heartbeat: "+err.Error())
+			return
+		}
+		h := models.Heartbeat{Name: in.Name, Token: in.Token, PeriodSec: in.PeriodSec, GraceSec: in.GraceSec}
+		if err := checks.NormalizeHeartbeat(&h); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid heartbeat: "+err.Error())
+			return
+		}
+		h, err := s.createHeartbeat(r.Context(), h)
+		if errors.Is(err, errTokenTaken) {
+			writeAPIError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, heartbeatFrom(r, h))
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleV1Heartbeat(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, apiV1Prefix+"/heartbeats/"), 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, "heartbeat not found")
+		return
+	}
+	if r.Method != http.MethodDelete {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	err = s.repo.DeleteHeartbeat(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeAPIError(w, http.StatusNotFound, "heartbeat not found")
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var errTokenTaken = errors.New("token is already in use")
+
+// createHeartbeat stores a validated heartbeat under its token, or a new
+// one when it has none.
+func (s *Server) createHeartbeat(ctx context.Context, h models.Heartbeat) (models.Heartbeat, error) {
+	if h.Token == "" {
+		token, err := checks.NewHeartbeatToken()
+		if err != nil {
+			return h, err
+		}
+		h.Token = token
+	} else {
+		existing, err := s.repo.ListHeartbeats(ctx)
+		if err != nil {
+			return h, err
+		}
+		for _, e := range existing {
+			if e.Token == h.Token {
+				return h, errTokenTaken
+			}
+		}
+	}
+	h.CreatedAt = time.Now().UTC()
+	var err error
+	h.ID, err = s.repo.CreateHeartbeat(ctx, h)
+	return h, err
+}
+
+func heartbeatFrom(r *http.Request, h models.Heartbeat) api.Heartbeat {
+	base := baseURL(r)
+	return api.HeartbeatFrom(h, base+"/ping/"+h.Token, base+"/api/push/"+h.Token, checks.HeartbeatState(h, time.Now()), checks.HeartbeatDeadline(h))
+}
+
+// baseURL is the address the request reached dashi on, honouring
+// X-Forwarded-Proto from a reverse proxy.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// handleHeartbeatsFragment renders the heartbeat table of the uptime page.
+// POST adds a heartbeat from the page form first.
+func (s *Server) handleHeartbeatsFragment(w http.ResponseWriter, r *http.Request) {
+	data := map[string]any{}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		period, _ := strconv.Atoi(r.FormValue("period_sec"))
+		grace, _ := strconv.Atoi(r.FormValue("grace_sec"))
+		h := models.Heartbeat{Name: r.FormValue("name"), Token: r.FormValue("token"), PeriodSec: period, GraceSec: grace}
+		if err := checks.NormalizeHeartbeat(&h); err != nil {
+			data["error"] = err.Error()
+		} else if _, err := s.createHeartbeat(r.Context(), h); errors.Is(err, errTokenTaken) {
+			data["error"] = err.Error()
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.renderHeartbeats(w, r, data)
+}
+
+func (s *Server) handleHeartbeatsDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err := s.repo.DeleteHeartbeat(r.Context(), id); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.renderHeartbeats(w, r, map[string]any{})
+}
+
+func (s *Server) renderHeartbeats(w http.ResponseWriter, r *http.Request, data map[string]any) {
+	heartbeats, err := s.repo.ListHeartbeats(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	items := make([]api.Heartbeat, 0, len(heartbeats))
+	for _, h := range heartbeats {
+		items = append(items, heartbeatFrom(r, h))
+	}
+	data["heartbeats"] = items
+	_ = s.tpl.ExecuteTemplate(w, "fragment_heartbeats.html", data)
+}
@@ -0,0 +1,47 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dashi/internal/api"
+)
+
+func TestKumaPush(t *testing.T) {
+	s, _ := newTestServer(t, Options{})
+	h := s.Routes()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/v1/heartbeats", `{"name": "backup", "period_sec": 3600, "token": "kumaPushToken1"}`)
+	var hb api.Heartbeat
+	if err := json.Unmarshal(rec.Body.Bytes(), &hb); err != nil || rec.Code != http.StatusCreated ||
+		hb.PushURL != "http://example.com/api/push/kumaPushToken1" || hb.PingURL != "http://example.com/ping/kumaPushToken1" {
+		t.Fatalf("create = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/api/v1/heartbeats", `{"name": "again", "period_sec": 3600, "token": "kumaPushToken1"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate token = %d", rec.Code)
+	}
+
+	if rec := do(http.MethodGet, "/api/push/unknownToken?status=up", ""); rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), `"ok":false`) {
+		t.Fatalf("unknown push = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodGet, "/api/push/kumaPushToken1?status=up&msg=OK&ping=", ""); rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"ok":true}` {
+		t.Fatalf("push = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodGet, "/api/v1/heartbeats", ""); !strings.Contains(rec.Body.String(), `"state": "up"`) {
+		t.Fatalf("after push = %s", rec.Body)
+	}
+	if rec := do(http.MethodPost, "/api/push/kumaPushToken1?status=down&msg=disk+full", ""); rec.Code != http.StatusOK {
+		t.Fatalf("down push = %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodGet, "/api/v1/heartbeats", ""); !strings.Contains(rec.Body.String(), `"state": "failed"`) {
+		t.Fatalf("after down push = %s", rec.Body)
+	}
+}
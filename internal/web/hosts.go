@@ -0,0 +1,112 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/docker"
+	"dashi/internal/models"
+)
+
+// handleHostsFragment renders the all-hosts grid: each Docker host's CPU,
+// memory and disk, or its containers' CPU and memory where dashi does not
+// sample the host, and its firing alerts.
+func (s *Server) handleHostsFragment(w http.ResponseWriter, r *http.Request) {
+	hosts, err := s.repo.ListHosts(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	_ = s.tpl.ExecuteTemplate(w, "fragment_hosts.html", map[string]any{
+		"hosts":   api.HostsFrom(hosts, time.Now()),
+		"metrics": !s.opts.MetricsDisabled,
+		"alerts":  !s.opts.AlertsDisabled,
+	})
+}
+
+// handleHostOverview renders the overview of a host dashi does not sample
+// from its summary.
+func (s *Server) handleHostOverview(w http.ResponseWriter, r *http.Request, host string) {
+	hosts, err := s.repo.ListHosts(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	for _, h := range api.HostsFrom(hosts, time.Now()) {
+		if h.Name == host {
+			_ = s.tpl.ExecuteTemplate(w, "fragment_overview.html", map[string]any{"host": h})
+			return
+		}
+	}
+	http.Error(w, "unknown host", http.StatusNotFound)
+}
+
+// hostNames returns the names of the Docker hosts for the host selector,
+// or nil while there is only the local one.
+func (s *Server) hostNames(r *http.Request) []string {
+	hosts, err := s.repo.ListHosts(r.Context())
+	if err != nil {
+		s.log.Warn("list hosts", "err", err)
+		return nil
+	}
+	if len(hosts) == 1 && hosts[0].Name == docker.LocalHost {
+		return nil
+	}
+	names := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		names = append(names, h.Name)
+	}
+	return names
+}
+
+// queryHost reads the ?host= a page or fragment is scoped to, "" for all.
+func queryHost(r *http.Request) string {
+	return strings.TrimSpace(r.URL.Query().Get("host"))
+}
+
+func (s *Server) handleV1Agents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	agents, err := s.repo.ListAgents(r.Context())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.Agents{Items: api.AgentsFrom(agents, time.Now())})
+}
+
+// handleV1Agent forgets a decommissioned agent, so it no longer shows as
+// offline; one still running registers again with its next heartbeat.
+func (s *Server) handleV1Agent(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, apiV1Prefix+"/agents/")
+	if name == "" || strings.Contains(name, "/") {
+		writeAPIError(w, http.StatusNotFound, "agent not found")
+		return
+	}
+	if r.Method != http.MethodDelete {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	err := s.repo.DeleteAgent(r.Context(), name)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeAPIError(w, http.StatusNotFound, "agent not found")
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.log.Info("agent removed", "agent", name, "source", r.RemoteAddr)
+	event := models.AuditEvent{Action: "agent.delete", Target: name, Source: r.RemoteAddr}
+	if err := s.repo.InsertAudit(context.WithoutCancel(r.Context()), event); err != nil {
+		s.log.Error("write audit event", "action", event.Action, "err", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,108 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/models"
+)
+
+func TestHostScopedDashboard(t *testing.T) {
+	s, repo := newTestServer(t, Options{})
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, c := range []models.Container{
+		{ID: "c1", ServiceID: "web", Name: "web", Status: "running"},
+		{ID: "c2", ServiceID: "db@nas", Host: "nas", Name: "db", Status: "running"},
+		{ID: "c3", ServiceID: "cache@nas", Host: "nas", Name: "cache", Status: "running"},
+	} {
+		svc := models.Service{ID: c.ServiceID, Host: c.Host, Name: c.Name, Image: "img", LabelsJSON: "{}", Status: "running"}
+		if err := repo.UpsertServiceAndContainer(ctx, svc, c); err != nil {
+			t.Fatalf("store %s: %v", c.ID, err)
+		}
+	}
+	err := repo.InsertMetricsBatch(ctx,
+		[]models.HostMetric{{TS: now, CPUPct: 12, MemUsedBytes: 1 << 30, MemTotalBytes: 4 << 30, DiskUsedBytes: 10 << 30, DiskTotalBytes: 100 << 30}},
+		[]models.ContainerMetric{{TS: now, ContainerID: "c1", CPUPct: 5}, {TS: now, ContainerID: "c2", CPUPct: 20, MemUsedBytes: 300 << 20}, {TS: now, ContainerID: "c3", CPUPct: 10, MemUsedBytes: 100 << 20}})
+	if err != nil {
+		t.Fatalf("insert metrics: %v", err)
+	}
+	rules, err := repo.ListRules(ctx)
+	if err != nil || len(rules) == 0 {
+		t.Fatalf("rules = %d, err %v", len(rules), err)
+	}
+	for _, a := range []struct{ target, summary string }{{"c2", "db on nas is busy"}, {"host", "local disk is full"}} {
+		if _, err := repo.CreateAlert(ctx, rules[0].ID, a.target, "firing", a.summary, map[string]any{}, now); err != nil {
+			t.Fatalf("create alert: %v", err)
+		}
+	}
+	h := s.Routes()
+	get := func(path string) string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", path, rec.Code, rec.Body)
+		}
+		return rec.Body.String()
+	}
+
+	page := get("/")
+	if !strings.Contains(page, `<option >nas</option>`) || !strings.Contains(page, `id="hosts"`) {
+		t.Fatalf("index without a host has no host selector or grid")
+	}
+	page = get("/?host=nas")
+	for _, want := range []string{`<option selected>nas</option>`, `/fragments/services?host=nas`, `/fragments/alerts?host=nas`, `name="host" value="nas"`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("index scoped to nas lacks %s", want)
+		}
+	}
+	if strings.Contains(page, `id="hosts"`) {
+		t.Errorf("index scoped to nas shows the all-hosts grid")
+	}
+
+	var hosts api.Hosts
+	if err := json.Unmarshal([]byte(get("/api/v1/hosts")), &hosts); err != nil || len(hosts.Items) != 2 {
+		t.Fatalf("hosts = %+v, err %v", hosts, err)
+	}
+	local, nas := hosts.Items[0], hosts.Items[1]
+	if !local.Sampled || local.CPUPct != 12 || local.DiskTotalBytes != 100<<30 || local.ActiveAlerts != 1 {
+		t.Errorf("local = %+v, want its host sample and one alert", local)
+	}
+	if nas.Sampled || nas.Running != 2 || nas.CPUPct != 30 || nas.MemUsedBytes != 400<<20 || nas.ActiveAlerts != 1 {
+		t.Errorf("nas = %+v, want its containers' sums and one alert", nas)
+	}
+
+	if grid := get("/fragments/hosts"); !strings.Contains(grid, `href="/?host=nas"`) || !strings.Contains(grid, "n/a") {
+		t.Errorf("hosts grid does not link nas or mark its disk unknown: %s", grid)
+	}
+	if overview := get("/fragments/overview?host=nas"); !strings.Contains(overview, "30.0%") {
+		t.Errorf("nas overview does not show its containers' CPU: %s", overview)
+	}
+	alerts := get("/fragments/alerts?host=nas")
+	if !strings.Contains(alerts, "db on nas is busy") || strings.Contains(alerts, "local disk is full") {
+		t.Errorf("alerts scoped to nas: %s", alerts)
+	}
+	if services := get("/fragments/services?host=nas"); !strings.Contains(services, "<td>db") || strings.Contains(services, "<td>web") {
+		t.Errorf("services scoped to nas: %s", services)
+	}
+
+	// Other instances federate this one through its summary.
+	var sum api.Summary
+	if err := json.Unmarshal([]byte(get("/api/v1/summary")), &sum); err != nil || len(sum.Hosts) != 2 || sum.FiringAlerts != 2 || len(sum.TopAlerts) != 2 {
+		t.Fatalf("summary = %+v, err %v", sum, err)
+	}
+	raw, _ := json.Marshal(sum)
+	if err := repo.SaveSite(ctx, models.Site{Name: "berlin", URL: "https://berlin.example.com", CheckedAt: now, LastOKAt: &now, SummaryJSON: string(raw)}); err != nil {
+		t.Fatalf("save site: %v", err)
+	}
+	if sites := get("/fragments/sites"); !strings.Contains(sites, "berlin") || !strings.Contains(sites, "2 firing") || !strings.Contains(sites, "db on nas is busy") {
+		t.Errorf("sites fragment: %s", sites)
+	}
+}
@@ -0,0 +1,444 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/db"
+	"dashi/internal/docker"
+	"dashi/internal/gelf"
+	"dashi/internal/logs"
+	"dashi/internal/models"
+	"dashi/internal/otlp"
+)
+
+const (
+	// ingestKind is the pseudo host of sources pushing logs over HTTP.
+	ingestKind       = "ingest"
+	maxIngestEntries = 1000
+	maxIngestBytes   = 4 << 20
+	// maxIngestSkew is how far the timestamp of a signed push may be off.
+	maxIngestSkew = 5 * time.Minute
+	// agentMissedBeats is how many heartbeats an agent may miss before it
+	// is offline, at least minAgentGrace after its last one.
+	agentMissedBeats = 3
+	minAgentGrace    = time.Minute
+)
+
+var (
+	ingestSource = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,99}$`)
+	ingestStream = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+)
+
+// handleIngestLogs stores a batch of log lines pushed by scripts and
+// services outside Docker. Callers authenticate with the ingest token as a
+// bearer token; without a configured token the endpoint does not exist.
+func (s *Server) handleIngestLogs(w http.ResponseWriter, r *http.Request) {
+	if s.opts.LogSink == nil {
+		writeAPIError(w, http.StatusNotFound, "log ingestion is not enabled")
+		return
+	}
+	var in api.IngestLogs
+	call, ok := s.ingestRequest(w, r, &in)
+	if !ok {
+		return
+	}
+	entries, err := ingestEntries(in, time.Now().UTC())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	type ids struct{ service, container string }
+	sources := map[string]ids{}
+	// services maps the containers agents name to their service, "" for
+	// unknown ones and, for a signed push, those of other hosts.
+	services := map[string]string{}
+	var res api.IngestResult
+	for i, e := range entries {
+		if e.ContainerID != "" {
+			svc, ok := services[e.ContainerID]
+			if !ok {
+				var err error
+				svc, err = s.repo.ContainerService(r.Context(), e.ContainerID)
+				if err != nil && !errors.Is(err, sql.ErrNoRows) {
+					writeAPIError(w, http.StatusInternalServerError, err.Error())
+					return
+				}
+				if svc != "" && call.agent != "" {
+					host, err := s.repo.ContainerHost(r.Context(), e.ContainerID)
+					if err != nil {
+						writeAPIError(w, http.StatusInternalServerError, err.Error())
+						return
+					}
+					if host != call.agent {
+						svc = ""
+					}
+				}
+				services[e.ContainerID] = svc
+			}
+			entries[i].ServiceID = svc
+			continue
+		}
+		src, ok := sources[e.source]
+		if !ok {
+			svc, cid, err := s.opts.LogSink.Source(r.Context(), logs.Source{Kind: ingestKind, Name: e.source})
+			if err != nil {
+				writeAPIError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			src = ids{svc, cid}
+			sources[e.source] = src
+		}
+		entries[i].ServiceID, entries[i].ContainerID = src.service, src.container
+	}
+	for _, e := range entries {
+		if e.ServiceID == "" {
+			res.Skipped++
+			continue
+		}
+		s.opts.LogSink.Write(e.LogEntry)
+		res.Accepted++
+	}
+	s.writeIngestResult(w, r, call, res)
+}
+
+// handleIngestAgent records an agent's heartbeat, registering the agent on
+// its first one.
+func (s *Server) handleIngestAgent(w http.ResponseWriter, r *http.Request) {
+	var in api.AgentHeartbeat
+	call, ok := s.ingestRequest(w, r, &in)
+	if !ok || !s.checkAgentHost(w, call, in.Host) {
+		return
+	}
+	if in.IntervalSec <= 0 || in.IntervalSec > 86400 {
+		writeAPIError(w, http.StatusBadRequest, "interval_sec must be between 1 and 86400")
+		return
+	}
+	now := time.Now().UTC()
+	a := models.Agent{
+		Host:            in.Host,
+		OS:              in.OS,
+		Arch:            in.Arch,
+		Kernel:          in.Kernel,
+		DockerVersion:   in.DockerVersion,
+		IntervalSec:     in.IntervalSec,
+		RemoteAddr:      r.RemoteAddr,
+		LastHeartbeatAt: now,
+		Deadline:        now.Add(max(agentMissedBeats*time.Duration(in.IntervalSec)*time.Second, minAgentGrace)),
+	}
+	prev, err := s.repo.RecordAgentHeartbeat(r.Context(), a)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	switch {
+	case prev.IsZero():
+		s.log.Info("agent registered", "agent", a.Host, "os", a.OS, "arch", a.Arch, "docker_version", a.DockerVersion, "source", r.RemoteAddr)
+	case now.After(prev):
+		s.log.Info("agent back online", "agent", a.Host, "offline_for", now.Sub(prev).Round(time.Second))
+	}
+	s.writeIngestResult(w, r, call, api.IngestResult{Accepted: 1})
+}
+
+// handleIngestContainers stores the services and containers an agent sees
+// on its Docker host and marks the host's other containers missing.
+func (s *Server) handleIngestContainers(w http.ResponseWriter, r *http.Request) {
+	var in api.IngestContainers
+	call, ok := s.ingestRequest(w, r, &in)
+	if !ok || !s.checkAgentHost(w, call, in.Host) {
+		return
+	}
+	var seen []string
+	for _, d := range in.Services {
+		if !strings.HasSuffix(d.ID, "@"+in.Host) {
+			writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("service %q is not on host %s", d.ID, in.Host))
+			return
+		}
+		d.Host = in.Host
+		svc, containers := d.Model()
+		for _, c := range containers {
+			if c.ID == "" {
+				writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("service %q: container id is required", d.ID))
+				return
+			}
+			if err := s.repo.UpsertServiceAndContainer(r.Context(), svc, c); errors.Is(err, db.ErrContainerConflict) {
+				writeAPIError(w, http.StatusConflict, err.Error())
+				return
+			} else if err != nil {
+				writeAPIError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			seen = append(seen, c.ID)
+		}
+	}
+	if err := s.repo.MarkMissingContainers(r.Context(), in.Host, seen); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.writeIngestResult(w, r, call, api.IngestResult{Accepted: len(seen)})
+}
+
+// handleIngestMetrics stores container samples an agent collected. Every
+// container has to be one the agent pushed for its host.
+func (s *Server) handleIngestMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.opts.MetricsDisabled {
+		writeAPIError(w, http.StatusNotFound, "metric collection is disabled")
+		return
+	}
+	var in api.IngestMetrics
+	call, ok := s.ingestRequest(w, r, &in)
+	if !ok || !s.checkAgentHost(w, call, in.Host) {
+		return
+	}
+	if len(in.Containers) > maxIngestEntries {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("at most %d samples per batch", maxIngestEntries))
+		return
+	}
+	known := map[string]bool{}
+	var res api.IngestResult
+	samples := make([]models.ContainerMetric, 0, len(in.Containers))
+	for _, m := range in.Containers {
+		ok, checked := known[m.ContainerID]
+		if !checked {
+			host, err := s.repo.ContainerHost(r.Context(), m.ContainerID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				writeAPIError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			ok = err == nil && host == in.Host
+			known[m.ContainerID] = ok
+		}
+		if !ok || m.TS.IsZero() {
+			res.Skipped++
+			continue
+		}
+		samples = append(samples, m.Model())
+	}
+	if err := s.repo.InsertMetricsBatch(r.Context(), nil, samples); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	res.Accepted = len(samples)
+	s.writeIngestResult(w, r, call, res)
+}
+
+// ingestCall is an authenticated push.
+type ingestCall struct {
+	// agent is the host a signed push is for, "" for a push with the
+	// ingest token.
+	agent string
+	// key is the idempotency key scoped to the caller and endpoint, ""
+	// without one.
+	key string
+}
+
+// ingestRequest authenticates a push and decodes its JSON body into in.
+func (s *Server) ingestRequest(w http.ResponseWriter, r *http.Request, in any) (ingestCall, bool) {
+	call, body, ok := s.ingestBody(w, r)
+	if !ok {
+		return call, false
+	}
+	if err := json.Unmarshal(body, in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid batch: "+err.Error())
+		return call, false
+	}
+	return call, true
+}
+
+// ingestBody authenticates a push and returns its body, which may be
+// gzip-compressed. Agents sign their pushes (see api.Sign) with
+// their token from AgentTokens or else the ingest token; other callers send
+// the ingest token as a bearer token. A push repeating an idempotency key
+// is answered with the first response and not handled again. Without any
+// token the ingest endpoints do not exist.
+func (s *Server) ingestBody(w http.ResponseWriter, r *http.Request) (ingestCall, []byte, bool) {
+	var call ingestCall
+	if s.opts.IngestToken == "" && len(s.opts.AgentTokens) == 0 {
+		writeAPIError(w, http.StatusNotFound, "ingestion is not enabled")
+		return call, nil, false
+	}
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return call, nil, false
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxIngestBytes+1))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "read batch: "+err.Error())
+		return call, nil, false
+	}
+	if len(body) > maxIngestBytes {
+		writeAPIError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("batches are limited to %d bytes", maxIngestBytes))
+		return call, nil, false
+	}
+	key := r.Header.Get(api.IdempotencyHeader)
+	if len(key) > 200 {
+		writeAPIError(w, http.StatusBadRequest, "idempotency key is longer than 200 characters")
+		return call, nil, false
+	}
+	if agent := r.Header.Get(api.AgentHeader); agent != "" {
+		token, ok := s.opts.AgentTokens[agent]
+		if !ok {
+			token = s.opts.IngestToken
+		}
+		ts, err := strconv.ParseInt(r.Header.Get(api.TimestampHeader), 10, 64)
+		skew := time.Since(time.Unix(ts, 0))
+		if token == "" || err != nil || skew > maxIngestSkew || skew < -maxIngestSkew ||
+			!api.ValidSignature(token, r.Header.Get(api.SignatureHeader), ts, r.Method, r.URL.Path, key, body) {
+			writeAPIError(w, http.StatusUnauthorized, "invalid agent signature; check the agent's token and clock")
+			return call, nil, false
+		}
+		call.agent = agent
+	} else if !s.validIngestToken(r, body) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="dashi"`)
+		writeAPIError(w, http.StatusUnauthorized, "invalid ingest token")
+		return call, nil, false
+	}
+	if key != "" {
+		call.key = call.agent + " " + r.URL.Path + " " + key
+		res, seen, err := s.repo.IngestResult(r.Context(), call.key)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return call, nil, false
+		}
+		if seen {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = io.WriteString(w, res)
+			return call, nil, false
+		}
+	}
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid gzip body: "+err.Error())
+			return call, nil, false
+		}
+		if body, err = io.ReadAll(io.LimitReader(zr, maxIngestBytes+1)); err != nil || len(body) > maxIngestBytes {
+			writeAPIError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("batches are limited to %d bytes uncompressed", maxIngestBytes))
+			return call, nil, false
+		}
+	}
+	return call, body, true
+}
+
+// validIngestToken checks the ingest token of an unsigned push: a bearer
+// token, or for webhooks, which cannot send one, GitLab's X-Gitlab-Token
+// header or GitHub's X-Hub-Signature-256 made with it as the secret.
+func (s *Server) validIngestToken(r *http.Request, body []byte) bool {
+	if s.opts.IngestToken == "" {
+		return false
+	}
+	want := []byte(s.opts.IngestToken)
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return subtle.ConstantTimeCompare([]byte(token), want) == 1
+	}
+	if token := r.Header.Get("X-Gitlab-Token"); token != "" {
+		return subtle.ConstantTimeCompare([]byte(token), want) == 1
+	}
+	if sig, ok := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256="); ok {
+		mac := hmac.New(sha256.New, want)
+		mac.Write(body)
+		return subtle.ConstantTimeCompare([]byte(sig), []byte(hex.EncodeToString(mac.Sum(nil)))) == 1
+	}
+	return false
+}
+
+// checkAgentHost rejects host names an agent may not push under: those of
+// the server's own Docker hosts and of the pseudo hosts of log sources.
+// Signed pushes are limited to the agent's host, and hosts with their own
+// token accept only signed pushes.
+func (s *Server) checkAgentHost(w http.ResponseWriter, call ingestCall, host string) bool {
+	if !ingestSource.MatchString(host) {
+		writeAPIError(w, http.StatusBadRequest, "host must be 1-100 letters, digits, '.', '_' or '-'")
+		return false
+	}
+	_, own := s.opts.DockerHosts[host]
+	if own || host == docker.LocalHost || host == ingestKind || host == gelf.Kind || host == logs.FileKind || host == otlp.Kind {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("host %s is not an agent's", host))
+		return false
+	}
+	if _, signed := s.opts.AgentTokens[host]; (call.agent != "" && host != call.agent) || (call.agent == "" && signed) {
+		writeAPIError(w, http.StatusForbidden, fmt.Sprintf("only agent %s may push for host %s", host, host))
+		return false
+	}
+	return true
+}
+
+// writeIngestResult answers a push and remembers the answer for its
+// idempotency key.
+func (s *Server) writeIngestResult(w http.ResponseWriter, r *http.Request, call ingestCall, res api.IngestResult) {
+	raw, _ := json.Marshal(res)
+	if call.key != "" {
+		if err := s.repo.SaveIngestResult(r.Context(), call.key, string(raw), time.Now()); err != nil {
+			s.log.Warn("save ingest idempotency key", "err", err)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write(raw)
+}
+
+// ingestEntry is a pushed line before its source is resolved.
+type ingestEntry struct {
+	source string
+	models.LogEntry
+}
+
+// ingestEntries validates a batch, rejecting all of it on the first invalid
+// entry.
+func ingestEntries(in api.IngestLogs, now time.Time) ([]ingestEntry, error) {
+	if len(in.Entries) == 0 {
+		return nil, fmt.Errorf("entries are required")
+	}
+	if len(in.Entries) > maxIngestEntries {
+		return nil, fmt.Errorf("at most %d entries per batch", maxIngestEntries)
+	}
+	out := make([]ingestEntry, 0, len(in.Entries))
+	for i, e := range in.Entries {
+		source := strings.TrimSpace(e.Source)
+		if source == "" {
+			source = strings.TrimSpace(in.Source)
+		}
+		if e.ContainerID == "" && !ingestSource.MatchString(source) {
+			return nil, fmt.Errorf("entry %d: source must be 1-100 letters, digits, '.', '_' or '-'", i+1)
+		}
+		if strings.TrimSpace(e.Message) == "" {
+			return nil, fmt.Errorf("entry %d: message is required", i+1)
+		}
+		stream := strings.ToLower(strings.TrimSpace(e.Stream))
+		if stream == "" {
+			stream = ingestKind
+		}
+		if !ingestStream.MatchString(stream) {
+			return nil, fmt.Errorf("entry %d: invalid stream %q", i+1, e.Stream)
+		}
+		ts := now
+		if e.TS != nil {
+			ts = e.TS.UTC()
+		}
+		out = append(out, ingestEntry{source: source, LogEntry: models.LogEntry{
+			TS:          ts,
+			ContainerID: e.ContainerID,
+			Stream:      stream,
+			Level:       logs.ParseLevel(e.Level, e.Message),
+			Message:     e.Message,
+			Fields:      e.Fields,
+		}})
+	}
+	return out, nil
+}
@@ -0,0 +1,164 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/db"
+	"dashi/internal/logs"
+)
+
+func TestIngestLogs(t *testing.T) {
+	s, repo := newTestServer(t, Options{IngestToken: "secret"})
+	// Serialize the sink's writers, which run per container and drop a
+	// locked batch without a spill directory.
+	repo.DB().SetMaxOpenConns(1)
+	sink := logs.NewSink(repo, s.log, logs.Options{})
+	s.opts.LogSink = sink
+	h := s.Routes()
+
+	batch := `{"source": "backup", "entries": [
+		{"ts": "2026-01-02T03:04:05Z", "level": "error", "message": "rsync failed"},
+		{"message": "cleanup warning: disk almost full", "source": "cron"}]}`
+	cases := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+	}{
+		{"no token", "", batch, http.StatusUnauthorized},
+		{"wrong token", "nope", batch, http.StatusUnauthorized},
+		{"missing source", "secret", `{"entries": [{"message": "x"}]}`, http.StatusBadRequest},
+		{"invalid source", "secret", `{"source": "a b", "entries": [{"message": "x"}]}`, http.StatusBadRequest},
+		{"empty message", "secret", `{"source": "a", "entries": [{"message": " "}]}`, http.StatusBadRequest},
+		{"accepted", "secret", batch, http.StatusAccepted},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/api/ingest/logs", strings.NewReader(tc.body))
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Fatalf("%s: status = %d, want %d (%s)", tc.name, rec.Code, tc.status, rec.Body)
+		}
+	}
+	ctx := context.Background()
+	if err := sink.Stop(ctx); err != nil {
+		t.Fatalf("stop sink: %v", err)
+	}
+
+	got, err := repo.QueryLogs(ctx, db.LogQuery{Stream: "ingest"})
+	if err != nil {
+		t.Fatalf("query logs: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("stored %d entries, want 2: %+v", len(got), got)
+	}
+	for _, e := range got {
+		switch e.ServiceID {
+		case "backup@ingest":
+			if e.Level != "ERROR" || e.TS.Year() != 2026 || e.ContainerID != "ingest:backup" {
+				t.Fatalf("backup entry = %+v", e)
+			}
+		case "cron@ingest":
+			if e.Level != "WARN" {
+				t.Fatalf("cron entry level = %s, want inferred WARN", e.Level)
+			}
+		default:
+			t.Fatalf("unexpected entry %+v", e)
+		}
+	}
+}
+
+func TestIngestLogsDisabledWithoutToken(t *testing.T) {
+	s := NewServer(nil, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)), Options{})
+	rec := httptest.NewRecorder()
+	s.handleIngestLogs(rec, httptest.NewRequest(http.MethodPost, "/api/ingest/logs", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestIngestSignedAgentPushes(t *testing.T) {
+	s, repo := newTestServer(t, Options{IngestToken: "shared", AgentTokens: map[string]string{"nas": "nas-token"}})
+	h := s.Routes()
+
+	push := func(path, agent, token, key, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if agent != "" {
+			ts := time.Now().Unix()
+			req.Header.Set(api.AgentHeader, agent)
+			req.Header.Set(api.TimestampHeader, strconv.FormatInt(ts, 10))
+			req.Header.Set(api.IdempotencyHeader, key)
+			req.Header.Set(api.SignatureHeader, api.Sign(token, ts, http.MethodPost, path, key, []byte(body)))
+		} else {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	containers := func(host string) string {
+		return `{"host": "` + host + `", "services": [{"id": "web@` + host + `", "name": "web", "containers": [{"id": "c-` + host + `", "name": "web-1", "status": "running"}]}]}`
+	}
+	cases := []struct {
+		name, agent, token, host string
+		status                   int
+	}{
+		{"signed by its agent", "nas", "nas-token", "nas", http.StatusAccepted},
+		{"wrong key", "nas", "shared", "nas", http.StatusUnauthorized},
+		{"another agent's host", "nas", "nas-token", "pi", http.StatusForbidden},
+		{"shared token for a host with its own", "", "shared", "nas", http.StatusForbidden},
+		{"agent without its own token", "pi", "shared", "pi", http.StatusAccepted},
+	}
+	for _, tc := range cases {
+		if rec := push("/api/ingest/containers", tc.agent, tc.token, "", containers(tc.host)); rec.Code != tc.status {
+			t.Fatalf("%s: status = %d, want %d (%s)", tc.name, rec.Code, tc.status, rec.Body)
+		}
+	}
+
+	// A batch pushed again with its key is answered without storing it twice.
+	sample := `{"host": "nas", "containers": [{"ts": "` + time.Now().UTC().Format(time.RFC3339) + `", "container_id": "c-nas", "cpu_pct": 12}]}`
+	for range 2 {
+		if rec := push("/api/ingest/metrics", "nas", "nas-token", "batch-1", sample); rec.Code != http.StatusAccepted || !strings.Contains(rec.Body.String(), `"accepted":1`) {
+			t.Fatalf("metrics push = %d %s", rec.Code, rec.Body)
+		}
+	}
+	metrics, err := repo.RecentContainerMetrics(context.Background(), "c-nas", time.Now().Add(-time.Hour), 10)
+	if err != nil || len(metrics) != 1 {
+		t.Fatalf("stored %d samples, err %v, want 1", len(metrics), err)
+	}
+
+	// Heartbeats register agents, which can be forgotten again.
+	if rec := push("/api/ingest/agent", "nas", "nas-token", "", `{"host": "nas", "os": "Debian", "arch": "arm64"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("heartbeat without an interval = %d, want 400", rec.Code)
+	}
+	if rec := push("/api/ingest/agent", "nas", "nas-token", "", `{"host": "nas", "os": "Debian", "arch": "arm64", "docker_version": "27.1", "interval_sec": 10}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("heartbeat = %d %s", rec.Code, rec.Body)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/agents", nil))
+	var agents api.Agents
+	if err := json.Unmarshal(rec.Body.Bytes(), &agents); err != nil || len(agents.Items) != 1 || !agents.Items[0].Online || agents.Items[0].DockerVersion != "27.1" {
+		t.Fatalf("agents = %s, err %v", rec.Body, err)
+	}
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/v1/agents/nas", nil))
+		if rec.Code != want {
+			t.Fatalf("delete agent = %d, want %d", rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package web
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/selfmon"
+)
+
+// handleInternals shows dashi's own health: the latest self metric sample
+// and those of the last hour, newest first.
+func (s *Server) handleInternals(w http.ResponseWriter, r *http.Request) {
+	samples, err := s.repo.RecentSelfMetrics(r.Context(), time.Now().Add(-time.Hour), 1024)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slices.Reverse(samples)
+	data := map[string]any{"samples": samples}
+	if len(samples) > 0 {
+		data["latest"] = samples[0]
+	}
+	if s.opts.LogSpill != nil {
+		data["spill"] = true
+		data["spillBatches"], data["spillBytes"] = s.opts.LogSpill.Pending()
+	}
+	if s.opts.LogBudget != nil {
+		data["budget"] = true
+		data["budgetUsed"], data["budgetMax"] = s.opts.LogBudget.Used()
+	}
+	data["overflows"] = s.overflows(r.Context())
+	_ = s.tpl.ExecuteTemplate(w, "internals.html", data)
+}
+
+func (s *Server) handleV1Internals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	rng := parseRange(r.URL.Query().Get("range"))
+	samples, err := s.repo.RecentSelfMetrics(r.Context(), time.Now().Add(-rng), 4096)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.SelfMetrics{Range: rng.String(), Items: api.SelfMetricsFrom(samples)})
+}
+
+// overflow is the number of pushed log lines of a container dropped under
+// backpressure since dashi started.
+type overflow struct {
+	ContainerID string
+	Name        string
+	Lines       int64
+}
+
+// overflows lists the containers that had log lines dropped, most first.
+func (s *Server) overflows(ctx context.Context) []overflow {
+	counts := selfmon.Overflows()
+	if len(counts) == 0 {
+		return nil
+	}
+	names := map[string]string{}
+	if containers, err := s.repo.ListContainers(ctx); err == nil {
+		for _, c := range containers {
+			names[c.ID] = c.Name
+		}
+	}
+	out := make([]overflow, 0, len(counts))
+	for id, n := range counts {
+		out = append(out, overflow{ContainerID: id, Name: names[id], Lines: n})
+	}
+	slices.SortFunc(out, func(a, b overflow) int { return cmp.Compare(b.Lines, a.Lines) })
+	return out
+}
+
+// recentLogOverflow returns the pushed log lines dropped in the last hour,
+// for the dashboard warning.
+func (s *Server) recentLogOverflow(ctx context.Context) int64 {
+	samples, err := s.repo.RecentSelfMetrics(ctx, time.Now().Add(-time.Hour), 1024)
+	if err != nil {
+		return 0
+	}
+	var n int64
+	for _, m := range samples {
+		n += m.LogOverflow
+	}
+	return n
+}
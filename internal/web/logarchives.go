@@ -0,0 +1,70 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+)
+
+// handleV1LogArchives lists the archived log segments, optionally of one
+// service and of days between from and to (YYYY-MM-DD).
+func (s *Server) handleV1LogArchives(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.opts.LogArchive == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, "log archival is not configured")
+		return
+	}
+	q := r.URL.Query()
+	for _, k := range []string{"from", "to"} {
+		if v := q.Get(k); v != "" {
+			if _, err := time.Parse(time.DateOnly, v); err != nil {
+				writeAPIError(w, http.StatusBadRequest, k+" must be a day such as 2026-01-31")
+				return
+			}
+		}
+	}
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	segments, err := s.repo.LogArchives(r.Context(), q.Get("from"), q.Get("to"), q.Get("service"), min(limit, 1000))
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.LogArchives{Items: api.LogArchivesFrom(segments)})
+}
+
+// handleV1LogArchiveRestore serves POST /api/v1/logs/archives/{id}/restore,
+// storing an archived segment's lines again.
+func (s *Server) handleV1LogArchiveRestore(w http.ResponseWriter, r *http.Request) {
+	rest, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, apiV1Prefix+"/logs/archives/"), "/restore")
+	id, err := strconv.ParseInt(rest, 10, 64)
+	if !ok || err != nil {
+		writeAPIError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.opts.LogArchive == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, "log archival is not configured")
+		return
+	}
+	restored, skipped, err := s.opts.LogArchive.Restore(r.Context(), id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeAPIError(w, http.StatusNotFound, "archive segment not found")
+	case err != nil:
+		s.log.Error("restore log archive", "id", id, "err", err)
+		writeAPIError(w, http.StatusBadGateway, err.Error())
+	default:
+		writeJSON(w, api.LogArchiveRestore{ID: id, Restored: restored, Skipped: skipped})
+	}
+}
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+// histogramSteps are the bucket widths a log histogram picks from, so
+// buckets start on round times.
+var histogramSteps = []time.Duration{
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 15 * time.Second, 30 * time.Second,
+	time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 2 * time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+}
+
+// histogramStep returns the smallest of the histogramSteps splitting rng
+// into at most buckets buckets, whole days beyond them.
+func histogramStep(rng time.Duration, buckets int) time.Duration {
+	want := rng / time.Duration(buckets)
+	for _, step := range histogramSteps {
+		if step >= want {
+			return step
+		}
+	}
+	days := (want + 24*time.Hour - 1) / (24 * time.Hour)
+	return days * 24 * time.Hour
+}
+
+// logHistogram counts the lines matching lq between from and to in at most
+// buckets buckets, and returns them all, the empty ones included, with
+// their width.
+func (s *Server) logHistogram(ctx context.Context, lq db.LogQuery, from, to time.Time, buckets int) ([]models.LogBucket, time.Duration, error) {
+	step := histogramStep(to.Sub(from), buckets)
+	start := from.Truncate(step)
+	lq.From, lq.To = &start, &to
+	counted, err := s.repo.LogHistogram(ctx, lq, step)
+	if err != nil {
+		return nil, 0, err
+	}
+	var out []models.LogBucket
+	for ts := start; ts.Before(to); ts = ts.Add(step) {
+		if len(counted) > 0 && counted[0].TS.Equal(ts) {
+			out = append(out, counted[0])
+			counted = counted[1:]
+			continue
+		}
+		out = append(out, models.LogBucket{TS: ts})
+	}
+	return out, step, nil
+}
+
+// histogramWindow is the window of a histogram request: the log query's
+// bounds, defaulting to the last hour up to now.
+func histogramWindow(lq db.LogQuery) (from, to time.Time) {
+	to = time.Now().UTC()
+	if lq.To != nil {
+		to = *lq.To
+	}
+	from = to.Add(-time.Hour)
+	if lq.From != nil {
+		from = *lq.From
+	}
+	return from, to
+}
+
+// handleV1LogHistogram serves GET /api/v1/logs/histogram: the volume of the
+// lines /api/v1/logs would return for the same filters, over time.
+func (s *Server) handleV1LogHistogram(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	f := logFiltersFromQuery(r)
+	lq, err := logQueryFrom(r, f)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	from, to := histogramWindow(lq)
+	if !from.Before(to) {
+		writeAPIError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	buckets := 60
+	if n, err := strconv.Atoi(r.URL.Query().Get("buckets")); err == nil && n > 0 {
+		buckets = min(n, 500)
+	}
+	counts, step, err := s.logHistogram(r.Context(), lq, from, to, buckets)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.LogHistogram{Filters: f, From: from, To: to, StepSec: int64(step.Seconds()), Buckets: api.LogBucketsFrom(counts)})
+}
+
+// histogramBar is a bar of the logs view's histogram, errors highlighted,
+// which zooms the view into its bucket when clicked.
+type histogramBar struct {
+	logVolumeBar
+	Zoom string
+}
+
+// handleLogsHistogramFragment charts the volume of the logs fragment's
+// lines above its entries.
+func (s *Server) handleLogsHistogramFragment(w http.ResponseWriter, r *http.Request) {
+	data := map[string]any{}
+	lq, err := logQueryFrom(r, logFiltersFromQuery(r))
+	if err != nil {
+		data["error"] = err.Error()
+		_ = s.tpl.ExecuteTemplate(w, "fragment_log_histogram.html", data)
+		return
+	}
+	from, to := histogramWindow(lq)
+	counts, step, err := s.logHistogram(r.Context(), lq, from, to, logVolumeBars)
+	if err != nil {
+		data["error"] = err.Error()
+		_ = s.tpl.ExecuteTemplate(w, "fragment_log_histogram.html", data)
+		return
+	}
+	var peak int64
+	for _, b := range counts {
+		peak = max(peak, b.Total)
+	}
+	width := 600 / float64(len(counts))
+	bars := make([]histogramBar, 0, len(counts))
+	for i, b := range counts {
+		q := r.URL.Query()
+		q.Del("range")
+		q.Del("follow")
+		q.Set("from", b.TS.Format(time.RFC3339))
+		q.Set("to", b.TS.Add(step).Format(time.RFC3339))
+		bar := histogramBar{Zoom: "/fragments/logs?" + q.Encode()}
+		bar.X = float64(i) * width
+		bar.Label = fmt.Sprintf("%s: %d lines, %d errors", b.TS.Format("Jan 2 15:04:05"), b.Total, b.Levels["ERROR"])
+		if peak > 0 {
+			bar.Height = 100 * float64(b.Total) / float64(peak)
+			bar.ErrorHeight = 100 * float64(b.Levels["ERROR"]) / float64(peak)
+		}
+		bar.Y, bar.ErrorY = 100-bar.Height, 100-bar.ErrorHeight
+		bars = append(bars, bar)
+	}
+	data["bars"], data["barWidth"], data["peak"], data["step"] = bars, width*0.9, peak, step
+	data["from"], data["to"] = from, to
+	_ = s.tpl.ExecuteTemplate(w, "fragment_log_histogram.html", data)
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/models"
+)
+
+func TestLogHistogram(t *testing.T) {
+	s, repo := newTestServer(t, Options{})
+	ctx := context.Background()
+	svc := models.Service{ID: "web", Name: "web", Image: "img", LabelsJSON: "{}", Status: "running"}
+	if err := repo.UpsertServiceAndContainer(ctx, svc, models.Container{ID: "c1", ServiceID: "web", Name: "web", Status: "running"}); err != nil {
+		t.Fatalf("store container: %v", err)
+	}
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	entries := []models.LogEntry{
+		{TS: start.Add(30 * time.Second), Level: "INFO", Message: "GET /"},
+		{TS: start.Add(10 * time.Minute), Level: "INFO", Message: "GET /health"},
+		{TS: start.Add(10*time.Minute + time.Second), Level: "ERROR", Message: "upstream timed out"},
+		{TS: start.Add(10*time.Minute + 2*time.Second), Level: "ERROR", Message: "upstream timed out again"},
+		{TS: start.Add(2 * time.Hour), Level: "INFO", Message: "outside the window"},
+	}
+	for i := range entries {
+		entries[i].ServiceID, entries[i].ContainerID, entries[i].Stream = "web", "c1", "stdout"
+	}
+	if err := repo.InsertLogs(ctx, entries); err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+	// Repeats are folded into repeat_count as they would be on ingestion.
+	if _, err := repo.DB().Exec(`UPDATE logs SET repeat_count=3 WHERE message='upstream timed out'`); err != nil {
+		t.Fatalf("set repeats: %v", err)
+	}
+	h := s.Routes()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	window := "from=" + start.Format(time.RFC3339) + "&to=" + start.Add(time.Hour).Format(time.RFC3339)
+
+	rec := get("/api/v1/logs/histogram?service=web&" + window)
+	var out api.LogHistogram
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("histogram = %d %s", rec.Code, rec.Body)
+	}
+	if out.StepSec != 60 || len(out.Buckets) != 60 || !out.Buckets[0].TS.Equal(start) {
+		t.Fatalf("buckets = %d of %ds from %v", len(out.Buckets), out.StepSec, out.Buckets[0].TS)
+	}
+	if b := out.Buckets[0]; b.Total != 1 || b.Levels["INFO"] != 1 {
+		t.Fatalf("first bucket = %+v", b)
+	}
+	if b := out.Buckets[10]; b.Total != 5 || b.Levels["ERROR"] != 4 || b.Levels["INFO"] != 1 {
+		t.Fatalf("spike bucket = %+v", b)
+	}
+	if b := out.Buckets[11]; b.Total != 0 || b.Levels == nil {
+		t.Fatalf("empty bucket = %+v", b)
+	}
+
+	rec = get("/api/v1/logs/histogram?level=error&buckets=6&" + window)
+	out = api.LogHistogram{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || out.StepSec != 600 || len(out.Buckets) != 6 || out.Buckets[1].Total != 4 || out.Buckets[0].Total != 0 {
+		t.Fatalf("error histogram = %d %s", rec.Code, rec.Body)
+	}
+	if rec := get("/api/v1/logs/histogram?from=noon"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid from = %d", rec.Code)
+	}
+
+	// Clicking the spike zooms the logs view into its minute.
+	rec = get("/fragments/logs/histogram?service=web&follow=1&" + window)
+	zoom := "/fragments/logs?from=" + strings.ReplaceAll(start.Add(10*time.Minute).Format(time.RFC3339), ":", "%3A")
+	if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, zoom) || strings.Contains(body, "follow=") {
+		t.Fatalf("histogram fragment = %d %s", rec.Code, body)
+	}
+	rec = get("/fragments/logs?service=web&from=" + start.Add(10*time.Minute).Format(time.RFC3339) + "&to=" + start.Add(11*time.Minute).Format(time.RFC3339))
+	if body := rec.Body.String(); strings.Count(body, "upstream timed out") != 2 || strings.Contains(body, "12:00:30") || !strings.Contains(body, "/fragments/logs/histogram?") {
+		t.Fatalf("zoomed logs = %d %s", rec.Code, body)
+	}
+}
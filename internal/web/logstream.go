@@ -0,0 +1,221 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/db"
+	"dashi/internal/models"
+)
+
+const (
+	// logStreamPing is how often an idle stream sends a comment, which
+	// keeps proxies from closing it.
+	logStreamPing = 15 * time.Second
+	// labelRefresh limits how often the service labels are reloaded for a
+	// service the stream has not seen before.
+	labelRefresh = 10 * time.Second
+)
+
+// logMatcher applies the logs view filters to live entries, as logWhere
+// does in SQL.
+type logMatcher struct {
+	services map[string]bool
+	host     string
+	level    string
+	stream   string
+	query    string
+	fields   map[string]string
+	labels   db.LabelSelector
+
+	repo     *db.Repository
+	matched  map[string]bool
+	loadedAt time.Time
+}
+
+func newLogMatcher(repo *db.Repository, r *http.Request) (*logMatcher, error) {
+	q := r.URL.Query()
+	labels, err := queryLabels(r)
+	if err != nil {
+		return nil, err
+	}
+	fields := queryFields(r)
+	if err := validateFields(fields); err != nil {
+		return nil, err
+	}
+	m := &logMatcher{
+		host:   strings.TrimSpace(q.Get("host")),
+		level:  strings.ToUpper(q.Get("level")),
+		stream: strings.ToLower(q.Get("stream")),
+		query:  strings.ToLower(q.Get("q")),
+		fields: fields,
+		labels: labels,
+		repo:   repo,
+	}
+	if services := splitServices(q.Get("service")); len(services) > 0 {
+		m.services = map[string]bool{}
+		for _, id := range services {
+			m.services[id] = true
+		}
+	}
+	return m, nil
+}
+
+func (m *logMatcher) match(ctx context.Context, e models.LogEntry) bool {
+	if m.services != nil && !m.services[e.ServiceID] {
+		return false
+	}
+	if m.level != "" && e.Level != m.level {
+		return false
+	}
+	if m.stream != "" && e.Stream != m.stream {
+		return false
+	}
+	if m.host != "" {
+		_, host, ok := strings.Cut(e.ServiceID, "@")
+		if !ok {
+			host = "local"
+		}
+		if host != m.host {
+			return false
+		}
+	}
+	for name, want := range m.fields {
+		if e.Fields[name] != want {
+			return false
+		}
+	}
+	if m.query != "" && !strings.Contains(strings.ToLower(e.Message), m.query) {
+		return false
+	}
+	return m.matchLabels(ctx, e.ServiceID)
+}
+
+// matchLabels checks the label selector against the service's labels,
+// loading them again when a service shows up that was not known yet.
+func (m *logMatcher) matchLabels(ctx context.Context, serviceID string) bool {
+	if len(m.labels) == 0 {
+		return true
+	}
+	ok, known := m.matched[serviceID]
+	if known {
+		return ok
+	}
+	if time.Since(m.loadedAt) < labelRefresh {
+		return false
+	}
+	m.loadedAt = time.Now()
+	all, err := m.repo.ServiceLabels(ctx)
+	if err != nil {
+		return false
+	}
+	m.matched = make(map[string]bool, len(all))
+	for id, labels := range all {
+		m.matched[id] = m.labels.Matches(labels)
+	}
+	return m.matched[serviceID]
+}
+
+// handleV1LogStream streams new log entries matching the filters of
+// /api/v1/logs as server-sent "log" events with api.LogEntry data.
+func (s *Server) handleV1LogStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.streamLogs(w, r, func(e models.LogEntry) (string, []byte, error) {
+		b, err := json.Marshal(api.LogEntryFrom(e))
+		return "log", b, err
+	})
+}
+
+// handleLogsStream streams new log entries as "row" events holding the
+// table rows of the logs fragment, for its follow mode.
+func (s *Server) handleLogsStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.streamLogs(w, r, func(e models.LogEntry) (string, []byte, error) {
+		var buf bytes.Buffer
+		err := s.tpl.ExecuteTemplate(&buf, "log_row", e)
+		return "row", buf.Bytes(), err
+	})
+}
+
+// streamLogs follows the stored logs until the client goes away. Entries
+// a slow client misses are reported in "skipped" events with their count.
+func (s *Server) streamLogs(w http.ResponseWriter, r *http.Request, render func(models.LogEntry) (string, []byte, error)) {
+	if s.opts.LogTail == nil {
+		http.Error(w, "live logs are not available", http.StatusNotImplemented)
+		return
+	}
+	matcher, err := newLogMatcher(s.repo, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f := s.opts.LogTail.Follow()
+	defer s.opts.LogTail.Unfollow(f)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprint(w, ": following\n\n"); err != nil || rc.Flush() != nil {
+		return
+	}
+	ping := time.NewTicker(logStreamPing)
+	defer ping.Stop()
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case e := <-f.C:
+			if n := f.Skipped(); n > 0 {
+				if err := writeEvent(w, "skipped", []byte(fmt.Sprint(n))); err != nil {
+					return
+				}
+			}
+			if !matcher.match(ctx, e) {
+				continue
+			}
+			event, data, err := render(e)
+			if err != nil {
+				s.log.Error("render log event", "err", err)
+				return
+			}
+			if err := writeEvent(w, event, data); err != nil {
+				return
+			}
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes one server-sent event; every line of data gets its
+// own data field.
+func writeEvent(w http.ResponseWriter, event string, data []byte) error {
+	var b strings.Builder
+	b.WriteString("event: " + event + "\n")
+	for _, line := range strings.Split(string(data), "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	_, err := w.Write([]byte(b.String()))
+	return err
+}
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/logs"
+	"dashi/internal/models"
+)
+
+func TestLogStreamFiltersEntries(t *testing.T) {
+	tail := logs.NewTail()
+	s, _ := newTestServer(t, Options{LogTail: tail})
+	srv := httptest.NewServer(s.Routes())
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/logs/stream?service=api,worker@edge&level=error&q=TIMEOUT", nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get stream: %v", err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type = %q", ct)
+	}
+	lines := bufio.NewScanner(res.Body)
+	if !lines.Scan() || lines.Text() != ": following" {
+		t.Fatalf("first line = %q", lines.Text())
+	}
+
+	tail.Publish([]models.LogEntry{
+		{ServiceID: "api", Level: "ERROR", Message: "upstream timeout"},
+		{ServiceID: "db", Level: "ERROR", Message: "lock timeout"},
+		{ServiceID: "worker@edge", Level: "INFO", Message: "job timeout retried"},
+		{ServiceID: "worker@edge", Level: "ERROR", Message: "job failed"},
+		{ServiceID: "worker@edge", Level: "ERROR", Message: "job Timeout"},
+	})
+	var got []string
+	for len(got) < 2 && lines.Scan() {
+		data, ok := strings.CutPrefix(lines.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var e api.LogEntry
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			t.Fatalf("decode %q: %v", data, err)
+		}
+		got = append(got, e.ServiceID+": "+e.Message)
+	}
+	want := []string{"api: upstream timeout", "worker@edge: job Timeout"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("streamed %q, want %q", got, want)
+	}
+}
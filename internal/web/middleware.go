@@ -1,8 +1,13 @@
 package web
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -29,3 +34,202 @@ func (s *statusWriter) WriteHeader(code int) {
 	s.status = code
 	s.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// flush server-sent events.
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// etagMiddleware buffers successful GET responses of fragments and the JSON
+// API, tags them with a hash of their body and answers 304 when the client
+// already has it, so an idle dashboard's polling transfers next to nothing.
+// Responses that flush, i.e. streams, and those larger than etagMaxBody,
+// such as backup downloads, pass through untagged.
+func etagMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) ||
+			!(strings.HasPrefix(r.URL.Path, "/fragments/") || strings.HasPrefix(r.URL.Path, "/api/")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ew := &etagWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(ew, r)
+		if ew.streaming {
+			return
+		}
+		h := w.Header()
+		if ew.status != http.StatusOK || h.Get("ETag") != "" {
+			ew.writeThrough()
+			return
+		}
+		sum := sha256.Sum256(ew.buf.Bytes())
+		etag := `"` + hex.EncodeToString(sum[:12]) + `"`
+		h.Set("ETag", etag)
+		if h.Get("Cache-Control") == "" {
+			// Cached, but revalidated on every poll.
+			h.Set("Cache-Control", "no-cache")
+		}
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		ew.writeThrough()
+	})
+}
+
+const etagMaxBody = 1 << 20
+
+func etagMatches(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == etag || v == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// etagWriter holds a response back until etagMiddleware has hashed it.
+type etagWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	buf         bytes.Buffer
+	// streaming is set once the handler flushed or wrote more than
+	// etagMaxBody; from then on writes go straight to the client.
+	streaming bool
+}
+
+func (e *etagWriter) WriteHeader(code int) {
+	if e.streaming {
+		e.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if !e.wroteHeader {
+		e.status, e.wroteHeader = code, true
+	}
+}
+
+func (e *etagWriter) Write(b []byte) (int, error) {
+	if !e.streaming && e.buf.Len()+len(b) > etagMaxBody {
+		e.writeThrough()
+		e.streaming = true
+	}
+	if e.streaming {
+		return e.ResponseWriter.Write(b)
+	}
+	return e.buf.Write(b)
+}
+
+// FlushError switches to streaming, sending what was buffered so far.
+// http.ResponseController calls it rather than unwrapping the writer.
+func (e *etagWriter) FlushError() error {
+	if !e.streaming {
+		e.writeThrough()
+		e.streaming = true
+	}
+	return http.NewResponseController(e.ResponseWriter).Flush()
+}
+
+func (e *etagWriter) Flush() { _ = e.FlushError() }
+
+func (e *etagWriter) Unwrap() http.ResponseWriter {
+	return e.ResponseWriter
+}
+
+func (e *etagWriter) writeThrough() {
+	e.ResponseWriter.WriteHeader(e.status)
+	_, _ = e.ResponseWriter.Write(e.buf.Bytes())
+	e.buf.Reset()
+}
+
+// corsMiddleware answers cross-origin requests to the JSON API for the
+// configured origins. An origin of "*" allows any caller.
+func corsMiddleware(next http.Handler, origins, methods []string) http.Handler {
+	if len(origins) == 0 {
+		return next
+	}
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		allowed[strings.TrimSuffix(o, "/")] = true
+	}
+	allowMethods := strings.Join(methods, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !allowed["*"] && !allowed[origin] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		if allowed["*"] {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", allowMethods)
+			if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", reqHeaders)
+			}
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// defaultScriptSrc covers the embedded UI: htmx from unpkg, and 'unsafe-eval'
+// because hx-on attributes are compiled with Function().
+var defaultScriptSrc = []string{"'self'", "https://unpkg.com", "'unsafe-eval'"}
+
+func buildCSP(extraScriptSrc []string) string {
+	scriptSrc := append(append([]string{}, defaultScriptSrc...), extraScriptSrc...)
+	return strings.Join([]string{
+		"default-src 'self'",
+		"script-src " + strings.Join(scriptSrc, " "),
+		"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com",
+		"font-src 'self' https://fonts.gstatic.com",
+		"img-src 'self' data:",
+		"connect-src 'self'",
+		"frame-ancestors 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+	}, "; ")
+}
+
+type securityHeaders struct {
+	csp            string
+	hstsMaxAge     time.Duration
+	frameOptions   string
+	referrerPolicy string
+}
+
+func securityMiddleware(next http.Handler, cfg securityHeaders) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		if cfg.csp != "" {
+			h.Set("Content-Security-Policy", cfg.csp)
+		}
+		if cfg.frameOptions != "" {
+			h.Set("X-Frame-Options", cfg.frameOptions)
+		}
+		if cfg.referrerPolicy != "" {
+			h.Set("Referrer-Policy", cfg.referrerPolicy)
+		}
+		// HSTS is only meaningful over TLS, which usually terminates at a proxy.
+		if cfg.hstsMaxAge > 0 && (r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")) {
+			h.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d; includeSubDomains", int64(cfg.hstsMaxAge.Seconds())))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,114 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	h := corsMiddleware(next, []string{"https://home.example"}, []string{"GET", "OPTIONS"})
+
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		origin     string
+		wantStatus int
+		wantOrigin string
+	}{
+		{"allowed origin", http.MethodGet, "/api/v1/logs", "https://home.example", http.StatusOK, "https://home.example"},
+		{"unknown origin", http.MethodGet, "/api/v1/logs", "https://evil.example", http.StatusOK, ""},
+		{"non api path", http.MethodGet, "/fragments/logs", "https://home.example", http.StatusOK, ""},
+		{"preflight", http.MethodOptions, "/api/v1/logs", "https://home.example", http.StatusNoContent, "https://home.example"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("Origin", tc.origin)
+		if tc.method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", "GET")
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.wantStatus {
+			t.Fatalf("%s: status = %d, want %d", tc.name, rec.Code, tc.wantStatus)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tc.wantOrigin {
+			t.Fatalf("%s: allow-origin = %q, want %q", tc.name, got, tc.wantOrigin)
+		}
+	}
+}
+
+func TestSecurityMiddlewareHSTSOnlyOverHTTPS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := securityMiddleware(next, securityHeaders{csp: buildCSP([]string{"https://cdn.example"}), hstsMaxAge: time.Hour, frameOptions: "DENY"})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Fatalf("hsts over plain http = %q, want empty", got)
+	}
+	if csp := rec.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "https://cdn.example") {
+		t.Fatalf("csp missing extra script source: %q", csp)
+	}
+
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "max-age=3600; includeSubDomains" {
+		t.Fatalf("hsts = %q", got)
+	}
+}
+
+func TestETagMiddleware(t *testing.T) {
+	body := "<div>idle</div>"
+	h := etagMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fragments/stream":
+			_, _ = w.Write([]byte("first"))
+			_ = http.NewResponseController(w).Flush()
+			_, _ = w.Write([]byte("second"))
+		case "/fragments/missing":
+			http.Error(w, "gone", http.StatusNotFound)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(body))
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fragments/overview", nil))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || rec.Body.String() != body || etag == "" {
+		t.Fatalf("first poll: status %d, body %q, etag %q", rec.Code, rec.Body, etag)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/fragments/overview", nil)
+	req.Header.Set("If-None-Match", `"stale", `+etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || rec.Header().Get("ETag") != etag {
+		t.Fatalf("repeat poll: status %d, body %q, etag %q", rec.Code, rec.Body, rec.Header().Get("ETag"))
+	}
+
+	body = "<div>busy</div>"
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != body {
+		t.Fatalf("changed content: status %d, body %q", rec.Code, rec.Body)
+	}
+
+	for path, want := range map[string]string{"/fragments/stream": "firstsecond", "/fragments/missing": "gone\n", "/settings": body} {
+		rec = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("If-None-Match", "*")
+		h.ServeHTTP(rec, req)
+		if rec.Body.String() != want || rec.Header().Get("ETag") != "" {
+			t.Errorf("%s: body %q, etag %q; want %q untagged", path, rec.Body, rec.Header().Get("ETag"), want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"dashi/internal/logs"
+	"dashi/internal/otlp"
+)
+
+// handleOTLPLogs stores the logs of an OTLP/HTTP export under the service
+// that sent them, or under its container when its container.id is one
+// dashi monitors.
+func (s *Server) handleOTLPLogs(w http.ResponseWriter, r *http.Request) {
+	if s.opts.LogSink == nil {
+		writeAPIError(w, http.StatusNotFound, "log ingestion is not enabled")
+		return
+	}
+	call, body, protobuf, ok := s.otlpRequest(w, r)
+	if !ok {
+		return
+	}
+	req, err := otlp.UnmarshalLogs(body, protobuf)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid export: "+err.Error())
+		return
+	}
+	type ids struct{ service, container string }
+	sources := map[string]ids{}
+	containers := map[string]string{}
+	for _, rec := range req.Records(time.Now().UTC()) {
+		if rec.Container != "" {
+			svc, ok := containers[rec.Container]
+			if !ok {
+				var err error
+				svc, err = s.repo.ContainerService(r.Context(), rec.Container)
+				if err != nil && !errors.Is(err, sql.ErrNoRows) {
+					writeAPIError(w, http.StatusInternalServerError, err.Error())
+					return
+				}
+				if svc != "" && call.agent != "" {
+					host, err := s.repo.ContainerHost(r.Context(), rec.Container)
+					if err != nil {
+						writeAPIError(w, http.StatusInternalServerError, err.Error())
+						return
+					}
+					if host != call.agent {
+						svc = ""
+					}
+				}
+				containers[rec.Container] = svc
+			}
+			if svc != "" {
+				rec.ServiceID, rec.ContainerID = svc, rec.Container
+				s.opts.LogSink.Write(rec.LogEntry)
+				continue
+			}
+		}
+		src, ok := sources[rec.Service]
+		if !ok {
+			svc, cid, err := s.opts.LogSink.Source(r.Context(), logs.Source{Kind: otlp.Kind, Name: rec.Service})
+			if err != nil {
+				writeAPIError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			src = ids{svc, cid}
+			sources[rec.Service] = src
+		}
+		rec.ServiceID, rec.ContainerID = src.service, src.container
+		s.opts.LogSink.Write(rec.LogEntry)
+	}
+	writeOTLPResponse(w, protobuf)
+}
+
+// handleOTLPMetrics stores the metrics of an OTLP/HTTP export like scraped
+// series, with the sending service as their target.
+func (s *Server) handleOTLPMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.opts.MetricsDisabled || s.opts.OTLP == nil {
+		writeAPIError(w, http.StatusNotFound, "metric collection is disabled")
+		return
+	}
+	_, body, protobuf, ok := s.otlpRequest(w, r)
+	if !ok {
+		return
+	}
+	req, err := otlp.UnmarshalMetrics(body, protobuf)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid export: "+err.Error())
+		return
+	}
+	if err := s.repo.InsertScrapeSamples(r.Context(), s.opts.OTLP.Samples(req, time.Now().UTC())); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeOTLPResponse(w, protobuf)
+}
+
+// otlpRequest authenticates an OTLP/HTTP export like the /api/ingest
+// pushes and returns its body and whether it is protobuf rather than JSON.
+func (s *Server) otlpRequest(w http.ResponseWriter, r *http.Request) (ingestCall, []byte, bool, bool) {
+	call, body, ok := s.ingestBody(w, r)
+	if !ok {
+		return call, nil, false, false
+	}
+	switch mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt {
+	case "application/x-protobuf":
+		return call, body, true, true
+	case "application/json":
+		return call, body, false, true
+	}
+	writeAPIError(w, http.StatusUnsupportedMediaType, "exports must be application/x-protobuf or application/json")
+	return call, nil, false, false
+}
+
+// writeOTLPResponse answers an export with an empty export response in its
+// encoding.
+func writeOTLPResponse(w http.ResponseWriter, protobuf bool) {
+	if protobuf {
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, "{}")
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/db"
+	"dashi/internal/logs"
+	"dashi/internal/models"
+	"dashi/internal/otlp"
+)
+
+func TestOTLPExports(t *testing.T) {
+	s, repo := newTestServer(t, Options{IngestToken: "secret", OTLP: otlp.NewConverter()})
+	// One connection, so the per-container log writers never hit a locked
+	// database: without a spill the batch would be lost.
+	repo.DB().SetMaxOpenConns(1)
+	ctx := context.Background()
+	svc := models.Service{ID: "web", Name: "web", Image: "img", LabelsJSON: "{}", Status: "running"}
+	if err := repo.UpsertServiceAndContainer(ctx, svc, models.Container{ID: "c1", ServiceID: "web", Name: "web", Status: "running"}); err != nil {
+		t.Fatalf("store container: %v", err)
+	}
+	sink := logs.NewSink(repo, s.log, logs.Options{})
+	s.opts.LogSink = sink
+	h := s.Routes()
+	export := func(path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := export("/otlp/v1/logs", "text/plain", "{}"); rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("text export status = %d", rec.Code)
+	}
+	logsBody := `{"resourceLogs": [
+		{"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "checkout"}}]},
+		 "scopeLogs": [{"logRecords": [{"severityNumber": 17, "body": {"stringValue": "payment failed"}}]}]},
+		{"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "web"}}, {"key": "container.id", "value": {"stringValue": "c1"}}]},
+		 "scopeLogs": [{"logRecords": [{"body": {"stringValue": "GET / 200"}}]}]}]}`
+	rec := export("/otlp/v1/logs", "application/json", logsBody)
+	if rec.Code != http.StatusOK || rec.Body.String() != "{}" {
+		t.Fatalf("logs export = %d %s", rec.Code, rec.Body)
+	}
+	if err := sink.Stop(ctx); err != nil {
+		t.Fatalf("stop sink: %v", err)
+	}
+	got, err := repo.QueryLogs(ctx, db.LogQuery{Stream: otlp.Stream})
+	if err != nil || len(got) != 2 {
+		t.Fatalf("logs = %+v, err %v", got, err)
+	}
+	for _, e := range got {
+		if (e.ServiceID != "checkout@otlp" || e.Level != "ERROR") && (e.ServiceID != "web" || e.ContainerID != "c1") {
+			t.Fatalf("entry = %+v", e)
+		}
+	}
+
+	metricsBody := `{"resourceMetrics": [{"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "checkout"}}]},
+		"scopeMetrics": [{"metrics": [{"name": "queue.depth", "gauge": {"dataPoints": [{"asInt": "4"}]}}]}]}]}`
+	if rec := export("/otlp/v1/metrics", "application/json", metricsBody); rec.Code != http.StatusOK {
+		t.Fatalf("metrics export = %d %s", rec.Code, rec.Body)
+	}
+	series, err := repo.ScrapeSeries(ctx, "checkout", "queue.depth", time.Now().Add(-time.Hour))
+	if err != nil || len(series) != 1 || series[0].Value != 4 {
+		t.Fatalf("series = %+v, err %v", series, err)
+	}
+}
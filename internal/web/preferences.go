@@ -0,0 +1,96 @@
+package web
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+)
+
+const (
+	prefsUserHeader = "X-Dashi-User"
+	prefsCookie     = "dashi_session"
+)
+
+func (s *Server) handleV1Preferences(w http.ResponseWriter, r *http.Request) {
+	owner := preferencesOwner(w, r)
+	switch r.Method {
+	case http.MethodGet:
+		raw, updated, err := s.repo.GetPreferences(r.Context(), owner)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSON(w, api.Preferences{})
+			return
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		var prefs api.Preferences
+		if err := json.Unmarshal([]byte(raw), &prefs); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, "stored preferences are corrupt")
+			return
+		}
+		prefs.UpdatedAt = &updated
+		writeJSON(w, prefs)
+	case http.MethodPut, http.MethodPost:
+		var prefs api.Preferences
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&prefs); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid preferences: "+err.Error())
+			return
+		}
+		if prefs.DefaultRange != "" {
+			if d, err := time.ParseDuration(prefs.DefaultRange); err != nil || d <= 0 {
+				writeAPIError(w, http.StatusBadRequest, "invalid default_range")
+				return
+			}
+		}
+		prefs.UpdatedAt = nil
+		b, _ := json.Marshal(prefs)
+		if err := s.repo.SavePreferences(r.Context(), owner, string(b)); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		now := time.Now().UTC()
+		prefs.UpdatedAt = &now
+		writeJSON(w, prefs)
+	case http.MethodDelete:
+		if err := s.repo.DeletePreferences(r.Context(), owner); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// preferencesOwner keys preferences by an explicit user name when a client
+// sends one (so they follow the user across devices), otherwise by a
+// long-lived session cookie that is issued on first use.
+func preferencesOwner(w http.ResponseWriter, r *http.Request) string {
+	if u := strings.TrimSpace(r.Header.Get(prefsUserHeader)); u != "" {
+		return "user:" + u
+	}
+	if c, err := r.Cookie(prefsCookie); err == nil && c.Value != "" {
+		return "session:" + c.Value
+	}
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	id := hex.EncodeToString(buf)
+	http.SetCookie(w, &http.Cookie{
+		Name:     prefsCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   365 * 24 * 3600,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return "session:" + id
+}
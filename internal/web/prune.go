@@ -0,0 +1,136 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"dashi/internal/api"
+	"dashi/internal/docker"
+	"dashi/internal/models"
+)
+
+var (
+	errPruneDisabled = errors.New("prune actions are disabled, set APP_PRUNE_ENABLED=true to allow them")
+	errUnknownHost   = errors.New("unknown docker host")
+	errBadPruneKind  = fmt.Errorf("kind must be one of %s", strings.Join(docker.PruneKinds, ", "))
+)
+
+// handleV1Prune previews (dry_run=1) or runs a prune of stopped containers,
+// dangling images or unused volumes on one Docker host.
+func (s *Server) handleV1Prune(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	rep, err := s.prune(r, r.FormValue("kind"), r.FormValue("host"), r.FormValue("dry_run") == "1")
+	switch {
+	case errors.Is(err, errPruneDisabled):
+		writeAPIError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, errUnknownHost):
+		writeAPIError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, errBadPruneKind):
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		writeAPIError(w, http.StatusBadGateway, err.Error())
+	default:
+		writeJSON(w, api.PruneReportFrom(rep))
+	}
+}
+
+func (s *Server) handleV1Audit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	events, err := s.repo.ListAudit(r.Context(), limit)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.AuditEvents{Items: api.AuditEventsFrom(events)})
+}
+
+// handlePruneFragment renders a prune preview with a confirm button, or
+// the result of the confirmed prune.
+func (s *Server) handlePruneFragment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rep, err := s.prune(r, r.FormValue("kind"), r.FormValue("host"), r.FormValue("dry_run") == "1")
+	data := map[string]any{"report": rep, "pruneEnabled": s.opts.PruneEnabled}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	_ = s.tpl.ExecuteTemplate(w, "fragment_prune.html", data)
+}
+
+// prune runs or previews a prune. Real runs are written to the audit log,
+// failed ones included.
+func (s *Server) prune(r *http.Request, kind, host string, dryRun bool) (models.PruneReport, error) {
+	if host == "" {
+		host = docker.LocalHost
+	}
+	rep := models.PruneReport{Host: host, Kind: kind, DryRun: dryRun}
+	if !slices.Contains(docker.PruneKinds, kind) {
+		return rep, errBadPruneKind
+	}
+	dc, ok := s.hostDocker(host)
+	if !ok {
+		return rep, errUnknownHost
+	}
+	if dryRun {
+		out, err := dc.PrunePreview(r.Context(), kind)
+		out.Host = host
+		return out, err
+	}
+	if !s.opts.PruneEnabled {
+		return rep, errPruneDisabled
+	}
+	out, err := dc.Prune(r.Context(), kind)
+	out.Host = host
+	event := models.AuditEvent{Action: "prune." + kind, Target: host, Source: r.RemoteAddr}
+	if err != nil {
+		event.Detail = "failed: " + err.Error()
+		s.log.Error("prune failed", "kind", kind, "docker_host", host, "err", err)
+	} else {
+		event.Detail = fmt.Sprintf("removed %d, reclaimed %d bytes", len(out.Items), out.ReclaimedBytes)
+		s.log.Info("pruned", "kind", kind, "docker_host", host, "removed", len(out.Items), "reclaimed_bytes", out.ReclaimedBytes, "source", r.RemoteAddr)
+	}
+	// The prune already happened, so the audit write must not be cut short
+	// by a client that went away.
+	if aerr := s.repo.InsertAudit(context.WithoutCancel(r.Context()), event); aerr != nil {
+		s.log.Error("write audit event", "action", event.Action, "err", aerr)
+	}
+	return out, err
+}
+
+// hostDocker returns the client of a Docker host by name.
+func (s *Server) hostDocker(host string) (*docker.Client, bool) {
+	if c, ok := s.opts.DockerHosts[host]; ok {
+		return c, true
+	}
+	if host == docker.LocalHost && s.docker != nil {
+		return s.docker, true
+	}
+	return nil, false
+}
+
+// dockerHostNames lists the configured Docker hosts, local first.
+func (s *Server) dockerHostNames() []string {
+	names := []string{docker.LocalHost}
+	for name := range s.opts.DockerHosts {
+		if name != docker.LocalHost {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
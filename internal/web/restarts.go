@@ -0,0 +1,52 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"dashi/internal/api"
+)
+
+// restartRange is the default window of the restart history.
+const restartRange = "168h"
+
+// restartRanges are the windows the restart history offers.
+var restartRanges = []struct{ Label, Range string }{{"24h", "24h"}, {"7d", "168h"}, {"30d", "720h"}}
+
+// handleV1Restarts serves GET /api/v1/restarts, the restarts and
+// replacements the collector detected.
+func (s *Server) handleV1Restarts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	q := r.URL.Query()
+	param := q.Get("range")
+	if param == "" {
+		param = restartRange
+	}
+	rng := parseRange(param)
+	now := time.Now()
+	events, err := s.repo.RestartEvents(r.Context(), now.Add(-rng), now, q.Get("service"), q.Get("host"), 500)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.RestartEvents{Range: rng.String(), Items: api.RestartEventsFrom(events)})
+}
+
+// handleServiceRestartsFragment lists the restart history of a service.
+func (s *Server) handleServiceRestartsFragment(w http.ResponseWriter, r *http.Request, id string) {
+	param := r.URL.Query().Get("range")
+	if param == "" {
+		param = restartRange
+	}
+	now := time.Now()
+	data := map[string]any{"serviceID": id, "range": param, "ranges": restartRanges}
+	events, err := s.repo.RestartEvents(r.Context(), now.Add(-parseRange(param)), now, id, "", 100)
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	data["events"] = api.RestartEventsFrom(events)
+	_ = s.tpl.ExecuteTemplate(w, "fragment_service_restarts.html", data)
+}
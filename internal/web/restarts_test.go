@@ -0,0 +1,46 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/models"
+)
+
+func TestRestartHistory(t *testing.T) {
+	s, repo := newTestServer(t, Options{})
+	ctx := context.Background()
+	now := time.Now().UTC()
+	finished, started, code := now.Add(-time.Hour), now.Add(-time.Hour+30*time.Second), 137
+	for _, ev := range []models.RestartEvent{
+		{ContainerID: "api1", ServiceID: "api", Name: "api", Reason: "oom", ExitCode: &code, RestartCount: 3, FinishedAt: &finished, StartedAt: &started, DetectedAt: now.Add(-time.Hour)},
+		{ContainerID: "db1", ServiceID: "db", Name: "db", Reason: "restart", DetectedAt: now.Add(-time.Minute)},
+	} {
+		if err := repo.InsertRestartEvent(ctx, ev); err != nil {
+			t.Fatalf("insert restart event: %v", err)
+		}
+	}
+	h := s.Routes()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/restarts?service=api", nil))
+	var out api.RestartEvents
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("restarts = %d %s", rec.Code, rec.Body)
+	}
+	if len(out.Items) != 1 || out.Range != "168h0m0s" || out.Items[0].DowntimeSec == nil || *out.Items[0].DowntimeSec != 30 || *out.Items[0].ExitCode != 137 {
+		t.Fatalf("restarts = %+v", out)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fragments/service/api/restarts", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "oom") || !strings.Contains(body, "137") || !strings.Contains(body, "30s") || strings.Contains(body, "db1") {
+		t.Fatalf("restart history fragment:\n%s", body)
+	}
+}
@@ -0,0 +1,210 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/db"
+	"dashi/internal/scrape"
+	"dashi/internal/settings"
+)
+
+const (
+	// scrapeChartLines is the most series a panel draws.
+	scrapeChartLines = 8
+	scrapeChartWidth = 600
+)
+
+// scrapeLine is one series of a panel as an SVG polyline, y pointing down.
+type scrapeLine struct {
+	Name   string
+	Points string
+	Last   float64
+}
+
+// scrapeChart is a panel with its series scaled to a chart.
+type scrapeChart struct {
+	scrape.Panel
+	Lines    []scrapeLine
+	Min, Max float64
+	// Hidden counts the series beyond scrapeChartLines.
+	Hidden int
+	Error  string
+}
+
+// handleExporters shows the scrape targets with their last scrape and the
+// panels charting what they keep.
+func (s *Server) handleExporters(w http.ResponseWriter, r *http.Request) {
+	param := r.URL.Query().Get("range")
+	if param == "" {
+		param = "1h"
+	}
+	rng := parseRange(param)
+	now := time.Now().UTC()
+	var panels []scrape.Panel
+	var raw json.RawMessage
+	if ok, err := s.opts.Settings.Get(r.Context(), scrape.PanelsKey, &raw); err == nil && ok {
+		panels, _ = scrape.ParsePanels(raw)
+	}
+	charts := make([]scrapeChart, 0, len(panels))
+	for _, p := range panels {
+		c := scrapeChart{Panel: p}
+		series, err := s.scrapeSeries(r.Context(), p.Target, p.Metric, p.Labels, now.Add(-rng))
+		if err != nil {
+			c.Error = err.Error()
+		} else {
+			c.Lines, c.Min, c.Max = scrapeLines(series, now.Add(-rng), now, 100)
+			c.Hidden = len(series) - len(c.Lines)
+		}
+		charts = append(charts, c)
+	}
+	_ = s.tpl.ExecuteTemplate(w, "exporters.html", map[string]any{
+		"range":       param,
+		"targets":     s.opts.Scraper.Statuses(),
+		"charts":      charts,
+		"targetsJSON": s.settingJSON(r.Context(), scrape.TargetsKey),
+		"panelsJSON":  s.settingJSON(r.Context(), scrape.PanelsKey),
+	})
+}
+
+// handleExportersSettings saves the scrape targets or panels from the
+// exporters page, like handleSettingsLogDrops.
+func (s *Server) handleExportersSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	key := scrape.TargetsKey
+	if r.URL.Path == "/exporters/panels" {
+		key = scrape.PanelsKey
+	}
+	value := strings.TrimSpace(r.FormValue("value"))
+	if value == "" {
+		value = "[]"
+	}
+	if err := s.opts.Settings.SetRaw(r.Context(), key, json.RawMessage(value)); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, settings.ErrInvalid) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	http.Redirect(w, r, "/exporters", http.StatusSeeOther)
+}
+
+func (s *Server) handleV1ScrapeTargets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	out := api.ScrapeTargets{Items: []api.ScrapeTarget{}}
+	for _, st := range s.opts.Scraper.Statuses() {
+		item := api.ScrapeTarget{Name: st.Name, URL: st.URL, Metrics: st.Metrics, Up: !st.ScrapedAt.IsZero() && st.Error == "",
+			DurationMS: st.Duration.Milliseconds(), Series: st.Series, Dropped: st.Dropped, Error: st.Error}
+		if !st.ScrapedAt.IsZero() {
+			item.ScrapedAt = &st.ScrapedAt
+		}
+		out.Items = append(out.Items, item)
+	}
+	writeJSON(w, out)
+}
+
+func (s *Server) handleV1ScrapeSeries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	q := r.URL.Query()
+	target, metric := q.Get("target"), q.Get("metric")
+	if target == "" || metric == "" {
+		writeAPIError(w, http.StatusBadRequest, "target and metric are required")
+		return
+	}
+	rng := parseRange(q.Get("range"))
+	series, err := s.scrapeSeries(r.Context(), target, metric, q.Get("labels"), time.Now().Add(-rng))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, api.ScrapeMetric{Target: target, Metric: metric, Range: rng.String(), Series: series})
+}
+
+// scrapeSeries returns the series of metric scraped from target since
+// since whose labels match selector, by name.
+func (s *Server) scrapeSeries(ctx context.Context, target, metric, selector string, since time.Time) ([]api.ScrapeSeries, error) {
+	sel, err := db.ParseLabelSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	samples, err := s.repo.ScrapeSeries(ctx, target, metric, since)
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]*api.ScrapeSeries{}
+	for _, sample := range samples {
+		if !sel.Matches(sample.Labels) {
+			continue
+		}
+		name := scrape.SeriesName(sample.Metric, sample.Labels)
+		series, ok := byName[name]
+		if !ok {
+			series = &api.ScrapeSeries{Name: name, Labels: sample.Labels, Points: []api.ScrapePoint{}}
+			byName[name] = series
+		}
+		series.Points = append(series.Points, api.ScrapePoint{TS: sample.TS.UTC(), Value: sample.Value})
+	}
+	out := make([]api.ScrapeSeries, 0, len(byName))
+	for _, series := range byName {
+		out = append(out, *series)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// scrapeLines scales up to scrapeChartLines series between start and end
+// to a chart of the given height, sharing one value axis.
+func scrapeLines(series []api.ScrapeSeries, start, end time.Time, height float64) ([]scrapeLine, float64, float64) {
+	if len(series) > scrapeChartLines {
+		series = series[:scrapeChartLines]
+	}
+	lo, hi, any := 0.0, 0.0, false
+	for _, s := range series {
+		for _, p := range s.Points {
+			if !any {
+				lo, hi, any = p.Value, p.Value, true
+			}
+			lo, hi = min(lo, p.Value), max(hi, p.Value)
+		}
+	}
+	span := hi - lo
+	if span == 0 {
+		span = 1
+	}
+	width := end.Sub(start).Seconds()
+	lines := make([]scrapeLine, 0, len(series))
+	for _, s := range series {
+		if len(s.Points) == 0 {
+			continue
+		}
+		pts := make([]string, 0, len(s.Points))
+		for _, p := range s.Points {
+			x := scrapeChartWidth * p.TS.Sub(start).Seconds() / width
+			y := height - height*(p.Value-lo)/span
+			pts = append(pts, fmt.Sprintf("%.1f,%.2f", x, y))
+		}
+		lines = append(lines, scrapeLine{Name: s.Name, Points: strings.Join(pts, " "), Last: s.Points[len(s.Points)-1].Value})
+	}
+	return lines, lo, hi
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/models"
+	"dashi/internal/scrape"
+	"dashi/internal/settings"
+)
+
+func TestExportersPanelsAndSeries(t *testing.T) {
+	s, repo := newTestServer(t, Options{})
+	ctx := context.Background()
+	now := time.Now().UTC()
+	var samples []models.ScrapeSample
+	for i, code := range []string{"200", "500"} {
+		for m := 2; m >= 1; m-- {
+			samples = append(samples, models.ScrapeSample{TS: now.Add(-time.Duration(m) * time.Minute), Target: "app", Metric: "http_requests_total",
+				Labels: map[string]string{"code": code}, Value: float64(10*(i+1) + m)})
+		}
+	}
+	if err := repo.InsertScrapeSamples(ctx, samples); err != nil {
+		t.Fatalf("insert samples: %v", err)
+	}
+	st := settings.NewStore(repo, s.log)
+	st.Validate("scrape", func(key string, raw json.RawMessage) error {
+		_, err := scrape.ParsePanels(raw)
+		return err
+	})
+	s.opts.Settings = st
+	s.opts.Scraper = scrape.NewScraper(repo, s.log)
+	h := s.Routes()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/scrape/series?target=app&metric=http_requests_total&labels=code%3D500", nil))
+	var metric api.ScrapeMetric
+	if err := json.Unmarshal(rec.Body.Bytes(), &metric); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("series = %d %s", rec.Code, rec.Body)
+	}
+	if len(metric.Series) != 1 || metric.Series[0].Name != `http_requests_total{code="500"}` || len(metric.Series[0].Points) != 2 || metric.Series[0].Points[1].Value != 21 {
+		t.Fatalf("series = %+v", metric)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/scrape/series?target=app", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("series without metric = %d", rec.Code)
+	}
+
+	post := func(path, value string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(url.Values{"value": {value}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := post("/exporters/panels", `[{"target": "app"}]`); code != http.StatusBadRequest {
+		t.Fatalf("invalid panels status = %d", code)
+	}
+	if code := post("/exporters/panels", `[{"title": "Requests/s", "target": "app", "metric": "http_requests_total"}]`); code != http.StatusSeeOther {
+		t.Fatalf("save panels status = %d", code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/exporters", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "<h3>Requests/s</h3>") || strings.Count(body, "<polyline") != 2 {
+		t.Fatalf("exporters page = %d %s", rec.Code, body)
+	}
+}
@@ -0,0 +1,124 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/models"
+)
+
+// searchWeights rank the kinds of search hits: what a quick jump is most
+// likely after comes first.
+var searchWeights = map[string]int{
+	"service":   50,
+	"container": 45,
+	"image":     30,
+	"label":     25,
+	"alert":     20,
+	"log":       10,
+}
+
+// searchScore ranks a hit of q: its kind's weight, raised when the matched
+// text is q itself or starts with it.
+func searchScore(h models.SearchHit, q string) int {
+	text, q := strings.ToLower(h.Text), strings.ToLower(q)
+	score := searchWeights[h.Kind]
+	switch {
+	case text == q:
+		score += 30
+	case strings.HasPrefix(text, q):
+		score += 20
+	case strings.Contains(text, q):
+		score += 10
+	}
+	return score
+}
+
+// search returns the limit best hits of q, ties broken by recency and then
+// title.
+func (s *Server) search(ctx context.Context, q string, limit int) ([]api.SearchResult, error) {
+	now := time.Now()
+	var alertsSince, logsSince time.Time
+	if !s.opts.AlertsDisabled {
+		alertsSince = now.Add(-7 * 24 * time.Hour)
+	}
+	if !s.opts.LogsDisabled {
+		logsSince = now.Add(-24 * time.Hour)
+	}
+	hits, err := s.repo.Search(ctx, q, alertsSince, logsSince, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]api.SearchResult, 0, len(hits))
+	for _, h := range hits {
+		res := api.SearchResult{Type: h.Kind, ID: h.ID, Title: h.Name, Match: h.Text, URL: "/", Score: searchScore(h, q)}
+		if len(res.Match) > 200 {
+			res.Match = res.Match[:200] + "…"
+		}
+		if h.ContainerID != "" {
+			res.URL = "/containers/" + h.ContainerID
+		} else if h.Kind == "alert" {
+			res.URL = "/#alerts"
+		}
+		if !h.TS.IsZero() {
+			ts := h.TS.UTC()
+			res.TS = &ts
+		}
+		out = append(out, res)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.TS != nil && b.TS != nil && !a.TS.Equal(*b.TS) {
+			return a.TS.After(*b.TS)
+		}
+		return a.Title < b.Title
+	})
+	return out[:min(len(out), limit)], nil
+}
+
+// handleSearch serves GET /api/search?q=, searching services, containers,
+// images, labels, alerts and recent logs at once for the quick-jump box.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeAPIError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit := 20
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = min(n, 100)
+	}
+	results, err := s.search(r.Context(), q, limit)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.Search{Query: q, Results: results})
+}
+
+// handleSearchFragment lists the hits of the quick-jump box as links; it
+// waits for two characters before searching.
+func (s *Server) handleSearchFragment(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	data := map[string]any{"q": q}
+	if len([]rune(q)) >= 2 {
+		results, err := s.search(r.Context(), q, 10)
+		if err != nil {
+			data["error"] = err.Error()
+		}
+		data["results"] = results
+	}
+	_ = s.tpl.ExecuteTemplate(w, "fragment_search.html", data)
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/models"
+)
+
+func TestSearch(t *testing.T) {
+	s, repo := newTestServer(t, Options{})
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, s := range []struct{ id, image, labels string }{
+		{"postgres", "postgres:16", `{"team":"billing"}`},
+		{"billing-api", "ghcr.io/acme/billing:2.1", `{}`},
+	} {
+		svc := models.Service{ID: s.id, Name: s.id, Image: s.image, LabelsJSON: s.labels, Status: "running"}
+		if err := repo.UpsertServiceAndContainer(ctx, svc, models.Container{ID: s.id + "-c1", ServiceID: s.id, Name: s.id + "-1", Status: "running"}); err != nil {
+			t.Fatalf("store container: %v", err)
+		}
+	}
+	if err := repo.InsertLogs(ctx, []models.LogEntry{
+		{TS: now.Add(-time.Minute), ServiceID: "postgres", ContainerID: "postgres-c1", Level: "ERROR", Stream: "stderr", Message: "billing.invoices: deadlock detected"},
+		{TS: now.Add(-48 * time.Hour), ServiceID: "postgres", ContainerID: "postgres-c1", Level: "ERROR", Stream: "stderr", Message: "billing too old to show"},
+	}); err != nil {
+		t.Fatalf("insert logs: %v", err)
+	}
+	if _, err := repo.CreateAlert(ctx, 1, "billing-api-c1", "firing", "Billing latency high", nil, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("create alert: %v", err)
+	}
+	h := s.Routes()
+	search := func(q string) (api.Search, *httptest.ResponseRecorder) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/search?q="+q, nil))
+		var out api.Search
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		return out, rec
+	}
+
+	// Kinds rank by how likely a jump targets them, then by how well the
+	// text matches: the alert summary starting with the query outranks the
+	// image merely containing it.
+	out, rec := search("Billing")
+	var got []string
+	for _, r := range out.Results {
+		got = append(got, r.Type+":"+r.Title+"->"+r.URL)
+	}
+	want := []string{
+		"service:billing-api->/containers/billing-api-c1",
+		"container:billing-api-1->/containers/billing-api-c1",
+		"alert:Host CPU high->/containers/billing-api-c1",
+		"image:billing-api->/containers/billing-api-c1",
+		"label:postgres->/containers/postgres-c1",
+		"log:postgres->/containers/postgres-c1",
+	}
+	if rec.Code != http.StatusOK || strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("search = %d\n%s\nwant\n%s", rec.Code, strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if out.Results[4].Match != "team=billing" || out.Results[5].TS == nil {
+		t.Fatalf("results = %+v", out.Results)
+	}
+
+	// An exact name outranks names merely containing it.
+	if out, _ := search("postgres"); len(out.Results) == 0 || out.Results[0].Type != "service" || out.Results[0].Title != "postgres" {
+		t.Fatalf("exact match = %+v", out.Results)
+	}
+	// Log messages match substrings like everything else, also where the
+	// FTS5 index only matches whole tokens.
+	if out, _ := search("DEADLO"); len(out.Results) != 1 || out.Results[0].Type != "log" {
+		t.Fatalf("substring of a log message = %+v", out.Results)
+	}
+	if _, rec := search(""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("empty query = %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fragments/search?q=dead", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `href="/containers/postgres-c1"`) || !strings.Contains(body, "deadlock detected") {
+		t.Fatalf("fragment = %s", body)
+	}
+}
@@ -1,22 +1,35 @@
 package web
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io/fs"
 	"log/slog"
 	"net/http"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"dashi/internal/api"
+	"dashi/internal/backup"
 	"dashi/internal/db"
 	"dashi/internal/docker"
+	"dashi/internal/logarchive"
+	"dashi/internal/logs"
+	"dashi/internal/models"
 	"dashi/internal/notifier"
+	"dashi/internal/otlp"
+	"dashi/internal/retention"
+	"dashi/internal/scrape"
+	"dashi/internal/settings"
 )
 
 //go:embed templates/*.html static/*
@@ -28,39 +41,172 @@ type Server struct {
 	notify *notifier.Telegram
 	log    *slog.Logger
 	tpl    *template.Template
+	opts   Options
 }
 
-func NewServer(repo *db.Repository, docker *docker.Client, notify *notifier.Telegram, logger *slog.Logger) *Server {
+type Options struct {
+	CORSOrigins []string
+	CORSMethods []string
+
+	// CSP replaces the built-in Content-Security-Policy entirely; use
+	// CSPScriptSrc to only allow additional script sources.
+	CSP            string
+	CSPScriptSrc   []string
+	HSTSMaxAge     time.Duration
+	FrameOptions   string
+	ReferrerPolicy string
+
+	Settings  *settings.Store
+	Retention *retention.Service
+	Backup    *backup.Service
+	// LogArchive lists and restores the log segments archived before
+	// retention; nil unless APP_LOG_ARCHIVE_S3_* are set.
+	LogArchive *logarchive.Archiver
+
+	// DockerHosts maps host names to clients for requests that go to the
+	// daemon running a container; the server's own client is used for
+	// "local" and unknown hosts.
+	DockerHosts map[string]*docker.Client
+	// PruneEnabled allows prune actions; previews always work.
+	PruneEnabled bool
+	// LogDrops reports the log drop rules and their counts.
+	LogDrops *logs.Dropper
+	// LogExtract reports the log field extraction rules.
+	LogExtract *logs.Extractor
+	// LogTail feeds the live log streams.
+	LogTail *logs.Tail
+	// Reload applies ConfigFile, if set, and reloads the settings, for the
+	// settings page and /api/v1/admin/reload.
+	Reload     func(ctx context.Context) error
+	ConfigFile string
+	// LogSink stores logs pushed to /api/ingest/logs, which like the other
+	// /api/ingest endpoints is only served with an IngestToken or
+	// AgentTokens.
+	LogSink     *logs.Sink
+	IngestToken string
+	// AgentTokens are the tokens agents sign their pushes with, by the
+	// host they push for. Agents without one sign with IngestToken.
+	AgentTokens map[string]string
+	// Scraper reports the scrape targets and their last scrapes; nil while
+	// metric collection is off.
+	Scraper *scrape.Scraper
+	// OTLP converts the metrics exported to /otlp/v1/metrics; nil while
+	// metric collection is off. Like the /api/ingest endpoints, the OTLP
+	// ones are only served with an IngestToken or AgentTokens.
+	OTLP *otlp.Converter
+	// Federation marks that other dashi instances are polled for their
+	// summaries, which the dashboard then shows by site.
+	Federation bool
+	// LogSpill holds the log batches waiting to be stored and LogBudget
+	// bounds the memory of those in the pipelines, both shown on the
+	// internals page.
+	LogSpill  *logs.Spill
+	LogBudget *logs.Budget
+	// ConfigWarnings are the configuration problems dashi started with;
+	// they are shown on every page and make /api/v1/health degraded.
+	ConfigWarnings []string
+	// LogsDisabled, AlertsDisabled and MetricsDisabled mark subsystems
+	// switched off in the configuration: their pages, fragments and API
+	// endpoints answer 404 and the dashboard leaves their panels out.
+	LogsDisabled    bool
+	AlertsDisabled  bool
+	MetricsDisabled bool
+	// Demo marks demo mode: the data is generated, there is no Docker
+	// host to ask, and the dashboard says so.
+	Demo bool
+}
+
+func NewServer(repo *db.Repository, docker *docker.Client, notify *notifier.Telegram, logger *slog.Logger, opts Options) *Server {
 	tpl := template.Must(template.New("all").Funcs(template.FuncMap{
+		"ansi":      ansiHTML,
 		"bytesToMB": func(v int64) string { return fmt.Sprintf("%.1f MB", float64(v)/1024.0/1024.0) },
+		"floatMB":   func(v float64) string { return fmt.Sprintf("%.1f MB", v/1024.0/1024.0) },
+		"join":      strings.Join,
+		"list":      func(v ...string) []string { return v },
 		"pct":       func(v float64) string { return fmt.Sprintf("%.1f%%", v) },
+		"portLink":  portURL,
+		"rateMB":    func(v float64) string { return fmt.Sprintf("%.1f MB/s", v/1024.0/1024.0) },
 		"timeago":   func(t time.Time) string { return time.Since(t).Round(time.Second).String() + " ago" },
 	}).ParseFS(webFS, "templates/*.html"))
-	return &Server{repo: repo, docker: docker, notify: notify, log: logger, tpl: tpl}
+	return &Server{repo: repo, docker: docker, notify: notify, log: logger, tpl: tpl, opts: opts}
 }
 
 func (s *Server) Routes() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.handleIndex)
-	mux.HandleFunc("/fragments/overview", s.handleOverviewFragment)
+	mux.HandleFunc("/fragments/overview", disabled(s.opts.MetricsDisabled, "metric collection", s.handleOverviewFragment))
+	mux.HandleFunc("/fragments/top", disabled(s.opts.MetricsDisabled, "metric collection", s.handleTopFragment))
+	mux.HandleFunc("/fragments/hosts", s.handleHostsFragment)
+	mux.HandleFunc("/fragments/sites", s.handleSitesFragment)
 	mux.HandleFunc("/fragments/services", s.handleServicesFragment)
-	mux.HandleFunc("/fragments/alerts", s.handleAlertsFragment)
-	mux.HandleFunc("/fragments/alerts/cleanup", s.handleAlertsCleanup)
-	mux.HandleFunc("/fragments/restarts", s.handleRestartAlertsFragment)
-	mux.HandleFunc("/fragments/logs", s.handleLogsFragment)
+	mux.HandleFunc("/fragments/services/lifecycle", s.handleServicesLifecycle)
+	mux.HandleFunc("/fragments/alerts", disabled(s.opts.AlertsDisabled, "alerting", s.handleAlertsFragment))
+	mux.HandleFunc("/fragments/alerts/cleanup", disabled(s.opts.AlertsDisabled, "alerting", s.handleAlertsCleanup))
+	mux.HandleFunc("/fragments/alerts/", disabled(s.opts.AlertsDisabled, "alerting", s.handleAlertNotesFragment))
+	mux.HandleFunc("/fragments/restarts", disabled(s.opts.AlertsDisabled, "alerting", s.handleRestartAlertsFragment))
+	mux.HandleFunc("/fragments/logs", disabled(s.opts.LogsDisabled, "log ingestion", s.handleLogsFragment))
+	mux.HandleFunc("/fragments/logs/stream", disabled(s.opts.LogsDisabled, "log ingestion", s.handleLogsStream))
+	mux.HandleFunc("/fragments/logs/histogram", disabled(s.opts.LogsDisabled, "log ingestion", s.handleLogsHistogramFragment))
+	mux.HandleFunc("/fragments/processes", s.handleProcessesFragment)
+	mux.HandleFunc("/fragments/config", s.handleConfigFragment)
 	mux.HandleFunc("/fragments/service/", s.handleServiceSubroutes)
+	mux.HandleFunc("/containers/", s.handleContainerPage)
+	mux.HandleFunc("/fragments/container/", s.handleContainerSubroutes)
+	mux.HandleFunc("/storage", s.handleStorage)
+	mux.HandleFunc("/fragments/prune", s.handlePruneFragment)
+	mux.HandleFunc("/uptime", s.handleUptime)
+	mux.HandleFunc("/topology", s.handleTopology)
+	mux.HandleFunc("/fragments/topology", s.handleTopologyFragment)
+	mux.HandleFunc("/exporters", disabled(s.opts.MetricsDisabled, "metric collection", s.handleExporters))
+	mux.HandleFunc("/exporters/targets", disabled(s.opts.MetricsDisabled, "metric collection", s.handleExportersSettings))
+	mux.HandleFunc("/exporters/panels", disabled(s.opts.MetricsDisabled, "metric collection", s.handleExportersSettings))
+	mux.HandleFunc("/internals", s.handleInternals)
+	mux.HandleFunc("/fragments/checks", s.handleChecksFragment)
+	mux.HandleFunc("/fragments/checks/delete", s.handleChecksDelete)
+	mux.HandleFunc("/fragments/heartbeats", s.handleHeartbeatsFragment)
+	mux.HandleFunc("/fragments/heartbeats/delete", s.handleHeartbeatsDelete)
+	mux.HandleFunc("/ping/", s.handlePing)
+	mux.HandleFunc("/api/push/", s.handleKumaPush)
 	mux.HandleFunc("/settings", s.handleSettings)
-	mux.HandleFunc("/settings/telegram", s.handleSettingsTelegram)
-	mux.HandleFunc("/settings/rules", s.handleSettingsRules)
-	mux.HandleFunc("/api/metrics/host", s.handleHostMetricsAPI)
-	mux.HandleFunc("/api/metrics/container/", s.handleContainerMetricsAPI)
-	mux.HandleFunc("/api/logs", s.handleLogsAPI)
-	mux.HandleFunc("/api/alerts/test-telegram", s.handleTestTelegram)
+	mux.HandleFunc("/settings/telegram", disabled(s.opts.AlertsDisabled, "alerting", s.handleSettingsTelegram))
+	mux.HandleFunc("/settings/rules", disabled(s.opts.AlertsDisabled, "alerting", s.handleSettingsRules))
+	mux.HandleFunc("/settings/retention", s.handleSettingsRetention)
+	mux.HandleFunc("/settings/log-drops", disabled(s.opts.LogsDisabled, "log ingestion", s.handleSettingsLogDrops))
+	mux.HandleFunc("/settings/log-extract", disabled(s.opts.LogsDisabled, "log ingestion", s.handleSettingsLogExtract))
+	mux.HandleFunc("/settings/reload", s.handleSettingsReload)
+	s.registerAPIV1(mux)
+	mux.HandleFunc("/api/search", s.handleSearch)
+	mux.HandleFunc("/fragments/search", s.handleSearchFragment)
+	mux.HandleFunc(grafanaPrefix, disabled(s.opts.MetricsDisabled, "metric collection", s.handleGrafana))
+	mux.HandleFunc(grafanaPrefix+"/", disabled(s.opts.MetricsDisabled, "metric collection", s.handleGrafana))
+	mux.HandleFunc("/api/metrics/host", disabled(s.opts.MetricsDisabled, "metric collection", deprecated(apiV1Prefix+"/metrics/host", s.handleHostMetricsAPI)))
+	mux.HandleFunc("/api/metrics/container/", disabled(s.opts.MetricsDisabled, "metric collection", deprecated(apiV1Prefix+"/metrics/container/", s.handleContainerMetricsAPI)))
+	mux.HandleFunc("/api/logs", disabled(s.opts.LogsDisabled, "log ingestion", deprecated(apiV1Prefix+"/logs", s.handleLogsAPI)))
+	mux.HandleFunc("/api/alerts/test-telegram", disabled(s.opts.AlertsDisabled, "alerting", deprecated(apiV1Prefix+"/alerts/test-telegram", s.handleTestTelegram)))
+	mux.HandleFunc("/api/ingest/logs", s.handleIngestLogs)
+	mux.HandleFunc("/api/ingest/containers", s.handleIngestContainers)
+	mux.HandleFunc("/api/ingest/metrics", s.handleIngestMetrics)
+	mux.HandleFunc("/api/ingest/agent", s.handleIngestAgent)
+	mux.HandleFunc("/api/ingest/deployments", s.handleIngestDeployments)
+	mux.HandleFunc("/otlp/v1/logs", s.handleOTLPLogs)
+	mux.HandleFunc("/otlp/v1/metrics", s.handleOTLPMetrics)
 	mux.HandleFunc("/healthz", s.handleHealthz)
 	mux.HandleFunc("/readyz", s.handleReadyz)
 	staticFS, _ := fs.Sub(webFS, "static")
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticFS))))
-	return logMiddleware(mux, s.log)
+	csp := s.opts.CSP
+	if csp == "" {
+		csp = buildCSP(s.opts.CSPScriptSrc)
+	}
+	var h http.Handler = etagMiddleware(mux)
+	h = corsMiddleware(h, s.opts.CORSOrigins, s.opts.CORSMethods)
+	h = securityMiddleware(h, securityHeaders{
+		csp:            csp,
+		hstsMaxAge:     s.opts.HSTSMaxAge,
+		frameOptions:   s.opts.FrameOptions,
+		referrerPolicy: s.opts.ReferrerPolicy,
+	})
+	return logMiddleware(h, s.log)
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
@@ -68,22 +214,61 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	if err := s.tpl.ExecuteTemplate(w, "index.html", nil); err != nil {
+	name := r.URL.Query().Get("dashboard")
+	if name == "" {
+		name = defaultDashboard
+	}
+	dashboard, err := s.dashboard(r, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	host, hosts := queryHost(r), s.hostNames(r)
+	data := map[string]any{
+		"configWarnings": s.opts.ConfigWarnings,
+		"demo":           s.opts.Demo,
+		"host":           host,
+		"hosts":          hosts,
+		"dashboard":      name,
+	}
+	data["left"], data["main"] = s.dashboardColumns(dashboard, host, hosts)
+	if !s.opts.LogsDisabled {
+		data["logOverflow"] = s.recentLogOverflow(r.Context())
+	}
+	if err := s.tpl.ExecuteTemplate(w, "index.html", data); err != nil {
 		http.Error(w, err.Error(), 500)
 	}
 }
 
+// handleOverviewFragment shows the metrics of the machine dashi runs on,
+// with the alerts of all hosts, or of the ?host= it is scoped to.
 func (s *Server) handleOverviewFragment(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	host := queryHost(r)
+	if host != "" && host != docker.LocalHost {
+		s.handleHostOverview(w, r, host)
+		return
+	}
 	metric, err := s.repo.LatestHostMetric(ctx)
 	if err != nil {
 		http.Error(w, "no metrics yet", http.StatusServiceUnavailable)
 		return
 	}
 	alerts, _ := s.repo.ActiveAlertCount(ctx)
+	if host != "" {
+		byHost, _ := s.repo.ActiveAlertsByHost(ctx)
+		alerts = byHost[host]
+	}
+	reboots, _ := s.repo.RecentHostReboots(ctx, 5)
 	data := map[string]any{
 		"metric":       metric,
+		"reboots":      api.HostRebootsFrom(reboots),
 		"mem_pct":      pct(metric.MemUsedBytes, metric.MemTotalBytes),
+		"swap_pct":     pct(metric.SwapUsedBytes, metric.SwapTotalBytes),
 		"disk_pct":     pct(metric.DiskUsedBytes, metric.DiskTotalBytes),
 		"activeAlerts": alerts,
 	}
@@ -118,17 +303,31 @@ func (s *Server) handleServicesFragment(w http.ResponseWriter, r *http.Request)
 		}
 	}
 	includeMissing := r.URL.Query().Get("include_missing") == "1"
-	rows, err := s.repo.ListServicesWithHealth(r.Context(), minCPU, minMemMB*1024*1024, limit, includeMissing)
+	labels, err := queryLabels(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	host := queryHost(r)
+	rows, err := s.repo.ListServicesWithHealth(r.Context(), minCPU, minMemMB*1024*1024, limit, includeMissing, host, labels)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
+	for _, row := range rows {
+		row["endpoint_host"] = s.endpointHost(row["host"].(string), r)
+	}
 	_ = s.tpl.ExecuteTemplate(w, "fragment_services.html", map[string]any{
-		"services":   rows,
-		"minCPU":     minCPU,
-		"minMemMB":   minMemMB,
-		"limit":      limit,
-		"serviceCnt": len(rows),
+		"services":       rows,
+		"minCPU":         minCPU,
+		"minMemMB":       minMemMB,
+		"limit":          limit,
+		"labels":         labels.String(),
+		"host":           host,
+		"includeMissing": includeMissing,
+		"serviceCnt":     len(rows),
+		"logs":           !s.opts.LogsDisabled,
+		"metrics":        !s.opts.MetricsDisabled,
 	})
 }
 
@@ -172,12 +371,30 @@ func (s *Server) handleAlertsCleanup(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) renderAlertsFragment(w http.ResponseWriter, r *http.Request) {
 	since := time.Now().Add(-24 * time.Hour)
-	alerts, err := s.repo.RecentAlerts(r.Context(), since, 100)
+	host := queryHost(r)
+	alerts, err := s.repo.RecentAlerts(r.Context(), since, 100, host)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if err := s.withAlertNotes(r.Context(), alerts); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	// Deployments interleave with the alerts so regressions line up with
+	// the release before them.
+	deployments, err := s.repo.Deployments(r.Context(), since, time.Now(), "", host, 100)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
-	_ = s.tpl.ExecuteTemplate(w, "fragment_alerts.html", map[string]any{"alerts": alerts})
+	if len(deployments) > 0 {
+		alerts = append(alerts, deploymentAlertRows(deployments)...)
+		sort.SliceStable(alerts, func(i, j int) bool {
+			return alerts[i]["started"].(time.Time).After(alerts[j]["started"].(time.Time))
+		})
+	}
+	_ = s.tpl.ExecuteTemplate(w, "fragment_alerts.html", map[string]any{"alerts": alerts, "host": host})
 }
 
 func (s *Server) handleRestartAlertsFragment(w http.ResponseWriter, r *http.Request) {
@@ -195,36 +412,91 @@ func (s *Server) handleLogsFragment(w http.ResponseWriter, r *http.Request) {
 	serviceID := r.URL.Query().Get("service")
 	level := r.URL.Query().Get("level")
 	stream := r.URL.Query().Get("stream")
-	from := queryRangeStart(r)
+	from, to, err := queryLogWindow(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 	if limit == 0 {
 		limit = 150
 	}
-	entries, err := s.repo.QueryLogs(r.Context(), serviceID, q, level, stream, from, nil, limit)
+	labels, err := queryLabels(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	host := queryHost(r)
+	lq := db.LogQuery{Host: host, Query: q, Level: level, Stream: stream, Labels: labels, From: from, To: to, Limit: limit}
+	services := splitServices(serviceID)
+	if len(services) == 1 {
+		lq.ServiceID = services[0]
+	} else {
+		lq.ServiceIDs = services
+	}
+	entries, err := s.repo.QueryLogs(r.Context(), lq)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
 	title := "Recent Logs"
-	if serviceID != "" {
-		title = "Logs for " + serviceID
+	if len(services) > 0 {
+		title = "Logs for " + strings.Join(services, ", ")
 	}
-	_ = s.tpl.ExecuteTemplate(w, "fragment_logs.html", map[string]any{
+	data := map[string]any{
 		"entries":   entries,
 		"serviceID": serviceID,
 		"title":     title,
 		"stream":    stream,
-	})
+		"limit":     limit,
+	}
+	if r.URL.Query().Get("follow") != "" {
+		data["follow"] = "/fragments/logs/stream?" + r.URL.RawQuery
+	}
+	data["histogram"] = "/fragments/logs/histogram?" + r.URL.RawQuery
+	if to != nil {
+		data["zoomed"] = true
+	}
+	_ = s.tpl.ExecuteTemplate(w, "fragment_logs.html", data)
+}
+
+// splitServices reads a comma-separated list of service IDs.
+func splitServices(v string) []string {
+	var out []string
+	for _, id := range strings.Split(v, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			out = append(out, id)
+		}
+	}
+	return out
 }
 
 func (s *Server) handleServiceSubroutes(w http.ResponseWriter, r *http.Request) {
-	// /fragments/service/{id}/logs
+	// /fragments/service/{id}/logs, /fragments/service/{id}/endpoints,
+	// /fragments/service/{id}/log-volume, /fragments/service/{id}/restarts,
+	// /fragments/service/{id}/availability
 	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
 	if len(parts) == 4 && parts[0] == "fragments" && parts[1] == "service" && parts[3] == "logs" {
 		svcID := parts[2]
 		s.handleServiceLogsFragment(w, r.WithContext(context.WithValue(r.Context(), "serviceID", svcID)))
 		return
 	}
+	if len(parts) == 4 && parts[0] == "fragments" && parts[1] == "service" && parts[3] == "endpoints" {
+		s.handleServiceEndpointsFragment(w, r, parts[2])
+		return
+	}
+	if len(parts) == 4 && parts[0] == "fragments" && parts[1] == "service" && parts[3] == "log-volume" {
+		s.handleServiceLogVolumeFragment(w, r, parts[2])
+		return
+	}
+	if len(parts) == 4 && parts[0] == "fragments" && parts[1] == "service" && parts[3] == "restarts" {
+		s.handleServiceRestartsFragment(w, r, parts[2])
+		return
+	}
+	if len(parts) == 4 && parts[0] == "fragments" && parts[1] == "service" && parts[3] == "availability" {
+		s.handleServiceAvailabilityFragment(w, r, parts[2])
+		return
+	}
 	http.NotFound(w, r)
 }
 
@@ -233,12 +505,16 @@ func (s *Server) handleServiceLogsFragment(w http.ResponseWriter, r *http.Reques
 	q := r.URL.Query().Get("q")
 	level := r.URL.Query().Get("level")
 	stream := r.URL.Query().Get("stream")
-	from := queryRangeStart(r)
+	from, to, err := queryLogWindow(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 	if limit == 0 {
 		limit = 200
 	}
-	entries, err := s.repo.QueryLogs(r.Context(), svcID, q, level, stream, from, nil, limit)
+	entries, err := s.repo.QueryLogs(r.Context(), db.LogQuery{ServiceID: svcID, Query: q, Level: level, Stream: stream, From: from, To: to, Limit: limit})
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
@@ -247,9 +523,143 @@ func (s *Server) handleServiceLogsFragment(w http.ResponseWriter, r *http.Reques
 }
 
 func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
-	token, chatID, _ := s.repo.LoadTelegramSettings(r.Context())
-	rules, _ := s.repo.ListRules(r.Context())
-	_ = s.tpl.ExecuteTemplate(w, "settings.html", map[string]any{"token": token, "chat_id": chatID, "rules": rules})
+	ctx := r.Context()
+	token := s.opts.Settings.String(ctx, "telegram.token", "")
+	chatID := s.opts.Settings.String(ctx, "telegram.chat_id", "")
+	rules, _ := s.repo.ListRules(ctx)
+	data := map[string]any{"token": token, "chat_id": chatID, "rules": rules, "configWarnings": s.opts.ConfigWarnings, "alerts": !s.opts.AlertsDisabled}
+	if s.opts.Retention != nil {
+		data["retention"] = s.opts.Retention.Policy(r.Context())
+	}
+	if s.opts.LogDrops != nil {
+		data["dropRulesJSON"] = s.settingJSON(ctx, logs.DropRulesKey)
+		data["logDrops"] = true
+		data["dropCounts"] = s.opts.LogDrops.Counts()
+	}
+	if s.opts.LogExtract != nil {
+		data["extractRulesJSON"] = s.settingJSON(ctx, logs.ExtractRulesKey)
+		data["logExtract"] = true
+		data["extractRules"] = s.opts.LogExtract.Rules()
+		data["grokPatterns"] = logs.GrokPatterns()
+	}
+	if s.opts.Reload != nil {
+		data["reload"] = true
+		data["configFile"] = s.opts.ConfigFile
+	}
+	_ = s.tpl.ExecuteTemplate(w, "settings.html", data)
+}
+
+func (s *Server) handleSettingsReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.opts.Reload == nil {
+		http.Error(w, "reload is not available", http.StatusNotImplemented)
+		return
+	}
+	if err := s.opts.Reload(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+// handleSettingsLogDrops saves the log drop rules from a JSON textarea; an
+// empty one removes them all.
+func (s *Server) handleSettingsLogDrops(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	rules := strings.TrimSpace(r.FormValue("rules"))
+	if rules == "" {
+		rules = "[]"
+	}
+	if err := s.opts.Settings.SetRaw(r.Context(), logs.DropRulesKey, json.RawMessage(rules)); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, settings.ErrInvalid) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+// settingJSON returns the indented value of key, or "" when it is unset.
+func (s *Server) settingJSON(ctx context.Context, key string) string {
+	var raw json.RawMessage
+	if ok, _ := s.opts.Settings.Get(ctx, key, &raw); !ok {
+		return ""
+	}
+	var buf bytes.Buffer
+	if json.Indent(&buf, raw, "", "  ") == nil {
+		raw = buf.Bytes()
+	}
+	return string(raw)
+}
+
+// handleSettingsLogExtract saves the log field extraction rules like
+// handleSettingsLogDrops.
+func (s *Server) handleSettingsLogExtract(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	rules := strings.TrimSpace(r.FormValue("rules"))
+	if rules == "" {
+		rules = "[]"
+	}
+	if err := s.opts.Settings.SetRaw(r.Context(), logs.ExtractRulesKey, json.RawMessage(rules)); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, settings.ErrInvalid) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+func (s *Server) handleSettingsRetention(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	days := func(name string) (int, bool) {
+		n, err := strconv.Atoi(strings.TrimSpace(r.FormValue(name)))
+		return n, err == nil && n > 0 && n <= retention.MaxDays
+	}
+	var p models.RetentionPolicy
+	var ok [4]bool
+	p.LogsDays, ok[0] = days("logs_days")
+	p.MetricsDays, ok[1] = days("metrics_days")
+	p.RollupDays, ok[2] = days("rollups_days")
+	p.AlertsDays, ok[3] = days("alerts_days")
+	for _, v := range ok {
+		if !v {
+			http.Error(w, "retention days must be between 1 and 3650", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := s.opts.Retention.Save(r.Context(), p); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
 }
 
 func (s *Server) handleSettingsTelegram(w http.ResponseWriter, r *http.Request) {
@@ -263,11 +673,12 @@ func (s *Server) handleSettingsTelegram(w http.ResponseWriter, r *http.Request)
 	}
 	token := strings.TrimSpace(r.FormValue("token"))
 	chatID := strings.TrimSpace(r.FormValue("chat_id"))
-	if err := s.repo.SaveTelegramSettings(r.Context(), token, chatID); err != nil {
-		http.Error(w, err.Error(), 500)
-		return
+	for key, v := range map[string]string{"telegram.token": token, "telegram.chat_id": chatID} {
+		if err := s.opts.Settings.Set(r.Context(), key, v); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
 	}
-	s.notify.Update(token, chatID)
 	http.Redirect(w, r, "/settings", http.StatusSeeOther)
 }
 
@@ -285,10 +696,19 @@ func (s *Server) handleSettingsRules(w http.ResponseWriter, r *http.Request) {
 	forSec, _ := strconv.Atoi(r.FormValue("for_seconds"))
 	cooldown, _ := strconv.Atoi(r.FormValue("cooldown_seconds"))
 	enabled := r.FormValue("enabled") == "on"
+	sel, err := db.ParseLabelSelector(r.FormValue("label_selector"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err := s.repo.UpdateRuleThresholds(r.Context(), id, th, forSec, cooldown, enabled); err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
+	if err := s.repo.UpdateRuleLabelSelector(r.Context(), id, sel); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
 	http.Redirect(w, r, "/settings", http.StatusSeeOther)
 }
 
@@ -322,25 +742,36 @@ func (s *Server) handleLogsAPI(w http.ResponseWriter, r *http.Request) {
 	serviceID := r.URL.Query().Get("service")
 	level := r.URL.Query().Get("level")
 	stream := r.URL.Query().Get("stream")
-	groupBy := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("group_by")))
+	groupBy := queryGroupBy(r)
 	from := queryRangeStart(r)
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	labels, err := queryLabels(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields := queryFields(r)
+	if err := validateFields(fields); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lq := db.LogQuery{ServiceID: serviceID, Query: q, Level: level, Stream: stream, Labels: labels, Fields: fields, From: from, Limit: limit}
 
 	if groupBy != "" {
-		groups, err := s.repo.GroupLogs(r.Context(), groupBy, serviceID, q, level, stream, from, nil, limit)
+		groups, err := s.repo.GroupLogs(r.Context(), groupBy, lq)
 		if err != nil {
 			http.Error(w, err.Error(), 400)
 			return
 		}
 		writeJSON(w, map[string]any{
 			"group_by": groupBy,
-			"filters":  map[string]any{"service": serviceID, "q": q, "level": level, "stream": stream, "range": r.URL.Query().Get("range")},
+			"filters":  map[string]any{"service": serviceID, "q": q, "level": level, "stream": stream, "range": r.URL.Query().Get("range"), "fields": fields},
 			"groups":   groups,
 		})
 		return
 	}
 
-	entries, err := s.repo.QueryLogs(r.Context(), serviceID, q, level, stream, from, nil, limit)
+	entries, err := s.repo.QueryLogs(r.Context(), lq)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
@@ -348,6 +779,47 @@ func (s *Server) handleLogsAPI(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, entries)
 }
 
+// queryFields collects the ?field.<name>=<value> filters on extracted log
+// fields, or nil when there are none.
+func queryFields(r *http.Request) map[string]string {
+	var out map[string]string
+	for k, v := range r.URL.Query() {
+		name, ok := strings.CutPrefix(k, "field.")
+		if !ok || len(v) == 0 {
+			continue
+		}
+		if out == nil {
+			out = map[string]string{}
+		}
+		out[name] = v[0]
+	}
+	return out
+}
+
+// queryGroupBy reads ?group_by=, lowercased except for the names of
+// extracted fields (field.<name>), which are case-sensitive.
+func queryGroupBy(r *http.Request) string {
+	v := strings.TrimSpace(r.URL.Query().Get("group_by"))
+	if strings.HasPrefix(v, "field.") {
+		return v
+	}
+	return strings.ToLower(v)
+}
+
+func validateFields(fields map[string]string) error {
+	for name := range fields {
+		if !db.FieldName.MatchString(name) {
+			return fmt.Errorf("invalid field name %q", name)
+		}
+	}
+	return nil
+}
+
+// queryLabels parses the ?labels= selector, e.g. "env=prod,tier!=db".
+func queryLabels(r *http.Request) (db.LabelSelector, error) {
+	return db.ParseLabelSelector(r.URL.Query().Get("labels"))
+}
+
 func queryRangeStart(r *http.Request) *time.Time {
 	v := strings.TrimSpace(r.URL.Query().Get("range"))
 	if v == "" {
@@ -381,9 +853,11 @@ func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "db not ready", 503)
 		return
 	}
-	if err := s.docker.Ping(r.Context()); err != nil {
-		http.Error(w, "docker not ready", 503)
-		return
+	if s.docker != nil {
+		if err := s.docker.Ping(r.Context()); err != nil {
+			http.Error(w, "docker not ready", 503)
+			return
+		}
 	}
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("ready"))
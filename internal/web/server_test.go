@@ -0,0 +1,25 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"dashi/internal/db"
+)
+
+// newTestServer returns a server over a fresh migrated database in a temp
+// dir, without Docker or notifications, along with its repository.
+func newTestServer(t *testing.T, opts Options) (*Server, *db.Repository) {
+	t.Helper()
+	sqldb, err := db.Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = sqldb.Close() })
+	if err := db.Migrate(sqldb); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+	repo := db.NewRepository(sqldb)
+	return NewServer(repo, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)), opts), repo
+}
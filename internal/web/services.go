@@ -0,0 +1,214 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/docker"
+	"dashi/internal/models"
+)
+
+// handleV1Service returns a service with the ports and networks of its
+// containers.
+func (s *Server) handleV1Service(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, apiV1Prefix+"/services/")
+	if svcID, ok := strings.CutSuffix(id, "/log-metrics"); ok && svcID != "" && !strings.Contains(svcID, "/") {
+		s.handleV1ServiceLogMetrics(w, r, svcID)
+		return
+	}
+	if svcID, ok := strings.CutSuffix(id, "/availability"); ok && svcID != "" && !strings.Contains(svcID, "/") {
+		s.handleV1ServiceAvailability(w, r, svcID)
+		return
+	}
+	if id == "" || strings.Contains(id, "/") {
+		writeAPIError(w, http.StatusNotFound, "service not found")
+		return
+	}
+	svc, containers, err := s.repo.ServiceDetail(r.Context(), id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeAPIError(w, http.StatusNotFound, "service not found")
+	case err != nil:
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, api.ServiceDetailFrom(svc, containers))
+	}
+}
+
+// handleV1ServiceLogMetrics returns the per-minute log volume of a
+// service.
+func (s *Server) handleV1ServiceLogMetrics(w http.ResponseWriter, r *http.Request, id string) {
+	rng := parseRange(r.URL.Query().Get("range"))
+	metrics, err := s.repo.ServiceLogMetrics(r.Context(), id, time.Now().Add(-rng))
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.ServiceLogMetricsFrom(id, rng.String(), metrics))
+}
+
+// logVolumeBars is the number of bars of the log volume chart.
+const logVolumeBars = 60
+
+// logVolumeBar is one bar of the chart, in SVG units with y pointing down.
+type logVolumeBar struct {
+	X, Y, ErrorY, Height, ErrorHeight float64
+	Label                             string
+}
+
+// volumeSeries picks what a volume chart shows of each minute: a total and
+// the part of it drawn highlighted.
+type volumeSeries struct {
+	count          func(models.ServiceLogMetric) (total, part int64)
+	unit, partUnit string
+}
+
+var (
+	logLineSeries = volumeSeries{func(m models.ServiceLogMetric) (int64, int64) { return m.Lines, m.ErrorLines }, "lines", "errors"}
+	requestSeries = volumeSeries{func(m models.ServiceLogMetric) (int64, int64) { return m.Requests, m.Requests5xx }, "requests", "5xx"}
+)
+
+// logVolumeWindow returns the start of the first of the logVolumeBars
+// buckets ending at now and their width.
+func logVolumeWindow(now time.Time, rng time.Duration) (time.Time, time.Duration) {
+	step := max(rng/logVolumeBars, time.Minute)
+	return now.Truncate(time.Minute).Add(-step * (logVolumeBars - 1)), step
+}
+
+// logVolumeChart sums a series of metrics into logVolumeBars buckets ending
+// at now and scales them to a chart of the given height.
+func logVolumeChart(metrics []models.ServiceLogMetric, series volumeSeries, now time.Time, rng time.Duration, height float64) ([]logVolumeBar, int64) {
+	start, step := logVolumeWindow(now, rng)
+	var lines, errs [logVolumeBars]int64
+	for _, m := range metrics {
+		i := int(m.TS.Sub(start) / step)
+		if i < 0 || i >= logVolumeBars {
+			continue
+		}
+		total, part := series.count(m)
+		lines[i] += total
+		errs[i] += part
+	}
+	var peak int64
+	for _, n := range lines {
+		peak = max(peak, n)
+	}
+	bars := make([]logVolumeBar, logVolumeBars)
+	for i := range bars {
+		b := logVolumeBar{X: float64(i * 10), Label: fmt.Sprintf("%s: %d %s, %d %s", start.Add(step*time.Duration(i)).Format("15:04"), lines[i], series.unit, errs[i], series.partUnit)}
+		if peak > 0 {
+			b.Height = height * float64(lines[i]) / float64(peak)
+			b.ErrorHeight = height * float64(errs[i]) / float64(peak)
+		}
+		b.Y, b.ErrorY = height-b.Height, height-b.ErrorHeight
+		bars[i] = b
+	}
+	return bars, peak
+}
+
+// handleServiceLogVolumeFragment charts the lines and error lines a service
+// logged over the selected range, and the requests and 5xx responses of its
+// access log when it has one, marking its deployments.
+func (s *Server) handleServiceLogVolumeFragment(w http.ResponseWriter, r *http.Request, id string) {
+	param := r.URL.Query().Get("range")
+	if param == "" {
+		param = "1h"
+	}
+	rng := parseRange(param)
+	now := time.Now().UTC()
+	data := map[string]any{"serviceID": id, "range": param}
+	metrics, err := s.repo.ServiceLogMetrics(r.Context(), id, now.Add(-rng-time.Minute))
+	if err != nil {
+		data["error"] = err.Error()
+	} else {
+		var total models.ServiceLogMetric
+		for _, m := range metrics {
+			total.Lines += m.Lines
+			total.ErrorLines += m.ErrorLines
+			total.Bytes += m.Bytes
+			total.Requests += m.Requests
+			total.Requests5xx += m.Requests5xx
+			total.TimedRequests += m.TimedRequests
+			total.LatencyMS += m.LatencyMS
+		}
+		minutes := rng.Minutes()
+		start, step := logVolumeWindow(now, rng)
+		if deployments, err := s.repo.Deployments(r.Context(), start, now, id, "", 50); err == nil {
+			data["deploys"] = deployMarkers(deployments, start, step/10, 10*logVolumeBars)
+		}
+		data["bars"], data["peak"] = logVolumeChart(metrics, logLineSeries, now, rng, 100)
+		data["linesPerMin"] = float64(total.Lines) / minutes
+		data["errorsPerMin"] = float64(total.ErrorLines) / minutes
+		data["bytesPerMin"] = int64(float64(total.Bytes) / minutes)
+		if total.Requests > 0 {
+			data["requestBars"], data["requestPeak"] = logVolumeChart(metrics, requestSeries, now, rng, 100)
+			data["requestsPerMin"] = float64(total.Requests) / minutes
+			data["errorPct"] = 100 * float64(total.Requests5xx) / float64(total.Requests)
+			if total.TimedRequests > 0 {
+				data["avgLatencyMS"] = total.LatencyMS / float64(total.TimedRequests)
+			}
+		}
+	}
+	_ = s.tpl.ExecuteTemplate(w, "fragment_log_volume.html", data)
+}
+
+// handleServiceEndpointsFragment renders the ports and networks of a
+// service's containers.
+func (s *Server) handleServiceEndpointsFragment(w http.ResponseWriter, r *http.Request, id string) {
+	svc, containers, err := s.repo.ServiceDetail(r.Context(), id)
+	data := map[string]any{"service": svc, "containers": containers, "endpointHost": s.endpointHost(svc.Host, r)}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		data["error"] = "service not found"
+	case err != nil:
+		data["error"] = err.Error()
+	}
+	_ = s.tpl.ExecuteTemplate(w, "fragment_endpoints.html", data)
+}
+
+// endpointHost is the hostname published ports of dockerHost are reached
+// on: the host the dashboard was opened with for the local daemon, the
+// daemon address for tcp hosts, and empty when it is unknown.
+func (s *Server) endpointHost(dockerHost string, r *http.Request) string {
+	if dockerHost == "" || dockerHost == docker.LocalHost {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		return host
+	}
+	if c, ok := s.opts.DockerHosts[dockerHost]; ok {
+		return c.Hostname()
+	}
+	return ""
+}
+
+// portURL links a published TCP port, empty for unpublished and UDP ports
+// and for ports bound to loopback on another machine.
+func portURL(hostname string, p models.Port) string {
+	if hostname == "" || p.HostPort == 0 || p.Protocol != "tcp" {
+		return ""
+	}
+	if ip := net.ParseIP(p.HostIP); ip != nil && ip.IsLoopback() {
+		if h := net.ParseIP(hostname); hostname != "localhost" && (h == nil || !h.IsLoopback()) {
+			return ""
+		}
+	} else if ip != nil && !ip.IsUnspecified() {
+		hostname = p.HostIP
+	}
+	scheme := "http"
+	if p.ContainerPort == 443 || p.ContainerPort == 8443 {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/", scheme, net.JoinHostPort(hostname, fmt.Sprint(p.HostPort)))
+}
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"dashi/internal/api"
+	"dashi/internal/settings"
+)
+
+func (s *Server) handleV1Settings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	all, err := s.opts.Settings.List(r.Context(), strings.TrimSpace(r.URL.Query().Get("namespace")))
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	out := api.Settings{Items: make([]api.Setting, 0, len(all))}
+	for k, v := range all {
+		out.Items = append(out.Items, settingFrom(k, v))
+	}
+	sort.Slice(out.Items, func(i, j int) bool { return out.Items[i].Key < out.Items[j].Key })
+	writeJSON(w, out)
+}
+
+func (s *Server) handleV1Setting(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, apiV1Prefix+"/settings/")
+	if key == "" || strings.Contains(key, "/") {
+		writeAPIError(w, http.StatusNotFound, "setting not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		var v json.RawMessage
+		ok, err := s.opts.Settings.Get(r.Context(), key, &v)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !ok {
+			writeAPIError(w, http.StatusNotFound, "setting not found")
+			return
+		}
+		writeJSON(w, settingFrom(key, v))
+	case http.MethodPut:
+		raw, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := s.opts.Settings.SetRaw(r.Context(), key, raw); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, settings.ErrInvalid) {
+				status = http.StatusBadRequest
+			}
+			writeAPIError(w, status, err.Error())
+			return
+		}
+		writeJSON(w, settingFrom(key, raw))
+	case http.MethodDelete:
+		if err := s.opts.Settings.Delete(r.Context(), key); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func settingFrom(key string, v json.RawMessage) api.Setting {
+	if settings.IsSecret(key) {
+		return api.Setting{Key: key, Secret: true}
+	}
+	return api.Setting{Key: key, Value: v}
+}
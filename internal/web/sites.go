@@ -0,0 +1,72 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"dashi/internal/api"
+)
+
+// topAlerts is how many of the newest firing alerts a summary lists.
+const topAlerts = 10
+
+// handleV1Summary serves what other dashi instances poll to show this one
+// as a site.
+func (s *Server) handleV1Summary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	sum, err := s.summary(r.Context())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, sum)
+}
+
+func (s *Server) summary(ctx context.Context) (api.Summary, error) {
+	now := time.Now()
+	hosts, err := s.repo.ListHosts(ctx)
+	if err != nil {
+		return api.Summary{}, err
+	}
+	sum := api.Summary{GeneratedAt: now.UTC(), Hosts: api.HostsFrom(hosts, now), TopAlerts: []api.Alert{}}
+	if s.opts.AlertsDisabled {
+		return sum, nil
+	}
+	if sum.FiringAlerts, err = s.repo.ActiveAlertCount(ctx); err != nil {
+		return api.Summary{}, err
+	}
+	firing, err := s.repo.FiringAlerts(ctx, topAlerts)
+	if err != nil {
+		return api.Summary{}, err
+	}
+	sum.TopAlerts = api.AlertsFrom(firing)
+	return sum, nil
+}
+
+func (s *Server) handleV1Sites(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	sites, err := s.repo.ListSites(r.Context())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.Sites{Items: api.SitesFrom(sites)})
+}
+
+// handleSitesFragment renders the federated sites with the hosts and
+// firing alerts of each, as of their last successful poll.
+func (s *Server) handleSitesFragment(w http.ResponseWriter, r *http.Request) {
+	sites, err := s.repo.ListSites(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	_ = s.tpl.ExecuteTemplate(w, "fragment_sites.html", map[string]any{"sites": api.SitesFrom(sites)})
+}
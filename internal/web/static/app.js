@@ -1,5 +1,7 @@
 (function () {
   var logsKey = 'dashi.logsFilter.v1';
+  var prefsURL = '/api/v1/preferences';
+  var serverPrefs = {};
 
   function syncVisibilityState() {
     document.body.dataset.visibility = document.hidden ? 'hidden' : 'visible';
@@ -7,15 +9,40 @@
 
   function saveLogsFilter(form) {
     var data = {};
-    var fields = form.querySelectorAll('input[name], select[name]');
+    var fields = form.querySelectorAll('input[name]:not([data-scope]), select[name]');
     for (var i = 0; i < fields.length; i++) {
-      data[fields[i].name] = fields[i].value;
+      data[fields[i].name] = fields[i].type === 'checkbox' ? fields[i].checked : fields[i].value;
     }
     try {
       localStorage.setItem(logsKey, JSON.stringify(data));
     } catch (e) {
       // ignore storage failures
     }
+    serverPrefs.filters = data;
+    if (window.fetch) {
+      fetch(prefsURL, {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(serverPrefs)
+      }).catch(function () {
+        // local copy is still kept
+      });
+    }
+  }
+
+  function applyLogsFilter(form, data) {
+    var fields = form.querySelectorAll('input[name]:not([data-scope]), select[name]');
+    for (var i = 0; i < fields.length; i++) {
+      var el = fields[i];
+      if (!Object.prototype.hasOwnProperty.call(data, el.name)) {
+        continue;
+      }
+      if (el.type === 'checkbox') {
+        el.checked = data[el.name] === true;
+      } else {
+        el.value = data[el.name];
+      }
+    }
   }
 
   function restoreLogsFilter(form) {
@@ -24,14 +51,7 @@
       if (!raw) {
         return;
       }
-      var data = JSON.parse(raw);
-      var fields = form.querySelectorAll('input[name], select[name]');
-      for (var i = 0; i < fields.length; i++) {
-        var el = fields[i];
-        if (Object.prototype.hasOwnProperty.call(data, el.name)) {
-          el.value = data[el.name];
-        }
-      }
+      applyLogsFilter(form, JSON.parse(raw));
     } catch (e) {
       // ignore storage/parsing failures
     }
@@ -43,6 +63,23 @@
       return;
     }
     restoreLogsFilter(form);
+    if (window.fetch) {
+      fetch(prefsURL, { credentials: 'same-origin' })
+        .then(function (res) { return res.ok ? res.json() : {}; })
+        .then(function (prefs) {
+          serverPrefs = prefs || {};
+          delete serverPrefs.updated_at;
+          if (serverPrefs.filters) {
+            applyLogsFilter(form, serverPrefs.filters);
+            if (window.htmx) {
+              window.htmx.trigger(form, 'submit');
+            }
+          }
+        })
+        .catch(function () {
+          // fall back to the local copy
+        });
+    }
     form.addEventListener('change', function () {
       saveLogsFilter(form);
     });
@@ -55,9 +92,112 @@
     }
   }
 
+  // Follow mode: the logs fragment names a stream of new rows matching its
+  // filters, which are prepended to the table as they arrive.
+  var logsSource = null;
+
+  function followLogs(panel) {
+    if (logsSource) {
+      logsSource.close();
+      logsSource = null;
+    }
+    var table = panel.querySelector('table[data-follow]');
+    if (!table || !window.EventSource) {
+      return;
+    }
+    var body = table.tBodies[0];
+    var limit = parseInt(table.dataset.limit, 10) || 150;
+    var state = document.getElementById('logs-follow-state');
+    logsSource = new EventSource(table.dataset.follow);
+    logsSource.addEventListener('row', function (event) {
+      var empty = body.querySelector('.log-empty');
+      if (empty) {
+        empty.remove();
+      }
+      body.insertAdjacentHTML('afterbegin', event.data);
+      while (body.rows.length > limit) {
+        body.deleteRow(body.rows.length - 1);
+      }
+    });
+    logsSource.addEventListener('skipped', function (event) {
+      if (state) {
+        state.textContent = 'Following (' + event.data + ' skipped)';
+      }
+    });
+    logsSource.onopen = function () {
+      if (state) {
+        state.textContent = 'Following';
+      }
+    };
+    logsSource.onerror = function () {
+      if (state) {
+        state.textContent = 'Reconnecting…';
+      }
+    };
+  }
+
+  // The host selector shows the chosen host as soon as it changes.
+  function setupAutosubmit() {
+    var selects = document.querySelectorAll('select[data-autosubmit]');
+    for (var i = 0; i < selects.length; i++) {
+      selects[i].addEventListener('change', function () {
+        this.form.submit();
+      });
+    }
+  }
+
+  // Hovering a node of the topology map highlights it, its edges and the
+  // nodes at their other ends.
+  function highlightTopology(event, on) {
+    var node = event.target.closest && event.target.closest('.topo-node');
+    var map = node && node.closest('.topology-map');
+    if (!map) {
+      return;
+    }
+    var near = map.querySelectorAll('.topo-near');
+    for (var i = 0; i < near.length; i++) {
+      near[i].classList.remove('topo-near');
+    }
+    map.classList.toggle('topo-focus', on);
+    if (!on) {
+      return;
+    }
+    var id = node.dataset.id;
+    var ids = {};
+    ids[id] = true;
+    var edges = map.querySelectorAll('.topo-edge');
+    for (var j = 0; j < edges.length; j++) {
+      if (edges[j].dataset.from === id || edges[j].dataset.to === id) {
+        edges[j].classList.add('topo-near');
+        ids[edges[j].dataset.from] = true;
+        ids[edges[j].dataset.to] = true;
+      }
+    }
+    var nodes = map.querySelectorAll('.topo-node');
+    for (var k = 0; k < nodes.length; k++) {
+      if (ids[nodes[k].dataset.id]) {
+        nodes[k].classList.add('topo-near');
+      }
+    }
+  }
+
   document.addEventListener('visibilitychange', syncVisibilityState);
   syncVisibilityState();
   setupLogsFilterPersistence();
+  setupAutosubmit();
+
+  document.body.addEventListener('mouseover', function (event) {
+    highlightTopology(event, true);
+  });
+  document.body.addEventListener('mouseout', function (event) {
+    highlightTopology(event, false);
+  });
+
+  document.body.addEventListener('htmx:afterSwap', function (event) {
+    if (event.detail && event.detail.target && event.detail.target.id === 'logs-panel') {
+      followLogs(event.detail.target);
+    }
+  });
 
   // Stop htmx polling requests while tab is hidden.
   document.body.addEventListener('htmx:beforeRequest', function (event) {
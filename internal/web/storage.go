@@ -0,0 +1,50 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"dashi/internal/docker"
+	"dashi/internal/models"
+)
+
+func (s *Server) handleStorage(w http.ResponseWriter, r *http.Request) {
+	volumes, images, err := s.storage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	pools, err := s.repo.ListStoragePools(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	audit, err := s.repo.ListAudit(r.Context(), 10)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = s.tpl.ExecuteTemplate(w, "storage.html", map[string]any{
+		"volumes":      volumes,
+		"images":       images,
+		"pools":        pools,
+		"hosts":        s.dockerHostNames(),
+		"pruneKinds":   docker.PruneKinds,
+		"pruneEnabled": s.opts.PruneEnabled,
+		"audit":        audit,
+	})
+}
+
+// storage loads the latest volume and image sizes; volume growth covers the
+// last 24 hours, matching the volume_growth_bytes alert metric.
+func (s *Server) storage(r *http.Request) ([]models.VolumeUsage, []models.ImageUsage, error) {
+	volumes, err := s.repo.ListVolumeUsage(r.Context(), time.Now().Add(-24*time.Hour))
+	if err != nil {
+		return nil, nil, err
+	}
+	images, err := s.repo.ListImageUsage(r.Context())
+	if err != nil {
+		return nil, nil, err
+	}
+	return volumes, images, nil
+}
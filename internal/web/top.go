@@ -0,0 +1,111 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/models"
+)
+
+// topKeys rank container usage by ?by= and ?stat=.
+var topKeys = map[string]map[string]func(models.ContainerUsage) float64{
+	"cpu":  {"avg": func(u models.ContainerUsage) float64 { return u.CPUAvg }, "max": func(u models.ContainerUsage) float64 { return u.CPUMax }},
+	"mem":  {"avg": func(u models.ContainerUsage) float64 { return u.MemAvg }, "max": func(u models.ContainerUsage) float64 { return float64(u.MemMax) }},
+	"net":  {"avg": func(u models.ContainerUsage) float64 { return u.NetAvg }, "max": func(u models.ContainerUsage) float64 { return u.NetMax }},
+	"disk": {"avg": func(u models.ContainerUsage) float64 { return u.BlkAvg }, "max": func(u models.ContainerUsage) float64 { return u.BlkMax }},
+}
+
+// topQuery is a top consumers request over the window of queryWindow.
+type topQuery struct {
+	from, to time.Time
+	res      time.Duration
+	by, stat string
+	host     string
+	limit    int
+}
+
+func parseTopQuery(r *http.Request) (topQuery, error) {
+	q := r.URL.Query()
+	t := topQuery{by: q.Get("by"), stat: q.Get("stat"), host: queryHost(r), limit: 10}
+	if t.by == "" {
+		t.by = "cpu"
+	}
+	if t.stat == "" {
+		t.stat = "avg"
+	}
+	if topKeys[t.by] == nil {
+		return t, errors.New("by must be one of cpu, mem, net, disk")
+	}
+	if topKeys[t.by][t.stat] == nil {
+		return t, errors.New("stat must be avg or max")
+	}
+	from, to, err := queryWindow(r)
+	if err != nil {
+		return t, err
+	}
+	t.from, t.to = from, to
+	res, ok := queryResolution(r, t.to.Sub(t.from))
+	if !ok {
+		return t, errors.New("invalid resolution")
+	}
+	t.res = res
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 {
+		t.limit = min(n, 100)
+	}
+	return t, nil
+}
+
+// topContainers returns the t.limit containers using the most of t.by
+// over the window, by their average or peak.
+func (s *Server) topContainers(ctx context.Context, t topQuery) ([]models.ContainerUsage, error) {
+	usage, err := s.repo.ContainerUsage(ctx, t.from, t.to, t.res, t.host)
+	if err != nil {
+		return nil, err
+	}
+	key := topKeys[t.by][t.stat]
+	sort.SliceStable(usage, func(i, j int) bool {
+		if a, b := key(usage[i]), key(usage[j]); a != b {
+			return a > b
+		}
+		return usage[i].Name < usage[j].Name
+	})
+	return usage[:min(len(usage), t.limit)], nil
+}
+
+// handleV1Top serves GET /api/v1/metrics/top, answering what was busiest
+// over a past window rather than right now.
+func (s *Server) handleV1Top(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	t, err := parseTopQuery(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	usage, err := s.topContainers(r.Context(), t)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, api.TopContainers{From: t.from, To: t.to, Resolution: resolutionName(t.res), By: t.by, Stat: t.stat, Items: api.ContainerUsageFrom(usage)})
+}
+
+func (s *Server) handleTopFragment(w http.ResponseWriter, r *http.Request) {
+	data := map[string]any{"range": r.URL.Query().Get("range"), "host": queryHost(r)}
+	t, err := parseTopQuery(r)
+	if err == nil {
+		data["usage"], err = s.topContainers(r.Context(), t)
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	data["by"], data["stat"], data["resolution"] = t.by, t.stat, resolutionName(t.res)
+	_ = s.tpl.ExecuteTemplate(w, "fragment_top.html", data)
+}
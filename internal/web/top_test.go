@@ -0,0 +1,91 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/models"
+)
+
+func TestTopContainers(t *testing.T) {
+	s, repo := newTestServer(t, Options{})
+	ctx := context.Background()
+	for _, id := range []string{"web", "batch"} {
+		svc := models.Service{ID: id, Name: id, Image: "img", LabelsJSON: "{}", Status: "running"}
+		if err := repo.UpsertServiceAndContainer(ctx, svc, models.Container{ID: id + "1", ServiceID: id, Name: id, Status: "running"}); err != nil {
+			t.Fatalf("store container: %v", err)
+		}
+	}
+	// web is steadily busy; batch idles but spiked once, last night.
+	night := time.Now().UTC().Add(-12 * time.Hour).Truncate(time.Hour)
+	for m := 0; m < 10; m++ {
+		ts := night.Add(time.Duration(m) * time.Minute)
+		batchCPU := 1.0
+		if m == 5 {
+			batchCPU = 95
+		}
+		for _, s := range []models.ContainerMetric{
+			{TS: ts, ContainerID: "web1", CPUPct: 20, MemUsedBytes: 100 << 20, NetRXRate: 1000},
+			{TS: ts, ContainerID: "batch1", CPUPct: batchCPU, MemUsedBytes: 10 << 20, BlkWriteRate: 5000},
+		} {
+			if err := repo.InsertContainerMetric(ctx, s); err != nil {
+				t.Fatalf("insert container metric: %v", err)
+			}
+		}
+	}
+	h := s.Routes()
+	top := func(query string) ([]api.ContainerUsage, *httptest.ResponseRecorder) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/metrics/top?"+query, nil))
+		var out api.TopContainers
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		return out.Items, rec
+	}
+	names := func(items []api.ContainerUsage) string {
+		var out []string
+		for _, u := range items {
+			out = append(out, u.Name)
+		}
+		return strings.Join(out, " ")
+	}
+
+	window := "from=" + night.Add(-time.Hour).Format(time.RFC3339) + "&to=" + night.Add(time.Hour).Format(time.RFC3339)
+	items, rec := top(window + "&resolution=raw")
+	if rec.Code != http.StatusOK || names(items) != "web batch" || items[0].Samples != 10 || items[0].CPUAvg != 20 {
+		t.Fatalf("top by cpu avg = %d %s", rec.Code, rec.Body)
+	}
+	if items, rec = top(window + "&resolution=raw&stat=max&limit=1"); names(items) != "batch" || items[0].CPUMax != 95 {
+		t.Fatalf("top by cpu max = %s", rec.Body)
+	}
+	if items, rec = top(window + "&resolution=raw&by=disk"); names(items) != "batch web" || items[0].BlkAvg != 5000 {
+		t.Fatalf("top by disk = %s", rec.Body)
+	}
+	if items, rec = top("range=1h&resolution=raw"); rec.Code != http.StatusOK || len(items) != 0 {
+		t.Fatalf("top of the last hour = %s", rec.Body)
+	}
+
+	if err := repo.RollupContainerMetrics(ctx, time.Minute, night.Add(-time.Hour), night.Add(time.Hour)); err != nil {
+		t.Fatalf("rollup: %v", err)
+	}
+	if items, rec = top(window + "&resolution=1m&stat=max&by=mem"); names(items) != "web batch" || items[0].MemMax != 100<<20 || items[1].CPUMax != 95 {
+		t.Fatalf("top from rollups = %s", rec.Body)
+	}
+
+	for _, q := range []string{"by=gpu", "stat=p99", "from=yesterday", "from=" + night.Format(time.RFC3339) + "&to=" + night.Add(-time.Hour).Format(time.RFC3339)} {
+		if _, rec := top(q); rec.Code != http.StatusBadRequest {
+			t.Errorf("top?%s = %d", q, rec.Code)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fragments/top?range=24h&by=net", nil))
+	if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, `href="/containers/web1"`) || strings.Index(body, "web1") > strings.Index(body, "batch1") {
+		t.Fatalf("fragment = %d %s", rec.Code, body)
+	}
+}
@@ -0,0 +1,277 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"dashi/internal/api"
+	"dashi/internal/docker"
+)
+
+// topologySkipNetworks connect nothing by name: containers on the default
+// bridge cannot resolve each other, host and none are not networks.
+var topologySkipNetworks = map[string]bool{"bridge": true, "host": true, "none": true}
+
+// networkNodeID names a network node like service IDs: bare for the local
+// host, "name@host" for others, as each host has its own networks.
+func networkNodeID(name, host string) string {
+	if host == "" || host == docker.LocalHost {
+		return "network:" + name
+	}
+	return "network:" + name + "@" + host
+}
+
+// topology builds the service graph, of one host when host is set.
+// Dependencies are read from the com.docker.compose.depends_on label, as
+// "db:service_started:false,cache:service_healthy:true", and resolve to the
+// services of the same compose project on the same host; links to the
+// services of the linked containers.
+func (s *Server) topology(ctx context.Context, host string) (api.Topology, error) {
+	services, err := s.repo.ListServices(ctx)
+	if err != nil {
+		return api.Topology{}, fmt.Errorf("list services: %w", err)
+	}
+	containers, err := s.repo.ListContainers(ctx)
+	if err != nil {
+		return api.Topology{}, fmt.Errorf("list containers: %w", err)
+	}
+	firing, err := s.repo.FiringAlertsByTarget(ctx)
+	if err != nil {
+		return api.Topology{}, fmt.Errorf("count alerts: %w", err)
+	}
+	out := api.Topology{Nodes: []api.TopologyNode{}, Edges: []api.TopologyEdge{}}
+	nodes := map[string]*api.TopologyNode{}
+	labels := map[string]map[string]string{}
+	byCompose := map[string]string{}
+	for _, svc := range services {
+		if host != "" && svc.Host != host {
+			continue
+		}
+		l := map[string]string{}
+		_ = json.Unmarshal([]byte(svc.LabelsJSON), &l)
+		labels[svc.ID] = l
+		project := l["com.docker.compose.project"]
+		if name := l["com.docker.compose.service"]; name != "" {
+			byCompose[svc.Host+"\x00"+project+"\x00"+name] = svc.ID
+		}
+		nodes[svc.ID] = &api.TopologyNode{ID: svc.ID, Type: "service", Name: svc.Name, Host: svc.Host, Project: project, Alerts: firing[svc.ID]}
+	}
+	edges := map[api.TopologyEdge]bool{}
+	addEdge := func(e api.TopologyEdge) {
+		if e.From != e.To && !edges[e] {
+			edges[e] = true
+			out.Edges = append(out.Edges, e)
+		}
+	}
+	byName := map[string]string{}
+	unhealthy := map[string]bool{}
+	for _, c := range containers {
+		n := nodes[c.ServiceID]
+		// Missing containers were removed or replaced, e.g. by a compose
+		// recreate, and are only listed until they are archived.
+		if n == nil || c.Status == "missing" {
+			continue
+		}
+		byName[c.Host+"\x00"+c.Name] = c.ServiceID
+		n.Containers++
+		n.Alerts += firing[c.ID]
+		if c.Status == "running" {
+			n.Running++
+		}
+		// The node links to its first running container, else to any.
+		if n.ContainerID == "" || c.Status == "running" && n.Running == 1 {
+			n.ContainerID = c.ID
+		}
+		unhealthy[c.ServiceID] = unhealthy[c.ServiceID] || c.Health == "unhealthy"
+		for _, net := range c.Networks {
+			if topologySkipNetworks[net.Name] {
+				continue
+			}
+			id := networkNodeID(net.Name, c.Host)
+			if nodes[id] == nil {
+				nodes[id] = &api.TopologyNode{ID: id, Type: "network", Name: net.Name, Host: c.Host}
+			}
+			addEdge(api.TopologyEdge{From: c.ServiceID, To: id, Kind: "network"})
+		}
+	}
+	for _, c := range containers {
+		if nodes[c.ServiceID] == nil || c.Status == "missing" {
+			continue
+		}
+		for _, target := range c.Links {
+			if to := byName[c.Host+"\x00"+target]; to != "" {
+				addEdge(api.TopologyEdge{From: c.ServiceID, To: to, Kind: "link"})
+			}
+		}
+	}
+	for id, l := range labels {
+		for _, dep := range strings.Split(l["com.docker.compose.depends_on"], ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(dep), ":")
+			if to := byCompose[nodes[id].Host+"\x00"+l["com.docker.compose.project"]+"\x00"+name]; name != "" && to != "" {
+				addEdge(api.TopologyEdge{From: id, To: to, Kind: "depends_on"})
+			}
+		}
+	}
+	for id, n := range nodes {
+		if n.Type == "service" {
+			switch {
+			case n.Running == 0:
+				n.Health = "down"
+			case n.Alerts > 0:
+				n.Health = "alerting"
+			case n.Running < n.Containers || unhealthy[id]:
+				n.Health = "degraded"
+			default:
+				n.Health = "ok"
+			}
+		}
+		out.Nodes = append(out.Nodes, *n)
+	}
+	sort.Slice(out.Nodes, func(i, j int) bool {
+		a, b := out.Nodes[i], out.Nodes[j]
+		if a.Type != b.Type {
+			return a.Type > b.Type
+		}
+		return a.ID < b.ID
+	})
+	sort.Slice(out.Edges, func(i, j int) bool {
+		a, b := out.Edges[i], out.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Kind < b.Kind
+	})
+	return out, nil
+}
+
+// topologyWidth and topologyHeight are the size of the map's view box.
+const (
+	topologyWidth  = 1000.0
+	topologyHeight = 640.0
+	// topologyNodeRadius is the radius of a service's circle plus a gap.
+	topologyNodeRadius = 18.0
+)
+
+type topoPoint struct{ X, Y float64 }
+
+// layoutTopology places the nodes with a force-directed layout: nodes push
+// each other apart and edges pull theirs together, starting from a circle
+// so the same graph is always drawn the same.
+func layoutTopology(t api.Topology) map[string]topoPoint {
+	pos := make(map[string]topoPoint, len(t.Nodes))
+	n := len(t.Nodes)
+	cx, cy, margin := topologyWidth/2, topologyHeight/2, 40.0
+	for i, node := range t.Nodes {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		pos[node.ID] = topoPoint{cx + (cx-margin)*math.Cos(a)*0.8, cy + (cy-margin)*math.Sin(a)*0.8}
+	}
+	if n < 2 {
+		for id := range pos {
+			pos[id] = topoPoint{cx, cy}
+		}
+		return pos
+	}
+	k := math.Sqrt(topologyWidth * topologyHeight / float64(n))
+	const iterations = 200
+	temp := topologyWidth / 10
+	for it := 0; it < iterations; it++ {
+		disp := make(map[string]topoPoint, n)
+		for i, a := range t.Nodes {
+			for _, b := range t.Nodes[i+1:] {
+				pa, pb := pos[a.ID], pos[b.ID]
+				dx, dy := pa.X-pb.X, pa.Y-pb.Y
+				d := math.Max(math.Hypot(dx, dy), 0.01)
+				f := k * k / d
+				da, db := disp[a.ID], disp[b.ID]
+				disp[a.ID] = topoPoint{da.X + dx/d*f, da.Y + dy/d*f}
+				disp[b.ID] = topoPoint{db.X - dx/d*f, db.Y - dy/d*f}
+			}
+		}
+		for _, e := range t.Edges {
+			pa, pb := pos[e.From], pos[e.To]
+			dx, dy := pa.X-pb.X, pa.Y-pb.Y
+			d := math.Max(math.Hypot(dx, dy), 0.01)
+			f := d * d / k
+			da, db := disp[e.From], disp[e.To]
+			disp[e.From] = topoPoint{da.X - dx/d*f, da.Y - dy/d*f}
+			disp[e.To] = topoPoint{db.X + dx/d*f, db.Y + dy/d*f}
+		}
+		for id, p := range pos {
+			d := disp[id]
+			l := math.Max(math.Hypot(d.X, d.Y), 0.01)
+			step := math.Min(l, temp)
+			pos[id] = topoPoint{
+				math.Min(topologyWidth-margin, math.Max(margin, p.X+d.X/l*step)),
+				math.Min(topologyHeight-margin, math.Max(margin, p.Y+d.Y/l*step)),
+			}
+		}
+		temp = math.Max(temp*0.98, 1)
+	}
+	return pos
+}
+
+// topoNode and topoEdge are what the map template draws.
+type topoNode struct {
+	api.TopologyNode
+	X, Y float64
+}
+
+type topoEdge struct {
+	api.TopologyEdge
+	X1, Y1, X2, Y2 float64
+}
+
+// handleV1Topology serves GET /api/v1/topology?host=.
+func (s *Server) handleV1Topology(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	t, err := s.topology(r.Context(), r.URL.Query().Get("host"))
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, t)
+}
+
+func (s *Server) handleTopology(w http.ResponseWriter, r *http.Request) {
+	_ = s.tpl.ExecuteTemplate(w, "topology.html", map[string]any{"host": r.URL.Query().Get("host"), "hosts": s.dockerHostNames()})
+}
+
+// handleTopologyFragment draws the topology as an SVG map.
+func (s *Server) handleTopologyFragment(w http.ResponseWriter, r *http.Request) {
+	t, err := s.topology(r.Context(), r.URL.Query().Get("host"))
+	if err != nil {
+		_ = s.tpl.ExecuteTemplate(w, "fragment_topology.html", map[string]any{"error": err.Error()})
+		return
+	}
+	pos := layoutTopology(t)
+	nodes := make([]topoNode, 0, len(t.Nodes))
+	for _, n := range t.Nodes {
+		nodes = append(nodes, topoNode{TopologyNode: n, X: pos[n.ID].X, Y: pos[n.ID].Y})
+	}
+	edges := make([]topoEdge, 0, len(t.Edges))
+	for _, e := range t.Edges {
+		from, to := pos[e.From], pos[e.To]
+		// Arrows end at the edge of the service they point to.
+		if e.Kind != "network" {
+			if d := math.Hypot(to.X-from.X, to.Y-from.Y); d > topologyNodeRadius {
+				to.X -= (to.X - from.X) / d * topologyNodeRadius
+				to.Y -= (to.Y - from.Y) / d * topologyNodeRadius
+			}
+		}
+		edges = append(edges, topoEdge{TopologyEdge: e, X1: from.X, Y1: from.Y, X2: to.X, Y2: to.Y})
+	}
+	_ = s.tpl.ExecuteTemplate(w, "fragment_topology.html", map[string]any{
+		"nodes": nodes, "edges": edges, "width": topologyWidth, "height": topologyHeight,
+	})
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dashi/internal/api"
+	"dashi/internal/models"
+)
+
+func TestTopology(t *testing.T) {
+	s, repo := newTestServer(t, Options{})
+	ctx := context.Background()
+	app := []models.Network{{Name: "app_default"}, {Name: "bridge"}}
+	for _, st := range []struct {
+		name, dependsOn, status, health string
+		links                           []string
+	}{
+		{name: "web", dependsOn: "api:service_started:false", status: "running", links: []string{"app-cache-1"}},
+		{name: "api", dependsOn: "db:service_healthy:false,cache:service_started:false", status: "running", health: "unhealthy"},
+		{name: "db", status: "exited"},
+		{name: "cache", status: "running"},
+	} {
+		labels, _ := json.Marshal(map[string]string{"com.docker.compose.project": "app", "com.docker.compose.service": st.name, "com.docker.compose.depends_on": st.dependsOn})
+		svc := models.Service{ID: st.name, Name: st.name, Image: "img", LabelsJSON: string(labels), Status: st.status}
+		c := models.Container{ID: st.name + "1", ServiceID: st.name, Name: "app-" + st.name + "-1", Status: st.status, Health: st.health, Networks: app, Links: st.links}
+		if err := repo.UpsertServiceAndContainer(ctx, svc, c); err != nil {
+			t.Fatalf("store container: %v", err)
+		}
+	}
+	// A container replaced by a recreate stays listed as missing until it
+	// is archived; it neither counts nor degrades its service.
+	replaced := models.Container{ID: "web0", ServiceID: "web", Name: "app-web-1", Status: "missing", Networks: []models.Network{{Name: "old_net"}}}
+	if err := repo.UpsertServiceAndContainer(ctx, models.Service{ID: "web", Name: "web", Image: "img", LabelsJSON: `{"com.docker.compose.project":"app","com.docker.compose.service":"web","com.docker.compose.depends_on":"api:service_started:false"}`, Status: "running"}, replaced); err != nil {
+		t.Fatalf("store replaced container: %v", err)
+	}
+	rules, err := repo.ListRules(ctx)
+	if err != nil || len(rules) == 0 {
+		t.Fatalf("rules = %v, %v", rules, err)
+	}
+	if _, err := repo.CreateAlert(ctx, rules[0].ID, "cache1", "firing", "cache alert", nil, time.Now()); err != nil {
+		t.Fatalf("create alert: %v", err)
+	}
+	h := s.Routes()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/topology", nil))
+	var out api.Topology
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("topology = %d %s", rec.Code, rec.Body)
+	}
+	health := map[string]string{}
+	for _, n := range out.Nodes {
+		health[n.ID] = n.Health
+	}
+	want := map[string]string{"web": "ok", "api": "degraded", "db": "down", "cache": "alerting", "network:app_default": ""}
+	if len(health) != len(want) {
+		t.Fatalf("nodes = %+v, want the default bridge left out", out.Nodes)
+	}
+	for id, h := range want {
+		if got, ok := health[id]; !ok || got != h {
+			t.Fatalf("node %s health = %q, want %q", id, got, h)
+		}
+	}
+	for _, n := range out.Nodes {
+		if n.ID == "web" && (n.Containers != 1 || n.ContainerID != "web1") {
+			t.Fatalf("web node = %+v, want only the running container", n)
+		}
+	}
+	edges := map[string]bool{}
+	for _, e := range out.Edges {
+		edges[e.From+">"+e.To+":"+e.Kind] = true
+	}
+	for _, e := range []string{"web>api:depends_on", "api>db:depends_on", "api>cache:depends_on", "web>cache:link", "db>network:app_default:network"} {
+		if !edges[e] {
+			t.Fatalf("edges = %v, want %s", out.Edges, e)
+		}
+	}
+	if len(out.Edges) != 8 {
+		t.Fatalf("edges = %+v, want 8", out.Edges)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fragments/topology", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `class="topo-node topo-down"`) || !strings.Contains(body, `href="/containers/web1"`) || !strings.Contains(body, "marker-end") {
+		t.Fatalf("topology fragment:\n%s", body)
+	}
+}
+
+func TestLayoutTopologyStaysInView(t *testing.T) {
+	topo := api.Topology{}
+	for _, id := range []string{"a", "b", "c", "d", "network:n"} {
+		topo.Nodes = append(topo.Nodes, api.TopologyNode{ID: id})
+		if id != "network:n" {
+			topo.Edges = append(topo.Edges, api.TopologyEdge{From: id, To: "network:n", Kind: "network"})
+		}
+	}
+	pos := layoutTopology(topo)
+	again := layoutTopology(topo)
+	for id, p := range pos {
+		if p.X < 0 || p.X > topologyWidth || p.Y < 0 || p.Y > topologyHeight {
+			t.Fatalf("%s at %+v, outside the map", id, p)
+		}
+		if again[id] != p {
+			t.Fatalf("%s at %+v, then at %+v", id, p, again[id])
+		}
+	}
+	if a, b := pos["a"], pos["b"]; a == b {
+		t.Fatalf("a and b both at %+v", a)
+	}
+}